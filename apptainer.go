@@ -0,0 +1,66 @@
+package flowbase
+
+import (
+	"os/exec"
+)
+
+// ApptainerConfig contains configuration for running commands inside an
+// Apptainer (formerly Singularity) container, which is commonly available on
+// HPC clusters where Docker is not
+type ApptainerConfig struct {
+	// Image is the container image to use, such as a path to a .sif file or
+	// a URI like docker://ubuntu:22.04
+	Image string
+	// Binds are extra bind mounts, on the form src[:dest[:opts]]. The current
+	// working directory is bound by Apptainer by default.
+	Binds []string
+	// Executable is the Apptainer executable to use. Defaults to "apptainer",
+	// but can be set to "singularity" for older installations.
+	Executable string
+	// ExtraArgs are added to the exec sub-command, such as "--nv" for GPU
+	// support, or "--cleanenv"
+	ExtraArgs []string
+}
+
+// ApptainerExecutor runs commands inside Apptainer containers
+type ApptainerExecutor struct {
+	conf ApptainerConfig
+}
+
+// NewApptainerExecutor returns a new ApptainerExecutor, configured by conf
+func NewApptainerExecutor(conf ApptainerConfig) *ApptainerExecutor {
+	if conf.Image == "" {
+		Fail("No image set in Apptainer config")
+	}
+	if conf.Executable == "" {
+		conf.Executable = "apptainer"
+	}
+	return &ApptainerExecutor{conf: conf}
+}
+
+// SetApptainer makes the ExecProc run its commands inside an Apptainer
+// container, as configured by conf
+func (p *ExecProc) SetApptainer(conf ApptainerConfig) {
+	p.SetExecutor(NewApptainerExecutor(conf))
+}
+
+// Execute runs the command of task t in an Apptainer container, and records
+// the resources it used in the audit info of the task, as containers run as
+// child processes
+func (e *ApptainerExecutor) Execute(t *ExecTask, stdout func(line string)) error {
+	cmd := e.command(t.Command)
+	err := runCmdStreaming(cmd, t.Command, stdout)
+	recordUsage(t, cmd)
+	return err
+}
+
+// command returns a command running shellCmd in the configured container
+func (e *ApptainerExecutor) command(shellCmd string) *exec.Cmd {
+	args := []string{"exec"}
+	for _, bind := range e.conf.Binds {
+		args = append(args, "--bind", bind)
+	}
+	args = append(args, e.conf.ExtraArgs...)
+	args = append(args, e.conf.Image, "bash", "-c", shellCmd)
+	return exec.Command(e.conf.Executable, args...)
+}
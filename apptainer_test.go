@@ -0,0 +1,33 @@
+package flowbase
+
+import (
+	"testing"
+)
+
+func TestApptainerExecutor(t *testing.T) {
+	initTestLogs()
+	for _, executable := range []string{"", "singularity"} {
+		name := executable
+		if name == "" {
+			name = "apptainer"
+		}
+		stateDir := installFakeTool(t, name)
+
+		e := NewApptainerExecutor(ApptainerConfig{
+			Image:      "docker://ubuntu:22.04",
+			Binds:      []string{"/data", "/scratch:/tmp:rw"},
+			Executable: executable,
+			ExtraArgs:  []string{"--nv"},
+		})
+		task := executeFailingTask(t, e)
+
+		assertEqualValues(t, [][]string{{"exec",
+			"--bind", "/data",
+			"--bind", "/scratch:/tmp:rw",
+			"--nv",
+			"docker://ubuntu:22.04", "bash", "-c", task.Command}}, fakeToolCalls(stateDir, name))
+		if task.AuditInfo.ResourceUsage == nil {
+			t.Errorf("No resource usage recorded for %s", name)
+		}
+	}
+}
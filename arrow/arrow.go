@@ -0,0 +1,478 @@
+// Package arrow contains a minimal implementation of Apache Arrow record
+// batches, and the Arrow IPC streaming format, so that tabular data can flow
+// between processes in flowbase, and be handed to Arrow consumers, such as
+// DuckDB, Polars or pandas, without conversions. Columns keep the Arrow
+// memory layout, so that batches read from a stream refer to the bytes read,
+// without copying them. Only primitive types, strings and binary data are
+// supported, without dictionaries or compression. It only depends on the
+// standard library.
+package arrow
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Type is the type of the values of a column
+type Type int
+
+// The supported types
+const (
+	Int32 Type = iota + 1
+	Int64
+	Float32
+	Float64
+	Bool
+	// String is UTF-8 encoded text, the Arrow Utf8 type
+	String
+	Binary
+)
+
+var typeNames = map[Type]string{
+	Int32:   "int32",
+	Int64:   "int64",
+	Float32: "float32",
+	Float64: "float64",
+	Bool:    "bool",
+	String:  "string",
+	Binary:  "binary",
+}
+
+// String returns the name of the type, such as "int64"
+func (t Type) String() string {
+	if name, ok := typeNames[t]; ok {
+		return name
+	}
+	return "Type(" + strconv.Itoa(int(t)) + ")"
+}
+
+// ParseType returns the type named name (see Type.String)
+func ParseType(name string) (Type, error) {
+	for t, typeName := range typeNames {
+		if typeName == name {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown Arrow type: %s", name)
+}
+
+// width returns the size of the values of fixed width types, in bytes, or 0
+// for bools, strings and binary data
+func (t Type) width() int {
+	switch t {
+	case Int32, Float32:
+		return 4
+	case Int64, Float64:
+		return 8
+	}
+	return 0
+}
+
+// hasOffsets tells whether arrays of the type have an offsets buffer
+func (t Type) hasOffsets() bool {
+	return t == String || t == Binary
+}
+
+// Field is a column of a schema
+type Field struct {
+	Name     string
+	Type     Type
+	Nullable bool
+}
+
+// Schema describes the columns of record batches
+type Schema struct {
+	Fields []Field
+}
+
+// NewSchema returns a new schema with the fields fields
+func NewSchema(fields ...Field) *Schema {
+	return &Schema{Fields: fields}
+}
+
+// FieldIndex returns the index of the field named name, or -1 if there is
+// none
+func (s *Schema) FieldIndex(name string) int {
+	for i, f := range s.Fields {
+		if f.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Equal tells whether the schemas have the same fields
+func (s *Schema) Equal(other *Schema) bool {
+	if s == nil || other == nil {
+		return s == other
+	}
+	if len(s.Fields) != len(other.Fields) {
+		return false
+	}
+	for i := range s.Fields {
+		if s.Fields[i] != other.Fields[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ------------------------------------------------------------------------
+// Array
+// ------------------------------------------------------------------------
+
+// Array is a column of values, in the Arrow memory layout. Arrays are
+// immutable, and created with a Builder, or read from a stream.
+type Array struct {
+	typ       Type
+	length    int
+	nullCount int
+	// validity has a bit set for each value which is not null, or is nil if
+	// no value is null
+	validity []byte
+	// offsets are the int32 offsets of the values in values, for strings
+	// and binary data
+	offsets []byte
+	values  []byte
+}
+
+// Type returns the type of the values
+func (a *Array) Type() Type { return a.typ }
+
+// Len returns the number of values
+func (a *Array) Len() int { return a.length }
+
+// NullCount returns the number of null values
+func (a *Array) NullCount() int { return a.nullCount }
+
+// IsNull tells whether the value at index i is null
+func (a *Array) IsNull(i int) bool {
+	return a.nullCount > 0 && a.validity[i/8]&(1<<(i%8)) == 0
+}
+
+// Int32 returns the value at index i of an Int32 array
+func (a *Array) Int32(i int) int32 {
+	return int32(binary.LittleEndian.Uint32(a.values[4*i:]))
+}
+
+// Int64 returns the value at index i of an Int64 array
+func (a *Array) Int64(i int) int64 {
+	return int64(binary.LittleEndian.Uint64(a.values[8*i:]))
+}
+
+// Float32 returns the value at index i of a Float32 array
+func (a *Array) Float32(i int) float32 {
+	return math.Float32frombits(binary.LittleEndian.Uint32(a.values[4*i:]))
+}
+
+// Float64 returns the value at index i of a Float64 array
+func (a *Array) Float64(i int) float64 {
+	return math.Float64frombits(binary.LittleEndian.Uint64(a.values[8*i:]))
+}
+
+// Bool returns the value at index i of a Bool array
+func (a *Array) Bool(i int) bool {
+	return a.values[i/8]&(1<<(i%8)) != 0
+}
+
+// Bytes returns the value at index i of a String or Binary array, which
+// refers to the memory of the array, and must not be modified
+func (a *Array) Bytes(i int) []byte {
+	start := binary.LittleEndian.Uint32(a.offsets[4*i:])
+	end := binary.LittleEndian.Uint32(a.offsets[4*i+4:])
+	return a.values[start:end]
+}
+
+// String returns the value at index i of a String or Binary array
+func (a *Array) String(i int) string {
+	return string(a.Bytes(i))
+}
+
+// Value returns the value at index i, as the Go type of the type of the
+// array (int32, int64, float32, float64, bool, string or []byte), or nil if
+// it is null
+func (a *Array) Value(i int) any {
+	if a.IsNull(i) {
+		return nil
+	}
+	switch a.typ {
+	case Int32:
+		return a.Int32(i)
+	case Int64:
+		return a.Int64(i)
+	case Float32:
+		return a.Float32(i)
+	case Float64:
+		return a.Float64(i)
+	case Bool:
+		return a.Bool(i)
+	case String:
+		return a.String(i)
+	case Binary:
+		return a.Bytes(i)
+	}
+	return nil
+}
+
+// ------------------------------------------------------------------------
+// Builder
+// ------------------------------------------------------------------------
+
+// Builder builds an Array, by appending values to it
+type Builder struct {
+	typ       Type
+	length    int
+	nullCount int
+	validity  []byte
+	offsets   []byte
+	values    []byte
+}
+
+// NewBuilder returns a new Builder of an array of the type typ
+func NewBuilder(typ Type) *Builder {
+	b := &Builder{typ: typ}
+	if typ.hasOffsets() {
+		b.offsets = appendUint32(nil, 0)
+	}
+	return b
+}
+
+// AppendNull appends a null value
+func (b *Builder) AppendNull() {
+	if b.validity == nil {
+		// All values so far were valid
+		b.validity = make([]byte, (b.length+8)/8)
+		for i := 0; i < b.length; i++ {
+			b.validity[i/8] |= 1 << (i % 8)
+		}
+	}
+	b.nullCount++
+	b.appendValue(nil)
+}
+
+// Append appends the value v, which is converted to the type of the array
+// from any Go number, bool, string or []byte type that fits it, and is
+// appended as a null value if nil
+func (b *Builder) Append(v any) error {
+	if v == nil {
+		b.AppendNull()
+		return nil
+	}
+	encoded, err := b.encode(v)
+	if err != nil {
+		return err
+	}
+	if b.validity != nil {
+		if b.length%8 == 0 {
+			b.validity = append(b.validity, 0)
+		}
+		b.validity[b.length/8] |= 1 << (b.length % 8)
+	}
+	b.appendValue(encoded)
+	return nil
+}
+
+// appendValue appends the encoded value, or a zero value if it is nil, and
+// the offset of the next value, for strings and binary data
+func (b *Builder) appendValue(encoded []byte) {
+	switch {
+	case b.typ == Bool:
+		if b.length%8 == 0 {
+			b.values = append(b.values, 0)
+		}
+		if len(encoded) > 0 && encoded[0] != 0 {
+			b.values[b.length/8] |= 1 << (b.length % 8)
+		}
+	case b.typ.hasOffsets():
+		b.values = append(b.values, encoded...)
+		b.offsets = appendUint32(b.offsets, uint32(len(b.values)))
+	default:
+		if encoded == nil {
+			encoded = make([]byte, b.typ.width())
+		}
+		b.values = append(b.values, encoded...)
+	}
+	if b.validity != nil && len(b.validity) <= b.length/8 {
+		b.validity = append(b.validity, 0)
+	}
+	b.length++
+}
+
+// encode returns the value v encoded in the Arrow layout of the type of the
+// array
+func (b *Builder) encode(v any) ([]byte, error) {
+	switch b.typ {
+	case Int32, Int64:
+		n, ok := toInt64(v)
+		if !ok || (b.typ == Int32 && (n < math.MinInt32 || n > math.MaxInt32)) {
+			return nil, fmt.Errorf("can not append %v (%T) to an %s array", v, v, b.typ)
+		}
+		if b.typ == Int32 {
+			return appendUint32(nil, uint32(n)), nil
+		}
+		return appendUint64(nil, uint64(n)), nil
+	case Float32, Float64:
+		f, ok := toFloat64(v)
+		if !ok {
+			return nil, fmt.Errorf("can not append %v (%T) to a %s array", v, v, b.typ)
+		}
+		if b.typ == Float32 {
+			return appendUint32(nil, math.Float32bits(float32(f))), nil
+		}
+		return appendUint64(nil, math.Float64bits(f)), nil
+	case Bool:
+		t, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("can not append %v (%T) to a bool array", v, v)
+		}
+		if t {
+			return []byte{1}, nil
+		}
+		return []byte{0}, nil
+	case String, Binary:
+		switch s := v.(type) {
+		case string:
+			return []byte(s), nil
+		case []byte:
+			return s, nil
+		}
+		return nil, fmt.Errorf("can not append %v (%T) to a %s array", v, v, b.typ)
+	}
+	return nil, fmt.Errorf("unsupported type: %s", b.typ)
+}
+
+func toInt64(v any) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int8:
+		return int64(n), true
+	case int16:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint8:
+		return int64(n), true
+	case uint16:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	}
+	return 0, false
+}
+
+func toFloat64(v any) (float64, bool) {
+	switch f := v.(type) {
+	case float32:
+		return float64(f), true
+	case float64:
+		return f, true
+	}
+	if n, ok := toInt64(v); ok {
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// Array returns the array of the appended values. The builder must not be
+// used afterwards.
+func (b *Builder) Array() *Array {
+	a := &Array{
+		typ:       b.typ,
+		length:    b.length,
+		nullCount: b.nullCount,
+		offsets:   b.offsets,
+		values:    b.values,
+	}
+	if b.nullCount > 0 {
+		a.validity = b.validity
+	}
+	return a
+}
+
+// ------------------------------------------------------------------------
+// RecordBatch
+// ------------------------------------------------------------------------
+
+// RecordBatch is a table of rows, stored as one Array per column, which is
+// the unit in which tabular data is sent in packets
+type RecordBatch struct {
+	schema  *Schema
+	columns []*Array
+	numRows int
+}
+
+// NewRecordBatch returns a new record batch, with the columns columns, of
+// the types of the fields of schema, which all have the same length
+func NewRecordBatch(schema *Schema, columns []*Array) (*RecordBatch, error) {
+	if len(columns) != len(schema.Fields) {
+		return nil, fmt.Errorf("got %d columns for a schema with %d fields", len(columns), len(schema.Fields))
+	}
+	numRows := 0
+	for i, col := range columns {
+		field := schema.Fields[i]
+		if col.typ != field.Type {
+			return nil, fmt.Errorf("column %s is of type %s, but should be %s", field.Name, col.typ, field.Type)
+		}
+		if i == 0 {
+			numRows = col.length
+		} else if col.length != numRows {
+			return nil, fmt.Errorf("column %s has %d rows, but column %s has %d", field.Name, col.length, schema.Fields[0].Name, numRows)
+		}
+		if col.nullCount > 0 && !field.Nullable {
+			return nil, fmt.Errorf("column %s has null values, but is not nullable", field.Name)
+		}
+	}
+	return &RecordBatch{schema: schema, columns: columns, numRows: numRows}, nil
+}
+
+// NewRecordBatchFromRows returns a new record batch, with the rows rows,
+// which have the values of the columns by field name. Missing values are
+// null.
+func NewRecordBatchFromRows(schema *Schema, rows []map[string]any) (*RecordBatch, error) {
+	columns := make([]*Array, len(schema.Fields))
+	for i, field := range schema.Fields {
+		b := NewBuilder(field.Type)
+		for _, row := range rows {
+			if err := b.Append(row[field.Name]); err != nil {
+				return nil, fmt.Errorf("invalid value of column %s: %v", field.Name, err)
+			}
+		}
+		columns[i] = b.Array()
+	}
+	return NewRecordBatch(schema, columns)
+}
+
+// Schema returns the schema of the batch
+func (r *RecordBatch) Schema() *Schema { return r.schema }
+
+// NumRows returns the number of rows
+func (r *RecordBatch) NumRows() int { return r.numRows }
+
+// NumCols returns the number of columns
+func (r *RecordBatch) NumCols() int { return len(r.columns) }
+
+// Column returns the column at index i
+func (r *RecordBatch) Column(i int) *Array { return r.columns[i] }
+
+// ColumnByName returns the column named name, or nil if there is none
+func (r *RecordBatch) ColumnByName(name string) *Array {
+	if i := r.schema.FieldIndex(name); i >= 0 {
+		return r.columns[i]
+	}
+	return nil
+}
+
+// Row returns the values of the row at index i, by column name (see
+// Array.Value)
+func (r *RecordBatch) Row(i int) map[string]any {
+	row := make(map[string]any, len(r.columns))
+	for j, field := range r.schema.Fields {
+		row[field.Name] = r.columns[j].Value(i)
+	}
+	return row
+}
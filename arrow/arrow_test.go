@@ -0,0 +1,169 @@
+package arrow
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestStreamRoundTrip(t *testing.T) {
+	schema := NewSchema(
+		Field{Name: "i32", Type: Int32, Nullable: true},
+		Field{Name: "i64", Type: Int64},
+		Field{Name: "f32", Type: Float32},
+		Field{Name: "f64", Type: Float64, Nullable: true},
+		Field{Name: "b", Type: Bool, Nullable: true},
+		Field{Name: "s", Type: String, Nullable: true},
+		Field{Name: "bin", Type: Binary},
+	)
+	// More than 8 rows, so that the bitmaps span several bytes
+	rows := []map[string]any{}
+	for i := 0; i < 11; i++ {
+		row := map[string]any{
+			"i32": int32(i),
+			"i64": int64(i) << 40,
+			"f32": float32(i) / 2,
+			"f64": float64(i) / 4,
+			"b":   i%3 == 0,
+			"s":   string(rune('a' + i)),
+			"bin": []byte{byte(i)},
+		}
+		if i%4 == 1 {
+			row["i32"], row["f64"], row["b"], row["s"] = nil, nil, nil, nil
+		}
+		rows = append(rows, row)
+	}
+	batch, err := NewRecordBatchFromRows(schema, rows)
+	if err != nil {
+		t.Fatalf("Could not create record batch: %v", err)
+	}
+	empty, err := NewRecordBatchFromRows(schema, nil)
+	if err != nil {
+		t.Fatalf("Could not create record batch: %v", err)
+	}
+
+	buf := &bytes.Buffer{}
+	w := NewStreamWriter(buf)
+	if err := w.Write(batch, map[string]string{"k": "v"}); err != nil {
+		t.Fatalf("Could not write record batch: %v", err)
+	}
+	if err := w.Write(empty, nil); err != nil {
+		t.Fatalf("Could not write record batch: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Could not close stream: %v", err)
+	}
+	if buf.Len()%8 != 0 {
+		t.Errorf("Stream length %d is not a multiple of 8", buf.Len())
+	}
+
+	r := NewStreamReader(buf)
+	got, metadata, err := r.Read()
+	if err != nil {
+		t.Fatalf("Could not read record batch: %v", err)
+	}
+	if !got.Schema().Equal(schema) {
+		t.Errorf("Expected schema %v, got %v", schema, got.Schema())
+	}
+	if !reflect.DeepEqual(metadata, map[string]string{"k": "v"}) {
+		t.Errorf("Unexpected metadata: %v", metadata)
+	}
+	if got.NumRows() != len(rows) {
+		t.Fatalf("Expected %d rows, got %d", len(rows), got.NumRows())
+	}
+	for i, row := range rows {
+		if gotRow := got.Row(i); !reflect.DeepEqual(gotRow, row) {
+			t.Errorf("Expected row %d to be %v, got %v", i, row, gotRow)
+		}
+	}
+	if n := got.ColumnByName("s").NullCount(); n != 3 {
+		t.Errorf("Expected 3 null strings, got %d", n)
+	}
+
+	got, _, err = r.Read()
+	if err != nil {
+		t.Fatalf("Could not read record batch: %v", err)
+	}
+	if got.NumRows() != 0 {
+		t.Errorf("Expected an empty record batch, got %d rows", got.NumRows())
+	}
+	if _, _, err := r.Read(); err != io.EOF {
+		t.Errorf("Expected io.EOF at end of stream, got: %v", err)
+	}
+}
+
+func TestStreamReaderLegacyFormat(t *testing.T) {
+	schema := NewSchema(Field{Name: "n", Type: Int64})
+	batch, err := NewRecordBatchFromRows(schema, []map[string]any{{"n": 1}, {"n": 2}})
+	if err != nil {
+		t.Fatalf("Could not create record batch: %v", err)
+	}
+	buf := &bytes.Buffer{}
+	if err := NewStreamWriter(buf).Write(batch, nil); err != nil {
+		t.Fatalf("Could not write record batch: %v", err)
+	}
+
+	// Strip the continuation markers, as written before Arrow 0.15
+	stream := buf.Bytes()
+	legacy := []byte{}
+	for len(stream) > 0 {
+		size := binary.LittleEndian.Uint32(stream[4:])
+		msgLen := 8 + int(size)
+		var bodyLength int64
+		if err := fbDecode(stream[8:msgLen], func(msg fbReader) error {
+			bodyLength = msg.int64(3, 0)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		legacy = append(legacy, stream[4:msgLen+int(bodyLength)]...)
+		stream = stream[msgLen+int(bodyLength):]
+	}
+
+	got, _, err := NewStreamReader(bytes.NewReader(legacy)).Read()
+	if err != nil {
+		t.Fatalf("Could not read legacy stream: %v", err)
+	}
+	if n := got.Column(0).Int64(1); n != 2 {
+		t.Errorf("Expected 2, got %d", n)
+	}
+}
+
+func TestStreamReaderMalformed(t *testing.T) {
+	schema := NewSchema(Field{Name: "s", Type: String})
+	batch, err := NewRecordBatchFromRows(schema, []map[string]any{{"s": "abc"}})
+	if err != nil {
+		t.Fatalf("Could not create record batch: %v", err)
+	}
+	buf := &bytes.Buffer{}
+	if err := NewStreamWriter(buf).Write(batch, nil); err != nil {
+		t.Fatalf("Could not write record batch: %v", err)
+	}
+	// Corrupting any byte, or truncating the stream, must not panic
+	stream := buf.Bytes()
+	for i := range stream {
+		corrupted := append([]byte{}, stream...)
+		corrupted[i] ^= 0x5a
+		NewStreamReader(bytes.NewReader(corrupted)).Read()
+		if _, _, err := NewStreamReader(bytes.NewReader(stream[:i])).Read(); err == nil {
+			t.Errorf("Expected an error reading a stream truncated to %d bytes", i)
+		}
+	}
+}
+
+func TestBuilderErrors(t *testing.T) {
+	if err := NewBuilder(Int32).Append(int64(1) << 40); err == nil {
+		t.Errorf("Expected an error appending an out of range value")
+	}
+	if err := NewBuilder(String).Append(1); err == nil {
+		t.Errorf("Expected an error appending an int to a string array")
+	}
+	b := NewBuilder(Int64)
+	b.AppendNull()
+	schema := NewSchema(Field{Name: "n", Type: Int64})
+	if _, err := NewRecordBatch(schema, []*Array{b.Array()}); err == nil {
+		t.Errorf("Expected an error for null values in a column which is not nullable")
+	}
+}
@@ -0,0 +1,323 @@
+package arrow
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Minimal FlatBuffers encoding and decoding, for the metadata of Arrow IPC
+// messages. Objects are written front to back, with tables before the
+// objects they refer to, since offsets to objects must point forward.
+
+// fbObject is an object which can be written to a FlatBuffer: a table, a
+// vector or a string
+type fbObject interface {
+	// write writes the object, and the objects it refers to, and returns
+	// its position, which is where offsets to it point
+	write(b *fbBuilder) int
+}
+
+// fbBuilder accumulates a FlatBuffer
+type fbBuilder struct {
+	buf []byte
+}
+
+// fbFinish returns a FlatBuffer with the table root as its root, padded to a
+// multiple of 8 bytes
+func fbFinish(root fbObject) []byte {
+	b := &fbBuilder{buf: make([]byte, 4, 256)}
+	b.patch(0, root.write(b))
+	b.pad(8)
+	return b.buf
+}
+
+// pad appends zeros until the length of the buffer is a multiple of align
+func (b *fbBuilder) pad(align int) {
+	for len(b.buf)%align != 0 {
+		b.buf = append(b.buf, 0)
+	}
+}
+
+// patch sets the offset at the position at to point to the position target
+func (b *fbBuilder) patch(at int, target int) {
+	binary.LittleEndian.PutUint32(b.buf[at:], uint32(target-at))
+}
+
+func (b *fbBuilder) appendUint32(v uint32) {
+	b.buf = appendUint32(b.buf, v)
+}
+
+func appendUint16(buf []byte, v uint16) []byte {
+	return append(buf, byte(v), byte(v>>8))
+}
+
+func appendUint32(buf []byte, v uint32) []byte {
+	return append(buf, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendUint64(buf []byte, v uint64) []byte {
+	return appendUint32(appendUint32(buf, uint32(v)), uint32(v>>32))
+}
+
+// fbField is a field of a table, which is either a scalar, or an offset to
+// a child object. The zero value is an absent field.
+type fbField struct {
+	scalar []byte
+	child  fbObject
+}
+
+func fbUint8(v uint8) fbField { return fbField{scalar: []byte{v}} }
+
+func fbBool(v bool) fbField {
+	if v {
+		return fbUint8(1)
+	}
+	return fbUint8(0)
+}
+
+func fbInt16(v int16) fbField {
+	return fbField{scalar: appendUint16(nil, uint16(v))}
+}
+
+func fbInt32(v int32) fbField {
+	return fbField{scalar: appendUint32(nil, uint32(v))}
+}
+
+func fbInt64(v int64) fbField {
+	return fbField{scalar: appendUint64(nil, uint64(v))}
+}
+
+func fbChild(child fbObject) fbField { return fbField{child: child} }
+
+// size returns the size of the field inline in the table, which is also its
+// alignment
+func (f fbField) size() int {
+	if f.child != nil {
+		return 4
+	}
+	return len(f.scalar)
+}
+
+// fbTable is a table, with its fields by slot
+type fbTable []fbField
+
+func (t fbTable) write(b *fbBuilder) int {
+	// Lay out the fields after the offset to the vtable, aligned to their
+	// sizes, with the table aligned to the largest of them
+	maxAlign := 4
+	for _, f := range t {
+		if f.size() > maxAlign {
+			maxAlign = f.size()
+		}
+	}
+	offsets := make([]int, len(t))
+	size := 4
+	for i, f := range t {
+		if f.size() == 0 {
+			continue
+		}
+		for size%f.size() != 0 {
+			size++
+		}
+		offsets[i] = size
+		size += f.size()
+	}
+
+	b.pad(2)
+	vtPos := len(b.buf)
+	b.buf = appendUint16(b.buf, uint16(4+2*len(t)))
+	b.buf = appendUint16(b.buf, uint16(size))
+	for _, off := range offsets {
+		b.buf = appendUint16(b.buf, uint16(off))
+	}
+	b.pad(maxAlign)
+	pos := len(b.buf)
+	b.buf = append(b.buf, make([]byte, size)...)
+	binary.LittleEndian.PutUint32(b.buf[pos:], uint32(int32(pos-vtPos)))
+	for i, f := range t {
+		if f.scalar != nil {
+			copy(b.buf[pos+offsets[i]:], f.scalar)
+		}
+	}
+	for i, f := range t {
+		if f.child != nil {
+			b.patch(pos+offsets[i], f.child.write(b))
+		}
+	}
+	return pos
+}
+
+// fbVector is a vector of tables or strings
+type fbVector []fbObject
+
+func (v fbVector) write(b *fbBuilder) int {
+	b.pad(4)
+	pos := len(b.buf)
+	b.appendUint32(uint32(len(v)))
+	b.buf = append(b.buf, make([]byte, 4*len(v))...)
+	for i, elem := range v {
+		b.patch(pos+4+4*i, elem.write(b))
+	}
+	return pos
+}
+
+// fbStructVector is a vector of structs of int64s, such as Arrow FieldNodes
+// and Buffers, which all have two int64 fields
+type fbStructVector [][2]int64
+
+func (v fbStructVector) write(b *fbBuilder) int {
+	// The structs are aligned to 8 bytes, after the length
+	for (len(b.buf)+4)%8 != 0 {
+		b.buf = append(b.buf, 0)
+	}
+	pos := len(b.buf)
+	b.appendUint32(uint32(len(v)))
+	for _, s := range v {
+		b.buf = appendUint64(b.buf, uint64(s[0]))
+		b.buf = appendUint64(b.buf, uint64(s[1]))
+	}
+	return pos
+}
+
+// fbString is a string
+type fbString string
+
+func (s fbString) write(b *fbBuilder) int {
+	b.pad(4)
+	pos := len(b.buf)
+	b.appendUint32(uint32(len(s)))
+	b.buf = append(append(b.buf, s...), 0)
+	return pos
+}
+
+// ------------------------------------------------------------------------
+// Decoding
+// ------------------------------------------------------------------------
+
+// fbReader is a table in a FlatBuffer being decoded. Out of bounds offsets
+// in malformed buffers make the methods panic, which is recovered from by
+// fbDecode.
+type fbReader struct {
+	buf []byte
+	pos int
+}
+
+// fbDecode calls decode with the root table of the FlatBuffer buf, and
+// returns an error instead of panicking on malformed buffers
+func fbDecode(buf []byte, decode func(root fbReader) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("malformed flatbuffer: %v", r)
+		}
+	}()
+	return decode(fbReader{buf: buf, pos: int(binary.LittleEndian.Uint32(buf))})
+}
+
+// field returns the position of the field in the slot slot, or 0 if it is
+// absent
+func (t fbReader) field(slot int) int {
+	vt := t.pos - int(int32(binary.LittleEndian.Uint32(t.buf[t.pos:])))
+	vtSize := int(binary.LittleEndian.Uint16(t.buf[vt:]))
+	if 4+2*slot >= vtSize {
+		return 0
+	}
+	off := int(binary.LittleEndian.Uint16(t.buf[vt+4+2*slot:]))
+	if off == 0 {
+		return 0
+	}
+	return t.pos + off
+}
+
+func (t fbReader) uint8(slot int, def uint8) uint8 {
+	if p := t.field(slot); p != 0 {
+		return t.buf[p]
+	}
+	return def
+}
+
+func (t fbReader) bool(slot int) bool {
+	return t.uint8(slot, 0) != 0
+}
+
+func (t fbReader) int16(slot int, def int16) int16 {
+	if p := t.field(slot); p != 0 {
+		return int16(binary.LittleEndian.Uint16(t.buf[p:]))
+	}
+	return def
+}
+
+func (t fbReader) int32(slot int, def int32) int32 {
+	if p := t.field(slot); p != 0 {
+		return int32(binary.LittleEndian.Uint32(t.buf[p:]))
+	}
+	return def
+}
+
+func (t fbReader) int64(slot int, def int64) int64 {
+	if p := t.field(slot); p != 0 {
+		return int64(binary.LittleEndian.Uint64(t.buf[p:]))
+	}
+	return def
+}
+
+// deref returns the position the offset at the position p points to
+func (t fbReader) deref(p int) int {
+	return p + int(binary.LittleEndian.Uint32(t.buf[p:]))
+}
+
+// table returns the table in the slot slot, if present
+func (t fbReader) table(slot int) (fbReader, bool) {
+	p := t.field(slot)
+	if p == 0 {
+		return fbReader{}, false
+	}
+	return fbReader{buf: t.buf, pos: t.deref(p)}, true
+}
+
+func (t fbReader) string(slot int) string {
+	p := t.field(slot)
+	if p == 0 {
+		return ""
+	}
+	s := t.deref(p)
+	n := int(binary.LittleEndian.Uint32(t.buf[s:]))
+	return string(t.buf[s+4 : s+4+n])
+}
+
+// vector returns the position of the first element, and the length, of the
+// vector in the slot slot, or a length of 0 if it is absent
+func (t fbReader) vector(slot int) (start int, n int) {
+	p := t.field(slot)
+	if p == 0 {
+		return 0, 0
+	}
+	v := t.deref(p)
+	n = int(binary.LittleEndian.Uint32(t.buf[v:]))
+	// Check the length before allocating slices of that many elements,
+	// which are all at least 4 bytes
+	if v+4+4*n > len(t.buf) {
+		panic(fmt.Sprintf("vector of %d elements out of bounds", n))
+	}
+	return v + 4, n
+}
+
+// tables returns the tables of the vector of tables in the slot slot
+func (t fbReader) tables(slot int) []fbReader {
+	start, n := t.vector(slot)
+	tables := make([]fbReader, n)
+	for i := range tables {
+		tables[i] = fbReader{buf: t.buf, pos: t.deref(start + 4*i)}
+	}
+	return tables
+}
+
+// structs returns the structs of two int64s of the vector in the slot slot
+func (t fbReader) structs(slot int) [][2]int64 {
+	start, n := t.vector(slot)
+	structs := make([][2]int64, n)
+	for i := range structs {
+		p := start + 16*i
+		structs[i] = [2]int64{int64(binary.LittleEndian.Uint64(t.buf[p:])), int64(binary.LittleEndian.Uint64(t.buf[p+8:]))}
+	}
+	return structs
+}
@@ -0,0 +1,414 @@
+package arrow
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// The Arrow IPC streaming format: a schema message, followed by record
+// batch messages, and an end-of-stream marker. Each message is the marker
+// 0xFFFFFFFF, the length of its FlatBuffer metadata, the metadata, and the
+// body with the buffers of the columns, all aligned to 8 bytes.
+
+const (
+	continuationMarker = 0xFFFFFFFF
+	metadataVersionV5  = 4
+
+	headerSchema      = 1
+	headerRecordBatch = 3
+
+	typeInt           = 2
+	typeFloatingPoint = 3
+	typeBinary        = 4
+	typeUtf8          = 5
+	typeBool          = 6
+
+	precisionSingle = 1
+	precisionDouble = 2
+)
+
+// ------------------------------------------------------------------------
+// StreamWriter
+// ------------------------------------------------------------------------
+
+// StreamWriter writes record batches in the Arrow IPC streaming format
+type StreamWriter struct {
+	w      io.Writer
+	schema *Schema
+}
+
+// NewStreamWriter returns a new StreamWriter writing to w
+func NewStreamWriter(w io.Writer) *StreamWriter {
+	return &StreamWriter{w: w}
+}
+
+// Write writes the record batch batch, with the custom metadata metadata,
+// which may be nil. The schema is written before the first batch. If the
+// schema of batch differs from the one of the previous batch, the stream is
+// ended, and a new one started with the new schema, which StreamReader reads
+// as a single stream.
+func (sw *StreamWriter) Write(batch *RecordBatch, metadata map[string]string) error {
+	if !batch.schema.Equal(sw.schema) {
+		if sw.schema != nil {
+			if err := sw.writeEOS(); err != nil {
+				return err
+			}
+		}
+		msg := fbTable{
+			fbInt16(metadataVersionV5),
+			fbUint8(headerSchema),
+			fbChild(encodeSchema(batch.schema)),
+			fbInt64(0),
+		}
+		if err := sw.writeMessage(msg, nil); err != nil {
+			return err
+		}
+		sw.schema = batch.schema
+	}
+
+	var body []byte
+	nodes := fbStructVector{}
+	buffers := fbStructVector{}
+	addBuffer := func(buf []byte) {
+		buffers = append(buffers, [2]int64{int64(len(body)), int64(len(buf))})
+		body = append(body, buf...)
+		for len(body)%8 != 0 {
+			body = append(body, 0)
+		}
+	}
+	for _, col := range batch.columns {
+		nodes = append(nodes, [2]int64{int64(col.length), int64(col.nullCount)})
+		addBuffer(col.validity)
+		if col.typ.hasOffsets() {
+			addBuffer(col.offsets)
+		}
+		addBuffer(col.values)
+	}
+	msg := fbTable{
+		fbInt16(metadataVersionV5),
+		fbUint8(headerRecordBatch),
+		fbChild(fbTable{fbInt64(int64(batch.numRows)), fbChild(nodes), fbChild(buffers)}),
+		fbInt64(int64(len(body))),
+		{},
+	}
+	if len(metadata) > 0 {
+		keys := make([]string, 0, len(metadata))
+		for key := range metadata {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		keyValues := fbVector{}
+		for _, key := range keys {
+			keyValues = append(keyValues, fbTable{fbChild(fbString(key)), fbChild(fbString(metadata[key]))})
+		}
+		msg[4] = fbChild(keyValues)
+	}
+	return sw.writeMessage(msg, body)
+}
+
+// Close ends the stream. It does not close the underlying writer.
+func (sw *StreamWriter) Close() error {
+	return sw.writeEOS()
+}
+
+func (sw *StreamWriter) writeMessage(msg fbTable, body []byte) error {
+	metadata := fbFinish(msg)
+	header := appendUint32(appendUint32(nil, continuationMarker), uint32(len(metadata)))
+	if _, err := sw.w.Write(header); err != nil {
+		return err
+	}
+	if _, err := sw.w.Write(metadata); err != nil {
+		return err
+	}
+	_, err := sw.w.Write(body)
+	return err
+}
+
+func (sw *StreamWriter) writeEOS() error {
+	_, err := sw.w.Write(appendUint32(appendUint32(nil, continuationMarker), 0))
+	return err
+}
+
+func encodeSchema(schema *Schema) fbTable {
+	fields := fbVector{}
+	for _, f := range schema.Fields {
+		var typeType uint8
+		var typ fbTable
+		switch f.Type {
+		case Int32:
+			typeType, typ = typeInt, fbTable{fbInt32(32), fbBool(true)}
+		case Int64:
+			typeType, typ = typeInt, fbTable{fbInt32(64), fbBool(true)}
+		case Float32:
+			typeType, typ = typeFloatingPoint, fbTable{fbInt16(precisionSingle)}
+		case Float64:
+			typeType, typ = typeFloatingPoint, fbTable{fbInt16(precisionDouble)}
+		case Bool:
+			typeType, typ = typeBool, fbTable{}
+		case String:
+			typeType, typ = typeUtf8, fbTable{}
+		case Binary:
+			typeType, typ = typeBinary, fbTable{}
+		}
+		fields = append(fields, fbTable{
+			fbChild(fbString(f.Name)),
+			fbBool(f.Nullable),
+			fbUint8(typeType),
+			fbChild(typ),
+			{},
+			fbChild(fbVector{}),
+		})
+	}
+	// Little endian is the default endianness, in slot 0
+	return fbTable{{}, fbChild(fields)}
+}
+
+// ------------------------------------------------------------------------
+// StreamReader
+// ------------------------------------------------------------------------
+
+// StreamReader reads record batches in the Arrow IPC streaming format, as
+// written by StreamWriter, or by other Arrow implementations. The columns of
+// the batches refer to the bytes read, without copying them.
+type StreamReader struct {
+	r      io.Reader
+	schema *Schema
+}
+
+// NewStreamReader returns a new StreamReader reading from r
+func NewStreamReader(r io.Reader) *StreamReader {
+	return &StreamReader{r: r}
+}
+
+// Schema returns the schema of the batches read last, or nil if none has
+// been read yet
+func (sr *StreamReader) Schema() *Schema {
+	return sr.schema
+}
+
+// Read returns the next record batch, and its custom metadata, or io.EOF at
+// the end of the stream. Streams following the end of a stream are read as
+// part of it, such as when StreamWriter changes schemas.
+func (sr *StreamReader) Read() (*RecordBatch, map[string]string, error) {
+	for {
+		metadata, err := sr.readMetadata()
+		if err != nil {
+			return nil, nil, err
+		}
+		if metadata == nil {
+			// End of stream
+			sr.schema = nil
+			continue
+		}
+		var headerType uint8
+		var bodyLength int64
+		var header fbReader
+		var customMetadata map[string]string
+		err = fbDecode(metadata, func(msg fbReader) error {
+			if version := msg.int16(0, 0); version < metadataVersionV5 {
+				return fmt.Errorf("unsupported Arrow metadata version: %d", version)
+			}
+			headerType = msg.uint8(1, 0)
+			var ok bool
+			if header, ok = msg.table(2); !ok {
+				return errors.New("message has no header")
+			}
+			bodyLength = msg.int64(3, 0)
+			for _, kv := range msg.tables(4) {
+				if customMetadata == nil {
+					customMetadata = map[string]string{}
+				}
+				customMetadata[kv.string(0)] = kv.string(1)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		if bodyLength < 0 {
+			return nil, nil, fmt.Errorf("invalid Arrow message body length: %d", bodyLength)
+		}
+		// The body is read as it arrives, rather than allocated upfront, so
+		// that invalid lengths fail without exhausting memory
+		body, err := io.ReadAll(io.LimitReader(sr.r, bodyLength))
+		if err == nil && int64(len(body)) < bodyLength {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not read Arrow message body: %v", err)
+		}
+
+		switch headerType {
+		case headerSchema:
+			var schema *Schema
+			if err := fbDecode(metadata, func(fbReader) error {
+				schema, err = decodeSchema(header)
+				return err
+			}); err != nil {
+				return nil, nil, err
+			}
+			sr.schema = schema
+		case headerRecordBatch:
+			if sr.schema == nil {
+				return nil, nil, errors.New("got an Arrow record batch before a schema")
+			}
+			var batch *RecordBatch
+			if err := fbDecode(metadata, func(fbReader) error {
+				batch, err = decodeRecordBatch(sr.schema, header, body)
+				return err
+			}); err != nil {
+				return nil, nil, err
+			}
+			return batch, customMetadata, nil
+		default:
+			return nil, nil, fmt.Errorf("unsupported Arrow message type: %d", headerType)
+		}
+	}
+}
+
+// readMetadata reads the FlatBuffer metadata of the next message, or
+// returns nil at the end of a stream
+func (sr *StreamReader) readMetadata() ([]byte, error) {
+	var word [4]byte
+	if _, err := io.ReadFull(sr.r, word[:]); err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, fmt.Errorf("could not read Arrow message: %v", err)
+	}
+	size := binary.LittleEndian.Uint32(word[:])
+	if size == continuationMarker {
+		if _, err := io.ReadFull(sr.r, word[:]); err != nil {
+			return nil, fmt.Errorf("could not read Arrow message: %v", err)
+		}
+		size = binary.LittleEndian.Uint32(word[:])
+	}
+	// Otherwise, the stream is in the format before Arrow 0.15, without the
+	// continuation marker
+	if size == 0 {
+		return nil, nil
+	}
+	if size > 1<<30 {
+		return nil, fmt.Errorf("invalid Arrow metadata length: %d", size)
+	}
+	metadata := make([]byte, size)
+	if _, err := io.ReadFull(sr.r, metadata); err != nil {
+		return nil, fmt.Errorf("could not read Arrow message metadata: %v", err)
+	}
+	return metadata, nil
+}
+
+func decodeSchema(schema fbReader) (*Schema, error) {
+	if schema.int16(0, 0) != 0 {
+		return nil, errors.New("big endian Arrow data is not supported")
+	}
+	s := &Schema{}
+	for _, field := range schema.tables(1) {
+		f := Field{Name: field.string(0), Nullable: field.bool(1)}
+		typ, ok := field.table(3)
+		if !ok {
+			return nil, fmt.Errorf("field %s has no type", f.Name)
+		}
+		switch typeType := field.uint8(2, 0); {
+		case typeType == typeInt && typ.bool(1) && typ.int32(0, 0) == 32:
+			f.Type = Int32
+		case typeType == typeInt && typ.bool(1) && typ.int32(0, 0) == 64:
+			f.Type = Int64
+		case typeType == typeFloatingPoint && typ.int16(0, 0) == precisionSingle:
+			f.Type = Float32
+		case typeType == typeFloatingPoint && typ.int16(0, 0) == precisionDouble:
+			f.Type = Float64
+		case typeType == typeBool:
+			f.Type = Bool
+		case typeType == typeUtf8:
+			f.Type = String
+		case typeType == typeBinary:
+			f.Type = Binary
+		default:
+			return nil, fmt.Errorf("field %s is of an unsupported Arrow type: %d", f.Name, typeType)
+		}
+		if _, ok := field.table(4); ok {
+			return nil, fmt.Errorf("field %s is dictionary encoded, which is not supported", f.Name)
+		}
+		s.Fields = append(s.Fields, f)
+	}
+	return s, nil
+}
+
+func decodeRecordBatch(schema *Schema, batch fbReader, body []byte) (*RecordBatch, error) {
+	if _, ok := batch.table(3); ok {
+		return nil, errors.New("compressed Arrow record batches are not supported")
+	}
+	nodes := batch.structs(1)
+	buffers := batch.structs(2)
+	if len(nodes) != len(schema.Fields) {
+		return nil, fmt.Errorf("got %d Arrow field nodes for a schema with %d fields", len(nodes), len(schema.Fields))
+	}
+	nextBuffer := func(minLength int) ([]byte, error) {
+		if len(buffers) == 0 {
+			return nil, errors.New("missing Arrow buffers")
+		}
+		offset, length := buffers[0][0], buffers[0][1]
+		buffers = buffers[1:]
+		if offset < 0 || length < int64(minLength) || offset+length > int64(len(body)) {
+			return nil, fmt.Errorf("invalid Arrow buffer at offset %d, of length %d", offset, length)
+		}
+		return body[offset : offset+length : offset+length], nil
+	}
+
+	columns := make([]*Array, len(schema.Fields))
+	for i, field := range schema.Fields {
+		n, nullCount := int(nodes[i][0]), int(nodes[i][1])
+		if n < 0 || nullCount < 0 || nullCount > n {
+			return nil, fmt.Errorf("invalid Arrow field node of column %s", field.Name)
+		}
+		col := &Array{typ: field.Type, length: n, nullCount: nullCount}
+		bitmapLength := (n + 7) / 8
+		validity, err := nextBuffer(0)
+		if err != nil {
+			return nil, err
+		}
+		if nullCount > 0 {
+			if len(validity) < bitmapLength {
+				return nil, fmt.Errorf("invalid Arrow validity bitmap of column %s", field.Name)
+			}
+			col.validity = validity
+		}
+		switch {
+		case field.Type == Bool:
+			col.values, err = nextBuffer(bitmapLength)
+		case field.Type.hasOffsets():
+			if col.offsets, err = nextBuffer(4 * (n + 1)); err != nil {
+				return nil, err
+			}
+			if col.values, err = nextBuffer(0); err != nil {
+				return nil, err
+			}
+			err = checkOffsets(col)
+		default:
+			col.values, err = nextBuffer(n * field.Type.width())
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid Arrow column %s: %v", field.Name, err)
+		}
+		columns[i] = col
+	}
+	return NewRecordBatch(schema, columns)
+}
+
+// checkOffsets checks that the offsets of the values of col are in bounds,
+// so that accessing them does not panic
+func checkOffsets(col *Array) error {
+	prev := uint32(0)
+	for i := 0; i <= col.length; i++ {
+		offset := binary.LittleEndian.Uint32(col.offsets[4*i:])
+		if offset < prev || int(offset) > len(col.values) {
+			return fmt.Errorf("offset %d out of bounds", offset)
+		}
+		prev = offset
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package flowbase
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/flowbase/flowbase/arrow"
+)
+
+var errNotRecordBatch = errors.New("packet data is not an *arrow.RecordBatch")
+
+// arrowPacketMetadataKey is the key of the custom metadata of record batch
+// messages holding the ID, tags and audit info of packets, as JSON
+const arrowPacketMetadataKey = "flowbase.packet"
+
+// ArrowCodec serializes Packets with *arrow.RecordBatch data as an Arrow IPC
+// stream, with one record batch message per packet, so that tabular data can
+// be sent between processes without conversions, and streams written by it
+// read by Arrow consumers such as DuckDB, Polars or pandas. The ID, tags and
+// audit info of packets are embedded as JSON in the custom metadata of the
+// messages.
+type ArrowCodec struct{}
+
+// NewArrowCodec returns a new ArrowCodec
+func NewArrowCodec() *ArrowCodec {
+	return &ArrowCodec{}
+}
+
+// Name returns the name of the codec
+func (c *ArrowCodec) Name() string {
+	return "arrow"
+}
+
+// NewEncoder returns a new Arrow packet encoder writing to w
+func (c *ArrowCodec) NewEncoder(w io.Writer) PacketEncoder {
+	return &arrowPacketEncoder{w: arrow.NewStreamWriter(w)}
+}
+
+// NewDecoder returns a new Arrow packet decoder reading from r
+func (c *ArrowCodec) NewDecoder(r io.Reader) PacketDecoder {
+	return &arrowPacketDecoder{r: arrow.NewStreamReader(r)}
+}
+
+type arrowPacketEncoder struct {
+	w *arrow.StreamWriter
+}
+
+func (e *arrowPacketEncoder) Encode(ip *Packet) error {
+	batch, ok := ip.Data().(*arrow.RecordBatch)
+	if !ok {
+		return errWrapf(errNotRecordBatch, "Could not Arrow-encode packet (%s)", ip.ID())
+	}
+	env := newPacketEnvelope(ip)
+	env.Data = nil
+	envJSON, err := json.Marshal(env)
+	if err != nil {
+		return errWrapf(err, "Could not encode metadata of packet (%s)", ip.ID())
+	}
+	if err := e.w.Write(batch, map[string]string{arrowPacketMetadataKey: string(envJSON)}); err != nil {
+		return errWrapf(err, "Could not Arrow-encode packet (%s)", ip.ID())
+	}
+	return nil
+}
+
+type arrowPacketDecoder struct {
+	r *arrow.StreamReader
+}
+
+func (d *arrowPacketDecoder) Decode() (*Packet, error) {
+	batch, metadata, err := d.r.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, errWrap(err, "Could not Arrow-decode packet")
+	}
+	env := &packetEnvelope{}
+	// Streams written by other Arrow implementations have no packet
+	// metadata, and are decoded as packets without ID and tags
+	if envJSON, ok := metadata[arrowPacketMetadataKey]; ok {
+		if err := json.Unmarshal([]byte(envJSON), env); err != nil {
+			return nil, errWrap(err, "Could not decode metadata of Arrow packet")
+		}
+	}
+	env.Data = batch
+	return env.packet(), nil
+}
+
+func init() {
+	RegisterCodec(NewArrowCodec())
+}
@@ -1,6 +1,8 @@
 package flowbase
 
 import (
+	"encoding/json"
+	"os"
 	"time"
 )
 
@@ -8,15 +10,25 @@ import (
 // particular task (invocation), to go with all outgoing IPs from that task
 type AuditInfo struct {
 	ID          string
+	RunID       string `json:",omitempty"`
 	ProcessName string
-	Command     string
-	Params      map[string]string
-	Tags        map[string]string
-	StartTime   time.Time
-	FinishTime  time.Time
-	ExecTimeNS  time.Duration
-	OutFiles    map[string]string
-	Upstream    map[string]*AuditInfo
+	// ComponentVersion is the version of the process logic that produced the
+	// outputs, if set (see ExecProc.Version)
+	ComponentVersion string `json:",omitempty"`
+	Command          string
+	Params           map[string]string
+	Tags             map[string]string
+	StartTime        time.Time
+	FinishTime       time.Time
+	ExecTimeNS       time.Duration
+	OutFiles         map[string]string
+	// Checksums contains SHA-256 checksums of input and output files, keyed
+	// by path, when checksumming is enabled
+	Checksums map[string]string `json:",omitempty"`
+	// ResourceUsage is the resources used by the command, for commands run
+	// locally or in Apptainer containers
+	ResourceUsage *ResourceUsage `json:",omitempty"`
+	Upstream      map[string]*AuditInfo
 }
 
 // NewAuditInfo returns a new AuditInfo struct
@@ -32,3 +44,34 @@ func NewAuditInfo() *AuditInfo {
 		Upstream:    make(map[string]*AuditInfo),
 	}
 }
+
+// auditFileSuffix is added to the path of output files, to get the path of
+// the audit file written next to it
+const auditFileSuffix = ".audit.json"
+
+// WriteAuditFile writes the audit info as JSON to the audit file of the file
+// at path (path + ".audit.json"), with sensitive values redacted (see
+// RedactAuditKeys)
+func (ai *AuditInfo) WriteAuditFile(path string) error {
+	data, err := json.MarshalIndent(ai.Redacted(), "", "    ")
+	if err != nil {
+		return errWrap(err, "Could not marshal audit info to JSON")
+	}
+	if err := os.WriteFile(path+auditFileSuffix, data, 0644); err != nil {
+		return errWrapf(err, "Could not write audit file for %s", path)
+	}
+	return nil
+}
+
+// ReadAuditFile reads the audit info from the audit file of the file at path
+func ReadAuditFile(path string) (*AuditInfo, error) {
+	data, err := os.ReadFile(path + auditFileSuffix)
+	if err != nil {
+		return nil, err
+	}
+	ai := NewAuditInfo()
+	if err := json.Unmarshal(data, ai); err != nil {
+		return nil, errWrapf(err, "Could not parse audit file for %s", path)
+	}
+	return ai, nil
+}
@@ -0,0 +1,237 @@
+package flowbase
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flowbase/flowbase/template"
+)
+
+// AWSBatchConfig contains configuration for submitting the commands of an
+// ExecProc as jobs to AWS Batch. It uses the aws command line tool, which
+// needs to be installed and configured with credentials. The job definition's
+// container image needs bash and the aws command line tool too, as input
+// files are staged in, and outputs and stdout transferred back, via S3.
+type AWSBatchConfig struct {
+	JobQueue      string
+	JobDefinition string
+	// StagingURI is an S3 prefix, such as s3://mybucket/flowbase, under which
+	// the inputs, outputs and stdout of each job are stored
+	StagingURI string
+	// Region is the AWS region to use. Defaults to the configured one.
+	Region string
+	// DownloadOutputs makes output files be downloaded from S3 to their local
+	// paths after a job finishes. If false, the S3 URIs of the output files
+	// are sent on the out-ports instead of local paths, and no checksums or
+	// audit files are written for them.
+	DownloadOutputs bool
+	// PollInterval is how often to check for job completion. Defaults to 15
+	// seconds.
+	PollInterval time.Duration
+}
+
+// AWSBatchExecutor submits commands as AWS Batch jobs, and waits for them to
+// finish
+type AWSBatchExecutor struct {
+	conf AWSBatchConfig
+}
+
+// NewAWSBatchExecutor returns a new AWSBatchExecutor, configured by conf
+func NewAWSBatchExecutor(conf AWSBatchConfig) *AWSBatchExecutor {
+	if conf.JobQueue == "" || conf.JobDefinition == "" || !strings.HasPrefix(conf.StagingURI, "s3://") {
+		Fail("AWS Batch config needs JobQueue, JobDefinition and an s3:// StagingURI")
+	}
+	conf.StagingURI = strings.TrimSuffix(conf.StagingURI, "/")
+	if conf.PollInterval == 0 {
+		conf.PollInterval = 15 * time.Second
+	}
+	return &AWSBatchExecutor{conf: conf}
+}
+
+// SetAWSBatch makes the ExecProc submit its commands as AWS Batch jobs, as
+// configured by conf
+func (p *ExecProc) SetAWSBatch(conf AWSBatchConfig) {
+	p.SetExecutor(NewAWSBatchExecutor(conf))
+}
+
+// Execute submits the command of task t as an AWS Batch job, waits for it to
+// finish, and calls stdout for every line the job wrote to stdout
+func (e *AWSBatchExecutor) Execute(t *ExecTask, stdout func(line string)) error {
+	conf := &e.conf
+	jobName := awsBatchJobName(t)
+	jobURI := conf.StagingURI + "/" + jobName
+
+	// Input files are uploaded before the job is submitted, and downloaded to
+	// the same paths by the job script, which then creates the directories of
+	// the outputs, runs the command, and uploads stdout and all outputs.
+	// Inputs are paths, as for the {i:name} placeholders.
+	script := ""
+	for _, ip := range t.InPackets {
+		inPath := fmt.Sprint(ip.Data())
+		if fi, err := os.Stat(inPath); err != nil || fi.IsDir() {
+			continue
+		}
+		uri := s3URI(jobURI+"/inputs", inPath)
+		if _, err := e.awsCmd("s3", "cp", inPath, uri); err != nil {
+			return errWrapf(err, "Could not upload input %s", inPath)
+		}
+		script += fmt.Sprintf("mkdir -p %s && aws s3 cp %s %s || exit 1\n", template.ShellQuote(filepath.Dir(inPath)), template.ShellQuote(uri), template.ShellQuote(inPath))
+	}
+	for _, tempPath := range t.TempOutPaths {
+		script += fmt.Sprintf("mkdir -p %s || exit 1\n", template.ShellQuote(filepath.Dir(tempPath)))
+	}
+	script += fmt.Sprintf("( %s ) > stdout.txt; ec=$?\n", t.Command)
+	script += fmt.Sprintf("aws s3 cp stdout.txt %s\n", template.ShellQuote(jobURI+"/stdout.txt"))
+	for outName, outPath := range t.OutPaths {
+		tempPath := t.TempOutPaths[outName]
+		script += fmt.Sprintf("[ -e %s ] && aws s3 cp %s %s\n", template.ShellQuote(tempPath), template.ShellQuote(tempPath), template.ShellQuote(s3URI(jobURI+"/outputs", outPath)))
+	}
+	script += "exit $ec\n"
+
+	overrides := map[string]any{
+		"command": []string{"bash", "-c", script},
+	}
+	resReqs := []map[string]string{}
+	if t.Resources.Cores > 0 {
+		resReqs = append(resReqs, map[string]string{"type": "VCPU", "value": strconv.Itoa(t.Resources.Cores)})
+	}
+	if t.Resources.MemoryMB > 0 {
+		resReqs = append(resReqs, map[string]string{"type": "MEMORY", "value": strconv.Itoa(t.Resources.MemoryMB)})
+	}
+	if t.Resources.GPUs > 0 {
+		resReqs = append(resReqs, map[string]string{"type": "GPU", "value": strconv.Itoa(t.Resources.GPUs)})
+	}
+	if len(resReqs) > 0 {
+		overrides["resourceRequirements"] = resReqs
+	}
+	overridesJSON, err := json.Marshal(overrides)
+	Check(err)
+
+	args := []string{"batch", "submit-job",
+		"--job-name", jobName,
+		"--job-queue", conf.JobQueue,
+		"--job-definition", conf.JobDefinition,
+		"--container-overrides", string(overridesJSON)}
+	if t.Resources.Time > 0 {
+		args = append(args, "--timeout", fmt.Sprintf("attemptDurationSeconds=%d", int(t.Resources.Time.Seconds())))
+	}
+	submitOut, err := e.awsCmd(args...)
+	if err != nil {
+		return errWrapf(err, "Could not submit AWS Batch job for command (%s)", t.Command)
+	}
+	submitted := struct{ JobID string }{}
+	if err := json.Unmarshal(submitOut, &submitted); err != nil || submitted.JobID == "" {
+		return fmt.Errorf("could not parse job ID from AWS Batch output: %s", submitOut)
+	}
+	Audit.Printf("[Process:%s] Submitted AWS Batch job %s for command: %s\n", t.ProcessName, submitted.JobID, t.AuditInfo.RedactedCommand())
+
+	exitCode, reason, err := e.waitForJob(submitted.JobID)
+	if err != nil {
+		return err
+	}
+
+	if stdoutBytes, err := e.awsCmd("s3", "cp", jobURI+"/stdout.txt", "-"); err == nil {
+		scanner := newLineScanner(bytes.NewReader(stdoutBytes))
+		for scanner.Scan() {
+			stdout(scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			return errWrapf(err, "Could not read stdout of AWS Batch job %s", submitted.JobID)
+		}
+	}
+	if exitCode != 0 {
+		return &ExecError{Command: t.Command, ExitCode: exitCode, Stderr: reason}
+	}
+
+	for outName, outPath := range t.OutPaths {
+		uri := s3URI(jobURI+"/outputs", outPath)
+		if !conf.DownloadOutputs {
+			t.OutPaths[outName] = uri
+			t.TempOutPaths[outName] = uri
+			continue
+		}
+		if _, err := e.awsCmd("s3", "cp", uri, t.TempOutPaths[outName]); err != nil {
+			return errWrapf(err, "Could not download output %s", uri)
+		}
+	}
+	return nil
+}
+
+var awsBatchJobNamePtn = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// awsBatchJobName returns the name of the AWS Batch job of task t, made from
+// the process name and the task ID, with characters not allowed in job names,
+// such as the slashes of sub-network process names, replaced by underscores.
+// Job names can be at most 128 characters long.
+func awsBatchJobName(t *ExecTask) string {
+	name := awsBatchJobNamePtn.ReplaceAllString(t.ProcessName, "_")
+	if maxLen := 128 - len(t.AuditInfo.ID) - 1; len(name) > maxLen {
+		name = name[:maxLen]
+	}
+	return name + "-" + t.AuditInfo.ID
+}
+
+// s3URI returns the URI of the local path p under the S3 prefix prefix, with
+// absolute paths and paths with ".." elements kept under the prefix
+func s3URI(prefix string, p string) string {
+	return prefix + "/" + strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(p)), "/")
+}
+
+// waitForJob polls the status of the job jobID until it has finished, and
+// returns its exit code, and status reason
+func (e *AWSBatchExecutor) waitForJob(jobID string) (int, string, error) {
+	for {
+		out, err := e.awsCmd("batch", "describe-jobs", "--jobs", jobID)
+		if err != nil {
+			return 0, "", errWrapf(err, "Could not get status of AWS Batch job %s", jobID)
+		}
+		desc := struct {
+			Jobs []struct {
+				Status       string
+				StatusReason string
+				Container    struct {
+					ExitCode *int
+				}
+			}
+		}{}
+		if err := json.Unmarshal(out, &desc); err != nil || len(desc.Jobs) == 0 {
+			return 0, "", fmt.Errorf("could not parse status of AWS Batch job %s: %s", jobID, out)
+		}
+		job := desc.Jobs[0]
+		switch job.Status {
+		case "SUCCEEDED":
+			return 0, "", nil
+		case "FAILED":
+			exitCode := -1
+			if job.Container.ExitCode != nil {
+				exitCode = *job.Container.ExitCode
+			}
+			return exitCode, job.StatusReason, nil
+		}
+		time.Sleep(e.conf.PollInterval)
+	}
+}
+
+// awsCmd runs the aws command line tool with args, and returns its output
+func (e *AWSBatchExecutor) awsCmd(args ...string) ([]byte, error) {
+	if e.conf.Region != "" {
+		args = append(args, "--region", e.conf.Region)
+	}
+	cmd := exec.Command("aws", args...)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return out, errWrap(err, stderr.String())
+	}
+	return out, nil
+}
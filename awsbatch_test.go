@@ -0,0 +1,200 @@
+package flowbase
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// runFakeAWS implements the aws commands run by AWSBatchExecutor, with S3
+// objects kept as files under the state directory, and jobs run right away,
+// in a new directory, when they are submitted
+func runFakeAWS(args []string) int {
+	logFakeCall("aws", args)
+	dir := os.Getenv("FLOWBASE_FAKE_TOOL_DIR")
+	localPath := func(uri string) string {
+		if !strings.HasPrefix(uri, "s3://") {
+			return uri
+		}
+		return filepath.Join(dir, "s3", strings.TrimPrefix(uri, "s3://"))
+	}
+	if len(args) > 2 && args[len(args)-2] == "--region" {
+		args = args[:len(args)-2]
+	}
+	switch strings.Join(args[:2], " ") {
+	case "s3 cp":
+		content, err := os.ReadFile(localPath(args[2]))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if args[3] == "-" {
+			os.Stdout.Write(content)
+			return 0
+		}
+		dst := localPath(args[3])
+		Check(os.MkdirAll(filepath.Dir(dst), 0777))
+		Check(os.WriteFile(dst, content, 0644))
+	case "batch submit-job":
+		jobID := flagValue(args, "--job-name")
+		overrides := struct{ Command []string }{}
+		Check(json.Unmarshal([]byte(flagValue(args, "--container-overrides")), &overrides))
+		jobDir := filepath.Join(dir, "jobs", jobID)
+		Check(os.MkdirAll(jobDir, 0777))
+		cmd := exec.Command(overrides.Command[0], overrides.Command[1:]...)
+		cmd.Dir = jobDir
+		cmd.Run()
+		Check(os.WriteFile(jobDir+".exitcode", []byte(strconv.Itoa(cmd.ProcessState.ExitCode())), 0644))
+		fmt.Printf("{\"jobName\": %q, \"jobId\": %q}\n", jobID, jobID)
+	case "batch describe-jobs":
+		content, err := os.ReadFile(filepath.Join(dir, "jobs", flagValue(args, "--jobs")+".exitcode"))
+		Check(err)
+		exitCode, status := string(content), "SUCCEEDED"
+		if exitCode != "0" {
+			status = "FAILED"
+		}
+		fmt.Printf("{\"jobs\": [{\"status\": %q, \"statusReason\": \"Essential container in task exited\", \"container\": {\"exitCode\": %s}}]}\n", status, exitCode)
+	default:
+		fmt.Fprintf(os.Stderr, "unsupported command: %s\n", strings.Join(args, " "))
+		return 2
+	}
+	return 0
+}
+
+func TestAWSBatchExec(t *testing.T) {
+	initTestLogs()
+	stateDir := installFakeTool(t, "aws")
+
+	// Paths are relative to the working directory, with the input and the
+	// output in subdirectories, which are created in the job
+	wd, _ := os.Getwd()
+	Check(os.Chdir(t.TempDir()))
+	defer os.Chdir(wd)
+	inPath, outPath := "in/in.txt", "results/out.txt"
+	Check(os.MkdirAll(filepath.Dir(inPath), 0777))
+	Check(os.WriteFile(inPath, []byte("hello\n"), 0644))
+
+	net := NewNetwork("TestAWSBatchExec")
+	src := NewFileSource(net, "src", inPath)
+	run := NewExecProc(net, "align/cat", "cat {i:in} > {o:out}; echo done")
+	run.SetAWSBatch(AWSBatchConfig{
+		JobQueue:        "queue",
+		JobDefinition:   "def",
+		StagingURI:      "s3://bucket/flowbase/",
+		Region:          "eu-north-1",
+		DownloadOutputs: true,
+	})
+	run.In("in").From(src.Out())
+	run.SetOutPathFunc("out", func(t *ExecTask) string { return outPath })
+	stdout := NewPacketCollector(net, "stdout")
+	stdout.In().From(run.Stdout())
+	out := NewPacketCollector(net, "out")
+	out.In().From(run.Out("out"))
+	net.Run()
+
+	assertEqualValues(t, []any{"done"}, stdout.Data)
+	assertEqualValues(t, []any{NewFileIP(outPath)}, out.Data)
+	if content, err := os.ReadFile(outPath); err != nil || string(content) != "hello\n" {
+		t.Errorf("Output not downloaded: %q (%v)", content, err)
+	}
+
+	calls := fakeToolCalls(stateDir, "aws")
+	jobName := ""
+	for _, args := range calls {
+		// The calls made by the job script itself use the region of the job
+		if args[0] == "batch" {
+			assertEqualValues(t, []string{"--region", "eu-north-1"}, args[len(args)-2:])
+		}
+		if name := flagValue(args, "--job-name"); name != "" {
+			jobName = name
+		}
+		for _, arg := range args {
+			if strings.HasPrefix(arg, "s3://") && strings.Contains(strings.TrimPrefix(arg, "s3://"), "//") {
+				t.Errorf("Got S3 URI with an empty path element: %s", arg)
+			}
+		}
+	}
+	if !strings.HasPrefix(jobName, "align_cat-") {
+		t.Errorf("Got job name not made from the process name: %q", jobName)
+	}
+	assertEqualValues(t, []string{"s3", "cp", inPath, "s3://bucket/flowbase/" + jobName + "/inputs/" + inPath}, calls[0][:4])
+}
+
+func TestAWSBatchExecRemoteOutputs(t *testing.T) {
+	initTestLogs()
+	stateDir := installFakeTool(t, "aws")
+
+	wd, _ := os.Getwd()
+	Check(os.Chdir(t.TempDir()))
+	defer os.Chdir(wd)
+
+	net := NewNetwork("TestAWSBatchExecRemoteOutputs")
+	run := NewExecProc(net, "write", "echo hello > {o:out}")
+	run.SetAWSBatch(AWSBatchConfig{
+		JobQueue:      "queue",
+		JobDefinition: "def",
+		StagingURI:    "s3://bucket/flowbase",
+	})
+	run.Checksums = true
+	run.SetOutPathFunc("out", func(t *ExecTask) string { return "out.txt" })
+	out := NewPacketCollector(net, "out")
+	out.In().From(run.Out("out"))
+	net.Run()
+
+	jobName := ""
+	for _, args := range fakeToolCalls(stateDir, "aws") {
+		if name := flagValue(args, "--job-name"); name != "" {
+			jobName = name
+		}
+	}
+	assertEqualValues(t, []any{NewFileIP("s3://bucket/flowbase/" + jobName + "/outputs/out.txt")}, out.Data)
+	for _, path := range []string{"out.txt", "out.txt" + auditFileSuffix} {
+		if _, err := os.Stat(path); err == nil {
+			t.Errorf("Got local file for an output left in S3: %s", path)
+		}
+	}
+}
+
+func TestAWSBatchExecFailure(t *testing.T) {
+	initTestLogs()
+	installFakeTool(t, "aws")
+
+	net := NewNetwork("TestAWSBatchExecFailure")
+	run := NewExecProc(net, "fail", "echo failing; exit 3")
+	run.SetAWSBatch(AWSBatchConfig{
+		JobQueue:      "queue",
+		JobDefinition: "def",
+		StagingURI:    "s3://bucket/flowbase",
+	})
+	run.FailOnError = false
+	stdout := NewPacketCollector(net, "stdout")
+	stdout.In().From(run.Stdout())
+	errs := NewPacketCollector(net, "errs")
+	errs.In().From(run.Errors())
+	net.Run()
+
+	assertEqualValues(t, []any{"failing"}, stdout.Data)
+	if len(errs.Data) != 1 {
+		t.Fatalf("Expected one error, got: %v", errs.Data)
+	}
+	execErr, ok := errs.Data[0].(*ExecError)
+	if !ok || execErr.ExitCode != 3 {
+		t.Errorf("Expected an ExecError with exit code 3, got: %v", errs.Data[0])
+	}
+}
+
+func TestS3URI(t *testing.T) {
+	for p, want := range map[string]string{
+		"out.txt":          "s3://bucket/outputs/out.txt",
+		"/data/out.txt":    "s3://bucket/outputs/data/out.txt",
+		"dir//out.txt":     "s3://bucket/outputs/dir/out.txt",
+		"../dir/./out.txt": "s3://bucket/outputs/dir/out.txt",
+	} {
+		assertEqualValues(t, want, s3URI("s3://bucket/outputs", p))
+	}
+}
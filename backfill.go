@@ -0,0 +1,200 @@
+package flowbase
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/flowbase/flowbase/template"
+)
+
+// PartitionPeriod is the length of the time partitions of a Backfill
+type PartitionPeriod string
+
+const (
+	// PartitionHourly makes one partition per hour
+	PartitionHourly PartitionPeriod = "hour"
+	// PartitionDaily makes one partition per day
+	PartitionDaily PartitionPeriod = "day"
+	// PartitionMonthly makes one partition per month
+	PartitionMonthly PartitionPeriod = "month"
+)
+
+// PartitionBuilder builds the network net for one partition of a Backfill.
+// The parameters of the partition are sent as a packet on the out-port
+// params, to be connected to the processes which need them.
+type PartitionBuilder func(net *Network, params *OutPort)
+
+// Backfill runs a network once per time partition in a date range, such as
+// for processing historical data partitioned by day. Each partition gets a
+// parameter packet, whose data is a map[string]string of its parameters,
+// with the same values as tags, so that they can be used in {t:name}
+// placeholders of ExecProcs:
+//
+//	year, month, day, hour  The start of the partition, zero-padded
+//	date                    The date of the start of the partition, as 2006-01-02
+//	partition               The partition key, from the partition template
+//
+// The partition template, such as "dt={p:date}", is a template (see package
+// template) with the parameters as {p:name} placeholders. Completed
+// partitions are recorded by their keys in the ProcessedKeys store Completed,
+// so that they are skipped when the backfill is run again, such as after a
+// crash.
+type Backfill struct {
+	name      string
+	start     time.Time
+	end       time.Time
+	period    PartitionPeriod
+	partition *template.Template
+	build     PartitionBuilder
+	// Parallelism is the number of partitions run at the same time. Defaults
+	// to 1.
+	Parallelism int
+	// Completed records the keys of the completed partitions. Defaults to a
+	// store in memory, which does not survive restarts (see OpenFileKeys).
+	Completed ProcessedKeys
+}
+
+// NewBackfill returns a new Backfill, named name, running the networks built
+// by build for the partitions of length period from start, up to but not
+// including end, with keys from partitionTemplate
+func NewBackfill(name string, start time.Time, end time.Time, period PartitionPeriod, partitionTemplate string, build PartitionBuilder) (*Backfill, error) {
+	switch period {
+	case PartitionHourly, PartitionDaily, PartitionMonthly:
+	default:
+		return nil, fmt.Errorf("unknown partition period: %s", period)
+	}
+	tpl, err := template.Parse(partitionTemplate)
+	if err != nil {
+		return nil, errWrapf(err, "could not parse partition template %s", partitionTemplate)
+	}
+	for _, ph := range tpl.Placeholders() {
+		if ph.Type != "p" {
+			return nil, fmt.Errorf("partition template can only have {p:name} placeholders, got %s", ph.Raw)
+		}
+	}
+	return &Backfill{
+		name:        name,
+		start:       start,
+		end:         end,
+		period:      period,
+		partition:   tpl,
+		build:       build,
+		Parallelism: 1,
+		Completed:   NewMemoryKeys(),
+	}, nil
+}
+
+// Partitions returns the parameters of all partitions of the backfill, in
+// order
+func (b *Backfill) Partitions() ([]map[string]string, error) {
+	partitions := []map[string]string{}
+	for t := b.truncate(b.start); t.Before(b.end); t = b.next(t) {
+		params := map[string]string{
+			"year":  t.Format("2006"),
+			"month": t.Format("01"),
+			"day":   t.Format("02"),
+			"hour":  t.Format("15"),
+			"date":  t.Format("2006-01-02"),
+		}
+		key, err := b.partition.Execute(func(ph *template.Placeholder) ([]string, error) {
+			value, ok := params[ph.Name]
+			if !ok {
+				return nil, fmt.Errorf("unknown partition parameter: %s", ph.Name)
+			}
+			return []string{value}, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		params["partition"] = key
+		partitions = append(partitions, params)
+	}
+	return partitions, nil
+}
+
+// truncate returns the start of the partition t is in
+func (b *Backfill) truncate(t time.Time) time.Time {
+	switch b.period {
+	case PartitionHourly:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	case PartitionDaily:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	}
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+// next returns the start of the partition after the one starting at t
+func (b *Backfill) next(t time.Time) time.Time {
+	switch b.period {
+	case PartitionHourly:
+		return t.Add(time.Hour)
+	case PartitionDaily:
+		return t.AddDate(0, 0, 1)
+	}
+	return t.AddDate(0, 1, 0)
+}
+
+// Run runs the networks of all partitions which are not yet completed, with
+// at most Parallelism of them at the same time, and records each as
+// completed when its network has finished
+func (b *Backfill) Run() {
+	partitions, err := b.Partitions()
+	if err != nil {
+		Failf("[Backfill:%s] Could not make partitions: %v", b.name, err)
+	}
+	parallelism := b.Parallelism
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	slots := make(chan struct{}, parallelism)
+	wg := &sync.WaitGroup{}
+	for _, params := range partitions {
+		key := params["partition"]
+		done, err := b.Completed.Processed(key)
+		if err != nil {
+			Failf("[Backfill:%s] Could not look up partition %s: %v", b.name, key, err)
+		}
+		if done {
+			Audit.Printf("[Backfill:%s] Skipping completed partition %s\n", b.name, key)
+			continue
+		}
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(params map[string]string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			b.runPartition(params)
+		}(params)
+	}
+	wg.Wait()
+}
+
+// runPartition builds and runs the network of the partition with the
+// parameters params, and records it as completed
+func (b *Backfill) runPartition(params map[string]string) {
+	key := params["partition"]
+	Audit.Printf("[Backfill:%s] Starting partition %s\n", b.name, key)
+	net := NewNetwork(ProcPath(b.name, key))
+	// The data and the tags of the packet are copies of the parameters, so
+	// that processes changing one of them change neither the other, nor the
+	// parameters of the partition
+	ip := NewPacket(copyParams(params))
+	ip.AddTags(copyParams(params))
+	source := NewIIPSource(net, "params", ip)
+	b.build(net, source.Out())
+	net.Run()
+	if err := b.Completed.MarkProcessed(key); err != nil {
+		Failf("[Backfill:%s] Could not record partition %s as completed: %v", b.name, key, err)
+	}
+	Audit.Printf("[Backfill:%s] Finished partition %s\n", b.name, key)
+}
+
+// copyParams returns a copy of the parameters params
+func copyParams(params map[string]string) map[string]string {
+	c := make(map[string]string, len(params))
+	for k, v := range params {
+		c[k] = v
+	}
+	return c
+}
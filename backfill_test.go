@@ -0,0 +1,61 @@
+package flowbase
+
+import (
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBackfill(t *testing.T) {
+	initTestLogs()
+	keysPath := filepath.Join(t.TempDir(), "backfill.keys")
+	completed, err := OpenFileKeys(keysPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer completed.Close()
+	// As if a previous run crashed after completing this partition
+	completed.MarkProcessed("dt=2020-01-31")
+
+	mx := sync.Mutex{}
+	collectors := []*PacketCollector{}
+	start := time.Date(2020, 1, 30, 12, 0, 0, 0, time.UTC)
+	end := time.Date(2020, 2, 2, 0, 0, 0, 0, time.UTC)
+	backfill, err := NewBackfill("backfill", start, end, PartitionDaily, "dt={p:date}", func(net *Network, params *OutPort) {
+		// The tags of the packet do not change with its data
+		change := NewMapToTags(net, "change", func(ip *Packet) map[string]string {
+			ip.Data().(map[string]string)["changed"] = "yes"
+			if _, ok := ip.Tags()["changed"]; ok {
+				t.Errorf("Tags changed with the data of the parameters packet")
+			}
+			return nil
+		})
+		change.In().From(params)
+		collector := NewPacketCollector(net, "collector")
+		collector.In().From(change.Out())
+		mx.Lock()
+		defer mx.Unlock()
+		collectors = append(collectors, collector)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	backfill.Parallelism = 2
+	backfill.Completed = completed
+	backfill.Run()
+
+	ran := []string{}
+	for _, collector := range collectors {
+		params := collector.Data[0].(map[string]string)
+		ran = append(ran, params["partition"]+" "+params["year"]+params["month"]+params["day"])
+	}
+	sort.Strings(ran)
+	assertEqualValues(t, []string{"dt=2020-01-30 20200130", "dt=2020-02-01 20200201"}, ran)
+	for _, key := range []string{"dt=2020-01-30", "dt=2020-01-31", "dt=2020-02-01"} {
+		if done, _ := completed.Processed(key); !done {
+			t.Errorf("Partition %s was not recorded as completed", key)
+		}
+	}
+}
@@ -9,16 +9,48 @@ type BaseProcess struct {
 	workflow *Network
 	inPorts  map[string]*InPort
 	outPorts map[string]*OutPort
+	// noAutoAdd is set for processes that are never to be added to the
+	// workflow automatically, such as sinks
+	noAutoAdd bool
+	// record is shared by all copies of the process, and tells the network
+	// whether it was added
+	record *procRecord
+}
+
+// procRecord records whether a process constructed with NewBaseProcess was
+// added to a network, to report processes that were forgotten
+type procRecord struct {
+	name  string
+	added bool
 }
 
 // NewBaseProcess returns a new BaseProcess, connected to the provided workflow,
-// and with the name name
+// and with the name name. The process embedding it is added to the workflow
+// when its first port is initialized, unless turned off with
+// Network.SetAutoAddProcs.
 func NewBaseProcess(net *Network, name string) BaseProcess {
+	record := &procRecord{name: name}
+	if net != nil {
+		net.addConstructed(record)
+	}
 	return BaseProcess{
 		workflow: net,
 		name:     name,
 		inPorts:  make(map[string]*InPort),
 		outPorts: make(map[string]*OutPort),
+		record:   record,
+	}
+}
+
+// newDetachedBaseProcess returns a new BaseProcess like NewBaseProcess, for
+// processes that are not to be added to the workflow, such as sinks
+func newDetachedBaseProcess(net *Network, name string) BaseProcess {
+	return BaseProcess{
+		workflow:  net,
+		name:      name,
+		inPorts:   make(map[string]*InPort),
+		outPorts:  make(map[string]*OutPort),
+		noAutoAdd: true,
 	}
 }
 
@@ -52,6 +84,7 @@ func (p *BaseProcess) InitInPort(node Node, portName string) {
 	ipt := NewInPort(portName)
 	ipt.process = node
 	p.inPorts[portName] = ipt
+	p.autoAdd(node)
 }
 
 // InPorts returns a map of all the in-ports of the process, keyed by their
@@ -80,6 +113,20 @@ func (p *BaseProcess) InitOutPort(node Node, portName string) {
 	opt := NewOutPort(portName)
 	opt.process = node
 	p.outPorts[portName] = opt
+	p.autoAdd(node)
+}
+
+// autoAdd adds node, the process embedding p, to its workflow, unless it is
+// already added or opted out
+func (p *BaseProcess) autoAdd(node Node) {
+	if p.workflow == nil || p.noAutoAdd {
+		return
+	}
+	p.workflow.autoAddProc(node)
+}
+
+func (p *BaseProcess) processRecord() *procRecord {
+	return p.record
 }
 
 // OutPort returns the out-port with name portName
@@ -108,20 +155,29 @@ func (p *BaseProcess) DeleteOutPort(portName string) {
 // Other stuff
 // ------------------------------------------------
 
-// Ready checks whether all the process' ports are connected
+// Ready checks whether all the process' required ports are connected, and
+// that ports with multiplicity PortSingle are connected to at most one port
 func (p *BaseProcess) Ready() (isReady bool) {
 	isReady = true
 	for portName, port := range p.inPorts {
-		if !port.Ready() {
+		if !port.Ready() && port.Required() {
 			p.Failf("InPort (%s) is not connected - check your workflow code!", portName)
 			isReady = false
 		}
+		if port.info.multiplicity == PortSingle && len(port.ConnectedPorts()) > 1 {
+			p.Failf("InPort (%s) can only be connected to one out-port, but is connected to %d - check your workflow code!", portName, len(port.ConnectedPorts()))
+			isReady = false
+		}
 	}
 	for portName, port := range p.outPorts {
 		if !port.Ready() {
 			p.Failf("OutPort (%s) is not connected - check your workflow code!", portName)
 			isReady = false
 		}
+		if port.info.multiplicity == PortSingle && len(port.ConnectedPorts()) > 1 {
+			p.Failf("OutPort (%s) can only be connected to one in-port, but is connected to %d - check your workflow code!", portName, len(port.ConnectedPorts()))
+			isReady = false
+		}
 	}
 	return isReady
 }
@@ -140,6 +196,9 @@ func (p *BaseProcess) Failf(msg string, parts ...interface{}) {
 
 // Fail fails with a message that includes the process name
 func (p *BaseProcess) Fail(msg interface{}) {
+	if p.workflow != nil {
+		p.workflow.publish(&Event{Type: EventProcessFailed, Process: p.Name(), Message: fmt.Sprint(msg)})
+	}
 	Failf("[Process:%s] %s", p.Name(), msg)
 }
 
@@ -148,7 +207,11 @@ func (p *BaseProcess) Auditf(msg string, parts ...interface{}) {
 }
 
 func (p *BaseProcess) Audit(msg interface{}) {
-	Audit.Printf("[Process:%s] %s"+"\n", p.Name(), msg)
+	if p.workflow == nil {
+		Audit.Printf("[Process:%s] %s"+"\n", p.Name(), msg)
+		return
+	}
+	Audit.Printf("[Process:%s] [Run:%s] %s"+"\n", p.Name(), p.workflow.RunID(), msg)
 }
 
 func (p *BaseProcess) receiveOnInPorts() (ips map[string]*Packet, inPortsOpen bool) {
@@ -157,7 +220,7 @@ func (p *BaseProcess) receiveOnInPorts() (ips map[string]*Packet, inPortsOpen bo
 	// Read input IPs on in-ports and set up path mappings
 	for inpName, inPort := range p.InPorts() {
 		Debug.Printf("[Process %s]: Receieving on inPort (%s) ...", p.name, inpName)
-		ip, open := <-inPort.Chan
+		ip, open := inPort.RecvOK()
 		if !open {
 			inPortsOpen = false
 			continue
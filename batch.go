@@ -0,0 +1,305 @@
+package flowbase
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flowbase/flowbase/template"
+)
+
+// BatchSystem identifies a batch scheduler on an HPC cluster
+type BatchSystem string
+
+const (
+	// Slurm is the SLURM workload manager
+	Slurm BatchSystem = "slurm"
+	// SGE is the (Sun/Son of/Univa) Grid Engine
+	SGE BatchSystem = "sge"
+	// PBS is the Portable Batch System, including Torque and PBS Pro
+	PBS BatchSystem = "pbs"
+)
+
+// BatchConfig contains configuration for submitting the commands of an
+// ExecProc as jobs to a batch scheduler, such as SLURM
+type BatchConfig struct {
+	System BatchSystem
+	// Directives are extra scheduler directives added to job scripts, without
+	// the directive prefix, such as "--account=myproj" or "--partition=core"
+	// for SLURM. They can contain the placeholders {r:cores}, {r:mem},
+	// {r:time} and {r:gpus}, filled in from the process' Resources, as well
+	// as {p:name} and {t:name} for task parameters and tags.
+	Directives []string
+	// CommandPrefix is prepended to the command in job scripts, such as
+	// "module load samtools &&" or "srun". It supports the same placeholders
+	// as Directives.
+	CommandPrefix string
+	// SubmitArgs are extra arguments to the submit command (sbatch or qsub)
+	SubmitArgs []string
+	// JobDir is where job scripts, outputs and exit codes are stored. It must
+	// be on a file system shared with the compute nodes. Defaults to
+	// .flowbase/jobs.
+	JobDir string
+	// PollInterval is how often to check for job completion. Defaults to 5
+	// seconds.
+	PollInterval time.Duration
+}
+
+// BatchExecutor submits commands as jobs to a batch scheduler, and waits for
+// them to finish
+type BatchExecutor struct {
+	conf BatchConfig
+}
+
+// NewBatchExecutor returns a new BatchExecutor, configured by conf
+func NewBatchExecutor(conf BatchConfig) *BatchExecutor {
+	switch conf.System {
+	case Slurm, SGE, PBS:
+	default:
+		Failf("Unsupported batch system: (%s)", conf.System)
+	}
+	if conf.JobDir == "" {
+		conf.JobDir = filepath.Join(".flowbase", "jobs")
+	}
+	if conf.PollInterval == 0 {
+		conf.PollInterval = 5 * time.Second
+	}
+	return &BatchExecutor{conf: conf}
+}
+
+// SetBatch makes the ExecProc submit its commands as jobs to a batch
+// scheduler, as configured by conf
+func (p *ExecProc) SetBatch(conf BatchConfig) {
+	p.SetExecutor(NewBatchExecutor(conf))
+}
+
+// Execute submits the command of task t as a batch job, waits for it to
+// finish, and calls stdout for every line the job wrote to stdout
+func (e *BatchExecutor) Execute(t *ExecTask, stdout func(line string)) error {
+	conf := &e.conf
+	jobName := t.ProcessName + "-" + t.AuditInfo.ID
+	base, err := filepath.Abs(filepath.Join(conf.JobDir, jobName))
+	if err != nil {
+		return errWrap(err, "Could not get absolute path of job directory")
+	}
+	scriptPath, exitPath, outPath, errPath := base+".sh", base+".exitcode", base+".out", base+".err"
+
+	createDirs(scriptPath)
+	script, err := e.batchScript(t, jobName, exitPath, outPath, errPath)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(scriptPath, []byte(script), 0755); err != nil {
+		return errWrapf(err, "Could not write job script %s", scriptPath)
+	}
+
+	jobID, err := submitBatchJob(conf, scriptPath)
+	if err != nil {
+		return errWrapf(err, "Could not submit job for command (%s)", t.Command)
+	}
+	Audit.Printf("[Process:%s] Submitted %s job %s for command: %s\n", t.ProcessName, conf.System, jobID, t.AuditInfo.RedactedCommand())
+
+	exitCode, err := e.waitForJob(jobID, exitPath)
+	if err != nil {
+		return err
+	}
+
+	if outFile, err := os.Open(outPath); err == nil {
+		scanner := newLineScanner(outFile)
+		for scanner.Scan() {
+			stdout(scanner.Text())
+		}
+		outFile.Close()
+		if err := scanner.Err(); err != nil {
+			return errWrapf(err, "Could not read stdout of job %s", jobID)
+		}
+	}
+	if exitCode != 0 {
+		stderr, _ := os.ReadFile(errPath)
+		return &ExecError{Command: t.Command, ExitCode: exitCode, Stderr: string(stderr)}
+	}
+	return nil
+}
+
+// waitForJob polls for the exit code file of the job jobID, and returns the
+// exit code, or -1 if the job disappeared from the scheduler queue without
+// writing one (for example because it was cancelled or timed out)
+func (e *BatchExecutor) waitForJob(jobID string, exitPath string) (int, error) {
+	goneCount := 0
+	for {
+		if b, err := os.ReadFile(exitPath); err == nil && len(b) > 0 {
+			exitCode, err := strconv.Atoi(strings.TrimSpace(string(b)))
+			if err != nil {
+				return 0, errWrapf(err, "Could not parse exit code in %s", exitPath)
+			}
+			return exitCode, nil
+		}
+		if !batchJobActive(e.conf.System, jobID) {
+			// Allow for some file system latency on shared file systems
+			goneCount++
+			if goneCount > 3 {
+				Warning.Printf("Job %s left the queue without writing an exit code\n", jobID)
+				return -1, nil
+			}
+		}
+		time.Sleep(e.conf.PollInterval)
+	}
+}
+
+// batchScript returns the job script for task t
+func (e *BatchExecutor) batchScript(t *ExecTask, jobName string, exitPath string, outPath string, errPath string) (string, error) {
+	conf := &e.conf
+	res := t.Resources
+	directives := []string{}
+	switch conf.System {
+	case Slurm:
+		directives = append(directives, "#SBATCH --job-name="+jobName, "#SBATCH --output="+outPath, "#SBATCH --error="+errPath)
+		if res.Cores > 0 {
+			directives = append(directives, fmt.Sprintf("#SBATCH --cpus-per-task=%d", res.Cores))
+		}
+		if res.MemoryMB > 0 {
+			directives = append(directives, fmt.Sprintf("#SBATCH --mem=%dM", res.MemoryMB))
+		}
+		if res.Time > 0 {
+			directives = append(directives, "#SBATCH --time="+formatWallTime(res.Time))
+		}
+		if res.GPUs > 0 {
+			directives = append(directives, fmt.Sprintf("#SBATCH --gres=gpu:%d", res.GPUs))
+		}
+	case SGE:
+		directives = append(directives, "#$ -N "+jobName, "#$ -o "+outPath, "#$ -e "+errPath, "#$ -cwd", "#$ -S /bin/bash")
+		if res.Cores > 0 {
+			directives = append(directives, fmt.Sprintf("#$ -pe smp %d", res.Cores))
+		}
+		if res.MemoryMB > 0 {
+			directives = append(directives, fmt.Sprintf("#$ -l h_vmem=%dM", res.MemoryMB))
+		}
+		if res.Time > 0 {
+			directives = append(directives, "#$ -l h_rt="+formatWallTime(res.Time))
+		}
+	case PBS:
+		directives = append(directives, "#PBS -N "+jobName, "#PBS -o "+outPath, "#PBS -e "+errPath)
+		if res.Cores > 0 {
+			directives = append(directives, fmt.Sprintf("#PBS -l nodes=1:ppn=%d", res.Cores))
+		}
+		if res.MemoryMB > 0 {
+			directives = append(directives, fmt.Sprintf("#PBS -l mem=%dmb", res.MemoryMB))
+		}
+		if res.Time > 0 {
+			directives = append(directives, "#PBS -l walltime="+formatWallTime(res.Time))
+		}
+	}
+	directivePrefix := map[BatchSystem]string{Slurm: "#SBATCH ", SGE: "#$ ", PBS: "#PBS "}[conf.System]
+	for _, d := range conf.Directives {
+		directive, err := formatBatchTemplate(d, t)
+		if err != nil {
+			return "", err
+		}
+		directives = append(directives, directivePrefix+directive)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", errWrap(err, "Could not get working directory")
+	}
+	cmd := t.Command
+	if conf.CommandPrefix != "" {
+		prefix, err := formatBatchTemplate(conf.CommandPrefix, t)
+		if err != nil {
+			return "", err
+		}
+		cmd = prefix + " " + cmd
+	}
+
+	script := "#!/bin/bash\n"
+	script += strings.Join(directives, "\n") + "\n\n"
+	script += "cd " + template.ShellQuote(wd) + "\n"
+	script += cmd + "\n"
+	script += "echo $? > " + template.ShellQuote(exitPath+".tmp") + " && mv " + template.ShellQuote(exitPath+".tmp") + " " + template.ShellQuote(exitPath) + "\n"
+	return script, nil
+}
+
+// formatBatchTemplate fills in resource, parameter and tag placeholders in
+// the directive or prefix tpl
+func formatBatchTemplate(tpl string, t *ExecTask) (string, error) {
+	parsed, err := template.Parse(tpl)
+	if err != nil {
+		return "", errWrapf(err, "Could not parse batch template (%s)", tpl)
+	}
+	s, err := parsed.Execute(func(ph *template.Placeholder) ([]string, error) {
+		switch ph.Type {
+		case "r":
+			switch ph.Name {
+			case "cores":
+				return []string{strconv.Itoa(t.Resources.Cores)}, nil
+			case "mem":
+				return []string{strconv.Itoa(t.Resources.MemoryMB)}, nil
+			case "time":
+				return []string{formatWallTime(t.Resources.Time)}, nil
+			case "gpus":
+				return []string{strconv.Itoa(t.Resources.GPUs)}, nil
+			}
+		case "p":
+			return []string{t.Params[ph.Name]}, nil
+		case "t":
+			return []string{t.Tags[ph.Name]}, nil
+		}
+		return nil, fmt.Errorf("unsupported placeholder: %s", ph.Raw)
+	})
+	if err != nil {
+		return "", errWrapf(err, "Could not format batch template (%s)", tpl)
+	}
+	return s, nil
+}
+
+// submitBatchJob submits the job script at scriptPath, and returns the job ID
+func submitBatchJob(conf *BatchConfig, scriptPath string) (string, error) {
+	var cmd *exec.Cmd
+	switch conf.System {
+	case Slurm:
+		cmd = exec.Command("sbatch", append(append([]string{"--parsable"}, conf.SubmitArgs...), scriptPath)...)
+	case SGE:
+		cmd = exec.Command("qsub", append(append([]string{"-terse"}, conf.SubmitArgs...), scriptPath)...)
+	case PBS:
+		cmd = exec.Command("qsub", append(conf.SubmitArgs, scriptPath)...)
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", errWrap(err, string(out))
+	}
+	// sbatch --parsable may output "jobid;cluster"
+	jobID := strings.Split(strings.TrimSpace(string(out)), ";")[0]
+	if jobID == "" {
+		return "", fmt.Errorf("got no job ID from %s", cmd.Path)
+	}
+	return jobID, nil
+}
+
+// batchJobActive tells whether the job jobID is still known by the scheduler
+// as pending or running
+func batchJobActive(system BatchSystem, jobID string) bool {
+	var cmd *exec.Cmd
+	switch system {
+	case Slurm:
+		cmd = exec.Command("squeue", "-h", "-j", jobID, "-o", "%T")
+	case SGE:
+		cmd = exec.Command("qstat", "-j", jobID)
+	case PBS:
+		cmd = exec.Command("qstat", jobID)
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return false
+	}
+	return strings.TrimSpace(string(out)) != ""
+}
+
+// formatWallTime formats d as HH:MM:SS, as used by batch schedulers
+func formatWallTime(d time.Duration) string {
+	secs := int(d.Seconds())
+	return fmt.Sprintf("%02d:%02d:%02d", secs/3600, (secs%3600)/60, secs%60)
+}
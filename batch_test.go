@@ -0,0 +1,49 @@
+package flowbase
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExecProcSlurm(t *testing.T) {
+	initTestLogs()
+
+	// Fake sbatch, running the job script directly, and squeue, reporting no
+	// jobs in the queue
+	binDir := t.TempDir()
+	fakeSbatch := `#!/bin/bash
+script="${@: -1}"
+out=$(grep -- '--output=' "$script" | cut -d= -f2)
+bash "$script" > "$out"
+echo "4711;cluster"
+`
+	err := os.WriteFile(filepath.Join(binDir, "sbatch"), []byte(fakeSbatch), 0755)
+	Check(err)
+	err = os.WriteFile(filepath.Join(binDir, "squeue"), []byte("#!/bin/bash\n"), 0755)
+	Check(err)
+	t.Setenv("PATH", binDir+":"+os.Getenv("PATH"))
+
+	net := NewNetwork("TestExecProcSlurm")
+	echo := NewExecProc(net, "echo", "echo hello {p:who}")
+	echo.SetParam("who", "slurm")
+	echo.Resources = Resources{Cores: 2, Time: 90 * time.Minute}
+	echo.SetBatch(BatchConfig{
+		System:       Slurm,
+		Directives:   []string{"--account=proj"},
+		JobDir:       t.TempDir(),
+		PollInterval: 10 * time.Millisecond,
+	})
+
+	out := NewPacketCollector(net, "out")
+	out.In().From(echo.Stdout())
+
+	net.Run()
+
+	assertEqualValues(t, []any{"hello slurm"}, out.Data)
+}
+
+func TestFormatWallTime(t *testing.T) {
+	assertEqualValues(t, "26:03:05", formatWallTime(26*time.Hour+3*time.Minute+5*time.Second))
+}
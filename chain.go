@@ -0,0 +1,67 @@
+package flowbase
+
+// Chain connects processes in sequence, from the default out-port of each
+// process to the default in-port of the next, which is its only port, or
+// else its port named "out" or "in", for linear pipelines:
+//
+//	net.Chain(src).Then(upper).Then(lower).To(sink)
+type Chain struct {
+	net  *Network
+	last Node
+}
+
+// Chain starts a chain of processes with the process src
+func (net *Network) Chain(src Node) *Chain {
+	return &Chain{net: net, last: src}
+}
+
+// Then connects the default out-port of the last process of the chain to the
+// default in-port of the process stage, which becomes the last process
+func (c *Chain) Then(stage Node) *Chain {
+	out := defaultOutPort(c.last)
+	if out == nil {
+		c.net.Failf("Can not chain process (%s), as it has no default out-port", c.last.Name())
+	}
+	in := defaultInPort(stage)
+	if in == nil {
+		c.net.Failf("Can not chain process (%s), as it has no default in-port", stage.Name())
+	}
+	out.To(in)
+	c.last = stage
+	return c
+}
+
+// To connects the default out-port of the last process of the chain to the
+// default in-port of the process sink, which ends the chain
+func (c *Chain) To(sink Node) {
+	c.Then(sink)
+}
+
+// Last returns the last process of the chain
+func (c *Chain) Last() Node {
+	return c.last
+}
+
+// defaultInPort returns the only in-port of node, or else its in-port named
+// "in", or nil if it has neither
+func defaultInPort(node Node) *InPort {
+	ports := node.InPorts()
+	if len(ports) == 1 {
+		for _, pt := range ports {
+			return pt
+		}
+	}
+	return ports["in"]
+}
+
+// defaultOutPort returns the only out-port of node, or else its out-port
+// named "out", or nil if it has neither
+func defaultOutPort(node Node) *OutPort {
+	ports := node.OutPorts()
+	if len(ports) == 1 {
+		for _, pt := range ports {
+			return pt
+		}
+	}
+	return ports["out"]
+}
@@ -0,0 +1,43 @@
+package flowbase
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChain(t *testing.T) {
+	initTestLogs()
+	net := NewNetwork("TestChain")
+
+	src := NewFileSource(net, "src", "a.txt", "b.txt")
+	upper := net.NewFunc("upper", func(in <-chan string, out chan<- string) error {
+		for s := range in {
+			out <- strings.ToUpper(s)
+		}
+		return nil
+	})
+	trim := net.NewFunc("trim", func(in <-chan string, out chan<- string) error {
+		for s := range in {
+			out <- strings.TrimSuffix(s, ".TXT")
+		}
+		return nil
+	})
+	out := NewPacketCollector(net, "out")
+
+	chain := net.Chain(src).Then(upper).Then(trim)
+	assertEqualValues(t, trim, chain.Last())
+	chain.To(out)
+
+	net.Run()
+
+	assertEqualValues(t, []any{"A", "B"}, out.Data)
+}
+
+func TestChainNoDefaultPort(t *testing.T) {
+	ensureFailsProgram("TestChainNoDefaultPort", func() {
+		net := NewNetwork("TestChainNoDefaultPort")
+		src := NewFileSource(net, "src", "a.txt")
+		out := NewPacketCollector(net, "out")
+		net.Chain(out).To(src)
+	}, t)
+}
@@ -0,0 +1,96 @@
+package flowbase
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+)
+
+// fileChecksum returns the hex encoded SHA-256 checksum of the file at path
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", errWrapf(err, "Could not read %s for checksumming", path)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Checksum returns the hex encoded SHA-256 checksum of the file
+func (f *FileIP) Checksum() string {
+	sum, err := fileChecksum(f.path)
+	if err != nil {
+		f.Failf("Could not compute checksum: %v", err)
+	}
+	return sum
+}
+
+// inFilePaths returns the paths of the input files of task t, that is, the
+// data of received FileIPs and FileSetIPs, and of received strings that are paths of
+// existing files
+func (t *ExecTask) inFilePaths() []string {
+	paths := []string{}
+	for _, ip := range t.InPackets {
+		switch data := ip.Data().(type) {
+		case *FileIP:
+			paths = append(paths, data.Path())
+		case *FileSetIP:
+			paths = append(paths, data.Paths()...)
+		case string:
+			if fi, err := os.Stat(data); err == nil && fi.Mode().IsRegular() {
+				paths = append(paths, data)
+			}
+		}
+	}
+	return paths
+}
+
+// recordInputChecksums records the checksums of the input files of task t in
+// its audit info
+func (p *ExecProc) recordInputChecksums(t *ExecTask) {
+	if t.AuditInfo.Checksums == nil {
+		t.AuditInfo.Checksums = make(map[string]string)
+	}
+	for _, path := range t.inFilePaths() {
+		t.AuditInfo.Checksums[path] = NewFileIP(path).Checksum()
+	}
+}
+
+// recordOutputChecksum records the checksum of the output file at path of
+// task t in its audit info
+func (p *ExecProc) recordOutputChecksum(t *ExecTask, path string) {
+	if t.AuditInfo.Checksums == nil {
+		t.AuditInfo.Checksums = make(map[string]string)
+	}
+	t.AuditInfo.Checksums[path] = NewFileIP(path).Checksum()
+}
+
+// checksumsMatch tells whether the current checksums of the input files of
+// task t, and of the output file at outPath, match those recorded in the
+// audit file of outPath. If not, the output is out of date, since some file
+// has changed since it was produced.
+func (p *ExecProc) checksumsMatch(t *ExecTask, outPath string) bool {
+	ai, err := ReadAuditFile(outPath)
+	if err != nil {
+		Debug.Printf("[Process:%s] Could not read audit file of %s: %v", p.Name(), outPath, err)
+		return false
+	}
+	paths := append([]string{outPath}, t.inFilePaths()...)
+	for _, path := range paths {
+		recorded, ok := ai.Checksums[path]
+		if !ok {
+			return false
+		}
+		current, err := fileChecksum(path)
+		if err != nil || current != recorded {
+			p.Auditf("File %s has changed since %s was produced", path, outPath)
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,193 @@
+package flowbase
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the source of time for time-dependent processes, such as delays,
+// debouncing and windowing, so that they can be run with a VirtualClock in
+// tests, instead of the real time
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Timer
+}
+
+// Timer is a timer or ticker created by a Clock, whose channel C receives the
+// current time when it fires
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// Clock returns the clock of the network, which is the real time, unless set
+// with SetClock
+func (net *Network) Clock() Clock {
+	if net.clock == nil {
+		return RealClock{}
+	}
+	return net.clock
+}
+
+// SetClock sets the clock used by the time-dependent processes of the
+// network, such as a VirtualClock in tests
+func (net *Network) SetClock(clock Clock) {
+	net.clock = clock
+}
+
+// ------------------------------------------------------------------------
+// RealClock
+// ------------------------------------------------------------------------
+
+// RealClock is a Clock using the real time, from the time package
+type RealClock struct{}
+
+// Now returns the current time
+func (c RealClock) Now() time.Time { return time.Now() }
+
+// Sleep pauses the current goroutine for the duration d
+func (c RealClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+// NewTimer returns a timer firing once, after the duration d
+func (c RealClock) NewTimer(d time.Duration) Timer { return &realTimer{time.NewTimer(d)} }
+
+// NewTicker returns a ticker firing every d
+func (c RealClock) NewTicker(d time.Duration) Timer { return &realTicker{time.NewTicker(d)} }
+
+type realTimer struct{ t *time.Timer }
+
+func (t *realTimer) C() <-chan time.Time { return t.t.C }
+func (t *realTimer) Stop() bool          { return t.t.Stop() }
+
+type realTicker struct{ t *time.Ticker }
+
+func (t *realTicker) C() <-chan time.Time { return t.t.C }
+func (t *realTicker) Stop() bool          { t.t.Stop(); return true }
+
+// ------------------------------------------------------------------------
+// VirtualClock
+// ------------------------------------------------------------------------
+
+// VirtualClock is a Clock whose time only moves when advanced with Advance,
+// so that time-dependent networks can be tested deterministically, without
+// real sleeps. BlockUntilWaiters makes it possible to wait for processes to
+// reach the point where they wait for time to pass.
+type VirtualClock struct {
+	mx      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*virtualTimer
+}
+
+type virtualTimer struct {
+	clock  *VirtualClock
+	due    time.Time
+	period time.Duration
+	ch     chan time.Time
+}
+
+// NewVirtualClock returns a new VirtualClock, starting at the time start
+func NewVirtualClock(start time.Time) *VirtualClock {
+	c := &VirtualClock{now: start}
+	c.cond = sync.NewCond(&c.mx)
+	return c
+}
+
+// Now returns the current virtual time
+func (c *VirtualClock) Now() time.Time {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return c.now
+}
+
+// Sleep blocks until the virtual time has been advanced by d
+func (c *VirtualClock) Sleep(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	<-c.NewTimer(d).C()
+}
+
+// NewTimer returns a timer firing once, when the virtual time has been
+// advanced by d
+func (c *VirtualClock) NewTimer(d time.Duration) Timer {
+	return c.addTimer(d, 0)
+}
+
+// NewTicker returns a ticker firing every time the virtual time has been
+// advanced by d
+func (c *VirtualClock) NewTicker(d time.Duration) Timer {
+	return c.addTimer(d, d)
+}
+
+func (c *VirtualClock) addTimer(d time.Duration, period time.Duration) *virtualTimer {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	t := &virtualTimer{clock: c, due: c.now.Add(d), period: period, ch: make(chan time.Time, 1)}
+	c.waiters = append(c.waiters, t)
+	c.cond.Broadcast()
+	return t
+}
+
+func (t *virtualTimer) C() <-chan time.Time { return t.ch }
+
+func (t *virtualTimer) Stop() bool {
+	c := t.clock
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	for i, w := range c.waiters {
+		if w == t {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Advance moves the virtual time forward by d, firing all timers and tickers
+// that become due, in order of their due times
+func (c *VirtualClock) Advance(d time.Duration) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	end := c.now.Add(d)
+	for {
+		sort.SliceStable(c.waiters, func(i, j int) bool { return c.waiters[i].due.Before(c.waiters[j].due) })
+		if len(c.waiters) == 0 || c.waiters[0].due.After(end) {
+			break
+		}
+		t := c.waiters[0]
+		c.now = t.due
+		select {
+		case t.ch <- c.now:
+		default: // Like time.Ticker, drop ticks for slow receivers
+		}
+		if t.period > 0 {
+			t.due = t.due.Add(t.period)
+		} else {
+			c.waiters = c.waiters[1:]
+		}
+	}
+	c.now = end
+}
+
+// Waiters returns the number of pending timers and tickers
+func (c *VirtualClock) Waiters() int {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return len(c.waiters)
+}
+
+// BlockUntilWaiters blocks until there are at least n pending timers and
+// tickers, such as from processes sleeping on the clock, so that tests can
+// advance the time deterministically, once the network has reached a known
+// state
+func (c *VirtualClock) BlockUntilWaiters(n int) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	for len(c.waiters) < n {
+		c.cond.Wait()
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	fb "github.com/flowbase/flowbase"
+	// Registers the general purpose, DuckDB, image, notebook, ONNX and text
+	// components
+	_ "github.com/flowbase/flowbase/components"
+	_ "github.com/flowbase/flowbase/components/duckdb"
+	_ "github.com/flowbase/flowbase/components/image"
+	_ "github.com/flowbase/flowbase/components/notebook"
+	_ "github.com/flowbase/flowbase/components/onnx"
+	_ "github.com/flowbase/flowbase/components/text"
+)
+
+func runComponents(args []string) error {
+	flags := flag.NewFlagSet("components", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "Print the component descriptors as JSON")
+	flags.Parse(args)
+
+	infos := fb.RegisteredComponentInfos()
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(infos)
+	}
+	for _, info := range infos {
+		fmt.Printf("%s %s\n", info.Name, info.Version)
+		if info.Description != "" {
+			fmt.Printf("    %s\n", info.Description)
+		}
+		if len(info.InPorts) > 0 {
+			fmt.Printf("    In-ports:  %s\n", portSpecsString(info.InPorts))
+		}
+		if len(info.OutPorts) > 0 {
+			fmt.Printf("    Out-ports: %s\n", portSpecsString(info.OutPorts))
+		}
+		if len(info.Params) > 0 {
+			fmt.Printf("    Metadata:  %s\n", paramSpecsString(info.Params))
+		}
+	}
+	return nil
+}
+
+// portSpecsString formats specs as a comma-separated list, such as
+// "in (file, array, required), out (file)"
+func portSpecsString(specs []fb.PortSpec) string {
+	parts := []string{}
+	for _, spec := range specs {
+		part := spec.Name
+		if attrs := portSpecAttrs(spec); attrs != "" {
+			part += " (" + attrs + ")"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// portSpecAttrs formats the type, multiplicity and required flag of spec,
+// such as "file, array, required"
+func portSpecAttrs(spec fb.PortSpec) string {
+	attrs := []string{}
+	for _, attr := range []string{spec.Type, spec.Multiplicity} {
+		if attr != "" {
+			attrs = append(attrs, attr)
+		}
+	}
+	if spec.Required {
+		attrs = append(attrs, "required")
+	}
+	return strings.Join(attrs, ", ")
+}
+
+// paramSpecsString formats specs as a comma-separated list, such as
+// "interval (duration, required), sample (int)"
+func paramSpecsString(specs []fb.ParamSpec) string {
+	parts := []string{}
+	for _, spec := range specs {
+		attrs := []string{}
+		if spec.Type != "" {
+			attrs = append(attrs, spec.Type)
+		}
+		if spec.Required {
+			attrs = append(attrs, "required")
+		}
+		part := spec.Name
+		if len(attrs) > 0 {
+			part += " (" + strings.Join(attrs, ", ") + ")"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ", ")
+}
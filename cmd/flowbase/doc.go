@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// componentDoc is the data the component documentation template is executed
+// with
+type componentDoc struct {
+	fb.ComponentInfo
+	// Heading is the Markdown heading of the component, such as "#"
+	Heading string
+}
+
+// componentsDoc is the data the documentation index templates are executed
+// with
+type componentsDoc struct {
+	SiteName   string
+	Components []fb.ComponentInfo
+	// Pages tells whether each component is documented on a page of its own
+	Pages bool
+}
+
+func runDoc(args []string) error {
+	flags := flag.NewFlagSet("doc", flag.ExitOnError)
+	out := flags.String("out", "", "Path of the Markdown file to write. Defaults to stdout.")
+	mkdocsDir := flags.String("mkdocs", "", "Directory to write an mkdocs site to, with one page per component, instead of a single Markdown file")
+	siteName := flags.String("site-name", "FlowBase components", "Name of the mkdocs site")
+	flags.Parse(args)
+
+	templates := templatesFS()
+	infos := fb.RegisteredComponentInfos()
+	if *mkdocsDir != "" {
+		return genMkDocs(templates, infos, *mkdocsDir, *siteName)
+	}
+	md, err := genComponentsMarkdown(templates, infos)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err := os.Stdout.Write(md)
+		return err
+	}
+	return os.WriteFile(*out, md, 0644)
+}
+
+// genComponentsMarkdown generates a single Markdown document for the
+// components infos, with an index followed by a section per component
+func genComponentsMarkdown(templates fs.FS, infos []fb.ComponentInfo) ([]byte, error) {
+	md, err := executeTemplate(templates, "doc/index.md.tmpl", &componentsDoc{Components: infos})
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.NewBuffer(bytes.TrimRight(md, "\n"))
+	buf.WriteString("\n")
+	for _, info := range infos {
+		section, err := executeTemplate(templates, "doc/component.md.tmpl", &componentDoc{ComponentInfo: info, Heading: "##"})
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString("\n")
+		buf.Write(bytes.TrimRight(section, "\n"))
+		buf.WriteString("\n")
+	}
+	return buf.Bytes(), nil
+}
+
+// genMkDocs writes an mkdocs site documenting the components infos to dir,
+// with the configuration in mkdocs.yml, and the pages in docs/, with one page
+// per component in docs/components/
+func genMkDocs(templates fs.FS, infos []fb.ComponentInfo, dir string, siteName string) error {
+	data := &componentsDoc{SiteName: siteName, Components: infos, Pages: true}
+	files := map[string]string{
+		"mkdocs.yml":    "doc/mkdocs.yml.tmpl",
+		"docs/index.md": "doc/index.md.tmpl",
+	}
+	for path, tplPath := range files {
+		src, err := executeTemplate(templates, tplPath, data)
+		if err != nil {
+			return err
+		}
+		if err := writeGenerated(filepath.Join(dir, filepath.FromSlash(path)), src); err != nil {
+			return err
+		}
+	}
+	for _, info := range infos {
+		src, err := executeTemplate(templates, "doc/component.md.tmpl", &componentDoc{ComponentInfo: info, Heading: "#"})
+		if err != nil {
+			return err
+		}
+		if err := writeGenerated(filepath.Join(dir, "docs", "components", info.Name+".md"), src); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Wrote the documentation of %d components to %s\n", len(infos), dir)
+	return nil
+}
+
+// writeGenerated writes the generated file src to path, creating its
+// directory if needed, and ending it with a newline
+func writeGenerated(path string, src []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(bytes.TrimRight(src, "\n"), '\n'), 0644)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// merger merges the files of its in-ports, and is documented from its ports
+type merger struct {
+	fb.BaseProcess
+}
+
+func (p *merger) Run() {}
+
+// sampleComponentInfos returns the descriptors of the components of a small
+// sample network, with a fixed version, so that the golden files do not
+// change with the version of flowbase
+func sampleComponentInfos() []fb.ComponentInfo {
+	net := fb.NewNetwork("sample")
+	sorter := fb.NewExecProc(net, "sort", "sort {i:in} > {o:out}")
+	merge := &merger{BaseProcess: fb.NewBaseProcess(net, "merge")}
+	merge.InitInPort(merge, "in")
+	merge.InitInPort(merge, "extra")
+	merge.InitOutPort(merge, "out")
+	merge.InPort("in").SetDataType("file", "Files to merge, in order")
+	merge.InPort("in").SetMultiplicity(fb.PortArray)
+	merge.InPort("extra").SetRequired(false)
+	merge.InPort("extra").SetDataType("file", "Files to append | after the others")
+	merge.OutPort("out").SetDataType("file", "The merged file")
+	net.AddProc(merge)
+	merge.InPort("in").From(sorter.Out("out"))
+
+	sortInfo := fb.InfoOf(sorter)
+	sortInfo.Version = "1.2.3"
+	mergeInfo := fb.InfoOf(merge)
+	mergeInfo.Name = "Merger"
+	mergeInfo.Version = "0.1.0"
+	mergeInfo.Description = "Merges files"
+	mergeInfo.Resources = fb.Resources{Cores: 2, MemoryMB: 512, Time: 10 * time.Minute}
+	return []fb.ComponentInfo{sortInfo, mergeInfo}
+}
+
+func TestDocGolden(t *testing.T) {
+	useBuiltinTemplates(t)
+	md, err := genComponentsMarkdown(templatesFS(), sampleComponentInfos())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, filepath.Join("testdata", "doc", "components.md"), md)
+}
+
+func TestDocMkDocsGolden(t *testing.T) {
+	useBuiltinTemplates(t)
+	dir := t.TempDir()
+	if err := genMkDocs(templatesFS(), sampleComponentInfos(), dir, "Sample components"); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"mkdocs.yml", "docs/index.md", "docs/components/ExecProc.md", "docs/components/Merger.md"} {
+		src, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(path)))
+		if err != nil {
+			t.Errorf("File %s was not generated: %v", path, err)
+			continue
+		}
+		assertGolden(t, filepath.Join("testdata", "doc", "mkdocs", filepath.FromSlash(path)), src)
+	}
+}
@@ -0,0 +1,469 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	fb "github.com/flowbase/flowbase"
+	"github.com/flowbase/flowbase/components"
+)
+
+func runGenGo(args []string) error {
+	flags := flag.NewFlagSet("gen go", flag.ExitOnError)
+	dir := flags.String("dir", ".", "Directory to write the Go files to")
+	out := flags.String("out", "main.go", "Path of the Go file with the network wiring to write (relative to -dir)")
+	force := flags.Bool("force", false, "Overwrite the Go file with the network wiring if it exists")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("expected one graph file (.fbp or .json), e.g: flowbase gen go network.fbp")
+	}
+	graphPath := flags.Arg(0)
+	outPath := filepath.Join(*dir, *out)
+	if _, err := os.Stat(outPath); err == nil && !*force {
+		return fmt.Errorf("not overwriting existing file %s, use -force to overwrite it", outPath)
+	}
+
+	g, err := fb.ReadGraphFile(graphPath)
+	if err != nil {
+		return err
+	}
+	existing, err := parseTypeNames(*dir)
+	if err != nil {
+		return err
+	}
+
+	mainSrc, stubs, err := genGoNetwork(templatesFS(), g, filepath.Base(graphPath), existing)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(outPath, mainSrc, 0644); err != nil {
+		return err
+	}
+	for _, stub := range stubs {
+		path := filepath.Join(*dir, snakeCase(stub.typeName)+".go")
+		if _, err := os.Stat(path); err == nil {
+			fmt.Fprintf(os.Stderr, "Not overwriting existing file %s, for component stub %s\n", path, stub.typeName)
+			continue
+		}
+		if err := os.WriteFile(path, stub.src, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseTypeNames returns the names of the types declared in the Go files in
+// dir, which is allowed to not exist
+func parseTypeNames(dir string) (map[string]bool, error) {
+	names := map[string]bool{}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return names, nil
+	} else if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".go") || strings.HasSuffix(e.Name(), "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filepath.Join(dir, e.Name()), nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, decl := range file.Decls {
+			if gd, ok := decl.(*ast.GenDecl); ok && gd.Tok == token.TYPE {
+				for _, spec := range gd.Specs {
+					names[spec.(*ast.TypeSpec).Name.Name] = true
+				}
+			}
+		}
+	}
+	return names, nil
+}
+
+// ----------------------------------------------------------------------------
+// Built-in components
+// ----------------------------------------------------------------------------
+
+// builtinComponent generates the Go statements creating a process of a
+// component available in flowbase, as the variable varName, and returns them
+// along with the packages they need to import
+type builtinComponent func(varName string, procName string, metadata map[string]string) (string, []string, error)
+
+const (
+	fbImport         = `fb "github.com/flowbase/flowbase"`
+	componentsImport = `"github.com/flowbase/flowbase/components"`
+	timeImport       = `"time"`
+)
+
+var builtinComponents = map[string]builtinComponent{
+	"ExecProc": func(varName string, procName string, metadata map[string]string) (string, []string, error) {
+		s := fmt.Sprintf("%s := fb.NewExecProc(net, %q, %q)\n", varName, procName, metadata["command"])
+		if version, ok := metadata["version"]; ok {
+			s += fmt.Sprintf("%s.Version = %q\n", varName, version)
+		}
+		for _, key := range sortedKeys(metadata) {
+			if param := strings.TrimPrefix(key, "param."); param != key {
+				s += fmt.Sprintf("%s.SetParam(%q, %q)\n", varName, param, metadata[key])
+			}
+		}
+		return s, nil, nil
+	},
+	"GlobSource": func(varName string, procName string, metadata map[string]string) (string, []string, error) {
+		args := []string{"net", fmt.Sprintf("%q", procName)}
+		for _, ptn := range strings.Fields(metadata["patterns"]) {
+			args = append(args, fmt.Sprintf("%q", ptn))
+		}
+		s := fmt.Sprintf("%s := components.NewGlobSource(%s)\n", varName, strings.Join(args, ", "))
+		if ptn, ok := metadata["tagpattern"]; ok {
+			s += fmt.Sprintf("%s.SetTagPattern(%q)\n", varName, ptn)
+		}
+		return s, []string{componentsImport}, nil
+	},
+	"CronSource": func(varName string, procName string, metadata map[string]string) (string, []string, error) {
+		if _, err := components.ParseCron(metadata["expr"]); err != nil {
+			return "", nil, fmt.Errorf("invalid expr metadata for process %s: %v", procName, err)
+		}
+		s := fmt.Sprintf("%s := components.NewCronSource(net, %q, %q)\n", varName, procName, metadata["expr"])
+		return s, []string{componentsImport}, nil
+	},
+	"HoldUntil": func(varName string, procName string, metadata map[string]string) (string, []string, error) {
+		if tag, ok := metadata["tag"]; ok {
+			var embargo time.Duration
+			if value, ok := metadata["embargo"]; ok {
+				var err error
+				if embargo, err = time.ParseDuration(value); err != nil {
+					return "", nil, fmt.Errorf("invalid embargo metadata for process %s: %v", procName, err)
+				}
+			}
+			s := fmt.Sprintf("%s := components.NewHoldUntilTag(net, %q, %q, %s)\n", varName, procName, tag, durationExpr(embargo))
+			return s, []string{componentsImport, timeImport}, nil
+		}
+		until, err := time.Parse(time.RFC3339, metadata["until"])
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid until metadata for process %s: %v", procName, err)
+		}
+		u := until.UTC()
+		s := fmt.Sprintf("%s := components.NewHoldUntil(net, %q, time.Date(%d, time.%s, %d, %d, %d, %d, %d, time.UTC))\n",
+			varName, procName, u.Year(), u.Month(), u.Day(), u.Hour(), u.Minute(), u.Second(), u.Nanosecond())
+		return s, []string{componentsImport, timeImport}, nil
+	},
+	"Delay":    durationComponent("NewDelay", "duration"),
+	"Debounce": durationComponent("NewDebounce", "quiet"),
+	"Sample":   durationComponent("NewSample", "interval"),
+	"Ticker":   durationComponent("NewTicker", "interval"),
+	"Watchdog": durationComponent("NewWatchdog", "timeout"),
+}
+
+// durationComponent returns a builtinComponent for the timing components,
+// which are created with the duration in the metadata field key
+func durationComponent(constructor string, key string) builtinComponent {
+	return func(varName string, procName string, metadata map[string]string) (string, []string, error) {
+		d, err := time.ParseDuration(metadata[key])
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid %s metadata for process %s: %v", key, procName, err)
+		}
+		s := fmt.Sprintf("%s := components.%s(net, %q, %s)\n", varName, constructor, procName, durationExpr(d))
+		return s, []string{componentsImport, timeImport}, nil
+	}
+}
+
+// durationExpr returns a Go expression for d, such as "2 * time.Second"
+func durationExpr(d time.Duration) string {
+	units := []struct {
+		d    time.Duration
+		name string
+	}{{time.Hour, "Hour"}, {time.Minute, "Minute"}, {time.Second, "Second"}, {time.Millisecond, "Millisecond"}, {time.Microsecond, "Microsecond"}}
+	for _, unit := range units {
+		if d != 0 && d%unit.d == 0 {
+			return fmt.Sprintf("%d * time.%s", d/unit.d, unit.name)
+		}
+	}
+	return fmt.Sprintf("time.Duration(%d)", d)
+}
+
+// ----------------------------------------------------------------------------
+// Code generation
+// ----------------------------------------------------------------------------
+
+type componentStub struct {
+	typeName string
+	inPorts  map[string]bool
+	outPorts map[string]bool
+	src      []byte
+}
+
+// genGoNetwork generates the source of a main package wiring up the network
+// described by g, and stubs for components which are neither built in nor
+// among the existing types, from the component template in templates
+func genGoNetwork(templates fs.FS, g *fb.Graph, graphFile string, existing map[string]bool) ([]byte, []*componentStub, error) {
+	imports := map[string]bool{fbImport: true}
+	stubs := map[string]*componentStub{}
+	vars := map[string]string{}
+	// varUsed records the processes whose variables are used
+	varUsed := map[string]bool{}
+	usedVars := map[string]bool{"net": true, "fb": true, "components": true, "time": true, "main": true}
+
+	body := fmt.Sprintf("net := fb.NewNetwork(%q)\n\n", networkName(g))
+	for _, procName := range sortedKeys(g.Processes) {
+		proc := g.Processes[procName]
+		varName := uniqueIdent(goIdent(procName, false), usedVars)
+		vars[procName] = varName
+		metadata := map[string]string{}
+		for key, value := range proc.Metadata {
+			metadata[key] = fmt.Sprint(value)
+		}
+		if gen, ok := builtinComponents[proc.Component]; ok {
+			code, imps, err := gen(varName, procName, metadata)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, imp := range imps {
+				imports[imp] = true
+			}
+			varUsed[procName] = strings.Contains(code, "\n"+varName+".")
+			body += code
+			continue
+		}
+		typeName := goIdent(proc.Component, true)
+		if !existing[typeName] && stubs[typeName] == nil {
+			stubs[typeName] = &componentStub{typeName: typeName, inPorts: map[string]bool{}, outPorts: map[string]bool{}}
+		}
+		for _, key := range sortedKeys(metadata) {
+			body += fmt.Sprintf("// %s: %s\n", key, metadata[key])
+		}
+		body += fmt.Sprintf("%s := New%s(net, %q)\n", varName, typeName, procName)
+	}
+
+	// Collect the ports of stubbed components from how they are connected
+	stubOf := func(procName string) *componentStub {
+		return stubs[goIdent(g.Processes[procName].Component, true)]
+	}
+	for _, conn := range g.Connections {
+		if stub := stubOf(conn.Tgt.Process); stub != nil {
+			stub.inPorts[conn.Tgt.Port] = true
+		}
+		if conn.Src != nil {
+			if stub := stubOf(conn.Src.Process); stub != nil {
+				stub.outPorts[conn.Src.Port] = true
+			}
+		}
+	}
+	for _, ep := range g.InPorts {
+		if stub := stubOf(ep.Process); stub != nil {
+			stub.inPorts[ep.Port] = true
+		}
+	}
+	for _, ep := range g.OutPorts {
+		if stub := stubOf(ep.Process); stub != nil {
+			stub.outPorts[ep.Port] = true
+		}
+	}
+
+	if len(g.Connections) > 0 {
+		body += "\n"
+	}
+	for i, conn := range g.Connections {
+		tgtVar, ok := vars[conn.Tgt.Process]
+		if !ok {
+			return nil, nil, fmt.Errorf("no such process in graph: %s", conn.Tgt.Process)
+		}
+		varUsed[conn.Tgt.Process] = true
+		if conn.Src == nil {
+			iipName := fmt.Sprintf("iip%d_%s_%s", i, conn.Tgt.Process, conn.Tgt.Port)
+			body += fmt.Sprintf("%s.InPort(%q).From(fb.NewIIPSource(net, %q, %#v).Out())\n", tgtVar, conn.Tgt.Port, iipName, conn.Data)
+			continue
+		}
+		srcVar, ok := vars[conn.Src.Process]
+		if !ok {
+			return nil, nil, fmt.Errorf("no such process in graph: %s", conn.Src.Process)
+		}
+		varUsed[conn.Src.Process] = true
+		body += fmt.Sprintf("%s.InPort(%q).From(%s.OutPort(%q))\n", tgtVar, conn.Tgt.Port, srcVar, conn.Src.Port)
+	}
+	if len(g.InPorts)+len(g.OutPorts) > 0 {
+		body += "\n"
+	}
+	for _, name := range sortedKeys(g.InPorts) {
+		ep := g.InPorts[name]
+		varUsed[ep.Process] = true
+		body += fmt.Sprintf("net.ExportInPort(%q, %s.InPort(%q))\n", name, vars[ep.Process], ep.Port)
+	}
+	for _, name := range sortedKeys(g.OutPorts) {
+		ep := g.OutPorts[name]
+		varUsed[ep.Process] = true
+		body += fmt.Sprintf("net.ExportOutPort(%q, %s.OutPort(%q))\n", name, vars[ep.Process], ep.Port)
+	}
+	// Processes which are not connected are still created, but their
+	// variables need to be used
+	for _, procName := range sortedKeys(vars) {
+		if !varUsed[procName] {
+			body += fmt.Sprintf("_ = %s\n", vars[procName])
+		}
+	}
+	body += "\nnet.Run()\n"
+
+	s := fmt.Sprintf("// Code generated by flowbase gen go from %s. Edit as needed.\n\n", graphFile)
+	s += "package main\n\n"
+	// Standard library imports go first, in a group of their own
+	s += "import (\n"
+	if imports[timeImport] {
+		s += "\t" + timeImport + "\n\n"
+		delete(imports, timeImport)
+	}
+	for _, imp := range sortedKeys(imports) {
+		s += "\t" + imp + "\n"
+	}
+	s += ")\n\n"
+	s += "func main() {\n" + body + "}\n"
+	mainSrc, err := format.Source([]byte(s))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stubList := []*componentStub{}
+	for _, typeName := range sortedKeys(stubs) {
+		stub := stubs[typeName]
+		data := newComponentData("main", typeName, "flowbase gen go", sortedKeys(stub.inPorts), sortedKeys(stub.outPorts))
+		if stub.src, err = genComponentStub(templates, data); err != nil {
+			return nil, nil, err
+		}
+		stubList = append(stubList, stub)
+	}
+	return mainSrc, stubList, nil
+}
+
+// componentData is the data the component template is executed with
+type componentData struct {
+	// Package is the name of the package of the component
+	Package  string
+	TypeName string
+	// Generator is the command which generated the component
+	Generator string
+	InPorts   []*componentPort
+	OutPorts  []*componentPort
+	// ForwardPort is the out-port to which the stub forwards the packets it
+	// receives, or nil if it has no out-ports
+	ForwardPort *componentPort
+}
+
+// componentPort is a port of a component, for the component template
+type componentPort struct {
+	Name string
+	// Accessor is the name of the accessor method of the port
+	Accessor string
+}
+
+// newComponentData returns the data for a component stub typeName in the
+// package pkg, with the ports inPorts and outPorts, which forwards packets
+// to the out-port "out", or else the first one
+func newComponentData(pkg string, typeName string, generator string, inPorts []string, outPorts []string) *componentData {
+	data := &componentData{Package: pkg, TypeName: typeName, Generator: generator}
+	for _, pt := range inPorts {
+		data.InPorts = append(data.InPorts, &componentPort{Name: pt, Accessor: portAccessor("In", pt)})
+	}
+	for _, pt := range outPorts {
+		port := &componentPort{Name: pt, Accessor: portAccessor("Out", pt)}
+		data.OutPorts = append(data.OutPorts, port)
+		if data.ForwardPort == nil || pt == "out" {
+			data.ForwardPort = port
+		}
+	}
+	return data
+}
+
+// genComponentStub generates the source of a component stub described by
+// data, from the template component.go.tmpl in templates, which forwards all
+// packets it receives to one of its out-ports, for the user to implement
+func genComponentStub(templates fs.FS, data *componentData) ([]byte, error) {
+	return executeTemplate(templates, "component.go.tmpl", data)
+}
+
+// ----------------------------------------------------------------------------
+// Naming helpers
+// ----------------------------------------------------------------------------
+
+// networkName returns the name of the network of g
+func networkName(g *fb.Graph) string {
+	if g.Properties.Name != "" {
+		return g.Properties.Name
+	}
+	return "network"
+}
+
+// goIdent turns name, such as "my-proc" or "core/Kick", into a Go
+// identifier in camel case, such as "myProc", or "CoreKick" if exported
+func goIdent(name string, exported bool) string {
+	parts := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	ident := ""
+	for i, part := range parts {
+		if i > 0 || exported {
+			part = strings.ToUpper(part[:1]) + part[1:]
+		} else {
+			part = strings.ToLower(part[:1]) + part[1:]
+		}
+		ident += part
+	}
+	if ident == "" || unicode.IsDigit(rune(ident[0])) {
+		if exported {
+			ident = "P" + ident
+		} else {
+			ident = "p" + ident
+		}
+	}
+	if token.IsKeyword(ident) {
+		ident += "Proc"
+	}
+	return ident
+}
+
+// uniqueIdent returns ident, or ident with a numeric suffix if it is already
+// used, and marks it as used
+func uniqueIdent(ident string, used map[string]bool) string {
+	unique := ident
+	for i := 2; used[unique]; i++ {
+		unique = fmt.Sprintf("%s%d", ident, i)
+	}
+	used[unique] = true
+	return unique
+}
+
+// portAccessor returns the name of the accessor method for the port pt,
+// such as In for the in-port "in", and InData for the in-port "data"
+func portAccessor(prefix string, pt string) string {
+	if strings.EqualFold(pt, prefix) {
+		return prefix
+	}
+	name := prefix + goIdent(pt, true)
+	// Avoid clashing with the methods of BaseProcess
+	if name == "InPort" || name == "InPorts" || name == "OutPort" || name == "OutPorts" {
+		name += "Port"
+	}
+	return name
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
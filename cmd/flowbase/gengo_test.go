@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const genGoGraphSrc = `'hello' -> in upper(Upper) out -> in delay(Delay:duration=10ms) out -> in collect(Collect)
+`
+
+// TestGenGoCompiles generates the wiring and component stubs for a graph in
+// a temporary module, and checks that they build and run
+func TestGenGoCompiles(t *testing.T) {
+	useBuiltinTemplates(t)
+	dir := t.TempDir()
+	writeTestModule(t, dir, "example.com/network", map[string]string{"network.fbp": genGoGraphSrc})
+	graphPath := filepath.Join(dir, "network.fbp")
+
+	if err := runGenGo([]string{"-dir", dir, graphPath}); err != nil {
+		t.Fatalf("Could not generate Go code: %v", err)
+	}
+	for _, name := range []string{"main.go", "upper.go", "collect.go"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("File %s was not generated: %v", name, err)
+		}
+	}
+	if out, err := runGo(t, dir, "run", "."); err != nil {
+		t.Errorf("Generated code did not build and run: %v\n%s", err, out)
+	}
+}
+
+func TestGenGoKeepsMain(t *testing.T) {
+	useBuiltinTemplates(t)
+	dir := t.TempDir()
+	graphPath := filepath.Join(dir, "network.fbp")
+	if err := os.WriteFile(graphPath, []byte(genGoGraphSrc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mainPath := filepath.Join(dir, "main.go")
+	edited := []byte("package main\n\n// Edited by the user\n")
+	if err := os.WriteFile(mainPath, edited, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := runGenGo([]string{"-dir", dir, graphPath})
+	if err == nil || !strings.Contains(err.Error(), "-force") {
+		t.Errorf("Expected an error about not overwriting main.go, got: %v", err)
+	}
+	if src, _ := os.ReadFile(mainPath); string(src) != string(edited) {
+		t.Errorf("main.go was overwritten without -force:\n%s", src)
+	}
+
+	if err := runGenGo([]string{"-dir", dir, "-force", graphPath}); err != nil {
+		t.Fatalf("Could not generate Go code with -force: %v", err)
+	}
+	if src, _ := os.ReadFile(mainPath); !strings.Contains(string(src), "Code generated by flowbase gen go") {
+		t.Errorf("main.go was not overwritten with -force:\n%s", src)
+	}
+}
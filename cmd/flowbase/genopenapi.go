@@ -0,0 +1,258 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func runGenOpenAPI(args []string) error {
+	flags := flag.NewFlagSet("gen openapi", flag.ExitOnError)
+	dir := flags.String("dir", ".", "Directory to write the Go file to")
+	out := flags.String("out", "openapi.go", "Path of the Go file to write (relative to -dir)")
+	pkgName := flags.String("package", "main", "Name of the package of the Go file")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("expected one OpenAPI spec file (.json), e.g: flowbase gen openapi petstore.json")
+	}
+	specPath := flags.Arg(0)
+
+	data, err := os.ReadFile(specPath)
+	if err != nil {
+		return err
+	}
+	spec := &openAPISpec{}
+	if err := json.Unmarshal(data, spec); err != nil {
+		return fmt.Errorf("could not parse OpenAPI spec %s (only JSON is supported): %v", specPath, err)
+	}
+	src, err := genOpenAPIGo(spec, *pkgName, filepath.Base(specPath))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(*dir, *out), src, 0644)
+}
+
+// ----------------------------------------------------------------------------
+// OpenAPI spec
+// ----------------------------------------------------------------------------
+
+// openAPISpec is the subset of an OpenAPI 3 spec needed to generate
+// operation components
+type openAPISpec struct {
+	Info struct {
+		Title string `json:"title"`
+	} `json:"info"`
+	Servers []struct {
+		URL string `json:"url"`
+	} `json:"servers"`
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Parameters      map[string]*openAPIParam          `json:"parameters"`
+		SecuritySchemes map[string]*openAPISecurityScheme `json:"securitySchemes"`
+	} `json:"components"`
+	Security []map[string][]string `json:"security"`
+}
+
+type openAPIParam struct {
+	Ref      string `json:"$ref"`
+	Name     string `json:"name"`
+	In       string `json:"in"`
+	Required bool   `json:"required"`
+}
+
+type openAPIOperation struct {
+	OperationID string           `json:"operationId"`
+	Summary     string           `json:"summary"`
+	Parameters  []*openAPIParam  `json:"parameters"`
+	RequestBody *json.RawMessage `json:"requestBody"`
+	// Security overrides the security of the spec, if set
+	Security *[]map[string][]string `json:"security"`
+}
+
+type openAPISecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme"`
+	In     string `json:"in"`
+	Name   string `json:"name"`
+}
+
+var httpMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// resolveParam returns param, or the parameter it refers to
+func (spec *openAPISpec) resolveParam(param *openAPIParam) (*openAPIParam, error) {
+	if param.Ref == "" {
+		return param, nil
+	}
+	name := strings.TrimPrefix(param.Ref, "#/components/parameters/")
+	resolved, ok := spec.Components.Parameters[name]
+	if name == param.Ref || !ok {
+		return nil, fmt.Errorf("could not resolve parameter reference %s", param.Ref)
+	}
+	return resolved, nil
+}
+
+// authExpr returns a Go expression for the rest.Auth of the security
+// requirements security, where the secrets are named after the schemes
+func (spec *openAPISpec) authExpr(security []map[string][]string) (string, error) {
+	names := map[string]bool{}
+	// Only the first of alternative requirements is used
+	if len(security) > 0 {
+		for name := range security[0] {
+			names[name] = true
+		}
+	}
+	auths := []string{}
+	for _, name := range sortedKeys(names) {
+		scheme, ok := spec.Components.SecuritySchemes[name]
+		if !ok {
+			return "", fmt.Errorf("no such security scheme: %s", name)
+		}
+		switch {
+		case scheme.Type == "http" && strings.EqualFold(scheme.Scheme, "bearer"):
+			auths = append(auths, fmt.Sprintf("{Type: rest.AuthBearer, Secret: %q}", name))
+		case scheme.Type == "http" && strings.EqualFold(scheme.Scheme, "basic"):
+			auths = append(auths, fmt.Sprintf("{Type: rest.AuthBasic, Secret: %q}", name))
+		case scheme.Type == "apiKey" && scheme.In == "header":
+			auths = append(auths, fmt.Sprintf("{Type: rest.AuthAPIKey, Header: %q, Secret: %q}", scheme.Name, name))
+		default:
+			return "", fmt.Errorf("unsupported security scheme %s (only bearer, basic and header API keys are supported)", name)
+		}
+	}
+	return "[]rest.Auth{" + strings.Join(auths, ", ") + "}", nil
+}
+
+// ----------------------------------------------------------------------------
+// Code generation
+// ----------------------------------------------------------------------------
+
+// genOpenAPIGo generates the source of a Go file with a constructor for a
+// client of the API of spec, and for a rest.Operation process for each of its
+// operations
+func genOpenAPIGo(spec *openAPISpec, pkgName string, specFile string) ([]byte, error) {
+	title := spec.Info.Title
+	if title == "" {
+		title = "API"
+	}
+	baseURL := ""
+	if len(spec.Servers) > 0 {
+		baseURL = spec.Servers[0].URL
+	}
+	auth, err := spec.authExpr(spec.Security)
+	if err != nil {
+		return nil, err
+	}
+	secretNames := []string{}
+	for name := range spec.Components.SecuritySchemes {
+		secretNames = append(secretNames, name)
+	}
+	sort.Strings(secretNames)
+
+	s := fmt.Sprintf("// Code generated by flowbase gen openapi from %s. DO NOT EDIT.\n\n", specFile)
+	s += fmt.Sprintf("package %s\n\n", pkgName)
+	s += "import (\n\t" + fbImport + "\n\t\"github.com/flowbase/flowbase/components/crypto\"\n\t\"github.com/flowbase/flowbase/components/rest\"\n)\n\n"
+
+	clientFunc := "New" + goIdent(title, true) + "Client"
+	s += fmt.Sprintf("// %s returns a client for the API %s.\n", clientFunc, title)
+	if len(secretNames) > 0 {
+		s += fmt.Sprintf("// The secrets of its security schemes (%s) are looked up in secrets.\n", strings.Join(secretNames, ", "))
+	}
+	s += "// Use Configure to override its settings from a config.\n"
+	s += fmt.Sprintf("func %s(secrets crypto.SecretProvider) *rest.Client {\n", clientFunc)
+	s += fmt.Sprintf("return &rest.Client{\nBaseURL: %q,\nAuth: %s,\nSecrets: secrets,\n}\n}\n", baseURL, auth)
+
+	usedIdents := map[string]bool{clientFunc: true}
+	for _, path := range sortedKeys(spec.Paths) {
+		item := spec.Paths[path]
+		pathParams := []*openAPIParam{}
+		if raw, ok := item["parameters"]; ok {
+			if err := json.Unmarshal(raw, &pathParams); err != nil {
+				return nil, fmt.Errorf("could not parse parameters of %s: %v", path, err)
+			}
+		}
+		for _, method := range httpMethods {
+			raw, ok := item[method]
+			if !ok {
+				continue
+			}
+			op := &openAPIOperation{}
+			if err := json.Unmarshal(raw, op); err != nil {
+				return nil, fmt.Errorf("could not parse operation %s %s: %v", method, path, err)
+			}
+			code, err := genOpenAPIOperation(spec, method, path, op, pathParams, usedIdents)
+			if err != nil {
+				return nil, err
+			}
+			s += code
+		}
+	}
+	return format.Source([]byte(s))
+}
+
+// genOpenAPIOperation generates the constructor of a rest.Operation process
+// for the operation op
+func genOpenAPIOperation(spec *openAPISpec, method string, path string, op *openAPIOperation, pathParams []*openAPIParam, usedIdents map[string]bool) (string, error) {
+	opName := op.OperationID
+	desc := fmt.Sprintf("the operation %s (%s %s)", opName, strings.ToUpper(method), path)
+	if opName == "" {
+		opName = method + " " + path
+		desc = strings.ToUpper(method) + " " + path
+	}
+	funcName := uniqueIdent("New"+goIdent(opName, true), usedIdents)
+
+	// Operation parameters override path item parameters with the same name
+	// and location
+	params := []*openAPIParam{}
+	index := map[string]int{}
+	for _, param := range append(append([]*openAPIParam{}, pathParams...), op.Parameters...) {
+		param, err := spec.resolveParam(param)
+		if err != nil {
+			return "", err
+		}
+		if param.In == "cookie" {
+			return "", fmt.Errorf("unsupported cookie parameter %s, of %s %s", param.Name, method, path)
+		}
+		key := param.In + ":" + param.Name
+		if i, ok := index[key]; ok {
+			params[i] = param
+			continue
+		}
+		index[key] = len(params)
+		params = append(params, param)
+	}
+
+	s := fmt.Sprintf("\n// %s returns a process calling %s", funcName, desc)
+	if op.Summary != "" {
+		s += ":\n// " + strings.TrimSuffix(strings.TrimSpace(op.Summary), ".")
+	}
+	s += "\n"
+	s += fmt.Sprintf("func %s(net *fb.Network, name string, client *rest.Client) *rest.Operation {\n", funcName)
+	s += fmt.Sprintf("return rest.NewOperation(net, name, client, rest.OperationSpec{\nMethod: %q,\nPath: %q,\n", strings.ToUpper(method), path)
+	if len(params) > 0 {
+		s += "Params: []rest.Param{\n"
+		for _, param := range params {
+			// Path parameters are always required
+			s += fmt.Sprintf("{Name: %q, In: %q, Required: %v},\n", param.Name, param.In, param.Required || param.In == "path")
+		}
+		s += "},\n"
+	}
+	if op.RequestBody != nil {
+		s += "HasBody: true,\n"
+	}
+	if op.Security != nil {
+		auth, err := spec.authExpr(*op.Security)
+		if err != nil {
+			return "", err
+		}
+		s += fmt.Sprintf("Auth: %s,\n", auth)
+	}
+	s += "})\n}\n"
+	return s, nil
+}
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// packetMarker is the comment marking a struct type as a packet type, for
+// which protobuf definitions should be generated
+const packetMarker = "flowbase:packet"
+
+func runGenProto(args []string) error {
+	flags := flag.NewFlagSet("gen proto", flag.ExitOnError)
+	dir := flags.String("dir", ".", "Directory of the Go package containing the packet types")
+	types := flags.String("types", "", "Comma-separated list of struct types to generate for (default: structs marked with a '// "+packetMarker+"' comment)")
+	protoOut := flags.String("proto-out", "packets.proto", "Path of the .proto file to write (relative to -dir)")
+	goOut := flags.String("go-out", "packets_pb.go", "Path of the Go codec file to write (relative to -dir)")
+	flags.Parse(args)
+
+	pkgName, structs, err := parsePacketStructs(*dir, splitNonEmpty(*types))
+	if err != nil {
+		return err
+	}
+	if len(structs) == 0 {
+		return fmt.Errorf("no packet types found in %s", *dir)
+	}
+	msgs, err := protoMessagesFromStructs(structs)
+	if err != nil {
+		return err
+	}
+
+	protoSrc := genProtoFile(pkgName, msgs)
+	if err := os.WriteFile(filepath.Join(*dir, *protoOut), []byte(protoSrc), 0644); err != nil {
+		return err
+	}
+	goSrc, err := genProtoGoFile(pkgName, msgs)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(*dir, *goOut), goSrc, 0644)
+}
+
+// ----------------------------------------------------------------------------
+// Parsing
+// ----------------------------------------------------------------------------
+
+func parsePacketStructs(dir string, typeNames []string) (string, map[string]*ast.StructType, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", nil, err
+	}
+	wanted := map[string]bool{}
+	for _, tn := range typeNames {
+		wanted[tn] = true
+	}
+	fset := token.NewFileSet()
+	pkgName := ""
+	structs := map[string]*ast.StructType{}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") || strings.HasSuffix(name, "_pb.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return "", nil, err
+		}
+		pkgName = file.Name.Name
+		for _, decl := range file.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				ts := spec.(*ast.TypeSpec)
+				st, ok := ts.Type.(*ast.StructType)
+				if !ok {
+					continue
+				}
+				doc := ts.Doc
+				if doc == nil && len(gd.Specs) == 1 {
+					doc = gd.Doc
+				}
+				if len(typeNames) > 0 {
+					if wanted[ts.Name.Name] {
+						structs[ts.Name.Name] = st
+					}
+				} else if doc != nil && strings.Contains(doc.Text(), packetMarker) {
+					structs[ts.Name.Name] = st
+				}
+			}
+		}
+	}
+	for _, tn := range typeNames {
+		if _, ok := structs[tn]; !ok {
+			return "", nil, fmt.Errorf("struct type %s not found in %s", tn, dir)
+		}
+	}
+	return pkgName, structs, nil
+}
+
+// ----------------------------------------------------------------------------
+// Type mapping
+// ----------------------------------------------------------------------------
+
+type protoMessage struct {
+	Name   string
+	Fields []*protoField
+}
+
+type protoField struct {
+	GoName    string
+	ProtoName string
+	Num       int
+	// Kind is one of: string, bool, int, uint, double, float, bytes, map,
+	// message
+	Kind     string
+	GoType   string // Go type of the (element) value, for conversions
+	Repeated bool
+	Pointer  bool // For messages: whether the (element) value is a pointer
+}
+
+func (f *protoField) protoType() string {
+	switch f.Kind {
+	case "int":
+		return "int64"
+	case "uint":
+		return "uint64"
+	case "map":
+		return "map<string, string>"
+	case "message":
+		return f.GoType
+	}
+	return f.Kind
+}
+
+var goScalarKinds = map[string]string{
+	"string":  "string",
+	"bool":    "bool",
+	"int":     "int",
+	"int8":    "int",
+	"int16":   "int",
+	"int32":   "int",
+	"int64":   "int",
+	"uint":    "uint",
+	"uint8":   "uint",
+	"uint16":  "uint",
+	"uint32":  "uint",
+	"uint64":  "uint",
+	"float64": "double",
+	"float32": "float",
+}
+
+func protoMessagesFromStructs(structs map[string]*ast.StructType) ([]*protoMessage, error) {
+	names := []string{}
+	for name := range structs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	msgs := []*protoMessage{}
+	for _, name := range names {
+		msg := &protoMessage{Name: name}
+		num := 1
+		for _, field := range structs[name].Fields.List {
+			for _, ident := range field.Names {
+				if !ident.IsExported() {
+					continue
+				}
+				pf, err := protoFieldForType(field.Type, structs)
+				if err != nil {
+					return nil, fmt.Errorf("field %s.%s: %v", name, ident.Name, err)
+				}
+				pf.GoName = ident.Name
+				pf.ProtoName = snakeCase(ident.Name)
+				pf.Num = num
+				num++
+				msg.Fields = append(msg.Fields, pf)
+			}
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+func protoFieldForType(expr ast.Expr, structs map[string]*ast.StructType) (*protoField, error) {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if kind, ok := goScalarKinds[t.Name]; ok {
+			return &protoField{Kind: kind, GoType: t.Name}, nil
+		}
+		if _, ok := structs[t.Name]; ok {
+			return &protoField{Kind: "message", GoType: t.Name}, nil
+		}
+	case *ast.StarExpr:
+		if id, ok := t.X.(*ast.Ident); ok {
+			if _, ok := structs[id.Name]; ok {
+				return &protoField{Kind: "message", GoType: id.Name, Pointer: true}, nil
+			}
+		}
+	case *ast.ArrayType:
+		if t.Len != nil {
+			break
+		}
+		if id, ok := t.Elt.(*ast.Ident); ok && (id.Name == "byte" || id.Name == "uint8") {
+			return &protoField{Kind: "bytes", GoType: "[]byte"}, nil
+		}
+		elem, err := protoFieldForType(t.Elt, structs)
+		if err != nil {
+			return nil, err
+		}
+		if elem.Repeated || elem.Kind == "map" || elem.Kind == "bytes" {
+			break
+		}
+		elem.Repeated = true
+		return elem, nil
+	case *ast.MapType:
+		k, kok := t.Key.(*ast.Ident)
+		v, vok := t.Value.(*ast.Ident)
+		if kok && vok && k.Name == "string" && v.Name == "string" {
+			return &protoField{Kind: "map", GoType: "map[string]string"}, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported type: %s", exprString(expr))
+}
+
+func exprString(expr ast.Expr) string {
+	buf := &bytes.Buffer{}
+	format.Node(buf, token.NewFileSet(), expr)
+	return buf.String()
+}
+
+var snakeCasePtn = regexp.MustCompile(`([a-z0-9])([A-Z])`)
+
+func snakeCase(s string) string {
+	return strings.ToLower(snakeCasePtn.ReplaceAllString(s, "${1}_${2}"))
+}
+
+// ----------------------------------------------------------------------------
+// Code generation
+// ----------------------------------------------------------------------------
+
+func genProtoFile(pkgName string, msgs []*protoMessage) string {
+	s := "// Code generated by flowbase gen proto. DO NOT EDIT.\n\n"
+	s += "syntax = \"proto3\";\n\n"
+	s += fmt.Sprintf("package %s;\n\n", pkgName)
+	s += "// Packet is the envelope used by the flowbase protobuf codec. Streams of\n"
+	s += "// packets are written as length-delimited (varint-prefixed) messages.\n"
+	s += "message Packet {\n"
+	s += "  string id = 1;\n"
+	s += "  map<string, string> tags = 2;\n"
+	s += "  // Fully qualified name of the message type encoded in data\n"
+	s += "  string type = 3;\n"
+	s += "  bytes data = 4;\n"
+	s += "  // Audit info, encoded as JSON\n"
+	s += "  bytes audit_info = 5;\n"
+	s += "}\n"
+	for _, msg := range msgs {
+		s += fmt.Sprintf("\nmessage %s {\n", msg.Name)
+		for _, f := range msg.Fields {
+			repeated := ""
+			if f.Repeated {
+				repeated = "repeated "
+			}
+			s += fmt.Sprintf("  %s%s %s = %d;\n", repeated, f.protoType(), f.ProtoName, f.Num)
+		}
+		s += "}\n"
+	}
+	return s
+}
+
+// wireWriters maps scalar kinds to the pbwire.Buffer method and Go conversion
+// used to write them
+var wireWriters = map[string][2]string{
+	"string": {"String", ""},
+	"bool":   {"Bool", ""},
+	"int":    {"Int64", "int64"},
+	"uint":   {"Uint64", "uint64"},
+	"double": {"Double", "float64"},
+	"float":  {"Float", "float32"},
+	"bytes":  {"RawBytes", ""},
+}
+
+func genProtoGoFile(pkgName string, msgs []*protoMessage) ([]byte, error) {
+	s := "// Code generated by flowbase gen proto. DO NOT EDIT.\n\n"
+	s += fmt.Sprintf("package %s\n\n", pkgName)
+	s += "import (\n\t\"github.com/flowbase/flowbase\"\n\t\"github.com/flowbase/flowbase/pbwire\"\n)\n\n"
+
+	s += "func init() {\n"
+	for _, msg := range msgs {
+		s += fmt.Sprintf("\tflowbase.RegisterProtoType(%q, func() flowbase.ProtoMessage { return &%s{} })\n", pkgName+"."+msg.Name, msg.Name)
+	}
+	s += "}\n"
+
+	for _, msg := range msgs {
+		s += fmt.Sprintf("\n// ProtoTypeName returns the fully qualified protobuf message name of %s\n", msg.Name)
+		s += fmt.Sprintf("func (m *%s) ProtoTypeName() string { return %q }\n", msg.Name, pkgName+"."+msg.Name)
+
+		s += fmt.Sprintf("\n// MarshalProto encodes %s in the protobuf wire format\n", msg.Name)
+		s += fmt.Sprintf("func (m *%s) MarshalProto() ([]byte, error) {\n", msg.Name)
+		s += "\tbuf := &pbwire.Buffer{}\n"
+		for _, f := range msg.Fields {
+			s += genMarshalField(f)
+		}
+		s += "\treturn buf.Bytes(), nil\n}\n"
+
+		s += fmt.Sprintf("\n// UnmarshalProto decodes %s from the protobuf wire format\n", msg.Name)
+		s += fmt.Sprintf("func (m *%s) UnmarshalProto(b []byte) error {\n", msg.Name)
+		s += "\tr := pbwire.NewReader(b)\n"
+		s += "\tfor !r.Done() {\n"
+		s += "\t\tfield, wireType, err := r.Next()\n"
+		s += "\t\tif err != nil {\n\t\t\treturn err\n\t\t}\n"
+		s += "\t\tswitch field {\n"
+		for _, f := range msg.Fields {
+			s += fmt.Sprintf("\t\tcase %d:\n", f.Num)
+			s += genUnmarshalField(f)
+		}
+		s += "\t\tdefault:\n\t\t\terr = r.Skip(wireType)\n"
+		s += "\t\t}\n"
+		s += "\t\tif err != nil {\n\t\t\treturn err\n\t\t}\n"
+		s += "\t}\n\treturn nil\n}\n"
+	}
+	return format.Source([]byte(s))
+}
+
+func genMarshalField(f *protoField) string {
+	switch f.Kind {
+	case "map":
+		return fmt.Sprintf("\tbuf.StringMap(%d, m.%s)\n", f.Num, f.GoName)
+	case "message":
+		write := fmt.Sprintf("b, err := %%s.MarshalProto()\nif err != nil {\nreturn nil, err\n}\nbuf.RawBytes(%d, b)\n", f.Num)
+		if f.Repeated {
+			inner := fmt.Sprintf(write, "m."+f.GoName+"[i]")
+			if f.Pointer {
+				inner = fmt.Sprintf("if m.%s[i] == nil {\ncontinue\n}\n", f.GoName) + inner
+			}
+			return fmt.Sprintf("for i := range m.%s {\n%s}\n", f.GoName, inner)
+		}
+		if f.Pointer {
+			return fmt.Sprintf("if m.%s != nil {\n%s}\n", f.GoName, fmt.Sprintf(write, "m."+f.GoName))
+		}
+		return fmt.Sprintf("{\n%s}\n", fmt.Sprintf(write, "m."+f.GoName))
+	}
+	w := wireWriters[f.Kind]
+	val := "v"
+	if !f.Repeated {
+		val = "m." + f.GoName
+	}
+	if w[1] != "" && w[1] != f.GoType {
+		val = fmt.Sprintf("%s(%s)", w[1], val)
+	}
+	if f.Repeated {
+		return fmt.Sprintf("for _, v := range m.%s {\nbuf.%s(%d, %s)\n}\n", f.GoName, w[0], f.Num, val)
+	}
+	return fmt.Sprintf("buf.%s(%d, %s)\n", w[0], f.Num, val)
+}
+
+func genUnmarshalField(f *protoField) string {
+	target := "m." + f.GoName
+	switch f.Kind {
+	case "map":
+		return fmt.Sprintf("if %s == nil {\n%s = map[string]string{}\n}\nerr = r.StringMapEntry(%s)\n", target, target, target)
+	case "message":
+		val := "*v"
+		if f.Pointer {
+			val = "v"
+		}
+		assign := fmt.Sprintf("%s = %s", target, val)
+		if f.Repeated {
+			assign = fmt.Sprintf("%s = append(%s, %s)", target, target, val)
+		}
+		return fmt.Sprintf("var b []byte\nif b, err = r.RawBytes(); err == nil {\nv := &%s{}\nif err = v.UnmarshalProto(b); err == nil {\n%s\n}\n}\n", f.GoType, assign)
+	case "bytes":
+		return fmt.Sprintf("var v []byte\nv, err = r.RawBytes()\n%s = append([]byte(nil), v...)\n", target)
+	}
+
+	w := wireWriters[f.Kind]
+	readType := w[1]
+	if readType == "" {
+		readType = f.GoType
+	}
+	val := "v"
+	if readType != f.GoType {
+		val = fmt.Sprintf("%s(v)", f.GoType)
+	}
+	if !f.Repeated {
+		return fmt.Sprintf("var v %s\nv, err = r.%s()\n%s = %s\n", readType, w[0], target, val)
+	}
+	if f.Kind == "string" {
+		return fmt.Sprintf("var v string\nv, err = r.String()\n%s = append(%s, v)\n", target, target)
+	}
+	return fmt.Sprintf("err = r.Repeated(wireType, func(r *pbwire.Reader) error {\nv, err := r.%s()\n%s = append(%s, %s)\nreturn err\n})\n", w[0], target, target, val)
+}
+
+// ----------------------------------------------------------------------------
+// Helpers
+// ----------------------------------------------------------------------------
+
+func splitNonEmpty(s string) []string {
+	parts := []string{}
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return parts
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const genProtoTypesSrc = `package main
+
+// flowbase:packet
+type Sample struct {
+	Name    string
+	Count   int
+	Small   uint32
+	Score   float64
+	Ratio   float32
+	OK      bool
+	Raw     []byte
+	Labels  []string
+	Values  []int64
+	Meta    map[string]string
+	Reading Reading
+	Extra   *Reading
+	History []*Reading
+}
+
+// flowbase:packet
+type Reading struct {
+	Value float64
+}
+`
+
+const genProtoMainSrc = `package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+
+	"github.com/flowbase/flowbase"
+)
+
+func main() {
+	s := &Sample{
+		Name:    "a",
+		Count:   -3,
+		Small:   7,
+		Score:   1.5,
+		Ratio:   0.25,
+		OK:      true,
+		Raw:     []byte{0, 1, 2},
+		Labels:  []string{"x", "y"},
+		Values:  []int64{1, -2, 300},
+		Meta:    map[string]string{"k": "v"},
+		Reading: Reading{Value: 2},
+		Extra:   &Reading{Value: 3},
+		History: []*Reading{{Value: 4}, {Value: 5}},
+	}
+	ip := flowbase.NewPacket(s)
+	ip.AddTag("sample", "s1")
+	codec := flowbase.NewProtoCodec()
+	buf := &bytes.Buffer{}
+	if err := codec.NewEncoder(buf).Encode(ip); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	got, err := codec.NewDecoder(buf).Decode()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if !reflect.DeepEqual(s, got.Data()) || got.Tag("sample") != "s1" {
+		fmt.Printf("Round-tripped packet differs: %#v\n", got.Data())
+		os.Exit(1)
+	}
+	fmt.Println("ok")
+}
+`
+
+// TestGenProtoCompiles generates the protobuf codec for packet types in a
+// temporary module, and checks that it builds and round-trips packets
+func TestGenProtoCompiles(t *testing.T) {
+	dir := t.TempDir()
+	writeTestModule(t, dir, "example.com/packets", map[string]string{
+		"types.go": genProtoTypesSrc,
+		"main.go":  genProtoMainSrc,
+	})
+
+	if err := runGenProto([]string{"-dir", dir}); err != nil {
+		t.Fatalf("Could not generate protobuf code: %v", err)
+	}
+	for _, name := range []string{"packets.proto", "packets_pb.go"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("File %s was not generated: %v", name, err)
+		}
+	}
+
+	out, err := runGo(t, dir, "run", ".")
+	if err != nil || strings.TrimSpace(out) != "ok" {
+		t.Errorf("Generated code did not build and round-trip packets: %v\n%s", err, out)
+	}
+}
@@ -0,0 +1,101 @@
+// Command flowbase contains tooling for developing FlowBase networks and
+// components, such as code generators.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const usage = `Usage: flowbase <command> [arguments]
+
+Commands:
+  components   List the available components, with their ports and versions
+  doc          Generate Markdown documentation, or an mkdocs site, for the
+               available components
+  gen proto    Generate .proto definitions and protobuf codecs for packet types
+  gen go       Generate Go wiring code, and component stubs, from a .fbp or
+               NoFlo JSON graph file
+  gen openapi  Generate REST client components, for the operations of an
+               OpenAPI spec (JSON)
+  migrate      Rewrite code using the v1 API to the experimental v2 API, as
+               far as possible, and write a guide to the remaining changes
+  new-component
+               Generate a component stub, in the package of the directory
+  new-pipeline Generate a runnable example pipeline, from one of the
+               templates etl, stream, ml or batch
+  pipe         Run a .fbp or NoFlo JSON graph file in a Unix pipeline, with
+               its exported in-port reading from stdin and its exported
+               out-port writing to stdout
+  report gantt Generate a Gantt chart (HTML or SVG) of the tasks of a run,
+               from the audit files of its outputs
+  report critical-path
+               Show the chain of tasks that determined the run time of a
+               run, from the audit files of its outputs
+  report simulate
+               Estimate the run time of a run with other numbers of
+               concurrent tasks, cores or memory, from its audit files
+  report usage Show the memory, CPU and I/O used by the tasks of each
+               process of a run, from its audit files
+  templates    Copy the built-in templates of the generators to
+               ~/.flowbase/templates, for customizing them
+
+The generators use Go text/template files, which are replaced by the files
+with the same paths in ~/.flowbase/templates (or $FLOWBASE_TEMPLATES), such
+as component.go.tmpl for components, and pipelines/<name>/ for pipeline
+templates.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(1)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "components":
+		err = runComponents(os.Args[2:])
+	case "doc":
+		err = runDoc(os.Args[2:])
+	case "gen":
+		err = runGen(os.Args[2:])
+	case "migrate":
+		err = runMigrate(os.Args[2:])
+	case "new-component":
+		err = runNewComponent(os.Args[2:])
+	case "new-pipeline":
+		err = runNewPipeline(os.Args[2:])
+	case "pipe":
+		err = runPipe(os.Args[2:])
+	case "report":
+		err = runReport(os.Args[2:])
+	case "templates":
+		err = runTemplates(os.Args[2:])
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n%s", os.Args[1], usage)
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		os.Exit(1)
+	}
+}
+
+func runGen(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("missing generator name, e.g: flowbase gen proto")
+	}
+	switch args[0] {
+	case "proto":
+		return runGenProto(args[1:])
+	case "go":
+		return runGenGo(args[1:])
+	case "openapi":
+		return runGenOpenAPI(args[1:])
+	default:
+		return fmt.Errorf("unknown generator: %s", args[0])
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "Update the golden files in testdata")
+
+// assertGolden compares got with the golden file at path, or writes got to
+// it when tests are run with -update
+func assertGolden(t *testing.T, path string, got []byte) {
+	t.Helper()
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Could not read golden file (run go test -update to create it): %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Output differs from golden file %s (run go test -update to update it)\nGot:\n%s\nWant:\n%s", path, got, want)
+	}
+}
+
+// goCommand returns the path of the go command, skipping the test if there
+// is none
+func goCommand(t *testing.T) string {
+	t.Helper()
+	goBin := filepath.Join(runtime.GOROOT(), "bin", "go")
+	if _, err := os.Stat(goBin); err != nil {
+		t.Skipf("No go command found: %v", err)
+	}
+	return goBin
+}
+
+// writeTestModule writes files, and a go.mod for the module modPath which
+// requires flowbase from this repository, to dir
+func writeTestModule(t *testing.T, dir string, modPath string, files map[string]string) {
+	t.Helper()
+	repoDir, err := filepath.Abs("../..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	goMod := "module " + modPath + "\n\ngo 1.18\n\nrequire github.com/flowbase/flowbase v0.0.0\n\nreplace github.com/flowbase/flowbase => " + repoDir + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(goMod), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// runGo runs the go command with args in dir, without network access, and
+// returns its combined output
+func runGo(t *testing.T, dir string, args ...string) (string, error) {
+	t.Helper()
+	cmd := exec.Command(goCommand(t), args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off", "GOWORK=off")
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+// useBuiltinTemplates makes the generators use only the built-in templates,
+// and not the user's own
+func useBuiltinTemplates(t *testing.T) {
+	t.Setenv("FLOWBASE_TEMPLATES", t.TempDir())
+}
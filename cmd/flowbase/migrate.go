@@ -0,0 +1,550 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	v1ImportPath = "github.com/flowbase/flowbase"
+	v2ImportPath = "github.com/flowbase/flowbase/v2"
+)
+
+func runMigrate(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	write := flags.Bool("w", false, "Write the rewritten files in place, instead of only listing them")
+	guidePath := flags.String("guide", "", "File to write the migration guide to, instead of stdout")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		return fmt.Errorf("expected Go files or directories to migrate, e.g: flowbase migrate -w ./...")
+	}
+
+	paths, err := goFiles(flags.Args())
+	if err != nil {
+		return err
+	}
+	migrations := []*fileMigration{}
+	for _, path := range paths {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		m, err := migrateFile(path, src)
+		if err != nil {
+			return err
+		}
+		if m == nil {
+			continue
+		}
+		migrations = append(migrations, m)
+		if *write {
+			if err := os.WriteFile(path, m.src, 0644); err != nil {
+				return err
+			}
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if *guidePath != "" {
+		f, err := os.Create(*guidePath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	writeMigrationGuide(w, migrations, *write)
+	return nil
+}
+
+// goFiles returns the Go files in paths, which are files, directories, or
+// directories followed by /... for all Go files under them, skipping
+// generated files
+func goFiles(paths []string) ([]string, error) {
+	files := []string{}
+	for _, path := range paths {
+		recursive := strings.HasSuffix(path, "/...")
+		path = strings.TrimSuffix(path, "/...")
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() && p != path && (!recursive || d.Name() == "vendor" || strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			if !d.IsDir() && strings.HasSuffix(p, ".go") {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// fileMigration is the migration of a Go file to the v2 API
+type fileMigration struct {
+	path string
+	// src is the rewritten source of the file
+	src []byte
+	// rewrites describe the changes made automatically
+	rewrites []string
+	// todos are the changes that need to be made by hand
+	todos []migrationTodo
+}
+
+// migrationTodo is a change of a line that needs to be made by hand
+type migrationTodo struct {
+	line   int
+	code   string
+	advice string
+}
+
+// migrateFile rewrites the source src of the Go file at path to the v2 API,
+// as far as can be done automatically, or returns nil if it does not import
+// the v1 API
+func migrateFile(path string, src []byte) (*fileMigration, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	var fbImport *ast.ImportSpec
+	for _, spec := range file.Imports {
+		if importPath(spec) == v1ImportPath {
+			fbImport = spec
+		}
+	}
+	if fbImport == nil || ast.IsGenerated(file) {
+		return nil, nil
+	}
+	m := &fileMigration{path: path}
+	pkg := "flowbase"
+	if fbImport.Name != nil {
+		pkg = fbImport.Name.Name
+	}
+	fbImport.Path.Value = strconv.Quote(v2ImportPath)
+	m.rewrites = append(m.rewrites, "Imports the v2 API")
+
+	imports := map[string]bool{}
+	migrateRunMethods(m, file, pkg, imports)
+	migrateNetworkRuns(m, file, pkg, imports)
+	// The nodes left from the original source keep their positions in it
+	m.todos = migrationTodos(fset, file, src, pkg)
+	for _, path := range []string{"context", "fmt", "log"} {
+		if imports[path] {
+			addImport(file, path)
+		}
+	}
+	ast.SortImports(fset, file)
+
+	buf := &bytes.Buffer{}
+	if err := format.Node(buf, fset, file); err != nil {
+		return nil, err
+	}
+	if m.src, err = groupImports(buf.Bytes()); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// groupImports returns the Go source src with the imports of its first
+// import declaration in two groups, the standard library imports first, as
+// the imports added by the rewrites end up in the group of the v1 import
+func groupImports(src []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	var decl *ast.GenDecl
+	for _, d := range file.Decls {
+		if gen, ok := d.(*ast.GenDecl); ok && gen.Tok == token.IMPORT && gen.Lparen.IsValid() {
+			decl = gen
+			break
+		}
+	}
+	if decl == nil {
+		return src, nil
+	}
+	var std, other []string
+	for _, spec := range decl.Specs {
+		spec := spec.(*ast.ImportSpec)
+		start, end := spec.Pos(), spec.End()
+		if spec.Doc != nil {
+			start = spec.Doc.Pos()
+		}
+		if spec.Comment != nil {
+			end = spec.Comment.End()
+		}
+		code := string(src[fset.Position(start).Offset:fset.Position(end).Offset])
+		if strings.Contains(strings.SplitN(importPath(spec), "/", 2)[0], ".") {
+			other = append(other, code)
+		} else {
+			std = append(std, code)
+		}
+	}
+	groups := []string{}
+	for _, group := range [][]string{std, other} {
+		if len(group) > 0 {
+			groups = append(groups, "\t"+strings.Join(group, "\n\t")+"\n")
+		}
+	}
+	lparen, rparen := fset.Position(decl.Lparen).Offset, fset.Position(decl.Rparen).Offset
+	out := string(src[:lparen+1]) + "\n" + strings.Join(groups, "\n") + string(src[rparen:])
+	return format.Source([]byte(out))
+}
+
+// migrateRunMethods rewrites the Run methods of the processes declared in
+// file, of types embedding the BaseProcess of the package pkg, to the
+// signature of v2 processes, returning errors instead of calling Fail and
+// Failf
+func migrateRunMethods(m *fileMigration, file *ast.File, pkg string, imports map[string]bool) {
+	procTypes := map[string]bool{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		ts, ok := n.(*ast.TypeSpec)
+		if !ok {
+			return true
+		}
+		if st, ok := ts.Type.(*ast.StructType); ok {
+			for _, field := range st.Fields.List {
+				if len(field.Names) == 0 && isSelector(field.Type, pkg, "BaseProcess") {
+					procTypes[ts.Name.Name] = true
+				}
+			}
+		}
+		return true
+	})
+
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv == nil || fn.Name.Name != "Run" || fn.Body == nil ||
+			len(fn.Type.Params.List) > 0 || fn.Type.Results != nil {
+			continue
+		}
+		typeName := receiverTypeName(fn.Recv.List[0].Type)
+		if !procTypes[typeName] {
+			continue
+		}
+		recv := ""
+		if names := fn.Recv.List[0].Names; len(names) > 0 {
+			recv = names[0].Name
+		}
+		fn.Type.Params.List = []*ast.Field{{
+			Names: []*ast.Ident{ast.NewIdent("ctx")},
+			Type:  selector("context", "Context"),
+		}}
+		fn.Type.Results = &ast.FieldList{List: []*ast.Field{{Type: ast.NewIdent("error")}}}
+		imports["context"] = true
+		rewriteStmts(fn.Body, func(stmt ast.Stmt) ast.Stmt {
+			switch stmt := stmt.(type) {
+			case *ast.ReturnStmt:
+				if len(stmt.Results) == 0 {
+					stmt.Results = []ast.Expr{ast.NewIdent("nil")}
+				}
+			case *ast.ExprStmt:
+				call, ok := stmt.X.(*ast.CallExpr)
+				if !ok || recv == "" {
+					return stmt
+				}
+				args := call.Args
+				if isSelector(call.Fun, recv, "Fail") && len(args) == 1 {
+					args = []ast.Expr{&ast.BasicLit{ValuePos: call.Lparen, Kind: token.STRING, Value: `"%v"`}, args[0]}
+				} else if !isSelector(call.Fun, recv, "Failf") {
+					return stmt
+				}
+				imports["fmt"] = true
+				// The new nodes are positioned at the call, to keep the
+				// comments around it in place
+				errorf := &ast.CallExpr{Fun: selectorAt("fmt", "Errorf", call.Pos()), Lparen: call.Lparen, Args: args, Rparen: call.Rparen}
+				return &ast.ReturnStmt{Return: call.Pos(), Results: []ast.Expr{errorf}}
+			}
+			return stmt
+		})
+		if n := len(fn.Body.List); n == 0 || !isReturn(fn.Body.List[n-1]) {
+			fn.Body.List = append(fn.Body.List, &ast.ReturnStmt{Results: []ast.Expr{ast.NewIdent("nil")}})
+		}
+		m.rewrites = append(m.rewrites, fmt.Sprintf("Changes the Run method of %s to take a context and return an error, returning the errors of Fail and Failf calls", typeName))
+	}
+}
+
+// migrateNetworkRuns rewrites the calls to the Run method of networks
+// created with NewNetwork of the package pkg, which are run with a
+// background context, and exit the program with the error, if any
+func migrateNetworkRuns(m *fileMigration, file *ast.File, pkg string, imports map[string]bool) {
+	networks := map[string]bool{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		if assign, ok := n.(*ast.AssignStmt); ok && len(assign.Lhs) == len(assign.Rhs) {
+			for i, rhs := range assign.Rhs {
+				if call, ok := rhs.(*ast.CallExpr); ok && isSelector(call.Fun, pkg, "NewNetwork") {
+					if id, ok := assign.Lhs[i].(*ast.Ident); ok {
+						networks[id.Name] = true
+					}
+				}
+			}
+		}
+		return true
+	})
+
+	count := 0
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil {
+			continue
+		}
+		rewriteStmts(fn.Body, func(stmt ast.Stmt) ast.Stmt {
+			exprStmt, ok := stmt.(*ast.ExprStmt)
+			if !ok {
+				return stmt
+			}
+			call, ok := exprStmt.X.(*ast.CallExpr)
+			if !ok || len(call.Args) > 0 {
+				return stmt
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok || sel.Sel.Name != "Run" {
+				return stmt
+			}
+			if id, ok := sel.X.(*ast.Ident); !ok || !networks[id.Name] {
+				return stmt
+			}
+			count++
+			imports["context"], imports["log"] = true, true
+			// The new nodes are positioned at the call, to keep the comments
+			// around it in place
+			start, end := call.Pos(), call.End()
+			call.Args = []ast.Expr{&ast.CallExpr{Fun: selectorAt("context", "Background", call.Rparen), Lparen: call.Rparen, Rparen: call.Rparen}}
+			fatal := &ast.CallExpr{Fun: selectorAt("log", "Fatal", end), Lparen: end, Args: []ast.Expr{identAt("err", end)}, Rparen: end}
+			return &ast.IfStmt{
+				If:   start,
+				Init: &ast.AssignStmt{Lhs: []ast.Expr{identAt("err", start)}, TokPos: start, Tok: token.DEFINE, Rhs: []ast.Expr{call}},
+				Cond: &ast.BinaryExpr{X: identAt("err", end), OpPos: end, Op: token.NEQ, Y: identAt("nil", end)},
+				Body: &ast.BlockStmt{Lbrace: end, List: []ast.Stmt{&ast.ExprStmt{X: fatal}}, Rbrace: end},
+			}
+		})
+	}
+	if count > 0 {
+		m.rewrites = append(m.rewrites, fmt.Sprintf("Runs %d network(s) with a context, exiting with the returned error", count))
+	}
+}
+
+// migrationAdvice is the advice for calls of methods and functions of the v1
+// API that can not be migrated automatically, by their names
+var migrationAdvice = map[string]string{
+	"InitInPort":  "Declare the port as a field of type *fb.InPort[T], created with fb.NewInPort[T](&p.BaseProcess, name)",
+	"InitOutPort": "Declare the port as a field of type *fb.OutPort[T], created with fb.NewOutPort[T](&p.BaseProcess, name)",
+	"InPort":      "Use the typed port field instead of looking up the port by name",
+	"OutPort":     "Use the typed port field instead of looking up the port by name",
+	"RecvOK":      "Use Recv(ctx), which returns io.EOF when the port is closed, and the error of ctx when canceled",
+	"Recv":        "Recv takes a context, and returns io.EOF when the port is closed, and the error of ctx when canceled",
+	"Send":        "Send takes a context, and the value itself, and returns an error to return from Run",
+	"SendPacket":  "Use Send(ctx, v), which returns an error to return from Run",
+	"NewPacket":   "Ports send values of their type, instead of packets; use a struct type for values with tags",
+	"Failf":       "Return an error instead, from Run, or from functions called by it",
+	"Fail":        "Return an error instead, from Run, or from functions called by it",
+}
+
+// migrationTodos returns the calls left in file, after the automatic
+// rewrites, which need to be migrated by hand, as flagged by
+// migrationAdvice
+func migrationTodos(fset *token.FileSet, file *ast.File, src []byte, pkg string) []migrationTodo {
+	todos := []migrationTodo{}
+	seen := map[migrationTodo]bool{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || !call.Pos().IsValid() {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		advice, ok := migrationAdvice[sel.Sel.Name]
+		if !ok {
+			return true
+		}
+		if sel.Sel.Name == "NewPacket" && !isSelector(sel, pkg, "NewPacket") {
+			return true
+		}
+		pos := fset.Position(call.Pos())
+		code := string(src[pos.Offset:fset.Position(call.End()).Offset])
+		if i := strings.Index(code, "\n"); i >= 0 {
+			code = code[:i] + " ..."
+		}
+		todo := migrationTodo{line: pos.Line, code: code, advice: advice}
+		if !seen[todo] {
+			seen[todo] = true
+			todos = append(todos, todo)
+		}
+		return true
+	})
+	sort.SliceStable(todos, func(i, j int) bool { return todos[i].line < todos[j].line })
+	return todos
+}
+
+// writeMigrationGuide writes a Markdown guide to the migration of the files
+// of migrations, listing the changes made automatically, or to be made if
+// written is false, and the changes to make by hand
+func writeMigrationGuide(w io.Writer, migrations []*fileMigration, written bool) {
+	fmt.Fprintln(w, "# Migration to the FlowBase v2 API")
+	fmt.Fprintln(w)
+	if len(migrations) == 0 {
+		fmt.Fprintln(w, "No files import the v1 API ("+v1ImportPath+").")
+		return
+	}
+	fmt.Fprintln(w, "The v2 API is experimental, and only built with the flowbase_v2 build tag, as in:")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "    go build -tags flowbase_v2 ./...")
+	fmt.Fprintln(w)
+	if written {
+		fmt.Fprintln(w, "## Changes made")
+	} else {
+		fmt.Fprintln(w, "## Changes to be made by flowbase migrate -w")
+	}
+	fmt.Fprintln(w)
+	for _, m := range migrations {
+		fmt.Fprintf(w, "- %s\n", m.path)
+		for _, rewrite := range m.rewrites {
+			fmt.Fprintf(w, "  - %s\n", rewrite)
+		}
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "## Changes to make by hand")
+	for _, m := range migrations {
+		if len(m.todos) == 0 {
+			continue
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "### %s\n\n", m.path)
+		for _, todo := range m.todos {
+			fmt.Fprintf(w, "- Line %d: `%s`  \n  %s\n", todo.line, todo.code, todo.advice)
+		}
+	}
+}
+
+// rewriteStmts replaces the statements in block, and in the blocks nested in
+// its statements, apart from function literals, with the results of fn
+func rewriteStmts(block *ast.BlockStmt, fn func(ast.Stmt) ast.Stmt) {
+	var rewriteList func(stmts []ast.Stmt)
+	var rewriteNested func(stmt ast.Stmt)
+	rewriteList = func(stmts []ast.Stmt) {
+		for i, stmt := range stmts {
+			rewriteNested(stmt)
+			stmts[i] = fn(stmt)
+		}
+	}
+	rewriteNested = func(stmt ast.Stmt) {
+		switch stmt := stmt.(type) {
+		case *ast.BlockStmt:
+			rewriteList(stmt.List)
+		case *ast.IfStmt:
+			rewriteList(stmt.Body.List)
+			if stmt.Else != nil {
+				rewriteNested(stmt.Else)
+			}
+		case *ast.ForStmt:
+			rewriteList(stmt.Body.List)
+		case *ast.RangeStmt:
+			rewriteList(stmt.Body.List)
+		case *ast.SwitchStmt:
+			rewriteList(stmt.Body.List)
+		case *ast.TypeSwitchStmt:
+			rewriteList(stmt.Body.List)
+		case *ast.SelectStmt:
+			rewriteList(stmt.Body.List)
+		case *ast.CaseClause:
+			rewriteList(stmt.Body)
+		case *ast.CommClause:
+			rewriteList(stmt.Body)
+		case *ast.LabeledStmt:
+			rewriteNested(stmt.Stmt)
+		}
+	}
+	rewriteList(block.List)
+}
+
+// addImport adds the import of path to file, unless it is imported already
+func addImport(file *ast.File, path string) {
+	for _, spec := range file.Imports {
+		if importPath(spec) == path {
+			return
+		}
+	}
+	spec := &ast.ImportSpec{Path: &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(path)}}
+	file.Imports = append(file.Imports, spec)
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			gen.Specs = append(gen.Specs, spec)
+			if !gen.Lparen.IsValid() {
+				gen.Lparen = gen.Pos()
+			}
+			return
+		}
+	}
+}
+
+func importPath(spec *ast.ImportSpec) string {
+	path, _ := strconv.Unquote(spec.Path.Value)
+	return path
+}
+
+// isSelector tells whether expr is the selector x.sel
+func isSelector(expr ast.Expr, x string, sel string) bool {
+	s, ok := expr.(*ast.SelectorExpr)
+	if !ok || s.Sel.Name != sel {
+		return false
+	}
+	id, ok := s.X.(*ast.Ident)
+	return ok && id.Name == x
+}
+
+func selector(x string, sel string) *ast.SelectorExpr {
+	return selectorAt(x, sel, token.NoPos)
+}
+
+// selectorAt returns the selector x.sel, at the position pos
+func selectorAt(x string, sel string, pos token.Pos) *ast.SelectorExpr {
+	return &ast.SelectorExpr{X: identAt(x, pos), Sel: identAt(sel, pos)}
+}
+
+func identAt(name string, pos token.Pos) *ast.Ident {
+	return &ast.Ident{NamePos: pos, Name: name}
+}
+
+// receiverTypeName returns the name of the type of a method receiver, such
+// as T for *T
+func receiverTypeName(expr ast.Expr) string {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	if id, ok := expr.(*ast.Ident); ok {
+		return id.Name
+	}
+	return ""
+}
+
+func isReturn(stmt ast.Stmt) bool {
+	_, ok := stmt.(*ast.ReturnStmt)
+	return ok
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestMigrateGolden migrates the files testdata/migrate/<name>.input.go,
+// and compares the results, and their migration guides, with the golden
+// files <name>.golden.go and <name>.guide.md. The results of the files that
+// need no changes by hand are checked to build with the v2 API.
+func TestMigrateGolden(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		// builds tells whether the migrated file builds as it is
+		builds bool
+	}{
+		// Run methods of processes, with Fail, Failf and returns nested in
+		// blocks, and the run of a network, with a named v1 import
+		{name: "process", builds: true},
+		// The run of a network in a block, with an unnamed v1 import, an
+		// existing log import, and a Run method of a type that is not a
+		// process
+		{name: "network", builds: true},
+		// A Fail call, in a file with a single import, without parentheses
+		{name: "single", builds: true},
+		// Ports and packets, to be migrated by hand
+		{name: "ports"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := filepath.Join("testdata", "migrate")
+			inputPath := filepath.Join(dir, tc.name+".input.go")
+			src, err := os.ReadFile(inputPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := migrateFile(filepath.ToSlash(inputPath), src)
+			if err != nil {
+				t.Fatalf("Could not migrate %s: %v", inputPath, err)
+			}
+			if m == nil {
+				t.Fatalf("File %s was not migrated", inputPath)
+			}
+			assertGolden(t, filepath.Join(dir, tc.name+".golden.go"), m.src)
+
+			guide := &bytes.Buffer{}
+			writeMigrationGuide(guide, []*fileMigration{m}, true)
+			assertGolden(t, filepath.Join(dir, tc.name+".guide.md"), guide.Bytes())
+
+			if len(m.todos) > 0 == tc.builds {
+				t.Errorf("Expected changes by hand to be needed: %v, got %d", !tc.builds, len(m.todos))
+			}
+			if !tc.builds {
+				return
+			}
+			modDir := t.TempDir()
+			writeTestModule(t, modDir, "example.com/migrated", map[string]string{"main.go": string(m.src)})
+			if out, err := runGo(t, modDir, "build", "-tags", "flowbase_v2", "."); err != nil {
+				t.Errorf("Migrated file did not build with the v2 API: %v\n%s", err, out)
+			}
+		})
+	}
+}
+
+func TestMigrateSkipsFiles(t *testing.T) {
+	for name, src := range map[string]string{
+		"no v1 import": "package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Println() }\n",
+		"generated":    "// Code generated by flowbase gen go. DO NOT EDIT.\n\npackage main\n\nimport fb \"github.com/flowbase/flowbase\"\n\nfunc main() { fb.NewNetwork(\"x\").Run() }\n",
+	} {
+		m, err := migrateFile("main.go", []byte(src))
+		if err != nil {
+			t.Errorf("Could not migrate file with %s: %v", name, err)
+		}
+		if m != nil {
+			t.Errorf("Expected file with %s to be skipped, got:\n%s", name, m.src)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+func runNewComponent(args []string) error {
+	flags := flag.NewFlagSet("new-component", flag.ExitOnError)
+	dir := flags.String("dir", ".", "Directory to write the component to")
+	pkg := flags.String("package", "", "Package name of the component. Defaults to the package of the Go files in -dir, or else a name derived from go.mod or the directory.")
+	inPorts := flags.String("in", "in", "Comma-separated names of the in-ports")
+	outPorts := flags.String("out", "out", "Comma-separated names of the out-ports")
+	force := flags.Bool("force", false, "Overwrite the component file if it exists")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("expected one component name, e.g: flowbase new-component WordCounter")
+	}
+	typeName := goIdent(flags.Arg(0), true)
+
+	if *pkg == "" {
+		var err error
+		if *pkg, err = detectPackageName(*dir); err != nil {
+			return err
+		}
+	} else if !token.IsIdentifier(*pkg) {
+		return fmt.Errorf("invalid package name: %s", *pkg)
+	}
+	path := filepath.Join(*dir, snakeCase(typeName)+".go")
+	if _, err := os.Stat(path); err == nil && !*force {
+		return fmt.Errorf("not overwriting existing file %s, use -force to overwrite it", path)
+	}
+
+	data := newComponentData(*pkg, typeName, "flowbase new-component", splitNonEmpty(*inPorts), splitNonEmpty(*outPorts))
+	src, err := genComponentStub(templatesFS(), data)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, src, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Created component %s in package %s, in %s\n", typeName, *pkg, path)
+	return nil
+}
+
+// detectPackageName returns the name of the package in dir, from the
+// package clause of its Go files, or, if it has none, from the module path
+// in its go.mod file, or else the name of dir itself
+func detectPackageName(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	fset := token.NewFileSet()
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".go") || strings.HasSuffix(e.Name(), "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filepath.Join(dir, e.Name()), nil, parser.PackageClauseOnly)
+		if err != nil {
+			return "", err
+		}
+		return file.Name.Name, nil
+	}
+
+	modPath, err := readModulePath(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return "", err
+	}
+	name := modPath
+	if name == "" {
+		absDir, err := filepath.Abs(dir)
+		if err != nil {
+			return "", err
+		}
+		name = absDir
+	}
+	if pkg := packageNameFromPath(name); pkg != "" {
+		return pkg, nil
+	}
+	return "", fmt.Errorf("could not detect the package name for %s, use -package to set it", dir)
+}
+
+// readModulePath returns the module path in the go.mod file at path, or ""
+// if there is no such file
+func readModulePath(path string) (string, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], `"`), nil
+		}
+	}
+	return "", scanner.Err()
+}
+
+// packageNameFromPath returns a package name for the import or file path
+// path, from its last element, such as "flowbase" for
+// "github.com/flowbase/go-flowbase", skipping major version suffixes
+func packageNameFromPath(path string) string {
+	elems := strings.FieldsFunc(filepath.ToSlash(path), func(r rune) bool { return r == '/' })
+	for i := len(elems) - 1; i >= 0; i-- {
+		elem := elems[i]
+		if len(elem) > 1 && elem[0] == 'v' && strings.Trim(elem[1:], "0123456789") == "" {
+			continue
+		}
+		elem = strings.TrimPrefix(strings.TrimSuffix(elem, "-go"), "go-")
+		name := strings.Map(func(r rune) rune {
+			if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+				return unicode.ToLower(r)
+			}
+			return -1
+		}, elem)
+		if name == "" || unicode.IsDigit(rune(name[0])) || token.IsKeyword(name) {
+			return ""
+		}
+		return name
+	}
+	return ""
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const wordCounterMainSrc = `package main
+
+import (
+	"fmt"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+func main() {
+	net := fb.NewNetwork("stub")
+	src := fb.NewIIPSource(net, "src", "hello")
+	counter := NewWordCounter(net, "counter")
+	counter.In().From(src.Out())
+	sink := fb.NewPacketCollector(net, "sink")
+	sink.In().From(counter.Out())
+	net.Run()
+	for _, data := range sink.Data {
+		fmt.Printf("%T %v\n", data, data)
+	}
+}
+`
+
+// TestNewComponentRuns generates a component stub, and checks that it
+// builds, and forwards the packets it receives unchanged
+func TestNewComponentRuns(t *testing.T) {
+	useBuiltinTemplates(t)
+	dir := t.TempDir()
+	writeTestModule(t, dir, "example.com/stub", map[string]string{"main.go": wordCounterMainSrc})
+
+	if err := runNewComponent([]string{"-dir", dir, "WordCounter"}); err != nil {
+		t.Fatalf("Could not generate component: %v", err)
+	}
+	src, err := os.ReadFile(filepath.Join(dir, "word_counter.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(src), "p.Out().SendPacket(ip)") {
+		t.Errorf("Expected the stub to forward packets with SendPacket:\n%s", src)
+	}
+
+	out, err := runGo(t, dir, "run", ".")
+	if err != nil {
+		t.Fatalf("Generated stub did not build: %v\n%s", err, out)
+	}
+	if got := strings.TrimSpace(out); got != "string hello" {
+		t.Errorf("Expected the stub to forward the data string hello, got: %s", got)
+	}
+}
+
+func TestDetectPackageName(t *testing.T) {
+	for _, tc := range []struct {
+		desc  string
+		dir   string
+		files map[string]string
+		want  string
+	}{
+		{
+			desc: "package clause of the first non-test Go file",
+			dir:  "pipeline",
+			files: map[string]string{
+				"a_test.go": "package other_test\n",
+				"b.go":      "package stages\n",
+				"go.mod":    "module example.com/mod\n",
+			},
+			want: "stages",
+		},
+		{
+			desc:  "module path in go.mod",
+			dir:   "pipeline",
+			files: map[string]string{"go.mod": "module github.com/acme/go-word-tools/v2\n"},
+			want:  "wordtools",
+		},
+		{
+			desc: "name of the directory",
+			dir:  "My-Stages",
+			want: "mystages",
+		},
+		{
+			desc: "name of a directory which does not exist yet",
+			dir:  "new/counters",
+			want: "counters",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), tc.dir)
+			for name, src := range tc.files {
+				if err := os.MkdirAll(dir, 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := detectPackageName(dir)
+			if err != nil {
+				t.Fatalf("Could not detect the package name: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("Expected package name %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestNewComponentErrors(t *testing.T) {
+	useBuiltinTemplates(t)
+	for _, tc := range []struct {
+		desc     string
+		args     []string
+		existing bool
+		wantErr  string
+	}{
+		{
+			desc:    "invalid package name",
+			args:    []string{"-package", "my-stages", "WordCounter"},
+			wantErr: "invalid package name: my-stages",
+		},
+		{
+			desc:    "package name that is a keyword",
+			args:    []string{"-package", "func", "WordCounter"},
+			wantErr: "invalid package name: func",
+		},
+		{
+			desc:     "existing file without -force",
+			args:     []string{"-package", "stages", "WordCounter"},
+			existing: true,
+			wantErr:  "use -force to overwrite it",
+		},
+		{
+			desc:     "existing file with -force",
+			args:     []string{"-package", "stages", "-force", "WordCounter"},
+			existing: true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "word_counter.go")
+			const edited = "package stages\n\n// Edited by the user\n"
+			if tc.existing {
+				if err := os.WriteFile(path, []byte(edited), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := runNewComponent(append([]string{"-dir", dir}, tc.args...))
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got: %v", err)
+				}
+				if src, _ := os.ReadFile(path); !strings.Contains(string(src), "type WordCounter struct") {
+					t.Errorf("Component file was not written:\n%s", src)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("Expected an error containing %q, got: %v", tc.wantErr, err)
+			}
+			if tc.existing {
+				if src, _ := os.ReadFile(path); string(src) != edited {
+					t.Errorf("Existing file was overwritten:\n%s", src)
+				}
+			} else if _, err := os.Stat(path); err == nil {
+				t.Errorf("Component file was written despite the error")
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// pipelinesDir is the directory of the pipeline templates among the
+// templates of the generators (see templatesFS), with one directory per
+// template, whose files generate the files of the pipeline
+const pipelinesDir = "pipelines"
+
+// pipelineData is the data the pipeline templates are executed with
+type pipelineData struct {
+	// Name is the name of the pipeline, used as the name of its network
+	Name string
+}
+
+func runNewPipeline(args []string) error {
+	templates := templatesFS()
+	names, err := pipelineTemplateNames(templates)
+	if err != nil {
+		return err
+	}
+	flags := flag.NewFlagSet("new-pipeline", flag.ExitOnError)
+	tplName := flags.String("template", "etl", "Template of the pipeline, one of: "+strings.Join(names, ", "))
+	dir := flags.String("dir", "", "Directory to write the pipeline to. Defaults to the pipeline name.")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("expected one pipeline name, e.g: flowbase new-pipeline -template etl my-pipeline")
+	}
+	name := flags.Arg(0)
+	if *dir == "" {
+		*dir = name
+	}
+
+	files, err := genPipeline(templates, *tplName, pipelineData{Name: name})
+	if err != nil {
+		return err
+	}
+	// Nothing is written if any of the files exists, so that pipelines are
+	// never half overwritten
+	for _, relPath := range sortedKeys(files) {
+		path := filepath.Join(*dir, relPath)
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("not overwriting existing file %s", path)
+		}
+	}
+	for _, relPath := range sortedKeys(files) {
+		path := filepath.Join(*dir, relPath)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, files[relPath], 0644); err != nil {
+			return err
+		}
+	}
+	steps, err := pipelineRunSteps(*dir, name)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Created %s pipeline %s in %s. Run it with: %s\n", *tplName, name, *dir, steps)
+	return nil
+}
+
+// pipelineRunSteps returns the shell commands for running the pipeline name
+// in dir, which create a Go module for it first, if dir is not in one
+func pipelineRunSteps(dir string, name string) (string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	steps := []string{"cd " + dir}
+	if !inGoModule(absDir) {
+		steps = append(steps, "go mod init "+name, "go mod tidy")
+	}
+	return strings.Join(append(steps, "go run ."), " && "), nil
+}
+
+// inGoModule returns whether the directory dir, or one of its parents, has a
+// go.mod file
+func inGoModule(dir string) bool {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+}
+
+// pipelineTemplateNames returns the names of the pipeline templates in
+// templates, sorted
+func pipelineTemplateNames(templates fs.FS) ([]string, error) {
+	entries, err := fs.ReadDir(templates, pipelinesDir)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, e := range entries {
+		if e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	return names, nil
+}
+
+// genPipeline executes the files of the pipeline template tplName in
+// templates with data, and returns their contents, keyed by their paths
+// relative to the pipeline directory
+func genPipeline(templates fs.FS, tplName string, data pipelineData) (map[string][]byte, error) {
+	tplDir := path.Join(pipelinesDir, tplName)
+	if info, err := fs.Stat(templates, tplDir); err != nil || !info.IsDir() {
+		names, _ := pipelineTemplateNames(templates)
+		return nil, fmt.Errorf("unknown pipeline template %s, expected one of: %s", tplName, strings.Join(names, ", "))
+	}
+	files := map[string][]byte{}
+	err := fs.WalkDir(templates, tplDir, func(tplPath string, e fs.DirEntry, err error) error {
+		if err != nil || e.IsDir() || !strings.HasSuffix(tplPath, ".tmpl") {
+			return err
+		}
+		src, err := executeTemplate(templates, tplPath, data)
+		if err != nil {
+			return err
+		}
+		relPath := strings.TrimSuffix(strings.TrimPrefix(tplPath, tplDir+"/"), ".tmpl")
+		files[filepath.FromSlash(relPath)] = src
+		return nil
+	})
+	return files, err
+}
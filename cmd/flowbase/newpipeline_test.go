@@ -0,0 +1,55 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestNewPipelineVets scaffolds a pipeline from each of the built-in
+// templates in a temporary module, and checks that it passes go vet
+func TestNewPipelineVets(t *testing.T) {
+	useBuiltinTemplates(t)
+	names, err := pipelineTemplateNames(templatesFS())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestModule(t, dir, "example.com/"+name, nil)
+			if err := runNewPipeline([]string{"-template", name, "-dir", dir, name + "-pipeline"}); err != nil {
+				t.Fatalf("Could not scaffold pipeline: %v", err)
+			}
+			if out, err := runGo(t, dir, "vet", "."); err != nil {
+				t.Errorf("Pipeline %s did not pass go vet: %v\n%s", name, err, out)
+			}
+		})
+	}
+}
+
+func TestPipelineRunSteps(t *testing.T) {
+	dir := t.TempDir()
+	outside := filepath.Join(dir, "outside")
+	steps, err := pipelineRunSteps(outside, "my-pipeline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !inGoModule(outside) {
+		assertSteps(t, "cd "+outside+" && go mod init my-pipeline && go mod tidy && go run .", steps)
+	}
+
+	writeTestModule(t, dir, "example.com/pipelines", nil)
+	inside := filepath.Join(dir, "inside")
+	steps, err = pipelineRunSteps(inside, "my-pipeline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSteps(t, "cd "+inside+" && go run .", steps)
+}
+
+func assertSteps(t *testing.T, want string, got string) {
+	t.Helper()
+	if got != want {
+		t.Errorf("Expected the steps %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+
+	fb "github.com/flowbase/flowbase"
+	"github.com/flowbase/flowbase/components"
+)
+
+func runPipe(args []string) error {
+	flags := flag.NewFlagSet("pipe", flag.ExitOnError)
+	framingName := flags.String("framing", string(components.FramingLines), "How packets are delimited on stdin and stdout: lines, or length-prefixed, with a 4-byte big-endian length")
+	inName := flags.String("in", "", "Exported in-port to send the packets read from stdin to (default the only exported in-port, if any)")
+	outName := flags.String("out", "", "Exported out-port whose packets are written to stdout (default the only exported out-port)")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("expected one graph file, e.g: cat data.txt | flowbase pipe graph.fbp | sort")
+	}
+	framing, err := components.ParseFraming(*framingName)
+	if err != nil {
+		return err
+	}
+	g, err := fb.ReadGraphFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *inName == "" && len(g.InPorts) > 0 {
+		if *inName, err = onlyExportedPort("in", g.InPorts); err != nil {
+			return err
+		}
+	}
+	if *outName == "" {
+		if *outName, err = onlyExportedPort("out", g.OutPorts); err != nil {
+			return err
+		}
+	}
+	if _, ok := g.OutPorts[*outName]; !ok {
+		return fmt.Errorf("no exported out-port named %s in %s", *outName, flags.Arg(0))
+	}
+	if _, ok := g.InPorts[*inName]; *inName != "" && !ok {
+		return fmt.Errorf("no exported in-port named %s in %s", *inName, flags.Arg(0))
+	}
+
+	net, err := fb.NewNetworkFromGraph(g)
+	if err != nil {
+		return err
+	}
+	if *inName != "" {
+		stdin := components.NewStdinSource(net, "stdin", framing)
+		net.ExportedInPort(*inName).From(stdin.Out())
+	}
+	stdout := components.NewStdoutSink(net, "stdout", framing)
+	stdout.In().From(net.ExportedOutPort(*outName))
+	net.Run()
+	return nil
+}
+
+// onlyExportedPort returns the name of the only one of the exported ports,
+// of the kind kind ("in" or "out")
+func onlyExportedPort(kind string, ports map[string]fb.GraphEndpoint) (string, error) {
+	names := []string{}
+	for name := range ports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) != 1 {
+		return "", fmt.Errorf("expected one exported %s-port, found %d %v, select one with -%s", kind, len(names), names, kind)
+	}
+	return names[0], nil
+}
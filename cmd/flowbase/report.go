@@ -0,0 +1,244 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+func runReport(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("missing report name, e.g: flowbase report gantt")
+	}
+	switch args[0] {
+	case "gantt":
+		return runReportGantt(args[1:])
+	case "critical-path":
+		return runReportCriticalPath(args[1:])
+	case "simulate":
+		return runReportSimulate(args[1:])
+	case "usage":
+		return runReportUsage(args[1:])
+	default:
+		return fmt.Errorf("unknown report: %s", args[0])
+	}
+}
+
+func runReportGantt(args []string) error {
+	flags := flag.NewFlagSet("report gantt", flag.ExitOnError)
+	out := flags.String("out", "gantt.html", "Path of the chart to write, as HTML, or as SVG if it ends with .svg")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("expected one directory with the outputs and audit files of a run, e.g: flowbase report gantt results")
+	}
+	dir := flags.Arg(0)
+
+	infos, err := fb.ReadAuditInfos(dir)
+	if err != nil {
+		return err
+	}
+	if len(infos) == 0 {
+		return fmt.Errorf("no executed tasks found in the audit files in %s", dir)
+	}
+	file, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(filepath.Ext(*out), ".svg") {
+		err = fb.WriteGanttSVG(file, infos)
+	} else {
+		err = fb.WriteGanttHTML(file, "Tasks of "+dir, infos)
+	}
+	if err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote a Gantt chart of %d tasks to %s\n", len(infos), *out)
+	return nil
+}
+
+func runReportCriticalPath(args []string) error {
+	flags := flag.NewFlagSet("report critical-path", flag.ExitOnError)
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("expected one directory with the outputs and audit files of a run, e.g: flowbase report critical-path results")
+	}
+	dir := flags.Arg(0)
+
+	infos, err := fb.ReadAuditInfos(dir)
+	if err != nil {
+		return err
+	}
+	steps := fb.CriticalPath(infos)
+	if len(steps) == 0 {
+		return fmt.Errorf("no executed tasks found in the audit files in %s", dir)
+	}
+	start := steps[0].Task.StartTime
+	var busy, waiting time.Duration
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "START\tWAIT\tDURATION\tPROCESS\tCOMMAND")
+	for _, step := range steps {
+		busy += step.Duration
+		waiting += step.Wait
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", step.Task.StartTime.Sub(start), step.Wait, step.Duration, step.Task.ProcessName, step.Task.RedactedCommand())
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	total := steps[len(steps)-1].Task.FinishTime.Sub(start)
+	fmt.Printf("\nCritical path of %d tasks: %s, of which %s executing and %s waiting\n", len(steps), total, busy, waiting)
+	return nil
+}
+
+func runReportSimulate(args []string) error {
+	flags := flag.NewFlagSet("report simulate", flag.ExitOnError)
+	tasks := flags.String("tasks", "0", "Comma-separated numbers of tasks which can run at the same time, to simulate each of, where 0 is unlimited")
+	cores := flags.Int("cores", 0, "Number of cores shared by the tasks, where 0 is unlimited")
+	memory := flags.Int("memory", 0, "Memory shared by the tasks, in MB, where 0 is unlimited")
+	resources := flags.String("resources", "", "Comma-separated resources needed by each task of processes, as process=cores[:memoryMB], e.g: align=4:8000 (default 1 core)")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("expected one directory with the outputs and audit files of a run, e.g: flowbase report simulate -tasks 1,2,4,8 results")
+	}
+	dir := flags.Arg(0)
+
+	conf := fb.SimulationConfig{Cores: *cores, MemoryMB: *memory, Resources: map[string]fb.Resources{}}
+	if *resources != "" {
+		for _, spec := range strings.Split(*resources, ",") {
+			proc, res, err := parseProcessResources(spec)
+			if err != nil {
+				return err
+			}
+			conf.Resources[proc] = res
+		}
+	}
+	limits := []int{}
+	for _, s := range strings.Split(*tasks, ",") {
+		limit, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || limit < 0 {
+			return fmt.Errorf("invalid number of tasks: %s", s)
+		}
+		limits = append(limits, limit)
+	}
+
+	infos, err := fb.ReadAuditInfos(dir)
+	if err != nil {
+		return err
+	}
+	if len(infos) == 0 {
+		return fmt.Errorf("no executed tasks found in the audit files in %s", dir)
+	}
+	start, finish := infos[0].StartTime, infos[0].FinishTime
+	for _, ai := range infos {
+		if ai.FinishTime.After(finish) {
+			finish = ai.FinishTime
+		}
+	}
+	fmt.Printf("Recorded run of %d tasks: %s\n\n", len(infos), finish.Sub(start))
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TASKS\tESTIMATED TIME\tMAX CONCURRENT")
+	for _, limit := range limits {
+		conf.MaxConcurrentTasks = limit
+		result, err := fb.Simulate(infos, conf)
+		if err != nil {
+			return err
+		}
+		name := strconv.Itoa(limit)
+		if limit == 0 {
+			name = "unlimited"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\n", name, result.Duration, result.MaxConcurrency)
+	}
+	return tw.Flush()
+}
+
+// parseProcessResources parses the resources needed by the tasks of a
+// process, given as process=cores[:memoryMB]
+func parseProcessResources(spec string) (string, fb.Resources, error) {
+	res := fb.Resources{}
+	proc, value, ok := strings.Cut(strings.TrimSpace(spec), "=")
+	if !ok || proc == "" {
+		return "", res, fmt.Errorf("invalid resources %q, expected process=cores[:memoryMB]", spec)
+	}
+	coresStr, memoryStr, hasMemory := strings.Cut(value, ":")
+	var err error
+	if res.Cores, err = strconv.Atoi(coresStr); err != nil {
+		return "", res, fmt.Errorf("invalid cores in resources %q: %v", spec, err)
+	}
+	if hasMemory {
+		if res.MemoryMB, err = strconv.Atoi(memoryStr); err != nil {
+			return "", res, fmt.Errorf("invalid memory in resources %q: %v", spec, err)
+		}
+	}
+	return proc, res, nil
+}
+
+func runReportUsage(args []string) error {
+	flags := flag.NewFlagSet("report usage", flag.ExitOnError)
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("expected one directory with the outputs and audit files of a run, e.g: flowbase report usage results")
+	}
+	dir := flags.Arg(0)
+
+	infos, err := fb.ReadAuditInfos(dir)
+	if err != nil {
+		return err
+	}
+	type procUsage struct {
+		tasks        int
+		maxRSSKB     int64
+		cpuTime      time.Duration
+		execTime     time.Duration
+		readBytes    int64
+		writtenBytes int64
+	}
+	usages := map[string]*procUsage{}
+	procs := []string{}
+	for _, ai := range infos {
+		if ai.ResourceUsage == nil {
+			continue
+		}
+		u, ok := usages[ai.ProcessName]
+		if !ok {
+			u = &procUsage{}
+			usages[ai.ProcessName] = u
+			procs = append(procs, ai.ProcessName)
+		}
+		u.tasks++
+		if ai.ResourceUsage.MaxRSSKB > u.maxRSSKB {
+			u.maxRSSKB = ai.ResourceUsage.MaxRSSKB
+		}
+		u.cpuTime += ai.ResourceUsage.CPUTime()
+		u.execTime += ai.FinishTime.Sub(ai.StartTime)
+		u.readBytes += ai.ResourceUsage.ReadBytes
+		u.writtenBytes += ai.ResourceUsage.WrittenBytes
+	}
+	if len(procs) == 0 {
+		return fmt.Errorf("no tasks with recorded resource usage found in the audit files in %s", dir)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROCESS\tTASKS\tMAX RSS (MB)\tCPU TIME\tAVG CORES\tREAD (MB)\tWRITTEN (MB)")
+	for _, proc := range procs {
+		u := usages[proc]
+		cores := 0.0
+		if u.execTime > 0 {
+			cores = float64(u.cpuTime) / float64(u.execTime)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%.1f\t%s\t%.2f\t%.1f\t%.1f\n", proc, u.tasks, float64(u.maxRSSKB)/1024, u.cpuTime.Round(time.Millisecond), cores,
+			float64(u.readBytes)/1e6, float64(u.writtenBytes)/1e6)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// builtinTemplates holds the built-in templates of the generators, which are
+// Go text/template files named after the files they generate plus ".tmpl":
+//
+//	component.go.tmpl         Component stubs, generated by gen go
+//	pipelines/<name>/...      The files of the pipelines of new-pipeline
+//	doc/...                   The component documentation of doc
+//
+//go:embed templates
+var builtinTemplates embed.FS
+
+// userTemplatesDir returns the directory with the user's own templates,
+// which is $FLOWBASE_TEMPLATES, or else ~/.flowbase/templates. Templates in
+// it replace the built-in templates with the same paths, so that
+// organizations can standardize the generated code, such as with license
+// headers and logging conventions, and new pipeline templates can be added
+// as new directories under pipelines/.
+func userTemplatesDir() string {
+	if dir := os.Getenv("FLOWBASE_TEMPLATES"); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".flowbase", "templates")
+}
+
+// templatesFS returns the templates of the generators, where the user's
+// templates take precedence over the built-in ones
+func templatesFS() fs.FS {
+	builtin, err := fs.Sub(builtinTemplates, "templates")
+	if err != nil {
+		panic(err)
+	}
+	layers := []fs.FS{}
+	if dir := userTemplatesDir(); dir != "" {
+		layers = append(layers, os.DirFS(dir))
+	}
+	return overlayFS(append(layers, builtin))
+}
+
+// templateFuncs are the functions available in templates, in addition to
+// the built-in ones of text/template
+var templateFuncs = template.FuncMap{
+	"snakeCase": snakeCase,
+	"lower":     strings.ToLower,
+	"upper":     strings.ToUpper,
+	"mdcell":    mdCell,
+	"portattrs": portSpecAttrs,
+}
+
+// mdCell escapes s for use in a cell of a Markdown table
+func mdCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "<", "&lt;", ">", "&gt;", "\r\n", " ", "\n", " ").Replace(s)
+}
+
+// executeTemplate executes the template at path in fsys with data, and
+// returns the result, formatted if it is a Go file
+func executeTemplate(fsys fs.FS, path string, data any) ([]byte, error) {
+	src, err := fs.ReadFile(fsys, path)
+	if err != nil {
+		return nil, err
+	}
+	tpl, err := template.New(path).Funcs(templateFuncs).Parse(string(src))
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	if err := tpl.Execute(buf, data); err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, ".go.tmpl") {
+		return buf.Bytes(), nil
+	}
+	out, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("could not format the output of template %s: %v", path, err)
+	}
+	return out, nil
+}
+
+// overlayFS is a file system made of layers, where files in earlier layers
+// hide the files with the same paths in later layers, and directories list
+// the files of all layers
+type overlayFS []fs.FS
+
+// Open opens the file name in the first layer which has it
+func (o overlayFS) Open(name string) (fs.File, error) {
+	for _, layer := range o {
+		f, err := layer.Open(name)
+		if err == nil {
+			return f, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// ReadDir returns the entries of the directory name in all layers, sorted by
+// name
+func (o overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	found := false
+	entries := map[string]fs.DirEntry{}
+	for _, layer := range o {
+		layerEntries, err := fs.ReadDir(layer, name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		found = true
+		for _, e := range layerEntries {
+			if _, ok := entries[e.Name()]; !ok {
+				entries[e.Name()] = e
+			}
+		}
+	}
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	list := make([]fs.DirEntry, 0, len(entries))
+	for _, e := range entries {
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list, nil
+}
+
+// runTemplates copies the built-in templates to a directory, by default the
+// user's templates directory, as a starting point for customizing them.
+// Existing files are not overwritten.
+func runTemplates(args []string) error {
+	flags := flag.NewFlagSet("templates", flag.ExitOnError)
+	dir := flags.String("dir", userTemplatesDir(), "Directory to copy the built-in templates to")
+	flags.Parse(args)
+	if *dir == "" {
+		return fmt.Errorf("could not find the home directory, use -dir to set the directory to copy the templates to")
+	}
+	return fs.WalkDir(builtinTemplates, "templates", func(tplPath string, e fs.DirEntry, err error) error {
+		if err != nil || e.IsDir() {
+			return err
+		}
+		path := filepath.Join(*dir, filepath.FromSlash(strings.TrimPrefix(tplPath, "templates/")))
+		if _, err := os.Stat(path); err == nil {
+			fmt.Fprintf(os.Stderr, "Not overwriting existing file %s\n", path)
+			return nil
+		}
+		src, err := builtinTemplates.ReadFile(tplPath)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(path, src, 0644)
+	})
+}
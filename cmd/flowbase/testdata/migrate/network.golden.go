@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/flowbase/flowbase/v2"
+)
+
+// stopper stops at once
+type stopper struct {
+	flowbase.BaseProcess
+}
+
+func (p *stopper) Run(ctx context.Context) error {
+	log.Println("Stopping")
+	return nil
+}
+
+// clock is not a process, so its Run method is kept
+type clock struct{}
+
+func (c clock) Run() {}
+
+func main() {
+	clock{}.Run()
+	if len(os.Args) > 1 {
+		net := flowbase.NewNetwork(os.Args[1])
+		net.AddProc(&stopper{BaseProcess: flowbase.NewBaseProcess(net, "stopper")})
+		if err := net.Run(context.Background()); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/flowbase/flowbase"
+)
+
+// stopper stops at once
+type stopper struct {
+	flowbase.BaseProcess
+}
+
+func (p *stopper) Run() {
+	log.Println("Stopping")
+}
+
+// clock is not a process, so its Run method is kept
+type clock struct{}
+
+func (c clock) Run() {}
+
+func main() {
+	clock{}.Run()
+	if len(os.Args) > 1 {
+		net := flowbase.NewNetwork(os.Args[1])
+		net.AddProc(&stopper{BaseProcess: flowbase.NewBaseProcess(net, "stopper")})
+		net.Run()
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	fb "github.com/flowbase/flowbase/v2"
+)
+
+// upper upper-cases the strings it receives
+type upper struct {
+	fb.BaseProcess
+}
+
+func newUpper(net *fb.Network, name string) *upper {
+	p := &upper{BaseProcess: fb.NewBaseProcess(net, name)}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	return p
+}
+
+func (p *upper) Run(ctx context.Context) error {
+	defer p.CloseOutPorts()
+	for ip, ok := p.InPort("in").RecvOK(); ok; ip, ok = p.InPort("in").RecvOK() {
+		s, ok := ip.Data().(string)
+		if !ok {
+			return fmt.Errorf("expected a string, got %T", ip.Data())
+		}
+		p.OutPort("out").SendPacket(fb.NewPacket(s))
+	}
+	return nil
+}
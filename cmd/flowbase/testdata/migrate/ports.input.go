@@ -0,0 +1,28 @@
+package main
+
+import (
+	fb "github.com/flowbase/flowbase"
+)
+
+// upper upper-cases the strings it receives
+type upper struct {
+	fb.BaseProcess
+}
+
+func newUpper(net *fb.Network, name string) *upper {
+	p := &upper{BaseProcess: fb.NewBaseProcess(net, name)}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	return p
+}
+
+func (p *upper) Run() {
+	defer p.CloseOutPorts()
+	for ip, ok := p.InPort("in").RecvOK(); ok; ip, ok = p.InPort("in").RecvOK() {
+		s, ok := ip.Data().(string)
+		if !ok {
+			p.Failf("expected a string, got %T", ip.Data())
+		}
+		p.OutPort("out").SendPacket(fb.NewPacket(s))
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	fb "github.com/flowbase/flowbase/v2"
+)
+
+// counter counts to n, and fails for negative n
+type counter struct {
+	fb.BaseProcess
+	n int
+}
+
+func (p *counter) Run(ctx context.Context) error {
+	if p.n < 0 {
+		// Negative counts are not allowed
+		return fmt.Errorf("%v", "negative count")
+	}
+	for i := 0; i < p.n; i++ {
+		switch {
+		case i > 100:
+			return fmt.Errorf("count %d is too large", p.n)
+		case i > 10:
+			return nil
+		}
+	}
+	return nil
+}
+
+func main() {
+	net := fb.NewNetwork("counter")
+	net.AddProc(&counter{BaseProcess: fb.NewBaseProcess(net, "counter"), n: 3})
+	// Run the network
+	if err := net.Run(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	fb "github.com/flowbase/flowbase"
+)
+
+// counter counts to n, and fails for negative n
+type counter struct {
+	fb.BaseProcess
+	n int
+}
+
+func (p *counter) Run() {
+	if p.n < 0 {
+		// Negative counts are not allowed
+		p.Fail("negative count")
+	}
+	for i := 0; i < p.n; i++ {
+		switch {
+		case i > 100:
+			p.Failf("count %d is too large", p.n)
+		case i > 10:
+			return
+		}
+	}
+}
+
+func main() {
+	net := fb.NewNetwork("counter")
+	net.AddProc(&counter{BaseProcess: fb.NewBaseProcess(net, "counter"), n: 3})
+	// Run the network
+	net.Run()
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	fb "github.com/flowbase/flowbase/v2"
+)
+
+// failer always fails
+type failer struct {
+	fb.BaseProcess
+	err error
+}
+
+func (p *failer) Run(ctx context.Context) error {
+	return fmt.Errorf("%v", p.err)
+}
+
+func main() {}
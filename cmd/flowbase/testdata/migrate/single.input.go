@@ -0,0 +1,15 @@
+package main
+
+import fb "github.com/flowbase/flowbase"
+
+// failer always fails
+type failer struct {
+	fb.BaseProcess
+	err error
+}
+
+func (p *failer) Run() {
+	p.Fail(p.err)
+}
+
+func main() {}
@@ -0,0 +1,195 @@
+package flowbase
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"io"
+)
+
+// Codec is the interface for types that can serialize streams of Packets,
+// including their tags and audit info, to and from bytes. It is the foundation
+// for things like disk-backed queues, network transports and checkpointing.
+type Codec interface {
+	Name() string
+	NewEncoder(w io.Writer) PacketEncoder
+	NewDecoder(r io.Reader) PacketDecoder
+}
+
+// PacketEncoder writes a stream of packets to an underlying writer
+type PacketEncoder interface {
+	Encode(ip *Packet) error
+}
+
+// PacketDecoder reads a stream of packets from an underlying reader. Decode
+// returns io.EOF when the stream is exhausted.
+type PacketDecoder interface {
+	Decode() (*Packet, error)
+}
+
+// packetEnvelope is the exported mirror of a Packet, used by the codecs, since
+// the fields of Packet itself are not exported
+type packetEnvelope struct {
+	ID        string
+	Data      any
+	Tags      map[string]string
+	AuditInfo *AuditInfo
+	// Size is the size set with SetSize, if any
+	Size int64 `json:",omitempty"`
+}
+
+func newPacketEnvelope(ip *Packet) *packetEnvelope {
+	return &packetEnvelope{
+		ID:        ip.id,
+		Data:      ip.data,
+		Tags:      ip.tags,
+		AuditInfo: ip.auditInfo,
+		Size:      ip.size,
+	}
+}
+
+func (e *packetEnvelope) packet() *Packet {
+	tags := e.Tags
+	if tags == nil {
+		tags = make(map[string]string)
+	}
+	return &Packet{
+		data:      e.Data,
+		id:        e.ID,
+		tags:      tags,
+		auditInfo: e.AuditInfo,
+		size:      e.Size,
+	}
+}
+
+// ------------------------------------------------------------------------
+// GobCodec
+// ------------------------------------------------------------------------
+
+// GobCodec serializes Packets with encoding/gob. Concrete types sent as
+// packet data need to be registered with gob.Register before use.
+type GobCodec struct{}
+
+// NewGobCodec returns a new GobCodec
+func NewGobCodec() *GobCodec {
+	return &GobCodec{}
+}
+
+// Name returns the name of the codec
+func (c *GobCodec) Name() string {
+	return "gob"
+}
+
+// NewEncoder returns a new gob packet encoder writing to w
+func (c *GobCodec) NewEncoder(w io.Writer) PacketEncoder {
+	return &gobPacketEncoder{enc: gob.NewEncoder(w)}
+}
+
+// NewDecoder returns a new gob packet decoder reading from r
+func (c *GobCodec) NewDecoder(r io.Reader) PacketDecoder {
+	return &gobPacketDecoder{dec: gob.NewDecoder(r)}
+}
+
+type gobPacketEncoder struct {
+	enc *gob.Encoder
+}
+
+func (e *gobPacketEncoder) Encode(ip *Packet) error {
+	if err := e.enc.Encode(newPacketEnvelope(ip)); err != nil {
+		return errWrapf(err, "Could not gob-encode packet (%s)", ip.ID())
+	}
+	return nil
+}
+
+type gobPacketDecoder struct {
+	dec *gob.Decoder
+}
+
+func (d *gobPacketDecoder) Decode() (*Packet, error) {
+	env := &packetEnvelope{}
+	if err := d.dec.Decode(env); err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, errWrap(err, "Could not gob-decode packet")
+	}
+	return env.packet(), nil
+}
+
+// ------------------------------------------------------------------------
+// JSONCodec
+// ------------------------------------------------------------------------
+
+// JSONCodec serializes Packets as JSON, one object per line. Note that packet
+// data is decoded into the generic types of encoding/json (such as
+// map[string]any and float64), as the original Go type is not retained.
+type JSONCodec struct{}
+
+// NewJSONCodec returns a new JSONCodec
+func NewJSONCodec() *JSONCodec {
+	return &JSONCodec{}
+}
+
+// Name returns the name of the codec
+func (c *JSONCodec) Name() string {
+	return "json"
+}
+
+// NewEncoder returns a new JSON packet encoder writing to w
+func (c *JSONCodec) NewEncoder(w io.Writer) PacketEncoder {
+	return &jsonPacketEncoder{enc: json.NewEncoder(w)}
+}
+
+// NewDecoder returns a new JSON packet decoder reading from r
+func (c *JSONCodec) NewDecoder(r io.Reader) PacketDecoder {
+	return &jsonPacketDecoder{dec: json.NewDecoder(r)}
+}
+
+type jsonPacketEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *jsonPacketEncoder) Encode(ip *Packet) error {
+	if err := e.enc.Encode(newPacketEnvelope(ip)); err != nil {
+		return errWrapf(err, "Could not JSON-encode packet (%s)", ip.ID())
+	}
+	return nil
+}
+
+type jsonPacketDecoder struct {
+	dec *json.Decoder
+}
+
+func (d *jsonPacketDecoder) Decode() (*Packet, error) {
+	env := &packetEnvelope{}
+	if err := d.dec.Decode(env); err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, errWrap(err, "Could not JSON-decode packet")
+	}
+	return env.packet(), nil
+}
+
+// ------------------------------------------------------------------------
+// Codec registry
+// ------------------------------------------------------------------------
+
+var codecs = map[string]Codec{
+	"gob":  NewGobCodec(),
+	"json": NewJSONCodec(),
+}
+
+// RegisterCodec makes codec available by its name via GetCodec, so that
+// additional formats (such as protobuf or msgpack) can be plugged in
+func RegisterCodec(codec Codec) {
+	codecs[codec.Name()] = codec
+}
+
+// GetCodec returns the codec registered with the name name
+func GetCodec(name string) Codec {
+	codec, ok := codecs[name]
+	if !ok {
+		Failf("No codec registered with name (%s)", name)
+	}
+	return codec
+}
@@ -0,0 +1,197 @@
+package flowbase
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/flowbase/flowbase/arrow"
+	"github.com/flowbase/flowbase/pbwire"
+)
+
+func TestCodecsRoundTrip(t *testing.T) {
+	initTestLogs()
+
+	for _, codec := range []Codec{NewGobCodec(), NewJSONCodec()} {
+		ai := NewAuditInfo()
+		ai.ProcessName = "proc"
+
+		ip1 := NewPacket("abc")
+		ip1.AddTag("sample", "s1")
+		ip1.SetAuditInfo(ai)
+		ip2 := NewPacket("cde")
+
+		buf := &bytes.Buffer{}
+		enc := codec.NewEncoder(buf)
+		for _, ip := range []*Packet{ip1, ip2} {
+			if err := enc.Encode(ip); err != nil {
+				t.Fatalf("[%s] Could not encode: %v", codec.Name(), err)
+			}
+		}
+
+		dec := codec.NewDecoder(buf)
+		got1, err := dec.Decode()
+		if err != nil {
+			t.Fatalf("[%s] Could not decode: %v", codec.Name(), err)
+		}
+		assertEqualValues(t, ip1.ID(), got1.ID())
+		assertEqualValues(t, "abc", got1.Data())
+		assertEqualValues(t, "s1", got1.Tag("sample"))
+		assertEqualValues(t, "proc", got1.AuditInfo().ProcessName)
+
+		got2, err := dec.Decode()
+		if err != nil {
+			t.Fatalf("[%s] Could not decode: %v", codec.Name(), err)
+		}
+		assertEqualValues(t, "cde", got2.Data())
+
+		if _, err := dec.Decode(); err != io.EOF {
+			t.Errorf("[%s] Expected io.EOF at end of stream, got: %v", codec.Name(), err)
+		}
+	}
+}
+
+func TestArrowCodecRoundTrip(t *testing.T) {
+	initTestLogs()
+
+	schema := arrow.NewSchema(
+		arrow.Field{Name: "sample", Type: arrow.String},
+		arrow.Field{Name: "reads", Type: arrow.Int64, Nullable: true},
+	)
+	batch1, err := arrow.NewRecordBatchFromRows(schema, []map[string]any{
+		{"sample": "s1", "reads": 10},
+		{"sample": "s2"},
+	})
+	if err != nil {
+		t.Fatalf("Could not create record batch: %v", err)
+	}
+	otherSchema := arrow.NewSchema(arrow.Field{Name: "ok", Type: arrow.Bool})
+	batch2, err := arrow.NewRecordBatchFromRows(otherSchema, []map[string]any{{"ok": true}})
+	if err != nil {
+		t.Fatalf("Could not create record batch: %v", err)
+	}
+
+	ai := NewAuditInfo()
+	ai.ProcessName = "proc"
+	ip1 := NewPacket(batch1)
+	ip1.AddTag("run", "r1")
+	ip1.SetAuditInfo(ai)
+	ip2 := NewPacket(batch2)
+
+	codec := GetCodec("arrow")
+	buf := &bytes.Buffer{}
+	enc := codec.NewEncoder(buf)
+	for _, ip := range []*Packet{ip1, ip2} {
+		if err := enc.Encode(ip); err != nil {
+			t.Fatalf("Could not encode: %v", err)
+		}
+	}
+	if err := enc.Encode(NewPacket("abc")); err == nil {
+		t.Errorf("Expected an error when encoding a packet without a record batch")
+	}
+
+	dec := codec.NewDecoder(buf)
+	got1, err := dec.Decode()
+	if err != nil {
+		t.Fatalf("Could not decode: %v", err)
+	}
+	assertEqualValues(t, ip1.ID(), got1.ID())
+	assertEqualValues(t, "r1", got1.Tag("run"))
+	assertEqualValues(t, "proc", got1.AuditInfo().ProcessName)
+	gotBatch := got1.Data().(*arrow.RecordBatch)
+	assertEqualValues(t, true, gotBatch.Schema().Equal(schema))
+	assertEqualValues(t, map[string]any{"sample": "s1", "reads": int64(10)}, gotBatch.Row(0))
+	assertEqualValues(t, map[string]any{"sample": "s2", "reads": nil}, gotBatch.Row(1))
+
+	got2, err := dec.Decode()
+	if err != nil {
+		t.Fatalf("Could not decode: %v", err)
+	}
+	assertEqualValues(t, map[string]any{"ok": true}, got2.Data().(*arrow.RecordBatch).Row(0))
+
+	if _, err := dec.Decode(); err != io.EOF {
+		t.Errorf("Expected io.EOF at end of stream, got: %v", err)
+	}
+}
+
+// protoSample is a ProtoMessage, written by hand as it would be generated by
+// `flowbase gen proto`
+type protoSample struct {
+	Name  string
+	Count int
+}
+
+func (m *protoSample) ProtoTypeName() string { return "flowbase.protoSample" }
+
+func (m *protoSample) MarshalProto() ([]byte, error) {
+	buf := &pbwire.Buffer{}
+	buf.String(1, m.Name)
+	buf.Int64(2, int64(m.Count))
+	return buf.Bytes(), nil
+}
+
+func (m *protoSample) UnmarshalProto(b []byte) error {
+	r := pbwire.NewReader(b)
+	for !r.Done() {
+		field, wireType, err := r.Next()
+		if err != nil {
+			return err
+		}
+		switch field {
+		case 1:
+			m.Name, err = r.String()
+		case 2:
+			var v int64
+			v, err = r.Int64()
+			m.Count = int(v)
+		default:
+			err = r.Skip(wireType)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestProtoCodecRoundTrip(t *testing.T) {
+	initTestLogs()
+	RegisterProtoType("flowbase.protoSample", func() ProtoMessage { return &protoSample{} })
+	codec := NewProtoCodec()
+
+	ai := NewAuditInfo()
+	ai.ProcessName = "proc"
+	ip1 := NewPacket(&protoSample{Name: "a", Count: -1})
+	ip1.AddTag("sample", "s1")
+	ip1.SetAuditInfo(ai)
+	ip2 := NewPacket(&protoSample{Name: "b", Count: 2})
+
+	buf := &bytes.Buffer{}
+	enc := codec.NewEncoder(buf)
+	for _, ip := range []*Packet{ip1, ip2} {
+		if err := enc.Encode(ip); err != nil {
+			t.Fatalf("Could not encode: %v", err)
+		}
+	}
+	if err := enc.Encode(NewPacket("not a proto message")); err == nil {
+		t.Errorf("Expected an error encoding data which is not a ProtoMessage")
+	}
+
+	dec := codec.NewDecoder(buf)
+	got1, err := dec.Decode()
+	if err != nil {
+		t.Fatalf("Could not decode: %v", err)
+	}
+	assertEqualValues(t, ip1.ID(), got1.ID())
+	assertEqualValues(t, &protoSample{Name: "a", Count: -1}, got1.Data())
+	assertEqualValues(t, "s1", got1.Tag("sample"))
+	assertEqualValues(t, "proc", got1.AuditInfo().ProcessName)
+	got2, err := dec.Decode()
+	if err != nil {
+		t.Fatalf("Could not decode: %v", err)
+	}
+	assertEqualValues(t, &protoSample{Name: "b", Count: 2}, got2.Data())
+	if _, err := dec.Decode(); err != io.EOF {
+		t.Errorf("Expected io.EOF after the last packet, got: %v", err)
+	}
+}
@@ -6,9 +6,6 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
-	re "regexp"
-	"strings"
 	"time"
 
 	"errors"
@@ -55,15 +52,6 @@ func Failf(msg string, vs ...interface{}) {
 	Fail(fmt.Sprintf(msg+"\n", vs...))
 }
 
-// Return the regular expression used to parse the place-holder syntax for in-, out- and
-// parameter ports, that can be used to instantiate a Process.
-func getShellCommandPlaceHolderRegex() *re.Regexp {
-	regex := "{(o|os|i|is|p|t):([^{}]+)}"
-	r, err := re.Compile(regex)
-	CheckWithMsg(err, "Could not compile regex: "+regex)
-	return r
-}
-
 var letters = []byte("abcdefghijklmnopqrstuvwxyz0123456789")
 
 func randSeqLC(n int) string {
@@ -96,69 +84,6 @@ func splitAllPaths(path string) []string {
 	return parts
 }
 
-// applyPathModifiers applies modifiers such as basename and %.ext for trimming
-// file name extensions, which are added at the end of port-placeholders, separated
-// by |-characters, such as: {i:infile|%.txt}, to get the path of "infile", but with the
-// .txt extension trimmed.
-// The full number of replacements are as follows:
-// If the |-separated part looks like a search/replace
-// pattern on the form of: s/SEARCHSTRING/REPLACESTRING/
-// then execute the replacement.
-// If the |-separated part starts iwth a %-character, then
-// trim everything following that character, from the end
-// of the path.
-// If the |-separated part is "basename", then remove all leading
-// folders up to the actual file name.
-func applyPathModifiers(path string, modifiers []string) string {
-	replacement := path
-
-	substPtn := regexp.MustCompile("s\\/([^\\/]+)\\/([^\\/]*)\\/")
-	trimEndPtn := regexp.MustCompile("%(.*)")
-	basenamePtn := regexp.MustCompile(`.*\/`)
-	dirnamePtn := regexp.MustCompile(`\/[^\/]*$`)
-
-	for _, modifier := range modifiers {
-		// If the |-separated part looks like a search/replace
-		// pattern on the form of: s/SEARCHSTRING/REPLACESTRING/
-		// then execute the replacement.
-		if substPtn.MatchString(modifier) {
-			mbits := substPtn.FindStringSubmatch(modifier)
-			search := mbits[1]
-			replace := mbits[2]
-			replacement = strings.Replace(replacement, search, replace, 1)
-		}
-		// If the |-separated part starts iwth a %-character, then
-		// trim everything following that character, from the end
-		// of the path.
-		if trimEndPtn.MatchString(modifier) {
-			mbits := trimEndPtn.FindStringSubmatch(modifier)
-			end := mbits[1]
-			startPos := len(replacement) - len(end)
-			if startPos > 0 {
-				if end == replacement[len(replacement)-len(end):] {
-					replacement = replacement[:len(replacement)-len(end)]
-				} else {
-					Warning.Printf("Trying to remove piece (%s) that was not found in original path (%s)\n", end, replacement)
-				}
-			} else {
-				Warning.Printf("Trying to remove piece (%s) that is larger than original path (%s)\n", end, replacement)
-			}
-		}
-
-		switch modifier {
-		// If the |-separated part is "basename", then remove all leading
-		// folders up to the actual file name.
-		case "basename":
-			replacement = basenamePtn.ReplaceAllString(replacement, "")
-		// If the |-separated part is "dirname", then extract only
-		// the directory of the path.
-		case "dirname":
-			replacement = dirnamePtn.ReplaceAllString(replacement, "")
-		}
-	}
-	return replacement
-}
-
 func createDirs(path string) {
 	dir := filepath.Dir(path)
 	err := os.MkdirAll(dir, 0775)
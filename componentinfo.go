@@ -0,0 +1,216 @@
+package flowbase
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ComponentInfo describes a component, for discoverability, such as for
+// listing the available components, and for compatibility checks when
+// processes are created from graph files
+type ComponentInfo struct {
+	Name        string     `json:"name"`
+	Version     string     `json:"version,omitempty"`
+	Description string     `json:"description,omitempty"`
+	InPorts     []PortSpec `json:"inports,omitempty"`
+	OutPorts    []PortSpec `json:"outports,omitempty"`
+	// Params are the metadata fields the component is configured with, in
+	// graph files
+	Params []ParamSpec `json:"params,omitempty"`
+	// Resources are the compute resources typically needed by the component
+	Resources Resources `json:"resources"`
+}
+
+// PortSpec describes a port of a component, or of a process (see
+// InPort.PortInfo and OutPort.PortInfo)
+type PortSpec struct {
+	Name string `json:"name"`
+	// Direction is PortIn or PortOut
+	Direction string `json:"direction,omitempty"`
+	// Type is the kind of data sent on the port, such as "file" or "string"
+	Type        string `json:"type,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Multiplicity is PortSingle for ports to connect to one other port, or
+	// PortArray for ports to connect to any number of ports
+	Multiplicity string `json:"multiplicity,omitempty"`
+	// Required tells whether the port must be connected
+	Required bool `json:"required,omitempty"`
+}
+
+// ParamSpec describes a metadata field of a component
+type ParamSpec struct {
+	Name string `json:"name"`
+	// Type is the kind of value of the field, such as "duration" or "int"
+	Type        string `json:"type,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Required tells whether the field must be set
+	Required bool `json:"required,omitempty"`
+}
+
+// ComponentInfoer can be implemented by processes to describe their
+// component, such as when the ports depend on how the process is configured
+type ComponentInfoer interface {
+	ComponentInfo() ComponentInfo
+}
+
+var componentInfos = map[string]ComponentInfo{
+	"ExecProc": {
+		Name:        "ExecProc",
+		Version:     Version,
+		Description: "Executes a shell command for each set of input packets, with ports given by the placeholders of its command metadata",
+		Params: []ParamSpec{
+			{Name: "command", Type: "string", Description: "The command pattern, with placeholders such as {i:in} and {o:out}", Required: true},
+			{Name: "version", Type: "string", Description: "The version of the logic of the process, as major.minor.patch"},
+			{Name: "param.<name>", Type: "string", Description: "The value of the parameter name, for {p:name} placeholders"},
+		},
+	},
+	"RScriptProc": {
+		Name:        "RScriptProc",
+		Version:     Version,
+		Description: "Runs an R script for each set of input packets, with ports and parameters given by the placeholders of its script metadata, and sends the files it writes",
+		Params: []ParamSpec{
+			{Name: "script", Type: "string", Description: "The script path, followed by placeholders such as {i:in}, {o:out} and {p:alpha}", Required: true},
+			{Name: "version", Type: "string", Description: "The version of the logic of the process, as major.minor.patch"},
+			{Name: "param.<name>", Type: "string", Description: "The value of the parameter name, available to the script as params$name"},
+		},
+	},
+}
+
+// RegisterComponentInfo registers the descriptor info for the component with
+// the name info.Name
+func RegisterComponentInfo(info ComponentInfo) {
+	componentInfos[info.Name] = info
+}
+
+// LookupComponentInfo returns the descriptor registered for the component
+// with name name, and whether there is one
+func LookupComponentInfo(name string) (ComponentInfo, bool) {
+	info, ok := componentInfos[name]
+	return info, ok
+}
+
+// RegisteredComponentInfos returns the descriptors of all registered
+// components, sorted by name. Components registered without a descriptor get
+// one with just their name.
+func RegisteredComponentInfos() []ComponentInfo {
+	infos := []ComponentInfo{}
+	for _, name := range RegisteredComponents() {
+		info, ok := componentInfos[name]
+		if !ok {
+			info = ComponentInfo{Name: name}
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// InfoOf returns the descriptor of the component of the process node, as
+// returned by its ComponentInfo method, or registered for its component
+// name, with the ports filled in from the process if none are described
+func InfoOf(node Node) ComponentInfo {
+	if infoer, ok := node.(ComponentInfoer); ok {
+		return infoer.ComponentInfo()
+	}
+	name := componentName(node)
+	info, ok := componentInfos[name]
+	if !ok {
+		info = ComponentInfo{Name: name}
+	}
+	if len(info.InPorts) == 0 && len(info.OutPorts) == 0 {
+		for _, name := range sortedKeys(node.InPorts()) {
+			info.InPorts = append(info.InPorts, node.InPorts()[name].PortInfo())
+		}
+		for _, name := range sortedKeys(node.OutPorts()) {
+			info.OutPorts = append(info.OutPorts, node.OutPorts()[name].PortInfo())
+		}
+	}
+	return info
+}
+
+// ComponentInfo describes the process, with the ports given by its command
+// pattern
+func (p *ExecProc) ComponentInfo() ComponentInfo {
+	info := componentInfos["ExecProc"]
+	info.InPorts, info.OutPorts = nil, nil
+	for _, name := range sortedKeys(p.InPorts()) {
+		info.InPorts = append(info.InPorts, PortSpec{Name: name, Type: "file"})
+	}
+	for _, name := range sortedKeys(p.OutPorts()) {
+		spec := PortSpec{Name: name, Type: "file"}
+		switch name {
+		case "stdout":
+			spec = PortSpec{Name: name, Type: "string", Description: "Lines written to stdout"}
+		case "errors":
+			spec = PortSpec{Name: name, Type: "error", Description: "Errors of failed commands, when FailOnError is false"}
+		}
+		info.OutPorts = append(info.OutPorts, spec)
+	}
+	info.Resources = p.Resources
+	return info
+}
+
+// ComponentInfo describes the process, with the ports given by its script
+// pattern
+func (p *RScriptProc) ComponentInfo() ComponentInfo {
+	info := p.ExecProc.ComponentInfo()
+	registered := componentInfos["RScriptProc"]
+	info.Name, info.Description, info.Params = registered.Name, registered.Description, registered.Params
+	for i, spec := range info.OutPorts {
+		switch spec.Name {
+		case rScriptFilesPort:
+			info.OutPorts[i].Description = "The files written by the script to its working directory, one per packet"
+		case "errors":
+			info.OutPorts[i].Description = "R errors with tracebacks, of failed scripts, when FailOnError is false"
+		}
+	}
+	return info
+}
+
+// ------------------------------------------------------------------------
+// Version compatibility
+// ------------------------------------------------------------------------
+
+// VersionCompatible returns whether the component version available can be
+// used where version required was used before, following semantic
+// versioning: the major versions need to be equal, and available can not be
+// older than required. For major version 0, the minor versions need to be
+// equal too. Versions which can not be parsed are only compatible if equal.
+func VersionCompatible(required string, available string) bool {
+	req, err1 := parseVersion(required)
+	avail, err2 := parseVersion(available)
+	if err1 != nil || err2 != nil {
+		return required == available
+	}
+	if req[0] != avail[0] || (req[0] == 0 && req[1] != avail[1]) {
+		return false
+	}
+	for i := range req {
+		if avail[i] != req[i] {
+			return avail[i] > req[i]
+		}
+	}
+	return true
+}
+
+// parseVersion parses a version such as "1.2.3" or "v1.2", ignoring any
+// pre-release or build suffix, such as in "1.2.3-rc1"
+func parseVersion(version string) ([3]int, error) {
+	v := [3]int{}
+	version = strings.TrimPrefix(version, "v")
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+	parts := strings.Split(version, ".")
+	if len(parts) > 3 {
+		return v, fmt.Errorf("invalid version: %s", version)
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid version: %s", version)
+		}
+		v[i] = n
+	}
+	return v, nil
+}
@@ -0,0 +1,46 @@
+package flowbase
+
+import (
+	"testing"
+)
+
+func TestVersionCompatible(t *testing.T) {
+	for _, tc := range []struct {
+		required  string
+		available string
+		expected  bool
+	}{
+		{"1.2.0", "1.2.0", true},
+		{"1.2.0", "1.3.1", true},
+		{"1.3.0", "1.2.9", false},
+		{"1.2.0", "2.0.0", false},
+		{"0.2.0", "0.2.5", true},
+		{"0.2.0", "0.3.0", false},
+		{"v1.2", "1.2.0-rc1", true},
+		{"custom", "custom", true},
+		{"custom", "1.0.0", false},
+	} {
+		assertEqualValues(t, tc.expected, VersionCompatible(tc.required, tc.available))
+	}
+}
+
+func TestGraphComponentVersion(t *testing.T) {
+	initTestLogs()
+
+	g, err := ParseFBP("cat(ExecProc@1.0.0:command=cat {i:in} > {o:out})")
+	if err != nil {
+		t.Fatalf("Could not parse graph: %v", err)
+	}
+	assertEqualValues(t, "1.0.0", g.Processes["cat"].Version)
+	if _, err := NewNetworkFromGraph(g); err == nil {
+		t.Errorf("Expected an error for incompatible component version")
+	}
+
+	net := NewNetwork("TestGraphComponentVersion")
+	cat := NewExecProc(net, "cat", "cat {i:in} > {o:out}")
+	info := InfoOf(cat)
+	assertEqualValues(t, "ExecProc", info.Name)
+	assertEqualValues(t, Version, info.Version)
+	assertEqualValues(t, []PortSpec{{Name: "in", Type: "file"}}, info.InPorts)
+	assertEqualValues(t, 3, len(info.OutPorts))
+}
@@ -0,0 +1,197 @@
+package components
+
+import (
+	"sync"
+	"time"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// CircuitState is the state of a CircuitBreaker
+type CircuitState string
+
+const (
+	// CircuitClosed is the normal state, where packets are sent to the
+	// wrapped process
+	CircuitClosed CircuitState = "closed"
+	// CircuitOpen is the state after too many consecutive failures, where
+	// packets are sent to the fallback port
+	CircuitOpen CircuitState = "open"
+	// CircuitHalfOpen is the state after the cool-down, where one probe
+	// packet is sent to the wrapped process, to decide whether to close or
+	// reopen the circuit
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+// CircuitBreaker protects a flaky process, such as an ExecProc calling an
+// unreliable service, by wrapping it (see Wrap). Packets received on the
+// in-port are sent on to the wrapped process, whose results are passed on on
+// the out-port, and whose failures, from its errors port, on the errors port.
+// After threshold consecutive failures, the circuit opens, and packets are
+// sent on the fallback port instead, until the cool-down has passed. Then,
+// one probe packet is sent to the wrapped process: if it succeeds, the
+// circuit closes again, and otherwise it reopens.
+type CircuitBreaker struct {
+	fb.BaseProcess
+	threshold int
+	coolDown  time.Duration
+
+	mx       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+}
+
+// NewCircuitBreaker returns a new CircuitBreaker process, opening after
+// threshold consecutive failures, for the duration coolDown
+func NewCircuitBreaker(net *fb.Network, name string, threshold int, coolDown time.Duration) *CircuitBreaker {
+	if threshold <= 0 {
+		fb.Failf("Threshold of circuit breaker %s must be positive, got %d", name, threshold)
+	}
+	p := &CircuitBreaker{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		threshold:   threshold,
+		coolDown:    coolDown,
+		state:       CircuitClosed,
+	}
+	p.InitInPort(p, "in")
+	p.InitInPort(p, "results")
+	p.InitInPort(p, "failures")
+	// The results and failures come back from the wrapped process, but are
+	// received concurrently with sending to it, so that the cycle can not
+	// deadlock
+	p.Results().SetFeedback(true)
+	p.Failures().SetFeedback(true)
+	p.InitOutPort(p, "call")
+	p.InitOutPort(p, "out")
+	p.InitOutPort(p, "errors")
+	p.InitOutPort(p, "fallback")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *CircuitBreaker) In() *fb.InPort { return p.InPort("in") }
+
+// Out returns the out-port, on which the results of the wrapped process are
+// passed on
+func (p *CircuitBreaker) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Errors returns the out-port on which the failures of the wrapped process
+// are passed on
+func (p *CircuitBreaker) Errors() *fb.OutPort { return p.OutPort("errors") }
+
+// Fallback returns the out-port on which packets are sent while the circuit
+// is open
+func (p *CircuitBreaker) Fallback() *fb.OutPort { return p.OutPort("fallback") }
+
+// Call returns the out-port on which packets are sent to the wrapped process
+func (p *CircuitBreaker) Call() *fb.OutPort { return p.OutPort("call") }
+
+// Results returns the in-port receiving the results of the wrapped process
+func (p *CircuitBreaker) Results() *fb.InPort { return p.InPort("results") }
+
+// Failures returns the in-port receiving the failures of the wrapped process
+func (p *CircuitBreaker) Failures() *fb.InPort { return p.InPort("failures") }
+
+// Wrap connects the circuit breaker to the wrapped process, with the in-port
+// in, out-port out and errors port errors, such as those of an ExecProc
+func (p *CircuitBreaker) Wrap(in *fb.InPort, out *fb.OutPort, errors *fb.OutPort) {
+	in.From(p.Call())
+	p.Results().From(out)
+	p.Failures().From(errors)
+}
+
+// State returns the current state of the circuit
+func (p *CircuitBreaker) State() CircuitState {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	return p.state
+}
+
+// Run runs the CircuitBreaker process
+func (p *CircuitBreaker) Run() {
+	defer p.CloseOutPorts()
+
+	outcomesDone := make(chan struct{})
+	go func() {
+		defer close(outcomesDone)
+		p.passOutcomes()
+	}()
+
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		if p.allow() {
+			p.Call().SendPacket(ip)
+		} else {
+			p.Fallback().SendPacket(ip)
+		}
+	}
+	// Let the wrapped process finish, before closing the other out-ports
+	p.Call().Close()
+	<-outcomesDone
+}
+
+// allow tells whether a packet may be sent to the wrapped process, moving
+// the circuit to the half-open state if it is open and the cool-down has
+// passed
+func (p *CircuitBreaker) allow() bool {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	switch p.state {
+	case CircuitClosed:
+		return true
+	case CircuitOpen:
+		if p.Network().Clock().Now().Sub(p.openedAt) >= p.coolDown {
+			p.state = CircuitHalfOpen
+			return true
+		}
+	}
+	return false
+}
+
+// passOutcomes passes on the results and failures of the wrapped process,
+// updating the state of the circuit, until both of their ports are closed
+func (p *CircuitBreaker) passOutcomes() {
+	results := recvChan(p.Results())
+	failures := recvChan(p.Failures())
+	for results != nil || failures != nil {
+		select {
+		case ip, ok := <-results:
+			if !ok {
+				results = nil
+				continue
+			}
+			p.recordOutcome(true)
+			p.Out().SendPacket(ip)
+		case ip, ok := <-failures:
+			if !ok {
+				failures = nil
+				continue
+			}
+			p.recordOutcome(false)
+			p.Errors().SendPacket(ip)
+		}
+	}
+}
+
+// recordOutcome updates the state of the circuit with a success or failure
+// of the wrapped process
+func (p *CircuitBreaker) recordOutcome(success bool) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	if success {
+		p.failures = 0
+		if p.state == CircuitHalfOpen {
+			p.state = CircuitClosed
+		}
+		return
+	}
+	p.failures++
+	if p.state == CircuitHalfOpen || (p.state == CircuitClosed && p.failures >= p.threshold) {
+		if p.state == CircuitClosed {
+			fb.Warning.Printf("[Process:%s] Opening circuit after %d consecutive failures\n", p.Name(), p.failures)
+		}
+		p.state = CircuitOpen
+		p.openedAt = p.Network().Clock().Now()
+	}
+}
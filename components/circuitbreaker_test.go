@@ -0,0 +1,130 @@
+package components
+
+import (
+	"testing"
+	"time"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	initTestLogs()
+	net := fb.NewNetwork("TestCircuitBreaker")
+	clock := fb.NewVirtualClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	net.SetClock(clock)
+
+	feed := make(chan any)
+	ack := make(chan struct{})
+	src := newChanSource(net, "src", feed)
+	breaker := NewCircuitBreaker(net, "breaker", 2, time.Minute)
+	breaker.In().From(src.Out())
+	flaky := newFlaky(net, "flaky")
+	breaker.Wrap(flaky.In(), flaky.Out(), flaky.Errors())
+	out := newAckCollector(net, "out", ack)
+	out.In().From(breaker.Out())
+	errs := newAckCollector(net, "errors", ack)
+	errs.In().From(breaker.Errors())
+	fallback := newAckCollector(net, "fallback", ack)
+	fallback.In().From(breaker.Fallback())
+
+	done := make(chan struct{})
+	go func() {
+		net.Run()
+		close(done)
+	}()
+	send := func(v int) {
+		feed <- v
+		<-ack
+	}
+	send(-1)
+	send(-2)
+	send(3)
+	clock.Advance(time.Minute)
+	send(-4)
+	send(5)
+	clock.Advance(time.Minute)
+	send(6)
+	send(7)
+	close(feed)
+	<-done
+
+	assertEqualValues(t, []any{-1, -2, -4}, errs.data())
+	assertEqualValues(t, []any{3, 5}, fallback.data())
+	assertEqualValues(t, []any{6, 7}, out.data())
+	if state := breaker.State(); state != CircuitClosed {
+		t.Errorf("Wrong state of circuit: %s", state)
+	}
+}
+
+// chanSource sends the values received on a channel, until it is closed
+type chanSource struct {
+	fb.BaseProcess
+	values <-chan any
+}
+
+func newChanSource(net *fb.Network, name string, values <-chan any) *chanSource {
+	p := &chanSource{BaseProcess: fb.NewBaseProcess(net, name), values: values}
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+func (p *chanSource) Out() *fb.OutPort { return p.OutPort("out") }
+
+func (p *chanSource) Run() {
+	defer p.CloseOutPorts()
+	for v := range p.values {
+		p.Out().Send(v)
+	}
+}
+
+// flaky passes on positive numbers, and fails on negative ones
+type flaky struct {
+	fb.BaseProcess
+}
+
+func newFlaky(net *fb.Network, name string) *flaky {
+	p := &flaky{BaseProcess: fb.NewBaseProcess(net, name)}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	p.InitOutPort(p, "errors")
+	net.AddProc(p)
+	return p
+}
+
+func (p *flaky) In() *fb.InPort      { return p.InPort("in") }
+func (p *flaky) Out() *fb.OutPort    { return p.OutPort("out") }
+func (p *flaky) Errors() *fb.OutPort { return p.OutPort("errors") }
+
+func (p *flaky) Run() {
+	defer p.CloseOutPorts()
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		if ip.Data().(int) < 0 {
+			p.Errors().SendPacket(ip)
+		} else {
+			p.Out().SendPacket(ip)
+		}
+	}
+}
+
+// ackCollector is a collector that acknowledges every packet it receives
+type ackCollector struct {
+	*collector
+	ack chan<- struct{}
+}
+
+func newAckCollector(net *fb.Network, name string, ack chan<- struct{}) *ackCollector {
+	p := &ackCollector{collector: &collector{BaseProcess: fb.NewBaseProcess(net, name)}, ack: ack}
+	p.InitInPort(p, "in")
+	net.AddProc(p)
+	return p
+}
+
+func (p *ackCollector) Run() {
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		p.lock.Lock()
+		p.ips = append(p.ips, ip)
+		p.lock.Unlock()
+		p.ack <- struct{}{}
+	}
+}
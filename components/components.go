@@ -0,0 +1,21 @@
+// Package components contains general purpose FlowBase components, for
+// common stream processing tasks such as joining, sorting and timing.
+package components
+
+import (
+	fb "github.com/flowbase/flowbase"
+)
+
+// recvChan returns a channel on which all packets received on the in-port pt
+// are forwarded, and which is closed when pt is closed. This makes it possible
+// to select on in-ports regardless of their buffer implementation.
+func recvChan(pt *fb.InPort) <-chan *fb.Packet {
+	ch := make(chan *fb.Packet)
+	go func() {
+		defer close(ch)
+		for ip, ok := pt.RecvOK(); ok; ip, ok = pt.RecvOK() {
+			ch <- ip
+		}
+	}()
+	return ch
+}
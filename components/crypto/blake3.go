@@ -0,0 +1,231 @@
+package crypto
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+// This is a port of the BLAKE3 reference implementation, computing the
+// default 32-byte hash, without the keyed and key derivation modes. It
+// favours simplicity over speed, as the standard library has no BLAKE3.
+
+const (
+	blake3ChunkLen   = 1024
+	blake3BlockLen   = 64
+	blake3OutLen     = 32
+	blake3ChunkStart = 1 << 0
+	blake3ChunkEnd   = 1 << 1
+	blake3Parent     = 1 << 2
+	blake3Root       = 1 << 3
+)
+
+var blake3IV = [8]uint32{
+	0x6A09E667, 0xBB67AE85, 0x3C6EF372, 0xA54FF53A,
+	0x510E527F, 0x9B05688C, 0x1F83D9AB, 0x5BE0CD19,
+}
+
+var blake3MsgPermutation = [16]int{2, 6, 3, 10, 7, 0, 4, 13, 1, 11, 12, 5, 9, 14, 15, 8}
+
+func blake3G(s *[16]uint32, a, b, c, d int, mx, my uint32) {
+	s[a] = s[a] + s[b] + mx
+	s[d] = bits.RotateLeft32(s[d]^s[a], -16)
+	s[c] = s[c] + s[d]
+	s[b] = bits.RotateLeft32(s[b]^s[c], -12)
+	s[a] = s[a] + s[b] + my
+	s[d] = bits.RotateLeft32(s[d]^s[a], -8)
+	s[c] = s[c] + s[d]
+	s[b] = bits.RotateLeft32(s[b]^s[c], -7)
+}
+
+func blake3Round(s *[16]uint32, m *[16]uint32) {
+	// Mix the columns
+	blake3G(s, 0, 4, 8, 12, m[0], m[1])
+	blake3G(s, 1, 5, 9, 13, m[2], m[3])
+	blake3G(s, 2, 6, 10, 14, m[4], m[5])
+	blake3G(s, 3, 7, 11, 15, m[6], m[7])
+	// Mix the diagonals
+	blake3G(s, 0, 5, 10, 15, m[8], m[9])
+	blake3G(s, 1, 6, 11, 12, m[10], m[11])
+	blake3G(s, 2, 7, 8, 13, m[12], m[13])
+	blake3G(s, 3, 4, 9, 14, m[14], m[15])
+}
+
+func blake3Compress(cv [8]uint32, block [16]uint32, counter uint64, blockLen uint32, flags uint32) [16]uint32 {
+	s := [16]uint32{
+		cv[0], cv[1], cv[2], cv[3], cv[4], cv[5], cv[6], cv[7],
+		blake3IV[0], blake3IV[1], blake3IV[2], blake3IV[3],
+		uint32(counter), uint32(counter >> 32), blockLen, flags,
+	}
+	m := block
+	for r := 0; r < 7; r++ {
+		blake3Round(&s, &m)
+		if r < 6 {
+			var permuted [16]uint32
+			for i, j := range blake3MsgPermutation {
+				permuted[i] = m[j]
+			}
+			m = permuted
+		}
+	}
+	for i := 0; i < 8; i++ {
+		s[i] ^= s[i+8]
+		s[i+8] ^= cv[i]
+	}
+	return s
+}
+
+func first8(words [16]uint32) [8]uint32 {
+	var cv [8]uint32
+	copy(cv[:], words[:8])
+	return cv
+}
+
+func blockWords(block []byte) [16]uint32 {
+	var padded [blake3BlockLen]byte
+	copy(padded[:], block)
+	var words [16]uint32
+	for i := range words {
+		words[i] = binary.LittleEndian.Uint32(padded[i*4:])
+	}
+	return words
+}
+
+// blake3Output is the state just before the compression of a chunk or parent
+// node, which can produce either a chaining value or the root hash
+type blake3Output struct {
+	inputCV  [8]uint32
+	block    [16]uint32
+	counter  uint64
+	blockLen uint32
+	flags    uint32
+}
+
+func (o blake3Output) chainingValue() [8]uint32 {
+	return first8(blake3Compress(o.inputCV, o.block, o.counter, o.blockLen, o.flags))
+}
+
+func (o blake3Output) rootBytes() []byte {
+	words := blake3Compress(o.inputCV, o.block, 0, o.blockLen, o.flags|blake3Root)
+	out := make([]byte, blake3OutLen)
+	for i := 0; i < blake3OutLen/4; i++ {
+		binary.LittleEndian.PutUint32(out[i*4:], words[i])
+	}
+	return out
+}
+
+func blake3ParentOutput(left [8]uint32, right [8]uint32) blake3Output {
+	var block [16]uint32
+	copy(block[:8], left[:])
+	copy(block[8:], right[:])
+	return blake3Output{inputCV: blake3IV, block: block, blockLen: blake3BlockLen, flags: blake3Parent}
+}
+
+type blake3ChunkState struct {
+	cv               [8]uint32
+	chunkCounter     uint64
+	block            [blake3BlockLen]byte
+	blockLen         int
+	blocksCompressed int
+}
+
+func newBlake3ChunkState(chunkCounter uint64) blake3ChunkState {
+	return blake3ChunkState{cv: blake3IV, chunkCounter: chunkCounter}
+}
+
+func (c *blake3ChunkState) len() int {
+	return blake3BlockLen*c.blocksCompressed + c.blockLen
+}
+
+func (c *blake3ChunkState) startFlag() uint32 {
+	if c.blocksCompressed == 0 {
+		return blake3ChunkStart
+	}
+	return 0
+}
+
+func (c *blake3ChunkState) update(input []byte) {
+	for len(input) > 0 {
+		// The last block of a chunk is only compressed in output, as it needs
+		// the chunk end flag
+		if c.blockLen == blake3BlockLen {
+			c.cv = first8(blake3Compress(c.cv, blockWords(c.block[:]), c.chunkCounter, blake3BlockLen, c.startFlag()))
+			c.blocksCompressed++
+			c.block = [blake3BlockLen]byte{}
+			c.blockLen = 0
+		}
+		n := copy(c.block[c.blockLen:], input)
+		c.blockLen += n
+		input = input[n:]
+	}
+}
+
+func (c *blake3ChunkState) output() blake3Output {
+	return blake3Output{
+		inputCV:  c.cv,
+		block:    blockWords(c.block[:c.blockLen]),
+		counter:  c.chunkCounter,
+		blockLen: uint32(c.blockLen),
+		flags:    c.startFlag() | blake3ChunkEnd,
+	}
+}
+
+// blake3Hasher computes BLAKE3 hashes, implementing hash.Hash
+type blake3Hasher struct {
+	chunk   blake3ChunkState
+	cvStack [][8]uint32
+}
+
+// NewBLAKE3 returns a new hash.Hash computing the 32-byte BLAKE3 hash
+func NewBLAKE3() hash.Hash {
+	return &blake3Hasher{chunk: newBlake3ChunkState(0)}
+}
+
+// addChunkCV adds the chaining value of a completed chunk, merging it with
+// the chaining values of completed subtrees of the same size, as given by
+// the number of trailing zero bits in totalChunks
+func (h *blake3Hasher) addChunkCV(cv [8]uint32, totalChunks uint64) {
+	for totalChunks&1 == 0 {
+		left := h.cvStack[len(h.cvStack)-1]
+		h.cvStack = h.cvStack[:len(h.cvStack)-1]
+		cv = blake3ParentOutput(left, cv).chainingValue()
+		totalChunks >>= 1
+	}
+	h.cvStack = append(h.cvStack, cv)
+}
+
+func (h *blake3Hasher) Write(input []byte) (int, error) {
+	n := len(input)
+	for len(input) > 0 {
+		if h.chunk.len() == blake3ChunkLen {
+			cv := h.chunk.output().chainingValue()
+			totalChunks := h.chunk.chunkCounter + 1
+			h.addChunkCV(cv, totalChunks)
+			h.chunk = newBlake3ChunkState(totalChunks)
+		}
+		take := blake3ChunkLen - h.chunk.len()
+		if take > len(input) {
+			take = len(input)
+		}
+		h.chunk.update(input[:take])
+		input = input[take:]
+	}
+	return n, nil
+}
+
+func (h *blake3Hasher) Sum(b []byte) []byte {
+	out := h.chunk.output()
+	for i := len(h.cvStack) - 1; i >= 0; i-- {
+		out = blake3ParentOutput(h.cvStack[i], out.chainingValue())
+	}
+	return append(b, out.rootBytes()...)
+}
+
+func (h *blake3Hasher) Reset() {
+	h.chunk = newBlake3ChunkState(0)
+	h.cvStack = nil
+}
+
+func (h *blake3Hasher) Size() int { return blake3OutLen }
+
+func (h *blake3Hasher) BlockSize() int { return blake3BlockLen }
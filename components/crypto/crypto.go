@@ -0,0 +1,288 @@
+// Package crypto contains components for hashing packet payloads and files
+// (Hash), signing them with HMACs (HMACSign), and encrypting and decrypting
+// them with AES-GCM (Encrypt, Decrypt), with keys from a SecretProvider.
+//
+// Payloads are the data of packets, which can be []byte, strings, or
+// *fb.FileIP, in which case the content of the file is used.
+package crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// Algorithm is a hash algorithm
+type Algorithm string
+
+const (
+	// SHA256 is the SHA-256 hash algorithm
+	SHA256 Algorithm = "sha256"
+	// BLAKE3 is the BLAKE3 hash algorithm, with 32-byte hashes
+	BLAKE3 Algorithm = "blake3"
+)
+
+func (a Algorithm) newHash() hash.Hash {
+	switch a {
+	case SHA256:
+		return sha256.New()
+	case BLAKE3:
+		return NewBLAKE3()
+	}
+	fb.Failf("Unsupported hash algorithm: %s", a)
+	return nil
+}
+
+// openPayload returns a reader for the payload of the packet ip
+func openPayload(ip *fb.Packet) io.ReadCloser {
+	if f, ok := ip.Data().(*fb.FileIP); ok {
+		return f.Open()
+	}
+	return io.NopCloser(bytes.NewReader(payload(ip)))
+}
+
+// payload returns the payload of the packet ip
+func payload(ip *fb.Packet) []byte {
+	switch data := ip.Data().(type) {
+	case []byte:
+		return data
+	case string:
+		return []byte(data)
+	case *fb.FileIP:
+		return data.Read()
+	}
+	ip.Failf("Data (%v) of type %T is neither bytes, a string nor a file", ip.Data(), ip.Data())
+	return nil
+}
+
+// digest returns the hex encoded hash of the payload of ip, computed with h
+func digest(ip *fb.Packet, h hash.Hash) string {
+	r := openPayload(ip)
+	defer r.Close()
+	if _, err := io.Copy(h, r); err != nil {
+		ip.Failf("Could not read payload: %v", err)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ------------------------------------------------------------------------
+// Hash
+// ------------------------------------------------------------------------
+
+// Hash computes the hash of the payload of each packet it receives, and sends
+// the packet on, tagged with the hex encoded hash, with the name of the
+// algorithm as tag name, such as "sha256"
+type Hash struct {
+	fb.BaseProcess
+	algorithm Algorithm
+}
+
+// NewHash returns a new Hash process, hashing with algorithm
+func NewHash(net *fb.Network, name string, algorithm Algorithm) *Hash {
+	// Fail early on unsupported algorithms
+	algorithm.newHash()
+	p := &Hash{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		algorithm:   algorithm,
+	}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *Hash) In() *fb.InPort { return p.InPort("in") }
+
+// Out returns the out-port
+func (p *Hash) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Run runs the Hash process
+func (p *Hash) Run() {
+	defer p.CloseOutPorts()
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		ip.AddTag(string(p.algorithm), digest(ip, p.algorithm.newHash()))
+		p.Out().SendPacket(ip)
+	}
+}
+
+// ------------------------------------------------------------------------
+// HMACSign
+// ------------------------------------------------------------------------
+
+// HMACSign computes the HMAC-SHA256 of the payload of each packet it
+// receives, and sends the packet on, tagged with the hex encoded HMAC, as
+// "hmac"
+type HMACSign struct {
+	fb.BaseProcess
+	secrets SecretProvider
+	keyName string
+}
+
+// NewHMACSign returns a new HMACSign process, signing with the secret
+// keyName of secrets
+func NewHMACSign(net *fb.Network, name string, secrets SecretProvider, keyName string) *HMACSign {
+	p := &HMACSign{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		secrets:     secrets,
+		keyName:     keyName,
+	}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *HMACSign) In() *fb.InPort { return p.InPort("in") }
+
+// Out returns the out-port
+func (p *HMACSign) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Run runs the HMACSign process
+func (p *HMACSign) Run() {
+	defer p.CloseOutPorts()
+	key, err := p.secrets.Secret(p.keyName)
+	if err != nil {
+		p.Failf("Could not get signing key: %v", err)
+	}
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		ip.AddTag("hmac", digest(ip, hmac.New(sha256.New, key)))
+		p.Out().SendPacket(ip)
+	}
+}
+
+// VerifyHMAC tells whether the "hmac" tag of the packet ip, as set by
+// HMACSign, is valid for its payload and the key key
+func VerifyHMAC(ip *fb.Packet, key []byte) bool {
+	sig, err := hex.DecodeString(ip.Tags()["hmac"])
+	if err != nil {
+		return false
+	}
+	want, _ := hex.DecodeString(digest(ip, hmac.New(sha256.New, key)))
+	return hmac.Equal(sig, want)
+}
+
+// ------------------------------------------------------------------------
+// Encrypt / Decrypt
+// ------------------------------------------------------------------------
+
+// newGCM returns an AES-GCM cipher with the secret keyName of secrets, which
+// must be 16, 24 or 32 bytes long, for AES-128, AES-192 or AES-256
+func newGCM(secrets SecretProvider, keyName string) (cipher.AEAD, error) {
+	key, err := secrets.Secret(keyName)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encrypt encrypts the payload of each packet it receives with AES-GCM, and
+// sends the encrypted payload, as []byte, prefixed by the random nonce, with
+// the tags of the packet
+type Encrypt struct {
+	fb.BaseProcess
+	secrets SecretProvider
+	keyName string
+}
+
+// NewEncrypt returns a new Encrypt process, encrypting with the secret
+// keyName of secrets
+func NewEncrypt(net *fb.Network, name string, secrets SecretProvider, keyName string) *Encrypt {
+	p := &Encrypt{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		secrets:     secrets,
+		keyName:     keyName,
+	}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *Encrypt) In() *fb.InPort { return p.InPort("in") }
+
+// Out returns the out-port, on which the encrypted payloads are sent
+func (p *Encrypt) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Run runs the Encrypt process
+func (p *Encrypt) Run() {
+	defer p.CloseOutPorts()
+	gcm, err := newGCM(p.secrets, p.keyName)
+	if err != nil {
+		p.Failf("Could not set up encryption: %v", err)
+	}
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		nonce := make([]byte, gcm.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			p.Failf("Could not generate nonce: %v", err)
+		}
+		newIP := fb.NewPacket(gcm.Seal(nonce, nonce, payload(ip), nil))
+		newIP.AddTags(ip.Tags())
+		p.Out().SendPacket(newIP)
+	}
+}
+
+// Decrypt decrypts the payloads encrypted by Encrypt, and sends the
+// decrypted payloads, as []byte, with the tags of the packets. It fails on
+// payloads which were not encrypted with the same key, or were tampered with.
+type Decrypt struct {
+	fb.BaseProcess
+	secrets SecretProvider
+	keyName string
+}
+
+// NewDecrypt returns a new Decrypt process, decrypting with the secret
+// keyName of secrets
+func NewDecrypt(net *fb.Network, name string, secrets SecretProvider, keyName string) *Decrypt {
+	p := &Decrypt{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		secrets:     secrets,
+		keyName:     keyName,
+	}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *Decrypt) In() *fb.InPort { return p.InPort("in") }
+
+// Out returns the out-port, on which the decrypted payloads are sent
+func (p *Decrypt) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Run runs the Decrypt process
+func (p *Decrypt) Run() {
+	defer p.CloseOutPorts()
+	gcm, err := newGCM(p.secrets, p.keyName)
+	if err != nil {
+		p.Failf("Could not set up decryption: %v", err)
+	}
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		data := payload(ip)
+		if len(data) < gcm.NonceSize() {
+			ip.Fail("Encrypted payload is too short")
+		}
+		plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+		if err != nil {
+			ip.Failf("Could not decrypt payload: %v", err)
+		}
+		newIP := fb.NewPacket(plain)
+		newIP.AddTags(ip.Tags())
+		p.Out().SendPacket(newIP)
+	}
+}
@@ -0,0 +1,88 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"path/filepath"
+	"testing"
+
+	fb "github.com/flowbase/flowbase"
+	"github.com/flowbase/flowbase/flowbasetest"
+)
+
+func TestBLAKE3(t *testing.T) {
+	// Inputs of the official test vectors are bytes 0, 1, ..., 250, 0, 1, ...
+	input := func(n int) []byte {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = byte(i % 251)
+		}
+		return b
+	}
+	for _, tc := range []struct {
+		input []byte
+		want  string
+	}{
+		{[]byte{}, "af1349b9f5f9a1a6a0404dea36dcc9499bcb25c9adc112b7cc9a93cae41f3262"},
+		{[]byte("abc"), "6437b3ac38465133ffb63b75273a8db548c558465d79db03fd359c6cd5bd9d85"},
+		{input(1), "2d3adedff11b61f14c886e35afa036736dcd87a74d27b5c1510225d0f592e213"},
+		{input(1024), "42214739f095a406f3fc83deb889744ac00df831c10daa55189b5d121c855af7"},
+		{input(1025), "d00278ae47eb27b34faecf67b4fe263f82d5412916c1ffd97c8cb7fb814b8444"},
+		{input(3072), "b98cb0ff3623be03326b373de6b9095218513e64f1ee2edd2525c7ad1e5cffd2"},
+	} {
+		h := NewBLAKE3()
+		// Write in two parts, to check that state is kept between writes
+		h.Write(tc.input[:len(tc.input)/3])
+		h.Write(tc.input[len(tc.input)/3:])
+		if have := hex.EncodeToString(h.Sum(nil)); have != tc.want {
+			t.Errorf("Wrong BLAKE3 hash of %d bytes: %s, wanted: %s", len(tc.input), have, tc.want)
+		}
+	}
+}
+
+func TestHashSignEncrypt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.txt")
+	fb.NewFileIP(path).Write([]byte("abc"))
+	secrets := StaticSecrets{
+		"sign": []byte("signing key"),
+		"aes":  bytes.Repeat([]byte{7}, 32),
+	}
+
+	net := flowbasetest.NewTestNetwork(t)
+	hash := NewHash(net.Network, "sha256", SHA256)
+	flowbasetest.FeedPort(hash.In(), fb.NewFileIP(path), []byte("abc"))
+	blake := NewHash(net.Network, "blake3", BLAKE3)
+	blake.In().From(hash.Out())
+	sign := NewHMACSign(net.Network, "sign", secrets, "sign")
+	sign.In().From(blake.Out())
+	signed := flowbasetest.CollectPort[any](sign.Out())
+
+	encrypt := NewEncrypt(net.Network, "encrypt", secrets, "aes")
+	flowbasetest.FeedPort(encrypt.In(), "secret message")
+	decrypt := NewDecrypt(net.Network, "decrypt", secrets, "aes")
+	decrypt.In().From(encrypt.Out())
+	encrypted := flowbasetest.CollectPort[[]byte](encrypt.Out())
+	decrypted := flowbasetest.CollectPort[[]byte](decrypt.Out())
+	net.Run()
+
+	for _, ip := range signed.Packets() {
+		if ip.Tag("sha256") != "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad" {
+			t.Errorf("Wrong SHA-256 hash: %s", ip.Tag("sha256"))
+		}
+		if ip.Tag("blake3") != "6437b3ac38465133ffb63b75273a8db548c558465d79db03fd359c6cd5bd9d85" {
+			t.Errorf("Wrong BLAKE3 hash: %s", ip.Tag("blake3"))
+		}
+		if !VerifyHMAC(ip, secrets["sign"]) {
+			t.Error("HMAC could not be verified")
+		}
+		if VerifyHMAC(ip, []byte("other key")) {
+			t.Error("HMAC verified with wrong key")
+		}
+	}
+	if bytes.Contains(encrypted.Values()[0], []byte("secret message")) {
+		t.Error("Encrypted payload contains the plain text")
+	}
+	if have := string(decrypted.Values()[0]); have != "secret message" {
+		t.Errorf("Wrong decrypted payload: %s", have)
+	}
+}
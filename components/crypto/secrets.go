@@ -0,0 +1,50 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SecretProvider provides the keys used by the crypto components, by name,
+// so that keys can be kept out of workflow code, such as in environment
+// variables or a secrets manager
+type SecretProvider interface {
+	Secret(name string) ([]byte, error)
+}
+
+// EnvSecrets provides secrets from environment variables, named by Prefix
+// followed by the secret name, such as FLOWBASE_SECRET_signing_key. Values
+// of the form "hex:..." and "base64:..." are decoded, others are used as is.
+type EnvSecrets struct {
+	Prefix string
+}
+
+// Secret returns the value of the environment variable for the secret name
+func (s EnvSecrets) Secret(name string) ([]byte, error) {
+	value, ok := os.LookupEnv(s.Prefix + name)
+	if !ok {
+		return nil, fmt.Errorf("secret %s not found in environment variable %s", name, s.Prefix+name)
+	}
+	switch {
+	case strings.HasPrefix(value, "hex:"):
+		return hex.DecodeString(strings.TrimPrefix(value, "hex:"))
+	case strings.HasPrefix(value, "base64:"):
+		return base64.StdEncoding.DecodeString(strings.TrimPrefix(value, "base64:"))
+	}
+	return []byte(value), nil
+}
+
+// StaticSecrets provides secrets from a map, such as for tests
+type StaticSecrets map[string][]byte
+
+// Secret returns the secret name
+func (s StaticSecrets) Secret(name string) ([]byte, error) {
+	secret, ok := s[name]
+	if !ok {
+		return nil, fmt.Errorf("secret %s not found", name)
+	}
+	return secret, nil
+}
@@ -0,0 +1,378 @@
+// Package duckdb contains the SQLTransform component, which transforms
+// tabular packets with SQL, by loading them into DuckDB, which makes it
+// possible to filter, join, aggregate and reshape tables declaratively in
+// pipelines.
+//
+// DuckDB is run with the duckdb command line tool, as a separate process,
+// with an in-memory database for each packet, so that no driver needs to be
+// linked into programs using flowbase.
+package duckdb
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	fb "github.com/flowbase/flowbase"
+	"github.com/flowbase/flowbase/arrow"
+)
+
+// DuckDBPath is the duckdb executable used to run queries, which is looked
+// up in the PATH by default
+var DuckDBPath = "duckdb"
+
+// Format is the format of tabular packet data
+type Format string
+
+const (
+	// FormatCSV is CSV data with a header, as []byte or string
+	FormatCSV Format = "csv"
+	// FormatParquet is the data of a Parquet file, as []byte
+	FormatParquet Format = "parquet"
+	// FormatArrow is an *arrow.RecordBatch, or an Arrow IPC stream of
+	// record batches, as []byte, when received
+	FormatArrow Format = "arrow"
+)
+
+// ParseFormat returns the format named name, which is "csv", "parquet" or
+// "arrow"
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(name); format {
+	case FormatCSV, FormatParquet, FormatArrow:
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown format %q, expected %s, %s or %s", name, FormatCSV, FormatParquet, FormatArrow)
+}
+
+// inputTable is the table the data of each packet is loaded into
+const inputTable = "input"
+
+// ------------------------------------------------------------------------
+// SQLTransform
+// ------------------------------------------------------------------------
+
+// SQLTransform runs a SQL query on the table in each packet it receives, in
+// DuckDB, and sends on the result, with the tags of the packet. The query
+// reads the table from the table named input, such as:
+//
+//	SELECT sample, avg(coverage) AS coverage FROM input GROUP BY sample
+//
+// Packets with *arrow.RecordBatch data are loaded as is, and packets with
+// []byte or string data in the format InputFormat. The result is sent in the
+// output format, as []byte data for CSV and Parquet, and as an
+// *arrow.RecordBatch for Arrow.
+type SQLTransform struct {
+	fb.BaseProcess
+	query        string
+	outputFormat Format
+	// InputFormat is the format of packets with []byte or string data, which
+	// is CSV by default
+	InputFormat Format
+}
+
+// NewSQLTransform returns a new SQLTransform process, running the SQL query
+// query, and sending its result in the format outputFormat
+func NewSQLTransform(net *fb.Network, name string, query string, outputFormat Format) *SQLTransform {
+	p := &SQLTransform{
+		BaseProcess:  fb.NewBaseProcess(net, name),
+		query:        strings.TrimRight(strings.TrimSpace(query), ";"),
+		outputFormat: outputFormat,
+		InputFormat:  FormatCSV,
+	}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *SQLTransform) In() *fb.InPort { return p.InPort("in") }
+
+// Out returns the out-port
+func (p *SQLTransform) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Run runs the SQLTransform process
+func (p *SQLTransform) Run() {
+	defer p.CloseOutPorts()
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		result, err := p.transform(ip.Data())
+		if err != nil {
+			ip.Failf("Could not run SQL query: %v", err)
+		}
+		newIP := fb.NewPacket(result)
+		newIP.AddTags(ip.Tags())
+		p.Out().SendPacket(newIP)
+	}
+}
+
+// transform runs the query on the table data, in a temporary directory for
+// the input and output files, and returns the result
+func (p *SQLTransform) transform(data any) (any, error) {
+	dir, err := os.MkdirTemp("", "flowbase-duckdb-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	script := &strings.Builder{}
+	if err := writeLoadSQL(script, dir, data, p.InputFormat); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(script, "CREATE TABLE result AS %s;\n", p.query)
+
+	outPath := filepath.Join(dir, "result."+string(p.outputFormat))
+	switch p.outputFormat {
+	case FormatCSV:
+		fmt.Fprintf(script, "COPY result TO %s (FORMAT CSV, HEADER);\n", quoteString(outPath))
+	case FormatParquet:
+		fmt.Fprintf(script, "COPY result TO %s (FORMAT PARQUET);\n", quoteString(outPath))
+	case FormatArrow:
+		return readArrowResult(script.String(), filepath.Join(dir, "result.json"))
+	default:
+		return nil, fmt.Errorf("unknown output format: %s", p.outputFormat)
+	}
+	if _, err := runDuckDB(script.String()); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(outPath)
+}
+
+// runDuckDB runs the SQL script script in an in-memory database, and
+// returns the output of its queries, as CSV without header
+func runDuckDB(script string) ([]byte, error) {
+	cmd := exec.Command(DuckDBPath, "-bail", "-csv", "-noheader")
+	cmd.Stdin = strings.NewReader(script)
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("duckdb failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	// Errors are not always reflected in the exit status of the duckdb
+	// shell when reading from stdin
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return nil, fmt.Errorf("duckdb failed: %s", msg)
+	}
+	return stdout.Bytes(), nil
+}
+
+// writeLoadSQL writes the SQL statements loading the table data, in the
+// format format if it is not a record batch, into the input table, to
+// script, with files written to dir
+func writeLoadSQL(script io.Writer, dir string, data any, format Format) error {
+	var raw []byte
+	switch d := data.(type) {
+	case *arrow.RecordBatch:
+		return writeArrowLoadSQL(script, dir, []*arrow.RecordBatch{d})
+	case []byte:
+		raw = d
+	case string:
+		raw = []byte(d)
+	default:
+		return fmt.Errorf("data of type %T is not a table", data)
+	}
+
+	switch format {
+	case FormatArrow:
+		r := arrow.NewStreamReader(bytes.NewReader(raw))
+		batches := []*arrow.RecordBatch{}
+		for {
+			batch, _, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if len(batches) > 0 && !batch.Schema().Equal(batches[0].Schema()) {
+				return fmt.Errorf("record batches in Arrow stream have different schemas")
+			}
+			batches = append(batches, batch)
+		}
+		if len(batches) == 0 {
+			return fmt.Errorf("no record batch in Arrow stream")
+		}
+		return writeArrowLoadSQL(script, dir, batches)
+	case FormatCSV, FormatParquet:
+		path := filepath.Join(dir, "input."+string(format))
+		if err := os.WriteFile(path, raw, 0644); err != nil {
+			return err
+		}
+		reader := "read_csv_auto"
+		if format == FormatParquet {
+			reader = "read_parquet"
+		}
+		_, err := fmt.Fprintf(script, "CREATE TABLE %s AS SELECT * FROM %s(%s);\n", inputTable, reader, quoteString(path))
+		return err
+	}
+	return fmt.Errorf("unknown input format: %s", format)
+}
+
+// writeArrowLoadSQL writes the record batches batches, which all have the
+// same schema, as a Parquet file in dir, and the SQL statement loading it
+// into the input table, to script
+func writeArrowLoadSQL(script io.Writer, dir string, batches []*arrow.RecordBatch) error {
+	buf := &bytes.Buffer{}
+	if err := writeParquet(buf, batches); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, "input.parquet")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(script, "CREATE TABLE %s AS SELECT * FROM read_parquet(%s);\n", inputTable, quoteString(path))
+	return err
+}
+
+func quoteString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func quoteIdent(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// ------------------------------------------------------------------------
+// Arrow results
+// ------------------------------------------------------------------------
+
+// arrowColumn is a column of a result, with the SQL expression selecting it
+// in a form which can be decoded from JSON into its Arrow type
+type arrowColumn struct {
+	field arrow.Field
+	expr  string
+}
+
+// resultColumn returns the Arrow column for the result column name of the
+// DuckDB type duckDBType. Types without an Arrow equivalent are converted to
+// strings.
+func resultColumn(name string, duckDBType string) arrowColumn {
+	ident := quoteIdent(name)
+	col := arrowColumn{field: arrow.Field{Name: name, Nullable: true}, expr: ident}
+	switch {
+	case duckDBType == "INTEGER" || duckDBType == "SMALLINT" || duckDBType == "TINYINT" ||
+		duckDBType == "USMALLINT" || duckDBType == "UTINYINT":
+		col.field.Type = arrow.Int32
+	case duckDBType == "BIGINT" || duckDBType == "UINTEGER":
+		col.field.Type = arrow.Int64
+	case duckDBType == "HUGEINT" || duckDBType == "UBIGINT":
+		// Such as the results of sum, which fail to convert only if they
+		// overflow
+		col.field.Type = arrow.Int64
+		col.expr = "CAST(" + ident + " AS BIGINT)"
+	case duckDBType == "FLOAT":
+		col.field.Type = arrow.Float32
+	case duckDBType == "DOUBLE" || strings.HasPrefix(duckDBType, "DECIMAL"):
+		col.field.Type = arrow.Float64
+		col.expr = "CAST(" + ident + " AS DOUBLE)"
+	case duckDBType == "BOOLEAN":
+		col.field.Type = arrow.Bool
+	case duckDBType == "BLOB":
+		col.field.Type = arrow.Binary
+		col.expr = "hex(" + ident + ")"
+	default:
+		col.field.Type = arrow.String
+		col.expr = "CAST(" + ident + " AS VARCHAR)"
+	}
+	col.expr += " AS " + ident
+	return col
+}
+
+// readArrowResult runs the script script, which creates the result table,
+// and returns the table as a record batch, exported through a JSON file at
+// jsonPath. Float NaN and infinite values
+// are not supported, since they are not valid JSON.
+func readArrowResult(script string, jsonPath string) (*arrow.RecordBatch, error) {
+	// The result is described first, to be exported as JSON with values which
+	// can be converted to the Arrow types of the columns
+	out, err := runDuckDB(script + "SELECT column_name, column_type FROM (DESCRIBE result);\n")
+	if err != nil {
+		return nil, err
+	}
+	described, err := csv.NewReader(bytes.NewReader(out)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("could not read result columns: %v", err)
+	}
+	columns := []arrowColumn{}
+	exprs := []string{}
+	fields := []arrow.Field{}
+	for _, record := range described {
+		if len(record) != 2 {
+			return nil, fmt.Errorf("unexpected result column description: %v", record)
+		}
+		col := resultColumn(record[0], record[1])
+		columns = append(columns, col)
+		exprs = append(exprs, col.expr)
+		fields = append(fields, col.field)
+	}
+	script += fmt.Sprintf("COPY (SELECT %s FROM result) TO %s (FORMAT JSON);\n", strings.Join(exprs, ", "), quoteString(jsonPath))
+	if _, err := runDuckDB(script); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(jsonPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	builders := make([]*arrow.Builder, len(columns))
+	for i, col := range columns {
+		builders[i] = arrow.NewBuilder(col.field.Type)
+	}
+	dec := json.NewDecoder(f)
+	dec.UseNumber()
+	for {
+		row := map[string]any{}
+		if err := dec.Decode(&row); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("could not read result: %v", err)
+		}
+		for i, col := range columns {
+			v, err := arrowValue(row[col.field.Name], col.field.Type)
+			if err == nil {
+				err = builders[i].Append(v)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid value of result column %s: %v", col.field.Name, err)
+			}
+		}
+	}
+	arrays := make([]*arrow.Array, len(builders))
+	for i, b := range builders {
+		arrays[i] = b.Array()
+	}
+	return arrow.NewRecordBatch(arrow.NewSchema(fields...), arrays)
+}
+
+// arrowValue converts the JSON value v, decoded with json.Number numbers,
+// to a value which can be appended to an array of the type typ
+func arrowValue(v any, typ arrow.Type) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	switch typ {
+	case arrow.Int32, arrow.Int64:
+		if n, ok := v.(json.Number); ok {
+			return n.Int64()
+		}
+	case arrow.Float32, arrow.Float64:
+		if n, ok := v.(json.Number); ok {
+			return n.Float64()
+		}
+	case arrow.Binary:
+		if s, ok := v.(string); ok {
+			return hex.DecodeString(s)
+		}
+	default:
+		return v, nil
+	}
+	return nil, fmt.Errorf("unexpected value %v", v)
+}
@@ -0,0 +1,215 @@
+package duckdb
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	fb "github.com/flowbase/flowbase"
+	"github.com/flowbase/flowbase/arrow"
+	"github.com/flowbase/flowbase/flowbasetest"
+)
+
+func TestArrowLoadSQL(t *testing.T) {
+	schema := arrow.NewSchema(
+		arrow.Field{Name: "name", Type: arrow.String},
+		arrow.Field{Name: "score", Type: arrow.Float64, Nullable: true},
+		arrow.Field{Name: "raw", Type: arrow.Binary, Nullable: true},
+	)
+	batch, err := arrow.NewRecordBatchFromRows(schema, []map[string]any{
+		{"name": "o'brien", "score": 0.5, "raw": []byte{0xca, 0xfe}},
+		{"name": "x"},
+	})
+	if err != nil {
+		t.Fatalf("Could not create record batch: %v", err)
+	}
+	script := &strings.Builder{}
+	dir := t.TempDir()
+	if err := writeLoadSQL(script, dir, batch, FormatCSV); err != nil {
+		t.Fatalf("Could not write SQL: %v", err)
+	}
+	// The record batch is loaded from a Parquet file, rather than inserted row
+	// by row
+	path := filepath.Join(dir, "input.parquet")
+	assertEqualValues(t, "CREATE TABLE input AS SELECT * FROM read_parquet("+quoteString(path)+");\n", script.String())
+	parquet := &bytes.Buffer{}
+	if err := writeParquet(parquet, []*arrow.RecordBatch{batch}); err != nil {
+		t.Fatalf("Could not write Parquet: %v", err)
+	}
+	if content, err := os.ReadFile(path); err != nil || !bytes.Equal(parquet.Bytes(), content) {
+		t.Errorf("Record batch not written as Parquet to %s (%v)", path, err)
+	}
+
+	if err := writeLoadSQL(script, t.TempDir(), 42, FormatCSV); err == nil {
+		t.Errorf("Expected an error for data which is not a table")
+	}
+}
+
+func TestResultColumn(t *testing.T) {
+	for duckDBType, expected := range map[string]arrowColumn{
+		"BIGINT":        {arrow.Field{Name: "n", Type: arrow.Int64, Nullable: true}, `"n" AS "n"`},
+		"DECIMAL(18,3)": {arrow.Field{Name: "n", Type: arrow.Float64, Nullable: true}, `CAST("n" AS DOUBLE) AS "n"`},
+		"BLOB":          {arrow.Field{Name: "n", Type: arrow.Binary, Nullable: true}, `hex("n") AS "n"`},
+		"DATE":          {arrow.Field{Name: "n", Type: arrow.String, Nullable: true}, `CAST("n" AS VARCHAR) AS "n"`},
+	} {
+		assertEqualValues(t, expected, resultColumn("n", duckDBType))
+	}
+}
+
+func TestMain(m *testing.M) {
+	if os.Getenv("FLOWBASE_FAKE_DUCKDB") == "1" {
+		os.Exit(runFakeDuckDB())
+	}
+	os.Exit(m.Run())
+}
+
+var (
+	fakeLoadPtn   = regexp.MustCompile(`FROM (read_csv_auto|read_parquet)\('([^']*)'\)`)
+	fakeExportPtn = regexp.MustCompile(`TO '([^']*)' \(FORMAT (\w+)`)
+)
+
+// runFakeDuckDB stands in for the duckdb shell. Rather than running the script
+// it reads, it saves it, and the file it loads the input table from, in the
+// directory FLOWBASE_FAKE_DUCKDB_DIR, and answers it with the files there:
+// the result description in describe.csv, and the result, exported to the
+// file the script exports it to, in result.csv or result.json.
+func runFakeDuckDB() int {
+	dir := os.Getenv("FLOWBASE_FAKE_DUCKDB_DIR")
+	script, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := os.WriteFile(filepath.Join(dir, "script.sql"), script, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if m := fakeLoadPtn.FindSubmatch(script); m != nil {
+		content, err := os.ReadFile(string(m[2]))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		os.WriteFile(filepath.Join(dir, "input"+filepath.Ext(string(m[2]))), content, 0644)
+	}
+	if bytes.Contains(script, []byte("(DESCRIBE result)")) {
+		content, _ := os.ReadFile(filepath.Join(dir, "describe.csv"))
+		os.Stdout.Write(content)
+		return 0
+	}
+	if m := fakeExportPtn.FindSubmatch(script); m != nil {
+		content, err := os.ReadFile(filepath.Join(dir, "result."+strings.ToLower(string(m[2]))))
+		if err == nil {
+			err = os.WriteFile(string(m[1]), content, 0644)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	return 0
+}
+
+// useFakeDuckDB makes SQLTransform processes run the fake duckdb shell for
+// the duration of the test, answering with the files files, by name, and
+// returns the directory the fake saves the scripts and inputs in
+func useFakeDuckDB(t *testing.T, files map[string]string) string {
+	path := DuckDBPath
+	t.Cleanup(func() { DuckDBPath = path })
+	DuckDBPath = os.Args[0]
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("FLOWBASE_FAKE_DUCKDB", "1")
+	t.Setenv("FLOWBASE_FAKE_DUCKDB_DIR", dir)
+	return dir
+}
+
+func TestSQLTransform(t *testing.T) {
+	dir := useFakeDuckDB(t, map[string]string{
+		"describe.csv": "sample,VARCHAR\nreads,HUGEINT\n",
+		"result.json":  `{"sample":"s1","reads":13}` + "\n" + `{"sample":"s2","reads":5}` + "\n",
+	})
+	net := flowbasetest.NewTestNetwork(t)
+	sql := NewSQLTransform(net.Network, "sql", "SELECT sample, sum(reads) AS reads FROM input GROUP BY sample ORDER BY sample;", FormatArrow)
+	ip := fb.NewPacket("sample,reads\ns1,10\ns2,5\ns1,3\n")
+	ip.AddTag("run", "r1")
+	flowbasetest.FeedPort(sql.In(), ip)
+	results := flowbasetest.CollectPort[*arrow.RecordBatch](sql.Out())
+	net.Run()
+
+	batch := results.Values()[0]
+	assertEqualValues(t, 2, batch.NumRows())
+	assertEqualValues(t, "s1", batch.Row(0)["sample"])
+	assertEqualValues(t, int64(13), batch.Row(0)["reads"])
+	assertEqualValues(t, "r1", results.Packets()[0].Tag("run"))
+
+	script := readFile(t, filepath.Join(dir, "script.sql"))
+	for _, stmt := range []string{
+		"CREATE TABLE result AS SELECT sample, sum(reads) AS reads FROM input GROUP BY sample ORDER BY sample;\n",
+		`COPY (SELECT CAST("sample" AS VARCHAR) AS "sample", CAST("reads" AS BIGINT) AS "reads" FROM result) TO `,
+	} {
+		if !strings.Contains(script, stmt) {
+			t.Errorf("Statement %q not found in script:\n%s", stmt, script)
+		}
+	}
+	assertEqualValues(t, "sample,reads\ns1,10\ns2,5\ns1,3\n", readFile(t, filepath.Join(dir, "input.csv")))
+}
+
+func TestSQLTransformArrowInput(t *testing.T) {
+	dir := useFakeDuckDB(t, map[string]string{
+		"result.csv": "sample,reads\ns1,10\n",
+	})
+	schema := arrow.NewSchema(
+		arrow.Field{Name: "sample", Type: arrow.String},
+		arrow.Field{Name: "reads", Type: arrow.Int64},
+	)
+	batch, err := arrow.NewRecordBatchFromRows(schema, []map[string]any{
+		{"sample": "s1", "reads": 10},
+		{"sample": "s2", "reads": 5},
+	})
+	if err != nil {
+		t.Fatalf("Could not create record batch: %v", err)
+	}
+	net := flowbasetest.NewTestNetwork(t)
+	sql := NewSQLTransform(net.Network, "sql", "SELECT * FROM input WHERE reads > 5", FormatCSV)
+	flowbasetest.FeedPort(sql.In(), batch)
+	results := flowbasetest.CollectPort[[]byte](sql.Out())
+	net.Run()
+
+	assertEqualValues(t, "sample,reads\ns1,10\n", string(results.Values()[0]))
+	script := readFile(t, filepath.Join(dir, "script.sql"))
+	if !strings.HasPrefix(script, "CREATE TABLE input AS SELECT * FROM read_parquet(") || strings.Contains(script, "INSERT") {
+		t.Errorf("Record batch not loaded from a Parquet file:\n%s", script)
+	}
+	parquet := &bytes.Buffer{}
+	if err := writeParquet(parquet, []*arrow.RecordBatch{batch}); err != nil {
+		t.Fatalf("Could not write Parquet: %v", err)
+	}
+	assertEqualValues(t, parquet.String(), readFile(t, filepath.Join(dir, "input.parquet")))
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func assertEqualValues(t *testing.T, expected any, actual any) {
+	t.Helper()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Values are not equal (Expected: %v, Actual: %v)", expected, actual)
+	}
+}
@@ -0,0 +1,307 @@
+package duckdb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/flowbase/flowbase/arrow"
+)
+
+// Minimal Parquet encoding of record batches, so that Arrow data can be
+// loaded into DuckDB with read_parquet, rather than row by row. Each record
+// batch is written as a row group, with a single uncompressed, PLAIN encoded
+// data page per column. The file metadata is encoded with the Thrift compact
+// protocol.
+
+const parquetMagic = "PAR1"
+
+// Parquet physical types, repetition types, converted types, encodings and
+// page types
+const (
+	parquetBoolean   = 0
+	parquetInt32     = 1
+	parquetInt64     = 2
+	parquetFloat     = 4
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetRequired = 0
+	parquetOptional = 1
+
+	parquetUTF8 = 0
+
+	parquetPlain = 0
+	parquetRLE   = 3
+
+	parquetDataPage = 0
+)
+
+// parquetTypes are the Parquet physical types of the columns of record
+// batches
+var parquetTypes = map[arrow.Type]int32{
+	arrow.Int32:   parquetInt32,
+	arrow.Int64:   parquetInt64,
+	arrow.Float32: parquetFloat,
+	arrow.Float64: parquetDouble,
+	arrow.Bool:    parquetBoolean,
+	arrow.String:  parquetByteArray,
+	arrow.Binary:  parquetByteArray,
+}
+
+// writeParquet writes the record batches batches, which all have the same
+// schema, as a Parquet file to w
+func writeParquet(w io.Writer, batches []*arrow.RecordBatch) error {
+	schema := batches[0].Schema()
+	schemaElems := []thriftValue{thriftStruct{
+		{4, thriftBinary("schema")},
+		{5, thriftI32(len(schema.Fields))},
+	}}
+	for _, f := range schema.Fields {
+		typ, ok := parquetTypes[f.Type]
+		if !ok {
+			return fmt.Errorf("unsupported type of column %s: %s", f.Name, f.Type)
+		}
+		repetition := parquetRequired
+		if f.Nullable {
+			repetition = parquetOptional
+		}
+		elem := thriftStruct{
+			{1, thriftI32(typ)},
+			{3, thriftI32(repetition)},
+			{4, thriftBinary(f.Name)},
+		}
+		if f.Type == arrow.String {
+			elem = append(elem, thriftField{6, thriftI32(parquetUTF8)})
+		}
+		schemaElems = append(schemaElems, elem)
+	}
+
+	buf := []byte(parquetMagic)
+	rowGroups := []thriftValue{}
+	numRows := 0
+	for _, batch := range batches {
+		if batch.NumRows() == 0 {
+			continue
+		}
+		chunks := []thriftValue{}
+		groupStart := len(buf)
+		for i, f := range schema.Fields {
+			chunkStart := len(buf)
+			page := parquetPage(batch.Column(i), f.Nullable)
+			header := thriftStruct{
+				{1, thriftI32(parquetDataPage)},
+				{2, thriftI32(len(page))},
+				{3, thriftI32(len(page))},
+				{5, thriftStruct{
+					{1, thriftI32(batch.NumRows())},
+					{2, thriftI32(parquetPlain)},
+					{3, thriftI32(parquetRLE)},
+					{4, thriftI32(parquetRLE)},
+				}},
+			}
+			buf = header.appendThrift(buf)
+			buf = append(buf, page...)
+			chunkSize := len(buf) - chunkStart
+			chunks = append(chunks, thriftStruct{
+				{2, thriftI64(chunkStart)},
+				{3, thriftStruct{
+					{1, thriftI32(parquetTypes[f.Type])},
+					{2, thriftList{thriftI32(parquetPlain), thriftI32(parquetRLE)}},
+					{3, thriftList{thriftBinary(f.Name)}},
+					{4, thriftI32(0)}, // Uncompressed
+					{5, thriftI64(batch.NumRows())},
+					{6, thriftI64(chunkSize)},
+					{7, thriftI64(chunkSize)},
+					{9, thriftI64(chunkStart)},
+				}},
+			})
+		}
+		rowGroups = append(rowGroups, thriftStruct{
+			{1, thriftList(chunks)},
+			{2, thriftI64(len(buf) - groupStart)},
+			{3, thriftI64(batch.NumRows())},
+		})
+		numRows += batch.NumRows()
+	}
+
+	metadata := thriftStruct{
+		{1, thriftI32(1)},
+		{2, thriftList(schemaElems)},
+		{3, thriftI64(numRows)},
+		{4, thriftList(rowGroups)},
+		{6, thriftBinary("flowbase")},
+	}.appendThrift(nil)
+	buf = append(buf, metadata...)
+	buf = appendUint32(buf, uint32(len(metadata)))
+	buf = append(buf, parquetMagic...)
+	_, err := w.Write(buf)
+	return err
+}
+
+// parquetPage returns the data of a PLAIN encoded data page with the values
+// of col, preceded by the definition levels telling which values are null,
+// if the column is nullable. Null values are left out of the values.
+func parquetPage(col *arrow.Array, nullable bool) []byte {
+	page := []byte{}
+	if nullable {
+		levels := parquetDefinitionLevels(col)
+		page = appendUint32(page, uint32(len(levels)))
+		page = append(page, levels...)
+	}
+	var bits byte
+	numBits := 0
+	for i := 0; i < col.Len(); i++ {
+		if col.IsNull(i) {
+			continue
+		}
+		switch col.Type() {
+		case arrow.Int32:
+			page = appendUint32(page, uint32(col.Int32(i)))
+		case arrow.Int64:
+			page = appendUint64(page, uint64(col.Int64(i)))
+		case arrow.Float32:
+			page = appendUint32(page, math.Float32bits(col.Float32(i)))
+		case arrow.Float64:
+			page = appendUint64(page, math.Float64bits(col.Float64(i)))
+		case arrow.Bool:
+			// Bit-packed, with the first value in the least significant bit
+			if col.Bool(i) {
+				bits |= 1 << numBits
+			}
+			if numBits++; numBits == 8 {
+				page = append(page, bits)
+				bits, numBits = 0, 0
+			}
+		case arrow.String, arrow.Binary:
+			value := col.Bytes(i)
+			page = appendUint32(page, uint32(len(value)))
+			page = append(page, value...)
+		}
+	}
+	if numBits > 0 {
+		page = append(page, bits)
+	}
+	return page
+}
+
+// parquetDefinitionLevels returns the definition levels of the values of
+// col, 0 for null values, and 1 for others, in the RLE encoding with a bit
+// width of 1, as runs of equal levels
+func parquetDefinitionLevels(col *arrow.Array) []byte {
+	levels := []byte{}
+	for i := 0; i < col.Len(); {
+		level := byte(1)
+		if col.IsNull(i) {
+			level = 0
+		}
+		run := 1
+		for i+run < col.Len() && col.IsNull(i+run) == (level == 0) {
+			run++
+		}
+		levels = appendUvarint(levels, uint64(run)<<1)
+		levels = append(levels, level)
+		i += run
+	}
+	return levels
+}
+
+// ------------------------------------------------------------------------
+// Thrift compact protocol
+// ------------------------------------------------------------------------
+
+// thriftValue is a value which can be encoded with the Thrift compact
+// protocol
+type thriftValue interface {
+	appendThrift(buf []byte) []byte
+	// thriftType is the compact protocol type of the value
+	thriftType() byte
+}
+
+type (
+	thriftI32    int32
+	thriftI64    int64
+	thriftBinary string
+	thriftList   []thriftValue
+	// thriftStruct is a struct, with its fields in order of field ID
+	thriftStruct []thriftField
+)
+
+type thriftField struct {
+	id    int16
+	value thriftValue
+}
+
+func (v thriftI32) thriftType() byte    { return 5 }
+func (v thriftI64) thriftType() byte    { return 6 }
+func (v thriftBinary) thriftType() byte { return 8 }
+func (v thriftList) thriftType() byte   { return 9 }
+func (v thriftStruct) thriftType() byte { return 12 }
+
+func (v thriftI32) appendThrift(buf []byte) []byte {
+	return appendVarint(buf, int64(v))
+}
+
+func (v thriftI64) appendThrift(buf []byte) []byte {
+	return appendVarint(buf, int64(v))
+}
+
+func (v thriftBinary) appendThrift(buf []byte) []byte {
+	buf = appendUvarint(buf, uint64(len(v)))
+	return append(buf, v...)
+}
+
+// appendThrift appends the list, whose elements all have the same type, and
+// which is a list of structs if it is empty
+func (v thriftList) appendThrift(buf []byte) []byte {
+	elemType := thriftStruct{}.thriftType()
+	if len(v) > 0 {
+		elemType = v[0].thriftType()
+	}
+	if len(v) < 15 {
+		buf = append(buf, byte(len(v))<<4|elemType)
+	} else {
+		buf = append(buf, 0xf0|elemType)
+		buf = appendUvarint(buf, uint64(len(v)))
+	}
+	for _, elem := range v {
+		buf = elem.appendThrift(buf)
+	}
+	return buf
+}
+
+func (v thriftStruct) appendThrift(buf []byte) []byte {
+	lastID := int16(0)
+	for _, f := range v {
+		if delta := f.id - lastID; delta > 0 && delta <= 15 {
+			buf = append(buf, byte(delta)<<4|f.value.thriftType())
+		} else {
+			buf = append(buf, f.value.thriftType())
+			buf = appendVarint(buf, int64(f.id))
+		}
+		buf = f.value.appendThrift(buf)
+		lastID = f.id
+	}
+	return append(buf, 0)
+}
+
+func appendUint32(buf []byte, v uint32) []byte {
+	return append(buf, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendUint64(buf []byte, v uint64) []byte {
+	return appendUint32(appendUint32(buf, uint32(v)), uint32(v>>32))
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	tmp := [binary.MaxVarintLen64]byte{}
+	return append(buf, tmp[:binary.PutUvarint(tmp[:], v)]...)
+}
+
+// appendVarint appends v as a zigzag encoded varint, as used by the Thrift
+// compact protocol
+func appendVarint(buf []byte, v int64) []byte {
+	tmp := [binary.MaxVarintLen64]byte{}
+	return append(buf, tmp[:binary.PutVarint(tmp[:], v)]...)
+}
@@ -0,0 +1,149 @@
+package duckdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/flowbase/flowbase/arrow"
+)
+
+// thriftReader decodes Thrift compact protocol values, with structs decoded
+// into maps by field ID, and integers into int64s
+type thriftReader struct {
+	buf []byte
+	pos int
+}
+
+func (r *thriftReader) byte() byte {
+	b := r.buf[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.buf[r.pos:])
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) value(typ byte) any {
+	switch typ {
+	case 5, 6:
+		v, n := binary.Varint(r.buf[r.pos:])
+		r.pos += n
+		return v
+	case 8:
+		n := int(r.uvarint())
+		r.pos += n
+		return string(r.buf[r.pos-n : r.pos])
+	case 9:
+		header := r.byte()
+		size := int(header >> 4)
+		if size == 15 {
+			size = int(r.uvarint())
+		}
+		list := []any{}
+		for i := 0; i < size; i++ {
+			list = append(list, r.value(header&0xf))
+		}
+		return list
+	case 12:
+		fields := map[int16]any{}
+		id := int16(0)
+		for header := r.byte(); header != 0; header = r.byte() {
+			if header>>4 == 0 {
+				v, n := binary.Varint(r.buf[r.pos:])
+				r.pos += n
+				id = int16(v)
+			} else {
+				id += int16(header >> 4)
+			}
+			fields[id] = r.value(header & 0xf)
+		}
+		return fields
+	}
+	panic("unsupported Thrift type")
+}
+
+func TestWriteParquet(t *testing.T) {
+	schema := arrow.NewSchema(
+		arrow.Field{Name: "name", Type: arrow.String},
+		arrow.Field{Name: "score", Type: arrow.Float64, Nullable: true},
+		arrow.Field{Name: "pass", Type: arrow.Bool},
+		arrow.Field{Name: "reads", Type: arrow.Int32, Nullable: true},
+	)
+	batches := []*arrow.RecordBatch{}
+	for _, rows := range [][]map[string]any{
+		{
+			{"name": "a", "score": 0.5, "pass": true, "reads": 10},
+			{"name": "bc", "pass": false},
+			{"name": "", "score": 1.5, "pass": true},
+		},
+		{},
+		{
+			{"name": "d", "pass": false, "reads": -1},
+		},
+	} {
+		batch, err := arrow.NewRecordBatchFromRows(schema, rows)
+		if err != nil {
+			t.Fatalf("Could not create record batch: %v", err)
+		}
+		batches = append(batches, batch)
+	}
+	buf := &bytes.Buffer{}
+	if err := writeParquet(buf, batches); err != nil {
+		t.Fatalf("Could not write Parquet: %v", err)
+	}
+	data := buf.Bytes()
+
+	assertEqualValues(t, "PAR1", string(data[:4]))
+	assertEqualValues(t, "PAR1", string(data[len(data)-4:]))
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	r := &thriftReader{buf: data, pos: len(data) - 8 - footerLen}
+	metadata := r.value(12).(map[int16]any)
+	assertEqualValues(t, len(data)-8, r.pos)
+
+	assertEqualValues(t, int64(4), metadata[3])
+	assertEqualValues(t, []any{
+		map[int16]any{4: "schema", 5: int64(4)},
+		map[int16]any{1: int64(parquetByteArray), 3: int64(parquetRequired), 4: "name", 6: int64(parquetUTF8)},
+		map[int16]any{1: int64(parquetDouble), 3: int64(parquetOptional), 4: "score"},
+		map[int16]any{1: int64(parquetBoolean), 3: int64(parquetRequired), 4: "pass"},
+		map[int16]any{1: int64(parquetInt32), 3: int64(parquetOptional), 4: "reads"},
+	}, metadata[2])
+
+	// The empty record batch has no row group
+	rowGroups := metadata[4].([]any)
+	assertEqualValues(t, 2, len(rowGroups))
+	pages := [][]byte{}
+	for _, rowGroup := range rowGroups {
+		for _, chunk := range rowGroup.(map[int16]any)[1].([]any) {
+			columnMetadata := chunk.(map[int16]any)[3].(map[int16]any)
+			r := &thriftReader{buf: data, pos: int(columnMetadata[9].(int64))}
+			header := r.value(12).(map[int16]any)
+			assertEqualValues(t, int64(parquetDataPage), header[1])
+			assertEqualValues(t, columnMetadata[5], header[5].(map[int16]any)[1])
+			pageLen := int(header[2].(int64))
+			assertEqualValues(t, columnMetadata[6], int64(r.pos+pageLen)-columnMetadata[9].(int64))
+			pages = append(pages, data[r.pos:r.pos+pageLen])
+		}
+	}
+	assertEqualValues(t, [][]byte{
+		// name
+		{1, 0, 0, 0, 'a', 2, 0, 0, 0, 'b', 'c', 0, 0, 0, 0},
+		// score, with the definition levels 1, 0, 1, as runs of one
+		{6, 0, 0, 0, 2, 1, 2, 0, 2, 1,
+			0, 0, 0, 0, 0, 0, 0xe0, 0x3f,
+			0, 0, 0, 0, 0, 0, 0xf8, 0x3f},
+		// pass
+		{0x5},
+		// reads, with the definition levels 1, 0, 0
+		{4, 0, 0, 0, 2, 1, 4, 0, 10, 0, 0, 0},
+		// The second row group, with a null score
+		{1, 0, 0, 0, 'd'},
+		{2, 0, 0, 0, 2, 0},
+		{0x0},
+		{2, 0, 0, 0, 2, 1, 0xff, 0xff, 0xff, 0xff},
+	}, pages)
+}
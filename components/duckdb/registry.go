@@ -0,0 +1,49 @@
+package duckdb
+
+import (
+	fb "github.com/flowbase/flowbase"
+)
+
+// The SQLTransform component is registered, with its descriptor, so that it
+// can be used in graph files (see fb.NewNetworkFromGraph)
+func init() {
+	fb.RegisterComponent("SQLTransform", func(net *fb.Network, name string, metadata map[string]string) (fb.Node, error) {
+		outputFormat, inputFormat := FormatCSV, FormatCSV
+		var err error
+		if format, ok := metadata["format"]; ok {
+			if outputFormat, err = ParseFormat(format); err != nil {
+				return nil, err
+			}
+		}
+		if format, ok := metadata["inputformat"]; ok {
+			if inputFormat, err = ParseFormat(format); err != nil {
+				return nil, err
+			}
+		}
+		p := NewSQLTransform(net, name, metadata["query"], outputFormat)
+		p.InputFormat = inputFormat
+		return p, nil
+	})
+
+	fb.RegisterComponentInfo(fb.ComponentInfo{
+		Name:        "SQLTransform",
+		Description: "Runs the query metadata SQL query in DuckDB on the table in each packet, loaded as the table input, and sends on the result",
+		Version:     fb.Version,
+		InPorts:     []fb.PortSpec{{Name: "in", Type: "table", Description: "Record batches, or CSV, Parquet or Arrow IPC stream bytes"}},
+		OutPorts:    []fb.PortSpec{{Name: "out", Type: "table"}},
+		Params: []fb.ParamSpec{
+			{Name: "query", Type: "string", Description: "The SQL query, such as \"SELECT * FROM input WHERE score > 0.5\"", Required: true},
+			{Name: "format", Type: "string", Description: "The format of the results, csv, parquet or arrow. Defaults to csv"},
+			{Name: "inputformat", Type: "string", Description: "The format of packets with bytes, csv, parquet or arrow. Defaults to csv"},
+		},
+	})
+}
+
+// ComponentMetadata returns the query and formats of the process
+func (p *SQLTransform) ComponentMetadata() map[string]string {
+	return map[string]string{
+		"query":       p.query,
+		"format":      string(p.outputFormat),
+		"inputformat": string(p.InputFormat),
+	}
+}
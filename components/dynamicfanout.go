@@ -0,0 +1,160 @@
+package components
+
+import (
+	"sync"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// BranchFactory builds the processes of a branch of a DynamicFanOut, for the
+// key key, in the network net, and returns the in-port the packets for the
+// key should be sent to, and the out-port whose packets should be sent on by
+// the DynamicFanOut, or nil if the branch doesn't send anything
+type BranchFactory func(net *fb.Network, key string) (in *fb.InPort, out *fb.OutPort)
+
+// DynamicFanOut routes the packets it receives to branches which are created
+// on demand, one per distinct value of a tag, such as one per sample.
+// Each branch is built by a BranchFactory in a network of its own, named
+// "<process name>/<key>", which is run as soon as the first packet for the
+// key arrives. The packets sent by the branches are all sent on the out-port
+// of the DynamicFanOut. A branch is closed when the input stream ends, or,
+// if Grouped is set, as soon as a packet with another key arrives.
+type DynamicFanOut struct {
+	fb.BaseProcess
+	tag     string
+	factory BranchFactory
+	// Grouped tells that the packets for each key arrive together, so that
+	// the substream for a key has ended when a packet for another key
+	// arrives, and its branch can be closed right away. Should the key
+	// appear again later, a new branch is started for it.
+	Grouped bool
+}
+
+// NewDynamicFanOut returns a new DynamicFanOut process, creating branches
+// with factory, per value of the tag tag
+func NewDynamicFanOut(net *fb.Network, name string, tag string, factory BranchFactory) *DynamicFanOut {
+	p := &DynamicFanOut{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		tag:         tag,
+		factory:     factory,
+	}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *DynamicFanOut) In() *fb.InPort { return p.InPort("in") }
+
+// Out returns the out-port, on which the packets sent by all branches are
+// sent
+func (p *DynamicFanOut) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Run routes the incoming packets to their branches, and waits for all
+// branches to finish
+func (p *DynamicFanOut) Run() {
+	defer p.CloseOutPorts()
+
+	branches := map[string]chan *fb.Packet{}
+	running := &sync.WaitGroup{}
+	lastKey := ""
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		key := ip.Tag(p.tag)
+		if p.Grouped && key != lastKey {
+			if branch, ok := branches[lastKey]; ok {
+				close(branch)
+				delete(branches, lastKey)
+			}
+		}
+		lastKey = key
+		branch, ok := branches[key]
+		if !ok {
+			branch = p.startBranch(key, running)
+			branches[key] = branch
+		}
+		branch <- ip
+	}
+	for _, branch := range branches {
+		close(branch)
+	}
+	running.Wait()
+}
+
+// startBranch builds and starts the branch for key, and returns the channel
+// on which packets for it should be sent
+func (p *DynamicFanOut) startBranch(key string, running *sync.WaitGroup) chan *fb.Packet {
+	p.Auditf("Starting branch for %s=%s", p.tag, key)
+	net := fb.NewNetwork(fb.ProcPath(p.Name(), key))
+	if p.Network() != nil {
+		net.SetRunID(p.Network().RunID())
+		net.SetClock(p.Network().Clock())
+	}
+
+	packets := make(chan *fb.Packet, fb.BUFSIZE)
+	feeder := newBranchFeeder(net, "feeder", packets)
+	in, out := p.factory(net, key)
+	in.From(feeder.Out())
+	if out != nil {
+		fwd := newBranchForwarder(net, "forwarder", p.Out())
+		fwd.In().From(out)
+	}
+
+	running.Add(1)
+	go func() {
+		defer running.Done()
+		net.Run()
+		p.Auditf("Finished branch for %s=%s", p.tag, key)
+	}()
+	return packets
+}
+
+// branchFeeder sends the packets routed to a branch into its network
+type branchFeeder struct {
+	fb.BaseProcess
+	packets chan *fb.Packet
+}
+
+func newBranchFeeder(net *fb.Network, name string, packets chan *fb.Packet) *branchFeeder {
+	p := &branchFeeder{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		packets:     packets,
+	}
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+func (p *branchFeeder) Out() *fb.OutPort { return p.OutPort("out") }
+
+func (p *branchFeeder) Run() {
+	defer p.CloseOutPorts()
+	for ip := range p.packets {
+		p.Out().SendPacket(ip)
+	}
+}
+
+// branchForwarder sends the packets sent by a branch on the out-port of the
+// DynamicFanOut
+type branchForwarder struct {
+	fb.BaseProcess
+	dst *fb.OutPort
+}
+
+func newBranchForwarder(net *fb.Network, name string, dst *fb.OutPort) *branchForwarder {
+	p := &branchForwarder{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		dst:         dst,
+	}
+	p.InitInPort(p, "in")
+	net.AddProc(p)
+	return p
+}
+
+func (p *branchForwarder) In() *fb.InPort { return p.InPort("in") }
+
+func (p *branchForwarder) Run() {
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		p.dst.SendPacket(ip)
+	}
+}
@@ -0,0 +1,45 @@
+package components
+
+import (
+	"sort"
+	"sync"
+	"testing"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+func TestDynamicFanOut(t *testing.T) {
+	initTestLogs()
+	net := fb.NewNetwork("TestDynamicFanOut")
+
+	ips := []*fb.Packet{}
+	for _, s := range []string{"a1", "a2", "b1", "c1", "c2"} {
+		ips = append(ips, taggedPacket(s, map[string]string{"sample": s[:1]}))
+	}
+	src := newSliceSource(net, "src", ips...)
+
+	keys := []string{}
+	mx := sync.Mutex{}
+	fan := NewDynamicFanOut(net, "fan", "sample", func(branchNet *fb.Network, key string) (*fb.InPort, *fb.OutPort) {
+		mx.Lock()
+		keys = append(keys, key)
+		mx.Unlock()
+		d := NewDelay(branchNet, "delay", 0)
+		return d.In(), d.Out()
+	})
+	fan.Grouped = true
+	fan.In().From(src.Out())
+
+	out := newCollector(net, "out")
+	out.In().From(fan.Out())
+
+	net.Run()
+
+	assertEqualValues(t, []string{"a", "b", "c"}, keys)
+	data := []string{}
+	for _, d := range out.data() {
+		data = append(data, d.(string))
+	}
+	sort.Strings(data)
+	assertEqualValues(t, []string{"a1", "a2", "b1", "c1", "c2"}, data)
+}
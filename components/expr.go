@@ -0,0 +1,243 @@
+package components
+
+import (
+	"encoding/json"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// packetExpr is an expression in the jq-like language of JSONTransform,
+// evaluated on the data of packets, with their tags available as the
+// variable $tags, so that processes can be configured with expressions in
+// graph files, rather than Go functions
+type packetExpr struct {
+	src  string
+	expr jsonExpr
+	vars jsonExprVars
+}
+
+// mustCompilePacketExpr compiles the expression src of the process named
+// name, and fails if it is invalid
+func mustCompilePacketExpr(name string, src string) *packetExpr {
+	vars := jsonExprVars{"tags": nil}
+	expr, err := compileJSONExprVars(src, vars)
+	if err != nil {
+		fb.Failf("Process (%s) got invalid expression (%s): %v", name, src, err)
+	}
+	return &packetExpr{src: src, expr: expr, vars: vars}
+}
+
+// eval returns the outputs of the expression for the packet ip, and whether
+// its data was encoded JSON (see jsonValue)
+func (e *packetExpr) eval(ip *fb.Packet) ([]any, bool) {
+	v, encoded := jsonValue(ip)
+	tags := map[string]any{}
+	for k, tag := range ip.Tags() {
+		tags[k] = tag
+	}
+	e.vars["tags"] = tags
+	outs, err := e.expr(v)
+	if err != nil {
+		ip.Failf("Could not evaluate expression (%s): %v", e.src, err)
+	}
+	return outs, encoded
+}
+
+// evalSingle returns the single output of the expression for the packet ip,
+// and whether its data was encoded JSON
+func (e *packetExpr) evalSingle(ip *fb.Packet) (any, bool) {
+	outs, encoded := e.eval(ip)
+	if len(outs) != 1 {
+		ip.Failf("Expression (%s) returned %d values, instead of one", e.src, len(outs))
+	}
+	return outs[0], encoded
+}
+
+// ------------------------------------------------------------------------
+// Filter
+// ------------------------------------------------------------------------
+
+// Filter sends on the packets for which an expression, in the jq-like
+// language of JSONTransform, returns a true value, such as
+// `.score > 0.5 and $tags.sample != "control"`, where $tags are the tags of
+// the packet, and discards the rest
+type Filter struct {
+	fb.BaseProcess
+	expr *packetExpr
+}
+
+// NewFilter returns a new Filter process, filtering on the expression expr
+func NewFilter(net *fb.Network, name string, expr string) *Filter {
+	p := &Filter{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		expr:        mustCompilePacketExpr(name, expr),
+	}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *Filter) In() *fb.InPort { return p.InPort("in") }
+
+// Out returns the out-port
+func (p *Filter) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Run runs the Filter process
+func (p *Filter) Run() {
+	defer p.CloseOutPorts()
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		outs, _ := p.expr.eval(ip)
+		for _, out := range outs {
+			if truthy(out) {
+				p.Out().SendPacket(ip)
+				break
+			}
+		}
+	}
+}
+
+// ------------------------------------------------------------------------
+// Map
+// ------------------------------------------------------------------------
+
+// Map replaces the data of packets with the value of an expression, in the
+// jq-like language of JSONTransform, such as `{id, total: .price * .count}`,
+// and sets tags to the values of expressions (see SetTag), where $tags are
+// the tags of the packet. Expressions must return one value per packet. As
+// for JSONTransform, packets with encoded JSON data are sent with the new
+// data encoded as JSON.
+type Map struct {
+	fb.BaseProcess
+	expr *packetExpr
+	tags map[string]*packetExpr
+}
+
+// NewMap returns a new Map process, replacing the data of packets with the
+// value of the expression expr, or keeping it if expr is empty
+func NewMap(net *fb.Network, name string, expr string) *Map {
+	p := &Map{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		tags:        map[string]*packetExpr{},
+	}
+	if expr != "" {
+		p.expr = mustCompilePacketExpr(name, expr)
+	}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// SetTag makes the process set the tag tag of packets to the value of the
+// expression expr, evaluated on the packets as received. Strings are used as
+// is, and other values encoded as JSON.
+func (p *Map) SetTag(tag string, expr string) {
+	p.tags[tag] = mustCompilePacketExpr(p.Name(), expr)
+}
+
+// In returns the in-port
+func (p *Map) In() *fb.InPort { return p.InPort("in") }
+
+// Out returns the out-port
+func (p *Map) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Run runs the Map process
+func (p *Map) Run() {
+	defer p.CloseOutPorts()
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		tags := map[string]string{}
+		for tag, expr := range p.tags {
+			v, _ := expr.evalSingle(ip)
+			if s, ok := v.(string); ok {
+				tags[tag] = s
+				continue
+			}
+			b, err := json.Marshal(v)
+			if err != nil {
+				ip.Failf("Could not encode value of tag %s as JSON: %v", tag, err)
+			}
+			tags[tag] = string(b)
+		}
+		if p.expr == nil {
+			ip.AddTags(tags)
+			p.Out().SendPacket(ip)
+			continue
+		}
+
+		data, encoded := p.expr.evalSingle(ip)
+		if encoded {
+			b, err := json.Marshal(data)
+			if err != nil {
+				ip.Failf("Could not encode output as JSON: %v", err)
+			}
+			data = b
+		}
+		newIP := fb.NewPacket(data)
+		newIP.AddTags(ip.Tags())
+		newIP.AddTags(tags)
+		p.Out().SendPacket(newIP)
+	}
+}
+
+// ------------------------------------------------------------------------
+// Router
+// ------------------------------------------------------------------------
+
+// Router sends packets to the out-ports named by an expression, in the
+// jq-like language of JSONTransform, such as
+// `if .size > 1000000 then "large" else "small" end`, or `$tags.kind`,
+// where $tags are the tags of the packet. A packet is sent to each port named
+// by the outputs of the expression, and dropped if there is none. Outputs
+// which are not the names of out-ports of the process make it fail.
+type Router struct {
+	fb.BaseProcess
+	expr *packetExpr
+}
+
+// NewRouter returns a new Router process, with the out-ports ports, routing
+// packets with the expression expr
+func NewRouter(net *fb.Network, name string, expr string, ports ...string) *Router {
+	p := &Router{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		expr:        mustCompilePacketExpr(name, expr),
+	}
+	p.InitInPort(p, "in")
+	for _, port := range ports {
+		p.InitOutPort(p, port)
+	}
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *Router) In() *fb.InPort { return p.InPort("in") }
+
+// Out returns the out-port named port
+func (p *Router) Out(port string) *fb.OutPort { return p.OutPort(port) }
+
+// Run runs the Router process
+func (p *Router) Run() {
+	defer p.CloseOutPorts()
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		outs, _ := p.expr.eval(ip)
+		ports := []string{}
+		for _, out := range outs {
+			port, ok := out.(string)
+			if _, exists := p.OutPorts()[port]; !ok || !exists {
+				ip.Failf("Expression (%s) returned %v, which is not an out-port of process (%s)", p.expr.src, out, p.Name())
+			}
+			ports = append(ports, port)
+		}
+		// Packets sent to several ports are copied, since they are owned by
+		// the receiving processes
+		for i, port := range ports {
+			sent := ip
+			if i < len(ports)-1 {
+				sent = ip.Clone()
+			}
+			p.Out(port).SendPacket(sent)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package components
+
+import (
+	"testing"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+func TestFilterMapRouter(t *testing.T) {
+	initTestLogs()
+	net := fb.NewNetwork("test_expr")
+	src := newSliceSource(net, "src",
+		taggedPacket([]byte(`{"id": "a", "price": 2, "count": 3}`), map[string]string{"sample": "s1"}),
+		taggedPacket([]byte(`{"id": "b", "price": 10, "count": 0}`), map[string]string{"sample": "s2"}),
+		taggedPacket(map[string]any{"id": "c", "price": 50, "count": 1}, map[string]string{"sample": "control"}),
+	)
+	filter := NewFilter(net, "filter", `.count > 0 and $tags.sample != "s2"`)
+	filter.In().From(src.Out())
+	mapper := NewMap(net, "map", `{id, total: .price * .count}`)
+	mapper.SetTag("key", `$tags.sample + "-" + .id`)
+	mapper.In().From(filter.Out())
+	router := NewRouter(net, "router", `if $tags.sample == "control" then "control" else "samples" end`, "samples", "control")
+	router.In().From(mapper.Out())
+	samples := newCollector(net, "samples")
+	samples.In().From(router.Out("samples"))
+	controls := newCollector(net, "controls")
+	controls.In().From(router.Out("control"))
+	net.Run()
+
+	assertEqualValues(t, []any{[]byte(`{"id":"a","total":6}`)}, samples.data())
+	assertEqualValues(t, map[string]string{"sample": "s1", "key": "s1-a"}, samples.ips[0].Tags())
+	assertEqualValues(t, []any{map[string]any{"id": "c", "total": 50.0}}, controls.data())
+	assertEqualValues(t, "control-c", controls.ips[0].Tag("key"))
+}
+
+func TestExprComponentsFromGraph(t *testing.T) {
+	initTestLogs()
+	net := fb.NewNetwork("test_expr_graph")
+	newRouter, _ := fb.LookupComponent("Router")
+	node, err := newRouter(net, "router", map[string]string{"expr": `$tags.kind`, "ports": "a b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqualValues(t, map[string]string{"expr": `$tags.kind`, "ports": "a b"}, node.(*Router).ComponentMetadata())
+	newMap, _ := fb.LookupComponent("Map")
+	node, err = newMap(net, "map", map[string]string{"tag.n": `.n + 1`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqualValues(t, map[string]string{"tag.n": `.n + 1`}, node.(*Map).ComponentMetadata())
+}
@@ -0,0 +1,161 @@
+package frame
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	goimage "image"
+	"image/png"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// FFmpegPath is the ffmpeg executable used to decode and encode video, which
+// is looked up in the PATH by default
+var FFmpegPath = "ffmpeg"
+
+// ------------------------------------------------------------------------
+// FFmpegSource
+// ------------------------------------------------------------------------
+
+// FFmpegSource reads the frames of any video input supported by ffmpeg, such
+// as video files and network streams, by running ffmpeg as a separate
+// process, which sends the decoded frames as a stream of PNG images
+type FFmpegSource struct {
+	cmd    *exec.Cmd
+	stdout io.ReadCloser
+	r      *bufio.Reader
+	stderr *bytes.Buffer
+	next   int
+	closed bool
+}
+
+// NewVideoFileSource returns a new FFmpegSource, reading the frames of the
+// video file at path
+func NewVideoFileSource(path string) (*FFmpegSource, error) {
+	return NewFFmpegSource(path)
+}
+
+// NewRTSPSource returns a new FFmpegSource, reading the frames of the RTSP
+// stream at url, such as "rtsp://camera.local:554/stream", over TCP
+func NewRTSPSource(url string) (*FFmpegSource, error) {
+	return NewFFmpegSource(url, "-rtsp_transport", "tcp")
+}
+
+// NewFFmpegSource returns a new FFmpegSource, reading the frames of the
+// ffmpeg input input, with the ffmpeg input options inputArgs
+func NewFFmpegSource(input string, inputArgs ...string) (*FFmpegSource, error) {
+	args := append([]string{"-loglevel", "error"}, inputArgs...)
+	args = append(args, "-i", input, "-f", "image2pipe", "-vcodec", "png", "-")
+	cmd := exec.Command(FFmpegPath, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	src := &FFmpegSource{cmd: cmd, stdout: stdout, r: bufio.NewReader(stdout), stderr: &bytes.Buffer{}}
+	cmd.Stderr = src.stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("could not start ffmpeg: %v", err)
+	}
+	return src, nil
+}
+
+// ReadFrame decodes and returns the next frame sent by ffmpeg
+func (s *FFmpegSource) ReadFrame() (*Frame, error) {
+	if s.closed {
+		return nil, errClosed
+	}
+	if _, err := s.r.Peek(1); err == io.EOF {
+		if err := s.cmd.Wait(); err != nil {
+			return nil, fmt.Errorf("ffmpeg failed: %v: %s", err, strings.TrimSpace(s.stderr.String()))
+		}
+		s.closed = true
+		return nil, io.EOF
+	}
+	// The PNG decoder stops reading at the end of the image, so the next one
+	// can be decoded from the same reader
+	img, err := png.Decode(s.r)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode frame %d: %v", s.next, err)
+	}
+	frame := &Frame{Image: img, Index: s.next}
+	s.next++
+	return frame, nil
+}
+
+// Close stops ffmpeg, if it is still running
+func (s *FFmpegSource) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	s.stdout.Close()
+	s.cmd.Process.Kill()
+	s.cmd.Wait()
+	return nil
+}
+
+// ------------------------------------------------------------------------
+// FFmpegSink
+// ------------------------------------------------------------------------
+
+// FFmpegSink writes frames to any video output supported by ffmpeg, such as
+// video files, by running ffmpeg as a separate process, to which the frames
+// are sent as a stream of PNG images
+type FFmpegSink struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stderr *bytes.Buffer
+	closed bool
+}
+
+// NewVideoFileSink returns a new FFmpegSink, writing the frames to the video
+// file at path, with fps frames per second, in the format given by the file
+// extension
+func NewVideoFileSink(path string, fps float64) (*FFmpegSink, error) {
+	return NewFFmpegSink(path, fps)
+}
+
+// NewFFmpegSink returns a new FFmpegSink, writing the frames to the ffmpeg
+// output output, with fps frames per second and the ffmpeg output options
+// outputArgs
+func NewFFmpegSink(output string, fps float64, outputArgs ...string) (*FFmpegSink, error) {
+	args := []string{"-loglevel", "error", "-y", "-f", "image2pipe", "-framerate", strconv.FormatFloat(fps, 'f', -1, 64), "-i", "-"}
+	args = append(args, outputArgs...)
+	args = append(args, output)
+	cmd := exec.Command(FFmpegPath, args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	sink := &FFmpegSink{cmd: cmd, stdin: stdin, stderr: &bytes.Buffer{}}
+	cmd.Stderr = sink.stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("could not start ffmpeg: %v", err)
+	}
+	return sink, nil
+}
+
+// WriteFrame sends img to ffmpeg, as the next frame
+func (s *FFmpegSink) WriteFrame(img goimage.Image) error {
+	if s.closed {
+		return errClosed
+	}
+	return png.Encode(s.stdin, img)
+}
+
+// Close ends the stream of frames, and waits for ffmpeg to finish writing
+// the output
+func (s *FFmpegSink) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	s.stdin.Close()
+	if err := s.cmd.Wait(); err != nil {
+		return fmt.Errorf("ffmpeg failed: %v: %s", err, strings.TrimSpace(s.stderr.String()))
+	}
+	return nil
+}
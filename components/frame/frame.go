@@ -0,0 +1,128 @@
+// Package frame contains an abstraction of sources and sinks of video
+// frames, such as video files, network streams and directories of images,
+// along with processes sending the frames of a FrameSource into a network
+// (Source), and writing the frames received to a FrameSink (Sink).
+//
+// Frames are sent between processes as packets with image.Image data, tagged
+// with their frame number, as "frame", so that they can be processed with the
+// components of the components/image package.
+package frame
+
+import (
+	"fmt"
+	goimage "image"
+	"io"
+	"strconv"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// Frame is a frame read from a FrameSource
+type Frame struct {
+	Image goimage.Image
+	// Index is the number of the frame in the source, starting from 0
+	Index int
+}
+
+// FrameSource is a source of frames, such as a video file or stream
+type FrameSource interface {
+	// ReadFrame returns the next frame, or io.EOF when there are no more
+	ReadFrame() (*Frame, error)
+	// Close releases the resources of the source
+	Close() error
+}
+
+// FrameSink is a destination of frames, such as a video file
+type FrameSink interface {
+	// WriteFrame writes the image img as the next frame
+	WriteFrame(img goimage.Image) error
+	// Close finishes writing, and releases the resources of the sink
+	Close() error
+}
+
+// ------------------------------------------------------------------------
+// Source
+// ------------------------------------------------------------------------
+
+// Source sends the frames read from a FrameSource, until there are no more
+type Source struct {
+	fb.BaseProcess
+	src FrameSource
+}
+
+// NewSource returns a new Source process, sending the frames of src
+func NewSource(net *fb.Network, name string, src FrameSource) *Source {
+	p := &Source{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		src:         src,
+	}
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// Out returns the out-port, on which the frames are sent
+func (p *Source) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Run runs the Source process
+func (p *Source) Run() {
+	defer p.CloseOutPorts()
+	defer p.src.Close()
+	for {
+		f, err := p.src.ReadFrame()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			p.Failf("Could not read frame: %v", err)
+		}
+		ip := fb.NewPacket(f.Image)
+		ip.AddTag("frame", strconv.Itoa(f.Index))
+		p.Out().SendPacket(ip)
+	}
+}
+
+// ------------------------------------------------------------------------
+// Sink
+// ------------------------------------------------------------------------
+
+// Sink writes the image packets it receives as frames to a FrameSink, in the
+// order they arrive, and closes it when the input stream ends
+type Sink struct {
+	fb.BaseProcess
+	dst FrameSink
+}
+
+// NewSink returns a new Sink process, writing frames to dst
+func NewSink(net *fb.Network, name string, dst FrameSink) *Sink {
+	p := &Sink{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		dst:         dst,
+	}
+	p.InitInPort(p, "in")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *Sink) In() *fb.InPort { return p.InPort("in") }
+
+// Run runs the Sink process
+func (p *Sink) Run() {
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		img, ok := ip.Data().(goimage.Image)
+		if !ok {
+			ip.Failf("Data (%v) of type %T is not an image", ip.Data(), ip.Data())
+		}
+		if err := p.dst.WriteFrame(img); err != nil {
+			p.Failf("Could not write frame: %v", err)
+		}
+	}
+	if err := p.dst.Close(); err != nil {
+		p.Failf("Could not close frame sink: %v", err)
+	}
+}
+
+// errClosed is returned when reading from or writing to a closed source or
+// sink
+var errClosed = fmt.Errorf("frame source or sink is closed")
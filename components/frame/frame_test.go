@@ -0,0 +1,97 @@
+package frame
+
+import (
+	goimage "image"
+	"image/color"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/flowbase/flowbase/flowbasetest"
+)
+
+func writeTestFrames(t *testing.T, dir string, n int) {
+	sink, err := NewImageSequenceSink(dir, "frame_%03d.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		img := goimage.NewGray(goimage.Rect(0, 0, 16, 16))
+		img.SetGray(0, 0, color.Gray{Y: uint8(i * 10)})
+		if err := sink.WriteFrame(img); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImageSequence(t *testing.T) {
+	inDir := t.TempDir()
+	outDir := filepath.Join(t.TempDir(), "out")
+	writeTestFrames(t, inDir, 3)
+
+	src, err := NewImageSequenceSource(inDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := NewImageSequenceSink(outDir, "copy_%d.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	net := flowbasetest.NewTestNetwork(t)
+	source := NewSource(net.Network, "source", src)
+	frames := flowbasetest.CollectPort[goimage.Image](source.Out())
+	sink := NewSink(net.Network, "sink", dst)
+	sink.In().From(source.Out())
+	net.Run()
+
+	ips := frames.Packets()
+	if len(ips) != 3 || ips[2].Tag("frame") != "2" {
+		t.Fatalf("Got wrong frames: %v", ips)
+	}
+	copied, err := filepath.Glob(filepath.Join(outDir, "copy_*.png"))
+	if err != nil || len(copied) != 3 {
+		t.Errorf("Expected 3 copied frames, got: %v (%v)", copied, err)
+	}
+}
+
+func TestFFmpegRoundTrip(t *testing.T) {
+	if _, err := exec.LookPath(FFmpegPath); err != nil {
+		t.Skip("ffmpeg not found")
+	}
+	dir := t.TempDir()
+	writeTestFrames(t, dir, 5)
+	video := filepath.Join(dir, "video.avi")
+
+	src, err := NewImageSequenceSource(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := NewFFmpegSink(video, 5, "-vcodec", "png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	net := flowbasetest.NewTestNetwork(t)
+	NewSink(net.Network, "sink", dst).In().From(NewSource(net.Network, "source", src).Out())
+	net.Run()
+
+	videoSrc, err := NewVideoFileSource(video)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer videoSrc.Close()
+	n := 0
+	for ; ; n++ {
+		if _, err := videoSrc.ReadFrame(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n != 5 {
+		t.Errorf("Expected 5 frames in video, got %d", n)
+	}
+}
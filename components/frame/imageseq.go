@@ -0,0 +1,116 @@
+package frame
+
+import (
+	"fmt"
+	goimage "image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	// Registers the image formats of image sequences
+	_ "image/gif"
+	_ "image/jpeg"
+)
+
+// imageExtensions are the file extensions of the images read by
+// ImageSequenceSource
+var imageExtensions = map[string]bool{".png": true, ".jpg": true, ".jpeg": true, ".gif": true}
+
+// ImageSequenceSource reads the frames of a directory of image files, in
+// lexical order of their names, such as frame_000001.png, frame_000002.png
+// and so on
+type ImageSequenceSource struct {
+	paths  []string
+	next   int
+	closed bool
+}
+
+// NewImageSequenceSource returns a new ImageSequenceSource, for the JPEG, PNG
+// and GIF images in the directory dir
+func NewImageSequenceSource(dir string) (*ImageSequenceSource, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	src := &ImageSequenceSource{}
+	for _, e := range entries {
+		if !e.IsDir() && imageExtensions[strings.ToLower(filepath.Ext(e.Name()))] {
+			src.paths = append(src.paths, filepath.Join(dir, e.Name()))
+		}
+	}
+	sort.Strings(src.paths)
+	return src, nil
+}
+
+// ReadFrame decodes and returns the next image
+func (s *ImageSequenceSource) ReadFrame() (*Frame, error) {
+	if s.closed {
+		return nil, errClosed
+	}
+	if s.next >= len(s.paths) {
+		return nil, io.EOF
+	}
+	path := s.paths[s.next]
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := goimage.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode %s: %v", path, err)
+	}
+	frame := &Frame{Image: img, Index: s.next}
+	s.next++
+	return frame, nil
+}
+
+// Close closes the source
+func (s *ImageSequenceSource) Close() error {
+	s.closed = true
+	return nil
+}
+
+// ImageSequenceSink writes frames as numbered PNG images to a directory
+type ImageSequenceSink struct {
+	dir     string
+	pattern string
+	next    int
+	closed  bool
+}
+
+// NewImageSequenceSink returns a new ImageSequenceSink, writing frames to
+// the directory dir, which is created if needed, with file names given by
+// the fmt pattern pattern and the frame number, such as "frame_%06d.png"
+func NewImageSequenceSink(dir string, pattern string) (*ImageSequenceSink, error) {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+	return &ImageSequenceSink{dir: dir, pattern: pattern}, nil
+}
+
+// WriteFrame writes img to the file for the next frame number
+func (s *ImageSequenceSink) WriteFrame(img goimage.Image) error {
+	if s.closed {
+		return errClosed
+	}
+	f, err := os.Create(filepath.Join(s.dir, fmt.Sprintf(s.pattern, s.next)))
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	s.next++
+	return f.Close()
+}
+
+// Close closes the sink
+func (s *ImageSequenceSink) Close() error {
+	s.closed = true
+	return nil
+}
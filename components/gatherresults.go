@@ -0,0 +1,148 @@
+package components
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// GatherResults is a sink that gathers the final outputs of a workflow into a
+// results directory, structured as <ResultsDir>/<RunID>/<category>/<file>,
+// with one in-port per category. Files are copied, or symlinked if Symlink is
+// set. A manifest.json file listing all gathered files, with their source
+// paths and tags, is written to the run directory when all in-ports are
+// closed. Packets may contain *FileIPs, or file paths as strings.
+type GatherResults struct {
+	fb.BaseProcess
+	resultsDir string
+	categories []string
+	// RunID is the name of the run directory. Defaults to the run ID of the
+	// network (see Network.RunID).
+	RunID string
+	// Symlink makes files be symlinked into the results directory instead of
+	// copied
+	Symlink bool
+}
+
+// ResultsManifestEntry describes one file gathered by GatherResults, in the
+// manifest file
+type ResultsManifestEntry struct {
+	Category string
+	Path     string
+	Source   string
+	Tags     map[string]string `json:",omitempty"`
+}
+
+// NewGatherResults returns a new GatherResults, gathering files into
+// resultsDir, with one in-port per category in categories
+func NewGatherResults(net *fb.Network, name string, resultsDir string, categories ...string) *GatherResults {
+	p := &GatherResults{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		resultsDir:  resultsDir,
+		categories:  categories,
+	}
+	for _, category := range categories {
+		p.InitInPort(p, category)
+	}
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port for the category category
+func (p *GatherResults) In(category string) *fb.InPort { return p.InPort(category) }
+
+// RunDir returns the directory into which the files of this run are gathered
+func (p *GatherResults) RunDir() string {
+	if p.RunID == "" {
+		p.RunID = p.Network().RunID()
+	}
+	return filepath.Join(p.resultsDir, p.RunID)
+}
+
+// Run runs the GatherResults process
+func (p *GatherResults) Run() {
+	runDir := p.RunDir()
+
+	type categoryPacket struct {
+		category string
+		ip       *fb.Packet
+	}
+	received := make(chan categoryPacket)
+	wg := &sync.WaitGroup{}
+	for _, category := range p.categories {
+		wg.Add(1)
+		go func(category string) {
+			defer wg.Done()
+			for ip := range recvChan(p.In(category)) {
+				received <- categoryPacket{category, ip}
+			}
+		}(category)
+	}
+	go func() {
+		wg.Wait()
+		close(received)
+	}()
+
+	manifest := []ResultsManifestEntry{}
+	for cp := range received {
+		srcPath := fmt.Sprint(cp.ip.Data())
+		if fip, ok := cp.ip.Data().(*fb.FileIP); ok {
+			srcPath = fip.Path()
+		}
+		dstPath := filepath.Join(runDir, cp.category, filepath.Base(srcPath))
+		if err := p.gather(srcPath, dstPath); err != nil {
+			p.Failf("Could not gather %s into %s: %v", srcPath, dstPath, err)
+		}
+		manifest = append(manifest, ResultsManifestEntry{
+			Category: cp.category,
+			Path:     dstPath,
+			Source:   srcPath,
+			Tags:     cp.ip.Tags(),
+		})
+	}
+
+	if err := os.MkdirAll(runDir, 0777); err != nil {
+		p.Failf("Could not create run directory %s: %v", runDir, err)
+	}
+	manifestJSON, err := json.MarshalIndent(manifest, "", "    ")
+	if err != nil {
+		p.Failf("Could not marshal manifest: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(runDir, "manifest.json"), manifestJSON, 0644); err != nil {
+		p.Failf("Could not write manifest: %v", err)
+	}
+}
+
+// gather copies or symlinks the file at srcPath to dstPath
+func (p *GatherResults) gather(srcPath string, dstPath string) error {
+	if err := os.MkdirAll(filepath.Dir(dstPath), 0777); err != nil {
+		return err
+	}
+	if p.Symlink {
+		absSrc, err := filepath.Abs(srcPath)
+		if err != nil {
+			return err
+		}
+		os.Remove(dstPath)
+		return os.Symlink(absSrc, dstPath)
+	}
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(dstPath)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
@@ -0,0 +1,41 @@
+package components
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+func TestGatherResults(t *testing.T) {
+	initTestLogs()
+
+	dir := t.TempDir()
+	for _, fileName := range []string{"a.txt", "b.txt"} {
+		err := os.WriteFile(filepath.Join(dir, fileName), []byte(fileName), 0644)
+		fb.Check(err)
+	}
+
+	net := fb.NewNetwork("TestGatherResults")
+	src := NewGlobSource(net, "src", filepath.Join(dir, "*.txt"))
+	gather := NewGatherResults(net, "gather", filepath.Join(dir, "results"), "texts")
+	gather.RunID = "run1"
+	gather.In("texts").From(src.Out())
+
+	net.Run()
+
+	content, err := os.ReadFile(filepath.Join(dir, "results", "run1", "texts", "b.txt"))
+	fb.Check(err)
+	assertEqualValues(t, "b.txt", string(content))
+
+	manifestJSON, err := os.ReadFile(filepath.Join(dir, "results", "run1", "manifest.json"))
+	fb.Check(err)
+	manifest := []ResultsManifestEntry{}
+	fb.Check(json.Unmarshal(manifestJSON, &manifest))
+	if len(manifest) != 2 {
+		t.Fatalf("Expected 2 manifest entries, got %d", len(manifest))
+	}
+	assertEqualValues(t, filepath.Join(dir, "a.txt"), manifest[0].Source)
+}
@@ -0,0 +1,90 @@
+package components
+
+import (
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// GlobSource expands one or more glob patterns (as supported by
+// filepath.Glob), and sends a *FileIP for each matching file on its out-port,
+// in lexical order. If a tag pattern is set with SetTagPattern, tags are
+// extracted from the paths, using the named groups of the pattern.
+type GlobSource struct {
+	fb.BaseProcess
+	globPatterns []string
+	tagPattern   *regexp.Regexp
+}
+
+// NewGlobSource returns a new GlobSource, expanding globPatterns
+func NewGlobSource(net *fb.Network, name string, globPatterns ...string) *GlobSource {
+	p := &GlobSource{
+		BaseProcess:  fb.NewBaseProcess(net, name),
+		globPatterns: globPatterns,
+	}
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// Out returns the out-port, on which the FileIPs are sent
+func (p *GlobSource) Out() *fb.OutPort { return p.OutPort("out") }
+
+// SetTagPattern sets a regular expression with named groups, which are used to
+// extract tags from the matched paths. For example, the pattern
+// `(?P<sample>[^/]+)_(?P<date>\d{8})\.fastq$` adds the tags "sample" and
+// "date". Paths not matching the pattern are sent without tags.
+func (p *GlobSource) SetTagPattern(pattern string) {
+	ptn, err := regexp.Compile(pattern)
+	if err != nil {
+		p.Failf("Could not compile tag pattern (%s): %v", pattern, err)
+	}
+	p.tagPattern = ptn
+}
+
+// Run runs the GlobSource process
+func (p *GlobSource) Run() {
+	defer p.CloseOutPorts()
+
+	paths := []string{}
+	seen := map[string]bool{}
+	for _, globPattern := range p.globPatterns {
+		matches, err := filepath.Glob(globPattern)
+		if err != nil {
+			p.Failf("Malformed glob pattern (%s): %v", globPattern, err)
+		}
+		for _, path := range matches {
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		ip := fb.NewPacket(fb.NewFileIP(path))
+		if p.tagPattern != nil {
+			ip.AddTags(extractTags(p.tagPattern, path))
+		}
+		p.Out().SendPacket(ip)
+	}
+}
+
+// extractTags returns the values of the named groups of ptn, matched against
+// s, keyed by the group names
+func extractTags(ptn *regexp.Regexp, s string) map[string]string {
+	tags := map[string]string{}
+	m := ptn.FindStringSubmatch(s)
+	if m == nil {
+		return tags
+	}
+	for i, groupName := range ptn.SubexpNames() {
+		if i > 0 && groupName != "" {
+			tags[groupName] = m[i]
+		}
+	}
+	return tags
+}
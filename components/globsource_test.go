@@ -0,0 +1,34 @@
+package components
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+func TestGlobSourceTags(t *testing.T) {
+	initTestLogs()
+
+	dir := t.TempDir()
+	for _, fileName := range []string{"s2_20220101.txt", "s1_20220102.txt", "other.csv"} {
+		err := os.WriteFile(filepath.Join(dir, fileName), []byte{}, 0644)
+		fb.Check(err)
+	}
+
+	net := fb.NewNetwork("TestGlobSource")
+	src := NewGlobSource(net, "src", filepath.Join(dir, "*.txt"))
+	src.SetTagPattern(`(?P<sample>s\d)_(?P<date>\d{8})\.txt$`)
+	out := newCollector(net, "out")
+	out.In().From(src.Out())
+
+	net.Run()
+
+	if len(out.ips) != 2 {
+		t.Fatalf("Expected 2 files, got %d", len(out.ips))
+	}
+	assertEqualValues(t, filepath.Join(dir, "s1_20220102.txt"), out.ips[0].Data().(*fb.FileIP).Path())
+	assertEqualValues(t, map[string]string{"sample": "s1", "date": "20220102"}, out.ips[0].Tags())
+	assertEqualValues(t, "s2", out.ips[1].Tag("sample"))
+}
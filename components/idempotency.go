@@ -0,0 +1,73 @@
+package components
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// IdempotencyKey sets the idempotency key of the packets it receives (see
+// fb.Packet.IdempotencyKey), for side-effecting sinks downstream to skip
+// duplicates with. The key is made from the values of the tags tags, or, if
+// no tags are given, is a SHA-256 hash of the data of the packet, so that the
+// same data gives the same key across runs. Packets which already have a key
+// keep it.
+type IdempotencyKey struct {
+	fb.BaseProcess
+	tags []string
+}
+
+// NewIdempotencyKey returns a new IdempotencyKey process, making keys from
+// the tags tags, or from the data of packets if no tags are given
+func NewIdempotencyKey(net *fb.Network, name string, tags ...string) *IdempotencyKey {
+	p := &IdempotencyKey{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		tags:        tags,
+	}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *IdempotencyKey) In() *fb.InPort { return p.InPort("in") }
+
+// Out returns the out-port
+func (p *IdempotencyKey) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Run runs the IdempotencyKey process
+func (p *IdempotencyKey) Run() {
+	defer p.CloseOutPorts()
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		if ip.IdempotencyKey() == "" {
+			ip.SetIdempotencyKey(p.key(ip))
+		}
+		p.Out().SendPacket(ip)
+	}
+}
+
+// key returns the idempotency key for the packet ip
+func (p *IdempotencyKey) key(ip *fb.Packet) string {
+	if len(p.tags) > 0 {
+		values := []string{}
+		for _, tag := range p.tags {
+			values = append(values, ip.Tag(tag))
+		}
+		return strings.Join(values, "/")
+	}
+	var data []byte
+	switch d := ip.Data().(type) {
+	case []byte:
+		data = d
+	case string:
+		data = []byte(d)
+	default:
+		data = []byte(fmt.Sprintf("%#v", d))
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,33 @@
+package components
+
+import (
+	"testing"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	initTestLogs()
+	net := fb.NewNetwork("TestIdempotencyKey")
+
+	keyed := fb.NewPacket("c")
+	keyed.SetIdempotencyKey("given")
+	src := newSliceSource(net, "src",
+		taggedPacket("a", map[string]string{"date": "2020-01-01", "id": "1"}),
+		taggedPacket("a", map[string]string{"date": "2020-01-02", "id": "1"}),
+		keyed)
+	byTags := NewIdempotencyKey(net, "bytags", "date", "id")
+	byTags.In().From(src.Out())
+	byData := NewIdempotencyKey(net, "bydata")
+	byData.In().From(byTags.Out())
+	out := newCollector(net, "out")
+	out.In().From(byData.Out())
+
+	net.Run()
+
+	keys := []string{}
+	for _, ip := range out.ips {
+		keys = append(keys, ip.IdempotencyKey())
+	}
+	assertEqualValues(t, []string{"2020-01-01/1", "2020-01-02/1", "given"}, keys)
+}
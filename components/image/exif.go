@@ -0,0 +1,223 @@
+package image
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// ------------------------------------------------------------------------
+// EXIFExtractor
+// ------------------------------------------------------------------------
+
+// EXIFExtractor reads the EXIF metadata of the JPEG files (*fb.FileIP) or
+// encoded JPEG images ([]byte) it receives, and sends them on, tagged with
+// the metadata fields, as "exif.<field>", such as "exif.Model". Files without
+// EXIF metadata are sent on without new tags.
+type EXIFExtractor struct {
+	fb.BaseProcess
+}
+
+// NewEXIFExtractor returns a new EXIFExtractor process
+func NewEXIFExtractor(net *fb.Network, name string) *EXIFExtractor {
+	p := &EXIFExtractor{
+		BaseProcess: fb.NewBaseProcess(net, name),
+	}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *EXIFExtractor) In() *fb.InPort { return p.InPort("in") }
+
+// Out returns the out-port
+func (p *EXIFExtractor) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Run runs the EXIFExtractor process
+func (p *EXIFExtractor) Run() {
+	defer p.CloseOutPorts()
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		r := openData(ip)
+		fields, err := ReadEXIF(r)
+		r.Close()
+		if err != nil {
+			ip.Failf("Could not read EXIF metadata: %v", err)
+		}
+		for name, value := range fields {
+			ip.AddTag("exif."+name, value)
+		}
+		p.Out().SendPacket(ip)
+	}
+}
+
+// ------------------------------------------------------------------------
+// EXIF parsing
+// ------------------------------------------------------------------------
+
+// exifFieldNames are the names of the EXIF fields read by ReadEXIF, by tag
+// number
+var exifFieldNames = map[uint16]string{
+	0x010F: "Make",
+	0x0110: "Model",
+	0x0112: "Orientation",
+	0x0131: "Software",
+	0x0132: "DateTime",
+	0x829A: "ExposureTime",
+	0x829D: "FNumber",
+	0x8827: "ISOSpeedRatings",
+	0x9003: "DateTimeOriginal",
+	0x920A: "FocalLength",
+	0xA002: "PixelXDimension",
+	0xA003: "PixelYDimension",
+}
+
+const exifIFDPointer = 0x8769
+
+// ReadEXIF reads the EXIF metadata of the JPEG image read from r, and returns
+// the common fields, such as Make, Model, DateTime and Orientation, as
+// strings keyed by their names. It returns no fields, and no error, for JPEG
+// images without EXIF metadata.
+func ReadEXIF(r io.Reader) (map[string]string, error) {
+	br := bufio.NewReader(r)
+	soi := make([]byte, 2)
+	if _, err := io.ReadFull(br, soi); err != nil || soi[0] != 0xFF || soi[1] != 0xD8 {
+		return nil, errors.New("not a JPEG image")
+	}
+	for {
+		marker := make([]byte, 4)
+		if _, err := io.ReadFull(br, marker); err != nil {
+			return map[string]string{}, nil
+		}
+		if marker[0] != 0xFF {
+			return nil, errors.New("malformed JPEG segment")
+		}
+		// Start of scan, after which there are no more metadata segments
+		if marker[1] == 0xDA {
+			return map[string]string{}, nil
+		}
+		size := int(binary.BigEndian.Uint16(marker[2:])) - 2
+		if size < 0 {
+			return nil, errors.New("malformed JPEG segment")
+		}
+		segment := make([]byte, size)
+		if _, err := io.ReadFull(br, segment); err != nil {
+			return nil, fmt.Errorf("truncated JPEG segment: %v", err)
+		}
+		if marker[1] == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return parseTIFF(segment[6:])
+		}
+	}
+}
+
+// parseTIFF parses the TIFF structure of an EXIF segment, reading the fields
+// in exifFieldNames from the first IFD and the EXIF sub-IFD
+func parseTIFF(data []byte) (map[string]string, error) {
+	if len(data) < 8 {
+		return nil, errors.New("truncated EXIF data")
+	}
+	var order binary.ByteOrder
+	switch string(data[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, errors.New("invalid EXIF byte order")
+	}
+	fields := map[string]string{}
+	ifd := order.Uint32(data[4:])
+	subIFD, err := parseIFD(data, ifd, order, fields)
+	if err != nil {
+		return nil, err
+	}
+	if subIFD != 0 {
+		if _, err := parseIFD(data, subIFD, order, fields); err != nil {
+			return nil, err
+		}
+	}
+	return fields, nil
+}
+
+// parseIFD reads the fields of the IFD at offset into fields, and returns the
+// offset of the EXIF sub-IFD, if it points to one
+func parseIFD(data []byte, offset uint32, order binary.ByteOrder, fields map[string]string) (uint32, error) {
+	if int(offset)+2 > len(data) {
+		return 0, errors.New("EXIF IFD offset out of range")
+	}
+	n := int(order.Uint16(data[offset:]))
+	subIFD := uint32(0)
+	for i := 0; i < n; i++ {
+		start := int(offset) + 2 + i*12
+		if start+12 > len(data) {
+			return 0, errors.New("truncated EXIF IFD")
+		}
+		entry := data[start : start+12]
+		tag := order.Uint16(entry)
+		if tag == exifIFDPointer {
+			subIFD = order.Uint32(entry[8:])
+			continue
+		}
+		name, ok := exifFieldNames[tag]
+		if !ok {
+			continue
+		}
+		value, err := exifValue(data, entry, order)
+		if err != nil {
+			return 0, fmt.Errorf("invalid EXIF field %s: %v", name, err)
+		}
+		fields[name] = value
+	}
+	return subIFD, nil
+}
+
+// exifValue formats the value of the IFD entry entry as a string. Values of
+// more than four bytes are stored at an offset in data.
+func exifValue(data []byte, entry []byte, order binary.ByteOrder) (string, error) {
+	typ := order.Uint16(entry[2:])
+	count := int(order.Uint32(entry[4:]))
+	sizes := map[uint16]int{2: 1, 3: 2, 4: 4, 5: 8, 9: 4, 10: 8}
+	size, ok := sizes[typ]
+	if !ok {
+		return "", fmt.Errorf("unsupported type %d", typ)
+	}
+	if count < 1 || count > len(data) {
+		return "", fmt.Errorf("invalid count %d", count)
+	}
+	raw := entry[8:12]
+	if size*count > 4 {
+		offset := int(order.Uint32(entry[8:]))
+		if offset < 0 || offset+size*count > len(data) {
+			return "", errors.New("value offset out of range")
+		}
+		raw = data[offset : offset+size*count]
+	}
+
+	if typ == 2 {
+		return strings.TrimRight(string(raw[:count]), "\x00 "), nil
+	}
+	values := []string{}
+	for i := 0; i < count; i++ {
+		v := raw[i*size:]
+		switch typ {
+		case 3:
+			values = append(values, fmt.Sprint(order.Uint16(v)))
+		case 4:
+			values = append(values, fmt.Sprint(order.Uint32(v)))
+		case 9:
+			values = append(values, fmt.Sprint(int32(order.Uint32(v))))
+		case 5:
+			values = append(values, fmt.Sprintf("%d/%d", order.Uint32(v), order.Uint32(v[4:])))
+		case 10:
+			values = append(values, fmt.Sprintf("%d/%d", int32(order.Uint32(v)), int32(order.Uint32(v[4:]))))
+		}
+	}
+	return strings.Join(values, " "), nil
+}
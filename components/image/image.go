@@ -0,0 +1,180 @@
+// Package image contains components for processing images with the image
+// packages of the standard library: decoding image files (Decoder), encoding
+// images (Encoder), resizing (Resize), cropping (Crop), conversion to
+// grayscale (Grayscale), and extraction of EXIF metadata as tags
+// (EXIFExtractor).
+//
+// Images are sent between the components as packets with image.Image data.
+// The supported formats are JPEG, PNG and GIF.
+package image
+
+import (
+	"bytes"
+	"fmt"
+	goimage "image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	fb "github.com/flowbase/flowbase"
+	"github.com/flowbase/flowbase/template"
+)
+
+// openData returns a reader for the data of the packet ip, which should be a
+// *fb.FileIP or []byte
+func openData(ip *fb.Packet) io.ReadCloser {
+	switch data := ip.Data().(type) {
+	case *fb.FileIP:
+		return data.Open()
+	case []byte:
+		return io.NopCloser(bytes.NewReader(data))
+	}
+	ip.Failf("Data (%v) of type %T is neither a file nor bytes", ip.Data(), ip.Data())
+	return nil
+}
+
+// imageData returns the data of the packet ip as an image
+func imageData(ip *fb.Packet) goimage.Image {
+	img, ok := ip.Data().(goimage.Image)
+	if !ok {
+		ip.Failf("Data (%v) of type %T is not an image", ip.Data(), ip.Data())
+	}
+	return img
+}
+
+// ------------------------------------------------------------------------
+// Decoder
+// ------------------------------------------------------------------------
+
+// Decoder decodes the image files (*fb.FileIP) or encoded images ([]byte) it
+// receives, and sends on the images, tagged with their format, as "format"
+type Decoder struct {
+	fb.BaseProcess
+}
+
+// NewDecoder returns a new Decoder process
+func NewDecoder(net *fb.Network, name string) *Decoder {
+	p := &Decoder{
+		BaseProcess: fb.NewBaseProcess(net, name),
+	}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *Decoder) In() *fb.InPort { return p.InPort("in") }
+
+// Out returns the out-port, on which the decoded images are sent
+func (p *Decoder) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Run runs the Decoder process
+func (p *Decoder) Run() {
+	defer p.CloseOutPorts()
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		r := openData(ip)
+		img, format, err := goimage.Decode(r)
+		r.Close()
+		if err != nil {
+			ip.Failf("Could not decode image: %v", err)
+		}
+		newIP := fb.NewDerivedPacket(ip, img)
+		newIP.AddTag("format", format)
+		p.Out().SendPacket(newIP)
+	}
+}
+
+// ------------------------------------------------------------------------
+// Encoder
+// ------------------------------------------------------------------------
+
+// Encoder encodes the images it receives in a format, and sends on the
+// encoded images as []byte, or, if a path pattern is set with SetPath, writes
+// them to files and sends on the files as *fb.FileIP
+type Encoder struct {
+	fb.BaseProcess
+	format string
+	path   *template.Template
+	// Quality is the quality of JPEG images, from 1 to 100. Defaults to
+	// jpeg.DefaultQuality.
+	Quality int
+}
+
+// NewEncoder returns a new Encoder process, encoding images in the format
+// format, which is one of "jpeg", "png" or "gif"
+func NewEncoder(net *fb.Network, name string, format string) *Encoder {
+	switch format {
+	case "jpeg", "png", "gif":
+	default:
+		fb.Failf("Encoder (%s) got unsupported image format: %s", name, format)
+	}
+	p := &Encoder{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		format:      format,
+		Quality:     jpeg.DefaultQuality,
+	}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *Encoder) In() *fb.InPort { return p.InPort("in") }
+
+// Out returns the out-port, on which the encoded images are sent
+func (p *Encoder) Out() *fb.OutPort { return p.OutPort("out") }
+
+// SetPath makes the process write the encoded images to files, with paths
+// given by the pattern pattern, in which {t:name} is replaced by the value
+// of the tag name of the image packet, such as "thumbs/{t:id}.png"
+func (p *Encoder) SetPath(pattern string) {
+	tpl, err := template.Parse(pattern)
+	if err != nil {
+		p.Failf("Invalid path pattern: %v", err)
+	}
+	for _, ph := range tpl.Placeholders() {
+		if ph.Type != "t" {
+			p.Failf("Path pattern placeholder %s is not a tag placeholder ({t:name})", ph.Raw)
+		}
+	}
+	p.path = tpl
+}
+
+// Run runs the Encoder process
+func (p *Encoder) Run() {
+	defer p.CloseOutPorts()
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		buf := &bytes.Buffer{}
+		if err := p.encode(buf, imageData(ip)); err != nil {
+			ip.Failf("Could not encode image as %s: %v", p.format, err)
+		}
+		if p.path == nil {
+			p.Out().SendPacket(fb.NewDerivedPacket(ip, buf.Bytes()))
+			continue
+		}
+		path, err := p.path.Execute(func(ph *template.Placeholder) ([]string, error) {
+			return []string{ip.Tag(ph.Name)}, nil
+		})
+		if err != nil {
+			ip.Failf("Could not format path: %v", err)
+		}
+		file := fb.NewFileIP(path)
+		file.Write(buf.Bytes())
+		p.Out().SendPacket(fb.NewDerivedPacket(ip, file))
+	}
+}
+
+func (p *Encoder) encode(w io.Writer, img goimage.Image) error {
+	switch p.format {
+	case "jpeg":
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: p.Quality})
+	case "png":
+		return png.Encode(w, img)
+	case "gif":
+		return gif.Encode(w, img, nil)
+	}
+	return fmt.Errorf("unsupported image format: %s", p.format)
+}
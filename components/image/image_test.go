@@ -0,0 +1,120 @@
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	goimage "image"
+	"image/color"
+	"image/png"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	fb "github.com/flowbase/flowbase"
+	"github.com/flowbase/flowbase/flowbasetest"
+)
+
+// testImage returns a width times height image, with the red channel
+// increasing from left to right
+func testImage(width int, height int) *goimage.RGBA {
+	img := goimage.NewRGBA(goimage.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 255 / (width - 1)), G: 100, B: 0, A: 255})
+		}
+	}
+	return img
+}
+
+func TestDecodeTransformEncode(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, testImage(40, 20)); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "in.png")
+	fb.NewFileIP(path).Write(buf.Bytes())
+
+	net := flowbasetest.NewTestNetwork(t)
+	decoder := NewDecoder(net.Network, "decoder")
+	ip := fb.NewPacket(fb.NewFileIP(path))
+	ip.AddTag("id", "img1")
+	flowbasetest.FeedPort(decoder.In(), ip)
+	crop := NewCrop(net.Network, "crop", goimage.Rect(20, 0, 40, 20))
+	crop.In().From(decoder.Out())
+	resize := NewResize(net.Network, "resize", 10, 0)
+	resize.In().From(crop.Out())
+	gray := NewGrayscale(net.Network, "gray")
+	gray.In().From(resize.Out())
+	encoder := NewEncoder(net.Network, "encoder", "png")
+	encoder.SetPath(filepath.Join(t.TempDir(), "{t:id}_{t:format}.png"))
+	encoder.In().From(gray.Out())
+	files := flowbasetest.CollectPort[*fb.FileIP](encoder.Out())
+	net.Run()
+
+	out := files.Values()
+	if len(out) != 1 || filepath.Base(out[0].Path()) != "img1_png.png" {
+		t.Fatalf("Got wrong output files: %v", out)
+	}
+	img, err := png.Decode(out[0].Open())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds() != goimage.Rect(0, 0, 10, 10) {
+		t.Errorf("Got wrong size of resized image: %v", img.Bounds())
+	}
+	if _, ok := img.(*goimage.Gray); !ok {
+		t.Errorf("Expected a grayscale image, got %T", img)
+	}
+	// Only the right half, with the higher red values, is kept
+	left := img.(*goimage.Gray).GrayAt(0, 0).Y
+	right := img.(*goimage.Gray).GrayAt(9, 0).Y
+	if left >= right || left < 60 {
+		t.Errorf("Got wrong gray values after crop and resize: %d, %d", left, right)
+	}
+}
+
+func TestReadEXIF(t *testing.T) {
+	// A TIFF structure with Make and Orientation in IFD0, and a pointer to an
+	// EXIF sub-IFD with ExposureTime
+	tiff := &bytes.Buffer{}
+	le := binary.LittleEndian
+	tiff.WriteString("II")
+	binary.Write(tiff, le, uint16(42))
+	binary.Write(tiff, le, uint32(8))
+	// IFD0, at offset 8, with 3 entries, followed by the next IFD offset
+	binary.Write(tiff, le, uint16(3))
+	binary.Write(tiff, le, []uint16{0x010F, 2})
+	binary.Write(tiff, le, []uint32{6, 50})
+	binary.Write(tiff, le, []uint16{0x0112, 3})
+	binary.Write(tiff, le, []uint32{1, 6})
+	binary.Write(tiff, le, []uint16{0x8769, 4})
+	binary.Write(tiff, le, []uint32{1, 56})
+	binary.Write(tiff, le, uint32(0))
+	// Make, at offset 50
+	tiff.WriteString("Canon\x00")
+	// EXIF sub-IFD, at offset 56
+	binary.Write(tiff, le, uint16(1))
+	binary.Write(tiff, le, []uint16{0x829A, 5})
+	binary.Write(tiff, le, []uint32{1, 74})
+	binary.Write(tiff, le, uint32(0))
+	// ExposureTime, at offset 74
+	binary.Write(tiff, le, []uint32{1, 250})
+
+	jpg := &bytes.Buffer{}
+	jpg.Write([]byte{0xFF, 0xD8, 0xFF, 0xE1})
+	binary.Write(jpg, binary.BigEndian, uint16(2+6+tiff.Len()))
+	jpg.WriteString("Exif\x00\x00")
+	jpg.Write(tiff.Bytes())
+	jpg.Write([]byte{0xFF, 0xDA})
+
+	net := flowbasetest.NewTestNetwork(t)
+	exif := NewEXIFExtractor(net.Network, "exif")
+	flowbasetest.FeedPort(exif.In(), jpg.Bytes())
+	out := flowbasetest.CollectPort[[]byte](exif.Out())
+	net.Run()
+
+	want := map[string]string{"exif.Make": "Canon", "exif.Orientation": "6", "exif.ExposureTime": "1/250"}
+	if have := out.Packets()[0].Tags(); !reflect.DeepEqual(want, have) {
+		t.Errorf("Got wrong EXIF tags: %v, wanted: %v", have, want)
+	}
+}
@@ -0,0 +1,145 @@
+package image
+
+import (
+	"fmt"
+	goimage "image"
+	"strconv"
+	"strings"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// The image components are registered, with their descriptors, so that they
+// can be used in graph files (see fb.NewNetworkFromGraph)
+func init() {
+	fb.RegisterComponent("ImageDecoder", func(net *fb.Network, name string, metadata map[string]string) (fb.Node, error) {
+		return NewDecoder(net, name), nil
+	})
+	fb.RegisterComponent("ImageEncoder", func(net *fb.Network, name string, metadata map[string]string) (fb.Node, error) {
+		p := NewEncoder(net, name, metadata["format"])
+		if path, ok := metadata["path"]; ok {
+			p.SetPath(path)
+		}
+		if q, ok := metadata["quality"]; ok {
+			quality, err := strconv.Atoi(q)
+			if err != nil {
+				return nil, fmt.Errorf("invalid quality metadata: %s", q)
+			}
+			p.Quality = quality
+		}
+		return p, nil
+	})
+	fb.RegisterComponent("Resize", func(net *fb.Network, name string, metadata map[string]string) (fb.Node, error) {
+		size, err := intsMetadata(metadata, "width", "height")
+		if err != nil {
+			return nil, err
+		}
+		return NewResize(net, name, size[0], size[1]), nil
+	})
+	fb.RegisterComponent("Crop", func(net *fb.Network, name string, metadata map[string]string) (fb.Node, error) {
+		r, err := intsMetadata(metadata, "x0", "y0", "x1", "y1")
+		if err != nil {
+			return nil, err
+		}
+		return NewCrop(net, name, goimage.Rect(r[0], r[1], r[2], r[3])), nil
+	})
+	fb.RegisterComponent("Grayscale", func(net *fb.Network, name string, metadata map[string]string) (fb.Node, error) {
+		return NewGrayscale(net, name), nil
+	})
+	fb.RegisterComponent("EXIFExtractor", func(net *fb.Network, name string, metadata map[string]string) (fb.Node, error) {
+		return NewEXIFExtractor(net, name), nil
+	})
+
+	imageIn := []fb.PortSpec{{Name: "in", Type: "image"}}
+	imageOut := []fb.PortSpec{{Name: "out", Type: "image"}}
+	encodedIn := []fb.PortSpec{{Name: "in", Type: "file", Description: "Image file, or encoded image bytes"}}
+	for _, info := range []fb.ComponentInfo{
+		{Name: "ImageDecoder", Description: "Decodes JPEG, PNG and GIF images, tagged with their format", InPorts: encodedIn, OutPorts: imageOut},
+		{
+			Name:        "ImageEncoder",
+			Description: "Encodes images in the format metadata, as bytes, or to files at the path metadata pattern",
+			InPorts:     imageIn,
+			OutPorts:    []fb.PortSpec{{Name: "out", Type: "file"}},
+			Params: []fb.ParamSpec{
+				{Name: "format", Type: "string", Description: "The format, jpeg, png or gif", Required: true},
+				{Name: "path", Type: "string", Description: "The path pattern of the files to write, with {t:name} placeholders. Defaults to sending bytes"},
+				{Name: "quality", Type: "int", Description: "The JPEG quality, from 1 to 100"},
+			},
+		},
+		{
+			Name:        "Resize",
+			Description: "Scales images to the width and height metadata, keeping the aspect ratio if either is 0",
+			InPorts:     imageIn,
+			OutPorts:    imageOut,
+			Params: []fb.ParamSpec{
+				{Name: "width", Type: "int", Description: "The width, or 0 to keep the aspect ratio"},
+				{Name: "height", Type: "int", Description: "The height, or 0 to keep the aspect ratio"},
+			},
+		},
+		{
+			Name:        "Crop",
+			Description: "Cuts out the rectangle from x0,y0 to x1,y1 of images",
+			InPorts:     imageIn,
+			OutPorts:    imageOut,
+			Params: []fb.ParamSpec{
+				{Name: "x0", Type: "int", Description: "The left edge"},
+				{Name: "y0", Type: "int", Description: "The top edge"},
+				{Name: "x1", Type: "int", Description: "The right edge", Required: true},
+				{Name: "y1", Type: "int", Description: "The bottom edge", Required: true},
+			},
+		},
+		{Name: "Grayscale", Description: "Converts images to grayscale", InPorts: imageIn, OutPorts: imageOut},
+		{Name: "EXIFExtractor", Description: "Tags JPEG images with their EXIF metadata, as exif.<field>", InPorts: encodedIn, OutPorts: []fb.PortSpec{{Name: "out", Type: "file"}}},
+	} {
+		info.Version = fb.Version
+		fb.RegisterComponentInfo(info)
+	}
+}
+
+// intsMetadata parses the metadata fields keys as integers, which default to
+// 0 if missing
+func intsMetadata(metadata map[string]string, keys ...string) ([]int, error) {
+	values := []int{}
+	for _, key := range keys {
+		v := 0
+		if s, ok := metadata[key]; ok {
+			var err error
+			if v, err = strconv.Atoi(strings.TrimSpace(s)); err != nil {
+				return nil, fmt.Errorf("invalid %s metadata: %s", key, s)
+			}
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// ComponentMetadata returns the format and quality of the process, and its
+// path pattern, if set
+func (p *Encoder) ComponentMetadata() map[string]string {
+	metadata := map[string]string{"format": p.format, "quality": strconv.Itoa(p.Quality)}
+	if p.path != nil {
+		metadata["path"] = p.path.String()
+	}
+	return metadata
+}
+
+// ComponentMetadata returns the size of the process
+func (p *Resize) ComponentMetadata() map[string]string {
+	return map[string]string{"width": strconv.Itoa(p.width), "height": strconv.Itoa(p.height)}
+}
+
+// ComponentMetadata returns the rectangle of the process
+func (p *Crop) ComponentMetadata() map[string]string {
+	return map[string]string{
+		"x0": strconv.Itoa(p.rect.Min.X),
+		"y0": strconv.Itoa(p.rect.Min.Y),
+		"x1": strconv.Itoa(p.rect.Max.X),
+		"y1": strconv.Itoa(p.rect.Max.Y),
+	}
+}
+
+// ComponentName returns the name the process is registered with
+func (p *Decoder) ComponentName() string { return "ImageDecoder" }
+
+// ComponentName returns the name the process is registered with
+func (p *Encoder) ComponentName() string { return "ImageEncoder" }
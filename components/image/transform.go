@@ -0,0 +1,207 @@
+package image
+
+import (
+	goimage "image"
+	"image/color"
+	"image/draw"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// ------------------------------------------------------------------------
+// Resize
+// ------------------------------------------------------------------------
+
+// Resize scales the images it receives to a size, with bilinear
+// interpolation
+type Resize struct {
+	fb.BaseProcess
+	width  int
+	height int
+}
+
+// NewResize returns a new Resize process, scaling images to width times
+// height pixels. If either width or height is 0, it is computed from the
+// other one, so that the aspect ratio of the images is kept.
+func NewResize(net *fb.Network, name string, width int, height int) *Resize {
+	if width < 0 || height < 0 || (width == 0 && height == 0) {
+		fb.Failf("Resize (%s) got invalid size %dx%d", name, width, height)
+	}
+	p := &Resize{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		width:       width,
+		height:      height,
+	}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *Resize) In() *fb.InPort { return p.InPort("in") }
+
+// Out returns the out-port, on which the resized images are sent
+func (p *Resize) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Run runs the Resize process
+func (p *Resize) Run() {
+	defer p.CloseOutPorts()
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		img := imageData(ip)
+		b := img.Bounds()
+		width, height := p.width, p.height
+		if width == 0 {
+			width = max1(b.Dx() * height / max1(b.Dy()))
+		}
+		if height == 0 {
+			height = max1(b.Dy() * width / max1(b.Dx()))
+		}
+		p.Out().SendPacket(fb.NewDerivedPacket(ip, resizeBilinear(img, width, height)))
+	}
+}
+
+// resizeBilinear returns a copy of img scaled to width times height pixels,
+// where each pixel is interpolated from the four nearest pixels of img
+func resizeBilinear(img goimage.Image, width int, height int) *goimage.RGBA64 {
+	b := img.Bounds()
+	dst := goimage.NewRGBA64(goimage.Rect(0, 0, width, height))
+	if b.Empty() {
+		return dst
+	}
+	scaleX := float64(b.Dx()) / float64(width)
+	scaleY := float64(b.Dy()) / float64(height)
+	for y := 0; y < height; y++ {
+		sy := (float64(y)+0.5)*scaleY - 0.5
+		y0, fy := splitCoord(sy, b.Dy())
+		y1 := minInt(y0+1, b.Dy()-1)
+		for x := 0; x < width; x++ {
+			sx := (float64(x)+0.5)*scaleX - 0.5
+			x0, fx := splitCoord(sx, b.Dx())
+			x1 := minInt(x0+1, b.Dx()-1)
+			c00 := color.RGBA64Model.Convert(img.At(b.Min.X+x0, b.Min.Y+y0)).(color.RGBA64)
+			c10 := color.RGBA64Model.Convert(img.At(b.Min.X+x1, b.Min.Y+y0)).(color.RGBA64)
+			c01 := color.RGBA64Model.Convert(img.At(b.Min.X+x0, b.Min.Y+y1)).(color.RGBA64)
+			c11 := color.RGBA64Model.Convert(img.At(b.Min.X+x1, b.Min.Y+y1)).(color.RGBA64)
+			lerp := func(v00, v10, v01, v11 uint16) uint16 {
+				top := float64(v00)*(1-fx) + float64(v10)*fx
+				bottom := float64(v01)*(1-fx) + float64(v11)*fx
+				return uint16(top*(1-fy) + bottom*fy + 0.5)
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{
+				R: lerp(c00.R, c10.R, c01.R, c11.R),
+				G: lerp(c00.G, c10.G, c01.G, c11.G),
+				B: lerp(c00.B, c10.B, c01.B, c11.B),
+				A: lerp(c00.A, c10.A, c01.A, c11.A),
+			})
+		}
+	}
+	return dst
+}
+
+// splitCoord splits the source coordinate c into the index of the pixel
+// before it, within 0 and size-1, and the fraction of the way to the next one
+func splitCoord(c float64, size int) (int, float64) {
+	if c <= 0 {
+		return 0, 0
+	}
+	i := int(c)
+	if i >= size-1 {
+		return size - 1, 0
+	}
+	return i, c - float64(i)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max1(a int) int {
+	if a < 1 {
+		return 1
+	}
+	return a
+}
+
+// ------------------------------------------------------------------------
+// Crop
+// ------------------------------------------------------------------------
+
+// Crop cuts out a rectangle of the images it receives
+type Crop struct {
+	fb.BaseProcess
+	rect goimage.Rectangle
+}
+
+// NewCrop returns a new Crop process, cutting out the rectangle rect, in
+// coordinates relative to the top left corner of the images. Parts of rect
+// outside of an image are left out.
+func NewCrop(net *fb.Network, name string, rect goimage.Rectangle) *Crop {
+	p := &Crop{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		rect:        rect.Canon(),
+	}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *Crop) In() *fb.InPort { return p.InPort("in") }
+
+// Out returns the out-port, on which the cropped images are sent
+func (p *Crop) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Run runs the Crop process
+func (p *Crop) Run() {
+	defer p.CloseOutPorts()
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		img := imageData(ip)
+		b := img.Bounds()
+		r := p.rect.Add(b.Min).Intersect(b)
+		dst := goimage.NewRGBA(goimage.Rect(0, 0, r.Dx(), r.Dy()))
+		draw.Draw(dst, dst.Bounds(), img, r.Min, draw.Src)
+		p.Out().SendPacket(fb.NewDerivedPacket(ip, dst))
+	}
+}
+
+// ------------------------------------------------------------------------
+// Grayscale
+// ------------------------------------------------------------------------
+
+// Grayscale converts the images it receives to grayscale
+type Grayscale struct {
+	fb.BaseProcess
+}
+
+// NewGrayscale returns a new Grayscale process
+func NewGrayscale(net *fb.Network, name string) *Grayscale {
+	p := &Grayscale{
+		BaseProcess: fb.NewBaseProcess(net, name),
+	}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *Grayscale) In() *fb.InPort { return p.InPort("in") }
+
+// Out returns the out-port, on which the *image.Gray images are sent
+func (p *Grayscale) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Run runs the Grayscale process
+func (p *Grayscale) Run() {
+	defer p.CloseOutPorts()
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		img := imageData(ip)
+		dst := goimage.NewGray(img.Bounds())
+		draw.Draw(dst, dst.Bounds(), img, img.Bounds().Min, draw.Src)
+		p.Out().SendPacket(fb.NewDerivedPacket(ip, dst))
+	}
+}
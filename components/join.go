@@ -0,0 +1,173 @@
+package components
+
+import (
+	"sync"
+	"time"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// JoinedPackets is the data of packets emitted by Join, containing the joined
+// packets keyed by the name of the in-port they arrived on
+type JoinedPackets map[string]*fb.Packet
+
+// Join pairs up packets arriving on two or more in-ports by a join key, and
+// emits one packet with JoinedPackets data on its out-port for every key for
+// which packets have arrived on all in-ports. The tags of the joined packets
+// are merged into the emitted packet. Packets that could not be matched,
+// because the buffer limit or timeout was hit or because the input streams
+// ended, are sent on the Unmatched out-port.
+type Join struct {
+	fb.BaseProcess
+	keyFunc     func(ip *fb.Packet) string
+	inPortNames []string
+	// MaxBuffered is the maximum number of keys for which partial matches are
+	// kept. When exceeded, the oldest key is given up on. Zero means no limit.
+	MaxBuffered int
+	// Timeout is the maximum time to wait for the remaining packets of a key
+	// after the first one arrived. Zero means no timeout.
+	Timeout time.Duration
+}
+
+// NewJoin returns a new Join process, joining packets on the in-ports named
+// inPortNames, by the key returned by keyFunc
+func NewJoin(net *fb.Network, name string, keyFunc func(ip *fb.Packet) string, inPortNames ...string) *Join {
+	if len(inPortNames) < 2 {
+		fb.Failf("Join (%s) needs at least two in-ports, got: %v", name, inPortNames)
+	}
+	p := &Join{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		keyFunc:     keyFunc,
+		inPortNames: inPortNames,
+	}
+	for _, inPortName := range inPortNames {
+		p.InitInPort(p, inPortName)
+	}
+	p.InitOutPort(p, "out")
+	p.InitOutPort(p, "unmatched")
+	net.AddProc(p)
+	return p
+}
+
+// JoinOnTag returns a key function for Join, using the value of the tag tag
+func JoinOnTag(tag string) func(ip *fb.Packet) string {
+	return func(ip *fb.Packet) string {
+		return ip.Tag(tag)
+	}
+}
+
+// In returns the in-port with name portName
+func (p *Join) In(portName string) *fb.InPort { return p.InPort(portName) }
+
+// Out returns the out-port on which joined packets are sent
+func (p *Join) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Unmatched returns the out-port on which packets that could not be joined are
+// sent
+func (p *Join) Unmatched() *fb.OutPort { return p.OutPort("unmatched") }
+
+type joinItem struct {
+	portName string
+	ip       *fb.Packet
+}
+
+type pendingJoin struct {
+	ips     JoinedPackets
+	created time.Time
+}
+
+// Run runs the Join process
+func (p *Join) Run() {
+	defer p.CloseOutPorts()
+
+	merged := make(chan joinItem, len(p.inPortNames))
+	wg := &sync.WaitGroup{}
+	for _, inPortName := range p.inPortNames {
+		wg.Add(1)
+		go func(inPortName string) {
+			defer wg.Done()
+			inPort := p.InPort(inPortName)
+			for ip, ok := inPort.RecvOK(); ok; ip, ok = inPort.RecvOK() {
+				merged <- joinItem{inPortName, ip}
+			}
+		}(inPortName)
+	}
+	go func() {
+		wg.Wait()
+		close(merged)
+	}()
+
+	clock := p.Network().Clock()
+	var tick <-chan time.Time
+	if p.Timeout > 0 {
+		ticker := clock.NewTicker(p.Timeout / 2)
+		defer ticker.Stop()
+		tick = ticker.C()
+	}
+
+	pending := map[string]*pendingJoin{}
+	for {
+		select {
+		case item, ok := <-merged:
+			if !ok {
+				for key := range pending {
+					p.giveUp(pending, key)
+				}
+				return
+			}
+			key := p.keyFunc(item.ip)
+			pj, ok := pending[key]
+			if !ok {
+				pj = &pendingJoin{ips: JoinedPackets{}, created: clock.Now()}
+				pending[key] = pj
+			}
+			if old, ok := pj.ips[item.portName]; ok {
+				fb.Warning.Printf("[Process:%s] Got more than one packet with key (%s) on in-port (%s)\n", p.Name(), key, item.portName)
+				p.Unmatched().SendPacket(old)
+			}
+			pj.ips[item.portName] = item.ip
+			if len(pj.ips) == len(p.inPortNames) {
+				delete(pending, key)
+				p.Out().SendPacket(joinedPacket(pj.ips))
+			} else if p.MaxBuffered > 0 && len(pending) > p.MaxBuffered {
+				p.giveUp(pending, oldestPendingKey(pending))
+			}
+		case now := <-tick:
+			for key, pj := range pending {
+				if now.Sub(pj.created) > p.Timeout {
+					p.giveUp(pending, key)
+				}
+			}
+		}
+	}
+}
+
+// giveUp sends the packets for key in pending to the unmatched out-port, and
+// deletes the key from pending
+func (p *Join) giveUp(pending map[string]*pendingJoin, key string) {
+	for _, ip := range pending[key].ips {
+		p.Unmatched().SendPacket(ip)
+	}
+	delete(pending, key)
+}
+
+func oldestPendingKey(pending map[string]*pendingJoin) string {
+	oldestKey, found := "", false
+	var oldest time.Time
+	for key, pj := range pending {
+		if !found || pj.created.Before(oldest) {
+			oldestKey, oldest, found = key, pj.created, true
+		}
+	}
+	return oldestKey
+}
+
+func joinedPacket(ips JoinedPackets) *fb.Packet {
+	ip := fb.NewPacket(ips)
+	for _, jip := range ips {
+		for k, v := range jip.Tags() {
+			ip.Tags()[k] = v
+		}
+	}
+	return ip
+}
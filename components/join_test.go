@@ -0,0 +1,95 @@
+package components
+
+import (
+	"testing"
+	"time"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+func TestJoin(t *testing.T) {
+	initTestLogs()
+	net := fb.NewNetwork("TestJoin")
+
+	left := newSliceSource(net, "left",
+		taggedPacket("l1", map[string]string{"id": "1"}),
+		taggedPacket("l2", map[string]string{"id": "2"}),
+		taggedPacket("l3", map[string]string{"id": "3"}))
+	right := newSliceSource(net, "right",
+		taggedPacket("r2", map[string]string{"id": "2"}),
+		taggedPacket("r1", map[string]string{"id": "1"}))
+
+	join := NewJoin(net, "join", JoinOnTag("id"), "left", "right")
+	join.In("left").From(left.Out())
+	join.In("right").From(right.Out())
+
+	joined := newCollector(net, "joined")
+	joined.In().From(join.Out())
+
+	net.Run()
+
+	if len(joined.ips) != 2 {
+		t.Fatalf("Expected 2 joined packets, got %d", len(joined.ips))
+	}
+	for _, ip := range joined.ips {
+		jps := ip.Data().(JoinedPackets)
+		id := ip.Tag("id")
+		if jps["left"].Data() != "l"+id || jps["right"].Data() != "r"+id {
+			t.Errorf("Wrongly joined packets for id %s: %v, %v", id, jps["left"].Data(), jps["right"].Data())
+		}
+	}
+}
+
+func TestJoinTimeoutVirtualClock(t *testing.T) {
+	initTestLogs()
+	net := fb.NewNetwork("TestJoinTimeoutVirtualClock")
+	clock := fb.NewVirtualClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	net.SetClock(clock)
+
+	release := make(chan struct{})
+	left := net.NewFunc("left", func(out chan<- *fb.Packet) error {
+		out <- taggedPacket("l1", map[string]string{"id": "1"})
+		<-release
+		return nil
+	})
+	right := net.NewFunc("right", func(out chan<- *fb.Packet) error {
+		<-release
+		return nil
+	})
+	join := NewJoin(net, "join", JoinOnTag("id"), "left", "right")
+	join.Timeout = time.Hour
+	join.In("left").From(left.Out())
+	join.In("right").From(right.Out())
+	joined := newCollector(net, "joined")
+	joined.In().From(join.Out())
+	unmatched := make(chan any, 1)
+	sink := net.NewFunc("unmatched", func(in <-chan *fb.Packet) error {
+		for ip := range in {
+			unmatched <- ip.Data()
+		}
+		return nil
+	})
+	sink.In().From(join.Unmatched())
+
+	done := make(chan struct{})
+	go func() {
+		net.Run()
+		close(done)
+	}()
+	// The timeout is only hit after an hour of virtual time, as the time the
+	// packet arrived is taken from the clock of the network
+	clock.BlockUntilWaiters(1)
+	var got any
+	for i := 0; got == nil && i < 100; i++ {
+		clock.Advance(30 * time.Minute)
+		select {
+		case got = <-unmatched:
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	close(release)
+	<-done
+
+	assertEqualValues(t, "l1", got)
+	assertEqualValues(t, 0, len(joined.ips))
+}
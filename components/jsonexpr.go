@@ -0,0 +1,1021 @@
+package components
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// jsonExpr is a compiled expression of the jq-like language of JSONTransform,
+// which returns the outputs for an input value. Values are the ones returned
+// by json.Unmarshal into an any: nil, bool, float64, string, []any and
+// map[string]any.
+type jsonExpr func(v any) ([]any, error)
+
+// compileJSONExpr compiles the jq-like expression src
+func compileJSONExpr(src string) (jsonExpr, error) {
+	return compileJSONExprVars(src, nil)
+}
+
+// jsonExprVars are the values of the variables of expressions, by name
+// without the $, which are read when the expressions are evaluated, so that
+// they can be set before each evaluation
+type jsonExprVars map[string]any
+
+// compileJSONExprVars compiles the jq-like expression src, which can refer
+// to the variables in vars
+func compileJSONExprVars(src string, vars jsonExprVars) (jsonExpr, error) {
+	toks, err := lexJSONExpr(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &jsonExprParser{toks: toks, vars: vars}
+	expr, err := p.parsePipe()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.peek().text, p.peek().pos)
+	}
+	return expr, nil
+}
+
+// ------------------------------------------------------------------------
+// Lexer
+// ------------------------------------------------------------------------
+
+type jsonTokKind int
+
+const (
+	tokEOF jsonTokKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokPunct
+	tokVar
+)
+
+type jsonTok struct {
+	kind jsonTokKind
+	text string
+	pos  int
+}
+
+var jsonExprOps = []string{"==", "!=", "<=", ">=", "<", ">", ".", "[", "]", "{", "}", "(", ")", "|", ",", ":", "+", "-", "*", "/", "%"}
+
+// endsOperand tells whether the token t can end an operand, after which a -
+// is a subtraction rather than the sign of a number
+func (t jsonTok) endsOperand() bool {
+	switch t.kind {
+	case tokIdent, tokString, tokNumber, tokVar:
+		return true
+	case tokPunct:
+		return t.text == ")" || t.text == "]" || t.text == "}" || t.text == "."
+	}
+	return false
+}
+
+func lexJSONExpr(src string) ([]jsonTok, error) {
+	toks := []jsonTok{}
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"':
+			j := i + 1
+			for j < len(src) && src[j] != '"' {
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("unterminated string at position %d", i)
+			}
+			s, err := strconv.Unquote(src[i : j+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at position %d: %v", i, err)
+			}
+			toks = append(toks, jsonTok{tokString, s, i})
+			i = j + 1
+		case unicode.IsDigit(c) || (c == '-' && i+1 < len(src) && unicode.IsDigit(rune(src[i+1])) &&
+			(len(toks) == 0 || !toks[len(toks)-1].endsOperand())):
+			j := i + 1
+			for j < len(src) && (unicode.IsDigit(rune(src[j])) || strings.ContainsRune(".eE+-", rune(src[j]))) {
+				j++
+			}
+			toks = append(toks, jsonTok{tokNumber, src[i:j], i})
+			i = j
+		case c == '_' || unicode.IsLetter(c):
+			j := i + 1
+			for j < len(src) && (src[j] == '_' || unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j]))) {
+				j++
+			}
+			toks = append(toks, jsonTok{tokIdent, src[i:j], i})
+			i = j
+		case c == '$':
+			j := i + 1
+			for j < len(src) && (src[j] == '_' || unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j]))) {
+				j++
+			}
+			if j == i+1 {
+				return nil, fmt.Errorf("expected variable name at position %d", i)
+			}
+			toks = append(toks, jsonTok{tokVar, src[i+1 : j], i})
+			i = j
+		default:
+			op := ""
+			for _, o := range jsonExprOps {
+				if strings.HasPrefix(src[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+			}
+			toks = append(toks, jsonTok{tokPunct, op, i})
+			i += len(op)
+		}
+	}
+	return append(toks, jsonTok{kind: tokEOF, pos: len(src)}), nil
+}
+
+// ------------------------------------------------------------------------
+// Parser
+// ------------------------------------------------------------------------
+
+type jsonExprParser struct {
+	toks []jsonTok
+	i    int
+	vars jsonExprVars
+}
+
+func (p *jsonExprParser) peek() jsonTok { return p.toks[p.i] }
+
+func (p *jsonExprParser) next() jsonTok {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+// accept consumes the next token if it is the punctuation or keyword text
+func (p *jsonExprParser) accept(text string) bool {
+	t := p.peek()
+	if (t.kind == tokPunct || t.kind == tokIdent) && t.text == text {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *jsonExprParser) expect(text string) error {
+	if !p.accept(text) {
+		return fmt.Errorf("expected %q at position %d, got %q", text, p.peek().pos, p.peek().text)
+	}
+	return nil
+}
+
+// parsePipe parses a | b, which feeds each output of a to b
+func (p *jsonExprParser) parsePipe() (jsonExpr, error) {
+	left, err := p.parseComma()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("|") {
+		right, err := p.parseComma()
+		if err != nil {
+			return nil, err
+		}
+		left = pipeExpr(left, right)
+	}
+	return left, nil
+}
+
+// parseComma parses a, b, which outputs the outputs of a, then those of b
+func (p *jsonExprParser) parseComma() (jsonExpr, error) {
+	left, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(",") {
+		right, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		left = func(a, b jsonExpr) jsonExpr {
+			return func(v any) ([]any, error) {
+				outA, err := a(v)
+				if err != nil {
+					return nil, err
+				}
+				outB, err := b(v)
+				return append(outA, outB...), err
+			}
+		}(left, right)
+	}
+	return left, nil
+}
+
+func (p *jsonExprParser) parseOr() (jsonExpr, error) {
+	return p.parseBinary(p.parseAnd, "or")
+}
+
+func (p *jsonExprParser) parseAnd() (jsonExpr, error) {
+	return p.parseBinary(p.parseComparison, "and")
+}
+
+func (p *jsonExprParser) parseComparison() (jsonExpr, error) {
+	return p.parseBinary(p.parseAdditive, "==", "!=", "<=", ">=", "<", ">")
+}
+
+func (p *jsonExprParser) parseAdditive() (jsonExpr, error) {
+	return p.parseBinary(p.parseMultiplicative, "+", "-")
+}
+
+func (p *jsonExprParser) parseMultiplicative() (jsonExpr, error) {
+	return p.parseBinary(p.parsePostfix, "*", "/", "%")
+}
+
+// parseBinary parses operands with parseOperand, combined with any of the
+// operators ops
+func (p *jsonExprParser) parseBinary(parseOperand func() (jsonExpr, error), ops ...string) (jsonExpr, error) {
+	left, err := parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := ""
+		for _, o := range ops {
+			if p.accept(o) {
+				op = o
+				break
+			}
+		}
+		if op == "" {
+			return left, nil
+		}
+		right, err := parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr(op, left, right)
+	}
+}
+
+// parsePostfix parses a term followed by any number of .key, [index], [] or
+// [from:to] suffixes
+func (p *jsonExprParser) parsePostfix() (jsonExpr, error) {
+	expr, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != tokPunct || (t.text != "." && t.text != "[") {
+			return expr, nil
+		}
+		if t.text == "." {
+			p.next()
+			t := p.next()
+			if t.kind != tokIdent && t.kind != tokString {
+				return nil, fmt.Errorf("expected key after '.' at position %d", t.pos)
+			}
+			expr = pipeExpr(expr, keyExpr(t.text))
+			continue
+		}
+		suffix, err := p.parseBracket()
+		if err != nil {
+			return nil, err
+		}
+		expr = pipeExpr(expr, suffix)
+	}
+}
+
+// parseBracket parses [], [index] or [from:to]
+func (p *jsonExprParser) parseBracket() (jsonExpr, error) {
+	if err := p.expect("["); err != nil {
+		return nil, err
+	}
+	if p.accept("]") {
+		return iterateExpr, nil
+	}
+	var from, to jsonExpr
+	var err error
+	if p.peek().text != ":" {
+		if from, err = p.parsePipe(); err != nil {
+			return nil, err
+		}
+	}
+	if !p.accept(":") {
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		return indexExpr(from), nil
+	}
+	if p.peek().text != "]" {
+		if to, err = p.parsePipe(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect("]"); err != nil {
+		return nil, err
+	}
+	return sliceExpr(from, to), nil
+}
+
+// parseTerm parses ., .key, .[...], literals, variables, (expr), [expr],
+// {...}, if-then-else and function calls
+func (p *jsonExprParser) parseTerm() (jsonExpr, error) {
+	t := p.peek()
+	switch {
+	case t.kind == tokPunct && t.text == ".":
+		p.next()
+		switch n := p.peek(); {
+		case n.kind == tokIdent || n.kind == tokString:
+			p.next()
+			return keyExpr(n.text), nil
+		case n.kind == tokPunct && n.text == "[":
+			return p.parseBracket()
+		}
+		return identityExpr, nil
+	case t.kind == tokString:
+		p.next()
+		return constExpr(t.text), nil
+	case t.kind == tokNumber:
+		p.next()
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s at position %d", t.text, t.pos)
+		}
+		return constExpr(n), nil
+	case t.kind == tokPunct && t.text == "(":
+		p.next()
+		expr, err := p.parsePipe()
+		if err != nil {
+			return nil, err
+		}
+		return expr, p.expect(")")
+	case t.kind == tokPunct && t.text == "[":
+		p.next()
+		if p.accept("]") {
+			return constExpr([]any{}), nil
+		}
+		expr, err := p.parsePipe()
+		if err != nil {
+			return nil, err
+		}
+		return collectExpr(expr), p.expect("]")
+	case t.kind == tokPunct && t.text == "{":
+		return p.parseObject()
+	case t.kind == tokVar:
+		p.next()
+		if _, ok := p.vars[t.text]; !ok {
+			return nil, fmt.Errorf("unknown variable $%s at position %d", t.text, t.pos)
+		}
+		return varExpr(p.vars, t.text), nil
+	case t.kind == tokIdent && t.text == "if":
+		return p.parseIf()
+	case t.kind == tokIdent:
+		return p.parseFunction()
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", t.text, t.pos)
+}
+
+// parseObject parses {key: expr, ...}, where keys are names, strings or
+// (expr), and {key} is short for {key: .key}
+func (p *jsonExprParser) parseObject() (jsonExpr, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	keys, values := []jsonExpr{}, []jsonExpr{}
+	for !p.accept("}") {
+		if len(keys) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		var key jsonExpr
+		name := ""
+		switch t := p.next(); {
+		case t.kind == tokIdent || t.kind == tokString:
+			name = t.text
+			key = constExpr(t.text)
+		case t.kind == tokPunct && t.text == "(":
+			var err error
+			if key, err = p.parsePipe(); err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("expected object key at position %d, got %q", t.pos, t.text)
+		}
+		value := keyExpr(name)
+		if p.accept(":") {
+			var err error
+			// Values can not contain unparenthesized commas, which separate
+			// the fields
+			if value, err = p.parseOr(); err != nil {
+				return nil, err
+			}
+		} else if name == "" {
+			return nil, fmt.Errorf("expected ':' after computed object key at position %d", p.peek().pos)
+		}
+		keys = append(keys, key)
+		values = append(values, value)
+	}
+	return objectExpr(keys, values), nil
+}
+
+// parseIf parses if cond then a elif cond2 then b else c end, where the
+// elif and else branches are optional, and a missing else is .
+func (p *jsonExprParser) parseIf() (jsonExpr, error) {
+	if err := p.expect("if"); err != nil {
+		return nil, err
+	}
+	return p.parseIfBranches()
+}
+
+// parseIfBranches parses the rest of an if after the if or elif keyword
+func (p *jsonExprParser) parseIfBranches() (jsonExpr, error) {
+	cond, err := p.parsePipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("then"); err != nil {
+		return nil, err
+	}
+	then, err := p.parsePipe()
+	if err != nil {
+		return nil, err
+	}
+	var otherwise jsonExpr = identityExpr
+	switch {
+	case p.accept("elif"):
+		// elif is an if nested in the else branch, sharing its end
+		if otherwise, err = p.parseIfBranches(); err != nil {
+			return nil, err
+		}
+		return ifExpr(cond, then, otherwise), nil
+	case p.accept("else"):
+		if otherwise, err = p.parsePipe(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect("end"); err != nil {
+		return nil, err
+	}
+	return ifExpr(cond, then, otherwise), nil
+}
+
+// parseFunction parses the literals true, false and null, and the functions
+// select(f), map(f), has(key), not, length, keys, type, tostring and tonumber
+func (p *jsonExprParser) parseFunction() (jsonExpr, error) {
+	t := p.next()
+	switch t.text {
+	case "true":
+		return constExpr(true), nil
+	case "false":
+		return constExpr(false), nil
+	case "null":
+		return constExpr(nil), nil
+	case "not", "length", "keys", "type", "tostring", "tonumber":
+		return builtinExpr(t.text), nil
+	case "select", "map", "has":
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		arg, err := p.parsePipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		switch t.text {
+		case "select":
+			return selectExpr(arg), nil
+		case "map":
+			return collectExpr(pipeExpr(iterateExpr, arg)), nil
+		}
+		return hasExpr(arg), nil
+	}
+	return nil, fmt.Errorf("unknown function %s at position %d", t.text, t.pos)
+}
+
+// ------------------------------------------------------------------------
+// Evaluation
+// ------------------------------------------------------------------------
+
+func identityExpr(v any) ([]any, error) { return []any{v}, nil }
+
+func constExpr(c any) jsonExpr {
+	return func(any) ([]any, error) { return []any{c}, nil }
+}
+
+func pipeExpr(left jsonExpr, right jsonExpr) jsonExpr {
+	return func(v any) ([]any, error) {
+		outs, err := left(v)
+		if err != nil {
+			return nil, err
+		}
+		results := []any{}
+		for _, out := range outs {
+			res, err := right(out)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, res...)
+		}
+		return results, nil
+	}
+}
+
+func keyExpr(key string) jsonExpr {
+	return func(v any) ([]any, error) {
+		switch v := v.(type) {
+		case nil:
+			return []any{nil}, nil
+		case map[string]any:
+			return []any{v[key]}, nil
+		}
+		return nil, fmt.Errorf("can not get key %q of %s", key, jsonType(v))
+	}
+}
+
+func iterateExpr(v any) ([]any, error) {
+	switch v := v.(type) {
+	case []any:
+		return append([]any{}, v...), nil
+	case map[string]any:
+		outs := []any{}
+		for _, k := range sortedMapKeys(v) {
+			outs = append(outs, v[k])
+		}
+		return outs, nil
+	}
+	return nil, fmt.Errorf("can not iterate over %s", jsonType(v))
+}
+
+// indexExpr returns the element of an array at the index given by index,
+// counting from the end for negative indexes, or the value of an object at
+// the key given by index
+func indexExpr(index jsonExpr) jsonExpr {
+	return func(v any) ([]any, error) {
+		idxs, err := index(v)
+		if err != nil {
+			return nil, err
+		}
+		outs := []any{}
+		for _, idx := range idxs {
+			switch idx := idx.(type) {
+			case string:
+				res, err := keyExpr(idx)(v)
+				if err != nil {
+					return nil, err
+				}
+				outs = append(outs, res...)
+			case float64:
+				switch arr := v.(type) {
+				case nil:
+					outs = append(outs, nil)
+				case []any:
+					i := int(idx)
+					if i < 0 {
+						i += len(arr)
+					}
+					if i < 0 || i >= len(arr) {
+						outs = append(outs, nil)
+					} else {
+						outs = append(outs, arr[i])
+					}
+				default:
+					return nil, fmt.Errorf("can not index %s with a number", jsonType(v))
+				}
+			default:
+				return nil, fmt.Errorf("can not index with %s", jsonType(idx))
+			}
+		}
+		return outs, nil
+	}
+}
+
+// sliceExpr returns the elements of an array, or characters of a string,
+// from index from up to index to
+func sliceExpr(from jsonExpr, to jsonExpr) jsonExpr {
+	bound := func(expr jsonExpr, v any, dflt int, n int) (int, error) {
+		if expr == nil {
+			return dflt, nil
+		}
+		outs, err := expr(v)
+		if err != nil {
+			return 0, err
+		}
+		f, ok := single(outs).(float64)
+		if !ok {
+			return 0, fmt.Errorf("slice bounds must be numbers")
+		}
+		i := int(f)
+		if i < 0 {
+			i += n
+		}
+		if i < 0 {
+			i = 0
+		}
+		if i > n {
+			i = n
+		}
+		return i, nil
+	}
+	return func(v any) ([]any, error) {
+		n := 0
+		switch v := v.(type) {
+		case nil:
+			return []any{nil}, nil
+		case []any:
+			n = len(v)
+		case string:
+			n = len([]rune(v))
+		default:
+			return nil, fmt.Errorf("can not slice %s", jsonType(v))
+		}
+		i, err := bound(from, v, 0, n)
+		if err != nil {
+			return nil, err
+		}
+		j, err := bound(to, v, n, n)
+		if err != nil {
+			return nil, err
+		}
+		if j < i {
+			j = i
+		}
+		if s, ok := v.(string); ok {
+			return []any{string([]rune(s)[i:j])}, nil
+		}
+		return []any{append([]any{}, v.([]any)[i:j]...)}, nil
+	}
+}
+
+// collectExpr returns an array of all outputs of expr
+func collectExpr(expr jsonExpr) jsonExpr {
+	return func(v any) ([]any, error) {
+		outs, err := expr(v)
+		if err != nil {
+			return nil, err
+		}
+		return []any{outs}, nil
+	}
+}
+
+// objectExpr returns objects with the keys and values given by keys and
+// values, one per combination of their outputs
+func objectExpr(keys []jsonExpr, values []jsonExpr) jsonExpr {
+	return func(v any) ([]any, error) {
+		objs := []map[string]any{{}}
+		for i := range keys {
+			ks, err := keys[i](v)
+			if err != nil {
+				return nil, err
+			}
+			vs, err := values[i](v)
+			if err != nil {
+				return nil, err
+			}
+			extended := []map[string]any{}
+			for _, obj := range objs {
+				for _, k := range ks {
+					key, ok := k.(string)
+					if !ok {
+						return nil, fmt.Errorf("object keys must be strings, got %s", jsonType(k))
+					}
+					for _, val := range vs {
+						o := make(map[string]any, len(obj)+1)
+						for ok, ov := range obj {
+							o[ok] = ov
+						}
+						o[key] = val
+						extended = append(extended, o)
+					}
+				}
+			}
+			objs = extended
+		}
+		outs := []any{}
+		for _, obj := range objs {
+			outs = append(outs, obj)
+		}
+		return outs, nil
+	}
+}
+
+func varExpr(vars jsonExprVars, name string) jsonExpr {
+	return func(any) ([]any, error) { return []any{vars[name]}, nil }
+}
+
+// ifExpr returns the outputs of then for each true output of cond, and
+// those of otherwise for each false one
+func ifExpr(cond jsonExpr, then jsonExpr, otherwise jsonExpr) jsonExpr {
+	return func(v any) ([]any, error) {
+		conds, err := cond(v)
+		if err != nil {
+			return nil, err
+		}
+		results := []any{}
+		for _, c := range conds {
+			branch := otherwise
+			if truthy(c) {
+				branch = then
+			}
+			outs, err := branch(v)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, outs...)
+		}
+		return results, nil
+	}
+}
+
+func selectExpr(cond jsonExpr) jsonExpr {
+	return func(v any) ([]any, error) {
+		outs, err := cond(v)
+		if err != nil {
+			return nil, err
+		}
+		results := []any{}
+		for _, out := range outs {
+			if truthy(out) {
+				results = append(results, v)
+			}
+		}
+		return results, nil
+	}
+}
+
+func hasExpr(key jsonExpr) jsonExpr {
+	return func(v any) ([]any, error) {
+		keys, err := key(v)
+		if err != nil {
+			return nil, err
+		}
+		outs := []any{}
+		for _, k := range keys {
+			switch v := v.(type) {
+			case map[string]any:
+				s, ok := k.(string)
+				if !ok {
+					return nil, fmt.Errorf("can not check object for key of type %s", jsonType(k))
+				}
+				_, has := v[s]
+				outs = append(outs, has)
+			case []any:
+				f, ok := k.(float64)
+				if !ok {
+					return nil, fmt.Errorf("can not check array for key of type %s", jsonType(k))
+				}
+				outs = append(outs, f >= 0 && int(f) < len(v))
+			default:
+				return nil, fmt.Errorf("can not check %s for keys", jsonType(v))
+			}
+		}
+		return outs, nil
+	}
+}
+
+func builtinExpr(name string) jsonExpr {
+	return func(v any) ([]any, error) {
+		switch name {
+		case "not":
+			return []any{!truthy(v)}, nil
+		case "type":
+			return []any{jsonType(v)}, nil
+		case "length":
+			switch v := v.(type) {
+			case nil:
+				return []any{0.0}, nil
+			case string:
+				return []any{float64(len([]rune(v)))}, nil
+			case []any:
+				return []any{float64(len(v))}, nil
+			case map[string]any:
+				return []any{float64(len(v))}, nil
+			case float64:
+				if v < 0 {
+					v = -v
+				}
+				return []any{v}, nil
+			}
+		case "keys":
+			switch v := v.(type) {
+			case map[string]any:
+				keys := []any{}
+				for _, k := range sortedMapKeys(v) {
+					keys = append(keys, k)
+				}
+				return []any{keys}, nil
+			case []any:
+				keys := []any{}
+				for i := range v {
+					keys = append(keys, float64(i))
+				}
+				return []any{keys}, nil
+			}
+		case "tostring":
+			if s, ok := v.(string); ok {
+				return []any{s}, nil
+			}
+			b, err := json.Marshal(v)
+			return []any{string(b)}, err
+		case "tonumber":
+			switch v := v.(type) {
+			case float64:
+				return []any{v}, nil
+			case string:
+				f, err := strconv.ParseFloat(v, 64)
+				if err != nil {
+					return nil, fmt.Errorf("can not parse %q as a number", v)
+				}
+				return []any{f}, nil
+			}
+		}
+		return nil, fmt.Errorf("%s is not defined for %s", name, jsonType(v))
+	}
+}
+
+// binaryExpr returns the result of the operator op for each combination of
+// the outputs of left and right
+func binaryExpr(op string, left jsonExpr, right jsonExpr) jsonExpr {
+	return func(v any) ([]any, error) {
+		ls, err := left(v)
+		if err != nil {
+			return nil, err
+		}
+		rs, err := right(v)
+		if err != nil {
+			return nil, err
+		}
+		outs := []any{}
+		for _, l := range ls {
+			for _, r := range rs {
+				res, err := applyOp(op, l, r)
+				if err != nil {
+					return nil, err
+				}
+				outs = append(outs, res)
+			}
+		}
+		return outs, nil
+	}
+}
+
+func applyOp(op string, l any, r any) (any, error) {
+	switch op {
+	case "and":
+		return truthy(l) && truthy(r), nil
+	case "or":
+		return truthy(l) || truthy(r), nil
+	case "==":
+		return reflect.DeepEqual(l, r), nil
+	case "!=":
+		return !reflect.DeepEqual(l, r), nil
+	case "+", "-", "*", "/", "%":
+		return applyArithmetic(op, l, r)
+	}
+	cmp := 0
+	switch l := l.(type) {
+	case float64:
+		rf, ok := r.(float64)
+		if !ok {
+			return nil, fmt.Errorf("can not compare number with %s", jsonType(r))
+		}
+		if l < rf {
+			cmp = -1
+		} else if l > rf {
+			cmp = 1
+		}
+	case string:
+		rs, ok := r.(string)
+		if !ok {
+			return nil, fmt.Errorf("can not compare string with %s", jsonType(r))
+		}
+		cmp = strings.Compare(l, rs)
+	default:
+		return nil, fmt.Errorf("can not compare %s", jsonType(l))
+	}
+	switch op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	}
+	return cmp >= 0, nil
+}
+
+// truthy tells whether v counts as true, which all values but false and
+// null do
+func truthy(v any) bool {
+	return v != nil && v != false
+}
+
+func single(outs []any) any {
+	if len(outs) != 1 {
+		return nil
+	}
+	return outs[0]
+}
+
+func jsonType(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func sortedMapKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// applyArithmetic returns the result of the arithmetic operator op, which
+// also adds strings, arrays and objects, like jq, and treats null as the
+// identity of +
+func applyArithmetic(op string, l any, r any) (any, error) {
+	if op == "+" {
+		switch {
+		case l == nil:
+			return r, nil
+		case r == nil:
+			return l, nil
+		}
+		switch l := l.(type) {
+		case string:
+			if r, ok := r.(string); ok {
+				return l + r, nil
+			}
+		case []any:
+			if r, ok := r.([]any); ok {
+				return append(append([]any{}, l...), r...), nil
+			}
+		case map[string]any:
+			if r, ok := r.(map[string]any); ok {
+				merged := make(map[string]any, len(l)+len(r))
+				for k, v := range l {
+					merged[k] = v
+				}
+				for k, v := range r {
+					merged[k] = v
+				}
+				return merged, nil
+			}
+		}
+	}
+	lf, lok := l.(float64)
+	rf, rok := r.(float64)
+	if !lok || !rok {
+		return nil, fmt.Errorf("can not apply %s to %s and %s", op, jsonType(l), jsonType(r))
+	}
+	switch op {
+	case "+":
+		return lf + rf, nil
+	case "-":
+		return lf - rf, nil
+	case "*":
+		return lf * rf, nil
+	case "/":
+		if rf == 0 {
+			return nil, fmt.Errorf("can not divide %v by zero", lf)
+		}
+		return lf / rf, nil
+	}
+	if int(rf) == 0 {
+		return nil, fmt.Errorf("can not take %v modulo zero", lf)
+	}
+	return float64(int(lf) % int(rf)), nil
+}
@@ -0,0 +1,149 @@
+package components
+
+import (
+	"encoding/json"
+	"fmt"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// JSONTransform filters and reshapes JSON packets with an expression in a
+// subset of the jq language, which is received on the "expr" in-port, such
+// as from an IIP (see SetExpr), before any packets are processed. Each output
+// of the expression for a packet is sent as a packet of its own, with the
+// tags of the packet, so that an expression such as `.items[]` splits a
+// packet, and `select(.status == "done")` filters packets.
+//
+// Packets with []byte, string or json.RawMessage data are parsed as JSON, and
+// the outputs are sent as JSON encoded []byte. Packets with other data, such
+// as a map[string]any, are sent as values of the types returned by
+// json.Unmarshal.
+//
+// The supported expression syntax is:
+//
+//	.                     The input
+//	.key, ."key", .[key]  The value of key in an object
+//	.[i], .[i:j]          An element or slice of an array (or string)
+//	.[]                   Each element of an array, or value of an object
+//	a | b                 The outputs of b for each output of a
+//	a, b                  The outputs of a, then those of b
+//	[a]                   An array of the outputs of a
+//	{k: a, k2}            An object, where k2 is short for k2: .k2
+//	== != < <= > >=       Comparisons
+//	+ - * / %             Arithmetic, where + also joins strings, arrays and
+//	                      objects
+//	and, or, not          Boolean logic
+//	if c then a elif c2 then b else d end
+//	                      Conditionals
+//	select(cond)          The input, if cond is true
+//	map(f)                [.[] | f]
+//	has(key), length, keys, type, tostring, tonumber
+//
+// String, number, true, false and null literals are written as in JSON.
+type JSONTransform struct {
+	fb.BaseProcess
+}
+
+// NewJSONTransform returns a new JSONTransform process, with the expression
+// to be received on the expr in-port
+func NewJSONTransform(net *fb.Network, name string) *JSONTransform {
+	p := &JSONTransform{
+		BaseProcess: fb.NewBaseProcess(net, name),
+	}
+	p.InitInPort(p, "expr")
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// Expr returns the in-port on which the expression is received
+func (p *JSONTransform) Expr() *fb.InPort { return p.InPort("expr") }
+
+// In returns the in-port for JSON packets
+func (p *JSONTransform) In() *fb.InPort { return p.InPort("in") }
+
+// Out returns the out-port, on which the outputs of the expression are sent
+func (p *JSONTransform) Out() *fb.OutPort { return p.OutPort("out") }
+
+// SetExpr sets the expression, by connecting an IIP with expr to the expr
+// in-port. The expression is checked right away, while expressions received
+// from other processes are checked when the process runs.
+func (p *JSONTransform) SetExpr(expr string) {
+	if _, err := compileJSONExpr(expr); err != nil {
+		p.Failf("Invalid expression (%s): %v", expr, err)
+	}
+	p.Expr().From(fb.NewIIPSource(p.Network(), p.Name()+"_expr", expr).Out())
+}
+
+// Run runs the JSONTransform process
+func (p *JSONTransform) Run() {
+	defer p.CloseOutPorts()
+
+	exprIP, ok := p.Expr().RecvOK()
+	if !ok {
+		p.Fail("No expression received on the expr in-port")
+	}
+	src := fmt.Sprint(exprIP.Data())
+	expr, err := compileJSONExpr(src)
+	if err != nil {
+		p.Failf("Invalid expression (%s): %v", src, err)
+	}
+	// Drain any further expressions, so that upstream does not block
+	go func() {
+		for _, ok := p.Expr().RecvOK(); ok; _, ok = p.Expr().RecvOK() {
+		}
+	}()
+
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		v, encoded := jsonValue(ip)
+		outs, err := expr(v)
+		if err != nil {
+			ip.Failf("Could not evaluate expression (%s): %v", src, err)
+		}
+		for _, out := range outs {
+			var data any = out
+			if encoded {
+				b, err := json.Marshal(out)
+				if err != nil {
+					ip.Failf("Could not encode output as JSON: %v", err)
+				}
+				data = b
+			}
+			newIP := fb.NewPacket(data)
+			newIP.AddTags(ip.Tags())
+			p.Out().SendPacket(newIP)
+		}
+	}
+}
+
+// jsonValue returns the data of the packet ip as a value of the types
+// returned by json.Unmarshal, and whether the data was encoded JSON
+func jsonValue(ip *fb.Packet) (any, bool) {
+	var raw []byte
+	switch data := ip.Data().(type) {
+	case []byte:
+		raw = data
+	case json.RawMessage:
+		raw = data
+	case string:
+		raw = []byte(data)
+	default:
+		// Round-trip other values through JSON, so that they consist of
+		// the types the expressions work with
+		b, err := json.Marshal(data)
+		if err != nil {
+			ip.Failf("Could not convert data (%v) to JSON: %v", data, err)
+		}
+		var v any
+		if err := json.Unmarshal(b, &v); err != nil {
+			ip.Failf("Could not convert data (%v) to JSON: %v", data, err)
+		}
+		return v, false
+	}
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		ip.Failf("Could not parse data as JSON: %v", err)
+	}
+	return v, true
+}
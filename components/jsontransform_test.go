@@ -0,0 +1,115 @@
+package components
+
+import (
+	"testing"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+func TestJSONTransform(t *testing.T) {
+	initTestLogs()
+	net := fb.NewNetwork("test_json_transform")
+	src := newSliceSource(net, "src",
+		taggedPacket([]byte(`{"repo": "flowbase", "commits": [{"id": "a1", "files": 2}, {"id": "b2", "files": 7}]}`), map[string]string{"event": "push"}),
+		fb.NewPacket(map[string]any{"repo": "other", "commits": []any{}}),
+	)
+	transform := NewJSONTransform(net, "transform")
+	transform.SetExpr(`.commits[] | select(.files > 5) | {id, n: .files}`)
+	transform.In().From(src.Out())
+	sink := newCollector(net, "sink")
+	sink.In().From(transform.Out())
+	net.Run()
+
+	assertEqualValues(t, []any{[]byte(`{"id":"b2","n":7}`)}, sink.data())
+	assertEqualValues(t, map[string]string{"event": "push"}, sink.ips[0].Tags())
+}
+
+func TestJSONExpr(t *testing.T) {
+	input := map[string]any{
+		"name":  "run1",
+		"tags":  []any{"a", "b", "c"},
+		"stats": map[string]any{"reads": 120.0, "failed": false},
+		"files": []any{
+			map[string]any{"path": "x.txt", "size": 10.0},
+			map[string]any{"path": "y.csv", "size": 300.0},
+		},
+	}
+	for _, tc := range []struct {
+		expr string
+		want []any
+	}{
+		{`.`, []any{input}},
+		{`.name`, []any{"run1"}},
+		{`.stats.reads`, []any{120.0}},
+		{`."name"`, []any{"run1"}},
+		{`.["name"]`, []any{"run1"}},
+		{`.missing.deeper`, []any{nil}},
+		{`.tags[0], .tags[-1]`, []any{"a", "c"}},
+		{`.tags[1:]`, []any{[]any{"b", "c"}}},
+		{`.tags[]`, []any{"a", "b", "c"}},
+		{`.files[] | select(.size > 100) | .path`, []any{"y.csv"}},
+		{`[.files[].path]`, []any{[]any{"x.txt", "y.csv"}}},
+		{`.files | map(.size)`, []any{[]any{10.0, 300.0}}},
+		{`{name, n: (.tags | length)}`, []any{map[string]any{"name": "run1", "n": 3.0}}},
+		{`.stats | keys`, []any{[]any{"failed", "reads"}}},
+		{`.stats.failed | not`, []any{true}},
+		{`.stats.reads >= 100 and .name == "run1"`, []any{true}},
+		{`has("name"), has("nope")`, []any{true, false}},
+		{`.stats.reads | tostring`, []any{"120"}},
+		{`"12.5" | tonumber`, []any{12.5}},
+		{`.files[0] | type`, []any{"object"}},
+		{`[]`, []any{[]any{}}},
+		{`null, true, 1`, []any{nil, true, 1.0}},
+		{`.stats.reads * 2 - 40 / 4 % 3`, []any{239.0}},
+		{`.stats.reads-1, .tags[-1]`, []any{119.0, "c"}},
+		{`.name + "-" + .tags[0]`, []any{"run1-a"}},
+		{`.tags + ["d"] | length`, []any{4.0}},
+		{`{a: 1} + {b: 2}`, []any{map[string]any{"a": 1.0, "b": 2.0}}},
+		{`.files[] | if .size > 100 then "large" elif .size > 5 then "medium" else "small" end`, []any{"medium", "large"}},
+		{`if .stats.failed then "failed" end`, []any{input}},
+	} {
+		expr, err := compileJSONExpr(tc.expr)
+		if err != nil {
+			t.Errorf("Could not compile %s: %v", tc.expr, err)
+			continue
+		}
+		have, err := expr(input)
+		if err != nil {
+			t.Errorf("Could not evaluate %s: %v", tc.expr, err)
+			continue
+		}
+		assertEqualValues(t, tc.want, have)
+	}
+
+	for _, invalid := range []string{`.a |`, `.[`, `{(.a)}`, `nosuchfunc`, `"unterminated`, `.a ]`, `$novar`, `if .a then 1`} {
+		if _, err := compileJSONExpr(invalid); err == nil {
+			t.Errorf("Expected error compiling invalid expression %s", invalid)
+		}
+	}
+	if _, err := mustCompileForTest(t, `.name[0]`)(input); err == nil {
+		t.Error("Expected error indexing a string with a number")
+	}
+	if _, err := mustCompileForTest(t, `.name - 1`)(input); err == nil {
+		t.Error("Expected error subtracting a number from a string")
+	}
+
+	vars := jsonExprVars{"tags": nil}
+	expr, err := compileJSONExprVars(`$tags.sample + ":" + .name`, vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars["tags"] = map[string]any{"sample": "s1"}
+	have, err := expr(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqualValues(t, []any{"s1:run1"}, have)
+}
+
+func mustCompileForTest(t *testing.T, src string) jsonExpr {
+	expr, err := compileJSONExpr(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return expr
+}
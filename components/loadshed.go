@@ -0,0 +1,66 @@
+package components
+
+import (
+	"sync/atomic"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// LoadShedder passes on the packets it receives, but drops them instead of
+// blocking when the downstream processes are overloaded, that is, when the
+// queue depth of its out-port (see OutPort.QueueDepth) has reached the
+// threshold. This is needed for real-time sources, such as cameras, where
+// blocking the source causes lag. While overloaded, every SampleEvery-th
+// packet can still be passed on, to keep some data flowing.
+type LoadShedder struct {
+	fb.BaseProcess
+	threshold int
+	// SampleEvery makes every SampleEvery-th packet received while
+	// overloaded be passed on anyway. Defaults to 0, which drops all of them.
+	SampleEvery int
+	dropped     int64
+}
+
+// NewLoadShedder returns a new LoadShedder process, dropping packets when
+// threshold packets are queued downstream
+func NewLoadShedder(net *fb.Network, name string, threshold int) *LoadShedder {
+	p := &LoadShedder{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		threshold:   threshold,
+	}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *LoadShedder) In() *fb.InPort { return p.InPort("in") }
+
+// Out returns the out-port
+func (p *LoadShedder) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Dropped returns the number of packets dropped so far
+func (p *LoadShedder) Dropped() int64 { return atomic.LoadInt64(&p.dropped) }
+
+// Run runs the LoadShedder process
+func (p *LoadShedder) Run() {
+	defer p.CloseOutPorts()
+
+	shed := 0
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		if p.Out().QueueDepth() < p.threshold {
+			p.Out().SendPacket(ip)
+			continue
+		}
+		shed++
+		if p.SampleEvery > 0 && shed%p.SampleEvery == 0 {
+			p.Out().SendPacket(ip)
+			continue
+		}
+		atomic.AddInt64(&p.dropped, 1)
+	}
+	if dropped := p.Dropped(); dropped > 0 {
+		fb.Warning.Printf("[Process:%s] Dropped %d packets, as downstream processes were overloaded\n", p.Name(), dropped)
+	}
+}
@@ -0,0 +1,63 @@
+package components
+
+import (
+	"testing"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+func TestLoadShedder(t *testing.T) {
+	for _, tt := range []struct {
+		sampleEvery int
+		want        []any
+		dropped     int64
+	}{
+		{0, []any{1, 2}, 8},
+		{3, []any{1, 2, 5, 8}, 6},
+	} {
+		initTestLogs()
+		net := fb.NewNetwork("TestLoadShedder")
+
+		ips := []*fb.Packet{}
+		for i := 1; i <= 10; i++ {
+			ips = append(ips, fb.NewPacket(i))
+		}
+		src := newSliceSource(net, "src", ips...)
+		shedder := NewLoadShedder(net, "shedder", 2)
+		shedder.SampleEvery = tt.sampleEvery
+		shedder.In().From(src.Out())
+		out := newGatedCollector(net, "out")
+		out.In().From(shedder.Out())
+		// Let packets queue up in the collector until the shedder is done
+		net.Subscribe(fb.EventTypes(fb.EventProcessFinished), func(e *fb.Event) {
+			if e.Process == "shedder" {
+				close(out.gate)
+			}
+		})
+
+		net.Run()
+
+		assertEqualValues(t, tt.want, out.data())
+		if dropped := shedder.Dropped(); dropped != tt.dropped {
+			t.Errorf("Wrong number of dropped packets: %d, wanted %d", dropped, tt.dropped)
+		}
+	}
+}
+
+// gatedCollector is a collector that starts receiving when its gate is closed
+type gatedCollector struct {
+	*collector
+	gate chan struct{}
+}
+
+func newGatedCollector(net *fb.Network, name string) *gatedCollector {
+	p := &gatedCollector{collector: &collector{BaseProcess: fb.NewBaseProcess(net, name)}, gate: make(chan struct{})}
+	p.InitInPort(p, "in")
+	net.AddProc(p)
+	return p
+}
+
+func (p *gatedCollector) Run() {
+	<-p.gate
+	p.collector.Run()
+}
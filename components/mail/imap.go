@@ -0,0 +1,265 @@
+package mail
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// IMAPConfig configures the connection to an IMAP server
+type IMAPConfig struct {
+	// Addr is the host:port of the server, such as "imap.example.com:993"
+	Addr     string
+	Username string
+	Password string
+	// Mailbox is the mailbox to poll. Defaults to "INBOX".
+	Mailbox string
+	// Search is the IMAP search criteria of the messages to fetch. Defaults
+	// to "UNSEEN".
+	Search string
+	// Insecure makes the connection unencrypted, instead of using TLS, such
+	// as for local test servers
+	Insecure bool
+}
+
+// ------------------------------------------------------------------------
+// IMAPSource
+// ------------------------------------------------------------------------
+
+// IMAPSource polls an IMAP mailbox for messages matching the search criteria
+// of its config, unread messages by default, and sends each message as a
+// *Message on its out-port, and each attachment as an *Attachment on its
+// attachments out-port. Fetched messages are marked as read. Packets are
+// tagged with the IMAP UID of the message, as "uid", its sender, as "from",
+// and subject, as "subject", and attachments with their file name, as
+// "filename".
+type IMAPSource struct {
+	fb.BaseProcess
+	config   IMAPConfig
+	interval time.Duration
+	// MaxPolls is the number of times to poll the mailbox, after which the
+	// process finishes. Defaults to 0, which polls until the network is
+	// stopped.
+	MaxPolls int
+}
+
+// NewIMAPSource returns a new IMAPSource process, polling the mailbox of
+// config every interval
+func NewIMAPSource(net *fb.Network, name string, config IMAPConfig, interval time.Duration) *IMAPSource {
+	if config.Mailbox == "" {
+		config.Mailbox = "INBOX"
+	}
+	if config.Search == "" {
+		config.Search = "UNSEEN"
+	}
+	p := &IMAPSource{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		config:      config,
+		interval:    interval,
+	}
+	p.InitOutPort(p, "out")
+	p.InitOutPort(p, "attachments")
+	net.AddProc(p)
+	return p
+}
+
+// Out returns the out-port, on which the messages are sent
+func (p *IMAPSource) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Attachments returns the out-port on which the attachments of the messages
+// are sent
+func (p *IMAPSource) Attachments() *fb.OutPort { return p.OutPort("attachments") }
+
+// Run runs the IMAPSource process
+func (p *IMAPSource) Run() {
+	defer p.CloseOutPorts()
+	clock := p.Network().Clock()
+	for polls := 1; ; polls++ {
+		if err := p.poll(); err != nil {
+			p.Failf("Could not poll mailbox %s at %s: %v", p.config.Mailbox, p.config.Addr, err)
+		}
+		if p.MaxPolls > 0 && polls >= p.MaxPolls {
+			return
+		}
+		clock.Sleep(p.interval)
+	}
+}
+
+// poll fetches and sends the messages matching the search criteria, and
+// marks them as read
+func (p *IMAPSource) poll() error {
+	c, err := dialIMAP(p.config)
+	if err != nil {
+		return err
+	}
+	defer c.close()
+	if _, err := c.cmd("LOGIN %s %s", imapQuote(p.config.Username), imapQuote(p.config.Password)); err != nil {
+		return err
+	}
+	if _, err := c.cmd("SELECT %s", imapQuote(p.config.Mailbox)); err != nil {
+		return err
+	}
+	resps, err := c.cmd("UID SEARCH %s", p.config.Search)
+	if err != nil {
+		return err
+	}
+	uids := []string{}
+	for _, r := range resps {
+		if fields := strings.Fields(r.line); len(fields) > 1 && strings.EqualFold(fields[1], "SEARCH") {
+			uids = append(uids, fields[2:]...)
+		}
+	}
+	for _, uid := range uids {
+		resps, err := c.cmd("UID FETCH %s BODY.PEEK[]", uid)
+		if err != nil {
+			return err
+		}
+		raw := []byte(nil)
+		for _, r := range resps {
+			if len(r.literals) > 0 {
+				raw = r.literals[0]
+			}
+		}
+		if raw == nil {
+			return fmt.Errorf("no message body returned for UID %s", uid)
+		}
+		msg, err := ParseMessage(raw)
+		if err != nil {
+			return fmt.Errorf("could not parse message with UID %s: %v", uid, err)
+		}
+		p.send(uid, msg)
+		if _, err := c.cmd(`UID STORE %s +FLAGS (\Seen)`, uid); err != nil {
+			return err
+		}
+	}
+	c.cmd("LOGOUT")
+	return nil
+}
+
+func (p *IMAPSource) send(uid string, msg *Message) {
+	tags := map[string]string{"uid": uid, "from": msg.From, "subject": msg.Subject}
+	ip := fb.NewPacket(msg)
+	ip.AddTags(tags)
+	p.Out().SendPacket(ip)
+	for _, a := range msg.Attachments {
+		aip := fb.NewPacket(a)
+		aip.AddTags(tags)
+		aip.AddTag("filename", a.Filename)
+		p.Attachments().SendPacket(aip)
+	}
+}
+
+// ------------------------------------------------------------------------
+// IMAP client
+// ------------------------------------------------------------------------
+
+// imapClient is a minimal IMAP4rev1 client, supporting the commands needed
+// by IMAPSource
+type imapClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+	tag  int
+}
+
+// imapResponse is an untagged response line, with the literals ({n} followed
+// by n bytes) it contains
+type imapResponse struct {
+	line     string
+	literals [][]byte
+}
+
+var imapLiteralPtn = regexp.MustCompile(`\{(\d+)\}$`)
+
+func dialIMAP(config IMAPConfig) (*imapClient, error) {
+	var conn net.Conn
+	var err error
+	if config.Insecure {
+		conn, err = net.Dial("tcp", config.Addr)
+	} else {
+		conn, err = tls.Dial("tcp", config.Addr, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &imapClient{conn: conn, r: bufio.NewReader(conn)}
+	greeting, err := c.readResponse()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !strings.HasPrefix(greeting.line, "* OK") && !strings.HasPrefix(greeting.line, "* PREAUTH") {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected greeting: %s", greeting.line)
+	}
+	return c, nil
+}
+
+// cmd sends a command, and returns the untagged responses to it, or an error
+// if it did not complete with OK
+func (c *imapClient) cmd(format string, args ...any) ([]imapResponse, error) {
+	c.tag++
+	tag := "a" + strconv.Itoa(c.tag)
+	command := fmt.Sprintf(format, args...)
+	if _, err := fmt.Fprintf(c.conn, "%s %s\r\n", tag, command); err != nil {
+		return nil, err
+	}
+	resps := []imapResponse{}
+	for {
+		r, err := c.readResponse()
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(r.line, tag+" ") {
+			resps = append(resps, r)
+			continue
+		}
+		status := strings.TrimPrefix(r.line, tag+" ")
+		if !strings.HasPrefix(status, "OK") {
+			// Do not include the password in errors
+			name := strings.Fields(command)[0]
+			return nil, fmt.Errorf("%s failed: %s", name, status)
+		}
+		return resps, nil
+	}
+}
+
+// readResponse reads a response line, along with any literals in it
+func (c *imapClient) readResponse() (imapResponse, error) {
+	r := imapResponse{}
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			return r, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		r.line += line
+		m := imapLiteralPtn.FindStringSubmatch(line)
+		if m == nil {
+			return r, nil
+		}
+		n, _ := strconv.Atoi(m[1])
+		literal := make([]byte, n)
+		if _, err := io.ReadFull(c.r, literal); err != nil {
+			return r, err
+		}
+		r.literals = append(r.literals, literal)
+	}
+}
+
+func (c *imapClient) close() {
+	c.conn.Close()
+}
+
+// imapQuote formats s as an IMAP quoted string
+func imapQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
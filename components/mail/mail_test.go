@@ -0,0 +1,139 @@
+package mail
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flowbase/flowbase/flowbasetest"
+)
+
+// serveIMAP serves a mailbox with the single unread message raw, with UID
+// 42, to one connection, and returns its address and the commands received
+func serveIMAP(t *testing.T, raw []byte) (string, chan []string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := make(chan []string, 1)
+	go func() {
+		defer ln.Close()
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		commands := []string{}
+		defer func() { received <- commands }()
+		r := bufio.NewReader(conn)
+		fmt.Fprint(conn, "* OK IMAP4rev1 ready\r\n")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			tag, command := fields[0], strings.Join(fields[1:], " ")
+			commands = append(commands, command)
+			switch {
+			case strings.HasPrefix(command, "UID SEARCH"):
+				fmt.Fprint(conn, "* SEARCH 42\r\n")
+			case strings.HasPrefix(command, "UID FETCH"):
+				fmt.Fprintf(conn, "* 1 FETCH (UID 42 BODY[] {%d}\r\n%s)\r\n", len(raw), raw)
+			case command == "LOGOUT":
+				fmt.Fprintf(conn, "* BYE\r\n%s OK done\r\n", tag)
+				return
+			}
+			fmt.Fprintf(conn, "%s OK done\r\n", tag)
+		}
+	}()
+	return ln.Addr().String(), received
+}
+
+func TestMessageRoundTrip(t *testing.T) {
+	msg := &Message{
+		From:    "Robot <robot@example.com>",
+		To:      []string{"a@example.com", "b@example.com"},
+		Subject: "Report ready ✓",
+		Date:    time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Text:    "The report is attached.\n",
+		Attachments: []*Attachment{
+			{Filename: "report.csv", ContentType: "text/csv", Data: []byte("a,b\n1,2\n")},
+		},
+	}
+	parsed, err := ParseMessage(msg.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.From != msg.From {
+		t.Errorf("Wrong sender: %s", parsed.From)
+	}
+	if parsed.Subject != msg.Subject || parsed.Text != msg.Text || len(parsed.To) != 2 || !parsed.Date.Equal(msg.Date) {
+		t.Errorf("Message not parsed back correctly: %+v", parsed)
+	}
+	if len(parsed.Attachments) != 1 || parsed.Attachments[0].Filename != "report.csv" || string(parsed.Attachments[0].Data) != "a,b\n1,2\n" {
+		t.Errorf("Attachment not parsed back correctly: %+v", parsed.Attachments)
+	}
+}
+
+func TestIMAPSource(t *testing.T) {
+	raw := (&Message{
+		From:        "sender@example.com",
+		To:          []string{"inbox@example.com"},
+		Subject:     "Data",
+		Text:        "See attachment",
+		Attachments: []*Attachment{{Filename: "data.txt", Data: []byte("123")}},
+	}).Bytes()
+	addr, received := serveIMAP(t, raw)
+
+	net := flowbasetest.NewTestNetwork(t)
+	src := NewIMAPSource(net.Network, "imap", IMAPConfig{Addr: addr, Username: "user", Password: `pa"ss`, Insecure: true}, time.Second)
+	src.MaxPolls = 1
+	messages := flowbasetest.CollectPort[*Message](src.Out())
+	attachments := flowbasetest.CollectPort[*Attachment](src.Attachments())
+	net.Run()
+
+	if msgs := messages.Values(); len(msgs) != 1 || msgs[0].Subject != "Data" {
+		t.Fatalf("Got wrong messages: %v", msgs)
+	}
+	if ip := messages.Packets()[0]; ip.Tag("uid") != "42" || ip.Tag("from") != "sender@example.com" {
+		t.Errorf("Got wrong tags: %v", ip.Tags())
+	}
+	if as := attachments.Values(); len(as) != 1 || string(as[0].Data) != "123" {
+		t.Errorf("Got wrong attachments: %v", as)
+	}
+	want := []string{`LOGIN "user" "pa\"ss"`, `SELECT "INBOX"`, "UID SEARCH UNSEEN", "UID FETCH 42 BODY.PEEK[]", `UID STORE 42 +FLAGS (\Seen)`, "LOGOUT"}
+	if have := <-received; strings.Join(have, "|") != strings.Join(want, "|") {
+		t.Errorf("Got wrong IMAP commands: %v, wanted: %v", have, want)
+	}
+}
+
+func TestSMTPSink(t *testing.T) {
+	type sent struct {
+		from string
+		to   []string
+		msg  []byte
+	}
+	sends := []sent{}
+
+	net := flowbasetest.NewTestNetwork(t)
+	sink := NewSMTPSink(net.Network, "smtp", SMTPConfig{Addr: "localhost:25", From: "robot@example.com"})
+	sink.send = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		sends = append(sends, sent{from, to, msg})
+		return nil
+	}
+	flowbasetest.FeedPort(sink.In(), &Message{From: "Me <me@example.com>", To: []string{"You <you@example.com>"}, Subject: "Hi", Text: "Hello"})
+	net.Run()
+
+	if len(sends) != 1 || sends[0].from != "me@example.com" || sends[0].to[0] != "you@example.com" {
+		t.Fatalf("Got wrong sends: %v", sends)
+	}
+	parsed, err := ParseMessage(sends[0].msg)
+	if err != nil || parsed.Subject != "Hi" || parsed.Text != "Hello" {
+		t.Errorf("Sent wrong message: %+v (%v)", parsed, err)
+	}
+}
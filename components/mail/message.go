@@ -0,0 +1,217 @@
+// Package mail contains components for mail-driven networks: IMAPSource,
+// which polls an IMAP mailbox and sends the new messages, and their
+// attachments, as packets, and SMTPSink, which sends the messages it receives
+// via SMTP.
+package mail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	netmail "net/mail"
+	"strings"
+	"time"
+)
+
+// Message is an email message
+type Message struct {
+	From    string
+	To      []string
+	Cc      []string
+	Subject string
+	Date    time.Time
+	// Text is the plain text body of the message
+	Text        string
+	Attachments []*Attachment
+	// Raw is the message as received, if it was received
+	Raw []byte
+}
+
+// Attachment is a file attached to a message
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+var wordDecoder = &mime.WordDecoder{}
+
+// ParseMessage parses the RFC 5322 message raw, including the plain text
+// body and attachments of MIME multipart messages
+func ParseMessage(raw []byte) (*Message, error) {
+	m, err := netmail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	msg := &Message{Raw: raw}
+	msg.From = decodeHeader(m.Header.Get("From"))
+	msg.Subject = decodeHeader(m.Header.Get("Subject"))
+	msg.To = addressList(m.Header, "To")
+	msg.Cc = addressList(m.Header, "Cc")
+	if date, err := m.Header.Date(); err == nil {
+		msg.Date = date
+	}
+	err = msg.parsePart(m.Header.Get("Content-Type"), m.Header.Get("Content-Transfer-Encoding"), m.Header.Get("Content-Disposition"), m.Body)
+	return msg, err
+}
+
+// parsePart reads the MIME part with the given headers from r, recursing
+// into multipart parts
+func (msg *Message) parsePart(contentType string, encoding string, disposition string, r io.Reader) error {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(r, params["boundary"])
+		for {
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			err = msg.parsePart(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part.Header.Get("Content-Disposition"), part)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := io.ReadAll(decodeTransfer(encoding, r))
+	if err != nil {
+		return err
+	}
+	_, dispParams, _ := mime.ParseMediaType(disposition)
+	filename := dispParams["filename"]
+	if filename == "" {
+		filename = params["name"]
+	}
+	if filename != "" || strings.HasPrefix(disposition, "attachment") {
+		msg.Attachments = append(msg.Attachments, &Attachment{
+			Filename:    decodeHeader(filename),
+			ContentType: mediaType,
+			Data:        data,
+		})
+	} else if mediaType == "text/plain" && msg.Text == "" {
+		// Line breaks are CRLF in messages
+		msg.Text = strings.ReplaceAll(string(data), "\r\n", "\n")
+	}
+	return nil
+}
+
+func decodeTransfer(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, &newlineStripper{r: r})
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	}
+	return r
+}
+
+// newlineStripper removes line breaks, which base64 encoded parts contain
+type newlineStripper struct {
+	r io.Reader
+}
+
+func (s *newlineStripper) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	j := 0
+	for _, b := range p[:n] {
+		if b != '\r' && b != '\n' {
+			p[j] = b
+			j++
+		}
+	}
+	return j, err
+}
+
+func decodeHeader(s string) string {
+	decoded, err := wordDecoder.DecodeHeader(s)
+	if err != nil {
+		return s
+	}
+	return decoded
+}
+
+func addressList(h netmail.Header, key string) []string {
+	addrs, err := h.AddressList(key)
+	if err != nil {
+		if v := h.Get(key); v != "" {
+			return []string{v}
+		}
+		return nil
+	}
+	list := []string{}
+	for _, a := range addrs {
+		list = append(list, a.String())
+	}
+	return list
+}
+
+// Bytes formats the message as an RFC 5322 message, in MIME multipart format
+// if it has attachments
+func (msg *Message) Bytes() []byte {
+	buf := &bytes.Buffer{}
+	header := func(k, v string) { fmt.Fprintf(buf, "%s: %s\r\n", k, v) }
+	header("From", msg.From)
+	header("To", strings.Join(msg.To, ", "))
+	if len(msg.Cc) > 0 {
+		header("Cc", strings.Join(msg.Cc, ", "))
+	}
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	date := msg.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	header("Date", date.Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+
+	if len(msg.Attachments) == 0 {
+		header("Content-Type", `text/plain; charset="utf-8"`)
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		writeQuotedPrintable(buf, msg.Text)
+		return buf.Bytes()
+	}
+
+	mw := multipart.NewWriter(buf)
+	header("Content-Type", fmt.Sprintf(`multipart/mixed; boundary="%s"`, mw.Boundary()))
+	buf.WriteString("\r\n")
+	text, _ := mw.CreatePart(map[string][]string{
+		"Content-Type":              {`text/plain; charset="utf-8"`},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	writeQuotedPrintable(text, msg.Text)
+	for _, a := range msg.Attachments {
+		contentType := a.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		part, _ := mw.CreatePart(map[string][]string{
+			"Content-Type":              {contentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename})},
+		})
+		encoded := base64.StdEncoding.EncodeToString(a.Data)
+		for len(encoded) > 76 {
+			fmt.Fprintf(part, "%s\r\n", encoded[:76])
+			encoded = encoded[76:]
+		}
+		fmt.Fprintf(part, "%s\r\n", encoded)
+	}
+	mw.Close()
+	return buf.Bytes()
+}
+
+func writeQuotedPrintable(w io.Writer, text string) {
+	qw := quotedprintable.NewWriter(w)
+	qw.Write([]byte(text))
+	qw.Close()
+}
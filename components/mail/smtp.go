@@ -0,0 +1,127 @@
+package mail
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// SMTPConfig configures the connection to an SMTP server
+type SMTPConfig struct {
+	// Addr is the host:port of the server, such as "smtp.example.com:587".
+	// STARTTLS is used if the server supports it.
+	Addr string
+	// Username and Password are used for PLAIN authentication, if Username
+	// is set
+	Username string
+	Password string
+	// From is the sender of messages without a sender
+	From string
+}
+
+// SMTPSink sends the messages it receives via SMTP. Packets can contain a
+// *Message, or the text of a message as a string or []byte, in which case the
+// recipients are taken from the tag "to" (comma-separated), and the subject
+// from the tag "subject".
+type SMTPSink struct {
+	fb.BaseProcess
+	config SMTPConfig
+	// send sends the message, which is smtp.SendMail, unless replaced
+	send func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// NewSMTPSink returns a new SMTPSink process, sending via the server of
+// config
+func NewSMTPSink(net *fb.Network, name string, config SMTPConfig) *SMTPSink {
+	p := &SMTPSink{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		config:      config,
+		send:        smtp.SendMail,
+	}
+	p.InitInPort(p, "in")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *SMTPSink) In() *fb.InPort { return p.InPort("in") }
+
+// Run runs the SMTPSink process
+func (p *SMTPSink) Run() {
+	var auth smtp.Auth
+	if p.config.Username != "" {
+		host, _, err := net.SplitHostPort(p.config.Addr)
+		if err != nil {
+			p.Failf("Invalid SMTP server address (%s): %v", p.config.Addr, err)
+		}
+		auth = smtp.PlainAuth("", p.config.Username, p.config.Password, host)
+	}
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		msg := p.message(ip)
+		rcpts := []string{}
+		for _, addr := range append(append([]string{}, msg.To...), msg.Cc...) {
+			rcpt, err := envelopeAddress(addr)
+			if err != nil {
+				ip.Failf("Invalid recipient address (%s): %v", addr, err)
+			}
+			rcpts = append(rcpts, rcpt)
+		}
+		if len(rcpts) == 0 {
+			ip.Fail("Message has no recipients")
+		}
+		from, err := envelopeAddress(msg.From)
+		if err != nil {
+			ip.Failf("Invalid sender address (%s): %v", msg.From, err)
+		}
+		if err := p.send(p.config.Addr, auth, from, rcpts, msg.Bytes()); err != nil {
+			ip.Failf("Could not send message: %v", err)
+		}
+		p.Auditf("Sent message (%s) to %s", msg.Subject, strings.Join(rcpts, ", "))
+	}
+}
+
+// message returns the message to send for the packet ip
+func (p *SMTPSink) message(ip *fb.Packet) *Message {
+	var msg *Message
+	switch data := ip.Data().(type) {
+	case *Message:
+		copied := *data
+		msg = &copied
+	case string:
+		msg = &Message{Text: data}
+	case []byte:
+		msg = &Message{Text: string(data)}
+	default:
+		ip.Failf("Data (%v) of type %T is not a message", ip.Data(), ip.Data())
+	}
+	tags := ip.Tags()
+	if len(msg.To) == 0 && tags["to"] != "" {
+		for _, to := range strings.Split(tags["to"], ",") {
+			msg.To = append(msg.To, strings.TrimSpace(to))
+		}
+	}
+	if msg.Subject == "" {
+		msg.Subject = tags["subject"]
+	}
+	if msg.From == "" {
+		msg.From = p.config.From
+	}
+	return msg
+}
+
+// envelopeAddress returns the bare address of the address addr, which may
+// include a display name
+func envelopeAddress(addr string) (string, error) {
+	a, err := mail.ParseAddress(addr)
+	if err != nil {
+		return "", err
+	}
+	if a.Address == "" {
+		return "", fmt.Errorf("empty address")
+	}
+	return a.Address, nil
+}
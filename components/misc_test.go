@@ -0,0 +1,90 @@
+package components
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// --------------------------------------------------------------------------------
+// Testing Helper stuff
+// --------------------------------------------------------------------------------
+
+func initTestLogs() {
+	if fb.Warning == nil {
+		fb.InitLogWarning()
+	}
+}
+
+// sliceSource sends a fixed list of packets on its out-port
+type sliceSource struct {
+	fb.BaseProcess
+	ips []*fb.Packet
+}
+
+func newSliceSource(net *fb.Network, name string, ips ...*fb.Packet) *sliceSource {
+	p := &sliceSource{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		ips:         ips,
+	}
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+func (p *sliceSource) Out() *fb.OutPort { return p.OutPort("out") }
+
+func (p *sliceSource) Run() {
+	defer p.CloseOutPorts()
+	for _, ip := range p.ips {
+		p.Out().SendPacket(ip)
+	}
+}
+
+// collector collects all packets received on its in-port
+type collector struct {
+	fb.BaseProcess
+	ips  []*fb.Packet
+	lock sync.Mutex
+}
+
+func newCollector(net *fb.Network, name string) *collector {
+	p := &collector{
+		BaseProcess: fb.NewBaseProcess(net, name),
+	}
+	p.InitInPort(p, "in")
+	net.AddProc(p)
+	return p
+}
+
+func (p *collector) In() *fb.InPort { return p.InPort("in") }
+
+func (p *collector) Run() {
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		p.lock.Lock()
+		p.ips = append(p.ips, ip)
+		p.lock.Unlock()
+	}
+}
+
+func (p *collector) data() []any {
+	data := []any{}
+	for _, ip := range p.ips {
+		data = append(data, ip.Data())
+	}
+	return data
+}
+
+func taggedPacket(data any, tags map[string]string) *fb.Packet {
+	ip := fb.NewPacket(data)
+	ip.AddTags(tags)
+	return ip
+}
+
+func assertEqualValues(t *testing.T, expected interface{}, actual interface{}) {
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Values are not equal (Expected: %v, Actual: %v)\n", expected, actual)
+	}
+}
@@ -0,0 +1,181 @@
+// Package notebook contains the NotebookProc component, which executes
+// parameterized Jupyter notebooks, papermill-style, so that analyses written
+// as notebooks can be run as steps of pipelines, once per set of parameters.
+//
+// Notebooks are executed with the papermill command line tool, as a separate
+// process, which injects the parameters in a new cell after the cell tagged
+// "parameters" of the notebook, and runs it with its Jupyter kernel.
+package notebook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// PapermillPath is the papermill executable used to execute notebooks, which
+// is looked up in the PATH by default
+var PapermillPath = "papermill"
+
+// ------------------------------------------------------------------------
+// NotebookProc
+// ------------------------------------------------------------------------
+
+// NotebookProc executes a notebook for each packet of parameters it receives,
+// in a directory of its own, and sends the executed notebook, with its
+// outputs and any errors rendered in it, as a *fb.FileIP on the Notebook
+// out-port, and the files the notebook writes, declared with AddOutput, on
+// out-ports of their own. Parameters are given as a map[string]string, such
+// as sent by ParamSweep, whose values papermill converts to numbers and
+// booleans where possible, or as a JSON object, as a map[string]any, or as
+// []byte or string data. Sent packets get the tags of the parameter packets.
+type NotebookProc struct {
+	fb.BaseProcess
+	notebook    string
+	outputs     map[string]string
+	outputPorts []string
+	// OutDir is the directory the notebooks are executed in, in a directory
+	// per packet, named after the process and the number of the packet,
+	// such as "analysis.0". Defaults to the current directory.
+	OutDir string
+	// Kernel is the name of the Jupyter kernel to execute the notebook with,
+	// instead of the one in its metadata
+	Kernel    string
+	taskCount int
+}
+
+// NewNotebookProc returns a new NotebookProc, executing the notebook at path
+// notebook
+func NewNotebookProc(net *fb.Network, name string, notebook string) *NotebookProc {
+	p := &NotebookProc{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		notebook:    notebook,
+		outputs:     map[string]string{},
+	}
+	p.InitInPort(p, "params")
+	p.InitOutPort(p, "notebook")
+	net.AddProc(p)
+	return p
+}
+
+// AddOutput declares that the notebook writes a file at path, relative to
+// the directory it is executed in, which is sent on the new out-port
+// portName. Notebooks not writing their declared outputs make the process
+// fail.
+func (p *NotebookProc) AddOutput(portName string, path string) {
+	if filepath.IsAbs(path) || strings.HasPrefix(filepath.Clean(path), "..") {
+		p.Failf("Output (%s) of out-port (%s) must be a path inside the directory of the notebook", path, portName)
+	}
+	p.InitOutPort(p, portName)
+	p.outputs[portName] = path
+	p.outputPorts = append(p.outputPorts, portName)
+}
+
+// Params returns the in-port, on which the parameters are received
+func (p *NotebookProc) Params() *fb.InPort { return p.InPort("params") }
+
+// Notebook returns the out-port on which the executed notebooks are sent
+func (p *NotebookProc) Notebook() *fb.OutPort { return p.OutPort("notebook") }
+
+// Out returns the out-port named portName, of an output added with AddOutput
+func (p *NotebookProc) Out(portName string) *fb.OutPort { return p.OutPort(portName) }
+
+// Run runs the NotebookProc process
+func (p *NotebookProc) Run() {
+	defer p.CloseOutPorts()
+	for ip, ok := p.Params().RecvOK(); ok; ip, ok = p.Params().RecvOK() {
+		paramArgs, err := papermillParamArgs(ip.Data())
+		if err != nil {
+			ip.Failf("Could not read notebook parameters: %v", err)
+		}
+		dir := filepath.Join(p.OutDir, fmt.Sprintf("%s.%d", p.Name(), p.taskCount))
+		p.taskCount++
+		if err := os.MkdirAll(dir, 0777); err != nil {
+			ip.Failf("Could not create notebook directory: %v", err)
+		}
+		outNotebook := filepath.Join(dir, filepath.Base(p.notebook))
+		if err := p.execute(outNotebook, dir, paramArgs); err != nil {
+			ip.Failf("Could not execute notebook (%s), see %s: %v", p.notebook, outNotebook, err)
+		}
+
+		p.send(ip, p.Notebook(), outNotebook)
+		for _, portName := range p.outputPorts {
+			path := filepath.Join(dir, p.outputs[portName])
+			if _, err := os.Stat(path); err != nil {
+				ip.Failf("Notebook (%s) did not write its output (%s) for out-port (%s)", p.notebook, p.outputs[portName], portName)
+			}
+			p.send(ip, p.Out(portName), path)
+		}
+	}
+}
+
+// execute runs papermill on the notebook, writing the executed notebook to
+// outNotebook, with the working directory dir
+func (p *NotebookProc) execute(outNotebook string, dir string, paramArgs []string) error {
+	args := []string{"--cwd", dir}
+	if p.Kernel != "" {
+		args = append(args, "--kernel", p.Kernel)
+	}
+	args = append(args, paramArgs...)
+	args = append(args, p.notebook, outNotebook)
+	cmd := exec.Command(PapermillPath, args...)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	p.Auditf("Executing notebook: %s", strings.Join(cmd.Args, " "))
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%v\n%s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// send sends a *fb.FileIP for path on the out-port outPort, with the tags
+// of the parameter packet ip
+func (p *NotebookProc) send(ip *fb.Packet, outPort *fb.OutPort, path string) {
+	newIP := fb.NewPacket(fb.NewFileIP(path))
+	newIP.AddTags(ip.Tags())
+	outPort.SendPacket(newIP)
+}
+
+// papermillParamArgs returns the papermill arguments for the parameters
+// params, as -p flags for string values, which papermill converts to
+// numbers and booleans where possible, and as a -y flag with JSON, which is
+// YAML, for JSON objects
+func papermillParamArgs(params any) ([]string, error) {
+	switch params := params.(type) {
+	case nil:
+		return nil, nil
+	case map[string]string:
+		names := make([]string, 0, len(params))
+		for name := range params {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		args := []string{}
+		for _, name := range names {
+			args = append(args, "-p", name, params[name])
+		}
+		return args, nil
+	case map[string]any:
+		b, err := json.Marshal(params)
+		if err != nil {
+			return nil, err
+		}
+		return []string{"-y", string(b)}, nil
+	case []byte:
+		return papermillParamArgs(string(params))
+	case string:
+		obj := map[string]any{}
+		if err := json.Unmarshal([]byte(params), &obj); err != nil {
+			return nil, fmt.Errorf("parameters are not a JSON object: %v", err)
+		}
+		return []string{"-y", params}, nil
+	}
+	return nil, fmt.Errorf("unsupported parameters of type %T", params)
+}
@@ -0,0 +1,83 @@
+package notebook
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	fb "github.com/flowbase/flowbase"
+	"github.com/flowbase/flowbase/flowbasetest"
+)
+
+// fakePapermill is a stand-in for papermill, copying the notebook to the
+// output notebook, and writing its parameter arguments to params.txt in the
+// working directory
+const fakePapermill = `#!/bin/bash
+params=()
+while [ $# -gt 2 ]; do
+	case "$1" in
+		--cwd) cwd="$2"; shift 2 ;;
+		*) params+=("$1"); shift ;;
+	esac
+done
+cp "$1" "$2"
+echo "${params[@]}" > "$cwd/params.txt"
+`
+
+func TestNotebookProc(t *testing.T) {
+	dir := t.TempDir()
+	papermill := filepath.Join(dir, "papermill")
+	if err := os.WriteFile(papermill, []byte(fakePapermill), 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer func(path string) { PapermillPath = path }(PapermillPath)
+	PapermillPath = papermill
+	notebook := filepath.Join(dir, "analysis.ipynb")
+	if err := os.WriteFile(notebook, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	net := flowbasetest.NewTestNetwork(t)
+	nb := NewNotebookProc(net.Network, "nb", notebook)
+	nb.OutDir = filepath.Join(dir, "out")
+	nb.AddOutput("params", "params.txt")
+	ip := fb.NewPacket(map[string]string{"alpha": "0.5", "sample": "s1"})
+	ip.AddTag("sample", "s1")
+	flowbasetest.FeedPort(nb.Params(), ip, `{"alpha": 1}`)
+	notebooks := flowbasetest.CollectPort[*fb.FileIP](nb.Notebook())
+	params := flowbasetest.CollectPort[*fb.FileIP](nb.Out("params"))
+	net.Run()
+
+	assertEqualValues(t, filepath.Join(dir, "out", "nb.0", "analysis.ipynb"), notebooks.Values()[0].Path())
+	assertEqualValues(t, "s1", notebooks.Packets()[0].Tag("sample"))
+	contents := []string{}
+	for _, fip := range params.Values() {
+		b, err := os.ReadFile(fip.Path())
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents = append(contents, string(b))
+	}
+	assertEqualValues(t, []string{"-p alpha 0.5 -p sample s1\n", "-y {\"alpha\": 1}\n"}, contents)
+}
+
+func TestPapermillParamArgs(t *testing.T) {
+	args, err := papermillParamArgs(map[string]any{"n": 3, "name": "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqualValues(t, []string{"-y", `{"n":3,"name":"x"}`}, args)
+	for _, params := range []any{"[1, 2]", []byte("not json"), 42} {
+		if _, err := papermillParamArgs(params); err == nil {
+			t.Errorf("Expected an error for parameters %v", params)
+		}
+	}
+}
+
+func assertEqualValues(t *testing.T, expected any, actual any) {
+	t.Helper()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Values are not equal (Expected: %v, Actual: %v)", expected, actual)
+	}
+}
@@ -0,0 +1,66 @@
+package notebook
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// outputPrefix prefixes the metadata fields declaring the outputs of
+// NotebookProcs, as output.<port>=<path>
+const outputPrefix = "output."
+
+// The NotebookProc component is registered, with its descriptor, so that it
+// can be used in graph files (see fb.NewNetworkFromGraph)
+func init() {
+	fb.RegisterComponent("NotebookProc", func(net *fb.Network, name string, metadata map[string]string) (fb.Node, error) {
+		notebook, ok := metadata["notebook"]
+		if !ok {
+			return nil, fmt.Errorf("missing notebook metadata for NotebookProc %s", name)
+		}
+		p := NewNotebookProc(net, name, notebook)
+		p.OutDir = metadata["outdir"]
+		p.Kernel = metadata["kernel"]
+		keys := []string{}
+		for key := range metadata {
+			if strings.HasPrefix(key, outputPrefix) {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			p.AddOutput(strings.TrimPrefix(key, outputPrefix), metadata[key])
+		}
+		return p, nil
+	})
+
+	fb.RegisterComponentInfo(fb.ComponentInfo{
+		Name:        "NotebookProc",
+		Version:     fb.Version,
+		Description: "Executes the notebook metadata Jupyter notebook with papermill for each packet of parameters, and sends the executed notebook and the files it writes",
+		Params: []fb.ParamSpec{
+			{Name: "notebook", Type: "string", Description: "The path of the notebook", Required: true},
+			{Name: "outdir", Type: "string", Description: "The directory the notebooks are executed in, in a directory per packet. Defaults to the current directory"},
+			{Name: "kernel", Type: "string", Description: "The Jupyter kernel to execute the notebook with, instead of the one in its metadata"},
+			{Name: "output.<port>", Type: "string", Description: "The path of a file written by the notebook, relative to its directory, sent on the out-port port"},
+		},
+	})
+}
+
+// ComponentMetadata returns the notebook, directory, kernel and outputs of
+// the process
+func (p *NotebookProc) ComponentMetadata() map[string]string {
+	metadata := map[string]string{"notebook": p.notebook}
+	if p.OutDir != "" {
+		metadata["outdir"] = p.OutDir
+	}
+	if p.Kernel != "" {
+		metadata["kernel"] = p.Kernel
+	}
+	for portName, path := range p.outputs {
+		metadata[outputPrefix+portName] = path
+	}
+	return metadata
+}
@@ -0,0 +1,446 @@
+// Package onnx contains the ONNXInfer component, which runs inference with
+// ONNX models on tensor packets, such as for detecting objects in the images
+// of the components/image and components/frame packages with modern models.
+//
+// Models are run with ONNX Runtime, in a Python worker process per
+// ONNXInfer, which needs the onnxruntime (or onnxruntime-gpu) and numpy
+// Python packages, so that no native libraries need to be linked into
+// programs using flowbase. Tensors are exchanged with the worker as raw
+// float32 data over its stdin and stdout.
+package onnx
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	goimage "image"
+	"io"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// PythonPath is the Python executable running the inference workers, which
+// is looked up in the PATH by default
+var PythonPath = "python3"
+
+// workerScript is the Python code of the inference workers. It loads the
+// model with the execution provider of the device, writes a JSON line with
+// the name and shape of the input, and the names of the outputs, and then
+// answers each request, a JSON line with the shape of the input batch
+// followed by its float32 data, with a JSON line with the shapes of the
+// outputs followed by their float32 data, or with a JSON line with an error.
+const workerScript = `
+import json, sys
+import numpy as np
+import onnxruntime as ort
+
+def write(msg):
+    out.write((json.dumps(msg) + "\n").encode())
+    out.flush()
+
+out, stdin = sys.stdout.buffer, sys.stdin.buffer
+model, device = sys.argv[1], sys.argv[2]
+kind, _, index = device.partition(":")
+opts = {"device_id": int(index or 0)}
+provider = {
+    "cpu": "CPUExecutionProvider",
+    "cuda": ("CUDAExecutionProvider", opts),
+    "tensorrt": ("TensorrtExecutionProvider", opts),
+    "coreml": "CoreMLExecutionProvider",
+    "dml": ("DmlExecutionProvider", opts),
+}[kind]
+try:
+    sess = ort.InferenceSession(model, providers=[provider, "CPUExecutionProvider"])
+    name = provider if isinstance(provider, str) else provider[0]
+    if sess.get_providers()[0] != name:
+        raise RuntimeError("execution provider %s is not available, only %s" % (name, ", ".join(ort.get_available_providers())))
+except Exception as e:
+    write({"error": str(e)})
+    sys.exit(1)
+inp = sess.get_inputs()[0]
+write({
+    "input": inp.name,
+    "shape": [d if isinstance(d, int) else None for d in inp.shape],
+    "outputs": [o.name for o in sess.get_outputs()],
+})
+while True:
+    line = stdin.readline()
+    if not line:
+        break
+    shape = json.loads(line)["shape"]
+    x = np.frombuffer(stdin.read(4 * int(np.prod(shape))), dtype="<f4").reshape(shape)
+    try:
+        results = [np.ascontiguousarray(r, dtype="<f4") for r in sess.run(None, {inp.name: x})]
+    except Exception as e:
+        write({"error": str(e)})
+        continue
+    write({"shapes": [list(r.shape) for r in results]})
+    for r in results:
+        out.write(r.tobytes())
+    out.flush()
+`
+
+// devices are the kinds of devices models can be run on, which are mapped to
+// ONNX Runtime execution providers by workerScript
+var devices = map[string]bool{"cpu": true, "cuda": true, "tensorrt": true, "coreml": true, "dml": true}
+
+// checkDevice returns an error if device is not a known device, of the form
+// kind or kind:index, such as "cpu" or "cuda:1"
+func checkDevice(device string) error {
+	kind := device
+	i := strings.Index(device, ":")
+	if i >= 0 {
+		kind = device[:i]
+	}
+	if !devices[kind] {
+		return fmt.Errorf("unknown device %q, expected cpu, cuda, tensorrt, coreml or dml, optionally followed by :index", device)
+	}
+	if i >= 0 {
+		if n, err := strconv.Atoi(device[i+1:]); err != nil || n < 0 {
+			return fmt.Errorf("invalid device index in %q", device)
+		}
+	}
+	return nil
+}
+
+// ------------------------------------------------------------------------
+// ONNXInfer
+// ------------------------------------------------------------------------
+
+// ONNXInfer runs an ONNX model on the *Tensor or image.Image data of the
+// packets it receives, and sends on the outputs of the model, as *Tensors,
+// with the tags of the packets. Images are converted with ImageTensor.
+//
+// Tensors are the inputs of single samples, without the batch dimension, and
+// packets already queued on the in-port when a packet is received are run as
+// a batch, up to BatchSize packets, so that batches grow with the load
+// without delaying packets when there is none. The model needs to have a
+// single float32 input, with the batch as its first dimension, and outputs
+// with the batch as their first dimension, which are sent without it.
+//
+// The model is loaded when the process starts, and run WarmUp times on
+// zeros before the first batch, so that the time of the first inferences,
+// with the allocation of buffers and the compilation of kernels, does not
+// add to the latency of the first packets.
+type ONNXInfer struct {
+	fb.BaseProcess
+	model   string
+	outputs []string
+	// Device is the device the model is run on: "cpu" (the default),
+	// "cuda", "tensorrt", "coreml" or "dml", optionally followed by the index
+	// of the device, such as "cuda:1". The process fails if the device is
+	// not available.
+	Device string
+	// BatchSize is the maximum number of packets run as one batch. Defaults
+	// to 8.
+	BatchSize int
+	// WarmUp is the number of times the model is run before the first batch.
+	// Defaults to 1.
+	WarmUp int
+}
+
+// NewONNXInfer returns a new ONNXInfer process, running the ONNX model at
+// path model, and sending the model outputs named outputs on out-ports with
+// the same names, or the first model output on the out-port "out" if no
+// outputs are given
+func NewONNXInfer(net *fb.Network, name string, model string, outputs ...string) *ONNXInfer {
+	p := &ONNXInfer{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		model:       model,
+		outputs:     outputs,
+		Device:      "cpu",
+		BatchSize:   8,
+		WarmUp:      1,
+	}
+	p.InitInPort(p, "in")
+	if len(outputs) == 0 {
+		p.InitOutPort(p, "out")
+	}
+	for _, output := range outputs {
+		p.InitOutPort(p, output)
+	}
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *ONNXInfer) In() *fb.InPort { return p.InPort("in") }
+
+// Out returns the out-port named portName, which is "out", or the name of a
+// model output given to NewONNXInfer
+func (p *ONNXInfer) Out(portName string) *fb.OutPort { return p.OutPort(portName) }
+
+// Run runs the ONNXInfer process
+func (p *ONNXInfer) Run() {
+	defer p.CloseOutPorts()
+	if err := checkDevice(p.Device); err != nil {
+		p.Failf("Could not load model (%s): %v", p.model, err)
+	}
+	w, err := startWorker(p.model, p.Device)
+	if err != nil {
+		p.Failf("Could not load model (%s): %v", p.model, err)
+	}
+	defer w.close()
+	p.Auditf("Loaded model %s on device %s", p.model, p.Device)
+
+	// The out-ports are sent the outputs with their indexes
+	outIndexes := map[string]int{}
+	if len(p.outputs) == 0 {
+		outIndexes["out"] = 0
+	}
+	for _, output := range p.outputs {
+		i := indexOf(w.outputs, output)
+		if i < 0 {
+			p.Failf("Model (%s) has no output named %s, only: %s", p.model, output, strings.Join(w.outputs, ", "))
+		}
+		outIndexes[output] = i
+	}
+
+	batchSize := p.BatchSize
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	// Models with dynamic dimensions, apart from the batch dimension, are
+	// warmed up with the shape of the first batch
+	warmedUp := false
+	if shape, ok := w.sampleShape(); ok {
+		p.warmUp(w, shape, batchSize)
+		warmedUp = true
+	}
+	// pending is a packet received for a batch it could not be part of, with
+	// its tensor pendingTensor
+	var pending *fb.Packet
+	var pendingTensor *Tensor
+	for {
+		ip, tensor := pending, pendingTensor
+		pending, pendingTensor = nil, nil
+		if ip == nil {
+			var ok bool
+			if ip, ok = p.In().RecvOK(); !ok {
+				return
+			}
+			tensor = p.tensor(ip)
+		}
+		ips := []*fb.Packet{ip}
+		tensors := []*Tensor{tensor}
+		// Tensors of other shapes can not be batched, and are run in the next
+		// batch
+		for len(ips) < batchSize && p.In().Len() > 0 {
+			next, ok := p.In().RecvOK()
+			if !ok {
+				break
+			}
+			t := p.tensor(next)
+			if !equalShapes(t.Shape, tensors[0].Shape) {
+				pending, pendingTensor = next, t
+				break
+			}
+			ips = append(ips, next)
+			tensors = append(tensors, t)
+		}
+
+		if !warmedUp {
+			p.warmUp(w, tensors[0].Shape, batchSize)
+			warmedUp = true
+		}
+		results, err := w.infer(tensors)
+		if err != nil {
+			ips[0].Failf("Could not run model (%s) on batch of %d packets: %v", p.model, len(ips), err)
+		}
+		for i, ip := range ips {
+			for portName, index := range outIndexes {
+				newIP := fb.NewPacket(results[index][i])
+				newIP.AddTags(ip.Tags())
+				p.Out(portName).SendPacket(newIP)
+			}
+		}
+	}
+}
+
+// tensor returns the data of the packet ip as a tensor
+func (p *ONNXInfer) tensor(ip *fb.Packet) *Tensor {
+	switch data := ip.Data().(type) {
+	case *Tensor:
+		return data
+	case goimage.Image:
+		return ImageTensor(data)
+	}
+	ip.Failf("Data (%v) of type %T is neither a tensor nor an image", ip.Data(), ip.Data())
+	return nil
+}
+
+// warmUp runs the model WarmUp times on batches of batchSize samples of
+// zeros, of the shape shape
+func (p *ONNXInfer) warmUp(w *worker, shape []int, batchSize int) {
+	if p.WarmUp <= 0 {
+		return
+	}
+	zeros := make([]*Tensor, batchSize)
+	for i := range zeros {
+		zeros[i] = &Tensor{Shape: shape, Data: make([]float32, numElements(shape))}
+	}
+	for i := 0; i < p.WarmUp; i++ {
+		if _, err := w.infer(zeros); err != nil {
+			p.Failf("Could not warm up model (%s): %v", p.model, err)
+		}
+	}
+}
+
+func indexOf(names []string, name string) int {
+	for i, n := range names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// ------------------------------------------------------------------------
+// Inference worker
+// ------------------------------------------------------------------------
+
+// worker is a Python process running a model, with workerScript
+type worker struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	stderr *bytes.Buffer
+	// inputShape is the shape of the model input, with nil for dynamic
+	// dimensions
+	inputShape []*int
+	outputs    []string
+}
+
+// workerMessage is a JSON line written by a worker
+type workerMessage struct {
+	Error string `json:"error"`
+	// Input, Shape and Outputs describe the model, after it is loaded
+	Input   string   `json:"input"`
+	Shape   []*int   `json:"shape"`
+	Outputs []string `json:"outputs"`
+	// Shapes are the shapes of the outputs of an inference
+	Shapes [][]int `json:"shapes"`
+}
+
+// startWorker starts a worker running model on device, and waits until the
+// model is loaded
+func startWorker(model string, device string) (*worker, error) {
+	cmd := exec.Command(PythonPath, "-c", workerScript, model, device)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	w := &worker{cmd: cmd, stdin: stdin, stdout: bufio.NewReader(stdout), stderr: &bytes.Buffer{}}
+	cmd.Stderr = w.stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("could not start inference worker: %v", err)
+	}
+	msg, err := w.readMessage()
+	if err != nil {
+		w.close()
+		return nil, err
+	}
+	w.inputShape, w.outputs = msg.Shape, msg.Outputs
+	return w, nil
+}
+
+// sampleShape returns the shape of the samples of the model input, without
+// the batch dimension, and whether it is known, which it is not if it has
+// dynamic dimensions
+func (w *worker) sampleShape() ([]int, bool) {
+	if len(w.inputShape) == 0 {
+		return nil, false
+	}
+	shape := []int{}
+	for _, d := range w.inputShape[1:] {
+		if d == nil {
+			return nil, false
+		}
+		shape = append(shape, *d)
+	}
+	return shape, true
+}
+
+// infer runs the model on the batch of tensors, which need to have the same
+// shape, and returns the outputs for each tensor, by output index
+func (w *worker) infer(tensors []*Tensor) ([][]*Tensor, error) {
+	shape := append([]int{len(tensors)}, tensors[0].Shape...)
+	header, err := json.Marshal(map[string][]int{"shape": shape})
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.NewBuffer(append(header, '\n'))
+	for _, t := range tensors {
+		if err := binary.Write(buf, binary.LittleEndian, t.Data); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := w.stdin.Write(buf.Bytes()); err != nil {
+		return nil, w.failed(err)
+	}
+
+	msg, err := w.readMessage()
+	if err != nil {
+		return nil, err
+	}
+	results := make([][]*Tensor, len(msg.Shapes))
+	for i, outShape := range msg.Shapes {
+		if len(outShape) == 0 || outShape[0] != len(tensors) {
+			return nil, fmt.Errorf("output %d of shape %v does not have the batch size %d as its first dimension", i, outShape, len(tensors))
+		}
+		data := make([]float32, numElements(outShape))
+		raw := make([]byte, 4*len(data))
+		if _, err := io.ReadFull(w.stdout, raw); err != nil {
+			return nil, w.failed(err)
+		}
+		for j := range data {
+			data[j] = math.Float32frombits(binary.LittleEndian.Uint32(raw[4*j:]))
+		}
+		sampleShape := outShape[1:]
+		n := numElements(sampleShape)
+		for j := range tensors {
+			results[i] = append(results[i], &Tensor{Shape: sampleShape, Data: data[j*n : (j+1)*n : (j+1)*n]})
+		}
+	}
+	return results, nil
+}
+
+// readMessage reads the next JSON line written by the worker
+func (w *worker) readMessage() (*workerMessage, error) {
+	line, err := w.stdout.ReadBytes('\n')
+	if err != nil {
+		return nil, w.failed(err)
+	}
+	msg := &workerMessage{}
+	if err := json.Unmarshal(line, msg); err != nil {
+		return nil, fmt.Errorf("invalid message from inference worker: %v", err)
+	}
+	if msg.Error != "" {
+		return nil, fmt.Errorf("%s", msg.Error)
+	}
+	return msg, nil
+}
+
+// failed returns an error for the worker having stopped unexpectedly, with
+// what it wrote to stderr
+func (w *worker) failed(err error) error {
+	w.stdin.Close()
+	w.cmd.Wait()
+	return fmt.Errorf("inference worker failed: %v\n%s", err, strings.TrimSpace(w.stderr.String()))
+}
+
+// close stops the worker, by closing its stdin
+func (w *worker) close() {
+	w.stdin.Close()
+	w.cmd.Wait()
+}
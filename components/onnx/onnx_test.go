@@ -0,0 +1,112 @@
+package onnx
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	goimage "image"
+	"image/color"
+	"os"
+	"reflect"
+	"testing"
+
+	fb "github.com/flowbase/flowbase"
+	"github.com/flowbase/flowbase/flowbasetest"
+)
+
+func TestMain(m *testing.M) {
+	if os.Getenv("FLOWBASE_FAKE_ONNX_WORKER") == "1" {
+		runFakeWorker()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runFakeWorker implements the protocol of workerScript for a model with an
+// input of shape [batch, 2], and the outputs "double", with the input times
+// two, and "calls", with the number of inferences run so far
+func runFakeWorker() {
+	in := bufio.NewReader(os.Stdin)
+	enc := json.NewEncoder(os.Stdout)
+	enc.Encode(map[string]any{"input": "x", "shape": []any{nil, 2}, "outputs": []string{"double", "calls"}})
+	for calls := 1; ; calls++ {
+		line, err := in.ReadBytes('\n')
+		if err != nil {
+			return
+		}
+		req := struct{ Shape []int }{}
+		json.Unmarshal(line, &req)
+		x := make([]float32, numElements(req.Shape))
+		binary.Read(in, binary.LittleEndian, x)
+		for i := range x {
+			x[i] *= 2
+		}
+		n := req.Shape[0]
+		counts := make([]float32, n)
+		for i := range counts {
+			counts[i] = float32(calls)
+		}
+		enc.Encode(map[string]any{"shapes": [][]int{req.Shape, {n, 1}}})
+		binary.Write(os.Stdout, binary.LittleEndian, x)
+		binary.Write(os.Stdout, binary.LittleEndian, counts)
+	}
+}
+
+func TestONNXInfer(t *testing.T) {
+	defer func(path string) { PythonPath = path }(PythonPath)
+	PythonPath = os.Args[0]
+	t.Setenv("FLOWBASE_FAKE_ONNX_WORKER", "1")
+
+	net := flowbasetest.NewTestNetwork(t)
+	infer := NewONNXInfer(net.Network, "infer", "model.onnx", "double", "calls")
+	infer.WarmUp = 2
+	ips := []any{}
+	for i, sample := range []string{"a", "b", "c"} {
+		ip := fb.NewPacket(&Tensor{Shape: []int{2}, Data: []float32{float32(2 * i), float32(2*i + 1)}})
+		ip.AddTag("sample", sample)
+		ips = append(ips, ip)
+	}
+	flowbasetest.FeedPort(infer.In(), ips...)
+	doubled := flowbasetest.CollectPort[*Tensor](infer.Out("double"))
+	calls := flowbasetest.CollectPort[*Tensor](infer.Out("calls"))
+	net.Run()
+
+	data := [][]float32{}
+	for _, tensor := range doubled.Values() {
+		assertEqualValues(t, []int{2}, tensor.Shape)
+		data = append(data, tensor.Data)
+	}
+	assertEqualValues(t, [][]float32{{0, 2}, {4, 6}, {8, 10}}, data)
+	assertEqualValues(t, "c", doubled.Packets()[2].Tag("sample"))
+	// The model input has a static sample shape, so that the model is warmed
+	// up before the first batch
+	assertEqualValues(t, []float32{3}, calls.Values()[0].Data)
+}
+
+func TestCheckDevice(t *testing.T) {
+	for _, device := range []string{"cpu", "cuda", "cuda:1", "tensorrt:0", "coreml", "dml"} {
+		if err := checkDevice(device); err != nil {
+			t.Errorf("Expected device %s to be valid, got: %v", device, err)
+		}
+	}
+	for _, device := range []string{"", "gpu", "cuda:", "cuda:x", "cuda:-1"} {
+		if err := checkDevice(device); err == nil {
+			t.Errorf("Expected an error for device %q", device)
+		}
+	}
+}
+
+func TestImageTensor(t *testing.T) {
+	img := goimage.NewRGBA(goimage.Rect(0, 0, 2, 1))
+	img.Set(1, 0, color.RGBA{R: 255, B: 51, A: 255})
+	tensor := ImageTensor(img)
+	assertEqualValues(t, []int{3, 1, 2}, tensor.Shape)
+	assertEqualValues(t, []float32{0, 1, 0, 0, 0, 0.2}, tensor.Data)
+}
+
+func assertEqualValues(t *testing.T, expected any, actual any) {
+	t.Helper()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Values are not equal (Expected: %v, Actual: %v)", expected, actual)
+	}
+}
@@ -0,0 +1,74 @@
+package onnx
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// The ONNXInfer component is registered, with its descriptor, so that it can
+// be used in graph files (see fb.NewNetworkFromGraph)
+func init() {
+	fb.RegisterComponent("ONNXInfer", func(net *fb.Network, name string, metadata map[string]string) (fb.Node, error) {
+		model, ok := metadata["model"]
+		if !ok {
+			return nil, fmt.Errorf("missing model metadata for ONNXInfer %s", name)
+		}
+		if device, ok := metadata["device"]; ok {
+			if err := checkDevice(device); err != nil {
+				return nil, err
+			}
+		}
+		ints := map[string]int{}
+		for _, key := range []string{"batchsize", "warmup"} {
+			if value, ok := metadata[key]; ok {
+				n, err := strconv.Atoi(value)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("invalid %s metadata: %s", key, value)
+				}
+				ints[key] = n
+			}
+		}
+		p := NewONNXInfer(net, name, model, strings.Fields(metadata["outputs"])...)
+		if device, ok := metadata["device"]; ok {
+			p.Device = device
+		}
+		if n, ok := ints["batchsize"]; ok {
+			p.BatchSize = n
+		}
+		if n, ok := ints["warmup"]; ok {
+			p.WarmUp = n
+		}
+		return p, nil
+	})
+
+	fb.RegisterComponentInfo(fb.ComponentInfo{
+		Name:        "ONNXInfer",
+		Version:     fb.Version,
+		Description: "Runs the model metadata ONNX model on the tensors or images it receives, in batches, and sends on the model outputs as tensors",
+		Params: []fb.ParamSpec{
+			{Name: "model", Type: "string", Description: "The path of the ONNX model", Required: true},
+			{Name: "outputs", Type: "string", Description: "The names of the model outputs to send, on out-ports of the same names, space-separated. Defaults to the first output, on the out-port out"},
+			{Name: "device", Type: "string", Description: "The device to run the model on: cpu (the default), cuda, tensorrt, coreml or dml, optionally followed by :index"},
+			{Name: "batchsize", Type: "int", Description: "The maximum number of packets run as one batch. Defaults to 8"},
+			{Name: "warmup", Type: "int", Description: "The number of times the model is run before the first batch. Defaults to 1"},
+		},
+	})
+}
+
+// ComponentMetadata returns the model, outputs, device, batch size and
+// warm-up of the process
+func (p *ONNXInfer) ComponentMetadata() map[string]string {
+	metadata := map[string]string{
+		"model":     p.model,
+		"device":    p.Device,
+		"batchsize": strconv.Itoa(p.BatchSize),
+		"warmup":    strconv.Itoa(p.WarmUp),
+	}
+	if len(p.outputs) > 0 {
+		metadata["outputs"] = strings.Join(p.outputs, " ")
+	}
+	return metadata
+}
@@ -0,0 +1,65 @@
+package onnx
+
+import (
+	"fmt"
+	goimage "image"
+)
+
+// Tensor is a dense float32 tensor, in row-major order
+type Tensor struct {
+	Shape []int
+	Data  []float32
+}
+
+// NewTensor returns a new Tensor with the shape shape and the data data,
+// which needs to have as many values as the shape has elements
+func NewTensor(shape []int, data []float32) (*Tensor, error) {
+	if n := numElements(shape); n != len(data) {
+		return nil, fmt.Errorf("tensor of shape %v needs %d values, got %d", shape, n, len(data))
+	}
+	return &Tensor{Shape: shape, Data: data}, nil
+}
+
+// ImageTensor returns the pixels of img as a tensor of shape
+// [3, height, width], with the red, green and blue channels scaled to [0, 1],
+// which is the input of most vision models, apart from the batch dimension
+func ImageTensor(img goimage.Image) *Tensor {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	data := make([]float32, 3*w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			i := y*w + x
+			data[i] = float32(r) / 0xffff
+			data[w*h+i] = float32(g) / 0xffff
+			data[2*w*h+i] = float32(b) / 0xffff
+		}
+	}
+	return &Tensor{Shape: []int{3, h, w}, Data: data}
+}
+
+// String returns a short description of the tensor, with its shape
+func (t *Tensor) String() string {
+	return fmt.Sprintf("Tensor%v", t.Shape)
+}
+
+func numElements(shape []int) int {
+	n := 1
+	for _, d := range shape {
+		n *= d
+	}
+	return n
+}
+
+func equalShapes(a []int, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
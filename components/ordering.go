@@ -0,0 +1,145 @@
+package components
+
+import (
+	"sort"
+	"strconv"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// ------------------------------------------------------------------------
+// Sequence
+// ------------------------------------------------------------------------
+
+// Sequence numbers the packets it receives, by tagging them with their
+// sequence number, starting from 0, before a stage which is run in parallel,
+// such as a DynamicFanOut, so that their original order can be restored by
+// a Reorder process after the stage
+type Sequence struct {
+	fb.BaseProcess
+	tag string
+	// Lanes makes the packets also be tagged with their lane, as "lane",
+	// assigned round-robin among Lanes lanes, for distributing them over
+	// parallel instances, such as with a DynamicFanOut on the "lane" tag
+	Lanes int
+}
+
+// NewSequence returns a new Sequence process, tagging packets with their
+// sequence number as tag
+func NewSequence(net *fb.Network, name string, tag string) *Sequence {
+	p := &Sequence{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		tag:         tag,
+	}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *Sequence) In() *fb.InPort { return p.InPort("in") }
+
+// Out returns the out-port
+func (p *Sequence) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Run runs the Sequence process
+func (p *Sequence) Run() {
+	defer p.CloseOutPorts()
+	seq := 0
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		ip.AddTag(p.tag, strconv.Itoa(seq))
+		if p.Lanes > 0 {
+			ip.AddTag("lane", strconv.Itoa(seq%p.Lanes))
+		}
+		p.Out().SendPacket(ip)
+		seq++
+	}
+}
+
+// ------------------------------------------------------------------------
+// Reorder
+// ------------------------------------------------------------------------
+
+// Reorder restores the original order of packets numbered by a Sequence
+// process, which may have been lost in a stage run in parallel. Packets are
+// buffered until all packets before them have been sent on. Since packets
+// dropped by the stage would hold up all later ones, Reorder skips over the
+// missing numbers when more than MaxPending packets are buffered, and when the
+// input stream ends. Skipped packets arriving later are sent on right away.
+type Reorder struct {
+	fb.BaseProcess
+	tag string
+	// MaxPending is the number of buffered packets above which missing
+	// packets are skipped. Defaults to 0, which buffers without limit.
+	MaxPending int
+}
+
+// NewReorder returns a new Reorder process, ordering packets by the sequence
+// number in the tag tag
+func NewReorder(net *fb.Network, name string, tag string) *Reorder {
+	p := &Reorder{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		tag:         tag,
+	}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *Reorder) In() *fb.InPort { return p.InPort("in") }
+
+// Out returns the out-port
+func (p *Reorder) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Run runs the Reorder process
+func (p *Reorder) Run() {
+	defer p.CloseOutPorts()
+
+	pending := map[int]*fb.Packet{}
+	next := 0
+	sendReady := func() {
+		for ip, ok := pending[next]; ok; ip, ok = pending[next] {
+			p.Out().SendPacket(ip)
+			delete(pending, next)
+			next++
+		}
+	}
+	skip := func() {
+		first := firstSeq(pending)
+		fb.Warning.Printf("[Process:%s] Skipping missing packets %d to %d\n", p.Name(), next, first-1)
+		next = first
+		sendReady()
+	}
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		seq, err := strconv.Atoi(ip.Tag(p.tag))
+		if err != nil {
+			ip.Failf("Could not parse sequence number in tag %s: %v", p.tag, err)
+		}
+		if seq < next {
+			fb.Warning.Printf("[Process:%s] Sending on packet %d late, as it was skipped\n", p.Name(), seq)
+			p.Out().SendPacket(ip)
+			continue
+		}
+		pending[seq] = ip
+		sendReady()
+		if p.MaxPending > 0 && len(pending) > p.MaxPending {
+			skip()
+		}
+	}
+	for len(pending) > 0 {
+		skip()
+	}
+}
+
+// firstSeq returns the smallest sequence number in pending
+func firstSeq(pending map[int]*fb.Packet) int {
+	seqs := make([]int, 0, len(pending))
+	for seq := range pending {
+		seqs = append(seqs, seq)
+	}
+	sort.Ints(seqs)
+	return seqs[0]
+}
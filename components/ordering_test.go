@@ -0,0 +1,82 @@
+package components
+
+import (
+	"strconv"
+	"testing"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+func TestReorder(t *testing.T) {
+	initTestLogs()
+	net := fb.NewNetwork("TestReorder")
+
+	ips := []*fb.Packet{}
+	// 3 is missing
+	for _, seq := range []int{2, 0, 4, 1, 6, 5} {
+		ips = append(ips, taggedPacket(seq, map[string]string{"seq": strconv.Itoa(seq)}))
+	}
+	src := newSliceSource(net, "src", ips...)
+	reorder := NewReorder(net, "reorder", "seq")
+	reorder.In().From(src.Out())
+	out := newCollector(net, "out")
+	out.In().From(reorder.Out())
+
+	net.Run()
+
+	assertEqualValues(t, []any{0, 1, 2, 4, 5, 6}, out.data())
+}
+
+func TestReorderMaxPending(t *testing.T) {
+	initTestLogs()
+	net := fb.NewNetwork("TestReorderMaxPending")
+
+	ips := []*fb.Packet{}
+	for _, seq := range []int{1, 2, 3, 0} {
+		ips = append(ips, taggedPacket(seq, map[string]string{"seq": strconv.Itoa(seq)}))
+	}
+	src := newSliceSource(net, "src", ips...)
+	reorder := NewReorder(net, "reorder", "seq")
+	reorder.MaxPending = 2
+	reorder.In().From(src.Out())
+	out := newCollector(net, "out")
+	out.In().From(reorder.Out())
+
+	net.Run()
+
+	assertEqualValues(t, []any{1, 2, 3, 0}, out.data())
+}
+
+func TestSequenceParallelLanes(t *testing.T) {
+	initTestLogs()
+	net := fb.NewNetwork("TestSequenceParallelLanes")
+
+	ips := []*fb.Packet{}
+	for i := 0; i < 20; i++ {
+		ips = append(ips, fb.NewPacket(i))
+	}
+	src := newSliceSource(net, "src", ips...)
+	seq := NewSequence(net, "seq", "seq")
+	seq.Lanes = 4
+	seq.In().From(src.Out())
+	fan := NewDynamicFanOut(net, "fan", "lane", func(branchNet *fb.Network, key string) (*fb.InPort, *fb.OutPort) {
+		d := NewDelay(branchNet, "delay", 0)
+		return d.In(), d.Out()
+	})
+	fan.In().From(seq.Out())
+	reorder := NewReorder(net, "reorder", "seq")
+	reorder.In().From(fan.Out())
+	out := newCollector(net, "out")
+	out.In().From(reorder.Out())
+
+	net.Run()
+
+	want := []any{}
+	for i := 0; i < 20; i++ {
+		want = append(want, i)
+	}
+	assertEqualValues(t, want, out.data())
+	if lane := out.ips[6].Tag("lane"); lane != "2" {
+		t.Errorf("Wrong lane tag: %s", lane)
+	}
+}
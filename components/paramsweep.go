@@ -0,0 +1,160 @@
+package components
+
+import (
+	"math/rand"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// SweepMode is the way ParamSweep combines the values of its parameters
+type SweepMode int
+
+const (
+	// SweepCartesian sends every combination of the parameter values (the
+	// cartesian product)
+	SweepCartesian SweepMode = iota
+	// SweepZip pairs the values of all parameters by position, sending as
+	// many combinations as the shortest list of values has
+	SweepZip
+	// SweepLatinHypercube sends a Latin hypercube sample of the combinations,
+	// where the values of each parameter are spread evenly over the samples,
+	// which is useful for large parameter spaces (see SetLatinHypercube)
+	SweepLatinHypercube
+)
+
+// ParamSweep sends combinations of values of named parameters, such as for
+// running a tool with every combination of its settings. Each combination is
+// sent as a packet whose data is a map[string]string of parameter values,
+// with the same values as tags, so that they can be used in {t:name}
+// placeholders of ExecProcs.
+type ParamSweep struct {
+	fb.BaseProcess
+	names  []string
+	values map[string][]string
+	// Mode is how the parameter values are combined. Defaults to
+	// SweepCartesian.
+	Mode    SweepMode
+	samples int
+	seed    int64
+}
+
+// NewParamSweep returns a new ParamSweep process, without parameters
+func NewParamSweep(net *fb.Network, name string) *ParamSweep {
+	p := &ParamSweep{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		values:      map[string][]string{},
+	}
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// Out returns the out-port, on which the parameter combinations are sent
+func (p *ParamSweep) Out() *fb.OutPort { return p.OutPort("out") }
+
+// AddParam adds the parameter name, with the values values. In cartesian
+// mode, the values of the parameters added first vary the slowest.
+func (p *ParamSweep) AddParam(name string, values ...string) {
+	if _, ok := p.values[name]; !ok {
+		p.names = append(p.names, name)
+	}
+	p.values[name] = values
+}
+
+// SetLatinHypercube makes the process send samples Latin hypercube samples
+// of the parameter combinations, drawn with the random seed seed
+func (p *ParamSweep) SetLatinHypercube(samples int, seed int64) {
+	p.Mode = SweepLatinHypercube
+	p.samples = samples
+	p.seed = seed
+}
+
+// Run sends the parameter combinations
+func (p *ParamSweep) Run() {
+	defer p.CloseOutPorts()
+	for _, combination := range p.Combinations() {
+		ip := fb.NewPacket(combination)
+		ip.AddTags(combination)
+		p.Out().SendPacket(ip)
+	}
+}
+
+// Combinations returns the parameter combinations the process sends, in
+// order
+func (p *ParamSweep) Combinations() []map[string]string {
+	if len(p.names) == 0 {
+		return nil
+	}
+	switch p.Mode {
+	case SweepZip:
+		return p.zip()
+	case SweepLatinHypercube:
+		return p.latinHypercube()
+	default:
+		return p.cartesian()
+	}
+}
+
+func (p *ParamSweep) cartesian() []map[string]string {
+	combinations := []map[string]string{{}}
+	for _, name := range p.names {
+		extended := []map[string]string{}
+		for _, combination := range combinations {
+			for _, value := range p.values[name] {
+				c := copyParams(combination)
+				c[name] = value
+				extended = append(extended, c)
+			}
+		}
+		combinations = extended
+	}
+	return combinations
+}
+
+func (p *ParamSweep) zip() []map[string]string {
+	n := -1
+	for _, name := range p.names {
+		if n < 0 || len(p.values[name]) < n {
+			n = len(p.values[name])
+		}
+	}
+	combinations := []map[string]string{}
+	for i := 0; i < n; i++ {
+		c := map[string]string{}
+		for _, name := range p.names {
+			c[name] = p.values[name][i]
+		}
+		combinations = append(combinations, c)
+	}
+	return combinations
+}
+
+// latinHypercube divides the values of each parameter into as many strata as
+// there are samples, and assigns the strata to the samples in a random
+// order, independently per parameter, so that every stratum of every
+// parameter is used exactly once
+func (p *ParamSweep) latinHypercube() []map[string]string {
+	rnd := rand.New(rand.NewSource(p.seed))
+	combinations := make([]map[string]string, p.samples)
+	for i := range combinations {
+		combinations[i] = map[string]string{}
+	}
+	for _, name := range p.names {
+		values := p.values[name]
+		if len(values) == 0 {
+			return nil
+		}
+		for i, stratum := range rnd.Perm(p.samples) {
+			combinations[i][name] = values[stratum*len(values)/p.samples]
+		}
+	}
+	return combinations
+}
+
+func copyParams(params map[string]string) map[string]string {
+	c := make(map[string]string, len(params))
+	for k, v := range params {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,62 @@
+package components
+
+import (
+	"testing"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+func TestParamSweepCartesian(t *testing.T) {
+	initTestLogs()
+	net := fb.NewNetwork("TestParamSweepCartesian")
+
+	sweep := NewParamSweep(net, "sweep")
+	sweep.AddParam("k", "1", "2")
+	sweep.AddParam("mode", "fast", "slow")
+
+	out := newCollector(net, "out")
+	out.In().From(sweep.Out())
+
+	net.Run()
+
+	assertEqualValues(t, []any{
+		map[string]string{"k": "1", "mode": "fast"},
+		map[string]string{"k": "1", "mode": "slow"},
+		map[string]string{"k": "2", "mode": "fast"},
+		map[string]string{"k": "2", "mode": "slow"},
+	}, out.data())
+	assertEqualValues(t, "slow", out.ips[3].Tag("mode"))
+}
+
+func TestParamSweepZip(t *testing.T) {
+	net := fb.NewNetwork("TestParamSweepZip")
+	sweep := NewParamSweep(net, "sweep")
+	sweep.AddParam("a", "1", "2", "3")
+	sweep.AddParam("b", "x", "y")
+	sweep.Mode = SweepZip
+
+	assertEqualValues(t, []map[string]string{
+		{"a": "1", "b": "x"},
+		{"a": "2", "b": "y"},
+	}, sweep.Combinations())
+}
+
+func TestParamSweepLatinHypercube(t *testing.T) {
+	net := fb.NewNetwork("TestParamSweepLatinHypercube")
+	sweep := NewParamSweep(net, "sweep")
+	sweep.AddParam("a", "1", "2", "3", "4")
+	sweep.AddParam("b", "w", "x", "y", "z")
+	sweep.SetLatinHypercube(4, 42)
+
+	combinations := sweep.Combinations()
+	assertEqualValues(t, 4, len(combinations))
+	// Every value of every parameter is used exactly once
+	for _, name := range []string{"a", "b"} {
+		seen := map[string]bool{}
+		for _, c := range combinations {
+			seen[c[name]] = true
+		}
+		assertEqualValues(t, 4, len(seen))
+	}
+	assertEqualValues(t, combinations, sweep.Combinations())
+}
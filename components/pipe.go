@@ -0,0 +1,172 @@
+package components
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// Framing is how packets are delimited in a byte stream, such as stdin
+type Framing string
+
+const (
+	// FramingLines makes each line a packet, with the data as a string,
+	// without the newline
+	FramingLines Framing = "lines"
+	// FramingLengthPrefixed makes each packet a 4-byte big-endian length,
+	// followed by that many bytes of data, as []byte, for binary data
+	FramingLengthPrefixed Framing = "length-prefixed"
+)
+
+// ParseFraming returns the framing named name, which is "lines" or
+// "length-prefixed"
+func ParseFraming(name string) (Framing, error) {
+	switch framing := Framing(name); framing {
+	case FramingLines, FramingLengthPrefixed:
+		return framing, nil
+	}
+	return "", fmt.Errorf("unknown framing %q, expected %s or %s", name, FramingLines, FramingLengthPrefixed)
+}
+
+// ------------------------------------------------------------------------
+// StdinSource
+// ------------------------------------------------------------------------
+
+// StdinSource sends the packets read from stdin, framed by its framing, so
+// that networks can be used in Unix pipelines, such as:
+//
+//	cat data.txt | mypipeline | sort
+//
+// Processes should then log to stderr only, which is the default of
+// fb.NewNetwork.
+type StdinSource struct {
+	fb.BaseProcess
+	framing Framing
+	// Input is read instead of stdin, if set
+	Input io.Reader
+}
+
+// NewStdinSource returns a new StdinSource process, reading packets framed
+// by framing
+func NewStdinSource(net *fb.Network, name string, framing Framing) *StdinSource {
+	p := &StdinSource{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		framing:     framing,
+	}
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// Out returns the out-port
+func (p *StdinSource) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Run runs the StdinSource process
+func (p *StdinSource) Run() {
+	defer p.CloseOutPorts()
+	input := p.Input
+	if input == nil {
+		input = os.Stdin
+	}
+	r := bufio.NewReader(input)
+	if p.framing == FramingLines {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+		for scanner.Scan() {
+			p.Out().Send(scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			p.Failf("Could not read lines from stdin: %v", err)
+		}
+		return
+	}
+	for {
+		var size uint32
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			if err == io.EOF {
+				return
+			}
+			p.Failf("Could not read packet length from stdin: %v", err)
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			p.Failf("Could not read packet of %d bytes from stdin: %v", size, err)
+		}
+		p.Out().Send(data)
+	}
+}
+
+// ------------------------------------------------------------------------
+// StdoutSink
+// ------------------------------------------------------------------------
+
+// StdoutSink writes the data of the packets it receives to stdout, framed by
+// its framing (see StdinSource). []byte and string data is written as is,
+// and other data encoded as JSON. Output is flushed whenever no more packets
+// are waiting, so that downstream commands get it as it is produced.
+type StdoutSink struct {
+	fb.BaseProcess
+	framing Framing
+	// Output is written to instead of stdout, if set
+	Output io.Writer
+}
+
+// NewStdoutSink returns a new StdoutSink process, writing packets framed by
+// framing
+func NewStdoutSink(net *fb.Network, name string, framing Framing) *StdoutSink {
+	p := &StdoutSink{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		framing:     framing,
+	}
+	p.InitInPort(p, "in")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *StdoutSink) In() *fb.InPort { return p.InPort("in") }
+
+// Run runs the StdoutSink process
+func (p *StdoutSink) Run() {
+	output := p.Output
+	if output == nil {
+		output = os.Stdout
+	}
+	w := bufio.NewWriter(output)
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		var data []byte
+		switch d := ip.Data().(type) {
+		case []byte:
+			data = d
+		case string:
+			data = []byte(d)
+		default:
+			var err error
+			if data, err = json.Marshal(d); err != nil {
+				ip.Failf("Could not encode packet data as JSON: %v", err)
+			}
+		}
+		var err error
+		if p.framing == FramingLines {
+			if _, err = w.Write(data); err == nil {
+				err = w.WriteByte('\n')
+			}
+		} else if err = binary.Write(w, binary.BigEndian, uint32(len(data))); err == nil {
+			_, err = w.Write(data)
+		}
+		if err == nil && p.In().Len() == 0 {
+			err = w.Flush()
+		}
+		if err != nil {
+			p.Failf("Could not write to stdout: %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		p.Failf("Could not write to stdout: %v", err)
+	}
+}
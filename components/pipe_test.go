@@ -0,0 +1,67 @@
+package components
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+func TestStdinSourceLines(t *testing.T) {
+	initTestLogs()
+	net := fb.NewNetwork("TestStdinSourceLines")
+	src := NewStdinSource(net, "src", FramingLines)
+	src.Input = strings.NewReader("a\nb c\n\nd")
+	out := newCollector(net, "out")
+	out.In().From(src.Out())
+	net.Run()
+
+	assertEqualValues(t, []any{"a", "b c", "", "d"}, out.data())
+}
+
+func TestStdoutSinkLines(t *testing.T) {
+	initTestLogs()
+	net := fb.NewNetwork("TestStdoutSinkLines")
+	src := newSliceSource(net, "src", fb.NewPacket("a"), fb.NewPacket([]byte("b")), fb.NewPacket(map[string]int{"c": 1}))
+	sink := NewStdoutSink(net, "sink", FramingLines)
+	buf := &bytes.Buffer{}
+	sink.Output = buf
+	sink.In().From(src.Out())
+	net.Run()
+
+	assertEqualValues(t, "a\nb\n{\"c\":1}\n", buf.String())
+}
+
+func TestPipeLengthPrefixed(t *testing.T) {
+	initTestLogs()
+	frames := &bytes.Buffer{}
+	net := fb.NewNetwork("TestPipeLengthPrefixed")
+	src := newSliceSource(net, "src", fb.NewPacket([]byte("line\nbreak")), fb.NewPacket([]byte{}), fb.NewPacket([]byte{0, 1, 2}))
+	sink := NewStdoutSink(net, "sink", FramingLengthPrefixed)
+	sink.Output = frames
+	sink.In().From(src.Out())
+	net.Run()
+
+	assertEqualValues(t, 4+10+4+0+4+3, frames.Len())
+
+	net = fb.NewNetwork("TestPipeLengthPrefixedRead")
+	stdin := NewStdinSource(net, "stdin", FramingLengthPrefixed)
+	stdin.Input = frames
+	out := newCollector(net, "out")
+	out.In().From(stdin.Out())
+	net.Run()
+
+	assertEqualValues(t, []any{[]byte("line\nbreak"), []byte{}, []byte{0, 1, 2}}, out.data())
+}
+
+func TestParseFraming(t *testing.T) {
+	if _, err := ParseFraming("xml"); err == nil {
+		t.Error("Expected an error for an unknown framing")
+	}
+	framing, err := ParseFraming("length-prefixed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqualValues(t, FramingLengthPrefixed, framing)
+}
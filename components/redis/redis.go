@@ -0,0 +1,313 @@
+// Package redis contains components backed by Redis: sources of the entries
+// of a stream (StreamSource) and of the messages published to channels
+// (Subscriber), sinks adding entries to a stream (StreamSink) and publishing
+// messages (Publisher), and State, a key-value store shared between
+// processes, such as for coordination and counters across a network.
+//
+// A minimal client, speaking the Redis protocol over a plain or TLS
+// connection, is included, so that no client library is needed.
+package redis
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// ------------------------------------------------------------------------
+// StreamSource
+// ------------------------------------------------------------------------
+
+// StreamSource sends the entries of a Redis stream, as they are added, with
+// the fields of each entry as map[string]string data, tagged with the entry
+// ID, as "id", and the stream, as "stream"
+type StreamSource struct {
+	fb.BaseProcess
+	config Config
+	stream string
+	// StartID is the ID after which entries are read. Defaults to "$", which
+	// only reads entries added after the process started. Use "0" to read the
+	// stream from the beginning.
+	StartID string
+	// MaxEntries is the number of entries after which the process finishes.
+	// Defaults to 0, which reads entries until the network is stopped.
+	MaxEntries int
+	// Block is how long each read waits for new entries. Defaults to 1s.
+	Block time.Duration
+}
+
+// NewStreamSource returns a new StreamSource process, reading the stream
+// stream from the server of config
+func NewStreamSource(net *fb.Network, name string, config Config, stream string) *StreamSource {
+	p := &StreamSource{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		config:      config,
+		stream:      stream,
+		StartID:     "$",
+		Block:       time.Second,
+	}
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// Out returns the out-port, on which the stream entries are sent
+func (p *StreamSource) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Run runs the StreamSource process
+func (p *StreamSource) Run() {
+	defer p.CloseOutPorts()
+	c, err := dial(p.config)
+	if err != nil {
+		p.Failf("Could not connect to Redis at %s: %v", p.config.Addr, err)
+	}
+	defer c.close()
+
+	lastID := p.StartID
+	sent := 0
+	for p.MaxEntries == 0 || sent < p.MaxEntries {
+		reply, err := c.do("XREAD", "COUNT", "100", "BLOCK", strconv.FormatInt(p.Block.Milliseconds(), 10), "STREAMS", p.stream, lastID)
+		if err != nil {
+			p.Failf("Could not read stream %s: %v", p.stream, err)
+		}
+		// A nil reply means that no entries arrived before the timeout
+		if reply == nil {
+			continue
+		}
+		entries, err := streamEntries(reply)
+		if err != nil {
+			p.Failf("Could not read stream %s: %v", p.stream, err)
+		}
+		for _, e := range entries {
+			ip := fb.NewPacket(e.fields)
+			ip.AddTag("id", e.id)
+			ip.AddTag("stream", p.stream)
+			p.Out().SendPacket(ip)
+			lastID = e.id
+			sent++
+			if sent == p.MaxEntries {
+				break
+			}
+		}
+	}
+}
+
+type streamEntry struct {
+	id     string
+	fields map[string]string
+}
+
+// streamEntries returns the entries of an XREAD reply for a single stream,
+// which has the form [[stream, [[id, [field, value, ...]], ...]]]
+func streamEntries(reply any) ([]streamEntry, error) {
+	streams, ok := reply.([]any)
+	if !ok || len(streams) != 1 {
+		return nil, fmt.Errorf("unexpected reply: %v", reply)
+	}
+	stream, ok := streams[0].([]any)
+	if !ok || len(stream) != 2 {
+		return nil, fmt.Errorf("unexpected reply: %v", reply)
+	}
+	items, ok := stream[1].([]any)
+	if !ok {
+		return nil, fmt.Errorf("unexpected reply: %v", reply)
+	}
+	entries := []streamEntry{}
+	for _, item := range items {
+		entry, ok := item.([]any)
+		if !ok || len(entry) != 2 {
+			return nil, fmt.Errorf("unexpected stream entry: %v", item)
+		}
+		id, ok := entry[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected stream entry: %v", item)
+		}
+		kvs, err := replyStrings(entry[1])
+		if err != nil {
+			return nil, err
+		}
+		fields := map[string]string{}
+		for i := 0; i+1 < len(kvs); i += 2 {
+			fields[kvs[i]] = kvs[i+1]
+		}
+		entries = append(entries, streamEntry{id, fields})
+	}
+	return entries, nil
+}
+
+// ------------------------------------------------------------------------
+// StreamSink
+// ------------------------------------------------------------------------
+
+// StreamSink adds an entry to a Redis stream for each packet it receives.
+// Packets with map[string]string data are added with their keys and values
+// as fields, and other packets with their data, formatted with fmt.Sprint,
+// in the field "data".
+type StreamSink struct {
+	fb.BaseProcess
+	config Config
+	stream string
+}
+
+// NewStreamSink returns a new StreamSink process, adding entries to the
+// stream stream, on the server of config
+func NewStreamSink(net *fb.Network, name string, config Config, stream string) *StreamSink {
+	p := &StreamSink{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		config:      config,
+		stream:      stream,
+	}
+	p.InitInPort(p, "in")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *StreamSink) In() *fb.InPort { return p.InPort("in") }
+
+// Run runs the StreamSink process
+func (p *StreamSink) Run() {
+	c, err := dial(p.config)
+	if err != nil {
+		p.Failf("Could not connect to Redis at %s: %v", p.config.Addr, err)
+	}
+	defer c.close()
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		args := []string{"XADD", p.stream, "*"}
+		if fields, ok := ip.Data().(map[string]string); ok {
+			keys := make([]string, 0, len(fields))
+			for k := range fields {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				args = append(args, k, fields[k])
+			}
+		} else {
+			args = append(args, "data", payload(ip))
+		}
+		if _, err := c.do(args...); err != nil {
+			ip.Failf("Could not add entry to stream %s: %v", p.stream, err)
+		}
+	}
+}
+
+// payload returns the data of the packet ip as a string
+func payload(ip *fb.Packet) string {
+	if b, ok := ip.Data().([]byte); ok {
+		return string(b)
+	}
+	return fmt.Sprint(ip.Data())
+}
+
+// ------------------------------------------------------------------------
+// Subscriber
+// ------------------------------------------------------------------------
+
+// Subscriber subscribes to Redis pub/sub channels, and sends the messages
+// published to them, as string data, tagged with the channel, as "channel"
+type Subscriber struct {
+	fb.BaseProcess
+	config   Config
+	channels []string
+	// MaxMessages is the number of messages after which the process
+	// finishes. Defaults to 0, which receives messages until the network is
+	// stopped.
+	MaxMessages int
+}
+
+// NewSubscriber returns a new Subscriber process, subscribing to channels on
+// the server of config
+func NewSubscriber(net *fb.Network, name string, config Config, channels ...string) *Subscriber {
+	p := &Subscriber{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		config:      config,
+		channels:    channels,
+	}
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// Out returns the out-port, on which the messages are sent
+func (p *Subscriber) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Run runs the Subscriber process
+func (p *Subscriber) Run() {
+	defer p.CloseOutPorts()
+	c, err := dial(p.config)
+	if err != nil {
+		p.Failf("Could not connect to Redis at %s: %v", p.config.Addr, err)
+	}
+	defer c.close()
+	if err := c.send(append([]string{"SUBSCRIBE"}, p.channels...)...); err != nil {
+		p.Failf("Could not subscribe: %v", err)
+	}
+	for received := 0; p.MaxMessages == 0 || received < p.MaxMessages; {
+		reply, err := c.receive()
+		if err != nil {
+			p.Failf("Could not receive message: %v", err)
+		}
+		// Replies are [subscribe, channel, count] confirmations and
+		// [message, channel, payload] messages
+		msg, err := replyStrings(reply)
+		if err != nil || len(msg) != 3 {
+			if items, ok := reply.([]any); ok && len(items) == 3 && items[0] == "subscribe" {
+				continue
+			}
+			p.Failf("Unexpected reply: %v", reply)
+		}
+		if msg[0] != "message" {
+			continue
+		}
+		ip := fb.NewPacket(msg[2])
+		ip.AddTag("channel", msg[1])
+		p.Out().SendPacket(ip)
+		received++
+	}
+}
+
+// ------------------------------------------------------------------------
+// Publisher
+// ------------------------------------------------------------------------
+
+// Publisher publishes the data of each packet it receives, formatted with
+// fmt.Sprint, to a Redis pub/sub channel
+type Publisher struct {
+	fb.BaseProcess
+	config  Config
+	channel string
+}
+
+// NewPublisher returns a new Publisher process, publishing to channel on
+// the server of config
+func NewPublisher(net *fb.Network, name string, config Config, channel string) *Publisher {
+	p := &Publisher{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		config:      config,
+		channel:     channel,
+	}
+	p.InitInPort(p, "in")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *Publisher) In() *fb.InPort { return p.InPort("in") }
+
+// Run runs the Publisher process
+func (p *Publisher) Run() {
+	c, err := dial(p.config)
+	if err != nil {
+		p.Failf("Could not connect to Redis at %s: %v", p.config.Addr, err)
+	}
+	defer c.close()
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		if _, err := c.do("PUBLISH", p.channel, payload(ip)); err != nil {
+			ip.Failf("Could not publish to channel %s: %v", p.channel, err)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package redis
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/flowbase/flowbase/flowbasetest"
+)
+
+// fakeServer is an in-memory server, supporting the commands used by the
+// components in this package
+type fakeServer struct {
+	mx        sync.Mutex
+	keys      map[string]string
+	streams   map[string][][]string
+	published []string
+	// messages are sent to subscribers of their channel
+	messages map[string][]string
+}
+
+// serve starts serving s, and returns its address
+func (s *fakeServer) serve(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.handle(&conn{c: c, r: bufio.NewReader(c), w: bufio.NewWriter(c)})
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func (s *fakeServer) handle(c *conn) {
+	defer c.close()
+	for {
+		reply, err := c.readReply()
+		if err != nil {
+			return
+		}
+		args, _ := replyStrings(reply)
+		s.mx.Lock()
+		resp := s.command(args)
+		s.mx.Unlock()
+		if resp == "" {
+			// Replies nil to XREAD of no entries, after a while
+			time.Sleep(10 * time.Millisecond)
+			resp = "*-1\r\n"
+		}
+		fmt.Fprint(c.c, resp)
+	}
+}
+
+func (s *fakeServer) command(args []string) string {
+	bulk := func(v string) string { return fmt.Sprintf("$%d\r\n%s\r\n", len(v), v) }
+	switch args[0] {
+	case "GET":
+		if v, ok := s.keys[args[1]]; ok {
+			return bulk(v)
+		}
+		return "$-1\r\n"
+	case "SET":
+		if _, ok := s.keys[args[1]]; ok && len(args) > 3 && args[3] == "NX" {
+			return "$-1\r\n"
+		}
+		s.keys[args[1]] = args[2]
+		return "+OK\r\n"
+	case "INCRBY":
+		v, _ := strconv.ParseInt(s.keys[args[1]], 10, 64)
+		n, _ := strconv.ParseInt(args[2], 10, 64)
+		s.keys[args[1]] = strconv.FormatInt(v+n, 10)
+		return ":" + s.keys[args[1]] + "\r\n"
+	case "DEL":
+		delete(s.keys, args[1])
+		return ":1\r\n"
+	case "XADD":
+		id := fmt.Sprintf("%d-0", len(s.streams[args[1]])+1)
+		s.streams[args[1]] = append(s.streams[args[1]], append([]string{id}, args[3:]...))
+		return bulk(id)
+	case "XREAD":
+		// XREAD COUNT n BLOCK ms STREAMS stream id
+		stream, after := args[6], args[7]
+		entries := ""
+		n := 0
+		for _, e := range s.streams[stream] {
+			if after != "$" && e[0] > after {
+				entries += fmt.Sprintf("*2\r\n%s*%d\r\n", bulk(e[0]), len(e)-1)
+				for _, f := range e[1:] {
+					entries += bulk(f)
+				}
+				n++
+			}
+		}
+		if n == 0 {
+			return ""
+		}
+		return fmt.Sprintf("*1\r\n*2\r\n%s*%d\r\n%s", bulk(stream), n, entries)
+	case "PUBLISH":
+		s.published = append(s.published, args[1]+":"+args[2])
+		return ":1\r\n"
+	case "SUBSCRIBE":
+		resp := ""
+		for i, ch := range args[1:] {
+			resp += fmt.Sprintf("*3\r\n%s%s:%d\r\n", bulk("subscribe"), bulk(ch), i+1)
+		}
+		for _, ch := range args[1:] {
+			for _, msg := range s.messages[ch] {
+				resp += fmt.Sprintf("*3\r\n%s%s%s", bulk("message"), bulk(ch), bulk(msg))
+			}
+		}
+		return resp
+	}
+	return "-ERR unknown command\r\n"
+}
+
+func newFakeServer() *fakeServer {
+	return &fakeServer{keys: map[string]string{}, streams: map[string][][]string{}, messages: map[string][]string{}}
+}
+
+func TestStream(t *testing.T) {
+	server := newFakeServer()
+	config := Config{Addr: server.serve(t)}
+
+	net := flowbasetest.NewTestNetwork(t)
+	sink := NewStreamSink(net.Network, "sink", config, "events")
+	flowbasetest.FeedPort(sink.In(), map[string]string{"kind": "click", "user": "a"}, "hello")
+	src := NewStreamSource(net.Network, "src", config, "events")
+	src.StartID = "0"
+	src.MaxEntries = 2
+	entries := flowbasetest.CollectPort[map[string]string](src.Out())
+	net.Run()
+
+	vals := entries.Values()
+	if len(vals) != 2 || vals[0]["kind"] != "click" || vals[0]["user"] != "a" || vals[1]["data"] != "hello" {
+		t.Fatalf("Got wrong entries: %v", vals)
+	}
+	if ip := entries.Packets()[1]; ip.Tag("id") != "2-0" || ip.Tag("stream") != "events" {
+		t.Errorf("Got wrong tags: %v", ip.Tags())
+	}
+}
+
+func TestPubSub(t *testing.T) {
+	server := newFakeServer()
+	server.messages["news"] = []string{"first", "second"}
+	config := Config{Addr: server.serve(t)}
+
+	net := flowbasetest.NewTestNetwork(t)
+	sub := NewSubscriber(net.Network, "sub", config, "news")
+	sub.MaxMessages = 2
+	pub := NewPublisher(net.Network, "pub", config, "echo")
+	pub.In().From(sub.Out())
+	net.Run()
+
+	if want := "[echo:first echo:second]"; fmt.Sprint(server.published) != want {
+		t.Errorf("Got wrong published messages: %v, wanted: %s", server.published, want)
+	}
+}
+
+func TestState(t *testing.T) {
+	server := newFakeServer()
+	state, err := NewState(Config{Addr: server.serve(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer state.Close()
+	state.Prefix = "job:"
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := state.Incr("count", 2); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if v, ok, err := state.Get("count"); err != nil || !ok || v != "20" {
+		t.Errorf("Got wrong count: %s (%v, %v)", v, ok, err)
+	}
+	if set, _ := state.SetIfAbsent("lock", "a"); !set {
+		t.Error("Lock not set")
+	}
+	if set, _ := state.SetIfAbsent("lock", "b"); set {
+		t.Error("Lock set twice")
+	}
+	state.Delete("lock")
+	if _, ok, _ := state.Get("lock"); ok {
+		t.Error("Lock not deleted")
+	}
+	if _, ok := server.keys["job:count"]; !ok {
+		t.Errorf("Keys not prefixed: %v", server.keys)
+	}
+}
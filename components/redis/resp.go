@@ -0,0 +1,166 @@
+package redis
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// Config configures the connection to a Redis server
+type Config struct {
+	// Addr is the host:port of the server. Defaults to "localhost:6379".
+	Addr     string
+	Username string
+	Password string
+	DB       int
+	TLS      bool
+}
+
+// redisError is an error reply from the server
+type redisError string
+
+func (e redisError) Error() string { return string(e) }
+
+// conn is a minimal Redis client connection, speaking RESP2, which is safe
+// for concurrent use, one command at a time
+type conn struct {
+	mx sync.Mutex
+	c  net.Conn
+	r  *bufio.Reader
+	w  *bufio.Writer
+}
+
+// dial connects to the server of config, authenticating and selecting the
+// database, if configured
+func dial(config Config) (*conn, error) {
+	addr := config.Addr
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+	var c net.Conn
+	var err error
+	if config.TLS {
+		c, err = tls.Dial("tcp", addr, nil)
+	} else {
+		c, err = net.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	rc := &conn{c: c, r: bufio.NewReader(c), w: bufio.NewWriter(c)}
+	if config.Password != "" {
+		args := []string{"AUTH", config.Password}
+		if config.Username != "" {
+			args = []string{"AUTH", config.Username, config.Password}
+		}
+		if _, err := rc.do(args...); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("could not authenticate: %v", err)
+		}
+	}
+	if config.DB != 0 {
+		if _, err := rc.do("SELECT", strconv.Itoa(config.DB)); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return rc, nil
+}
+
+// do sends the command args, and returns its reply, which is a string, an
+// int64, nil, or a []any of replies
+func (c *conn) do(args ...string) (any, error) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if err := c.send(args...); err != nil {
+		return nil, err
+	}
+	return c.receive()
+}
+
+func (c *conn) send(args ...string) error {
+	fmt.Fprintf(c.w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(c.w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	return c.w.Flush()
+}
+
+// receive reads a reply, returning error replies as errors
+func (c *conn) receive() (any, error) {
+	reply, err := c.readReply()
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := reply.(redisError); ok {
+		return nil, e
+	}
+	return reply, nil
+}
+
+func (c *conn) readReply() (any, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 {
+		return nil, errors.New("malformed reply")
+	}
+	kind, payload := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return payload, nil
+	case '-':
+		return redisError(payload), nil
+	case ':':
+		return strconv.ParseInt(payload, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(payload)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(payload)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = c.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("unknown reply type %q", kind)
+}
+
+func (c *conn) close() error {
+	return c.c.Close()
+}
+
+// replyStrings returns the reply, which should be an array of strings, as a
+// []string
+func replyStrings(reply any) ([]string, error) {
+	items, ok := reply.([]any)
+	if !ok {
+		return nil, fmt.Errorf("unexpected reply: %v", reply)
+	}
+	strs := make([]string, len(items))
+	for i, item := range items {
+		if strs[i], ok = item.(string); !ok {
+			return nil, fmt.Errorf("unexpected reply item: %v", item)
+		}
+	}
+	return strs, nil
+}
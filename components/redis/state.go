@@ -0,0 +1,77 @@
+package redis
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// State is a key-value store in Redis, which can be shared between the
+// processes of a network, or between networks, such as for counters, or for
+// coordinating work. It is safe for concurrent use.
+type State struct {
+	c *conn
+	// Prefix is prepended to all keys, such as to keep the state of
+	// different networks apart
+	Prefix string
+}
+
+// NewState returns a new State, connected to the server of config
+func NewState(config Config) (*State, error) {
+	c, err := dial(config)
+	if err != nil {
+		return nil, err
+	}
+	return &State{c: c}, nil
+}
+
+// Get returns the value of key, and whether it is set
+func (s *State) Get(key string) (string, bool, error) {
+	reply, err := s.c.do("GET", s.Prefix+key)
+	if err != nil || reply == nil {
+		return "", false, err
+	}
+	value, ok := reply.(string)
+	if !ok {
+		return "", false, fmt.Errorf("unexpected reply: %v", reply)
+	}
+	return value, true, nil
+}
+
+// Set sets key to value
+func (s *State) Set(key string, value string) error {
+	_, err := s.c.do("SET", s.Prefix+key, value)
+	return err
+}
+
+// SetIfAbsent sets key to value, unless it is already set, and returns
+// whether it was set, such as for making sure only one process handles an
+// item
+func (s *State) SetIfAbsent(key string, value string) (bool, error) {
+	reply, err := s.c.do("SET", s.Prefix+key, value, "NX")
+	return reply != nil, err
+}
+
+// Incr increments the integer value of key by n, treating unset keys as 0,
+// and returns the new value
+func (s *State) Incr(key string, n int64) (int64, error) {
+	reply, err := s.c.do("INCRBY", s.Prefix+key, strconv.FormatInt(n, 10))
+	if err != nil {
+		return 0, err
+	}
+	value, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("unexpected reply: %v", reply)
+	}
+	return value, nil
+}
+
+// Delete removes key
+func (s *State) Delete(key string) error {
+	_, err := s.c.do("DEL", s.Prefix+key)
+	return err
+}
+
+// Close closes the connection to the server
+func (s *State) Close() error {
+	return s.c.close()
+}
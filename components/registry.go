@@ -0,0 +1,515 @@
+package components
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// The components which can be configured from metadata alone are registered,
+// with their descriptors, so that they can be used in graph files (see
+// fb.NewNetworkFromGraph)
+func init() {
+	fb.RegisterComponent("GlobSource", func(net *fb.Network, name string, metadata map[string]string) (fb.Node, error) {
+		p := NewGlobSource(net, name, strings.Fields(metadata["patterns"])...)
+		if ptn, ok := metadata["tagpattern"]; ok {
+			p.SetTagPattern(ptn)
+		}
+		return p, nil
+	})
+	fb.RegisterComponent("Delay", func(net *fb.Network, name string, metadata map[string]string) (fb.Node, error) {
+		d, err := durationMetadata(metadata, "duration")
+		if err != nil {
+			return nil, err
+		}
+		return NewDelay(net, name, d), nil
+	})
+	fb.RegisterComponent("Debounce", func(net *fb.Network, name string, metadata map[string]string) (fb.Node, error) {
+		quiet, err := durationMetadata(metadata, "quiet")
+		if err != nil {
+			return nil, err
+		}
+		return NewDebounce(net, name, quiet), nil
+	})
+	fb.RegisterComponent("Sample", func(net *fb.Network, name string, metadata map[string]string) (fb.Node, error) {
+		interval, err := durationMetadata(metadata, "interval")
+		if err != nil {
+			return nil, err
+		}
+		return NewSample(net, name, interval), nil
+	})
+	fb.RegisterComponent("Ticker", func(net *fb.Network, name string, metadata map[string]string) (fb.Node, error) {
+		interval, err := durationMetadata(metadata, "interval")
+		if err != nil {
+			return nil, err
+		}
+		if interval <= 0 {
+			return nil, fmt.Errorf("interval metadata must be positive, got %s", interval)
+		}
+		return NewTicker(net, name, interval), nil
+	})
+	fb.RegisterComponent("CronSource", func(net *fb.Network, name string, metadata map[string]string) (fb.Node, error) {
+		if _, err := ParseCron(metadata["expr"]); err != nil {
+			return nil, err
+		}
+		return NewCronSource(net, name, metadata["expr"]), nil
+	})
+	fb.RegisterComponent("HoldUntil", func(net *fb.Network, name string, metadata map[string]string) (fb.Node, error) {
+		if tag, ok := metadata["tag"]; ok {
+			var embargo time.Duration
+			if _, ok := metadata["embargo"]; ok {
+				var err error
+				if embargo, err = durationMetadata(metadata, "embargo"); err != nil {
+					return nil, err
+				}
+			}
+			return NewHoldUntilTag(net, name, tag, embargo), nil
+		}
+		until, err := time.Parse(time.RFC3339, metadata["until"])
+		if err != nil {
+			return nil, fmt.Errorf("invalid until metadata: %v", err)
+		}
+		return NewHoldUntil(net, name, until), nil
+	})
+	fb.RegisterComponent("Watchdog", func(net *fb.Network, name string, metadata map[string]string) (fb.Node, error) {
+		timeout, err := durationMetadata(metadata, "timeout")
+		if err != nil {
+			return nil, err
+		}
+		return NewWatchdog(net, name, timeout), nil
+	})
+	fb.RegisterComponent("CircuitBreaker", func(net *fb.Network, name string, metadata map[string]string) (fb.Node, error) {
+		threshold, err := strconv.Atoi(metadata["threshold"])
+		if err != nil || threshold <= 0 {
+			return nil, fmt.Errorf("threshold metadata must be a positive integer, got %q", metadata["threshold"])
+		}
+		coolDown, err := durationMetadata(metadata, "cooldown")
+		if err != nil {
+			return nil, err
+		}
+		return NewCircuitBreaker(net, name, threshold, coolDown), nil
+	})
+	fb.RegisterComponent("LoadShedder", func(net *fb.Network, name string, metadata map[string]string) (fb.Node, error) {
+		threshold, err := strconv.Atoi(metadata["threshold"])
+		if err != nil || threshold <= 0 {
+			return nil, fmt.Errorf("threshold metadata must be a positive integer, got %q", metadata["threshold"])
+		}
+		p := NewLoadShedder(net, name, threshold)
+		if sample, ok := metadata["sample"]; ok {
+			if p.SampleEvery, err = strconv.Atoi(sample); err != nil {
+				return nil, fmt.Errorf("invalid sample metadata: %v", err)
+			}
+		}
+		return p, nil
+	})
+	fb.RegisterComponent("Sequence", func(net *fb.Network, name string, metadata map[string]string) (fb.Node, error) {
+		p := NewSequence(net, name, metadata["tag"])
+		if lanes, ok := metadata["lanes"]; ok {
+			var err error
+			if p.Lanes, err = strconv.Atoi(lanes); err != nil {
+				return nil, fmt.Errorf("invalid lanes metadata: %v", err)
+			}
+		}
+		return p, nil
+	})
+	fb.RegisterComponent("Reorder", func(net *fb.Network, name string, metadata map[string]string) (fb.Node, error) {
+		p := NewReorder(net, name, metadata["tag"])
+		if maxPending, ok := metadata["maxpending"]; ok {
+			var err error
+			if p.MaxPending, err = strconv.Atoi(maxPending); err != nil {
+				return nil, fmt.Errorf("invalid maxpending metadata: %v", err)
+			}
+		}
+		return p, nil
+	})
+	fb.RegisterComponent("IdempotencyKey", func(net *fb.Network, name string, metadata map[string]string) (fb.Node, error) {
+		return NewIdempotencyKey(net, name, strings.Fields(metadata["tags"])...), nil
+	})
+	fb.RegisterComponent("JSONTransform", func(net *fb.Network, name string, metadata map[string]string) (fb.Node, error) {
+		return NewJSONTransform(net, name), nil
+	})
+	fb.RegisterComponent("StdinSource", func(net *fb.Network, name string, metadata map[string]string) (fb.Node, error) {
+		framing, err := framingMetadata(metadata)
+		if err != nil {
+			return nil, err
+		}
+		return NewStdinSource(net, name, framing), nil
+	})
+	fb.RegisterComponent("StdoutSink", func(net *fb.Network, name string, metadata map[string]string) (fb.Node, error) {
+		framing, err := framingMetadata(metadata)
+		if err != nil {
+			return nil, err
+		}
+		return NewStdoutSink(net, name, framing), nil
+	})
+	fb.RegisterComponent("Filter", func(net *fb.Network, name string, metadata map[string]string) (fb.Node, error) {
+		return NewFilter(net, name, metadata["expr"]), nil
+	})
+	fb.RegisterComponent("Map", func(net *fb.Network, name string, metadata map[string]string) (fb.Node, error) {
+		p := NewMap(net, name, metadata["expr"])
+		for key, expr := range metadata {
+			if strings.HasPrefix(key, "tag.") {
+				p.SetTag(strings.TrimPrefix(key, "tag."), expr)
+			}
+		}
+		return p, nil
+	})
+	fb.RegisterComponent("Router", func(net *fb.Network, name string, metadata map[string]string) (fb.Node, error) {
+		ports := strings.Fields(metadata["ports"])
+		if len(ports) == 0 {
+			return nil, fmt.Errorf("no out-ports in ports metadata")
+		}
+		return NewRouter(net, name, metadata["expr"], ports...), nil
+	})
+
+	packetsIn := []fb.PortSpec{{Name: "in", Type: "packet"}}
+	packetsOut := []fb.PortSpec{{Name: "out", Type: "packet"}}
+	fb.RegisterComponentInfo(fb.ComponentInfo{
+		Name:        "GlobSource",
+		Version:     fb.Version,
+		Description: "Sends a file for each path matching the glob patterns in the patterns metadata (space-separated), tagged by the optional tagpattern regexp",
+		OutPorts:    []fb.PortSpec{{Name: "out", Type: "file"}},
+		Params: []fb.ParamSpec{
+			{Name: "patterns", Type: "string", Description: "The glob patterns, space-separated", Required: true},
+			{Name: "tagpattern", Type: "regexp", Description: "A regexp with named groups, whose matches in the paths are set as tags"},
+		},
+	})
+	fb.RegisterComponentInfo(fb.ComponentInfo{
+		Name:        "Delay",
+		Version:     fb.Version,
+		Description: "Delays each packet by the duration metadata",
+		InPorts:     packetsIn,
+		OutPorts:    packetsOut,
+		Params: []fb.ParamSpec{
+			{Name: "duration", Type: "duration", Description: "How long each packet is delayed", Required: true},
+		},
+	})
+	fb.RegisterComponentInfo(fb.ComponentInfo{
+		Name:        "Debounce",
+		Version:     fb.Version,
+		Description: "Sends the last packet of each burst, once no packet has arrived for the quiet metadata duration",
+		InPorts:     packetsIn,
+		OutPorts:    packetsOut,
+		Params: []fb.ParamSpec{
+			{Name: "quiet", Type: "duration", Description: "How long no packet must arrive for a burst to end", Required: true},
+		},
+	})
+	fb.RegisterComponentInfo(fb.ComponentInfo{
+		Name:        "Sample",
+		Version:     fb.Version,
+		Description: "Sends the latest packet once every interval metadata duration",
+		InPorts:     packetsIn,
+		OutPorts:    packetsOut,
+		Params: []fb.ParamSpec{
+			{Name: "interval", Type: "duration", Description: "How often the latest packet is sent", Required: true},
+		},
+	})
+	fb.RegisterComponentInfo(fb.ComponentInfo{
+		Name:        "Ticker",
+		Version:     fb.Version,
+		Description: "Sends the time as a trigger packet once every interval metadata duration",
+		OutPorts:    []fb.PortSpec{{Name: "out", Type: "time"}},
+		Params: []fb.ParamSpec{
+			{Name: "interval", Type: "duration", Description: "The time between ticks", Required: true},
+		},
+	})
+	fb.RegisterComponentInfo(fb.ComponentInfo{
+		Name:        "CronSource",
+		Version:     fb.Version,
+		Description: "Sends the time as a trigger packet at the times matching the cron expression in the expr metadata",
+		OutPorts:    []fb.PortSpec{{Name: "out", Type: "time"}},
+		Params: []fb.ParamSpec{
+			{Name: "expr", Type: "cron", Description: "The cron expression, such as \"0 3 * * *\"", Required: true},
+		},
+	})
+	fb.RegisterComponentInfo(fb.ComponentInfo{
+		Name:        "HoldUntil",
+		Version:     fb.Version,
+		Description: "Holds each packet until the RFC 3339 time in the until metadata, or the time in the tag metadata tag plus the optional embargo metadata duration",
+		InPorts:     packetsIn,
+		OutPorts:    packetsOut,
+		Params: []fb.ParamSpec{
+			{Name: "until", Type: "time", Description: "The RFC 3339 time until which packets are held, if tag is not set"},
+			{Name: "tag", Type: "string", Description: "The tag holding the RFC 3339 time until which each packet is held"},
+			{Name: "embargo", Type: "duration", Description: "The time added to the time in the tag"},
+		},
+	})
+	fb.RegisterComponentInfo(fb.ComponentInfo{
+		Name:        "Watchdog",
+		Version:     fb.Version,
+		Description: "Passes on packets, sending an alert with the time of the last packet if none has arrived for the timeout metadata duration",
+		InPorts:     packetsIn,
+		OutPorts:    []fb.PortSpec{{Name: "out", Type: "packet"}, {Name: "alerts", Type: "time"}},
+		Params: []fb.ParamSpec{
+			{Name: "timeout", Type: "duration", Description: "How long no packet must arrive for an alert to be sent", Required: true},
+		},
+	})
+	fb.RegisterComponentInfo(fb.ComponentInfo{
+		Name:        "CircuitBreaker",
+		Version:     fb.Version,
+		Description: "Wraps a process via its call, results and failures ports, sending packets on fallback instead for the cooldown metadata duration after threshold metadata consecutive failures",
+		InPorts: []fb.PortSpec{
+			{Name: "in", Type: "packet"},
+			{Name: "results", Type: "packet", Description: "The out-port of the wrapped process"},
+			{Name: "failures", Type: "packet", Description: "The errors port of the wrapped process"},
+		},
+		OutPorts: []fb.PortSpec{
+			{Name: "call", Type: "packet", Description: "The in-port of the wrapped process"},
+			{Name: "out", Type: "packet"},
+			{Name: "errors", Type: "packet"},
+			{Name: "fallback", Type: "packet"},
+		},
+		Params: []fb.ParamSpec{
+			{Name: "threshold", Type: "int", Description: "The number of consecutive failures which opens the circuit", Required: true},
+			{Name: "cooldown", Type: "duration", Description: "How long the circuit stays open", Required: true},
+		},
+	})
+	fb.RegisterComponentInfo(fb.ComponentInfo{
+		Name:        "LoadShedder",
+		Version:     fb.Version,
+		Description: "Drops packets while threshold metadata packets are queued downstream, except every sample metadata-th one",
+		InPorts:     packetsIn,
+		OutPorts:    packetsOut,
+		Params: []fb.ParamSpec{
+			{Name: "threshold", Type: "int", Description: "The number of queued packets above which packets are dropped", Required: true},
+			{Name: "sample", Type: "int", Description: "Pass on every sample-th packet while overloaded"},
+		},
+	})
+	fb.RegisterComponentInfo(fb.ComponentInfo{
+		Name:        "Sequence",
+		Version:     fb.Version,
+		Description: "Tags packets with their sequence number in the tag metadata tag, and, if the lanes metadata is set, with a round-robin lane as lane",
+		InPorts:     packetsIn,
+		OutPorts:    packetsOut,
+		Params: []fb.ParamSpec{
+			{Name: "tag", Type: "string", Description: "The tag to set the sequence number in", Required: true},
+			{Name: "lanes", Type: "int", Description: "The number of lanes to assign round-robin, as the lane tag"},
+		},
+	})
+	fb.RegisterComponentInfo(fb.ComponentInfo{
+		Name:        "Reorder",
+		Version:     fb.Version,
+		Description: "Sends on packets in the order of the sequence numbers in the tag metadata tag, buffering at most maxpending metadata packets",
+		InPorts:     packetsIn,
+		OutPorts:    packetsOut,
+		Params: []fb.ParamSpec{
+			{Name: "tag", Type: "string", Description: "The tag holding the sequence number", Required: true},
+			{Name: "maxpending", Type: "int", Description: "The number of buffered packets above which missing packets are skipped"},
+		},
+	})
+	fb.RegisterComponentInfo(fb.ComponentInfo{
+		Name:        "IdempotencyKey",
+		Version:     fb.Version,
+		Description: "Sets the idempotency key of packets from the tags in the tags metadata (space-separated), or from a hash of their data",
+		InPorts:     packetsIn,
+		OutPorts:    packetsOut,
+		Params: []fb.ParamSpec{
+			{Name: "tags", Type: "string", Description: "The tags the key is made of, space-separated. Defaults to a hash of the data"},
+		},
+	})
+	fb.RegisterComponentInfo(fb.ComponentInfo{
+		Name:        "JSONTransform",
+		Version:     fb.Version,
+		Description: "Filters and reshapes JSON with a jq-like expression, sending each output as a packet",
+		InPorts: []fb.PortSpec{
+			{Name: "expr", Type: "string", Description: "The expression, such as from an IIP"},
+			{Name: "in", Type: "json"},
+		},
+		OutPorts: []fb.PortSpec{{Name: "out", Type: "json"}},
+	})
+	exprParam := fb.ParamSpec{Name: "expr", Type: "string", Description: "The expression, in the jq-like language of JSONTransform, with the tags of packets as $tags", Required: true}
+	fb.RegisterComponentInfo(fb.ComponentInfo{
+		Name:        "Filter",
+		Version:     fb.Version,
+		Description: "Sends on the packets for which the expr metadata expression is true",
+		InPorts:     packetsIn,
+		OutPorts:    packetsOut,
+		Params:      []fb.ParamSpec{exprParam},
+	})
+	fb.RegisterComponentInfo(fb.ComponentInfo{
+		Name:        "Map",
+		Version:     fb.Version,
+		Description: "Replaces the data of packets with the value of the expr metadata expression, and sets tags to the values of tag.<name> expressions",
+		InPorts:     packetsIn,
+		OutPorts:    packetsOut,
+		Params: []fb.ParamSpec{
+			{Name: "expr", Type: "string", Description: "The expression computing the new data, with the tags of packets as $tags. Defaults to keeping the data"},
+			{Name: "tag.<name>", Type: "string", Description: "The expression computing the value of the tag name"},
+		},
+	})
+	fb.RegisterComponentInfo(fb.ComponentInfo{
+		Name:        "Router",
+		Version:     fb.Version,
+		Description: "Sends packets to the out-ports in the ports metadata named by the expr metadata expression",
+		Params: []fb.ParamSpec{
+			exprParam,
+			{Name: "ports", Type: "string", Description: "The names of the out-ports, space-separated", Required: true},
+		},
+	})
+	framingParams := []fb.ParamSpec{
+		{Name: "framing", Type: "string", Description: "How packets are delimited: lines (the default), or length-prefixed, with a 4-byte big-endian length"},
+	}
+	fb.RegisterComponentInfo(fb.ComponentInfo{
+		Name:        "StdinSource",
+		Version:     fb.Version,
+		Description: "Sends the packets read from stdin, as lines or length-prefixed frames, for use in Unix pipelines",
+		OutPorts:    []fb.PortSpec{{Name: "out", Type: "string"}},
+		Params:      framingParams,
+	})
+	fb.RegisterComponentInfo(fb.ComponentInfo{
+		Name:        "StdoutSink",
+		Version:     fb.Version,
+		Description: "Writes the data of the packets it receives to stdout, as lines or length-prefixed frames, for use in Unix pipelines",
+		InPorts:     packetsIn,
+		Params:      framingParams,
+	})
+}
+
+// durationMetadata parses the metadata field key as a duration, such as "2s"
+func durationMetadata(metadata map[string]string, key string) (time.Duration, error) {
+	value, ok := metadata[key]
+	if !ok {
+		return 0, fmt.Errorf("missing %s metadata", key)
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s metadata: %v", key, err)
+	}
+	return d, nil
+}
+
+// framingMetadata parses the framing metadata field, which defaults to lines
+func framingMetadata(metadata map[string]string) (Framing, error) {
+	name, ok := metadata["framing"]
+	if !ok {
+		return FramingLines, nil
+	}
+	return ParseFraming(name)
+}
+
+// ComponentMetadata returns the glob patterns, and tag pattern, of the
+// process
+func (p *GlobSource) ComponentMetadata() map[string]string {
+	metadata := map[string]string{"patterns": strings.Join(p.globPatterns, " ")}
+	if p.tagPattern != nil {
+		metadata["tagpattern"] = p.tagPattern.String()
+	}
+	return metadata
+}
+
+// ComponentMetadata returns the delay of the process
+func (p *Delay) ComponentMetadata() map[string]string {
+	return map[string]string{"duration": p.d.String()}
+}
+
+// ComponentMetadata returns the quiet period of the process
+func (p *Debounce) ComponentMetadata() map[string]string {
+	return map[string]string{"quiet": p.quiet.String()}
+}
+
+// ComponentMetadata returns the sampling interval of the process
+func (p *Sample) ComponentMetadata() map[string]string {
+	return map[string]string{"interval": p.interval.String()}
+}
+
+// ComponentMetadata returns the interval of the process
+func (p *Ticker) ComponentMetadata() map[string]string {
+	return map[string]string{"interval": p.interval.String()}
+}
+
+// ComponentMetadata returns the cron expression of the process
+func (p *CronSource) ComponentMetadata() map[string]string {
+	return map[string]string{"expr": p.expr}
+}
+
+// ComponentMetadata returns the failure threshold and cool-down of the process
+func (p *CircuitBreaker) ComponentMetadata() map[string]string {
+	return map[string]string{"threshold": strconv.Itoa(p.threshold), "cooldown": p.coolDown.String()}
+}
+
+// ComponentMetadata returns the threshold, and sampling, of the process
+func (p *LoadShedder) ComponentMetadata() map[string]string {
+	metadata := map[string]string{"threshold": strconv.Itoa(p.threshold)}
+	if p.SampleEvery > 0 {
+		metadata["sample"] = strconv.Itoa(p.SampleEvery)
+	}
+	return metadata
+}
+
+// ComponentMetadata returns the tag, and number of lanes, of the process
+func (p *Sequence) ComponentMetadata() map[string]string {
+	metadata := map[string]string{"tag": p.tag}
+	if p.Lanes > 0 {
+		metadata["lanes"] = strconv.Itoa(p.Lanes)
+	}
+	return metadata
+}
+
+// ComponentMetadata returns the tag, and buffering limit, of the process
+func (p *Reorder) ComponentMetadata() map[string]string {
+	metadata := map[string]string{"tag": p.tag}
+	if p.MaxPending > 0 {
+		metadata["maxpending"] = strconv.Itoa(p.MaxPending)
+	}
+	return metadata
+}
+
+// ComponentMetadata returns the tags the keys are made from
+func (p *IdempotencyKey) ComponentMetadata() map[string]string {
+	return map[string]string{"tags": strings.Join(p.tags, " ")}
+}
+
+// ComponentMetadata returns the timeout of the process
+func (p *Watchdog) ComponentMetadata() map[string]string {
+	return map[string]string{"timeout": p.timeout.String()}
+}
+
+// ComponentMetadata returns the release time, or the tag and embargo, of the
+// process
+func (p *HoldUntil) ComponentMetadata() map[string]string {
+	if p.tag != "" {
+		return map[string]string{"tag": p.tag, "embargo": p.embargo.String()}
+	}
+	return map[string]string{"until": p.until.Format(time.RFC3339)}
+}
+
+// ComponentMetadata returns the framing of the process
+func (p *StdinSource) ComponentMetadata() map[string]string {
+	return map[string]string{"framing": string(p.framing)}
+}
+
+// ComponentMetadata returns the framing of the process
+func (p *StdoutSink) ComponentMetadata() map[string]string {
+	return map[string]string{"framing": string(p.framing)}
+}
+
+// ComponentMetadata returns the expression of the process
+func (p *Filter) ComponentMetadata() map[string]string {
+	return map[string]string{"expr": p.expr.src}
+}
+
+// ComponentMetadata returns the data and tag expressions of the process
+func (p *Map) ComponentMetadata() map[string]string {
+	metadata := map[string]string{}
+	if p.expr != nil {
+		metadata["expr"] = p.expr.src
+	}
+	for tag, expr := range p.tags {
+		metadata["tag."+tag] = expr.src
+	}
+	return metadata
+}
+
+// ComponentMetadata returns the expression and out-ports of the process
+func (p *Router) ComponentMetadata() map[string]string {
+	ports := []string{}
+	for port := range p.OutPorts() {
+		ports = append(ports, port)
+	}
+	sort.Strings(ports)
+	return map[string]string{"expr": p.expr.src, "ports": strings.Join(ports, " ")}
+}
@@ -0,0 +1,56 @@
+package components
+
+import (
+	"bufio"
+	"io"
+	"os"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// ReplaySource sends the packets of a stream recorded with Network.Record on
+// its out-port, with their original IDs, tags and audit info, so that
+// recorded production traffic can be fed back into a (sub)network
+type ReplaySource struct {
+	fb.BaseProcess
+	path  string
+	codec fb.Codec
+}
+
+// NewReplaySource returns a new ReplaySource, replaying the recording at
+// path, which was encoded with codec
+func NewReplaySource(net *fb.Network, name string, path string, codec fb.Codec) *ReplaySource {
+	p := &ReplaySource{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		path:        path,
+		codec:       codec,
+	}
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// Out returns the out-port, on which the recorded packets are sent
+func (p *ReplaySource) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Run runs the ReplaySource process
+func (p *ReplaySource) Run() {
+	defer p.CloseOutPorts()
+
+	file, err := os.Open(p.path)
+	if err != nil {
+		p.Failf("Could not open recording: %v", err)
+	}
+	defer file.Close()
+	dec := p.codec.NewDecoder(bufio.NewReader(file))
+	for {
+		ip, err := dec.Decode()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			p.Failf("Could not read recording %s: %v", p.path, err)
+		}
+		p.Out().SendPacket(ip)
+	}
+}
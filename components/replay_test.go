@@ -0,0 +1,31 @@
+package components
+
+import (
+	"path/filepath"
+	"testing"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	initTestLogs()
+	recPath := filepath.Join(t.TempDir(), "rec.json")
+
+	net := fb.NewNetwork("TestRecord")
+	src := newSliceSource(net, "src", fb.NewPacket("a"), fb.NewPacket("b"), fb.NewPacket("c"))
+	out := newCollector(net, "out")
+	out.In().From(src.Out())
+	net.Record(src.Out(), recPath, fb.GetCodec("json"))
+	net.Run()
+
+	replayNet := fb.NewNetwork("TestReplay")
+	replay := NewReplaySource(replayNet, "replay", recPath, fb.GetCodec("json"))
+	replayed := newCollector(replayNet, "out")
+	replayed.In().From(replay.Out())
+	replayNet.Run()
+
+	assertEqualValues(t, []any{"a", "b", "c"}, replayed.data())
+	for i := range out.ips {
+		assertEqualValues(t, out.ips[i].ID(), replayed.ips[i].ID())
+	}
+}
@@ -0,0 +1,295 @@
+// Package rest contains Operation, a component calling an operation of a
+// REST API for each packet it receives, and Client, which holds the base URL
+// and authentication of an API. Operations are usually created by the code
+// generated by flowbase gen openapi, from an OpenAPI spec.
+package rest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	fb "github.com/flowbase/flowbase"
+	"github.com/flowbase/flowbase/components/crypto"
+	"github.com/flowbase/flowbase/config"
+)
+
+// Auth types, corresponding to the OpenAPI security schemes
+const (
+	// AuthBearer sends the secret as "Authorization: Bearer <secret>"
+	AuthBearer = "bearer"
+	// AuthBasic sends the secret, on the form user:password, as
+	// "Authorization: Basic <base64 of secret>"
+	AuthBasic = "basic"
+	// AuthAPIKey sends the secret as is, in the header Header
+	AuthAPIKey = "apikey"
+)
+
+// Auth is an authentication header, whose value is looked up from the
+// secrets of the client, by the name Secret, for each request
+type Auth struct {
+	Type   string
+	Header string
+	Secret string
+}
+
+// Client holds what is common to the operations of an API
+type Client struct {
+	BaseURL string
+	// Auth are the authentication headers added to all requests
+	Auth []Auth
+	// Secrets provides the secrets of Auth
+	Secrets crypto.SecretProvider
+	// Headers are added to all requests
+	Headers    map[string]string
+	HTTPClient *http.Client
+	// Quota is the name of the quota of the network (see
+	// fb.Network.SetQuota) that the requests of all operations of the
+	// client count against, if any
+	Quota string
+}
+
+// Configure overrides the settings of the client with the values in cfg
+// under prefix: the base URL from "<prefix>.baseurl", and headers from keys
+// on the form "<prefix>.header.<name>"
+func (c *Client) Configure(cfg *config.Config, prefix string) {
+	prefix = strings.ToLower(prefix) + "."
+	c.BaseURL = cfg.String(prefix+"baseurl", c.BaseURL)
+	for _, key := range cfg.Keys() {
+		if name := strings.TrimPrefix(key, prefix+"header."); name != key {
+			if c.Headers == nil {
+				c.Headers = map[string]string{}
+			}
+			c.Headers[name], _ = cfg.Get(key)
+		}
+	}
+}
+
+// authorize adds the headers of the client, and the authentication headers
+// auths, to req
+func (c *Client) authorize(req *http.Request, auths []Auth) error {
+	for name, value := range c.Headers {
+		req.Header.Set(name, value)
+	}
+	for _, auth := range auths {
+		if c.Secrets == nil {
+			return fmt.Errorf("no secrets provider, for secret %s", auth.Secret)
+		}
+		secret, err := c.Secrets.Secret(auth.Secret)
+		if err != nil {
+			return err
+		}
+		switch auth.Type {
+		case AuthBearer:
+			req.Header.Set("Authorization", "Bearer "+string(secret))
+		case AuthBasic:
+			req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString(secret))
+		case AuthAPIKey:
+			req.Header.Set(auth.Header, string(secret))
+		default:
+			return fmt.Errorf("unknown auth type: %s", auth.Type)
+		}
+	}
+	return nil
+}
+
+// Param is a parameter of an operation, which is in the path, query or
+// header of the request
+type Param struct {
+	Name     string
+	In       string
+	Required bool
+}
+
+// OperationSpec describes an operation of an API
+type OperationSpec struct {
+	Method string
+	// Path is the path of the operation, relative to the base URL of the
+	// client, with path parameters on the form {name}
+	Path   string
+	Params []Param
+	// HasBody is whether requests have a body
+	HasBody bool
+	// Auth overrides the authentication headers of the client, if not nil
+	Auth []Auth
+}
+
+// Operation calls an operation of an API for each packet it receives. The
+// parameters of the request are taken from the tags of the packet, by name,
+// and the body, for operations with one, from the data of the packet: []byte
+// and string data is sent as is, and other data encoded as JSON.
+//
+// The bodies of successful (2xx) responses are sent as []byte on the
+// out-port "out", and of other responses on the out-port "errors", tagged
+// with the tags of the received packet, and the status code, as "status".
+//
+// The idempotency key of a packet, if it has one (see
+// fb.Packet.IdempotencyKey), is sent in the Idempotency-Key header. Each
+// request is reported as an API call (see fb.Network.ReportUsage), and
+// counts against the quota of the client, if it has one (see Client.Quota).
+type Operation struct {
+	fb.BaseProcess
+	client *Client
+	spec   OperationSpec
+	// Processed makes packets whose idempotency keys are marked as processed
+	// in it be skipped, and the keys of packets with successful responses be
+	// marked, so that requests are not repeated when a network is resumed
+	Processed fb.ProcessedKeys
+}
+
+// errUnsuccessful tells that a request got an unsuccessful response
+var errUnsuccessful = errors.New("unsuccessful response")
+
+// NewOperation returns a new Operation process, calling the operation spec
+// with client
+func NewOperation(net *fb.Network, name string, client *Client, spec OperationSpec) *Operation {
+	p := &Operation{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		client:      client,
+		spec:        spec,
+	}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	p.InitOutPort(p, "errors")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *Operation) In() *fb.InPort { return p.InPort("in") }
+
+// Out returns the out-port, on which the bodies of successful responses are
+// sent
+func (p *Operation) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Errors returns the out-port, on which the bodies of unsuccessful responses
+// are sent
+func (p *Operation) Errors() *fb.OutPort { return p.OutPort("errors") }
+
+// Run runs the Operation process
+func (p *Operation) Run() {
+	defer p.CloseOutPorts()
+	httpClient := p.client.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		if p.Processed == nil {
+			p.call(httpClient, ip)
+			continue
+		}
+		skipped, err := fb.ProcessOnce(p.Processed, ip, func() error {
+			if !p.call(httpClient, ip) {
+				return errUnsuccessful
+			}
+			return nil
+		})
+		if err != nil && err != errUnsuccessful {
+			ip.Failf("Could not call %s %s: %v", p.spec.Method, p.spec.Path, err)
+		}
+		if skipped {
+			p.Auditf("Skipping already processed packet with idempotency key %s", ip.IdempotencyKey())
+		}
+	}
+}
+
+// call makes the request for the packet ip, and sends on the response body,
+// telling whether the response was successful
+func (p *Operation) call(httpClient *http.Client, ip *fb.Packet) bool {
+	req, err := p.request(ip)
+	if err != nil {
+		ip.Failf("Could not create request for %s %s: %v", p.spec.Method, p.spec.Path, err)
+	}
+	release := p.Network().Quota(p.client.Quota).Acquire()
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		ip.Failf("Request %s %s failed: %v", req.Method, req.URL.Path, err)
+	}
+	p.ReportUsage(fb.Usage{APICalls: 1})
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	release()
+	if err != nil {
+		ip.Failf("Could not read response of %s %s: %v", req.Method, req.URL.Path, err)
+	}
+	out := fb.NewPacket(body)
+	out.AddTags(ip.Tags())
+	out.AddTag("status", strconv.Itoa(resp.StatusCode))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		p.Out().SendPacket(out)
+		return true
+	}
+	p.Errors().SendPacket(out)
+	return false
+}
+
+// request returns the request for the packet ip
+func (p *Operation) request(ip *fb.Packet) (*http.Request, error) {
+	tags := ip.Tags()
+	path := p.spec.Path
+	query := url.Values{}
+	header := http.Header{}
+	for _, param := range p.spec.Params {
+		value, ok := tags[param.Name]
+		if !ok {
+			if param.Required {
+				return nil, fmt.Errorf("missing required parameter %s (from tag %s)", param.Name, param.Name)
+			}
+			continue
+		}
+		switch param.In {
+		case "path":
+			path = strings.ReplaceAll(path, "{"+param.Name+"}", url.PathEscape(value))
+		case "query":
+			query.Set(param.Name, value)
+		case "header":
+			header.Set(param.Name, value)
+		}
+	}
+	u := strings.TrimSuffix(p.client.BaseURL, "/") + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if p.spec.HasBody && ip.Data() != nil {
+		switch data := ip.Data().(type) {
+		case []byte:
+			body = bytes.NewReader(data)
+		case string:
+			body = strings.NewReader(data)
+		default:
+			encoded, err := json.Marshal(data)
+			if err != nil {
+				return nil, err
+			}
+			body = bytes.NewReader(encoded)
+			header.Set("Content-Type", "application/json")
+		}
+	}
+	req, err := http.NewRequest(p.spec.Method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if key := ip.IdempotencyKey(); key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	auths := p.client.Auth
+	if p.spec.Auth != nil {
+		auths = p.spec.Auth
+	}
+	if err := p.client.authorize(req, auths); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
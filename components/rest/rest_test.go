@@ -0,0 +1,161 @@
+package rest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	fb "github.com/flowbase/flowbase"
+	"github.com/flowbase/flowbase/components/crypto"
+	"github.com/flowbase/flowbase/config"
+	"github.com/flowbase/flowbase/flowbasetest"
+)
+
+func TestOperation(t *testing.T) {
+	type request struct {
+		method, uri, auth, agent, body string
+	}
+	requests := []request{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, request{r.Method, r.URL.RequestURI(), r.Header.Get("Authorization"), r.Header.Get("User-Agent"), string(body)})
+		if r.URL.Path == "/v1/pets/404" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	cfg := config.New()
+	cfg.Set("pets.baseurl", server.URL+"/v1")
+	cfg.Set("pets.header.User-Agent", "flowbase-test")
+	client := &Client{
+		BaseURL: "https://unused.example.com",
+		Auth:    []Auth{{Type: AuthBearer, Secret: "token"}},
+		Secrets: crypto.StaticSecrets{"token": []byte("s3cret")},
+		// Kept-alive connections would be reported as leaked goroutines
+		HTTPClient: &http.Client{Transport: &http.Transport{DisableKeepAlives: true}},
+	}
+	client.Configure(cfg, "pets")
+
+	net := flowbasetest.NewTestNetwork(t)
+	op := NewOperation(net.Network, "update", client, OperationSpec{
+		Method:  "PUT",
+		Path:    "/pets/{id}",
+		Params:  []Param{{Name: "id", In: "path", Required: true}, {Name: "dryrun", In: "query"}},
+		HasBody: true,
+	})
+	ip1 := fb.NewPacket(map[string]any{"name": "Rex"})
+	ip1.AddTags(map[string]string{"id": "7", "dryrun": "true"})
+	ip2 := fb.NewPacket("raw")
+	ip2.AddTag("id", "404")
+	flowbasetest.FeedPort(op.In(), ip1, ip2)
+	out := flowbasetest.CollectPort[[]byte](op.Out())
+	errs := flowbasetest.CollectPort[[]byte](op.Errors())
+	net.Run()
+
+	want := []request{
+		{"PUT", "/v1/pets/7?dryrun=true", "Bearer s3cret", "flowbase-test", `{"name":"Rex"}`},
+		{"PUT", "/v1/pets/404", "Bearer s3cret", "flowbase-test", "raw"},
+	}
+	if len(requests) != len(want) {
+		t.Fatalf("Got wrong requests: %v", requests)
+	}
+	for i := range want {
+		if requests[i] != want[i] {
+			t.Errorf("Got wrong request: %+v, wanted: %+v", requests[i], want[i])
+		}
+	}
+	if ips := out.Packets(); len(ips) != 1 || ips[0].Tag("status") != "200" || ips[0].Tag("id") != "7" || string(out.Values()[0]) != `{"ok":true}` {
+		t.Errorf("Got wrong responses: %v", ips)
+	}
+	if ips := errs.Packets(); len(ips) != 1 || ips[0].Tag("status") != "404" {
+		t.Errorf("Got wrong error responses: %v", ips)
+	}
+}
+
+func TestOperationIdempotency(t *testing.T) {
+	keys := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+	client := &Client{
+		BaseURL:    server.URL,
+		HTTPClient: &http.Client{Transport: &http.Transport{DisableKeepAlives: true}},
+	}
+
+	processed := fb.NewMemoryKeys()
+	processed.MarkProcessed("done")
+	net := flowbasetest.NewTestNetwork(t)
+	op := NewOperation(net.Network, "post", client, OperationSpec{
+		Method: "POST",
+		Path:   "/{path}",
+		Params: []Param{{Name: "path", In: "path", Required: true}},
+	})
+	op.Processed = processed
+	ips := []any{}
+	for _, k := range [][2]string{{"done", "ok"}, {"new", "ok"}, {"failing", "fail"}} {
+		ip := fb.NewPacket(nil)
+		ip.SetIdempotencyKey(k[0])
+		ip.AddTag("path", k[1])
+		ips = append(ips, ip)
+	}
+	flowbasetest.FeedPort(op.In(), ips...)
+	flowbasetest.CollectPort[[]byte](op.Out())
+	flowbasetest.CollectPort[[]byte](op.Errors())
+	net.Run()
+
+	if len(keys) != 2 || keys[0] != "new" || keys[1] != "failing" {
+		t.Errorf("Got wrong requests, with idempotency keys: %v", keys)
+	}
+	if done, _ := processed.Processed("new"); !done {
+		t.Errorf("Successful request was not marked as processed")
+	}
+	if done, _ := processed.Processed("failing"); done {
+		t.Errorf("Failed request was marked as processed")
+	}
+}
+
+func TestOperationQuota(t *testing.T) {
+	mx := sync.Mutex{}
+	inFlight, maxInFlight := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mx.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mx.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mx.Lock()
+		inFlight--
+		mx.Unlock()
+	}))
+	defer server.Close()
+	client := &Client{
+		BaseURL:    server.URL,
+		HTTPClient: &http.Client{Transport: &http.Transport{DisableKeepAlives: true}},
+		Quota:      "pets",
+	}
+
+	net := flowbasetest.NewTestNetwork(t)
+	net.SetQuota("pets", fb.QuotaConfig{MaxConcurrent: 1})
+	for _, name := range []string{"get1", "get2"} {
+		op := NewOperation(net.Network, name, client, OperationSpec{Method: "GET", Path: "/pets"})
+		flowbasetest.FeedPort(op.In(), fb.NewPacket(nil), fb.NewPacket(nil), fb.NewPacket(nil))
+		flowbasetest.CollectPort[[]byte](op.Out())
+		flowbasetest.CollectPort[[]byte](op.Errors())
+	}
+	net.Run()
+
+	if maxInFlight != 1 {
+		t.Errorf("Expected at most 1 request in flight, got %d", maxInFlight)
+	}
+}
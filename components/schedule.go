@@ -0,0 +1,115 @@
+package components
+
+import (
+	"strconv"
+	"time"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// ------------------------------------------------------------------------
+// Ticker
+// ------------------------------------------------------------------------
+
+// Ticker sends a trigger packet every interval, with the time of the tick as
+// data, tagged with the number of the tick, starting from 1, as "tick", so
+// that long-running networks can start sub-flows periodically. Ticks are
+// skipped, rather than queued, when downstream processes are too slow to
+// receive them.
+type Ticker struct {
+	fb.BaseProcess
+	interval time.Duration
+	// MaxTicks is the number of ticks after which the process finishes.
+	// Defaults to 0, which sends ticks until the network is stopped.
+	MaxTicks int
+}
+
+// NewTicker returns a new Ticker process, ticking every interval
+func NewTicker(net *fb.Network, name string, interval time.Duration) *Ticker {
+	if interval <= 0 {
+		fb.Failf("Interval of ticker %s must be positive, got %s", name, interval)
+	}
+	p := &Ticker{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		interval:    interval,
+	}
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// Out returns the out-port, on which the ticks are sent
+func (p *Ticker) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Run runs the Ticker process
+func (p *Ticker) Run() {
+	defer p.CloseOutPorts()
+	clock := p.Network().Clock()
+	next := clock.Now()
+	for tick := 1; p.MaxTicks == 0 || tick <= p.MaxTicks; tick++ {
+		next = next.Add(p.interval)
+		// Skip ticks that have already passed
+		if now := clock.Now(); next.Before(now) {
+			next = next.Add(now.Sub(next).Truncate(p.interval) + p.interval)
+		}
+		clock.Sleep(next.Sub(clock.Now()))
+		p.Out().SendPacket(tickPacket(next, tick))
+	}
+}
+
+func tickPacket(t time.Time, tick int) *fb.Packet {
+	ip := fb.NewPacket(t)
+	ip.AddTag("tick", strconv.Itoa(tick))
+	return ip
+}
+
+// ------------------------------------------------------------------------
+// CronSource
+// ------------------------------------------------------------------------
+
+// CronSource sends a trigger packet at the times matching a cron expression
+// (see ParseCron), in the local time zone of the network clock, with the
+// time as data, tagged with the number of the trigger, starting from 1, as
+// "tick"
+type CronSource struct {
+	fb.BaseProcess
+	expr     string
+	schedule *CronSchedule
+	// MaxTicks is the number of triggers after which the process finishes.
+	// Defaults to 0, which sends triggers until the network is stopped.
+	MaxTicks int
+}
+
+// NewCronSource returns a new CronSource process, triggering at the times of
+// the cron expression expr, such as "0 6 * * mon-fri"
+func NewCronSource(net *fb.Network, name string, expr string) *CronSource {
+	schedule, err := ParseCron(expr)
+	if err != nil {
+		fb.Failf("Could not create cron source %s: %v", name, err)
+	}
+	p := &CronSource{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		expr:        expr,
+		schedule:    schedule,
+	}
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// Out returns the out-port, on which the triggers are sent
+func (p *CronSource) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Run runs the CronSource process
+func (p *CronSource) Run() {
+	defer p.CloseOutPorts()
+	clock := p.Network().Clock()
+	for tick := 1; p.MaxTicks == 0 || tick <= p.MaxTicks; tick++ {
+		next := p.schedule.Next(clock.Now())
+		if next.IsZero() {
+			p.Failf("Cron expression never matches: %s", p.expr)
+		}
+		clock.Sleep(next.Sub(clock.Now()))
+		p.Out().SendPacket(tickPacket(next, tick))
+	}
+}
@@ -0,0 +1,100 @@
+package components
+
+import (
+	"testing"
+	"time"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+func TestTicker(t *testing.T) {
+	initTestLogs()
+	net := fb.NewNetwork("TestTicker")
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := fb.NewVirtualClock(start)
+	net.SetClock(clock)
+
+	ticker := NewTicker(net, "ticker", time.Minute)
+	ticker.MaxTicks = 3
+	out := newCollector(net, "out")
+	out.In().From(ticker.Out())
+
+	done := make(chan struct{})
+	go func() {
+		net.Run()
+		close(done)
+	}()
+	for i := 0; i < 3; i++ {
+		clock.BlockUntilWaiters(1)
+		clock.Advance(time.Minute)
+	}
+	<-done
+
+	assertEqualValues(t, []any{start.Add(time.Minute), start.Add(2 * time.Minute), start.Add(3 * time.Minute)}, out.data())
+	if tick := out.ips[2].Tag("tick"); tick != "3" {
+		t.Errorf("Wrong tick tag: %s", tick)
+	}
+}
+
+func TestCronSource(t *testing.T) {
+	initTestLogs()
+	net := fb.NewNetwork("TestCronSource")
+	start := time.Date(2020, 1, 1, 10, 5, 0, 0, time.UTC)
+	clock := fb.NewVirtualClock(start)
+	net.SetClock(clock)
+
+	cron := NewCronSource(net, "cron", "*/15 * * * *")
+	cron.MaxTicks = 2
+	out := newCollector(net, "out")
+	out.In().From(cron.Out())
+
+	done := make(chan struct{})
+	go func() {
+		net.Run()
+		close(done)
+	}()
+	clock.BlockUntilWaiters(1)
+	clock.Advance(10 * time.Minute)
+	clock.BlockUntilWaiters(1)
+	clock.Advance(15 * time.Minute)
+	<-done
+
+	assertEqualValues(t, []any{start.Add(10 * time.Minute), start.Add(25 * time.Minute)}, out.data())
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	// 2020-01-01 is a Wednesday
+	from := time.Date(2020, 1, 1, 10, 5, 30, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2020, 1, 1, 10, 6, 0, 0, time.UTC)},
+		{"0 * * * *", time.Date(2020, 1, 1, 11, 0, 0, 0, time.UTC)},
+		{"30 9-17/2 * * *", time.Date(2020, 1, 1, 11, 30, 0, 0, time.UTC)},
+		{"0 6 * * mon-fri", time.Date(2020, 1, 2, 6, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2020, 1, 5, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 feb *", time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: either matches
+		{"0 0 13 * fri", time.Date(2020, 1, 3, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		schedule, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Errorf("Could not parse %s: %v", tt.expr, err)
+			continue
+		}
+		if got := schedule.Next(from); !got.Equal(tt.want) {
+			t.Errorf("Next of %s: got %s, wanted %s", tt.expr, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{"* * * *", "60 * * * *", "* * * foo *", "5-1 * * * *", "*/0 * * * *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("Invalid expression parsed: %s", expr)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package components
+
+import (
+	"bufio"
+	"container/heap"
+	"io"
+	"os"
+	"sort"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// Sort sorts the stream of packets arriving on its in-port, using the less
+// function, and sends them on in order on its out-port when the input stream
+// has ended. When the packets kept in memory reach MaxMemory bytes, or
+// MaxInMemory packets, they are spilled to a temporary file as a sorted run,
+// and the runs are merged in the end (an external merge sort). Packet data
+// must be serializable with Codec for this.
+type Sort struct {
+	fb.BaseProcess
+	less func(a, b *fb.Packet) bool
+	// MaxMemory is the memory budget, as the maximum approximate size in
+	// bytes (see Packet.Size) of the packets kept in memory before a sorted
+	// run is spilled to disk. Values <= 0 mean the default, of 64 MB.
+	MaxMemory int64
+	// MaxInMemory is the maximum number of packets kept in memory before a
+	// sorted run is spilled to disk, whatever their size. Values <= 0 mean
+	// the default, of 100000 packets.
+	MaxInMemory int
+	// Codec is used to serialize spilled packets
+	Codec fb.Codec
+	// TempDir is the directory for spill files. Defaults to os.TempDir().
+	TempDir string
+}
+
+const (
+	defaultSortMaxMemory   = 64 << 20
+	defaultSortMaxInMemory = 100000
+)
+
+// NewSort returns a new Sort process, sorting packets according to less
+func NewSort(net *fb.Network, name string, less func(a, b *fb.Packet) bool) *Sort {
+	p := &Sort{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		less:        less,
+		MaxMemory:   defaultSortMaxMemory,
+		MaxInMemory: defaultSortMaxInMemory,
+		Codec:       fb.NewGobCodec(),
+	}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// LessByTag returns a less function for Sort, comparing packets by the value
+// of the tag tag
+func LessByTag(tag string) func(a, b *fb.Packet) bool {
+	return func(a, b *fb.Packet) bool {
+		return a.Tag(tag) < b.Tag(tag)
+	}
+}
+
+// In returns the in-port
+func (p *Sort) In() *fb.InPort { return p.InPort("in") }
+
+// Out returns the out-port, on which sorted packets are sent
+func (p *Sort) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Run runs the Sort process
+func (p *Sort) Run() {
+	defer p.CloseOutPorts()
+
+	maxMemory, maxInMemory := p.MaxMemory, p.MaxInMemory
+	if maxMemory <= 0 {
+		maxMemory = defaultSortMaxMemory
+	}
+	if maxInMemory <= 0 {
+		maxInMemory = defaultSortMaxInMemory
+	}
+	buf := []*fb.Packet{}
+	var bufSize int64
+	spillFiles := []string{}
+	defer func() {
+		for _, f := range spillFiles {
+			os.Remove(f)
+		}
+	}()
+
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		buf = append(buf, ip)
+		bufSize += ip.Size()
+		if bufSize >= maxMemory || len(buf) >= maxInMemory {
+			spillFiles = append(spillFiles, p.spill(buf))
+			buf, bufSize = []*fb.Packet{}, 0
+		}
+	}
+
+	if len(spillFiles) == 0 {
+		sort.SliceStable(buf, func(i, j int) bool { return p.less(buf[i], buf[j]) })
+		for _, ip := range buf {
+			p.Out().SendPacket(ip)
+		}
+		return
+	}
+	if len(buf) > 0 {
+		spillFiles = append(spillFiles, p.spill(buf))
+	}
+	p.merge(spillFiles)
+}
+
+// spill sorts buf and writes it to a new temporary file, returning its path
+func (p *Sort) spill(buf []*fb.Packet) string {
+	sort.SliceStable(buf, func(i, j int) bool { return p.less(buf[i], buf[j]) })
+
+	f, err := os.CreateTemp(p.TempDir, "flowbase-sort-*")
+	if err != nil {
+		p.Failf("Could not create spill file: %v", err)
+	}
+	defer f.Close()
+	bw := bufio.NewWriter(f)
+	enc := p.Codec.NewEncoder(bw)
+	for _, ip := range buf {
+		if err := enc.Encode(ip); err != nil {
+			p.Fail(err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		p.Failf("Could not write spill file %s: %v", f.Name(), err)
+	}
+	fb.Debug.Printf("[Process:%s] Spilled %d packets to %s\n", p.Name(), len(buf), f.Name())
+	return f.Name()
+}
+
+// merge does a k-way merge of the sorted runs in spillFiles, sending the
+// packets on the out-port
+func (p *Sort) merge(spillFiles []string) {
+	h := &sortRunHeap{less: p.less}
+	for i, path := range spillFiles {
+		f, err := os.Open(path)
+		if err != nil {
+			p.Failf("Could not open spill file %s: %v", path, err)
+		}
+		defer f.Close()
+		run := &sortRun{idx: i, dec: p.Codec.NewDecoder(bufio.NewReader(f))}
+		if p.advance(run) {
+			heap.Push(h, run)
+		}
+	}
+	for h.Len() > 0 {
+		run := h.runs[0]
+		p.Out().SendPacket(run.head)
+		if p.advance(run) {
+			heap.Fix(h, 0)
+		} else {
+			heap.Pop(h)
+		}
+	}
+}
+
+// advance reads the next packet of run into run.head, returning false when the
+// run is exhausted
+func (p *Sort) advance(run *sortRun) bool {
+	ip, err := run.dec.Decode()
+	if err != nil {
+		if err == io.EOF {
+			return false
+		}
+		p.Fail(err)
+	}
+	run.head = ip
+	return true
+}
+
+type sortRun struct {
+	idx  int
+	head *fb.Packet
+	dec  fb.PacketDecoder
+}
+
+// sortRunHeap is a min-heap of sorted runs, ordered by their head packets, and
+// by run index for equal packets, to keep the sort stable
+type sortRunHeap struct {
+	runs []*sortRun
+	less func(a, b *fb.Packet) bool
+}
+
+func (h *sortRunHeap) Len() int { return len(h.runs) }
+
+func (h *sortRunHeap) Less(i, j int) bool {
+	a, b := h.runs[i], h.runs[j]
+	if h.less(a.head, b.head) {
+		return true
+	}
+	if h.less(b.head, a.head) {
+		return false
+	}
+	return a.idx < b.idx
+}
+
+func (h *sortRunHeap) Swap(i, j int) { h.runs[i], h.runs[j] = h.runs[j], h.runs[i] }
+
+func (h *sortRunHeap) Push(x any) { h.runs = append(h.runs, x.(*sortRun)) }
+
+func (h *sortRunHeap) Pop() any {
+	last := h.runs[len(h.runs)-1]
+	h.runs = h.runs[:len(h.runs)-1]
+	return last
+}
@@ -0,0 +1,81 @@
+package components
+
+import (
+	"io"
+	"strconv"
+	"testing"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// spillCounter is a codec counting the sorted runs spilled with it
+type spillCounter struct {
+	fb.Codec
+	runs int
+}
+
+func (c *spillCounter) NewEncoder(w io.Writer) fb.PacketEncoder {
+	c.runs++
+	return c.Codec.NewEncoder(w)
+}
+
+// runSort sorts packets with the numbers 5, 3, 9, 1, 7, 2, 8, 4, 6, of size
+// bytes each if size is not 0, with the Sort process set up by setup, and
+// returns the sorted data and the number of spilled runs
+func runSort(t *testing.T, size int64, setup func(srt *Sort)) ([]any, int) {
+	initTestLogs()
+	net := fb.NewNetwork("TestSort")
+
+	ips := []*fb.Packet{}
+	for _, n := range []int{5, 3, 9, 1, 7, 2, 8, 4, 6} {
+		ip := taggedPacket(n, map[string]string{"n": strconv.Itoa(n)})
+		if size != 0 {
+			ip.SetSize(size)
+		}
+		ips = append(ips, ip)
+	}
+	src := newSliceSource(net, "src", ips...)
+
+	srt := NewSort(net, "sort", LessByTag("n"))
+	counter := &spillCounter{Codec: srt.Codec}
+	srt.Codec = counter
+	srt.TempDir = t.TempDir()
+	setup(srt)
+	srt.In().From(src.Out())
+
+	sorted := newCollector(net, "sorted")
+	sorted.In().From(srt.Out())
+
+	net.Run()
+
+	return sorted.data(), counter.runs
+}
+
+func TestSortSpillsToDisk(t *testing.T) {
+	data, runs := runSort(t, 0, func(srt *Sort) { srt.MaxInMemory = 2 })
+
+	assertEqualValues(t, []any{1, 2, 3, 4, 5, 6, 7, 8, 9}, data)
+	assertEqualValues(t, 5, runs)
+}
+
+func TestSortMemoryBudget(t *testing.T) {
+	// Runs are spilled when 3 packets of 40 bytes exceed the budget
+	data, runs := runSort(t, 40, func(srt *Sort) { srt.MaxMemory = 100 })
+
+	assertEqualValues(t, []any{1, 2, 3, 4, 5, 6, 7, 8, 9}, data)
+	assertEqualValues(t, 3, runs)
+}
+
+func TestSortNonPositiveLimits(t *testing.T) {
+	for _, limit := range []int{0, -1} {
+		// The limits default to values which the packets fit in, instead of
+		// spilling every packet to its own run
+		data, runs := runSort(t, 0, func(srt *Sort) {
+			srt.MaxMemory = int64(limit)
+			srt.MaxInMemory = limit
+		})
+
+		assertEqualValues(t, []any{1, 2, 3, 4, 5, 6, 7, 8, 9}, data)
+		assertEqualValues(t, 0, runs)
+	}
+}
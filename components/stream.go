@@ -0,0 +1,234 @@
+package components
+
+import (
+	"sync"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// ------------------------------------------------------------------------
+// Concat
+// ------------------------------------------------------------------------
+
+// Concat sends on all packets of the stream on its first in-port, then all
+// packets of the stream on its second in-port, and so on, like cat does with
+// files. Packets arriving on the later in-ports are buffered in memory while
+// the earlier streams are sent, so that upstream processes never block.
+type Concat struct {
+	fb.BaseProcess
+	inPortNames []string
+}
+
+// NewConcat returns a new Concat process, concatenating the streams on the
+// in-ports named inPortNames, in that order
+func NewConcat(net *fb.Network, name string, inPortNames ...string) *Concat {
+	if len(inPortNames) < 2 {
+		fb.Failf("Concat (%s) needs at least two in-ports, got: %v", name, inPortNames)
+	}
+	p := &Concat{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		inPortNames: inPortNames,
+	}
+	for _, inPortName := range inPortNames {
+		p.InitInPort(p, inPortName)
+	}
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port with name portName
+func (p *Concat) In(portName string) *fb.InPort { return p.InPort(portName) }
+
+// Out returns the out-port
+func (p *Concat) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Run runs the Concat process
+func (p *Concat) Run() {
+	defer p.CloseOutPorts()
+
+	// All streams but the first are buffered concurrently
+	buffered := make([][]*fb.Packet, len(p.inPortNames))
+	wg := &sync.WaitGroup{}
+	for i, portName := range p.inPortNames[1:] {
+		wg.Add(1)
+		go func(i int, pt *fb.InPort) {
+			defer wg.Done()
+			for ip, ok := pt.RecvOK(); ok; ip, ok = pt.RecvOK() {
+				buffered[i] = append(buffered[i], ip)
+			}
+		}(i+1, p.In(portName))
+	}
+
+	first := p.In(p.inPortNames[0])
+	for ip, ok := first.RecvOK(); ok; ip, ok = first.RecvOK() {
+		p.Out().SendPacket(ip)
+	}
+	wg.Wait()
+	for _, ips := range buffered[1:] {
+		for _, ip := range ips {
+			p.Out().SendPacket(ip)
+		}
+	}
+}
+
+// ------------------------------------------------------------------------
+// Head
+// ------------------------------------------------------------------------
+
+// Head sends on the first n packets it receives, like head does with lines,
+// and discards the rest
+type Head struct {
+	fb.BaseProcess
+	n int
+}
+
+// NewHead returns a new Head process, sending on the first n packets
+func NewHead(net *fb.Network, name string, n int) *Head {
+	p := &Head{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		n:           n,
+	}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *Head) In() *fb.InPort { return p.InPort("in") }
+
+// Out returns the out-port
+func (p *Head) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Run runs the Head process. The out-port is closed as soon as n packets
+// have been sent, while the rest of the input stream is drained, so that
+// upstream processes can finish.
+func (p *Head) Run() {
+	defer p.CloseOutPorts()
+	sent := 0
+	if p.n <= 0 {
+		p.CloseOutPorts()
+	}
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		if sent < p.n {
+			p.Out().SendPacket(ip)
+			sent++
+			if sent == p.n {
+				p.CloseOutPorts()
+			}
+		}
+	}
+}
+
+// ------------------------------------------------------------------------
+// Skip
+// ------------------------------------------------------------------------
+
+// Skip discards the first n packets it receives, and sends on the rest
+type Skip struct {
+	fb.BaseProcess
+	n int
+}
+
+// NewSkip returns a new Skip process, skipping the first n packets
+func NewSkip(net *fb.Network, name string, n int) *Skip {
+	p := &Skip{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		n:           n,
+	}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *Skip) In() *fb.InPort { return p.InPort("in") }
+
+// Out returns the out-port
+func (p *Skip) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Run runs the Skip process
+func (p *Skip) Run() {
+	defer p.CloseOutPorts()
+	skipped := 0
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		if skipped < p.n {
+			skipped++
+			continue
+		}
+		p.Out().SendPacket(ip)
+	}
+}
+
+// ------------------------------------------------------------------------
+// Chunk
+// ------------------------------------------------------------------------
+
+// ChunkedPackets is the data of packets emitted by Chunk, containing the
+// packets of a chunk, in the order they arrived
+type ChunkedPackets []*fb.Packet
+
+// Chunk groups the packets it receives into chunks of n packets, and sends
+// one packet with ChunkedPackets data per chunk. The last chunk can contain
+// fewer packets. Tags with the same value on all packets of a chunk are set
+// on the emitted packet.
+type Chunk struct {
+	fb.BaseProcess
+	n int
+}
+
+// NewChunk returns a new Chunk process, grouping packets in chunks of n
+func NewChunk(net *fb.Network, name string, n int) *Chunk {
+	if n < 1 {
+		fb.Failf("Chunk (%s) needs a chunk size of at least 1, got: %d", name, n)
+	}
+	p := &Chunk{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		n:           n,
+	}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *Chunk) In() *fb.InPort { return p.InPort("in") }
+
+// Out returns the out-port, on which the chunks are sent
+func (p *Chunk) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Run runs the Chunk process
+func (p *Chunk) Run() {
+	defer p.CloseOutPorts()
+	chunk := ChunkedPackets{}
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		chunk = append(chunk, ip)
+		if len(chunk) == p.n {
+			p.sendChunk(chunk)
+			chunk = ChunkedPackets{}
+		}
+	}
+	if len(chunk) > 0 {
+		p.sendChunk(chunk)
+	}
+}
+
+func (p *Chunk) sendChunk(chunk ChunkedPackets) {
+	out := fb.NewPacket(chunk)
+	for k, v := range chunk[0].Tags() {
+		common := true
+		for _, ip := range chunk[1:] {
+			if ip.Tag(k) != v {
+				common = false
+				break
+			}
+		}
+		if common {
+			out.AddTag(k, v)
+		}
+	}
+	p.Out().SendPacket(out)
+}
@@ -0,0 +1,82 @@
+package components
+
+import (
+	"testing"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+func TestConcat(t *testing.T) {
+	initTestLogs()
+	net := fb.NewNetwork("test_concat")
+	srcA := newSliceSource(net, "src_a", fb.NewPacket("a1"), fb.NewPacket("a2"))
+	srcB := newSliceSource(net, "src_b", fb.NewPacket("b1"), fb.NewPacket("b2"), fb.NewPacket("b3"))
+	concat := NewConcat(net, "concat", "a", "b")
+	concat.In("a").From(srcA.Out())
+	concat.In("b").From(srcB.Out())
+	sink := newCollector(net, "sink")
+	sink.In().From(concat.Out())
+	net.Run()
+
+	assertEqualValues(t, []any{"a1", "a2", "b1", "b2", "b3"}, sink.data())
+}
+
+func TestHeadSkip(t *testing.T) {
+	initTestLogs()
+	ips := func() []*fb.Packet {
+		return []*fb.Packet{fb.NewPacket(1), fb.NewPacket(2), fb.NewPacket(3), fb.NewPacket(4), fb.NewPacket(5)}
+	}
+
+	for _, tc := range []struct {
+		n        int
+		wantHead []any
+		wantSkip []any
+	}{
+		{n: 0, wantHead: []any{}, wantSkip: []any{1, 2, 3, 4, 5}},
+		{n: 2, wantHead: []any{1, 2}, wantSkip: []any{3, 4, 5}},
+		{n: 7, wantHead: []any{1, 2, 3, 4, 5}, wantSkip: []any{}},
+	} {
+		net := fb.NewNetwork("test_head_skip")
+		headSrc := newSliceSource(net, "head_src", ips()...)
+		head := NewHead(net, "head", tc.n)
+		head.In().From(headSrc.Out())
+		headSink := newCollector(net, "head_sink")
+		headSink.In().From(head.Out())
+
+		skipSrc := newSliceSource(net, "skip_src", ips()...)
+		skip := NewSkip(net, "skip", tc.n)
+		skip.In().From(skipSrc.Out())
+		skipSink := newCollector(net, "skip_sink")
+		skipSink.In().From(skip.Out())
+		net.Run()
+
+		assertEqualValues(t, tc.wantHead, headSink.data())
+		assertEqualValues(t, tc.wantSkip, skipSink.data())
+	}
+}
+
+func TestChunk(t *testing.T) {
+	initTestLogs()
+	net := fb.NewNetwork("test_chunk")
+	src := newSliceSource(net, "src",
+		taggedPacket(1, map[string]string{"sample": "s1", "lane": "1"}),
+		taggedPacket(2, map[string]string{"sample": "s1", "lane": "2"}),
+		taggedPacket(3, map[string]string{"sample": "s2", "lane": "1"}),
+	)
+	chunk := NewChunk(net, "chunk", 2)
+	chunk.In().From(src.Out())
+	sink := newCollector(net, "sink")
+	sink.In().From(chunk.Out())
+	net.Run()
+
+	if len(sink.ips) != 2 {
+		t.Fatalf("Expected 2 chunks, got %d", len(sink.ips))
+	}
+	sizes := []int{}
+	for _, ip := range sink.ips {
+		sizes = append(sizes, len(ip.Data().(ChunkedPackets)))
+	}
+	assertEqualValues(t, []int{2, 1}, sizes)
+	assertEqualValues(t, map[string]string{"sample": "s1"}, sink.ips[0].Tags())
+	assertEqualValues(t, map[string]string{"sample": "s2", "lane": "1"}, sink.ips[1].Tags())
+}
@@ -0,0 +1,122 @@
+package text
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// The text components are registered, with their descriptors, so that they
+// can be used in graph files (see fb.NewNetworkFromGraph)
+func init() {
+	fb.RegisterComponent("Grep", func(net *fb.Network, name string, metadata map[string]string) (fb.Node, error) {
+		p := NewGrep(net, name, metadata["pattern"])
+		p.Invert = metadata["invert"] == "true"
+		return p, nil
+	})
+	fb.RegisterComponent("ReplaceRegexp", func(net *fb.Network, name string, metadata map[string]string) (fb.Node, error) {
+		return NewReplaceRegexp(net, name, metadata["pattern"], metadata["replacement"]), nil
+	})
+	fb.RegisterComponent("FieldExtractor", func(net *fb.Network, name string, metadata map[string]string) (fb.Node, error) {
+		fields := []int{}
+		for _, f := range strings.Fields(metadata["fields"]) {
+			n, err := strconv.Atoi(f)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid field number in fields metadata: %s", f)
+			}
+			fields = append(fields, n)
+		}
+		return NewFieldExtractor(net, name, metadata["sep"], fields...), nil
+	})
+	fb.RegisterComponent("Tokenizer", func(net *fb.Network, name string, metadata map[string]string) (fb.Node, error) {
+		return NewTokenizer(net, name, metadata["pattern"]), nil
+	})
+	fb.RegisterComponent("TemplateFormatter", func(net *fb.Network, name string, metadata map[string]string) (fb.Node, error) {
+		return NewTemplateFormatter(net, name, metadata["template"]), nil
+	})
+
+	stringIn := []fb.PortSpec{{Name: "in", Type: "string"}}
+	stringOut := []fb.PortSpec{{Name: "out", Type: "string"}}
+	for _, info := range []fb.ComponentInfo{
+		{
+			Name:        "Grep",
+			Description: "Sends on the strings matching the pattern metadata regexp, or not matching it if the invert metadata is true",
+			Params: []fb.ParamSpec{
+				{Name: "pattern", Type: "regexp", Description: "The regexp to match", Required: true},
+				{Name: "invert", Type: "bool", Description: "Send on the strings not matching the pattern"},
+			},
+		},
+		{
+			Name:        "ReplaceRegexp",
+			Description: "Replaces all matches of the pattern metadata regexp with the replacement metadata",
+			Params: []fb.ParamSpec{
+				{Name: "pattern", Type: "regexp", Description: "The regexp to replace the matches of", Required: true},
+				{Name: "replacement", Type: "string", Description: "The replacement, which can refer to groups as $1"},
+			},
+		},
+		{
+			Name:        "FieldExtractor",
+			Description: "Sends on the fields with the numbers in the fields metadata (space-separated, from 1), split by the sep metadata, or whitespace",
+			Params: []fb.ParamSpec{
+				{Name: "fields", Type: "string", Description: "The numbers of the fields, from 1, space-separated", Required: true},
+				{Name: "sep", Type: "string", Description: "The field separator. Defaults to whitespace"},
+			},
+		},
+		{
+			Name:        "Tokenizer",
+			Description: "Sends each match of the pattern metadata regexp, or each word, as a string of its own",
+			Params: []fb.ParamSpec{
+				{Name: "pattern", Type: "regexp", Description: "The regexp matching the tokens. Defaults to words"},
+			},
+		},
+		{
+			Name:        "TemplateFormatter",
+			Description: "Formats strings with the template metadata, where {d:data} is the string and {t:name} a tag",
+			Params: []fb.ParamSpec{
+				{Name: "template", Type: "string", Description: "The template, such as \"{t:name}: {d:data}\"", Required: true},
+			},
+		},
+	} {
+		info.Version = fb.Version
+		info.InPorts = stringIn
+		info.OutPorts = stringOut
+		fb.RegisterComponentInfo(info)
+	}
+}
+
+// ComponentMetadata returns the pattern of the process, and whether it is
+// inverted
+func (p *Grep) ComponentMetadata() map[string]string {
+	metadata := map[string]string{"pattern": p.ptn.String()}
+	if p.Invert {
+		metadata["invert"] = "true"
+	}
+	return metadata
+}
+
+// ComponentMetadata returns the pattern and replacement of the process
+func (p *ReplaceRegexp) ComponentMetadata() map[string]string {
+	return map[string]string{"pattern": p.ptn.String(), "replacement": p.replacement}
+}
+
+// ComponentMetadata returns the separator and fields of the process. Tag
+// fields are not included.
+func (p *FieldExtractor) ComponentMetadata() map[string]string {
+	fields := []string{}
+	for _, f := range p.fields {
+		fields = append(fields, strconv.Itoa(f))
+	}
+	return map[string]string{"sep": p.sep, "fields": strings.Join(fields, " ")}
+}
+
+// ComponentMetadata returns the token pattern of the process
+func (p *Tokenizer) ComponentMetadata() map[string]string {
+	return map[string]string{"pattern": p.ptn.String()}
+}
+
+// ComponentMetadata returns the template of the process
+func (p *TemplateFormatter) ComponentMetadata() map[string]string {
+	return map[string]string{"template": p.tpl.String()}
+}
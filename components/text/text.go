@@ -0,0 +1,320 @@
+// Package text contains components for processing packets with string data,
+// such as lines of text: filtering them by regexp (Grep), replacing text in
+// them (ReplaceRegexp), extracting fields (FieldExtractor), splitting them
+// into tokens (Tokenizer), and formatting them with templates
+// (TemplateFormatter).
+//
+// All components send on the tags of the packets they receive, and fail on
+// packets whose data is not a string or []byte.
+package text
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	fb "github.com/flowbase/flowbase"
+	"github.com/flowbase/flowbase/template"
+)
+
+// stringData returns the data of the packet ip as a string
+func stringData(ip *fb.Packet) string {
+	switch data := ip.Data().(type) {
+	case string:
+		return data
+	case []byte:
+		return string(data)
+	}
+	ip.Failf("Data (%v) of type %T is not a string", ip.Data(), ip.Data())
+	return ""
+}
+
+// ------------------------------------------------------------------------
+// Grep
+// ------------------------------------------------------------------------
+
+// Grep sends on the packets whose data matches a regular expression, and
+// discards the rest, like grep does with lines
+type Grep struct {
+	fb.BaseProcess
+	ptn *regexp.Regexp
+	// Invert makes the process send on the packets which do not match the
+	// pattern instead, like grep -v
+	Invert bool
+}
+
+// NewGrep returns a new Grep process, filtering on the regular expression
+// pattern
+func NewGrep(net *fb.Network, name string, pattern string) *Grep {
+	p := &Grep{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		ptn:         mustCompile(name, pattern),
+	}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *Grep) In() *fb.InPort { return p.InPort("in") }
+
+// Out returns the out-port
+func (p *Grep) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Run runs the Grep process
+func (p *Grep) Run() {
+	defer p.CloseOutPorts()
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		if p.ptn.MatchString(stringData(ip)) != p.Invert {
+			p.Out().SendPacket(ip)
+		}
+	}
+}
+
+// ------------------------------------------------------------------------
+// ReplaceRegexp
+// ------------------------------------------------------------------------
+
+// ReplaceRegexp replaces all matches of a regular expression in the data of
+// the packets it receives, like sed 's/pattern/replacement/g' does with lines
+type ReplaceRegexp struct {
+	fb.BaseProcess
+	ptn         *regexp.Regexp
+	replacement string
+}
+
+// NewReplaceRegexp returns a new ReplaceRegexp process, replacing matches of
+// the regular expression pattern with replacement, in which $1 or ${name}
+// are replaced by the text of the corresponding submatch, as with
+// regexp.Regexp.ReplaceAllString
+func NewReplaceRegexp(net *fb.Network, name string, pattern string, replacement string) *ReplaceRegexp {
+	p := &ReplaceRegexp{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		ptn:         mustCompile(name, pattern),
+		replacement: replacement,
+	}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *ReplaceRegexp) In() *fb.InPort { return p.InPort("in") }
+
+// Out returns the out-port
+func (p *ReplaceRegexp) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Run runs the ReplaceRegexp process
+func (p *ReplaceRegexp) Run() {
+	defer p.CloseOutPorts()
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		p.Out().SendPacket(fb.NewDerivedPacket(ip, p.ptn.ReplaceAllString(stringData(ip), p.replacement)))
+	}
+}
+
+// ------------------------------------------------------------------------
+// FieldExtractor
+// ------------------------------------------------------------------------
+
+// FieldExtractor splits the data of the packets it receives into fields, and
+// sends on the selected fields, joined by the separator, like cut -f does
+// with lines. Fields can also be set as tags on the packets, with TagField.
+type FieldExtractor struct {
+	fb.BaseProcess
+	sep       string
+	fields    []int
+	tagFields map[string]int
+}
+
+// NewFieldExtractor returns a new FieldExtractor process, splitting on the
+// separator sep, or on runs of whitespace if sep is empty, and sending on
+// the fields with the 1-based numbers fields, or all fields if none are
+// given. Fields missing in a packet are sent as empty strings.
+func NewFieldExtractor(net *fb.Network, name string, sep string, fields ...int) *FieldExtractor {
+	for _, f := range fields {
+		if f < 1 {
+			fb.Failf("FieldExtractor (%s) got invalid field number %d, fields are numbered from 1", name, f)
+		}
+	}
+	p := &FieldExtractor{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		sep:         sep,
+		fields:      fields,
+		tagFields:   map[string]int{},
+	}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *FieldExtractor) In() *fb.InPort { return p.InPort("in") }
+
+// Out returns the out-port
+func (p *FieldExtractor) Out() *fb.OutPort { return p.OutPort("out") }
+
+// TagField makes the process set the tag tag, on the packets it sends, to the
+// value of the field with the 1-based number field
+func (p *FieldExtractor) TagField(tag string, field int) {
+	if field < 1 {
+		p.Failf("Invalid field number %d for tag %s, fields are numbered from 1", field, tag)
+	}
+	p.tagFields[tag] = field
+}
+
+// Run runs the FieldExtractor process
+func (p *FieldExtractor) Run() {
+	defer p.CloseOutPorts()
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		fields := p.split(stringData(ip))
+		selected := fields
+		if len(p.fields) > 0 {
+			selected = []string{}
+			for _, f := range p.fields {
+				selected = append(selected, field(fields, f))
+			}
+		}
+		sep := p.sep
+		if sep == "" {
+			sep = " "
+		}
+		newIP := fb.NewDerivedPacket(ip, strings.Join(selected, sep))
+		for tag, f := range p.tagFields {
+			newIP.AddTag(tag, field(fields, f))
+		}
+		p.Out().SendPacket(newIP)
+	}
+}
+
+func (p *FieldExtractor) split(s string) []string {
+	if p.sep == "" {
+		return strings.Fields(s)
+	}
+	return strings.Split(s, p.sep)
+}
+
+// field returns the field with the 1-based number f, or "" if there is none
+func field(fields []string, f int) string {
+	if f > len(fields) {
+		return ""
+	}
+	return fields[f-1]
+}
+
+// ------------------------------------------------------------------------
+// Tokenizer
+// ------------------------------------------------------------------------
+
+// Tokenizer splits the data of the packets it receives into tokens, and sends
+// each token as a packet of its own. The tokens of a packet are numbered by
+// the tag "token", starting from 1.
+type Tokenizer struct {
+	fb.BaseProcess
+	ptn *regexp.Regexp
+}
+
+// NewTokenizer returns a new Tokenizer process, sending on every match of the
+// regular expression pattern as a token, or every run of non-whitespace
+// characters if pattern is empty
+func NewTokenizer(net *fb.Network, name string, pattern string) *Tokenizer {
+	if pattern == "" {
+		pattern = `\S+`
+	}
+	p := &Tokenizer{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		ptn:         mustCompile(name, pattern),
+	}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *Tokenizer) In() *fb.InPort { return p.InPort("in") }
+
+// Out returns the out-port, on which the tokens are sent
+func (p *Tokenizer) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Run runs the Tokenizer process
+func (p *Tokenizer) Run() {
+	defer p.CloseOutPorts()
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		for i, token := range p.ptn.FindAllString(stringData(ip), -1) {
+			newIP := fb.NewDerivedPacket(ip, token)
+			newIP.AddTag("token", fmt.Sprint(i+1))
+			p.Out().SendPacket(newIP)
+		}
+	}
+}
+
+// ------------------------------------------------------------------------
+// TemplateFormatter
+// ------------------------------------------------------------------------
+
+// TemplateFormatter formats each packet it receives with a template, with
+// the placeholder syntax of command patterns (see the template package),
+// where {d:data} is replaced by the data of the packet, and {t:name} by the
+// value of the tag name, such as in "{t:sample}: {d:data|basename}"
+type TemplateFormatter struct {
+	fb.BaseProcess
+	tpl *template.Template
+}
+
+// NewTemplateFormatter returns a new TemplateFormatter process, formatting
+// packets with the template pattern
+func NewTemplateFormatter(net *fb.Network, name string, pattern string) *TemplateFormatter {
+	tpl, err := template.Parse(pattern)
+	if err != nil {
+		fb.Failf("TemplateFormatter (%s) got invalid template: %v", name, err)
+	}
+	for _, ph := range tpl.Placeholders() {
+		if ph.Type != "d" && ph.Type != "t" {
+			fb.Failf("TemplateFormatter (%s) got placeholder %s of unknown type %s, only d and t are supported", name, ph.Raw, ph.Type)
+		}
+	}
+	p := &TemplateFormatter{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		tpl:         tpl,
+	}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *TemplateFormatter) In() *fb.InPort { return p.InPort("in") }
+
+// Out returns the out-port, on which the formatted strings are sent
+func (p *TemplateFormatter) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Run runs the TemplateFormatter process
+func (p *TemplateFormatter) Run() {
+	defer p.CloseOutPorts()
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		s, err := p.tpl.Execute(func(ph *template.Placeholder) ([]string, error) {
+			if ph.Type == "t" {
+				return []string{ip.Tag(ph.Name)}, nil
+			}
+			return []string{stringData(ip)}, nil
+		})
+		if err != nil {
+			ip.Failf("Could not format packet: %v", err)
+		}
+		p.Out().SendPacket(fb.NewDerivedPacket(ip, s))
+	}
+}
+
+// mustCompile compiles the regular expression pattern of the process with
+// name name, or fails
+func mustCompile(name string, pattern string) *regexp.Regexp {
+	ptn, err := regexp.Compile(pattern)
+	if err != nil {
+		fb.Failf("Process (%s) got invalid regular expression (%s): %v", name, pattern, err)
+	}
+	return ptn
+}
@@ -0,0 +1,63 @@
+package text
+
+import (
+	"reflect"
+	"testing"
+
+	fb "github.com/flowbase/flowbase"
+	"github.com/flowbase/flowbase/flowbasetest"
+)
+
+func TestGrepAndReplace(t *testing.T) {
+	net := flowbasetest.NewTestNetwork(t)
+	grep := NewGrep(net.Network, "grep", `^chr\d+`)
+	flowbasetest.FeedPort(grep.In(), "chr1 100", "# comment", "chr2 200")
+	notGrep := NewGrep(net.Network, "grep_v", `^#`)
+	notGrep.Invert = true
+	flowbasetest.FeedPort(notGrep.In(), "chr1 100", "# comment")
+	replace := NewReplaceRegexp(net.Network, "replace", `^chr(\d+)`, "chromosome-$1")
+	replace.In().From(grep.Out())
+	replaced := flowbasetest.CollectPort[string](replace.Out())
+	inverted := flowbasetest.CollectPort[string](notGrep.Out())
+	net.Run()
+
+	assertEqualValues(t, []string{"chromosome-1 100", "chromosome-2 200"}, replaced.Values())
+	assertEqualValues(t, []string{"chr1 100"}, inverted.Values())
+}
+
+func TestFieldExtractor(t *testing.T) {
+	net := flowbasetest.NewTestNetwork(t)
+	fields := NewFieldExtractor(net.Network, "fields", "\t", 3, 1)
+	fields.TagField("sample", 2)
+	flowbasetest.FeedPort(fields.In(), "a\ts1\t1.5", "b\ts2")
+	words := NewFieldExtractor(net.Network, "words", "")
+	flowbasetest.FeedPort(words.In(), "  x   y z ")
+	extracted := flowbasetest.CollectPort[string](fields.Out())
+	allWords := flowbasetest.CollectPort[string](words.Out())
+	net.Run()
+
+	assertEqualValues(t, []string{"1.5\ta", "\tb"}, extracted.Values())
+	assertEqualValues(t, "s2", extracted.Packets()[1].Tag("sample"))
+	assertEqualValues(t, []string{"x y z"}, allWords.Values())
+}
+
+func TestTokenizerAndTemplateFormatter(t *testing.T) {
+	net := flowbasetest.NewTestNetwork(t)
+	tokenizer := NewTokenizer(net.Network, "tokenizer", "")
+	ip := fb.NewPacket("the quick fox")
+	ip.AddTag("line", "7")
+	flowbasetest.FeedPort(tokenizer.In(), ip)
+	formatter := NewTemplateFormatter(net.Network, "formatter", "{t:line}.{t:token}: {d:data}")
+	formatter.In().From(tokenizer.Out())
+	formatted := flowbasetest.CollectPort[string](formatter.Out())
+	net.Run()
+
+	assertEqualValues(t, []string{"7.1: the", "7.2: quick", "7.3: fox"}, formatted.Values())
+}
+
+func assertEqualValues(t *testing.T, expected any, actual any) {
+	t.Helper()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Values are not equal. Expected: %v, got: %v", expected, actual)
+	}
+}
@@ -0,0 +1,346 @@
+package components
+
+import (
+	"sort"
+	"time"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// ------------------------------------------------------------------------
+// Delay
+// ------------------------------------------------------------------------
+
+// Delay sends on every packet it receives after the duration d has passed
+// since it arrived, keeping the original order
+type Delay struct {
+	fb.BaseProcess
+	d time.Duration
+}
+
+// NewDelay returns a new Delay process, delaying packets by d
+func NewDelay(net *fb.Network, name string, d time.Duration) *Delay {
+	p := &Delay{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		d:           d,
+	}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *Delay) In() *fb.InPort { return p.InPort("in") }
+
+// Out returns the out-port
+func (p *Delay) Out() *fb.OutPort { return p.OutPort("out") }
+
+type delayedPacket struct {
+	ip  *fb.Packet
+	due time.Time
+}
+
+// Run runs the Delay process
+func (p *Delay) Run() {
+	defer p.CloseOutPorts()
+
+	clock := p.Network().Clock()
+	delayed := make(chan delayedPacket, fb.BUFSIZE)
+	go func() {
+		defer close(delayed)
+		for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+			delayed <- delayedPacket{ip, clock.Now().Add(p.d)}
+		}
+	}()
+	for dp := range delayed {
+		clock.Sleep(dp.due.Sub(clock.Now()))
+		p.Out().SendPacket(dp.ip)
+	}
+}
+
+// ------------------------------------------------------------------------
+// Debounce
+// ------------------------------------------------------------------------
+
+// Debounce sends on a received packet only when no newer packet has arrived
+// within the quiet period after it, so that of a burst of packets, only the
+// last one is sent. When the input stream ends, any pending packet is sent.
+type Debounce struct {
+	fb.BaseProcess
+	quiet time.Duration
+}
+
+// NewDebounce returns a new Debounce process, with the quiet period quiet
+func NewDebounce(net *fb.Network, name string, quiet time.Duration) *Debounce {
+	p := &Debounce{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		quiet:       quiet,
+	}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *Debounce) In() *fb.InPort { return p.InPort("in") }
+
+// Out returns the out-port
+func (p *Debounce) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Run runs the Debounce process
+func (p *Debounce) Run() {
+	defer p.CloseOutPorts()
+
+	clock := p.Network().Clock()
+	in := recvChan(p.In())
+	timer := clock.NewTimer(p.quiet)
+	timer.Stop()
+	var pending *fb.Packet
+	for {
+		select {
+		case ip, ok := <-in:
+			if !ok {
+				timer.Stop()
+				if pending != nil {
+					p.Out().SendPacket(pending)
+				}
+				return
+			}
+			pending = ip
+			timer.Stop()
+			timer = clock.NewTimer(p.quiet)
+		case <-timer.C():
+			if pending != nil {
+				p.Out().SendPacket(pending)
+				pending = nil
+			}
+		}
+	}
+}
+
+// ------------------------------------------------------------------------
+// Sample
+// ------------------------------------------------------------------------
+
+// Sample sends on at most one packet per interval: the latest one received
+// during that interval. Intervals without packets send nothing. When the input
+// stream ends, any pending packet is sent.
+type Sample struct {
+	fb.BaseProcess
+	interval time.Duration
+}
+
+// NewSample returns a new Sample process, with the sampling interval interval
+func NewSample(net *fb.Network, name string, interval time.Duration) *Sample {
+	p := &Sample{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		interval:    interval,
+	}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *Sample) In() *fb.InPort { return p.InPort("in") }
+
+// Out returns the out-port
+func (p *Sample) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Run runs the Sample process
+func (p *Sample) Run() {
+	defer p.CloseOutPorts()
+
+	in := recvChan(p.In())
+	ticker := p.Network().Clock().NewTicker(p.interval)
+	defer ticker.Stop()
+	var latest *fb.Packet
+	for {
+		select {
+		case ip, ok := <-in:
+			if !ok {
+				if latest != nil {
+					p.Out().SendPacket(latest)
+				}
+				return
+			}
+			latest = ip
+		case <-ticker.C():
+			if latest != nil {
+				p.Out().SendPacket(latest)
+				latest = nil
+			}
+		}
+	}
+}
+
+// ------------------------------------------------------------------------
+// HoldUntil
+// ------------------------------------------------------------------------
+
+// HoldUntil buffers the packets it receives, and releases each of them at
+// its release time, such as for scheduled publishing. The release time is
+// either fixed, or computed from a tag of each packet, plus an embargo
+// duration. Packets are released in the order of their release times, and
+// those whose release time has already passed are sent on immediately.
+type HoldUntil struct {
+	fb.BaseProcess
+	until   time.Time
+	tag     string
+	embargo time.Duration
+}
+
+// NewHoldUntil returns a new HoldUntil process, releasing all packets at the
+// time until
+func NewHoldUntil(net *fb.Network, name string, until time.Time) *HoldUntil {
+	p := &HoldUntil{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		until:       until,
+	}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// NewHoldUntilTag returns a new HoldUntil process, releasing each packet
+// after the embargo duration embargo has passed since the time in its tag
+// tag, in RFC 3339 format, such as "2024-05-01T09:00:00Z". Packets without a
+// valid time in the tag fail the process.
+func NewHoldUntilTag(net *fb.Network, name string, tag string, embargo time.Duration) *HoldUntil {
+	p := NewHoldUntil(net, name, time.Time{})
+	p.tag = tag
+	p.embargo = embargo
+	return p
+}
+
+// In returns the in-port
+func (p *HoldUntil) In() *fb.InPort { return p.InPort("in") }
+
+// Out returns the out-port
+func (p *HoldUntil) Out() *fb.OutPort { return p.OutPort("out") }
+
+// releaseTime returns the time when the packet ip is to be released
+func (p *HoldUntil) releaseTime(ip *fb.Packet) time.Time {
+	if p.tag == "" {
+		return p.until
+	}
+	t, err := time.Parse(time.RFC3339, ip.Tag(p.tag))
+	if err != nil {
+		ip.Failf("Could not parse release time in tag %s: %v", p.tag, err)
+	}
+	return t.Add(p.embargo)
+}
+
+// Run runs the HoldUntil process
+func (p *HoldUntil) Run() {
+	defer p.CloseOutPorts()
+
+	clock := p.Network().Clock()
+	in := recvChan(p.In())
+	// held is sorted by release time, and by arrival for equal times
+	held := []delayedPacket{}
+	for {
+		now := clock.Now()
+		for len(held) > 0 && !held[0].due.After(now) {
+			p.Out().SendPacket(held[0].ip)
+			held = held[1:]
+		}
+		if in == nil && len(held) == 0 {
+			return
+		}
+		var timer fb.Timer
+		var timerC <-chan time.Time
+		if len(held) > 0 {
+			timer = clock.NewTimer(held[0].due.Sub(now))
+			timerC = timer.C()
+		}
+		select {
+		case ip, ok := <-in:
+			if !ok {
+				in = nil
+				break
+			}
+			due := p.releaseTime(ip)
+			i := sort.Search(len(held), func(i int) bool { return held[i].due.After(due) })
+			held = append(held, delayedPacket{})
+			copy(held[i+1:], held[i:])
+			held[i] = delayedPacket{ip, due}
+		case <-timerC:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// ------------------------------------------------------------------------
+// Watchdog
+// ------------------------------------------------------------------------
+
+// Watchdog passes on the packets it receives, and sends an alert packet on
+// its alerts port if no packet has arrived for the timeout duration, such as
+// to detect dead upstream sources. The alert has the time of the last
+// arrival (or of the start of the process) as data, and is tagged with the
+// duration of the silence as "silence". Only one alert is sent per silence,
+// until the next packet arrives.
+type Watchdog struct {
+	fb.BaseProcess
+	timeout time.Duration
+	// OnAlert is, if set, called with each alert packet, before it is sent
+	OnAlert func(alert *fb.Packet)
+}
+
+// NewWatchdog returns a new Watchdog process, alerting after timeout
+func NewWatchdog(net *fb.Network, name string, timeout time.Duration) *Watchdog {
+	p := &Watchdog{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		timeout:     timeout,
+	}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	p.InitOutPort(p, "alerts")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *Watchdog) In() *fb.InPort { return p.InPort("in") }
+
+// Out returns the out-port, on which the received packets are passed on
+func (p *Watchdog) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Alerts returns the out-port on which the alerts are sent
+func (p *Watchdog) Alerts() *fb.OutPort { return p.OutPort("alerts") }
+
+// Run runs the Watchdog process
+func (p *Watchdog) Run() {
+	defer p.CloseOutPorts()
+
+	clock := p.Network().Clock()
+	in := recvChan(p.In())
+	last := clock.Now()
+	timer := clock.NewTimer(p.timeout)
+	for {
+		select {
+		case ip, ok := <-in:
+			timer.Stop()
+			if !ok {
+				return
+			}
+			last = clock.Now()
+			p.Out().SendPacket(ip)
+			timer = clock.NewTimer(p.timeout)
+		case now := <-timer.C():
+			alert := fb.NewPacket(last)
+			alert.AddTag("silence", now.Sub(last).String())
+			if p.OnAlert != nil {
+				p.OnAlert(alert)
+			}
+			p.Alerts().SendPacket(alert)
+		}
+	}
+}
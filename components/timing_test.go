@@ -0,0 +1,153 @@
+package components
+
+import (
+	"testing"
+	"time"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+func TestDebounceBurst(t *testing.T) {
+	initTestLogs()
+	net := fb.NewNetwork("TestDebounce")
+
+	src := newSliceSource(net, "src", fb.NewPacket(1), fb.NewPacket(2), fb.NewPacket(3))
+	deb := NewDebounce(net, "debounce", time.Second)
+	deb.In().From(src.Out())
+	out := newCollector(net, "out")
+	out.In().From(deb.Out())
+
+	net.Run()
+
+	assertEqualValues(t, []any{3}, out.data())
+}
+
+func TestDelayVirtualClock(t *testing.T) {
+	initTestLogs()
+	net := fb.NewNetwork("TestDelayVirtualClock")
+	clock := fb.NewVirtualClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	net.SetClock(clock)
+
+	src := newSliceSource(net, "src", fb.NewPacket(1), fb.NewPacket(2))
+	delay := NewDelay(net, "delay", time.Hour)
+	delay.In().From(src.Out())
+	out := newCollector(net, "out")
+	out.In().From(delay.Out())
+
+	done := make(chan struct{})
+	go func() {
+		net.Run()
+		close(done)
+	}()
+	clock.BlockUntilWaiters(1)
+	select {
+	case <-done:
+		t.Fatalf("Network finished before the delay had passed")
+	default:
+	}
+	clock.Advance(time.Hour)
+	<-done
+
+	assertEqualValues(t, []any{1, 2}, out.data())
+}
+
+func TestHoldUntil(t *testing.T) {
+	initTestLogs()
+	net := fb.NewNetwork("TestHoldUntil")
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := fb.NewVirtualClock(start)
+	net.SetClock(clock)
+
+	src := newSliceSource(net, "src", fb.NewPacket(1), fb.NewPacket(2))
+	hold := NewHoldUntil(net, "hold", start.Add(time.Hour))
+	hold.In().From(src.Out())
+	out := newCollector(net, "out")
+	out.In().From(hold.Out())
+
+	done := make(chan struct{})
+	go func() {
+		net.Run()
+		close(done)
+	}()
+	clock.BlockUntilWaiters(1)
+	select {
+	case <-done:
+		t.Fatalf("Network finished before the release time")
+	default:
+	}
+	clock.Advance(time.Hour)
+	<-done
+
+	assertEqualValues(t, []any{1, 2}, out.data())
+}
+
+func TestHoldUntilTag(t *testing.T) {
+	initTestLogs()
+	net := fb.NewNetwork("TestHoldUntilTag")
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := fb.NewVirtualClock(start)
+	net.SetClock(clock)
+
+	a := fb.NewPacket("a")
+	a.AddTag("published", start.Add(time.Hour).Format(time.RFC3339))
+	b := fb.NewPacket("b")
+	b.AddTag("published", start.Format(time.RFC3339))
+	src := newSliceSource(net, "src", a, b)
+	hold := NewHoldUntilTag(net, "hold", "published", time.Hour)
+	hold.In().From(src.Out())
+	out := newCollector(net, "out")
+	out.In().From(hold.Out())
+
+	done := make(chan struct{})
+	go func() {
+		net.Run()
+		close(done)
+	}()
+	clock.BlockUntilWaiters(1)
+	clock.Advance(time.Hour)
+	clock.BlockUntilWaiters(1)
+	clock.Advance(time.Hour)
+	<-done
+
+	assertEqualValues(t, []any{"b", "a"}, out.data())
+}
+
+func TestWatchdog(t *testing.T) {
+	initTestLogs()
+	net := fb.NewNetwork("TestWatchdog")
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := fb.NewVirtualClock(start)
+	net.SetClock(clock)
+
+	ticker := NewTicker(net, "ticker", 10*time.Minute)
+	ticker.MaxTicks = 2
+	watchdog := NewWatchdog(net, "watchdog", 5*time.Minute)
+	alerted := make(chan struct{}, 2)
+	watchdog.OnAlert = func(alert *fb.Packet) { alerted <- struct{}{} }
+	watchdog.In().From(ticker.Out())
+	out := newCollector(net, "out")
+	out.In().From(watchdog.Out())
+	alerts := newCollector(net, "alerts")
+	alerts.In().From(watchdog.Alerts())
+
+	done := make(chan struct{})
+	go func() {
+		net.Run()
+		close(done)
+	}()
+	clock.BlockUntilWaiters(2)
+	clock.Advance(5 * time.Minute)
+	<-alerted
+	clock.Advance(5 * time.Minute)
+	clock.BlockUntilWaiters(2)
+	clock.Advance(5 * time.Minute)
+	<-alerted
+	clock.Advance(5 * time.Minute)
+	<-done
+
+	assertEqualValues(t, []any{start.Add(10 * time.Minute), start.Add(20 * time.Minute)}, out.data())
+	assertEqualValues(t, []any{start, start.Add(10 * time.Minute)}, alerts.data())
+	if silence := alerts.ips[1].Tag("silence"); silence != "5m0s" {
+		t.Errorf("Wrong silence tag: %s", silence)
+	}
+}
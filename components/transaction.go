@@ -0,0 +1,143 @@
+package components
+
+import (
+	"fmt"
+	"sync"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// TxGroup makes a group of sinks (see fb.TxSink) take the side effects of the
+// packets of each substream, as identified by the value of a tag, either all
+// or none of them. The packets for each sink are received on an in-port of
+// its own (see AddSink), and staged in a transaction of the sink per
+// substream. When the input streams end, the transactions of each substream
+// are committed together, with fb.CommitAll, unless staging a packet of the
+// substream failed, or it was aborted with a packet on the Abort port, in
+// which case they are all rolled back. The outcome of each substream is sent
+// on the out-port, with its key as data, tagged with the tag, and with
+// "committed" or "rolled-back" as "tx".
+type TxGroup struct {
+	fb.BaseProcess
+	tag       string
+	sinks     map[string]fb.TxSink
+	sinkPorts []string
+}
+
+// NewTxGroup returns a new TxGroup process, with substreams identified by the
+// tag tag
+func NewTxGroup(net *fb.Network, name string, tag string) *TxGroup {
+	p := &TxGroup{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		tag:         tag,
+		sinks:       map[string]fb.TxSink{},
+	}
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// AddSink adds the sink sink to the group, and returns the in-port, named
+// portName, on which the packets for it are received
+func (p *TxGroup) AddSink(portName string, sink fb.TxSink) *fb.InPort {
+	p.InitInPort(p, portName)
+	p.sinks[portName] = sink
+	p.sinkPorts = append(p.sinkPorts, portName)
+	return p.InPort(portName)
+}
+
+// Abort returns the in-port on which a packet tagged with the key of a
+// substream makes its transactions be rolled back, such as from the errors
+// port of an upstream process. The port only exists once Abort is called.
+func (p *TxGroup) Abort() *fb.InPort {
+	if _, ok := p.InPorts()["abort"]; !ok {
+		p.InitInPort(p, "abort")
+	}
+	return p.InPort("abort")
+}
+
+// Out returns the out-port, on which the outcomes of the substreams are sent
+func (p *TxGroup) Out() *fb.OutPort { return p.OutPort("out") }
+
+// Run runs the TxGroup process
+func (p *TxGroup) Run() {
+	defer p.CloseOutPorts()
+
+	type portPacket struct {
+		port string
+		ip   *fb.Packet
+	}
+	received := make(chan portPacket)
+	wg := &sync.WaitGroup{}
+	for portName, pt := range p.InPorts() {
+		wg.Add(1)
+		go func(portName string, pt *fb.InPort) {
+			defer wg.Done()
+			for ip := range recvChan(pt) {
+				received <- portPacket{portName, ip}
+			}
+		}(portName, pt)
+	}
+	go func() {
+		wg.Wait()
+		close(received)
+	}()
+
+	keys := []string{}
+	txs := map[string]map[string]fb.Tx{}
+	failures := map[string]error{}
+	for pp := range received {
+		key := pp.ip.Tag(p.tag)
+		if _, ok := txs[key]; !ok {
+			keys = append(keys, key)
+			txs[key] = map[string]fb.Tx{}
+		}
+		if failures[key] != nil {
+			continue
+		}
+		if pp.port == "abort" {
+			failures[key] = fmt.Errorf("aborted by packet %s", pp.ip.ID())
+			continue
+		}
+		tx, ok := txs[key][pp.port]
+		if !ok {
+			var err error
+			if tx, err = p.sinks[pp.port].Begin(key); err != nil {
+				failures[key] = fmt.Errorf("could not begin transaction of sink %s: %v", pp.port, err)
+				continue
+			}
+			txs[key][pp.port] = tx
+		}
+		if err := tx.Stage(pp.ip); err != nil {
+			failures[key] = fmt.Errorf("could not stage packet %s in sink %s: %v", pp.ip.ID(), pp.port, err)
+		}
+	}
+
+	for _, key := range keys {
+		group := []fb.Tx{}
+		for _, portName := range p.sinkPorts {
+			if tx, ok := txs[key][portName]; ok {
+				group = append(group, tx)
+			}
+		}
+		err := failures[key]
+		if err != nil {
+			if rbErr := fb.RollbackAll(group...); rbErr != nil {
+				err = fmt.Errorf("%v\n%v", err, rbErr)
+			}
+		} else {
+			err = fb.CommitAll(group...)
+		}
+		outcome := fb.NewPacket(key)
+		outcome.AddTag(p.tag, key)
+		if err != nil {
+			fb.Warning.Printf("[Process:%s] Transactions for %s=%s were not committed: %v\n", p.Name(), p.tag, key, err)
+			outcome.AddTag("tx", "rolled-back")
+			outcome.AddTag("error", err.Error())
+		} else {
+			p.Auditf("Committed transactions for %s=%s", p.tag, key)
+			outcome.AddTag("tx", "committed")
+		}
+		p.Out().SendPacket(outcome)
+	}
+}
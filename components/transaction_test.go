@@ -0,0 +1,95 @@
+package components
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+func TestTxGroup(t *testing.T) {
+	initTestLogs()
+	net := fb.NewNetwork("TestTxGroup")
+
+	db := newMemTxSink()
+	http := newMemTxSink()
+	dbSrc := newSliceSource(net, "dbsrc",
+		taggedPacket("a1", map[string]string{"batch": "a"}),
+		taggedPacket("b1", map[string]string{"batch": "b"}),
+		taggedPacket("c1", map[string]string{"batch": "c"}),
+		taggedPacket("a2", map[string]string{"batch": "a"}))
+	httpSrc := newSliceSource(net, "httpsrc",
+		taggedPacket("a3", map[string]string{"batch": "a"}),
+		taggedPacket("bad", map[string]string{"batch": "b"}))
+	abortSrc := newSliceSource(net, "abortsrc",
+		taggedPacket("error", map[string]string{"batch": "c"}))
+
+	group := NewTxGroup(net, "tx", "batch")
+	group.AddSink("db", db).From(dbSrc.Out())
+	group.AddSink("http", http).From(httpSrc.Out())
+	group.Abort().From(abortSrc.Out())
+	out := newCollector(net, "out")
+	out.In().From(group.Out())
+
+	net.Run()
+
+	assertEqualValues(t, map[string][]any{"a": {"a1", "a2"}}, db.committed)
+	assertEqualValues(t, map[string][]any{"a": {"a3"}}, http.committed)
+	// Whether the db transactions of b and c were begun depends on the order
+	// packets arrive in, but the failed http one always was
+	if http.rolledBack != 1 {
+		t.Errorf("Wrong number of rolled back transactions: %d", http.rolledBack)
+	}
+	outcomes := map[string]string{}
+	for _, ip := range out.ips {
+		outcomes[ip.Tag("batch")] = ip.Tag("tx")
+	}
+	assertEqualValues(t, map[string]string{"a": "committed", "b": "rolled-back", "c": "rolled-back"}, outcomes)
+}
+
+// memTxSink is a TxSink collecting the data of committed packets, per key.
+// Staging packets with the data "bad" fails.
+type memTxSink struct {
+	mx         sync.Mutex
+	committed  map[string][]any
+	rolledBack int
+}
+
+func newMemTxSink() *memTxSink {
+	return &memTxSink{committed: map[string][]any{}}
+}
+
+func (s *memTxSink) Begin(key string) (fb.Tx, error) {
+	return &memTx{sink: s, key: key}, nil
+}
+
+type memTx struct {
+	sink   *memTxSink
+	key    string
+	staged []any
+}
+
+func (tx *memTx) Stage(ip *fb.Packet) error {
+	if ip.Data() == "bad" {
+		return errors.New("bad packet")
+	}
+	tx.staged = append(tx.staged, ip.Data())
+	return nil
+}
+
+func (tx *memTx) Prepare() error { return nil }
+
+func (tx *memTx) Commit() error {
+	tx.sink.mx.Lock()
+	defer tx.sink.mx.Unlock()
+	tx.sink.committed[tx.key] = append(tx.sink.committed[tx.key], tx.staged...)
+	return nil
+}
+
+func (tx *memTx) Rollback() error {
+	tx.sink.mx.Lock()
+	defer tx.sink.mx.Unlock()
+	tx.sink.rolledBack++
+	return nil
+}
@@ -0,0 +1,169 @@
+package flowbase
+
+import (
+	"strings"
+)
+
+// ProcNameSeparator separates the levels of hierarchical process names, such
+// as "subnet/worker/stage1", which are created when subnetworks are added to
+// networks
+const ProcNameSeparator = "/"
+
+// ProcPath joins the parts of a hierarchical process name
+func ProcPath(parts ...string) string {
+	return strings.Join(parts, ProcNameSeparator)
+}
+
+// ProcNamespace returns the namespace part of the hierarchical process name
+// name, that is, everything before the last separator, or "" if there is none
+func ProcNamespace(name string) string {
+	i := strings.LastIndex(name, ProcNameSeparator)
+	if i < 0 {
+		return ""
+	}
+	return name[:i]
+}
+
+// networkMember is implemented by processes embedding BaseProcess, whose name
+// and network can be changed when networks are merged
+type networkMember interface {
+	setName(name string)
+	setNetwork(net *Network)
+}
+
+func (p *BaseProcess) setName(name string) {
+	p.name = name
+	if p.record != nil {
+		p.record.name = name
+	}
+}
+
+func (p *BaseProcess) setNetwork(net *Network) {
+	p.workflow = net
+}
+
+// ExportInPort makes the in-port pt available under the name name, for
+// wiring the network together with other networks (see ComposeNetworks)
+func (net *Network) ExportInPort(name string, pt *InPort) {
+	if net.exportedInPorts == nil {
+		net.exportedInPorts = make(map[string]*InPort)
+	}
+	net.exportedInPorts[name] = pt
+}
+
+// ExportOutPort makes the out-port pt available under the name name, for
+// wiring the network together with other networks (see ComposeNetworks)
+func (net *Network) ExportOutPort(name string, pt *OutPort) {
+	if net.exportedOutPorts == nil {
+		net.exportedOutPorts = make(map[string]*OutPort)
+	}
+	net.exportedOutPorts[name] = pt
+}
+
+// ExportedInPort returns the in-port exported under the name name
+func (net *Network) ExportedInPort(name string) *InPort {
+	pt, ok := net.exportedInPorts[name]
+	if !ok {
+		net.Failf("No exported in-port named (%s)", name)
+	}
+	return pt
+}
+
+// ExportedOutPort returns the out-port exported under the name name
+func (net *Network) ExportedOutPort(name string) *OutPort {
+	pt, ok := net.exportedOutPorts[name]
+	if !ok {
+		net.Failf("No exported out-port named (%s)", name)
+	}
+	return pt
+}
+
+// Merge moves all processes, and exported ports, of the network other into
+// the network. Processes whose names collide with existing ones are renamed,
+// by prefixing them with the name of other, as in "other/proc". The network
+// other should not be used after the merge.
+func (net *Network) Merge(other *Network) {
+	net.merge(other, false)
+}
+
+// AddSubNetwork moves all processes, and exported ports, of the network sub
+// into the network, like Merge, but prefixes the names of all of them with
+// the name of sub, as in "sub/proc", so that they form a namespace. Nested
+// subnetworks get nested namespaces, such as "subnet/worker/stage1".
+func (net *Network) AddSubNetwork(sub *Network) {
+	net.merge(sub, true)
+}
+
+// merge moves all processes and exported ports of other into the network,
+// prefixing their names with the name of other if prefixAll is true, and
+// otherwise only when they collide with existing names
+func (net *Network) merge(other *Network, prefixAll bool) {
+	for _, node := range other.ProcsSorted() {
+		name := node.Name()
+		if _, exists := net.procs[name]; exists || prefixAll {
+			newName := ProcPath(other.Name(), name)
+			net.renameProc(node, newName)
+			Debug.Printf("[Network:%s] Renamed process (%s) from network (%s) to (%s) when merging", net.Name(), name, other.Name(), newName)
+		}
+		if m, ok := node.(networkMember); ok {
+			m.setNetwork(net)
+		}
+		net.AddProc(node)
+	}
+	other.procsMx.Lock()
+	other.procs = map[string]Node{}
+	other.procsMx.Unlock()
+
+	for name, pt := range other.exportedInPorts {
+		if _, exists := net.exportedInPorts[name]; exists || prefixAll {
+			name = ProcPath(other.Name(), name)
+		}
+		net.ExportInPort(name, pt)
+	}
+	for name, pt := range other.exportedOutPorts {
+		if _, exists := net.exportedOutPorts[name]; exists || prefixAll {
+			name = ProcPath(other.Name(), name)
+		}
+		net.ExportOutPort(name, pt)
+	}
+}
+
+// renameProc gives the process node the name newName, updating the keys its
+// ports are known by in the ports they are connected to
+func (net *Network) renameProc(node Node, newName string) {
+	m, ok := node.(networkMember)
+	if !ok {
+		net.Failf("Can not rename process (%s), as it does not embed BaseProcess", node.Name())
+	}
+	oldInNames := map[*InPort]string{}
+	for _, pt := range node.InPorts() {
+		oldInNames[pt] = pt.FullName()
+	}
+	oldOutNames := map[*OutPort]string{}
+	for _, pt := range node.OutPorts() {
+		oldOutNames[pt] = pt.FullName()
+	}
+	m.setName(newName)
+	for pt, oldName := range oldInNames {
+		for _, rpt := range pt.ConnectedPorts() {
+			rpt.rekeyRemotePort(oldName, pt.FullName())
+		}
+	}
+	for pt, oldName := range oldOutNames {
+		for _, rpt := range pt.ConnectedPorts() {
+			rpt.rekeyRemotePort(oldName, pt.FullName())
+		}
+	}
+}
+
+// ComposeNetworks merges the network b into the network a (see Merge), and
+// connects their exported ports according to bindings, which maps names of
+// exported out-ports to names of exported in-ports, in either network. The
+// network a is returned.
+func ComposeNetworks(a *Network, b *Network, bindings map[string]string) *Network {
+	a.Merge(b)
+	for outName, inName := range bindings {
+		a.ExportedInPort(inName).From(a.ExportedOutPort(outName))
+	}
+	return a
+}
@@ -0,0 +1,47 @@
+package flowbase
+
+import (
+	"testing"
+)
+
+func TestComposeNetworks(t *testing.T) {
+	initTestLogs()
+
+	a := NewNetwork("a")
+	src := NewFileSource(a, "proc", "x.txt", "y.txt")
+	a.ExportOutPort("files", src.Out())
+
+	b := NewNetwork("b")
+	out := NewPacketCollector(b, "proc")
+	b.ExportInPort("files", out.In())
+
+	net := ComposeNetworks(a, b, map[string]string{"files": "files"})
+	net.Run()
+
+	assertEqualValues(t, []any{"x.txt", "y.txt"}, out.Data)
+	assertEqualValues(t, "b/proc", out.Name())
+	assertEqualValues(t, a, out.Network())
+	assertEqualValues(t, 0, len(b.Procs()))
+}
+
+func TestAddSubNetwork(t *testing.T) {
+	initTestLogs()
+
+	stage := NewNetwork("stage1")
+	src := NewFileSource(stage, "src", "x.txt")
+	stage.ExportOutPort("out", src.Out())
+
+	worker := NewNetwork("worker")
+	worker.AddSubNetwork(stage)
+
+	net := NewNetwork("subnet")
+	net.AddSubNetwork(worker)
+	out := NewPacketCollector(net, "out")
+	out.In().From(net.ExportedOutPort("worker/stage1/out"))
+
+	net.Run()
+
+	assertEqualValues(t, "worker/stage1/src", src.Name())
+	assertEqualValues(t, "worker/stage1", ProcNamespace(src.Name()))
+	assertEqualValues(t, []any{"x.txt"}, out.Data)
+}
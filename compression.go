@@ -0,0 +1,149 @@
+package flowbase
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Compression is a compression format for the content of FileIPs
+type Compression string
+
+const (
+	// CompressionAuto detects the compression format from the file extension
+	// (.gz for gzip, .zst for zstd)
+	CompressionAuto Compression = ""
+	// CompressionNone reads and writes files as is
+	CompressionNone Compression = "none"
+	// CompressionGzip reads and writes gzip compressed files
+	CompressionGzip Compression = "gzip"
+	// CompressionZstd reads and writes zstd compressed files, using the zstd
+	// command line tool, which needs to be installed
+	CompressionZstd Compression = "zstd"
+)
+
+// detectCompression returns the compression format of the file at path,
+// based on its extension
+func detectCompression(path string) Compression {
+	switch {
+	case strings.HasSuffix(path, ".gz"):
+		return CompressionGzip
+	case strings.HasSuffix(path, ".zst"):
+		return CompressionZstd
+	}
+	return CompressionNone
+}
+
+// decompressReader returns a reader of the decompressed content of file
+func decompressReader(file *os.File, compression Compression) (io.ReadCloser, error) {
+	switch compression {
+	case CompressionGzip:
+		gzr, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return &closeBoth{ReadCloser: gzr, file: file}, nil
+	case CompressionZstd:
+		cmd := exec.Command("zstd", "-q", "-d", "-c")
+		cmd.Stdin = file
+		out, err := cmd.StdoutPipe()
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			file.Close()
+			return nil, errWrap(err, "Could not start zstd")
+		}
+		return &cmdReadCloser{ReadCloser: out, cmd: cmd, file: file}, nil
+	}
+	return file, nil
+}
+
+// compressWriter returns a writer compressing content into file
+func compressWriter(file *os.File, compression Compression) (io.WriteCloser, error) {
+	switch compression {
+	case CompressionGzip:
+		return &closeBothWriter{WriteCloser: gzip.NewWriter(file), file: file}, nil
+	case CompressionZstd:
+		cmd := exec.Command("zstd", "-q", "-c")
+		cmd.Stdout = file
+		in, err := cmd.StdinPipe()
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			file.Close()
+			return nil, errWrap(err, "Could not start zstd")
+		}
+		return &cmdWriteCloser{WriteCloser: in, cmd: cmd, file: file}, nil
+	}
+	return file, nil
+}
+
+// closeBoth closes both a decompressing reader and its underlying file
+type closeBoth struct {
+	io.ReadCloser
+	file *os.File
+}
+
+func (c *closeBoth) Close() error {
+	err := c.ReadCloser.Close()
+	if ferr := c.file.Close(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// closeBothWriter closes both a compressing writer and its underlying file
+type closeBothWriter struct {
+	io.WriteCloser
+	file *os.File
+}
+
+func (c *closeBothWriter) Close() error {
+	err := c.WriteCloser.Close()
+	if ferr := c.file.Close(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// cmdReadCloser reads the output of a decompressing command
+type cmdReadCloser struct {
+	io.ReadCloser
+	cmd  *exec.Cmd
+	file *os.File
+}
+
+func (c *cmdReadCloser) Close() error {
+	// Drain the output, so that the command does not block on writing it
+	io.Copy(io.Discard, c.ReadCloser)
+	err := c.cmd.Wait()
+	if ferr := c.file.Close(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// cmdWriteCloser writes to the input of a compressing command
+type cmdWriteCloser struct {
+	io.WriteCloser
+	cmd  *exec.Cmd
+	file *os.File
+}
+
+func (c *cmdWriteCloser) Close() error {
+	err := c.WriteCloser.Close()
+	if werr := c.cmd.Wait(); err == nil {
+		err = werr
+	}
+	if ferr := c.file.Close(); err == nil {
+		err = ferr
+	}
+	return err
+}
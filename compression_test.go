@@ -0,0 +1,26 @@
+package flowbase
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestFileIPCompression(t *testing.T) {
+	dir := t.TempDir()
+	for _, fileName := range []string{"plain.txt", "gzipped.txt.gz", "zstded.txt.zst"} {
+		if fileName == "zstded.txt.zst" {
+			if _, err := exec.LookPath("zstd"); err != nil {
+				continue
+			}
+		}
+		f := NewFileIP(dir + "/" + fileName)
+		f.Write([]byte("some content"))
+		assertEqualValues(t, "some content", string(f.Read()), fileName)
+
+		raw := NewFileIP(f.Path())
+		raw.SetCompression(CompressionNone)
+		if f.Compression() != CompressionNone && string(raw.Read()) == "some content" {
+			t.Errorf("Content of %s was not compressed", fileName)
+		}
+	}
+}
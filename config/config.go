@@ -0,0 +1,237 @@
+// Package config binds the settings of FlowBase networks, and the parameters
+// of their processes, from a layered configuration, where later layers
+// override earlier ones: defaults < configuration file < environment
+// variables < command line flags.
+//
+// Keys are dot-separated and case-insensitive. The key "bufsize" sets the
+// channel buffer size of ports, and keys on the form "<process>.<param>"
+// set the parameter param of the process named process, for processes
+// with a SetParam method, such as ExecProc. Parameter names keep the case
+// they were given with in files and flags, as parameters are
+// case-sensitive.
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// EnvPrefix is the prefix of environment variables read by LoadEnv. The
+// rest of the variable name is lower-cased, with double underscores
+// replaced by dots, to form the key, so that FLOWBASE_ALIGN__THREADS sets
+// the key "align.threads".
+const EnvPrefix = "FLOWBASE_"
+
+// ParamSetter is implemented by processes whose parameters can be set from
+// the configuration
+type ParamSetter interface {
+	SetParam(name string, value string)
+}
+
+// Config is a layered configuration of string values, keyed by
+// dot-separated keys
+type Config struct {
+	values  map[string]string
+	sources map[string]string
+	// names are the keys as last given, by lower-cased key
+	names map[string]string
+
+	configFile string
+	flagValues []string
+}
+
+// New returns a new, empty Config
+func New() *Config {
+	return &Config{
+		values:  make(map[string]string),
+		sources: make(map[string]string),
+		names:   make(map[string]string),
+	}
+}
+
+// set sets the value of key, recording which layer it came from
+func (c *Config) set(key string, value string, source string) {
+	name := key
+	key = strings.ToLower(key)
+	c.values[key] = value
+	c.sources[key] = source
+	c.names[key] = name
+}
+
+// SetDefault sets the default value of key, which is overridden by all
+// other layers, so it should be called before loading them
+func (c *Config) SetDefault(key string, value string) {
+	if _, ok := c.values[strings.ToLower(key)]; !ok {
+		c.set(key, value, "default")
+	}
+}
+
+// Set sets the value of key, overriding any previous value
+func (c *Config) Set(key string, value string) {
+	c.set(key, value, "set")
+}
+
+// Get returns the value of key, and whether it is set
+func (c *Config) Get(key string) (string, bool) {
+	value, ok := c.values[strings.ToLower(key)]
+	return value, ok
+}
+
+// String returns the value of key, or def if it is not set
+func (c *Config) String(key string, def string) string {
+	if value, ok := c.Get(key); ok {
+		return value
+	}
+	return def
+}
+
+// Int returns the value of key as an integer, or def if it is not set. It
+// fails if the value is not an integer.
+func (c *Config) Int(key string, def int) int {
+	value, ok := c.Get(key)
+	if !ok {
+		return def
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		fb.Failf("Config value of %s (from %s) is not an integer: %s", key, c.Source(key), value)
+	}
+	return i
+}
+
+// Bool returns the value of key as a boolean, or def if it is not set. It
+// fails if the value is not a boolean.
+func (c *Config) Bool(key string, def bool) bool {
+	value, ok := c.Get(key)
+	if !ok {
+		return def
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		fb.Failf("Config value of %s (from %s) is not a boolean: %s", key, c.Source(key), value)
+	}
+	return b
+}
+
+// Source returns the layer the value of key came from, such as "default",
+// "env", "flag" or the path of a configuration file
+func (c *Config) Source(key string) string {
+	return c.sources[strings.ToLower(key)]
+}
+
+// Keys returns all the set keys, in lexical order
+func (c *Config) Keys() []string {
+	keys := []string{}
+	for key := range c.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// LoadFile loads the configuration file at path, whose format is detected
+// from its extension: .json, .toml, or .yaml/.yml. Nested tables and maps are
+// flattened into dot-separated keys. Only the commonly used subsets of TOML
+// and YAML are supported: tables, maps and scalar values, but not arrays.
+func (c *Config) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read config file %s: %w", path, err)
+	}
+	var values map[string]string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		values, err = parseJSON(data)
+	case ".toml":
+		values, err = parseTOML(data)
+	case ".yaml", ".yml":
+		values, err = parseYAML(data)
+	default:
+		return fmt.Errorf("unsupported config file format: %s", path)
+	}
+	if err != nil {
+		return fmt.Errorf("could not parse config file %s: %w", path, err)
+	}
+	for key, value := range values {
+		c.set(key, value, path)
+	}
+	return nil
+}
+
+// LoadEnv loads the values of all environment variables with EnvPrefix
+func (c *Config) LoadEnv() {
+	for _, env := range os.Environ() {
+		name, value, ok := strings.Cut(env, "=")
+		if !ok || !strings.HasPrefix(name, EnvPrefix) {
+			continue
+		}
+		key := strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(name, EnvPrefix), "__", "."))
+		c.set(key, value, "env")
+	}
+}
+
+// RegisterFlags adds the flags -config, for the path of a configuration file,
+// and -set, for setting values on the form key=value (repeatable), to fs
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.configFile, "config", "", "Path of a configuration file (.json, .toml or .yaml)")
+	fs.Func("set", "Set a configuration value, on the form key=value (repeatable)", func(s string) error {
+		if !strings.Contains(s, "=") {
+			return fmt.Errorf("expected key=value, got %s", s)
+		}
+		c.flagValues = append(c.flagValues, s)
+		return nil
+	})
+}
+
+// Load loads all layers, after defaults have been set and the flags
+// registered with RegisterFlags have been parsed: first the configuration
+// file given with -config (if any), then the environment, and last values
+// given with -set
+func (c *Config) Load() error {
+	if c.configFile != "" {
+		if err := c.LoadFile(c.configFile); err != nil {
+			return err
+		}
+	}
+	c.LoadEnv()
+	for _, kv := range c.flagValues {
+		key, value, _ := strings.Cut(kv, "=")
+		c.set(strings.TrimSpace(key), strings.TrimSpace(value), "flag")
+	}
+	return nil
+}
+
+// ApplySettings applies the global FlowBase settings in the configuration,
+// which currently is "bufsize". It needs to be called before any processes
+// are created, as ports get their buffer size when created.
+func (c *Config) ApplySettings() {
+	fb.BUFSIZE = c.Int("bufsize", fb.BUFSIZE)
+}
+
+// Apply injects the values with keys on the form "<process>.<param>" as
+// parameters into the processes of net, which implement ParamSetter
+func (c *Config) Apply(net *fb.Network) {
+	for _, key := range c.Keys() {
+		procName, param, ok := strings.Cut(c.names[key], ".")
+		if !ok {
+			continue
+		}
+		for name, node := range net.Procs() {
+			if !strings.EqualFold(name, procName) {
+				continue
+			}
+			ps, ok := node.(ParamSetter)
+			if !ok {
+				fb.Failf("Config sets %s, but process %s does not take parameters", key, name)
+			}
+			ps.SetParam(param, c.values[key])
+		}
+	}
+}
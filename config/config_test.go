@@ -0,0 +1,147 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+func TestConfigLayers(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	err := os.WriteFile(cfgPath, []byte(`
+bufsize: 64
+align:
+  threads: 4 # from file
+  ref: "hg38"
+  mode: fast
+`), 0644)
+	fb.Check(err)
+	t.Setenv("FLOWBASE_ALIGN__THREADS", "8")
+
+	c := New()
+	c.SetDefault("align.mode", "slow")
+	c.SetDefault("align.extra", "x")
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	c.RegisterFlags(fs)
+	fb.Check(fs.Parse([]string{"-config", cfgPath, "-set", "align.ref=hg19"}))
+	fb.Check(c.Load())
+
+	for key, expected := range map[string]string{
+		"align.extra":   "x",
+		"align.mode":    "fast",
+		"align.threads": "8",
+		"align.ref":     "hg19",
+	} {
+		if value := c.String(key, ""); value != expected {
+			t.Errorf("Wrong value of %s (from %s): %s, expected %s", key, c.Source(key), value, expected)
+		}
+	}
+	if c.Int("bufsize", 0) != 64 {
+		t.Errorf("Wrong bufsize: %d", c.Int("bufsize", 0))
+	}
+}
+
+func TestConfigTOML(t *testing.T) {
+	values, err := parseTOML([]byte(`
+name = "net" # comment
+[align]
+threads = 4
+ref = 'hg#38'
+`))
+	fb.Check(err)
+	expected := map[string]string{"name": "net", "align.threads": "4", "align.ref": "hg#38"}
+	for key, value := range expected {
+		if values[key] != value {
+			t.Errorf("Wrong value of %s: %s, expected %s", key, values[key], value)
+		}
+	}
+}
+
+func TestConfigApply(t *testing.T) {
+	net := fb.NewNetwork("TestConfigApply")
+	proc := fb.NewExecProc(net, "align", "echo {p:threads} {p:numReads} {p:minQual}")
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+	fb.Check(os.WriteFile(cfgPath, []byte("Align:\n  minQual: 20\n"), 0644))
+	c := New()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	c.RegisterFlags(fs)
+	fb.Check(fs.Parse([]string{"-config", cfgPath, "-set", "align.numReads=100"}))
+	fb.Check(c.Load())
+	c.Set("align.threads", "4")
+	c.Apply(net)
+
+	// Parameter names keep their case, while process names do not need to
+	for name, expected := range map[string]string{"threads": "4", "numReads": "100", "minQual": "20"} {
+		if proc.Param(name) != expected {
+			t.Errorf("Parameter %s was not injected: %s", name, proc.Param(name))
+		}
+	}
+}
+
+func TestConfigApplyEnv(t *testing.T) {
+	net := fb.NewNetwork("TestConfigApplyEnv")
+	proc := fb.NewExecProc(net, "proc", "echo {p:param}")
+	t.Setenv("FLOWBASE_PROC__PARAM", "42")
+	c := New()
+	c.LoadEnv()
+	c.Apply(net)
+
+	// Environment variable names are lower-cased to form the key
+	if proc.Param("param") != "42" {
+		t.Errorf("Parameter param was not injected: %s", proc.Param("param"))
+	}
+}
+
+func TestConfigApplySettings(t *testing.T) {
+	defer func(bufsize int) { fb.BUFSIZE = bufsize }(fb.BUFSIZE)
+	cfgPath := filepath.Join(t.TempDir(), "config.json")
+	fb.Check(os.WriteFile(cfgPath, []byte(`{"bufsize": 64}`), 0644))
+	t.Setenv("FLOWBASE_BUFSIZE", "32")
+
+	for _, tc := range []struct {
+		args     []string
+		expected int
+	}{
+		{[]string{"-config", cfgPath}, 32},
+		{[]string{"-config", cfgPath, "-set", "bufsize=16"}, 16},
+	} {
+		c := New()
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		c.RegisterFlags(fs)
+		fb.Check(fs.Parse(tc.args))
+		fb.Check(c.Load())
+		c.ApplySettings()
+		if fb.BUFSIZE != tc.expected {
+			t.Errorf("Wrong BUFSIZE with %v: %d, expected %d", tc.args, fb.BUFSIZE, tc.expected)
+		}
+		net := fb.NewNetwork("TestConfigApplySettings")
+		proc := fb.NewExecProc(net, "cat", "cat {i:in} > {o:out}")
+		if capacity := proc.In("in").Capacity(); capacity != tc.expected {
+			t.Errorf("Wrong port capacity with %v: %d, expected %d", tc.args, capacity, tc.expected)
+		}
+	}
+}
+
+func TestConfigApplySettingsEnv(t *testing.T) {
+	defer func(bufsize int) { fb.BUFSIZE = bufsize }(fb.BUFSIZE)
+	t.Setenv("FLOWBASE_BUFSIZE", "1234")
+
+	// FLOWBASE_BUFSIZE only takes effect through the configuration
+	net := fb.NewNetwork("TestConfigApplySettingsEnv")
+	if capacity := fb.NewExecProc(net, "before", "cat {i:in} > {o:out}").In("in").Capacity(); capacity == 1234 {
+		t.Errorf("FLOWBASE_BUFSIZE took effect without the configuration")
+	}
+	c := New()
+	c.LoadEnv()
+	c.ApplySettings()
+	if fb.BUFSIZE != 1234 {
+		t.Errorf("Wrong BUFSIZE from FLOWBASE_BUFSIZE: %d, expected 1234", fb.BUFSIZE)
+	}
+	if capacity := fb.NewExecProc(net, "after", "cat {i:in} > {o:out}").In("in").Capacity(); capacity != 1234 {
+		t.Errorf("Wrong port capacity from FLOWBASE_BUFSIZE: %d, expected 1234", capacity)
+	}
+}
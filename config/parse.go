@@ -0,0 +1,151 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseJSON parses a JSON object into flattened key-values
+func parseJSON(data []byte) (map[string]string, error) {
+	obj := map[string]any{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, err
+	}
+	values := map[string]string{}
+	flatten("", obj, values)
+	return values, nil
+}
+
+// flatten adds the values of the nested map obj to values, with keys
+// prefixed by prefix
+func flatten(prefix string, obj map[string]any, values map[string]string) {
+	for k, v := range obj {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		switch val := v.(type) {
+		case map[string]any:
+			flatten(key, val, values)
+		case string:
+			values[key] = val
+		default:
+			values[key] = fmt.Sprint(val)
+		}
+	}
+}
+
+// parseTOML parses the subset of TOML consisting of [table] headers and
+// key = value pairs with scalar values
+func parseTOML(data []byte) (map[string]string, error) {
+	values := map[string]string{}
+	table := ""
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(stripComment(line))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			table = strings.TrimSpace(strings.Trim(line, "[]"))
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value: %s", i+1, line)
+		}
+		key = strings.Trim(strings.TrimSpace(key), `"`)
+		if table != "" {
+			key = table + "." + key
+		}
+		val, err := parseScalar(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		values[key] = val
+	}
+	return values, nil
+}
+
+// parseYAML parses the subset of YAML consisting of nested maps, indented
+// with spaces, with scalar values
+func parseYAML(data []byte) (map[string]string, error) {
+	values := map[string]string{}
+	type level struct {
+		indent int
+		key    string
+	}
+	parents := []level{}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(stripComment(line), " \t\r")
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "- ") || strings.HasPrefix(line, "\t") {
+			return nil, fmt.Errorf("line %d: only maps indented with spaces are supported: %s", i+1, line)
+		}
+		indent := len(line) - len(trimmed)
+		for len(parents) > 0 && parents[len(parents)-1].indent >= indent {
+			parents = parents[:len(parents)-1]
+		}
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key: value: %s", i+1, line)
+		}
+		key = strings.Trim(strings.TrimSpace(key), `"'`)
+		if len(parents) > 0 {
+			key = parents[len(parents)-1].key + "." + key
+		}
+		value = strings.TrimSpace(value)
+		if value == "" {
+			parents = append(parents, level{indent, key})
+			continue
+		}
+		val, err := parseScalar(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		values[key] = val
+	}
+	return values, nil
+}
+
+// parseScalar returns the string form of a scalar TOML or YAML value,
+// removing quotes from quoted strings
+func parseScalar(value string) (string, error) {
+	if strings.HasPrefix(value, `"`) {
+		s, err := strconv.Unquote(value)
+		if err != nil {
+			return "", fmt.Errorf("malformed string: %s", value)
+		}
+		return s, nil
+	}
+	if strings.HasPrefix(value, "'") {
+		if len(value) < 2 || !strings.HasSuffix(value, "'") {
+			return "", fmt.Errorf("malformed string: %s", value)
+		}
+		return value[1 : len(value)-1], nil
+	}
+	if strings.HasPrefix(value, "[") || strings.HasPrefix(value, "{") {
+		return "", fmt.Errorf("arrays and inline tables are not supported: %s", value)
+	}
+	return value, nil
+}
+
+// stripComment removes a trailing # comment from line, unless inside quotes
+func stripComment(line string) string {
+	inQuote := rune(0)
+	for i, r := range line {
+		switch {
+		case inQuote != 0 && r == inQuote:
+			inQuote = 0
+		case inQuote == 0 && (r == '"' || r == '\''):
+			inQuote = r
+		case inQuote == 0 && r == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
@@ -0,0 +1,122 @@
+package flowbase
+
+import "sync"
+
+// Usage is an amount of billable resources used by a process
+type Usage struct {
+	CPUHours         float64
+	BytesTransferred int64
+	APICalls         int
+}
+
+// add adds the usage u2 to u
+func (u *Usage) add(u2 Usage) {
+	u.CPUHours += u2.CPUHours
+	u.BytesTransferred += u2.BytesTransferred
+	u.APICalls += u2.APICalls
+}
+
+// ReportUsage publishes an EventUsage event, for the use of the billable
+// resources usage by the process named process, so that it can be
+// attributed a cost (see CostAccountant). ExecProc reports the CPU time of
+// its tasks, as their execution time times their number of cores, StageIn
+// and StageOut the bytes they transfer, and components calling APIs their
+// API calls.
+func (net *Network) ReportUsage(process string, usage Usage) {
+	net.publish(&Event{Type: EventUsage, Process: process, Usage: &usage})
+}
+
+// ReportUsage reports the use of billable resources by the process (see
+// Network.ReportUsage)
+func (p *BaseProcess) ReportUsage(usage Usage) {
+	p.Network().ReportUsage(p.Name(), usage)
+}
+
+// CostRates are the prices of billable resources, in any currency
+type CostRates struct {
+	PerCPUHour       float64
+	PerGBTransferred float64
+	PerAPICall       float64
+}
+
+// Cost returns the cost of the usage u at the rates r
+func (r CostRates) Cost(u Usage) float64 {
+	return u.CPUHours*r.PerCPUHour + float64(u.BytesTransferred)/1e9*r.PerGBTransferred + float64(u.APICalls)*r.PerAPICall
+}
+
+// ProcessCost is the use of billable resources by a process, and its cost
+type ProcessCost struct {
+	Usage
+	Cost float64
+}
+
+// CostAccountant attributes costs to the processes of a network, from the
+// usage they report (see Network.ReportUsage), at configurable rates, such
+// as for teams running pipelines in the cloud to see what each run, and
+// each of its steps, costs.
+type CostAccountant struct {
+	mx          sync.Mutex
+	rates       CostRates
+	procRates   map[string]CostRates
+	costs       map[string]*ProcessCost
+	unsubscribe func()
+}
+
+// NewCostAccountant returns a new CostAccountant, attributing costs to the
+// processes of net at the rates rates, or at the rates in procRates for the
+// processes in it, by process name, until Stop is called
+func NewCostAccountant(net *Network, rates CostRates, procRates map[string]CostRates) *CostAccountant {
+	a := &CostAccountant{
+		rates:     rates,
+		procRates: procRates,
+		costs:     map[string]*ProcessCost{},
+	}
+	a.unsubscribe = net.Subscribe(EventTypes(EventUsage), a.record)
+	return a
+}
+
+// Stop stops attributing costs
+func (a *CostAccountant) Stop() {
+	a.unsubscribe()
+}
+
+// record adds the usage of the event e to the cost of its process
+func (a *CostAccountant) record(e *Event) {
+	rates, ok := a.procRates[e.Process]
+	if !ok {
+		rates = a.rates
+	}
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	pc, ok := a.costs[e.Process]
+	if !ok {
+		pc = &ProcessCost{}
+		a.costs[e.Process] = pc
+	}
+	pc.add(*e.Usage)
+	pc.Cost += rates.Cost(*e.Usage)
+}
+
+// Costs returns the usage and cost of each process which has reported any
+// usage, by process name
+func (a *CostAccountant) Costs() map[string]*ProcessCost {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	costs := make(map[string]*ProcessCost, len(a.costs))
+	for proc, pc := range a.costs {
+		pcCopy := *pc
+		costs[proc] = &pcCopy
+	}
+	return costs
+}
+
+// TotalCost returns the total cost of all processes
+func (a *CostAccountant) TotalCost() float64 {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	total := 0.0
+	for _, pc := range a.costs {
+		total += pc.Cost
+	}
+	return total
+}
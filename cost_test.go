@@ -0,0 +1,38 @@
+package flowbase
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCostAccountant(t *testing.T) {
+	initTestLogs()
+	net := NewNetwork("TestCostAccountant")
+	costs := NewCostAccountant(net, CostRates{PerCPUHour: 2, PerGBTransferred: 0.1}, map[string]CostRates{"api": {PerAPICall: 0.01}})
+
+	src := NewFileSource(net, "src", "a.txt", "b.txt")
+	echo := NewExecProc(net, "echo", "echo {i:in}")
+	echo.In("in").From(src.Out())
+	out := NewPacketCollector(net, "out")
+	out.In().From(echo.Stdout())
+	net.Run()
+
+	net.ReportUsage("stage", Usage{BytesTransferred: 3e9})
+	net.ReportUsage("api", Usage{APICalls: 100})
+	net.ReportUsage("api", Usage{APICalls: 50})
+	costs.Stop()
+	net.ReportUsage("api", Usage{APICalls: 1000})
+
+	procCosts := costs.Costs()
+	if procCosts["echo"] == nil || procCosts["echo"].CPUHours <= 0 {
+		t.Errorf("No CPU time recorded for the tasks of echo: %v", procCosts["echo"])
+	}
+	assertEqualValues(t, int64(3e9), procCosts["stage"].BytesTransferred)
+	assertEqualValues(t, 0.3, math.Round(procCosts["stage"].Cost*1e6)/1e6)
+	assertEqualValues(t, 150, procCosts["api"].APICalls)
+	assertEqualValues(t, 1.5, procCosts["api"].Cost)
+	total := procCosts["echo"].Cost + procCosts["stage"].Cost + procCosts["api"].Cost
+	if math.Abs(total-costs.TotalCost()) > 1e-9 {
+		t.Errorf("Total cost %f is not the sum of the process costs %f", costs.TotalCost(), total)
+	}
+}
@@ -0,0 +1,77 @@
+package flowbase
+
+import "time"
+
+// CriticalPathStep is a task on the critical path of a run (see
+// CriticalPath)
+type CriticalPathStep struct {
+	Task *AuditInfo
+	// Duration is how long the task executed
+	Duration time.Duration
+	// Wait is the time from when the previous task on the path finished
+	// until the task started, such as when waiting for a free task slot, or
+	// for other inputs
+	Wait time.Duration
+}
+
+// CriticalPath returns the critical path through the tasks infos of a run
+// (see ReadAuditInfos), which is the chain of tasks that determined the
+// total run time, in the order they ran. It ends with the task which
+// finished last, and goes back from each task to the upstream task which
+// finished last, as the one the task had to wait for. Speeding up tasks off
+// the critical path does not make the run finish sooner.
+func CriticalPath(infos []*AuditInfo) []*CriticalPathStep {
+	if len(infos) == 0 {
+		return nil
+	}
+	byID := map[string]*AuditInfo{}
+	var last *AuditInfo
+	for _, ai := range infos {
+		byID[ai.ID] = ai
+		if last == nil || ai.FinishTime.After(last.FinishTime) {
+			last = ai
+		}
+	}
+
+	path := []*AuditInfo{}
+	for ai := last; ai != nil; {
+		path = append(path, ai)
+		var prev *AuditInfo
+		for _, up := range executedUpstream(ai, byID, map[string]bool{}) {
+			if prev == nil || up.FinishTime.After(prev.FinishTime) {
+				prev = up
+			}
+		}
+		ai = prev
+	}
+
+	steps := make([]*CriticalPathStep, 0, len(path))
+	for i := len(path) - 1; i >= 0; i-- {
+		ai := path[i]
+		step := &CriticalPathStep{Task: ai, Duration: ai.FinishTime.Sub(ai.StartTime)}
+		if i < len(path)-1 {
+			step.Wait = ai.StartTime.Sub(path[i+1].FinishTime)
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+// executedUpstream returns the upstream tasks of ai which are among the
+// executed tasks byID, looking past upstream tasks which were not executed,
+// such as sources, to their own upstream tasks
+func executedUpstream(ai *AuditInfo, byID map[string]*AuditInfo, seen map[string]bool) []*AuditInfo {
+	ups := []*AuditInfo{}
+	for _, up := range ai.Upstream {
+		if seen[up.ID] {
+			continue
+		}
+		seen[up.ID] = true
+		if executed, ok := byID[up.ID]; ok {
+			ups = append(ups, executed)
+			continue
+		}
+		ups = append(ups, executedUpstream(up, byID, seen)...)
+	}
+	return ups
+}
@@ -0,0 +1,36 @@
+package flowbase
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCriticalPath(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	download := newTimedAuditInfo("download", "download", t0, 0, 2)
+	index := newTimedAuditInfo("index", "index", t0, 0, 3)
+	// The source of the reference is not an executed task itself, so the
+	// path goes through it to the index task
+	source := NewAuditInfo()
+	source.ID = "source"
+	source.Upstream["in"] = index
+	align := newTimedAuditInfo("align", "align", t0, 4, 7)
+	align.Upstream["reads"] = download
+	align.Upstream["ref"] = source
+	stats := newTimedAuditInfo("stats", "stats", t0, 2, 4)
+	stats.Upstream["in"] = download
+	report := newTimedAuditInfo("report", "report", t0, 7, 8)
+	report.Upstream["aligned"] = align
+	report.Upstream["stats"] = stats
+
+	steps := CriticalPath([]*AuditInfo{download, index, align, stats, report})
+
+	ids := []string{}
+	for _, step := range steps {
+		ids = append(ids, step.Task.ID)
+	}
+	assertEqualValues(t, []string{"index", "align", "report"}, ids)
+	assertEqualValues(t, 3*time.Second, steps[1].Duration)
+	assertEqualValues(t, time.Second, steps[1].Wait)
+	assertEqualValues(t, time.Duration(0), steps[2].Wait)
+}
@@ -0,0 +1,143 @@
+package flowbase
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CycleError is returned by Network.Validate, and makes Network.Run fail,
+// when the connections between processes form a cycle which is not broken by
+// a feedback in-port (see InPort.SetFeedback). Path contains the connections
+// making up the cycle, in order, each formatted as "proc.outport ->
+// proc.inport".
+type CycleError struct {
+	Network string
+	Path    []string
+}
+
+// Error returns a description of the cycle, with a suggestion for how to fix
+// it
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("network %s contains a cycle, which will deadlock: %s. "+
+		"If the cycle is intended, mark one of its in-ports as a feedback edge "+
+		"with SetFeedback(true), and make sure it is buffered enough for the "+
+		"packets in flight (see SetRingBuffer)", e.Network, strings.Join(e.Path, ", "))
+}
+
+// SetFeedback marks the in-port as the receiving end of a feedback edge,
+// which makes its incoming connections be allowed to form cycles, and be
+// ignored when ordering processes topologically
+func (pt *InPort) SetFeedback(feedback bool) {
+	pt.feedback = feedback
+}
+
+// Feedback returns whether the in-port is marked as the receiving end of a
+// feedback edge
+func (pt *InPort) Feedback() bool {
+	return pt.feedback
+}
+
+// Validate checks that the network can be run, that is, that it is not empty,
+// that all processes are connected, and that it does not contain any cycles
+// without a feedback edge
+func (net *Network) Validate() error {
+	if len(net.procs) == 0 {
+		return errors.New("network " + net.Name() + " is empty")
+	}
+	for _, node := range net.ProcsSorted() {
+		if !node.Ready() {
+			return fmt.Errorf("process %s in network %s is not fully connected", node.Name(), net.Name())
+		}
+	}
+	if cycle := net.findCycle(net.procs); cycle != nil {
+		return cycle
+	}
+	return nil
+}
+
+// findCycle returns a *CycleError for the first cycle found among procs, by
+// depth-first search in name order, or nil if there is none
+func (net *Network) findCycle(procs map[string]Node) *CycleError {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[string]int{}
+	// path holds the connections followed from the start of the search to
+	// the process currently visited, and pathProcs the processes they go from
+	path := []string{}
+	pathProcs := []string{}
+
+	var visit func(node Node) *CycleError
+	visit = func(node Node) *CycleError {
+		state[node.Name()] = visiting
+		for _, edge := range forwardEdges(node, procs) {
+			next := edge.to.Process()
+			path = append(path, edge.from.FullName()+" -> "+edge.to.FullName())
+			pathProcs = append(pathProcs, node.Name())
+			switch state[next.Name()] {
+			case visiting:
+				for i, name := range pathProcs {
+					if name == next.Name() {
+						return &CycleError{Network: net.Name(), Path: append([]string{}, path[i:]...)}
+					}
+				}
+			case unvisited:
+				if cycle := visit(next); cycle != nil {
+					return cycle
+				}
+			}
+			path = path[:len(path)-1]
+			pathProcs = pathProcs[:len(pathProcs)-1]
+		}
+		state[node.Name()] = visited
+		return nil
+	}
+
+	names := []string{}
+	for name := range procs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if state[name] == unvisited {
+			if cycle := visit(procs[name]); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// edge is a connection from an out-port to an in-port
+type edge struct {
+	from *OutPort
+	to   *InPort
+}
+
+// forwardEdges returns the connections from the out-ports of node to
+// in-ports of processes among procs, except for feedback in-ports, sorted by
+// port names
+func forwardEdges(node Node, procs map[string]Node) []edge {
+	edges := []edge{}
+	for _, opt := range node.OutPorts() {
+		for _, ipt := range opt.ConnectedPorts() {
+			if ipt.Feedback() || ipt.Process() == nil {
+				continue
+			}
+			if member, ok := procs[ipt.Process().Name()]; ok && member == ipt.Process() {
+				edges = append(edges, edge{from: opt, to: ipt})
+			}
+		}
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].from.Name() != edges[j].from.Name() {
+			return edges[i].from.Name() < edges[j].from.Name()
+		}
+		return edges[i].to.FullName() < edges[j].to.FullName()
+	})
+	return edges
+}
@@ -0,0 +1,38 @@
+package flowbase
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateCycle(t *testing.T) {
+	initTestLogs()
+
+	net := NewNetwork("TestValidateCycle")
+	noTags := func(ip *Packet) map[string]string { return nil }
+	a := NewMapToTags(net, "a", noTags)
+	b := NewMapToTags(net, "b", noTags)
+	b.In().From(a.Out())
+	a.In().From(b.Out())
+
+	err := net.Validate()
+	cycle := &CycleError{}
+	if !errors.As(err, &cycle) {
+		t.Fatalf("Expected a CycleError, got: %v", err)
+	}
+	assertEqualValues(t, []string{"a.out -> b.in", "b.out -> a.in"}, cycle.Path)
+
+	if _, err := net.TopologicalOrder(); err == nil {
+		t.Fatal("Expected TopologicalOrder to fail on a cycle")
+	}
+
+	a.In().SetFeedback(true)
+	if err := net.Validate(); err != nil {
+		t.Fatalf("Expected no error with a feedback edge, got: %v", err)
+	}
+	order, err := net.TopologicalOrder()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	assertEqualValues(t, []string{"a", "b"}, nodeNames(order))
+}
@@ -0,0 +1,93 @@
+package flowbase
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// diskGuard keeps track of the disk space reserved by running tasks, by
+// filesystem, so that concurrent tasks do not all count the same free space
+type diskGuard struct {
+	mx         sync.Mutex
+	minFreeMB  int64
+	reservedMB map[uint64]int64
+}
+
+// SetMinFreeDisk makes tasks of ExecProcs only start if the filesystems
+// their outputs are written to would have at least minFreeMB MB free after
+// the task, and the other running tasks, have written their outputs, and
+// otherwise fail the network, with a message on which filesystem is full,
+// rather than leave half-written outputs behind. The disk space a task
+// needs is estimated by ExecProc.RequiredDiskMB. Disk space is only checked
+// on Linux, macOS and FreeBSD.
+func (net *Network) SetMinFreeDisk(minFreeMB int) {
+	net.disk.mx.Lock()
+	defer net.disk.mx.Unlock()
+	net.disk.minFreeMB = int64(minFreeMB)
+}
+
+// reserveDisk reserves requiredMB MB on each of the filesystems of the
+// paths, and returns a function releasing the reservations, or an error if
+// that would leave less than the minimum free space on any of them
+func (net *Network) reserveDisk(paths []string, requiredMB int64) (release func(), err error) {
+	g := &net.disk
+	g.mx.Lock()
+	defer g.mx.Unlock()
+	if g.minFreeMB <= 0 {
+		return func() {}, nil
+	}
+	if g.reservedMB == nil {
+		g.reservedMB = map[uint64]int64{}
+	}
+	filesystems := map[uint64]string{}
+	freeMB := map[uint64]int64{}
+	for _, path := range paths {
+		dir := filepath.Dir(path)
+		fs, free, ok, err := diskFree(dir)
+		if err != nil {
+			return nil, errWrapf(err, "could not check free disk space in %s", dir)
+		}
+		if !ok {
+			continue
+		}
+		if _, seen := filesystems[fs]; !seen {
+			filesystems[fs] = dir
+			freeMB[fs] = free / (1024 * 1024)
+		}
+	}
+	for fs, dir := range filesystems {
+		if left := freeMB[fs] - g.reservedMB[fs] - requiredMB; left < g.minFreeMB {
+			return nil, fmt.Errorf("not enough free disk space on the filesystem of %s: %d MB free, of which %d MB are reserved by running tasks, and the task needs %d MB, which leaves less than the minimum of %d MB",
+				dir, freeMB[fs], g.reservedMB[fs], requiredMB, g.minFreeMB)
+		}
+	}
+	for fs := range filesystems {
+		g.reservedMB[fs] += requiredMB
+	}
+	return func() {
+		g.mx.Lock()
+		defer g.mx.Unlock()
+		for fs := range filesystems {
+			g.reservedMB[fs] -= requiredMB
+		}
+	}, nil
+}
+
+// requiredDiskMB returns the disk space the task t is estimated to need for
+// its outputs, in MB (see ExecProc.RequiredDiskMB)
+func (p *ExecProc) requiredDiskMB(t *ExecTask) int64 {
+	if p.RequiredDiskMB > 0 {
+		return int64(p.RequiredDiskMB)
+	}
+	var size int64
+	for _, ip := range t.InPackets {
+		if f, ok := ip.Data().(*FileIP); ok {
+			if stat, err := os.Stat(f.Path()); err == nil {
+				size += stat.Size()
+			}
+		}
+	}
+	return (size + 1024*1024 - 1) / (1024 * 1024)
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd
+
+package flowbase
+
+// diskFree returns false, as checking free disk space is not supported on
+// this operating system
+func diskFree(dir string) (fs uint64, free int64, ok bool, err error) {
+	return 0, 0, false, nil
+}
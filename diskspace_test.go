@@ -0,0 +1,57 @@
+package flowbase
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReserveDisk(t *testing.T) {
+	dir := t.TempDir()
+	fs, free, ok, err := diskFree(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Skip("checking free disk space is not supported")
+	}
+	freeMB := free / (1024 * 1024)
+	net := NewNetwork("TestReserveDisk")
+	paths := []string{filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")}
+
+	// Without a minimum, nothing is checked or reserved
+	release, err := net.reserveDisk(paths, freeMB*2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+
+	net.SetMinFreeDisk(1)
+	release, err = net.reserveDisk(paths, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqualValues(t, int64(1), net.disk.reservedMB[fs])
+	release()
+	assertEqualValues(t, int64(0), net.disk.reservedMB[fs])
+
+	_, err = net.reserveDisk(paths, freeMB+1)
+	if err == nil || !strings.Contains(err.Error(), "not enough free disk space") {
+		t.Errorf("Expected an error about the disk space, got: %v", err)
+	}
+}
+
+func TestExecProcRequiredDiskMB(t *testing.T) {
+	initTestLogs()
+	path := filepath.Join(t.TempDir(), "in.txt")
+	if err := os.WriteFile(path, make([]byte, 3*1024*1024+1), 0644); err != nil {
+		t.Fatal(err)
+	}
+	net := NewNetwork("TestExecProcRequiredDiskMB")
+	p := NewExecProc(net, "cat", "cat {i:in} > {o:out}")
+	task := &ExecTask{InPackets: map[string]*Packet{"in": NewPacket(NewFileIP(path))}}
+	assertEqualValues(t, int64(4), p.requiredDiskMB(task))
+	p.RequiredDiskMB = 100
+	assertEqualValues(t, int64(100), p.requiredDiskMB(task))
+}
@@ -0,0 +1,19 @@
+//go:build linux || darwin || freebsd
+
+package flowbase
+
+import "syscall"
+
+// diskFree returns an ID of the filesystem of the directory dir, and the
+// bytes available on it to unprivileged users
+func diskFree(dir string) (fs uint64, free int64, ok bool, err error) {
+	var stat syscall.Stat_t
+	if err := syscall.Stat(dir, &stat); err != nil {
+		return 0, 0, false, err
+	}
+	var statfs syscall.Statfs_t
+	if err := syscall.Statfs(dir, &statfs); err != nil {
+		return 0, 0, false, err
+	}
+	return uint64(stat.Dev), int64(statfs.Bavail) * int64(statfs.Bsize), true, nil
+}
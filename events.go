@@ -0,0 +1,167 @@
+package flowbase
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EventType is the type of an Event
+type EventType string
+
+const (
+	// EventNetworkStarted is published when a network starts running
+	EventNetworkStarted EventType = "NetworkStarted"
+	// EventNetworkFinished is published when a network has finished running
+	EventNetworkFinished EventType = "NetworkFinished"
+	// EventProcessStarted is published when a process starts running
+	EventProcessStarted EventType = "ProcessStarted"
+	// EventProcessFinished is published when the Run method of a process
+	// has returned
+	EventProcessFinished EventType = "ProcessFinished"
+	// EventProcessFailed is published when a process fails, right before the
+	// program exits
+	EventProcessFailed EventType = "ProcessFailed"
+	// EventPacketSent is published for every packet sent on an out-port, once
+	// regardless of how many in-ports it is connected to
+	EventPacketSent EventType = "PacketSent"
+	// EventTaskStarted is published when a task of a process, such as a
+	// command of an ExecProc, starts executing
+	EventTaskStarted EventType = "TaskStarted"
+	// EventTaskFinished is published when a task has finished executing,
+	// whether it succeeded or not
+	EventTaskFinished EventType = "TaskFinished"
+	// EventUsage is published when a process reports its use of billable
+	// resources, such as CPU time or API calls (see Network.ReportUsage)
+	EventUsage EventType = "Usage"
+	// EventHighWaterMark is published when the queue of an in-port has been
+	// above its high-water mark for longer than the grace period, identifying
+	// the process of the in-port as a slow consumer (see
+	// InPort.SetHighWaterMark)
+	EventHighWaterMark EventType = "HighWaterMark"
+)
+
+// Event is an event in a network, published to subscribers registered with
+// Network.Subscribe. Process, Port and Packet are set for events concerning
+// them, Task for task events, Usage for usage events, QueueDepth for
+// high-water-mark events, and Message for failures and warnings.
+type Event struct {
+	Type    EventType
+	Time    time.Time
+	RunID   string
+	Process string
+	Port    string
+	Packet  *Packet
+	// Task is the audit info of the task, for task events
+	Task *AuditInfo
+	// Usage is the resources used, for usage events
+	Usage *Usage
+	// QueueDepth is the number of packets queued in the in-port, for
+	// high-water-mark events
+	QueueDepth int
+	Message    string
+}
+
+// EventFilter selects the events a subscriber receives. A nil filter selects
+// all events.
+type EventFilter func(e *Event) bool
+
+// EventTypes returns an EventFilter selecting events of the types types
+func EventTypes(types ...EventType) EventFilter {
+	return func(e *Event) bool {
+		for _, typ := range types {
+			if e.Type == typ {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// eventBus dispatches events to subscribers
+type eventBus struct {
+	mx          sync.RWMutex
+	subscribers map[int]*subscriber
+	nextID      int
+	// count is the number of subscribers, which can be checked without
+	// locking, so that publishing is cheap when there are no subscribers
+	count int32
+}
+
+type subscriber struct {
+	filter  EventFilter
+	handler func(e *Event)
+}
+
+// Subscribe registers handler to be called with every event in the network
+// selected by filter (or all events, if filter is nil). Handlers are called
+// synchronously, from the goroutine where the event happened, so they need to
+// be fast and safe for concurrent use. The returned function unsubscribes the
+// handler.
+func (net *Network) Subscribe(filter EventFilter, handler func(e *Event)) (unsubscribe func()) {
+	bus := &net.events
+	bus.mx.Lock()
+	defer bus.mx.Unlock()
+	if bus.subscribers == nil {
+		bus.subscribers = make(map[int]*subscriber)
+	}
+	id := bus.nextID
+	bus.nextID++
+	bus.subscribers[id] = &subscriber{filter: filter, handler: handler}
+	atomic.AddInt32(&bus.count, 1)
+	return func() {
+		bus.mx.Lock()
+		defer bus.mx.Unlock()
+		if _, ok := bus.subscribers[id]; ok {
+			delete(bus.subscribers, id)
+			atomic.AddInt32(&bus.count, -1)
+		}
+	}
+}
+
+// hasSubscribers tells whether any handlers are subscribed to events
+func (net *Network) hasSubscribers() bool {
+	return atomic.LoadInt32(&net.events.count) > 0
+}
+
+// publish sends the event e to all subscribers whose filters select it
+func (net *Network) publish(e *Event) {
+	if !net.hasSubscribers() {
+		return
+	}
+	e.Time = time.Now()
+	e.RunID = net.RunID()
+	bus := &net.events
+	bus.mx.RLock()
+	subs := make([]*subscriber, 0, len(bus.subscribers))
+	for _, sub := range bus.subscribers {
+		subs = append(subs, sub)
+	}
+	bus.mx.RUnlock()
+	for _, sub := range subs {
+		if sub.filter == nil || sub.filter(e) {
+			sub.handler(e)
+		}
+	}
+}
+
+// networkOf returns the network of the process node, if it has one
+func networkOf(node Node) *Network {
+	if n, ok := node.(interface{ Network() *Network }); ok {
+		return n.Network()
+	}
+	return nil
+}
+
+// publishPacketSent publishes an EventPacketSent event for the packet ip sent
+// on the out-port pt
+func publishPacketSent(pt *OutPort, ip *Packet) {
+	if pt.process == nil {
+		return
+	}
+	net := networkOf(pt.process)
+	if net == nil || !net.hasSubscribers() {
+		return
+	}
+	net.publish(&Event{Type: EventPacketSent, Process: pt.process.Name(), Port: pt.Name(), Packet: ip})
+}
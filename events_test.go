@@ -0,0 +1,38 @@
+package flowbase
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestNetworkSubscribe(t *testing.T) {
+	initTestLogs()
+	net := NewNetwork("TestNetworkSubscribe")
+
+	src := NewFileSource(net, "src", "a.txt", "b.txt")
+	out := NewPacketCollector(net, "out")
+	out.In().From(src.Out())
+
+	mx := sync.Mutex{}
+	counts := map[EventType]int{}
+	net.Subscribe(nil, func(e *Event) {
+		mx.Lock()
+		defer mx.Unlock()
+		counts[e.Type]++
+	})
+	sent := []string{}
+	unsubscribe := net.Subscribe(EventTypes(EventPacketSent), func(e *Event) {
+		mx.Lock()
+		defer mx.Unlock()
+		sent = append(sent, e.Process+"."+e.Port)
+	})
+
+	net.Run()
+	unsubscribe()
+
+	assertEqualValues(t, 1, counts[EventNetworkStarted])
+	assertEqualValues(t, 1, counts[EventNetworkFinished])
+	assertEqualValues(t, 3, counts[EventProcessStarted]) // Including the sink
+	assertEqualValues(t, 2, counts[EventPacketSent])
+	assertEqualValues(t, []string{"src.out", "src.out"}, sent)
+}
@@ -0,0 +1,650 @@
+package flowbase
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/flowbase/flowbase/template"
+)
+
+// ExecProc is a process that runs a shell command for every set of packets
+// received on its in-ports (or once, if it has no in-ports). The command is
+// given as a pattern with placeholders, which are filled in for each task:
+//
+//	{i:name}  The data of the packet received on the in-port name
+//	{o:name}  The path of the file produced for the out-port name
+//	{os:name} Like {o:name}, but streamed via a named pipe (see SetOutStreaming)
+//	{p:name}  The value of the parameter name (see SetParam)
+//	{t:name}  The value of the tag name, of the received packets
+//
+// Placeholders can be followed by |-separated modifiers, such as
+// {i:infile|basename|%.txt} (see the template package). Each line the command
+// writes to stdout is sent as a string packet on the Stdout out-port, and the
+// produced files as *FileIPs on the out-ports named in the pattern. Commands
+// are run by the Executor set on the process or on its network, and locally
+// by default.
+type ExecProc struct {
+	BaseProcess
+	CommandPattern string
+	cmdTemplate    *template.Template
+	// FailOnError makes the network fail when a command exits with a
+	// non-zero exit code. If false, an *ExecError is sent on the Errors
+	// out-port instead.
+	FailOnError bool
+	// ReuseExisting makes tasks whose output files all exist already be
+	// skipped, sending on the existing files instead
+	ReuseExisting bool
+	// Checksums makes SHA-256 checksums of input and output files be recorded
+	// in the audit info of tasks, and in the audit files of outputs
+	Checksums bool
+	// VerifyChecksums makes existing outputs only be reused (with
+	// ReuseExisting) if the checksums of the output and input files match the
+	// ones recorded in the output's audit file, and otherwise re-executes the
+	// task. It implies Checksums.
+	VerifyChecksums bool
+	// ReuseIfNewer makes existing outputs only be reused (with ReuseExisting)
+	// if they are newer than all input files, like make does. Combined with
+	// VerifyChecksums, outputs older than some input are still reused if the
+	// checksums show that no file has changed.
+	ReuseIfNewer bool
+	// Version is the version of the logic of the process, such as of the tool
+	// its command runs, in the form major.minor.patch. It is recorded in the
+	// audit info of tasks, and with ReuseExisting, existing outputs made with
+	// a different major or minor version are recomputed, while patch
+	// versions are assumed to not change the results.
+	Version string
+	// WorkspaceDir makes each task execute in its own scratch directory,
+	// created under WorkspaceDir. Outputs are written inside the scratch
+	// directory and moved to their final paths, after which the scratch
+	// directory is removed along with anything else the command wrote there.
+	WorkspaceDir string
+	params       map[string]string
+	outPathFuncs map[string]func(t *ExecTask) string
+	outPortNames []string
+	// streamingOutPorts are the out-ports whose files are streamed via named
+	// pipes (FIFOs), rather than written to disk
+	streamingOutPorts map[string]bool
+	// outCompanions are the suffixes of files written alongside the main
+	// output file of out-ports, such as index files, which are sent together
+	// with it as a FileSetIP
+	outCompanions map[string][]string
+	// outDirs are the out-ports whose outputs are directories
+	outDirs map[string]bool
+	// outDirFiles are the out-ports whose output directories are sent as
+	// the files in them
+	outDirFiles map[string]bool
+	// wrapError, if set, wraps the errors of failed commands before they
+	// are sent on the Errors out-port, or fail the network
+	wrapError func(err *ExecError) error
+	taskCount int
+	executor  Executor
+	finalizer Finalizer
+	// Resources are the compute resources needed by each task, used for
+	// resource requests when submitting tasks to batch schedulers
+	Resources Resources
+	// RequiredDiskMB is the disk space each task is estimated to need for
+	// its outputs, in MB, for the disk space check of the network (see
+	// Network.SetMinFreeDisk). Defaults to the total size of the input
+	// files of the task.
+	RequiredDiskMB int
+}
+
+// ExecTask contains the information about one execution of the command of
+// an ExecProc
+type ExecTask struct {
+	ProcessName string
+	Command     string
+	Resources   Resources
+	InPackets   map[string]*Packet
+	Params      map[string]string
+	Tags        map[string]string
+	OutPaths    map[string]string
+	// TempOutPaths are the paths the command writes its outputs to, before
+	// they are finalized to OutPaths (see Finalizer)
+	TempOutPaths map[string]string
+	// WorkDir is the scratch directory the command is executed in, if the
+	// process has a WorkspaceDir
+	WorkDir   string
+	AuditInfo *AuditInfo
+}
+
+// Resources describes the compute resources needed by a task
+type Resources struct {
+	Cores    int
+	MemoryMB int
+	Time     time.Duration
+	GPUs     int
+}
+
+// ExecError is sent on the Errors out-port of an ExecProc when a command fails
+type ExecError struct {
+	Command  string
+	ExitCode int
+	Stderr   string
+}
+
+// Error returns the error message of the ExecError
+func (e *ExecError) Error() string {
+	return fmt.Sprintf("Command exited with exit code %d: %s\nStderr: %s", e.ExitCode, e.Command, e.Stderr)
+}
+
+// NewExecProc returns a new ExecProc, with in-ports, out-ports and parameters
+// set up according to the placeholders in cmdPattern
+func NewExecProc(net *Network, name string, cmdPattern string) *ExecProc {
+	p := newExecProc(net, name, cmdPattern)
+	net.AddProc(p)
+	return p
+}
+
+// newExecProc returns a new ExecProc like NewExecProc, for processes wrapping
+// it, which is not added to the network, neither by newExecProc, nor
+// automatically when its ports are initialized, as the wrapping process is
+// to be added instead
+func newExecProc(net *Network, name string, cmdPattern string) *ExecProc {
+	p := &ExecProc{
+		BaseProcess:       newDetachedBaseProcess(net, name),
+		CommandPattern:    cmdPattern,
+		FailOnError:       true,
+		params:            make(map[string]string),
+		outPathFuncs:      make(map[string]func(t *ExecTask) string),
+		streamingOutPorts: make(map[string]bool),
+		outCompanions:     make(map[string][]string),
+		outDirs:           make(map[string]bool),
+		outDirFiles:       make(map[string]bool),
+		finalizer:         FinalizeRenameInDir{},
+	}
+	cmdTemplate, err := template.Parse(cmdPattern)
+	if err != nil {
+		p.Failf("Could not parse command pattern: %v", err)
+	}
+	p.cmdTemplate = cmdTemplate
+	for _, ph := range cmdTemplate.Placeholders() {
+		typ, phName := ph.Type, ph.Name
+		switch typ {
+		case "i":
+			if _, ok := p.inPorts[phName]; !ok {
+				p.InitInPort(p, phName)
+			}
+		case "o", "os":
+			if _, ok := p.outPorts[phName]; !ok {
+				p.InitOutPort(p, phName)
+				p.outPortNames = append(p.outPortNames, phName)
+			}
+			if typ == "os" {
+				p.streamingOutPorts[phName] = true
+			}
+		case "p":
+			p.params[phName] = ""
+		case "t":
+		default:
+			p.Failf("Unsupported placeholder type (%s) in command pattern: %s", typ, cmdPattern)
+		}
+		// In-packet data, tags, and output paths, which can be derived from
+		// them, are not under our control, so their values are quoted
+		if typ != "p" {
+			addQuoteModifier(ph)
+		}
+	}
+	p.InitOutPort(p, "stdout")
+	p.InitOutPort(p, "errors")
+	return p
+}
+
+// addQuoteModifier adds a quote modifier to the placeholder ph, unless it
+// already has one, so that each of its values is quoted for the shell, after
+// the modifiers transforming single values, but before any join modifier
+func addQuoteModifier(ph *template.Placeholder) {
+	for _, mod := range ph.Modifiers {
+		if mod.Name == "quote" {
+			return
+		}
+	}
+	quote := template.Modifier{Name: "quote"}
+	for i, mod := range ph.Modifiers {
+		if mod.Name == "join" {
+			ph.Modifiers = append(ph.Modifiers[:i:i], append([]template.Modifier{quote}, ph.Modifiers[i:]...)...)
+			return
+		}
+	}
+	ph.Modifiers = append(ph.Modifiers, quote)
+}
+
+// In returns the in-port with name portName
+func (p *ExecProc) In(portName string) *InPort { return p.InPort(portName) }
+
+// Out returns the out-port with name portName
+func (p *ExecProc) Out(portName string) *OutPort { return p.OutPort(portName) }
+
+// Stdout returns the out-port on which the lines written to stdout are sent
+func (p *ExecProc) Stdout() *OutPort { return p.OutPort("stdout") }
+
+// Errors returns the out-port on which *ExecErrors are sent, for failed
+// commands, when FailOnError is false
+func (p *ExecProc) Errors() *OutPort { return p.OutPort("errors") }
+
+// SetParam sets the value of the parameter name, used for {p:name}
+// placeholders
+func (p *ExecProc) SetParam(name string, value string) {
+	if _, ok := p.params[name]; !ok {
+		p.Failf("No parameter placeholder {p:%s} in command pattern: %s", name, p.CommandPattern)
+	}
+	p.params[name] = value
+}
+
+// Param returns the value of the parameter name
+func (p *ExecProc) Param(name string) string {
+	return p.params[name]
+}
+
+// SetOut sets the path pattern for the file of the out-port portName. The
+// pattern can contain the placeholders {i:name}, {p:name} and {t:name}, with
+// modifiers, just like the command pattern, so that output paths can be
+// derived from inputs, parameters and tags, such as:
+//
+//	p.SetOut("out", "{i:in|%.txt}.sorted.txt")
+//
+// Relative paths are relative to the output directory of the network, if it
+// has one (see Network.SetOutDir).
+func (p *ExecProc) SetOut(portName string, pathPattern string) {
+	pathTemplate, err := template.Parse(pathPattern)
+	if err != nil {
+		p.Failf("Could not parse path pattern for out-port (%s): %v", portName, err)
+	}
+	for _, ph := range pathTemplate.Placeholders() {
+		switch ph.Type {
+		case "i":
+			if _, ok := p.inPorts[ph.Name]; !ok {
+				p.Failf("No in-port (%s) for placeholder %s in path pattern: %s", ph.Name, ph.Raw, pathPattern)
+			}
+		case "p", "t":
+		default:
+			p.Failf("Unsupported placeholder %s in path pattern: %s", ph.Raw, pathPattern)
+		}
+	}
+	p.SetOutPathFunc(portName, func(t *ExecTask) string {
+		path, err := pathTemplate.Execute(func(ph *template.Placeholder) ([]string, error) {
+			return p.placeholderValue(t, ph)
+		})
+		if err != nil {
+			p.Failf("Could not format path for out-port (%s): %v", portName, err)
+		}
+		return path
+	})
+}
+
+// SetOutPathFunc sets the function used to create the path of the file for
+// the out-port portName, for a task
+func (p *ExecProc) SetOutPathFunc(portName string, pathFunc func(t *ExecTask) string) {
+	if _, ok := p.outPorts[portName]; !ok {
+		p.Failf("No out-port (%s) in command pattern: %s", portName, p.CommandPattern)
+	}
+	p.outPathFuncs[portName] = pathFunc
+}
+
+// SetOutStreaming sets whether the file for the out-port portName should be
+// streamed via a named pipe (FIFO) instead of being written to disk. The path
+// of the FIFO is sent downstream before the command starts, so that the
+// downstream process can read from it while it is being written. This only
+// works when the downstream process reads the file exactly once, from start
+// to end, and when the processes run on the same machine.
+func (p *ExecProc) SetOutStreaming(portName string, streaming bool) {
+	if _, ok := p.outPorts[portName]; !ok {
+		p.Failf("No out-port (%s) in command pattern: %s", portName, p.CommandPattern)
+	}
+	p.streamingOutPorts[portName] = streaming
+}
+
+// SetOutCompanions declares that the command writes companion files, with the
+// paths of the output of the out-port portName plus each of suffixes, such
+// as ".bai" for index files. The output and its companions are finalized as
+// one unit, and sent as a FileSetIP, with the output as the main file.
+func (p *ExecProc) SetOutCompanions(portName string, suffixes ...string) {
+	p.outCompanions[portName] = suffixes
+}
+
+// SetOutDir declares that the output of the out-port portName is a directory,
+// which is sent as a FileSetIP (see NewDirIP)
+func (p *ExecProc) SetOutDir(portName string) {
+	p.outDirs[portName] = true
+}
+
+// SetOutDirFiles declares that the output of the out-port portName is a
+// directory, like SetOutDir, but sends each file in it as a *FileIP, in
+// lexical order, such as for commands writing a varying number of files
+func (p *ExecProc) SetOutDirFiles(portName string) {
+	p.outDirs[portName] = true
+	p.outDirFiles[portName] = true
+}
+
+// SetFinalizer sets the strategy for writing and finalizing output files.
+// The default is FinalizeRenameInDir.
+func (p *ExecProc) SetFinalizer(finalizer Finalizer) {
+	p.finalizer = finalizer
+}
+
+// Run runs the ExecProc process
+func (p *ExecProc) Run() {
+	defer p.CloseOutPorts()
+
+	if len(p.InPorts()) == 0 {
+		p.runTask(p.newTask(map[string]*Packet{}))
+		return
+	}
+	for {
+		ips, open := p.receiveOnInPorts()
+		if !open {
+			return
+		}
+		p.runTask(p.newTask(ips))
+	}
+}
+
+// newTask creates a new ExecTask from the packets ips received on the in-ports
+func (p *ExecProc) newTask(ips map[string]*Packet) *ExecTask {
+	t := &ExecTask{
+		ProcessName:  p.Name(),
+		Resources:    p.Resources,
+		InPackets:    ips,
+		Params:       make(map[string]string),
+		Tags:         make(map[string]string),
+		OutPaths:     make(map[string]string),
+		TempOutPaths: make(map[string]string),
+		AuditInfo:    NewAuditInfo(),
+	}
+	t.AuditInfo.ComponentVersion = p.Version
+	for k, v := range p.params {
+		t.Params[k] = v
+	}
+	for inpName, ip := range ips {
+		for k, v := range ip.Tags() {
+			t.Tags[k] = v
+		}
+		if ip.AuditInfo() != nil {
+			t.AuditInfo.Upstream[inpName] = ip.AuditInfo()
+		}
+	}
+	if p.WorkspaceDir != "" {
+		t.WorkDir = absPath(filepath.Join(p.WorkspaceDir, p.Name()+"."+t.AuditInfo.ID))
+	}
+	for _, outName := range p.outPortNames {
+		if pathFunc, ok := p.outPathFuncs[outName]; ok {
+			t.OutPaths[outName] = pathFunc(t)
+		} else {
+			t.OutPaths[outName] = fmt.Sprintf("%s.%s.%d.out", p.Name(), outName, p.taskCount)
+		}
+		if outDir := p.Network().OutDir(); outDir != "" && !filepath.IsAbs(t.OutPaths[outName]) {
+			t.OutPaths[outName] = filepath.Join(outDir, t.OutPaths[outName])
+		}
+		if p.streamingOutPorts[outName] {
+			t.OutPaths[outName] += ".fifo"
+			t.TempOutPaths[outName] = t.OutPaths[outName]
+		} else {
+			t.TempOutPaths[outName] = p.finalizer.TempPath(t.OutPaths[outName], t.AuditInfo.ID)
+		}
+		if t.WorkDir != "" {
+			t.setWorkspaceOutPath(outName, p.streamingOutPorts[outName])
+		}
+	}
+	p.taskCount++
+	t.Command = p.formatCommand(t)
+	if t.WorkDir != "" {
+		t.Command = fmt.Sprintf("cd %s && %s", template.ShellQuote(t.WorkDir), t.Command)
+	}
+
+	t.AuditInfo.RunID = p.Network().RunID()
+	t.AuditInfo.ProcessName = p.Name()
+	t.AuditInfo.Command = t.Command
+	t.AuditInfo.Params = t.Params
+	t.AuditInfo.Tags = t.Tags
+	t.AuditInfo.OutFiles = t.OutPaths
+	return t
+}
+
+// formatCommand fills in the placeholders of the command pattern for task t
+func (p *ExecProc) formatCommand(t *ExecTask) string {
+	cmd, err := p.cmdTemplate.Execute(func(ph *template.Placeholder) ([]string, error) {
+		return p.placeholderValue(t, ph)
+	})
+	if err != nil {
+		p.Failf("Could not format command: %v", err)
+	}
+	return cmd
+}
+
+// placeholderValue returns the value of the placeholder ph for the task t
+func (p *ExecProc) placeholderValue(t *ExecTask, ph *template.Placeholder) ([]string, error) {
+	switch ph.Type {
+	case "i":
+		// The files of file sets are separate values, so that each path is
+		// quoted as a single argument
+		vals := []string{fmt.Sprint(t.InPackets[ph.Name].Data())}
+		if fs, ok := t.InPackets[ph.Name].Data().(*FileSetIP); ok && !fs.IsDir() {
+			vals = append([]string{}, fs.Paths()...)
+		}
+		if t.WorkDir != "" {
+			for i, val := range vals {
+				vals[i] = t.workspaceInPath(val)
+			}
+		}
+		return vals, nil
+	case "o", "os":
+		return []string{t.TempOutPaths[ph.Name]}, nil
+	case "p":
+		return []string{t.Params[ph.Name]}, nil
+	case "t":
+		return []string{t.Tags[ph.Name]}, nil
+	}
+	return nil, fmt.Errorf("unsupported placeholder: %s", ph.Raw)
+}
+
+// runTask executes the command of the task t, and sends on the results
+func (p *ExecProc) runTask(t *ExecTask) {
+	if p.ReuseExisting && p.outputsReusable(t) {
+		p.Auditf("Reusing existing outputs for: %s", t.AuditInfo.RedactedCommand())
+		for outName, path := range t.OutPaths {
+			if ai, err := ReadAuditFile(path); err == nil {
+				t.AuditInfo = ai
+			}
+			p.sendOutput(t, outName, path)
+		}
+		return
+	}
+	checksums := p.Checksums || p.VerifyChecksums
+	if checksums {
+		p.recordInputChecksums(t)
+	}
+
+	for _, path := range t.TempOutPaths {
+		createDirs(path)
+	}
+	if t.WorkDir != "" {
+		if err := os.MkdirAll(t.WorkDir, 0777); err != nil {
+			p.Failf("Could not create workspace %s: %v", t.WorkDir, err)
+		}
+		defer os.RemoveAll(t.WorkDir)
+	}
+
+	// Tasks writing to FIFOs can not take up a concurrent task slot, as they
+	// block until the downstream task, which might need one, reads from them
+	streaming := false
+	for outName, path := range t.OutPaths {
+		if p.streamingOutPorts[outName] {
+			streaming = true
+			os.Remove(path)
+			if out, err := exec.Command("mkfifo", path).CombinedOutput(); err != nil {
+				p.Failf("Could not create FIFO %s: %v\n%s", path, err, out)
+			}
+			defer os.Remove(path)
+			p.Out(outName).SendPacket(p.newOutPacket(t, NewFileIP(path)))
+		}
+	}
+
+	if !streaming {
+		p.Network().IncConcurrentTasks(1)
+		defer p.Network().DecConcurrentTasks(1)
+	}
+	tempPaths := make([]string, 0, len(t.TempOutPaths))
+	for _, path := range t.TempOutPaths {
+		tempPaths = append(tempPaths, path)
+	}
+	releaseDisk, err := p.Network().reserveDisk(tempPaths, p.requiredDiskMB(t))
+	if err != nil {
+		p.Failf("Refusing to execute (%s): %v", t.AuditInfo.RedactedCommand(), err)
+	}
+	defer releaseDisk()
+
+	p.Auditf("Executing: %s", t.AuditInfo.RedactedCommand())
+	t.AuditInfo.StartTime = time.Now()
+	p.Network().publish(&Event{Type: EventTaskStarted, Process: p.Name(), Task: t.AuditInfo})
+	sendLine := func(line string) {
+		p.Stdout().SendPacket(p.newOutPacket(t, line))
+	}
+	err = p.Executor().Execute(t, sendLine)
+	t.AuditInfo.FinishTime = time.Now()
+	t.AuditInfo.ExecTimeNS = t.AuditInfo.FinishTime.Sub(t.AuditInfo.StartTime)
+	taskFinished := &Event{Type: EventTaskFinished, Process: p.Name(), Task: t.AuditInfo}
+	if err != nil {
+		taskFinished.Message = err.Error()
+	}
+	p.Network().publish(taskFinished)
+	cores := t.Resources.Cores
+	if cores < 1 {
+		cores = 1
+	}
+	p.ReportUsage(Usage{CPUHours: t.AuditInfo.ExecTimeNS.Hours() * float64(cores)})
+
+	if err != nil {
+		for outName, tempPath := range t.TempOutPaths {
+			if !p.streamingOutPorts[outName] {
+				os.RemoveAll(tempPath)
+				for _, suffix := range p.outCompanions[outName] {
+					os.RemoveAll(tempPath + suffix)
+				}
+			}
+		}
+		execErr, ok := err.(*ExecError)
+		if !ok {
+			p.Failf("Could not execute command (%s): %v", t.Command, err)
+		}
+		var failErr error = execErr
+		if p.wrapError != nil {
+			failErr = p.wrapError(execErr)
+		}
+		if p.FailOnError {
+			p.Fail(failErr)
+		}
+		p.Errors().SendPacket(p.newOutPacket(t, failErr))
+		return
+	}
+	// Outputs left in S3 by the executor (see AWSBatchConfig.DownloadOutputs)
+	// are sent on as they are, without local finalization, checksums or
+	// audit files
+	for outName, path := range t.OutPaths {
+		if p.streamingOutPorts[outName] || isBucketURI(path, "s3://") {
+			continue
+		}
+		tempPaths, finalPaths := []string{t.TempOutPaths[outName]}, []string{path}
+		for _, suffix := range p.outCompanions[outName] {
+			tempPaths = append(tempPaths, t.TempOutPaths[outName]+suffix)
+			finalPaths = append(finalPaths, path+suffix)
+		}
+		if err := finalizeFileSet(p.finalizer, tempPaths, finalPaths); err != nil {
+			p.Failf("Could not finalize output (%s): %v", path, err)
+		}
+		if checksums && !p.outDirs[outName] {
+			p.recordOutputChecksum(t, path)
+		}
+	}
+	for outName, path := range t.OutPaths {
+		if p.streamingOutPorts[outName] {
+			continue
+		}
+		if isBucketURI(path, "s3://") {
+			p.Out(outName).SendPacket(p.newOutPacket(t, NewFileIP(path)))
+			continue
+		}
+		if err := t.AuditInfo.WriteAuditFile(path); err != nil {
+			Warning.Printf("[Process:%s] %v\n", p.Name(), err)
+		}
+		p.sendOutput(t, outName, path)
+	}
+}
+
+// sendOutput sends the finalized output at path on the out-port outName
+func (p *ExecProc) sendOutput(t *ExecTask, outName string, path string) {
+	if p.outDirFiles[outName] {
+		for _, fip := range NewDirIP(path).Files() {
+			p.Out(outName).SendPacket(p.newOutPacket(t, fip))
+		}
+		return
+	}
+	p.Out(outName).SendPacket(p.newOutPacket(t, p.outData(outName, path)))
+}
+
+// outData returns the packet data for the finalized output at path, of the
+// out-port outName
+func (p *ExecProc) outData(outName string, path string) any {
+	if p.outDirs[outName] {
+		return NewDirIP(path)
+	}
+	if suffixes, ok := p.outCompanions[outName]; ok {
+		paths := []string{path}
+		for _, suffix := range suffixes {
+			paths = append(paths, path+suffix)
+		}
+		return NewFileSetIP(paths...)
+	}
+	return NewFileIP(path)
+}
+
+// outputsReusable tells whether all the output files of task t exist already,
+// and are up to date, so that the task does not need to be executed
+func (p *ExecProc) outputsReusable(t *ExecTask) bool {
+	if len(t.OutPaths) == 0 {
+		return false
+	}
+	for outName, path := range t.OutPaths {
+		if p.streamingOutPorts[outName] {
+			return false
+		}
+		if fs, ok := p.outData(outName, path).(*FileSetIP); ok && !fs.Exists() {
+			return false
+		}
+		if !NewFileIP(path).Exists() {
+			return false
+		}
+		if !p.outputUpToDate(t, path) {
+			return false
+		}
+	}
+	return true
+}
+
+// SetExecutor sets the executor used to run the commands of the process,
+// overriding the one set on the network, if any
+func (p *ExecProc) SetExecutor(executor Executor) {
+	p.executor = executor
+}
+
+// Executor returns the executor used to run the commands of the process
+func (p *ExecProc) Executor() Executor {
+	if p.executor != nil {
+		return p.executor
+	}
+	if p.Network() != nil && p.Network().executor != nil {
+		return p.Network().executor
+	}
+	return NewLocalExecutor()
+}
+
+// newOutPacket returns a new packet with data, and the tags and audit info of
+// the task t
+func (p *ExecProc) newOutPacket(t *ExecTask, data any) *Packet {
+	ip := NewPacket(data)
+	ip.AddTags(t.Tags)
+	ip.SetAuditInfo(t.AuditInfo)
+	return ip
+}
@@ -0,0 +1,283 @@
+package flowbase
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecProcStdoutLines(t *testing.T) {
+	initTestLogs()
+	net := NewNetwork("TestExecProc")
+
+	src := NewFileSource(net, "src", "abc.txt", "cde.txt")
+	echo := NewExecProc(net, "echo", "echo {i:in|%.txt} {p:suffix}")
+	echo.SetParam("suffix", "x")
+	echo.In("in").From(src.Out())
+
+	out := NewPacketCollector(net, "out")
+	out.In().From(echo.Stdout())
+
+	net.Run()
+
+	assertEqualValues(t, []any{"abc x", "cde x"}, out.Data)
+}
+
+func TestExecProcQuotesPlaceholders(t *testing.T) {
+	initTestLogs()
+	net := NewNetwork("TestExecProcQuotesPlaceholders")
+	dir := t.TempDir()
+
+	src := NewFileSource(net, "src", "a b; echo injected")
+	tagger := NewMapToTags(net, "tagger", func(ip *Packet) map[string]string {
+		return map[string]string{"sample": "it's; echo injected"}
+	})
+	tagger.In().From(src.Out())
+	echo := NewExecProc(net, "echo", "echo {i:in} {t:sample} > {o:out}; cat {o:out}")
+	echo.In("in").From(tagger.Out())
+	echo.SetOut("out", dir+"/{t:sample}.txt")
+
+	out := NewPacketCollector(net, "out")
+	out.In().From(echo.Stdout())
+
+	net.Run()
+
+	assertEqualValues(t, []any{"a b; echo injected it's; echo injected"}, out.Data)
+	if _, err := os.Stat(dir + "/it's; echo injected.txt"); err != nil {
+		t.Errorf("Output not written to path with the tag: %v", err)
+	}
+}
+
+func TestExecProcFileSetInput(t *testing.T) {
+	initTestLogs()
+	net := NewNetwork("TestExecProcFileSetInput")
+	dir := t.TempDir()
+	paths := []string{dir + "/a b.txt", dir + "/c.txt"}
+	for _, path := range paths {
+		Check(os.WriteFile(path, []byte("x\n"), 0644))
+	}
+
+	// Each path of a file set is a separate argument, also when joined
+	src := NewIIPSource(net, "src", NewFileSetIP(paths...))
+	ls := NewExecProc(net, "ls", "ls {i:in}; echo {i:in|basename|join:,}; for f in {i:in|join: }; do echo \"$f\"; done")
+	ls.In("in").From(src.Out())
+
+	out := NewPacketCollector(net, "out")
+	out.In().From(ls.Stdout())
+
+	net.Run()
+
+	assertEqualValues(t, []any{paths[0], paths[1], "a b.txt,c.txt", paths[0], paths[1]}, out.Data)
+}
+
+func TestExecProcStreaming(t *testing.T) {
+	initTestLogs()
+	net := NewNetwork("TestExecProcStreaming")
+
+	dir := t.TempDir()
+	write := NewExecProc(net, "write", "printf 'a\\nb\\n' > {os:out}")
+	write.SetOutPathFunc("out", func(t *ExecTask) string { return dir + "/out.txt" })
+	read := NewExecProc(net, "read", "cat {i:in}")
+	read.In("in").From(write.Out("out"))
+
+	out := NewPacketCollector(net, "out")
+	out.In().From(read.Stdout())
+
+	net.Run()
+
+	assertEqualValues(t, []any{"a", "b"}, out.Data)
+}
+
+func TestExecProcFinalizesOutputs(t *testing.T) {
+	initTestLogs()
+	net := NewNetwork("TestExecProcFinalizesOutputs")
+
+	dir := t.TempDir()
+	src := NewFileSource(net, "src", dir+"/in.txt")
+	write := NewExecProc(net, "write", "echo {o:out} {p:ext} {i:in} > {o:out}")
+	write.In("in").From(src.Out())
+	write.SetOut("out", "{i:in|%.txt}.{p:ext}")
+	write.SetParam("ext", "out.txt")
+
+	out := NewPacketCollector(net, "out")
+	out.In().From(write.Out("out"))
+
+	net.Run()
+
+	assertEqualValues(t, []any{NewFileIP(dir + "/in.out.txt")}, out.Data)
+	content, err := os.ReadFile(dir + "/in.out.txt")
+	Check(err)
+	if !strings.Contains(string(content), ".flowbase.tmp.") {
+		t.Errorf("Command did not write to a temporary path: %s", content)
+	}
+}
+
+func TestExecProcVerifyChecksums(t *testing.T) {
+	initTestLogs()
+	dir := t.TempDir()
+	Check(os.WriteFile(dir+"/in.txt", []byte("a"), 0644))
+
+	runs := 0
+	run := func() {
+		net := NewNetwork("TestExecProcVerifyChecksums")
+		src := NewFileSource(net, "src", dir+"/in.txt")
+		cp := NewExecProc(net, "cp", "cat {i:in} > {o:out}; echo ran")
+		cp.In("in").From(src.Out())
+		cp.SetOut("out", "{i:in}.copy")
+		cp.ReuseExisting = true
+		cp.VerifyChecksums = true
+		out := NewPacketCollector(net, "out")
+		out.In().From(cp.Stdout())
+		net.Run()
+		runs += len(out.Data)
+	}
+
+	run()
+	run()
+	if runs != 1 {
+		t.Errorf("Task with unchanged inputs was executed again (runs: %d)", runs)
+	}
+	Check(os.WriteFile(dir+"/in.txt", []byte("b"), 0644))
+	run()
+	if runs != 2 {
+		t.Errorf("Task with changed inputs was not executed again (runs: %d)", runs)
+	}
+	ai, err := ReadAuditFile(dir + "/in.txt.copy")
+	Check(err)
+	if ai.Checksums[dir+"/in.txt"] != NewFileIP(dir+"/in.txt").Checksum() {
+		t.Errorf("Audit file does not contain checksum of the input: %v", ai.Checksums)
+	}
+}
+
+func TestExecProcReuseIfNewer(t *testing.T) {
+	initTestLogs()
+	dir := t.TempDir()
+	Check(os.WriteFile(dir+"/in.txt", []byte("a"), 0644))
+
+	runs := 0
+	run := func() {
+		net := NewNetwork("TestExecProcReuseIfNewer")
+		src := NewFileSource(net, "src", dir+"/in.txt")
+		cp := NewExecProc(net, "cp", "cat {i:in} > {o:out}; echo ran")
+		cp.In("in").From(src.Out())
+		cp.SetOut("out", "{i:in}.copy")
+		cp.ReuseExisting = true
+		cp.ReuseIfNewer = true
+		out := NewPacketCollector(net, "out")
+		out.In().From(cp.Stdout())
+		net.Run()
+		runs += len(out.Data)
+	}
+
+	run()
+	run()
+	if runs != 1 {
+		t.Errorf("Task with older inputs was executed again (runs: %d)", runs)
+	}
+	future := time.Now().Add(time.Hour)
+	Check(os.Chtimes(dir+"/in.txt", future, future))
+	run()
+	if runs != 2 {
+		t.Errorf("Task with newer inputs was not executed again (runs: %d)", runs)
+	}
+}
+
+func TestExecProcReuseVersion(t *testing.T) {
+	initTestLogs()
+	dir := t.TempDir()
+	Check(os.WriteFile(dir+"/in.txt", []byte("a"), 0644))
+
+	runs := 0
+	run := func(version string) {
+		net := NewNetwork("TestExecProcReuseVersion")
+		src := NewFileSource(net, "src", dir+"/in.txt")
+		cp := NewExecProc(net, "cp", "cat {i:in} > {o:out}; echo ran")
+		cp.In("in").From(src.Out())
+		cp.SetOut("out", "{i:in}.copy")
+		cp.ReuseExisting = true
+		cp.Version = version
+		out := NewPacketCollector(net, "out")
+		out.In().From(cp.Stdout())
+		net.Run()
+		runs += len(out.Data)
+	}
+
+	run("1.2.0")
+	run("1.2.3")
+	if runs != 1 {
+		t.Errorf("Task was executed again after a patch version change (runs: %d)", runs)
+	}
+	run("1.3.0")
+	if runs != 2 {
+		t.Errorf("Task was not executed again after a minor version change (runs: %d)", runs)
+	}
+	ai, err := ReadAuditFile(dir + "/in.txt.copy")
+	Check(err)
+	assertEqualValues(t, "1.3.0", ai.ComponentVersion)
+}
+
+func TestExecProcWorkspace(t *testing.T) {
+	initTestLogs()
+	net := NewNetwork("TestExecProcWorkspace")
+
+	dir := t.TempDir()
+	Check(os.WriteFile(dir+"/in.txt", []byte("a"), 0644))
+	src := NewFileSource(net, "src", dir+"/in.txt")
+	cp := NewExecProc(net, "cp", "cat {i:in} > {o:out}; touch junk.txt")
+	cp.In("in").From(src.Out())
+	cp.SetOut("out", "{i:in}.copy")
+	cp.WorkspaceDir = dir + "/ws"
+
+	out := NewPacketCollector(net, "out")
+	out.In().From(cp.Out("out"))
+
+	net.Run()
+
+	assertEqualValues(t, []any{NewFileIP(dir + "/in.txt.copy")}, out.Data)
+	assertEqualValues(t, "a", string(NewFileIP(dir+"/in.txt.copy").Read()))
+	entries, err := os.ReadDir(dir + "/ws")
+	Check(err)
+	if len(entries) != 0 {
+		t.Errorf("Workspace was not cleaned up, contains: %v", entries)
+	}
+}
+
+func TestExecProcFileSetOutputs(t *testing.T) {
+	initTestLogs()
+	net := NewNetwork("TestExecProcFileSetOutputs")
+
+	dir := t.TempDir()
+	write := NewExecProc(net, "write", "echo data > {o:data}; echo index > {o:data}.idx; mkdir {o:dir}; echo a > {o:dir}/a.txt")
+	write.SetOut("data", dir+"/data.txt")
+	write.SetOutCompanions("data", ".idx")
+	write.SetOut("dir", dir+"/outdir")
+	write.SetOutDir("dir")
+
+	dataOut := NewPacketCollector(net, "data")
+	dataOut.In().From(write.Out("data"))
+
+	net.Run()
+
+	assertEqualValues(t, []any{NewFileSetIP(dir+"/data.txt", dir+"/data.txt.idx")}, dataOut.Data)
+	assertEqualValues(t, "index\n", string(NewFileIP(dir+"/data.txt.idx").Read()))
+	assertEqualValues(t, []string{dir + "/outdir/a.txt"}, NewDirIP(dir+"/outdir").Paths())
+}
+
+func TestExecProcAuditRunID(t *testing.T) {
+	initTestLogs()
+	net := NewNetwork("TestExecProcAuditRunID")
+	net.SetRunID("run-1")
+
+	dir := t.TempDir()
+	write := NewExecProc(net, "write", "echo a > {o:out}")
+	write.SetOut("out", dir+"/out.txt")
+	out := NewPacketCollector(net, "out")
+	out.In().From(write.Out("out"))
+
+	net.Run()
+
+	ai, err := ReadAuditFile(dir + "/out.txt")
+	Check(err)
+	assertEqualValues(t, "run-1", ai.RunID)
+}
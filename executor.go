@@ -0,0 +1,278 @@
+package flowbase
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Executor is the interface for backends running the commands of tasks, such
+// as for ExecProc. Execute runs the command of task t, calls stdout for every
+// line written to stdout, and blocks until the command has finished. If the
+// command fails, an *ExecError is returned, while other errors indicate a
+// failure in the execution backend itself.
+type Executor interface {
+	Execute(t *ExecTask, stdout func(line string)) error
+}
+
+// ------------------------------------------------------------------------
+// LocalExecutor
+// ------------------------------------------------------------------------
+
+// LocalExecutor runs commands with bash on the local machine
+type LocalExecutor struct{}
+
+// NewLocalExecutor returns a new LocalExecutor
+func NewLocalExecutor() *LocalExecutor {
+	return &LocalExecutor{}
+}
+
+// Execute runs the command of task t locally, and records the resources it
+// used in the audit info of the task
+func (e *LocalExecutor) Execute(t *ExecTask, stdout func(line string)) error {
+	cmd := exec.Command("bash", "-c", t.Command)
+	err := runCmdStreaming(cmd, t.Command, stdout)
+	recordUsage(t, cmd)
+	return err
+}
+
+// maxLineSize is the maximum size of the lines read from the output of
+// commands
+const maxLineSize = 64 * 1024 * 1024
+
+// newLineScanner returns a scanner of the lines of r, with room for lines of
+// up to maxLineSize bytes
+func newLineScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	return scanner
+}
+
+// runCmdStreaming runs cmd, calling stdout for each line it writes to stdout,
+// and returns an *ExecError for command failures
+func runCmdStreaming(cmd *exec.Cmd, command string, stdout func(line string)) error {
+	return runCmdStreamingStderr(cmd, command, stdout, nil)
+}
+
+// runCmdStreamingStderr is like runCmdStreaming, but also calls stderr, if
+// not nil, for each line cmd writes to stderr
+func runCmdStreamingStderr(cmd *exec.Cmd, command string, stdout func(line string), stderr func(line string)) error {
+	stderrBuf := &bytes.Buffer{}
+	stdoutPipe, err := cmd.StdoutPipe()
+	if err != nil {
+		return errWrap(err, "Could not get stdout of command")
+	}
+	var stderrPipe io.Reader
+	if stderr == nil {
+		cmd.Stderr = stderrBuf
+	} else if stderrPipe, err = cmd.StderrPipe(); err != nil {
+		return errWrap(err, "Could not get stderr of command")
+	}
+	if err := cmd.Start(); err != nil {
+		return errWrapf(err, "Could not start command (%s)", command)
+	}
+	var stderrErr error
+	stderrDone := make(chan struct{})
+	go func() {
+		defer close(stderrDone)
+		if stderrPipe == nil {
+			return
+		}
+		scanner := newLineScanner(stderrPipe)
+		for scanner.Scan() {
+			stderrBuf.WriteString(scanner.Text() + "\n")
+			stderr(scanner.Text())
+		}
+		if stderrErr = scanner.Err(); stderrErr != nil {
+			io.Copy(io.Discard, stderrPipe)
+		}
+	}()
+	scanner := newLineScanner(stdoutPipe)
+	for scanner.Scan() {
+		stdout(scanner.Text())
+	}
+	// The pipes need to be read to the end before waiting, also when a line
+	// is too long to be scanned
+	stdoutErr := scanner.Err()
+	if stdoutErr != nil {
+		io.Copy(io.Discard, stdoutPipe)
+	}
+	<-stderrDone
+	if err := cmd.Wait(); err != nil {
+		execErr := &ExecError{Command: command, ExitCode: -1, Stderr: stderrBuf.String()}
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			execErr.ExitCode = exitErr.ExitCode()
+		}
+		return execErr
+	}
+	if stdoutErr != nil {
+		return errWrapf(stdoutErr, "Could not read stdout of command (%s)", command)
+	}
+	if stderrErr != nil {
+		return errWrapf(stderrErr, "Could not read stderr of command (%s)", command)
+	}
+	return nil
+}
+
+// ------------------------------------------------------------------------
+// DockerExecutor
+// ------------------------------------------------------------------------
+
+// DockerExecutor runs commands inside Docker containers, with the current
+// working directory mounted, and used as working directory, in the container
+type DockerExecutor struct {
+	Image string
+	// ExtraArgs are added to the docker run command, such as "--gpus=all" or
+	// extra volume mounts
+	ExtraArgs []string
+}
+
+// NewDockerExecutor returns a new DockerExecutor, running commands in
+// containers of image
+func NewDockerExecutor(image string) *DockerExecutor {
+	return &DockerExecutor{Image: image}
+}
+
+// Execute runs the command of task t in a Docker container
+func (e *DockerExecutor) Execute(t *ExecTask, stdout func(line string)) error {
+	wd, err := os.Getwd()
+	if err != nil {
+		return errWrap(err, "Could not get working directory")
+	}
+	args := []string{"run", "--rm", "-v", wd + ":" + wd, "-w", wd}
+	if t.Resources.Cores > 0 {
+		args = append(args, "--cpus", strconv.Itoa(t.Resources.Cores))
+	}
+	if t.Resources.MemoryMB > 0 {
+		args = append(args, "--memory", strconv.Itoa(t.Resources.MemoryMB)+"m")
+	}
+	args = append(args, e.ExtraArgs...)
+	args = append(args, e.Image, "bash", "-c", t.Command)
+	return runCmdStreaming(exec.Command("docker", args...), t.Command, stdout)
+}
+
+// ------------------------------------------------------------------------
+// KubernetesExecutor
+// ------------------------------------------------------------------------
+
+// KubernetesExecutor runs commands as pods in a Kubernetes cluster, using the
+// kubectl command line tool. Input and output files need to be on storage
+// accessible from the pods, such as a volume configured with Overrides.
+type KubernetesExecutor struct {
+	Image     string
+	Namespace string
+	// Overrides is an optional JSON pod spec override, passed to kubectl run,
+	// which is applied as a strategic merge patch, together with the resource
+	// requests of tasks
+	Overrides string
+}
+
+var allowedK8sNameChars = regexp.MustCompile("[^a-zA-Z0-9-]")
+
+// maxK8sNameLen is the maximum length of pod names, as DNS-1123 labels
+const maxK8sNameLen = 63
+
+// k8sPodName returns the name of the pod for the task with the ID id, of the
+// process processName, as a valid DNS-1123 label. The process name is
+// shortened to fit, so that the ID, which keeps pod names unique, is kept.
+func k8sPodName(processName string, id string) string {
+	sanitize := func(s string) string {
+		return strings.Trim(strings.ToLower(allowedK8sNameChars.ReplaceAllString(s, "-")), "-")
+	}
+	id = sanitize(id)
+	name := sanitize(processName)
+	if maxLen := maxK8sNameLen - len(id) - 1; maxLen < 0 {
+		name = ""
+	} else if len(name) > maxLen {
+		name = strings.TrimRight(name[:maxLen], "-")
+	}
+	if name != "" {
+		name += "-" + id
+	} else {
+		name = id
+	}
+	if len(name) > maxK8sNameLen {
+		name = name[:maxK8sNameLen]
+	}
+	return strings.Trim(name, "-")
+}
+
+// NewKubernetesExecutor returns a new KubernetesExecutor, running commands in
+// pods with containers of image
+func NewKubernetesExecutor(image string) *KubernetesExecutor {
+	return &KubernetesExecutor{Image: image}
+}
+
+// Execute runs the command of task t in a Kubernetes pod
+func (e *KubernetesExecutor) Execute(t *ExecTask, stdout func(line string)) error {
+	podName := k8sPodName(t.ProcessName, t.AuditInfo.ID)
+	args := []string{"run", podName, "--rm", "-i", "--quiet", "--restart=Never", "--image=" + e.Image}
+	if e.Namespace != "" {
+		args = append(args, "--namespace="+e.Namespace)
+	}
+	overrides, err := k8sOverrides(e.Overrides, podName, t.Resources)
+	if err != nil {
+		return err
+	}
+	if overrides != "" {
+		args = append(args, "--overrides="+overrides, "--override-type=strategic")
+	}
+	args = append(args, "--", "bash", "-c", t.Command)
+	return runCmdStreaming(exec.Command("kubectl", args...), t.Command, stdout)
+}
+
+// k8sOverrides returns the pod spec override for kubectl run, with the
+// resource requests of res added to the override overrides, for the container
+// of the pod podName, which is named after the pod. kubectl run has no flags
+// for resource requests.
+func k8sOverrides(overrides string, podName string, res Resources) (string, error) {
+	requests := map[string]string{}
+	if res.Cores > 0 {
+		requests["cpu"] = strconv.Itoa(res.Cores)
+	}
+	if res.MemoryMB > 0 {
+		requests["memory"] = strconv.Itoa(res.MemoryMB) + "Mi"
+	}
+	if len(requests) == 0 {
+		return overrides, nil
+	}
+	pod := map[string]any{}
+	if overrides != "" {
+		if err := json.Unmarshal([]byte(overrides), &pod); err != nil {
+			return "", errWrap(err, "Could not parse Kubernetes pod overrides")
+		}
+	}
+	spec, ok := pod["spec"].(map[string]any)
+	if !ok {
+		spec = map[string]any{}
+		pod["spec"] = spec
+	}
+	containers, _ := spec["containers"].([]any)
+	var container map[string]any
+	for _, c := range containers {
+		if c, ok := c.(map[string]any); ok && c["name"] == podName {
+			container = c
+		}
+	}
+	if container == nil {
+		container = map[string]any{"name": podName}
+		spec["containers"] = append(containers, container)
+	}
+	resources, ok := container["resources"].(map[string]any)
+	if !ok {
+		resources = map[string]any{}
+		container["resources"] = resources
+	}
+	resources["requests"] = requests
+	podJSON, err := json.Marshal(pod)
+	if err != nil {
+		return "", err
+	}
+	return string(podJSON), nil
+}
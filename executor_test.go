@@ -0,0 +1,153 @@
+package flowbase
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runFakeContainerTool returns a stand-in for the container tool name, such
+// as docker, which runs the "bash -c" command at the end of its arguments
+// locally, in the working directory given with -w, if any
+func runFakeContainerTool(name string) func(args []string) int {
+	return func(args []string) int {
+		logFakeCall(name, args)
+		cmd := exec.Command(args[len(args)-3], args[len(args)-2:]...)
+		cmd.Dir = flagValue(args, "-w")
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		cmd.Run()
+		return cmd.ProcessState.ExitCode()
+	}
+}
+
+// executeFailingTask runs a task printing hello, and exiting with exit code
+// 3, with the executor e, and checks that the output and the exit code are
+// passed on
+func executeFailingTask(t *testing.T, e Executor) *ExecTask {
+	task := &ExecTask{
+		ProcessName: "align/bwa",
+		Command:     "echo hello; echo failing >&2; exit 3",
+		Resources:   Resources{Cores: 2, MemoryMB: 512},
+		AuditInfo:   NewAuditInfo(),
+	}
+	lines := []string{}
+	err := e.Execute(task, func(line string) { lines = append(lines, line) })
+
+	assertEqualValues(t, []string{"hello"}, lines)
+	var execErr *ExecError
+	if !errors.As(err, &execErr) {
+		t.Fatalf("Expected an ExecError, got: %v", err)
+	}
+	assertEqualValues(t, 3, execErr.ExitCode)
+	assertEqualValues(t, "failing\n", execErr.Stderr)
+	return task
+}
+
+func TestDockerExecutor(t *testing.T) {
+	initTestLogs()
+	stateDir := installFakeTool(t, "docker")
+
+	e := NewDockerExecutor("ubuntu:22.04")
+	e.ExtraArgs = []string{"--gpus=all"}
+	task := executeFailingTask(t, e)
+
+	wd, err := os.Getwd()
+	Check(err)
+	assertEqualValues(t, [][]string{{"run", "--rm",
+		"-v", wd + ":" + wd,
+		"-w", wd,
+		"--cpus", "2",
+		"--memory", "512m",
+		"--gpus=all",
+		"ubuntu:22.04", "bash", "-c", task.Command}}, fakeToolCalls(stateDir, "docker"))
+}
+
+func TestKubernetesExecutor(t *testing.T) {
+	initTestLogs()
+	stateDir := installFakeTool(t, "kubectl")
+
+	e := NewKubernetesExecutor("ubuntu:22.04")
+	e.Namespace = "pipelines"
+	e.Overrides = `{"spec": {"volumes": [{"name": "data"}]}}`
+	task := executeFailingTask(t, e)
+
+	// Resources are requested in the overrides, as kubectl run has no flags
+	// for them
+	podName := "align-bwa-" + task.AuditInfo.ID
+	assertEqualValues(t, [][]string{{"run", podName,
+		"--rm", "-i", "--quiet", "--restart=Never",
+		"--image=ubuntu:22.04",
+		"--namespace=pipelines",
+		`--overrides={"spec":{"containers":[{"name":"` + podName + `","resources":{"requests":{"cpu":"2","memory":"512Mi"}}}],"volumes":[{"name":"data"}]}}`,
+		"--override-type=strategic",
+		"--", "bash", "-c", task.Command}}, fakeToolCalls(stateDir, "kubectl"))
+}
+
+func TestK8sPodName(t *testing.T) {
+	id := "abcdefghij0123456789"
+	longName := strings.Repeat("align_reads_", 6)
+	for _, tc := range []struct {
+		processName string
+		expected    string
+	}{
+		{"align_bwa", "align-bwa-" + id},
+		{"_Align.BWA_", "align-bwa-" + id},
+		// Shortened to 42 characters, ending with an underscore
+		{longName, "align-reads-align-reads-align-reads-align-" + id},
+		{longName[:41] + "_x", "align-reads-align-reads-align-reads-align-" + id},
+		{"__", id},
+	} {
+		name := k8sPodName(tc.processName, id)
+		assertEqualValues(t, tc.expected, name)
+		if len(name) > 63 || strings.HasPrefix(name, "-") || strings.HasSuffix(name, "-") {
+			t.Errorf("Invalid pod name for %s: %s", tc.processName, name)
+		}
+	}
+	// IDs too long to fit are cut too
+	name := k8sPodName(longName, strings.Repeat("x", 62)+"-y")
+	assertEqualValues(t, strings.Repeat("x", 62), name)
+}
+
+func TestK8sOverrides(t *testing.T) {
+	overrides := `{"spec": {"volumes": [{"name": "data"}]}}`
+	// Without resource requests, the overrides are passed on as they are
+	got, err := k8sOverrides(overrides, "pod", Resources{})
+	assertEqualValues(t, nil, err)
+	assertEqualValues(t, overrides, got)
+
+	got, err = k8sOverrides(`{"spec": {"containers": [{"name": "pod", "env": [{"name": "A", "value": "1"}]}]}}`, "pod", Resources{Cores: 4})
+	assertEqualValues(t, nil, err)
+	assertEqualValues(t, `{"spec":{"containers":[{"env":[{"name":"A","value":"1"}],"name":"pod","resources":{"requests":{"cpu":"4"}}}]}}`, got)
+
+	if _, err := k8sOverrides(`{"spec":`, "pod", Resources{Cores: 4}); err == nil {
+		t.Errorf("Expected an error for invalid overrides")
+	}
+}
+
+func TestLocalExecutorLongLines(t *testing.T) {
+	initTestLogs()
+	task := &ExecTask{
+		ProcessName: "long",
+		Command:     "head -c 200000 /dev/zero | tr '\\0' a; echo; seq 1 100000",
+		AuditInfo:   NewAuditInfo(),
+	}
+	lines := []string{}
+	done := make(chan error)
+	go func() {
+		done <- NewLocalExecutor().Execute(task, func(line string) { lines = append(lines, line) })
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Command with long line failed: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Command with long line did not finish")
+	}
+	assertEqualValues(t, 100001, len(lines))
+	assertEqualValues(t, 200000, len(lines[0]))
+	assertEqualValues(t, "100000", lines[len(lines)-1])
+}
@@ -0,0 +1,80 @@
+package flowbase
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/flowbase/flowbase/template"
+)
+
+// fakeTools are stand-ins for the command line tools run by executors, by
+// name, which are run by the test binary itself (see installFakeTool)
+var fakeTools = map[string]func(args []string) int{
+	"apptainer":   runFakeContainerTool("apptainer"),
+	"aws":         runFakeAWS,
+	"docker":      runFakeContainerTool("docker"),
+	"kubectl":     runFakeContainerTool("kubectl"),
+	"singularity": runFakeContainerTool("singularity"),
+}
+
+func TestMain(m *testing.M) {
+	if name := os.Getenv("FLOWBASE_FAKE_TOOL"); name != "" {
+		os.Exit(fakeTools[name](os.Args[1:]))
+	}
+	os.Exit(m.Run())
+}
+
+// installFakeTool puts a command named name, which runs the fake tool of the
+// same name, first in PATH for the duration of the test, and returns the
+// directory the fake tool keeps its state in
+func installFakeTool(t *testing.T, name string) string {
+	testBin, err := os.Executable()
+	Check(err)
+	binDir, stateDir := t.TempDir(), t.TempDir()
+	script := fmt.Sprintf("#!/bin/sh\nFLOWBASE_FAKE_TOOL=%s exec %s \"$@\"\n", name, template.ShellQuote(testBin))
+	Check(os.WriteFile(filepath.Join(binDir, name), []byte(script), 0755))
+	t.Setenv("PATH", binDir+":"+os.Getenv("PATH"))
+	t.Setenv("FLOWBASE_FAKE_TOOL_DIR", stateDir)
+	return stateDir
+}
+
+// logFakeCall appends the arguments of a call to the fake tool name to its
+// log in the state directory, as a JSON array per line
+func logFakeCall(name string, args []string) {
+	f, err := os.OpenFile(filepath.Join(os.Getenv("FLOWBASE_FAKE_TOOL_DIR"), name+".log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	Check(err)
+	defer f.Close()
+	Check(json.NewEncoder(f).Encode(args))
+}
+
+// fakeToolCalls returns the arguments of the calls to the fake tool name
+// logged in the state directory stateDir
+func fakeToolCalls(stateDir string, name string) [][]string {
+	calls := [][]string{}
+	f, err := os.Open(filepath.Join(stateDir, name+".log"))
+	if os.IsNotExist(err) {
+		return calls
+	}
+	Check(err)
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	for dec.More() {
+		args := []string{}
+		Check(dec.Decode(&args))
+		calls = append(calls, args)
+	}
+	return calls
+}
+
+// flagValue returns the value following the flag name in args
+func flagValue(args []string, name string) string {
+	for i, arg := range args[:len(args)-1] {
+		if arg == name {
+			return args[i+1]
+		}
+	}
+	return ""
+}
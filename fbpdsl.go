@@ -0,0 +1,450 @@
+package flowbase
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ------------------------------------------------------------------------
+// .fbp DSL
+// ------------------------------------------------------------------------
+
+// FBP returns the graph in the classical .fbp DSL format, such as:
+//
+//	# @name mynetwork
+//	INPORT=cat.in:IN
+//	cat(ExecProc:command=cat {i:in} > {o:out})
+//	upper(ExecProc:command=tr a-z A-Z < {i:in} > {o:out})
+//	cat out -> in upper
+//	'data.txt' -> in cat
+//
+// Processes are declared on lines of their own, with their component names,
+// versions and metadata, followed by the connections and IIPs. IIP data other than
+// strings is written with fmt.Sprint, so the JSON format should be used to
+// preserve it exactly.
+func (g *Graph) FBP() string {
+	var sb strings.Builder
+	if g.Properties.Name != "" {
+		fmt.Fprintf(&sb, "# @name %s\n", g.Properties.Name)
+	}
+	for _, name := range sortedKeys(g.InPorts) {
+		ep := g.InPorts[name]
+		fmt.Fprintf(&sb, "INPORT=%s.%s:%s\n", ep.Process, ep.Port, name)
+	}
+	for _, name := range sortedKeys(g.OutPorts) {
+		ep := g.OutPorts[name]
+		fmt.Fprintf(&sb, "OUTPORT=%s.%s:%s\n", ep.Process, ep.Port, name)
+	}
+	for _, name := range sortedKeys(g.Processes) {
+		proc := g.Processes[name]
+		spec := proc.Component
+		if proc.Version != "" {
+			spec += "@" + proc.Version
+		}
+		if len(proc.Metadata) > 0 {
+			fields := []string{}
+			for _, key := range sortedKeys(proc.Metadata) {
+				fields = append(fields, key+"="+fbpMetadataValue(fmt.Sprint(proc.Metadata[key])))
+			}
+			spec += ":" + strings.Join(fields, ",")
+		}
+		fmt.Fprintf(&sb, "%s(%s)\n", name, spec)
+	}
+	for _, conn := range g.Connections {
+		if conn.Src == nil {
+			fmt.Fprintf(&sb, "%s -> %s %s\n", fbpQuote(fmt.Sprint(conn.Data)), conn.Tgt.Port, conn.Tgt.Process)
+			continue
+		}
+		fmt.Fprintf(&sb, "%s %s -> %s %s\n", conn.Src.Process, conn.Src.Port, conn.Tgt.Port, conn.Tgt.Process)
+	}
+	return sb.String()
+}
+
+// ParseFBP parses a graph in the .fbp DSL format, which consists of lines
+// with connections, such as:
+//
+//	'data.txt' -> in cat(ExecProc:command=cat {i:in} > {o:out})
+//	cat out -> in upper(ExecProc:command=tr a-z A-Z < {i:in} > {o:out})
+//
+// where each process needs its component name, and metadata, given in
+// parentheses at least once. Commas and parentheses in metadata values are
+// taken literally when quoted with single or double quotes, or escaped with a
+// backslash, and a value quoted as a whole with double quotes, such as
+// command="cut -d, -f1 {i:in} > {o:out}", is unquoted, with \" and \\ as
+// escapes for " and \. Lines can also export ports, such as
+// INPORT=cat.in:IN, and comments starting with #. The network name can be
+// given with a "# @name <name>" annotation.
+func ParseFBP(src string) (*Graph, error) {
+	g := newGraph()
+	referenced := map[string]int{}
+	for i, line := range strings.Split(src, "\n") {
+		lineNo := i + 1
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "#"):
+			if comment := strings.TrimSpace(strings.TrimPrefix(line, "#")); strings.HasPrefix(comment, "@name ") {
+				g.Properties.Name = strings.TrimSpace(strings.TrimPrefix(comment, "@name "))
+			}
+			continue
+		case strings.HasPrefix(line, "INPORT=") || strings.HasPrefix(line, "OUTPORT="):
+			if err := g.parseFBPExport(line); err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNo, err)
+			}
+			continue
+		}
+		tokens, err := tokenizeFBP(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+		procs, err := g.parseFBPStatement(tokens)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+		for _, proc := range procs {
+			if _, ok := referenced[proc]; !ok {
+				referenced[proc] = lineNo
+			}
+		}
+	}
+	for proc, lineNo := range referenced {
+		if _, ok := g.Processes[proc]; !ok {
+			return nil, fmt.Errorf("line %d: no component given for process %s", lineNo, proc)
+		}
+	}
+	return g, nil
+}
+
+// parseFBPExport parses an INPORT=proc.port:NAME or OUTPORT=proc.port:NAME
+// line
+func (g *Graph) parseFBPExport(line string) error {
+	kind, spec, _ := strings.Cut(line, "=")
+	ref, name, ok := cutLast(spec, ":")
+	if !ok {
+		return fmt.Errorf("missing exported port name in: %s", line)
+	}
+	proc, port, ok := cutLast(ref, ".")
+	if !ok {
+		return fmt.Errorf("expected process.port in: %s", line)
+	}
+	if kind == "INPORT" {
+		g.InPorts[name] = GraphEndpoint{Process: proc, Port: port}
+	} else {
+		g.OutPorts[name] = GraphEndpoint{Process: proc, Port: port}
+	}
+	return nil
+}
+
+// parseFBPStatement parses the tokens of one statement, which is either a
+// process declaration, such as "proc(Component)", or a chain of connections,
+// adding its processes and connections to the graph. It returns the names of
+// the processes in the statement.
+func (g *Graph) parseFBPStatement(tokens []fbpToken) ([]string, error) {
+	pos := 0
+	next := func(kind fbpTokenKind) (string, bool) {
+		if pos < len(tokens) && tokens[pos].kind == kind {
+			pos++
+			return tokens[pos-1].text, true
+		}
+		return "", false
+	}
+	procs := []string{}
+	// ref reads a process name, and its optional component spec
+	ref := func() (string, error) {
+		name, ok := next(fbpWord)
+		if !ok {
+			return "", fmt.Errorf("expected process name at token %d", pos+1)
+		}
+		procs = append(procs, name)
+		if spec, ok := next(fbpComponent); ok {
+			if err := g.declareFBPProcess(name, spec); err != nil {
+				return "", err
+			}
+		}
+		return name, nil
+	}
+
+	var src *GraphEndpoint
+	var data any
+	if iip, ok := next(fbpIIP); ok {
+		data = iip
+	} else {
+		proc, err := ref()
+		if err != nil {
+			return nil, err
+		}
+		if pos == len(tokens) {
+			return procs, nil
+		}
+		port, ok := next(fbpWord)
+		if !ok {
+			return nil, fmt.Errorf("expected out-port name after process %s", proc)
+		}
+		src = &GraphEndpoint{Process: proc, Port: port}
+	}
+	for {
+		if _, ok := next(fbpArrow); !ok {
+			return nil, fmt.Errorf("expected -> at token %d", pos+1)
+		}
+		inPort, ok := next(fbpWord)
+		if !ok {
+			return nil, fmt.Errorf("expected in-port name after ->")
+		}
+		proc, err := ref()
+		if err != nil {
+			return nil, err
+		}
+		g.Connections = append(g.Connections, GraphConnection{Data: data, Src: src, Tgt: GraphEndpoint{Process: proc, Port: inPort}})
+		if pos == len(tokens) {
+			return procs, nil
+		}
+		outPort, ok := next(fbpWord)
+		if !ok {
+			return nil, fmt.Errorf("expected out-port name after process %s", proc)
+		}
+		src, data = &GraphEndpoint{Process: proc, Port: outPort}, nil
+	}
+}
+
+// fbpMetadataKey matches the start of a key=value metadata field
+var fbpMetadataKey = regexp.MustCompile(`^\s*[\w.-]+=`)
+
+// declareFBPProcess adds the process name to the graph, with the component,
+// version and metadata in spec, which has the form
+// Component@version:key=value,key=value, where the version and metadata are
+// optional
+func (g *Graph) declareFBPProcess(name string, spec string) error {
+	component, meta, _ := strings.Cut(spec, ":")
+	component, version, _ := strings.Cut(component, "@")
+	proc := GraphProcess{Component: strings.TrimSpace(component), Version: strings.TrimSpace(version)}
+	if meta != "" {
+		proc.Metadata = map[string]any{}
+		fields, err := splitFBPFields(meta)
+		if err != nil {
+			return fmt.Errorf("invalid metadata for process %s: %v", name, err)
+		}
+		for _, field := range fields {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				return fmt.Errorf("expected key=value metadata for process %s: %s", name, field)
+			}
+			proc.Metadata[strings.TrimSpace(key)] = unquoteFBPValue(value)
+		}
+	}
+	if existing, ok := g.Processes[name]; ok && existing.Component != proc.Component {
+		return fmt.Errorf("process %s declared with different components: %s and %s", name, existing.Component, proc.Component)
+	}
+	g.Processes[name] = proc
+	return nil
+}
+
+// splitFBPFields splits the metadata meta into key=value fields, at the
+// commas which are not quoted, escaped or in parentheses. Values can also
+// contain such commas unquoted, such as in "sort -t, -k2", so fields not
+// starting with key= are part of the value of the previous field.
+func splitFBPFields(meta string) ([]string, error) {
+	parts := []string{}
+	start, depth := 0, 0
+	err := fbpScan(meta, func(i int) bool {
+		switch meta[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, meta[start:i])
+				start = i + 1
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	parts = append(parts, meta[start:])
+	fields := []string{}
+	for _, part := range parts {
+		if len(fields) > 0 && !fbpMetadataKey.MatchString(part) {
+			fields[len(fields)-1] += "," + part
+			continue
+		}
+		fields = append(fields, part)
+	}
+	return fields, nil
+}
+
+// unquoteFBPValue returns the metadata value, without its double quotes and
+// escapes if it is quoted as a whole, or else unchanged
+func unquoteFBPValue(value string) string {
+	if len(value) < 2 || value[0] != '"' {
+		return value
+	}
+	var sb strings.Builder
+	for i := 1; i < len(value); i++ {
+		switch c := value[i]; {
+		case c == '\\' && i+1 < len(value):
+			i++
+			sb.WriteByte(value[i])
+		case c == '"':
+			if i != len(value)-1 {
+				// Only the start of the value is quoted
+				return value
+			}
+			return sb.String()
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return value
+}
+
+// fbpMetadataValue returns value as written in a component spec, which is
+// quoted with double quotes if it would not be parsed back as it is
+func fbpMetadataValue(value string) string {
+	needsQuotes := strings.HasPrefix(value, `"`) || strings.HasSuffix(value, `\`)
+	depth := 0
+	err := fbpScan(value, func(i int) bool {
+		switch value[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			needsQuotes = needsQuotes || depth < 0
+		case ',':
+			needsQuotes = true
+		}
+		return !needsQuotes
+	})
+	if !needsQuotes && err == nil && depth == 0 {
+		return value
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// ------------------------------------------------------------------------
+// Tokenizer
+// ------------------------------------------------------------------------
+
+type fbpTokenKind int
+
+const (
+	fbpWord fbpTokenKind = iota
+	fbpIIP
+	fbpArrow
+	fbpComponent
+)
+
+type fbpToken struct {
+	kind fbpTokenKind
+	text string
+}
+
+// tokenizeFBP splits a line of the .fbp DSL into words (process and port
+// names), quoted IIPs, arrows and parenthesized component specs
+func tokenizeFBP(line string) ([]fbpToken, error) {
+	tokens := []fbpToken{}
+	for i := 0; i < len(line); {
+		switch c := line[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case strings.HasPrefix(line[i:], "->"):
+			tokens = append(tokens, fbpToken{fbpArrow, "->"})
+			i += 2
+		case c == '\'':
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(line) && line[j] != '\''; j++ {
+				if line[j] == '\\' && j+1 < len(line) && line[j+1] == '\'' {
+					j++
+				}
+				sb.WriteByte(line[j])
+			}
+			if j == len(line) {
+				return nil, fmt.Errorf("unterminated IIP: %s", line[i:])
+			}
+			tokens = append(tokens, fbpToken{fbpIIP, sb.String()})
+			i = j + 1
+		case c == '(':
+			// Parentheses in quotes, or escaped, do not count
+			depth, end := 0, -1
+			err := fbpScan(line[i:], func(j int) bool {
+				switch line[i+j] {
+				case '(':
+					depth++
+				case ')':
+					depth--
+					if depth == 0 {
+						end = i + j
+						return false
+					}
+				}
+				return true
+			})
+			if err != nil {
+				return nil, err
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("unbalanced parentheses: %s", line[i:])
+			}
+			tokens = append(tokens, fbpToken{fbpComponent, line[i+1 : end]})
+			i = end + 1
+		default:
+			j := i
+			for ; j < len(line); j++ {
+				if c := line[j]; c == ' ' || c == '\t' || c == '(' || c == '\'' || strings.HasPrefix(line[j:], "->") {
+					break
+				}
+			}
+			tokens = append(tokens, fbpToken{fbpWord, line[i:j]})
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+// fbpScan steps through s, calling fn with the index of each byte which is
+// neither quoted nor escaped with a backslash, until fn returns false. As in
+// shells, quotes are single or double quotes, and backslashes only escape
+// within double quotes, and outside quotes.
+func fbpScan(s string, fn func(i int) bool) error {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\\':
+			i++
+		case c == '\'' || c == '"':
+			quote = c
+		default:
+			if !fn(i) {
+				return nil
+			}
+		}
+	}
+	if quote != 0 {
+		return fmt.Errorf("unterminated quote: %s", s)
+	}
+	return nil
+}
+
+// fbpQuote quotes s as an IIP in the .fbp DSL
+func fbpQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
+
+// cutLast slices s around the last instance of sep
+func cutLast(s string, sep string) (before string, after string, found bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return s, "", false
+	}
+	return s[:i], s[i+len(sep):], true
+}
@@ -0,0 +1,304 @@
+package flowbase
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ------------------------------------------------------------------------
+// Graph
+// ------------------------------------------------------------------------
+
+// Graph is a description of the topology of a network, in the NoFlo JSON
+// graph format, which can also be written and read in the classical .fbp DSL
+// format, for interchange with other flow-based programming tools. Processes
+// are instantiated from their component names via the component registry
+// (see RegisterComponent).
+type Graph struct {
+	Properties  GraphProperties          `json:"properties"`
+	InPorts     map[string]GraphEndpoint `json:"inports,omitempty"`
+	OutPorts    map[string]GraphEndpoint `json:"outports,omitempty"`
+	Processes   map[string]GraphProcess  `json:"processes"`
+	Connections []GraphConnection        `json:"connections"`
+}
+
+// GraphProperties contains the properties of a graph
+type GraphProperties struct {
+	Name string `json:"name,omitempty"`
+}
+
+// GraphEndpoint refers to a port of a process in a graph
+type GraphEndpoint struct {
+	Process string `json:"process"`
+	Port    string `json:"port"`
+}
+
+// GraphProcess describes a process in a graph, by its component name, and the
+// metadata needed to create it. Version is the version of the component the
+// graph was made with, if known, which the registered component needs to be
+// compatible with (see VersionCompatible).
+type GraphProcess struct {
+	Component string         `json:"component"`
+	Version   string         `json:"version,omitempty"`
+	Metadata  map[string]any `json:"metadata,omitempty"`
+}
+
+// GraphConnection describes a connection from the out-port Src to the in-port
+// Tgt, or, if Src is nil, an initial information packet (IIP) with the data
+// Data, which is sent to Tgt when the network starts
+type GraphConnection struct {
+	Data any            `json:"data,omitempty"`
+	Src  *GraphEndpoint `json:"src,omitempty"`
+	Tgt  GraphEndpoint  `json:"tgt"`
+}
+
+func newGraph() *Graph {
+	return &Graph{
+		InPorts:     map[string]GraphEndpoint{},
+		OutPorts:    map[string]GraphEndpoint{},
+		Processes:   map[string]GraphProcess{},
+		Connections: []GraphConnection{},
+	}
+}
+
+// Graph returns a description of the topology of the network, with component
+// names and metadata of the processes (see ComponentNamer and
+// ComponentMetadataer), for writing to graph files
+func (net *Network) Graph() *Graph {
+	g := newGraph()
+	g.Properties.Name = net.Name()
+	// IIPs are listed first, followed by the connections between processes
+	iips := []GraphConnection{}
+	for _, node := range net.ProcsSorted() {
+		if iip, ok := node.(*IIPSource); ok {
+			for _, ipt := range sortedValues(iip.Out().ConnectedPorts()) {
+				iips = append(iips, GraphConnection{Data: iip.data, Tgt: endpointOf(ipt.Process(), ipt.Name())})
+			}
+			continue
+		}
+		proc := GraphProcess{Component: componentName(node), Version: InfoOf(node).Version}
+		if metadata := componentMetadata(node); len(metadata) > 0 {
+			proc.Metadata = map[string]any{}
+			for key, value := range metadata {
+				proc.Metadata[key] = value
+			}
+		}
+		g.Processes[node.Name()] = proc
+
+		for _, opt := range sortedValues(node.OutPorts()) {
+			for _, ipt := range sortedValues(opt.ConnectedPorts()) {
+				if ipt.Process() == nil || net.procs[ipt.Process().Name()] != ipt.Process() {
+					continue
+				}
+				src := endpointOf(node, opt.Name())
+				g.Connections = append(g.Connections, GraphConnection{Src: &src, Tgt: endpointOf(ipt.Process(), ipt.Name())})
+			}
+		}
+	}
+	g.Connections = append(iips, g.Connections...)
+	for name, ipt := range net.exportedInPorts {
+		g.InPorts[name] = endpointOf(ipt.Process(), ipt.Name())
+	}
+	for name, opt := range net.exportedOutPorts {
+		g.OutPorts[name] = endpointOf(opt.Process(), opt.Name())
+	}
+	return g
+}
+
+// NewNetworkFromGraph returns a new network with the processes,
+// connections, IIPs and exported ports described by g. Processes are created
+// with the factories registered for their component names.
+func NewNetworkFromGraph(g *Graph) (*Network, error) {
+	name := g.Properties.Name
+	if name == "" {
+		name = "network"
+	}
+	net := NewNetwork(name)
+
+	for _, procName := range sortedKeys(g.Processes) {
+		proc := g.Processes[procName]
+		factory, ok := LookupComponent(proc.Component)
+		if !ok {
+			return nil, fmt.Errorf("no component registered with name %s, for process %s", proc.Component, procName)
+		}
+		if info, ok := LookupComponentInfo(proc.Component); ok && proc.Version != "" && info.Version != "" {
+			if !VersionCompatible(proc.Version, info.Version) {
+				return nil, fmt.Errorf("version %s of component %s is not compatible with version %s, used by process %s", info.Version, proc.Component, proc.Version, procName)
+			}
+		}
+		metadata := map[string]string{}
+		for key, value := range proc.Metadata {
+			metadata[key] = fmt.Sprint(value)
+		}
+		if _, err := factory(net, procName, metadata); err != nil {
+			return nil, errWrapf(err, "Could not create process %s", procName)
+		}
+	}
+
+	for i, conn := range g.Connections {
+		ipt, err := net.graphInPort(conn.Tgt)
+		if err != nil {
+			return nil, err
+		}
+		if conn.Src == nil {
+			iip := NewIIPSource(net, fmt.Sprintf("iip%d_%s_%s", i, conn.Tgt.Process, conn.Tgt.Port), conn.Data)
+			ipt.From(iip.Out())
+			continue
+		}
+		opt, err := net.graphOutPort(*conn.Src)
+		if err != nil {
+			return nil, err
+		}
+		ipt.From(opt)
+	}
+
+	for name, ep := range g.InPorts {
+		ipt, err := net.graphInPort(ep)
+		if err != nil {
+			return nil, err
+		}
+		net.ExportInPort(name, ipt)
+	}
+	for name, ep := range g.OutPorts {
+		opt, err := net.graphOutPort(ep)
+		if err != nil {
+			return nil, err
+		}
+		net.ExportOutPort(name, opt)
+	}
+	return net, nil
+}
+
+// graphInPort returns the in-port referred to by ep
+func (net *Network) graphInPort(ep GraphEndpoint) (*InPort, error) {
+	node, ok := net.procs[ep.Process]
+	if !ok {
+		return nil, fmt.Errorf("no such process in graph: %s", ep.Process)
+	}
+	ipt, ok := node.InPorts()[ep.Port]
+	if !ok {
+		return nil, fmt.Errorf("no such in-port in graph: %s.%s", ep.Process, ep.Port)
+	}
+	return ipt, nil
+}
+
+// graphOutPort returns the out-port referred to by ep
+func (net *Network) graphOutPort(ep GraphEndpoint) (*OutPort, error) {
+	node, ok := net.procs[ep.Process]
+	if !ok {
+		return nil, fmt.Errorf("no such process in graph: %s", ep.Process)
+	}
+	opt, ok := node.OutPorts()[ep.Port]
+	if !ok {
+		return nil, fmt.Errorf("no such out-port in graph: %s.%s", ep.Process, ep.Port)
+	}
+	return opt, nil
+}
+
+// ------------------------------------------------------------------------
+// Graph files
+// ------------------------------------------------------------------------
+
+// ReadGraphFile reads a graph from the file at path, in the .fbp DSL format if
+// it has the extension .fbp, and in the NoFlo JSON format otherwise
+func ReadGraphFile(path string) (*Graph, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errWrapf(err, "Could not read graph file %s", path)
+	}
+	if filepath.Ext(path) == ".fbp" {
+		return ParseFBP(string(data))
+	}
+	g := newGraph()
+	if err := json.Unmarshal(data, g); err != nil {
+		return nil, errWrapf(err, "Could not parse graph file %s", path)
+	}
+	return g, nil
+}
+
+// WriteFile writes the graph to the file at path, in the .fbp DSL format if
+// it has the extension .fbp, and in the NoFlo JSON format otherwise
+func (g *Graph) WriteFile(path string) error {
+	var data []byte
+	if filepath.Ext(path) == ".fbp" {
+		data = []byte(g.FBP())
+	} else {
+		var err error
+		data, err = json.MarshalIndent(g, "", "  ")
+		if err != nil {
+			return errWrap(err, "Could not JSON-encode graph")
+		}
+	}
+	createDirs(path)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return errWrapf(err, "Could not write graph file %s", path)
+	}
+	return nil
+}
+
+// ------------------------------------------------------------------------
+// IIP source
+// ------------------------------------------------------------------------
+
+// IIPSource sends an initial information packet (IIP), such as a
+// configuration value or file name from a graph file, and then closes its
+// out-port
+type IIPSource struct {
+	BaseProcess
+	data any
+}
+
+// NewIIPSource returns a new IIPSource, sending data. If data is a *Packet,
+// it is sent as is, with its tags.
+func NewIIPSource(net *Network, name string, data any) *IIPSource {
+	p := &IIPSource{
+		BaseProcess: NewBaseProcess(net, name),
+		data:        data,
+	}
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// Out returns the out-port, on which the IIP is sent
+func (p *IIPSource) Out() *OutPort { return p.OutPort("out") }
+
+// Run sends the IIP
+func (p *IIPSource) Run() {
+	defer p.CloseOutPorts()
+	if ip, ok := p.data.(*Packet); ok {
+		p.Out().SendPacket(ip)
+		return
+	}
+	p.Out().Send(p.data)
+}
+
+// ------------------------------------------------------------------------
+// Helper functions
+// ------------------------------------------------------------------------
+
+func endpointOf(node Node, port string) GraphEndpoint {
+	return GraphEndpoint{Process: node.Name(), Port: port}
+}
+
+// sortedKeys returns the keys of m, sorted
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sortedValues returns the values of m, sorted by their keys
+func sortedValues[V any](m map[string]V) []V {
+	values := make([]V, 0, len(m))
+	for _, key := range sortedKeys(m) {
+		values = append(values, m[key])
+	}
+	return values
+}
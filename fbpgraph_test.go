@@ -0,0 +1,149 @@
+package flowbase
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testFBP = `# @name fbptest
+OUTPORT=upper.out:OUT
+'fbp_in.txt' -> in cat(ExecProc:command=cat {i:in} > {o:out})
+cat out -> in upper(ExecProc:command=tr a-z A-Z < {i:in} > {o:out}) out -> in count(ExecProc:command=wc -c < {i:in} > {o:out})
+`
+
+func TestParseFBP(t *testing.T) {
+	g, err := ParseFBP(testFBP)
+	if err != nil {
+		t.Fatalf("Could not parse graph: %v", err)
+	}
+	assertEqualValues(t, "fbptest", g.Properties.Name)
+	assertEqualValues(t, 3, len(g.Processes))
+	assertEqualValues(t, "tr a-z A-Z < {i:in} > {o:out}", g.Processes["upper"].Metadata["command"])
+	assertEqualValues(t, 3, len(g.Connections))
+	assertEqualValues(t, "fbp_in.txt", g.Connections[0].Data)
+	assertEqualValues(t, GraphEndpoint{Process: "upper", Port: "out"}, *g.Connections[2].Src)
+	assertEqualValues(t, GraphEndpoint{Process: "upper", Port: "out"}, g.OutPorts["OUT"])
+
+	if _, err := ParseFBP("a out -> in b(ExecProc:command=cat {i:in})"); err == nil {
+		t.Error("Expected an error for a process without component")
+	}
+}
+
+func TestParseFBPQuotedMetadata(t *testing.T) {
+	for _, tc := range []struct {
+		spec    string
+		command string
+		version any
+	}{
+		{`ExecProc:command="cut -d, -f1,2 {i:in} > {o:out}",version=1.0.0`, "cut -d, -f1,2 {i:in} > {o:out}", "1.0.0"},
+		{`ExecProc:command=awk -F, '{print $1,x=$2}' {i:in} > {o:out},version=1.0.0`, "awk -F, '{print $1,x=$2}' {i:in} > {o:out}", "1.0.0"},
+		{`ExecProc:command="echo \"a,b\" \\ > {o:out}"`, `echo "a,b" \ > {o:out}`, nil},
+		{`ExecProc:command=sort -t, -k2 {i:in} > {o:out}`, "sort -t, -k2 {i:in} > {o:out}", nil},
+	} {
+		g, err := ParseFBP("p(" + tc.spec + ")")
+		if err != nil {
+			t.Fatalf("Could not parse %s: %v", tc.spec, err)
+		}
+		assertEqualValues(t, tc.command, g.Processes["p"].Metadata["command"])
+		assertEqualValues(t, tc.version, g.Processes["p"].Metadata["version"])
+	}
+}
+
+func TestParseFBPParentheses(t *testing.T) {
+	for _, tc := range []struct {
+		line    string
+		command string
+	}{
+		{`p(ExecProc:command=echo $(cat {i:in}) > {o:out}) out -> in q(Sink)`, "echo $(cat {i:in}) > {o:out}"},
+		{`p(ExecProc:command=find . \( -name {p:name} \) > {o:out}) out -> in q(Sink)`, `find . \( -name {p:name} \) > {o:out}`},
+		{`p(ExecProc:command=echo ')' > {o:out}) out -> in q(Sink)`, "echo ')' > {o:out}"},
+		{`p(ExecProc:command="echo (" > {o:out}) out -> in q(Sink)`, `"echo (" > {o:out}`},
+	} {
+		g, err := ParseFBP(tc.line)
+		if err != nil {
+			t.Fatalf("Could not parse %s: %v", tc.line, err)
+		}
+		assertEqualValues(t, tc.command, g.Processes["p"].Metadata["command"])
+		assertEqualValues(t, "Sink", g.Processes["q"].Component)
+	}
+
+	for _, line := range []string{
+		`p(ExecProc:command=echo 'a > {o:out})`,
+		`p(ExecProc:command=echo \) > {o:out}`,
+	} {
+		if _, err := ParseFBP(line); err == nil {
+			t.Errorf("Expected an error for %s", line)
+		}
+	}
+}
+
+func TestFBPQuotesMetadata(t *testing.T) {
+	g := newGraph()
+	for name, command := range map[string]string{
+		"plain":   "tr a-z A-Z < {i:in} > {o:out}",
+		"comma":   "cut -d, -f1,x=2 {i:in} > {o:out}",
+		"paren":   "echo ) > {o:out}",
+		"quote":   `"a" don't \`,
+		"shell":   "awk '{print ($1)}' {i:in} > {o:out}",
+		"escaped": `echo \( > {o:out}`,
+	} {
+		g.Processes[name] = GraphProcess{Component: "ExecProc", Metadata: map[string]any{"command": command}}
+	}
+	fbp := g.FBP()
+	assertEqualValues(t, true, strings.Contains(fbp, "plain(ExecProc:command=tr a-z A-Z < {i:in} > {o:out})"))
+
+	read, err := ParseFBP(fbp)
+	if err != nil {
+		t.Fatalf("Could not parse %s: %v", fbp, err)
+	}
+	for name, proc := range g.Processes {
+		assertEqualValues(t, proc.Metadata, read.Processes[name].Metadata)
+	}
+}
+
+func TestGraphRoundTrip(t *testing.T) {
+	initTestLogs()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "fbp_in.txt"), []byte("hej\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Outputs get default paths in the working directory
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	g, err := ParseFBP(testFBP)
+	if err != nil {
+		t.Fatalf("Could not parse graph: %v", err)
+	}
+	net, err := NewNetworkFromGraph(g)
+	if err != nil {
+		t.Fatalf("Could not build network: %v", err)
+	}
+
+	exported := net.Graph()
+	assertEqualValues(t, g.Connections, exported.Connections)
+	assertEqualValues(t, Version, exported.Processes["cat"].Version)
+	for _, ext := range []string{".fbp", ".json"} {
+		path := filepath.Join(dir, "graph"+ext)
+		if err := exported.WriteFile(path); err != nil {
+			t.Fatal(err)
+		}
+		read, err := ReadGraphFile(path)
+		if err != nil {
+			t.Fatalf("Could not read %s: %v", path, err)
+		}
+		assertEqualValues(t, exported, read)
+	}
+
+	out := NewPacketCollector(net, "collector")
+	out.In().From(net.ExportedOutPort("OUT"))
+	net.Run()
+
+	assertEqualValues(t, 1, len(out.Data))
+	assertEqualValues(t, "HEJ\n", string(out.Data[0].(*FileIP).Read()))
+}
@@ -0,0 +1,126 @@
+package flowbase
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+)
+
+// FileIP is packet data representing a file on disk, with helper methods for
+// reading and writing its contents. Compressed files are transparently
+// decompressed when read, and compressed when written, with the compression
+// format detected from the file extension, unless set with SetCompression.
+type FileIP struct {
+	path        string
+	compression Compression
+}
+
+// NewFileIP returns a new FileIP for the file at path
+func NewFileIP(path string) *FileIP {
+	return &FileIP{path: path}
+}
+
+// Path returns the path of the file
+func (f *FileIP) Path() string {
+	return f.path
+}
+
+// SetCompression sets the compression format of the file, overriding the one
+// detected from the file extension
+func (f *FileIP) SetCompression(compression Compression) {
+	f.compression = compression
+}
+
+// Compression returns the compression format of the file
+func (f *FileIP) Compression() Compression {
+	if f.compression == CompressionAuto {
+		return detectCompression(f.path)
+	}
+	return f.compression
+}
+
+// String returns the path of the file, so that FileIPs can be used directly
+// in command placeholders and format strings
+func (f *FileIP) String() string {
+	return f.path
+}
+
+// Exists tells whether the file exists
+func (f *FileIP) Exists() bool {
+	_, err := os.Stat(f.path)
+	return err == nil
+}
+
+// Size returns the size of the file on disk in bytes, which for compressed
+// files is the compressed size
+func (f *FileIP) Size() int64 {
+	stat, err := os.Stat(f.path)
+	if err != nil {
+		f.Failf("Could not stat file: %v", err)
+	}
+	return stat.Size()
+}
+
+// Open opens the file for reading, decompressing its content if compressed
+func (f *FileIP) Open() io.ReadCloser {
+	file, err := os.Open(f.path)
+	if err != nil {
+		f.Failf("Could not open file: %v", err)
+	}
+	r, err := decompressReader(file, f.Compression())
+	if err != nil {
+		f.Failf("Could not open file for decompression: %v", err)
+	}
+	return r
+}
+
+// Create creates (or truncates) the file, and opens it for writing,
+// compressing the written content if the file is compressed
+func (f *FileIP) Create() io.WriteCloser {
+	createDirs(f.path)
+	file, err := os.Create(f.path)
+	if err != nil {
+		f.Failf("Could not create file: %v", err)
+	}
+	w, err := compressWriter(file, f.Compression())
+	if err != nil {
+		f.Failf("Could not open file for compression: %v", err)
+	}
+	return w
+}
+
+// Read reads the whole (decompressed) content of the file
+func (f *FileIP) Read() []byte {
+	r := f.Open()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		f.Failf("Could not read file: %v", err)
+	}
+	if err := r.Close(); err != nil {
+		f.Failf("Could not read file: %v", err)
+	}
+	return data
+}
+
+// Write writes data to the file, replacing any existing content
+func (f *FileIP) Write(data []byte) {
+	w := f.Create()
+	if _, err := w.Write(data); err != nil {
+		f.Failf("Could not write file: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		f.Failf("Could not write file: %v", err)
+	}
+}
+
+// UnMarshalJSON reads the file and decodes its JSON content into v
+func (f *FileIP) UnMarshalJSON(v any) {
+	if err := json.Unmarshal(f.Read(), v); err != nil {
+		f.Failf("Could not unmarshal JSON content: %v", err)
+	}
+}
+
+// Failf fails with a message that includes the file path
+func (f *FileIP) Failf(msg string, parts ...interface{}) {
+	Failf("[FileIP:%s] "+msg, append([]interface{}{f.path}, parts...)...)
+}
@@ -0,0 +1,120 @@
+package flowbase
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FileSetIP is packet data representing a group of related files, such as a
+// data file with its index files, or a directory, which moves through the
+// network as one unit
+type FileSetIP struct {
+	dir   string
+	paths []string
+}
+
+// NewFileSetIP returns a new FileSetIP for the files at paths. The first path
+// is considered the main file of the set.
+func NewFileSetIP(paths ...string) *FileSetIP {
+	return &FileSetIP{paths: paths}
+}
+
+// NewDirIP returns a new FileSetIP for the directory dir, with all regular
+// files under it as members
+func NewDirIP(dir string) *FileSetIP {
+	return &FileSetIP{dir: dir}
+}
+
+// Path returns the directory of a directory file set, or the path of the main
+// file otherwise
+func (s *FileSetIP) Path() string {
+	if s.dir != "" {
+		return s.dir
+	}
+	if len(s.paths) == 0 {
+		return ""
+	}
+	return s.paths[0]
+}
+
+// IsDir tells whether the file set is a directory
+func (s *FileSetIP) IsDir() bool {
+	return s.dir != ""
+}
+
+// String returns the directory of a directory file set, or the paths of the
+// files separated by spaces, so that file sets can be used directly in
+// command placeholders
+func (s *FileSetIP) String() string {
+	if s.dir != "" {
+		return s.dir
+	}
+	return strings.Join(s.paths, " ")
+}
+
+// Paths returns the paths of the member files. For directories, these are
+// all regular files under the directory, in lexical order.
+func (s *FileSetIP) Paths() []string {
+	if s.dir == "" {
+		return s.paths
+	}
+	paths := []string{}
+	err := filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		Failf("[FileSetIP:%s] Could not list files: %v", s.dir, err)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Files returns FileIPs for the member files
+func (s *FileSetIP) Files() []*FileIP {
+	fips := []*FileIP{}
+	for _, path := range s.Paths() {
+		fips = append(fips, NewFileIP(path))
+	}
+	return fips
+}
+
+// Exists tells whether the directory, or all the member files, exist
+func (s *FileSetIP) Exists() bool {
+	if s.dir != "" {
+		fi, err := os.Stat(s.dir)
+		return err == nil && fi.IsDir()
+	}
+	for _, path := range s.paths {
+		if _, err := os.Stat(path); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// finalizeFileSet finalizes all the files at tempPaths to the corresponding
+// finalPaths with finalizer, as one unit: if any of them can not be
+// finalized, the already finalized ones are removed again, so that the set
+// never shows up only partly at its final paths.
+func finalizeFileSet(finalizer Finalizer, tempPaths []string, finalPaths []string) error {
+	for i := range tempPaths {
+		if err := finalizer.Finalize(tempPaths[i], finalPaths[i]); err != nil {
+			for j := 0; j < i; j++ {
+				if tempPaths[j] != finalPaths[j] {
+					os.RemoveAll(finalPaths[j])
+				}
+			}
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package flowbase
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// Finalizer is a strategy for how output files of tasks are written and then
+// finalized, so that half-written files never show up at their final paths.
+// TempPath returns the path a task should write to, for an output with the
+// final path finalPath, and Finalize moves it in place after the task has
+// finished successfully.
+type Finalizer interface {
+	TempPath(finalPath string, taskID string) string
+	Finalize(tempPath string, finalPath string) error
+}
+
+// ------------------------------------------------------------------------
+// Finalization strategies
+// ------------------------------------------------------------------------
+
+// FinalizeNone writes outputs directly to their final paths
+type FinalizeNone struct{}
+
+// TempPath returns finalPath itself
+func (f FinalizeNone) TempPath(finalPath string, taskID string) string {
+	return finalPath
+}
+
+// Finalize does nothing
+func (f FinalizeNone) Finalize(tempPath string, finalPath string) error {
+	return nil
+}
+
+// FinalizeRenameInDir writes outputs to a hidden temporary file in the same
+// directory as the final path, and renames it in place, which is atomic on
+// POSIX file systems. This is the default strategy.
+type FinalizeRenameInDir struct{}
+
+// TempPath returns a hidden path in the directory of finalPath
+func (f FinalizeRenameInDir) TempPath(finalPath string, taskID string) string {
+	return filepath.Join(filepath.Dir(finalPath), ".flowbase.tmp."+taskID+"."+filepath.Base(finalPath))
+}
+
+// Finalize renames tempPath to finalPath
+func (f FinalizeRenameInDir) Finalize(tempPath string, finalPath string) error {
+	return moveFile(tempPath, finalPath, false)
+}
+
+// FinalizeTempDir writes outputs to a separate temporary directory, such as
+// on a fast local disk, and moves them to their final paths. When the
+// directories are on different file systems, the file is copied to a
+// temporary path next to the final one and then renamed, so that the final
+// path still appears atomically.
+type FinalizeTempDir struct {
+	Dir string
+}
+
+// TempPath returns a path under the temporary directory
+func (f FinalizeTempDir) TempPath(finalPath string, taskID string) string {
+	return filepath.Join(f.Dir, taskID, finalPath)
+}
+
+// Finalize moves tempPath to finalPath
+func (f FinalizeTempDir) Finalize(tempPath string, finalPath string) error {
+	if err := moveFile(tempPath, finalPath, false); err != nil {
+		return err
+	}
+	os.Remove(filepath.Dir(tempPath))
+	return nil
+}
+
+// FinalizeFsync works like FinalizeRenameInDir, but also flushes the file and
+// the directory entry to disk, so that finalized outputs survive crashes.
+// It also waits for the final path to become visible, which is needed on
+// some shared (network) file systems with attribute caching.
+type FinalizeFsync struct{}
+
+// TempPath returns a hidden path in the directory of finalPath
+func (f FinalizeFsync) TempPath(finalPath string, taskID string) string {
+	return FinalizeRenameInDir{}.TempPath(finalPath, taskID)
+}
+
+// Finalize fsyncs tempPath, renames it to finalPath, and fsyncs the directory
+func (f FinalizeFsync) Finalize(tempPath string, finalPath string) error {
+	if err := moveFile(tempPath, finalPath, true); err != nil {
+		return err
+	}
+	if tempPath == finalPath {
+		return nil
+	}
+	return waitForFile(finalPath, 10)
+}
+
+// ------------------------------------------------------------------------
+// Helper functions
+// ------------------------------------------------------------------------
+
+// moveFile moves the file src to dst, falling back to copying when they are
+// on different file systems. If sync is true, data and directory entries are
+// flushed to disk.
+func moveFile(src string, dst string, sync bool) error {
+	if src == dst {
+		return nil
+	}
+	createDirs(dst)
+	if sync {
+		if err := syncPath(src); err != nil {
+			return err
+		}
+	}
+	err := os.Rename(src, dst)
+	var linkErr *os.LinkError
+	if err != nil && errors.As(err, &linkErr) && linkErr.Err == syscall.EXDEV {
+		err = copyThenRename(src, dst)
+	}
+	if err != nil {
+		return errWrapf(err, "Could not move %s to %s", src, dst)
+	}
+	if sync {
+		return syncPath(filepath.Dir(dst))
+	}
+	return nil
+}
+
+// copyThenRename copies src to a temporary file next to dst, and renames it
+// to dst, so that dst appears atomically, and then removes src
+func copyThenRename(src string, dst string) error {
+	part := dst + ".flowbase.part"
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(part)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(part, dst); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// syncPath flushes the file or directory at path to disk
+func syncPath(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := f.Sync(); err != nil {
+		return errWrapf(err, "Could not sync %s", path)
+	}
+	return nil
+}
+
+// waitForFile waits, with exponential backoff, for path to be visible
+func waitForFile(path string, retries int) error {
+	wait := 10 * time.Millisecond
+	for i := 0; ; i++ {
+		_, err := os.Stat(path)
+		if err == nil || i >= retries {
+			return err
+		}
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
@@ -0,0 +1,104 @@
+package flowbasetest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// ContractTimeout is how long a network run may take in the component
+// contract tests, before the component is considered hanging
+var ContractTimeout = 10 * time.Second
+
+// ComponentFactory creates the component under test, in the network net
+type ComponentFactory func(net *fb.Network) fb.Node
+
+// RunComponentContract checks that the component created by factory gives
+// the standard guarantees of FlowBase components, as subtests of t:
+//
+//   - EmptyInput: it finishes when all its in-ports are closed without any
+//     packets having been sent, which is also how networks are shut down
+//   - ClosesOutPorts: it closes all its out-ports before finishing
+//   - DrainsInPorts: it receives all packets sent on its in-ports, for each
+//     of sampleInputs, which maps in-port names to values to send on them
+//
+// Each check runs the component in a new network, with in-ports not
+// connected by the factory fed by FeedPort, and out-ports collected by
+// CollectPort, and also fails on goroutines leaked by the run. Only the
+// goroutines started by the run are checked (see TestNetwork.Run), so the
+// checks can be run in parallel tests.
+func RunComponentContract(t *testing.T, factory ComponentFactory, sampleInputs ...map[string][]any) {
+	t.Run("EmptyInput", func(t *testing.T) {
+		proc := runContractNetwork(t, factory, nil)
+		checkOutPortsClosed(t, proc)
+	})
+	t.Run("ClosesOutPorts", func(t *testing.T) {
+		proc := runContractNetwork(t, factory, firstOrNil(sampleInputs))
+		checkOutPortsClosed(t, proc)
+	})
+	for i, inputs := range sampleInputs {
+		t.Run(fmt.Sprintf("DrainsInPorts/%d", i), func(t *testing.T) {
+			proc := runContractNetwork(t, factory, inputs)
+			for name, inp := range proc.InPorts() {
+				if n := len(inp.Chan); n > 0 {
+					t.Errorf("In-port %s was not drained: %d packets left", name, n)
+				}
+			}
+		})
+	}
+}
+
+// runContractNetwork creates the component with factory in a new network,
+// feeds inputs to its in-ports, and runs the network, failing the test if
+// it does not finish within ContractTimeout
+func runContractNetwork(t *testing.T, factory ComponentFactory, inputs map[string][]any) fb.Node {
+	t.Helper()
+	net := NewTestNetwork(t)
+	proc := factory(net.Network)
+	for name, inp := range proc.InPorts() {
+		if !inp.Ready() {
+			FeedPort(inp, inputs[name]...)
+		}
+	}
+	for name := range inputs {
+		if _, ok := proc.InPorts()[name]; !ok {
+			t.Fatalf("Sample input for non-existing in-port %s", name)
+		}
+	}
+	for _, outp := range proc.OutPorts() {
+		if !outp.Ready() {
+			CollectPort[any](outp)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		net.Run()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(ContractTimeout):
+		t.Fatalf("Network with component %s did not finish within %v", proc.Name(), ContractTimeout)
+	}
+	return proc
+}
+
+// checkOutPortsClosed checks that all out-ports of proc have been closed
+func checkOutPortsClosed(t *testing.T, proc fb.Node) {
+	t.Helper()
+	for name, outp := range proc.OutPorts() {
+		if len(outp.ConnectedPorts()) > 0 {
+			t.Errorf("Out-port %s was not closed", name)
+		}
+	}
+}
+
+func firstOrNil(inputs []map[string][]any) map[string][]any {
+	if len(inputs) == 0 {
+		return nil
+	}
+	return inputs[0]
+}
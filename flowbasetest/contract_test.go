@@ -0,0 +1,18 @@
+package flowbasetest
+
+import (
+	"testing"
+	"time"
+
+	fb "github.com/flowbase/flowbase"
+	"github.com/flowbase/flowbase/components"
+)
+
+func TestComponentContract(t *testing.T) {
+	// Parallel tests start goroutines while the networks of the contract
+	// tests run, which are not to be reported as leaked by them
+	t.Parallel()
+	RunComponentContract(t, func(net *fb.Network) fb.Node {
+		return components.NewDelay(net, "delay", time.Millisecond)
+	}, map[string][]any{"in": {1, 2, 3}})
+}
@@ -0,0 +1,302 @@
+// Package flowbasetest contains helpers for testing FlowBase components and
+// networks: feeding values into in-ports, collecting what is sent on
+// out-ports, scripted mock processes, golden-file assertions for audit
+// output, detection of goroutines leaked by a network run, and a contract
+// test suite for components.
+package flowbasetest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+var update = flag.Bool("update", false, "Update golden files, instead of comparing with them")
+
+// LeakTimeout is how long TestNetwork.Run waits for goroutines started by the
+// network to exit, before reporting them as leaked
+var LeakTimeout = 2 * time.Second
+
+// ------------------------------------------------------------------------
+// TestNetwork
+// ------------------------------------------------------------------------
+
+// TestNetwork is a Network bound to a test, which reports goroutines leaked
+// by a run as test errors
+type TestNetwork struct {
+	*fb.Network
+	t *testing.T
+}
+
+// NewTestNetwork returns a new TestNetwork, named after the test t
+func NewTestNetwork(t *testing.T) *TestNetwork {
+	t.Helper()
+	if fb.Warning == nil {
+		fb.InitLogWarning()
+	}
+	return &TestNetwork{
+		Network: fb.NewNetwork(t.Name()),
+		t:       t,
+	}
+}
+
+// Run runs the network, and then checks that all goroutines started by the
+// run have exited. The goroutines of the run are told apart from those of
+// other tests, such as parallel ones, by a pprof label, which goroutines
+// inherit from the goroutine starting them.
+func (net *TestNetwork) Run() {
+	net.t.Helper()
+	runLabel := fmt.Sprintf("%s#%d", net.Name(), atomic.AddInt64(&runCount, 1))
+	pprof.Do(context.Background(), pprof.Labels(runLabelKey, runLabel), func(context.Context) {
+		net.Network.Run()
+	})
+	// With profiling enabled, processes are run with the labels of the
+	// network instead
+	labels := []string{labelString(runLabelKey, runLabel), labelString("network", net.Name())}
+	deadline := time.Now().Add(LeakTimeout)
+	for {
+		count, stacks := labeledGoroutines(labels...)
+		if count == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			net.t.Errorf("%d goroutine(s) leaked by network run:\n%s", count, stacks)
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// runLabelKey is the key of the pprof label of the goroutines of a run of a
+// TestNetwork
+const runLabelKey = "flowbasetest.run"
+
+// runCount is the number of runs of test networks, which makes the labels of
+// their goroutines unique
+var runCount int64
+
+// labelString returns the pprof label key=value, as written in goroutine
+// profiles
+func labelString(key string, value string) string {
+	return fmt.Sprintf("%q:%q", key, value)
+}
+
+// labeledGoroutines returns the number of goroutines with any of labels,
+// formatted with labelString, along with their stacks
+func labeledGoroutines(labels ...string) (int, string) {
+	buf := &bytes.Buffer{}
+	pprof.Lookup("goroutine").WriteTo(buf, 1)
+	count, stacks := 0, []string{}
+	// The profile lists the goroutines grouped by stack and labels, as
+	// records separated by empty lines, starting with their count
+	for _, record := range strings.Split(buf.String(), "\n\n") {
+		lines := strings.SplitN(record, "\n", 3)
+		if len(lines) < 2 || !strings.HasPrefix(lines[1], "# labels: ") || !containsAny(lines[1], labels) {
+			continue
+		}
+		n, err := strconv.Atoi(strings.Fields(lines[0])[0])
+		if err != nil {
+			continue
+		}
+		count += n
+		stacks = append(stacks, record)
+	}
+	return count, strings.Join(stacks, "\n\n")
+}
+
+func containsAny(s string, substrs []string) bool {
+	for _, substr := range substrs {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ------------------------------------------------------------------------
+// Feeding and collecting
+// ------------------------------------------------------------------------
+
+// networkOf returns the network of the process of the port pt
+func networkOf(proc fb.Node) *fb.Network {
+	p, ok := proc.(interface{ Network() *fb.Network })
+	if !ok || p.Network() == nil {
+		fb.Failf("Process %s is not part of a network", proc.Name())
+	}
+	return p.Network()
+}
+
+// feeder sends a fixed list of values on its out-port
+type feeder struct {
+	fb.BaseProcess
+	values []any
+}
+
+func (p *feeder) Run() {
+	defer p.CloseOutPorts()
+	for _, v := range p.values {
+		sendValue(p.OutPort("out"), v)
+	}
+}
+
+// sendValue sends v on the out-port pt, as is if it is a *Packet
+func sendValue(pt *fb.OutPort, v any) {
+	if ip, ok := v.(*fb.Packet); ok {
+		pt.SendPacket(ip)
+		return
+	}
+	pt.Send(v)
+}
+
+// FeedPort connects a source process to the in-port port, which sends values
+// on it, when the network is run. Values that are *Packets are sent as is,
+// so that tags and audit info can be included.
+func FeedPort(port *fb.InPort, values ...any) {
+	net := networkOf(port.Process())
+	p := &feeder{
+		BaseProcess: fb.NewBaseProcess(net, "feed_"+strings.ReplaceAll(port.FullName(), ".", "_")),
+		values:      values,
+	}
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	port.From(p.OutPort("out"))
+}
+
+// Collected gives access to the packets collected from an out-port by
+// CollectPort, after the network has been run
+type Collected[T any] struct {
+	mx  sync.Mutex
+	ips []*fb.Packet
+}
+
+// Values returns the data of the collected packets, as type T. It fails if
+// any data is not of type T.
+func (c *Collected[T]) Values() []T {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	values := []T{}
+	for _, ip := range c.ips {
+		v, ok := ip.Data().(T)
+		if !ok {
+			var zero T
+			fb.Failf("Collected packet data (%v) is of type %T, not %T", ip.Data(), ip.Data(), zero)
+		}
+		values = append(values, v)
+	}
+	return values
+}
+
+// Packets returns the collected packets
+func (c *Collected[T]) Packets() []*fb.Packet {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return append([]*fb.Packet{}, c.ips...)
+}
+
+// collector receives packets on its in-port
+type collector[T any] struct {
+	fb.BaseProcess
+	collected *Collected[T]
+}
+
+func (p *collector[T]) Run() {
+	for ip, ok := p.InPort("in").RecvOK(); ok; ip, ok = p.InPort("in").RecvOK() {
+		p.collected.mx.Lock()
+		p.collected.ips = append(p.collected.ips, ip)
+		p.collected.mx.Unlock()
+	}
+}
+
+// CollectPort connects a process to the out-port port, which collects all
+// packets sent on it, when the network is run. The data of the packets is
+// available via Values on the returned Collected, after the run.
+func CollectPort[T any](port *fb.OutPort) *Collected[T] {
+	net := networkOf(port.Process())
+	p := &collector[T]{
+		BaseProcess: fb.NewBaseProcess(net, "collect_"+strings.ReplaceAll(port.FullName(), ".", "_")),
+		collected:   &Collected[T]{},
+	}
+	p.InitInPort(p, "in")
+	net.AddProc(p)
+	port.To(p.InPort("in"))
+	return p.collected
+}
+
+// ------------------------------------------------------------------------
+// Golden files
+// ------------------------------------------------------------------------
+
+// AssertGolden compares actual with the content of the golden file at path,
+// reporting a test error if they differ. When tests are run with -update,
+// the golden file is written with actual instead.
+func AssertGolden(t *testing.T, path string, actual []byte) {
+	t.Helper()
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatalf("Could not create directory for golden file: %v", err)
+		}
+		if err := os.WriteFile(path, actual, 0644); err != nil {
+			t.Fatalf("Could not write golden file: %v", err)
+		}
+		return
+	}
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Could not read golden file (run with -update to create it): %v", err)
+	}
+	if !bytes.Equal(expected, actual) {
+		t.Errorf("Content does not match golden file %s (run with -update to update it)\nExpected:\n%s\nActual:\n%s", path, expected, actual)
+	}
+}
+
+// AssertAuditGolden compares the audit info ai, including its upstream audit
+// infos, with the golden file at path (see AssertGolden). Fields that vary
+// between runs, such as IDs, run IDs and times, are normalized first.
+func AssertAuditGolden(t *testing.T, path string, ai *fb.AuditInfo) {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "    ")
+	if err := enc.Encode(normalizeAuditInfo(ai)); err != nil {
+		t.Fatalf("Could not marshal audit info: %v", err)
+	}
+	AssertGolden(t, path, buf.Bytes())
+}
+
+// tempPathPtn matches the task specific part of temporary output paths
+var tempPathPtn = regexp.MustCompile(`\.flowbase\.tmp\.[a-z0-9]+\.`)
+
+// normalizeAuditInfo returns a copy of ai with the fields that vary between
+// runs replaced with fixed values
+func normalizeAuditInfo(ai *fb.AuditInfo) *fb.AuditInfo {
+	norm := *ai
+	norm.ID = "<id>"
+	norm.Command = tempPathPtn.ReplaceAllString(norm.Command, ".flowbase.tmp.<id>.")
+	if norm.RunID != "" {
+		norm.RunID = "<runid>"
+	}
+	norm.StartTime = time.Time{}
+	norm.FinishTime = time.Time{}
+	norm.ExecTimeNS = 0
+	norm.ResourceUsage = nil
+	norm.Upstream = make(map[string]*fb.AuditInfo)
+	for k, up := range ai.Upstream {
+		norm.Upstream[k] = normalizeAuditInfo(up)
+	}
+	return &norm
+}
@@ -0,0 +1,89 @@
+package flowbasetest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"testing"
+	"time"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+func TestFeedAndCollect(t *testing.T) {
+	net := NewTestNetwork(t)
+	// Write outputs to relative paths in a temporary directory, so that the
+	// audit info is the same between runs
+	wd, err := os.Getwd()
+	fb.Check(err)
+	fb.Check(os.Chdir(t.TempDir()))
+	defer os.Chdir(wd)
+
+	proc := fb.NewExecProc(net.Network, "upper", "echo {i:in} | tr a-z A-Z | tee {o:out}")
+	proc.SetOut("out", "{i:in}.txt")
+	FeedPort(proc.In("in"), "abc", "def")
+	stdout := CollectPort[string](proc.Stdout())
+	outs := CollectPort[*fb.FileIP](proc.Out("out"))
+
+	net.Run()
+
+	lines := stdout.Values()
+	if len(lines) != 2 || lines[0] != "ABC" || lines[1] != "DEF" {
+		t.Errorf("Wrong lines collected: %v", lines)
+	}
+	AssertAuditGolden(t, filepath.Join(wd, "testdata", "upper.audit.golden.json"), outs.Packets()[0].AuditInfo())
+}
+
+func TestRunIgnoresOtherGoroutines(t *testing.T) {
+	// A goroutine started by another test, such as a parallel one, while the
+	// network runs, is not reported as leaked by the run
+	start, started, stop := make(chan struct{}), make(chan struct{}), make(chan struct{})
+	defer close(stop)
+	go func() {
+		<-start
+		go func() { <-stop }()
+		close(started)
+	}()
+
+	net := NewTestNetwork(t)
+	proc := net.NewFunc("proc", func(out chan<- int) error {
+		close(start)
+		<-started
+		out <- 1
+		return nil
+	})
+	values := CollectPort[int](proc.Out())
+
+	net.Run()
+
+	if got := values.Values(); len(got) != 1 {
+		t.Errorf("Wrong values collected: %v", got)
+	}
+}
+
+func TestLabeledGoroutines(t *testing.T) {
+	label := labelString(runLabelKey, t.Name())
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	pprof.Do(context.Background(), pprof.Labels(runLabelKey, t.Name()), func(context.Context) {
+		go func() {
+			<-stop
+			close(stopped)
+		}()
+	})
+	// Unlabeled goroutines are not counted
+	go func() { <-stop }()
+
+	if count, stacks := labeledGoroutines(label); count != 1 {
+		t.Errorf("Expected 1 labeled goroutine, got %d:\n%s", count, stacks)
+	}
+	close(stop)
+	<-stopped
+	deadline := time.Now().Add(LeakTimeout)
+	for count, _ := labeledGoroutines(label); count > 0; count, _ = labeledGoroutines(label) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected no labeled goroutines after they exited, got %d", count)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
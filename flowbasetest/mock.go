@@ -0,0 +1,138 @@
+package flowbasetest
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	fb "github.com/flowbase/flowbase"
+)
+
+// MockProcess is a test double for the neighbors of a component under test.
+// Its ports are scripted with what to send (MockOutPort.WillSend) and what
+// to expect to receive (MockInPort.Expect), and the steps of the script are
+// run in the order they were added, when the network runs. Mismatches are
+// reported as errors on the test, as are packets received beyond the
+// expected ones, and expectations left unmet when the in-ports close.
+type MockProcess struct {
+	fb.BaseProcess
+	t     *testing.T
+	mx    sync.Mutex
+	steps []func()
+}
+
+// NewMockProcess returns a new MockProcess, added to the network net
+func NewMockProcess(t *testing.T, net *fb.Network, name string) *MockProcess {
+	p := &MockProcess{
+		BaseProcess: fb.NewBaseProcess(net, name),
+		t:           t,
+	}
+	net.AddProc(p)
+	return p
+}
+
+// In returns the mock in-port with name name, creating it if needed
+func (p *MockProcess) In(name string) *MockInPort {
+	if _, ok := p.InPorts()[name]; !ok {
+		p.InitInPort(p, name)
+	}
+	return &MockInPort{InPort: p.InPort(name), proc: p}
+}
+
+// Out returns the mock out-port with name name, creating it if needed
+func (p *MockProcess) Out(name string) *MockOutPort {
+	if _, ok := p.OutPorts()[name]; !ok {
+		p.InitOutPort(p, name)
+	}
+	return &MockOutPort{OutPort: p.OutPort(name), proc: p}
+}
+
+// addStep adds a step to the script of the process
+func (p *MockProcess) addStep(step func()) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	p.steps = append(p.steps, step)
+}
+
+// Run runs the script of the MockProcess, then closes its out-ports, and
+// reports any unexpected packets received on its in-ports
+func (p *MockProcess) Run() {
+	p.mx.Lock()
+	steps := p.steps
+	p.mx.Unlock()
+	for _, step := range steps {
+		step()
+	}
+	p.CloseOutPorts()
+	for name, inp := range p.InPorts() {
+		for ip, ok := inp.RecvOK(); ok; ip, ok = inp.RecvOK() {
+			p.t.Errorf("[MockProcess:%s] Unexpected packet on in-port %s: %v", p.Name(), name, ip.Data())
+		}
+	}
+}
+
+// MockInPort is an in-port of a MockProcess, on which packets can be
+// expected
+type MockInPort struct {
+	*fb.InPort
+	proc *MockProcess
+}
+
+// Expect adds a step to the script, receiving one packet per value in values
+// on the port, and checking that its data equals the value
+func (pt *MockInPort) Expect(values ...any) *MockInPort {
+	for _, v := range values {
+		expected := v
+		pt.ExpectMatch(func(ip *fb.Packet) bool {
+			return reflect.DeepEqual(expected, ip.Data())
+		}, expected)
+	}
+	return pt
+}
+
+// ExpectMatch adds a step to the script, receiving one packet on the port,
+// and checking it with match. The description is used in error messages.
+func (pt *MockInPort) ExpectMatch(match func(ip *fb.Packet) bool, description any) *MockInPort {
+	p := pt.proc
+	pt.proc.addStep(func() {
+		ip, ok := pt.RecvOK()
+		if !ok {
+			p.t.Errorf("[MockProcess:%s] In-port %s closed while expecting: %v", p.Name(), pt.Name(), description)
+			return
+		}
+		if !match(ip) {
+			p.t.Errorf("[MockProcess:%s] Unexpected packet on in-port %s: %v (expected: %v)", p.Name(), pt.Name(), ip.Data(), description)
+		}
+	})
+	return pt
+}
+
+// ExpectClose adds a step to the script, checking that the port is closed
+// without any more packets arriving
+func (pt *MockInPort) ExpectClose() *MockInPort {
+	p := pt.proc
+	pt.proc.addStep(func() {
+		if ip, ok := pt.RecvOK(); ok {
+			p.t.Errorf("[MockProcess:%s] Unexpected packet on in-port %s, expected it to close: %v", p.Name(), pt.Name(), ip.Data())
+		}
+	})
+	return pt
+}
+
+// MockOutPort is an out-port of a MockProcess, on which packets can be
+// scripted to be sent
+type MockOutPort struct {
+	*fb.OutPort
+	proc *MockProcess
+}
+
+// WillSend adds a step to the script, sending values on the port. Values
+// that are *Packets are sent as is.
+func (pt *MockOutPort) WillSend(values ...any) *MockOutPort {
+	pt.proc.addStep(func() {
+		for _, v := range values {
+			sendValue(pt.OutPort, v)
+		}
+	})
+	return pt
+}
@@ -0,0 +1,25 @@
+package flowbasetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/flowbase/flowbase/components"
+)
+
+func TestMockProcess(t *testing.T) {
+	t.Parallel()
+	net := NewTestNetwork(t)
+
+	up := NewMockProcess(t, net.Network, "up")
+	up.Out("out").WillSend(1, 2, 3)
+
+	delay := components.NewDelay(net.Network, "delay", time.Millisecond)
+	delay.In().From(up.Out("out").OutPort)
+
+	down := NewMockProcess(t, net.Network, "down")
+	down.In("in").Expect(1, 2).Expect(3).ExpectClose()
+	down.In("in").From(delay.Out())
+
+	net.Run()
+}
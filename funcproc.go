@@ -0,0 +1,81 @@
+package flowbase
+
+import (
+	"reflect"
+)
+
+// FuncProcess is a process running a function, created with Network.NewFunc
+type FuncProcess struct {
+	BaseProcess
+	f     reflect.Value
+	links []*structLink
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// NewFunc adds a process with name name, running the function f, to the
+// network, for one-off stages without defining new types:
+//
+//	upper := net.NewFunc("upper", func(in <-chan string, out chan<- string) error {
+//		for s := range in {
+//			out <- strings.ToUpper(s)
+//		}
+//		return nil
+//	})
+//
+// The function takes a channel to receive from, which becomes the in-port
+// "in", and a channel to send on, which becomes the out-port "out", or only
+// one of them, for sources and sinks, and returns an error, which fails the
+// process. The channels carry the data of the packets, or the packets
+// themselves, with their tags, for channels of *Packet. The out channel is
+// closed when the function returns.
+func (net *Network) NewFunc(name string, f any) *FuncProcess {
+	p := &FuncProcess{
+		BaseProcess: NewBaseProcess(net, name),
+		f:           reflect.ValueOf(f),
+	}
+	t := p.f.Type()
+	if t.Kind() != reflect.Func || t.NumOut() != 1 || t.Out(0) != errorType || t.NumIn() < 1 || t.NumIn() > 2 {
+		p.Failf("Expected a function like func(in <-chan T, out chan<- U) error, got %T", f)
+	}
+	for i := 0; i < t.NumIn(); i++ {
+		arg := t.In(i)
+		switch {
+		case arg.Kind() == reflect.Chan && arg.ChanDir() == reflect.RecvDir && i == 0:
+			p.InitInPort(p, "in")
+			p.links = append(p.links, newStructLink("in", true, arg))
+		case arg.Kind() == reflect.Chan && arg.ChanDir() == reflect.SendDir && i == t.NumIn()-1:
+			p.InitOutPort(p, "out")
+			p.links = append(p.links, newStructLink("out", false, arg))
+		default:
+			p.Failf("Expected a function like func(in <-chan T, out chan<- U) error, got %T", f)
+		}
+	}
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port of the process, on which the function receives
+func (p *FuncProcess) In() *InPort { return p.InPort("in") }
+
+// Out returns the out-port of the process, on which the function sends
+func (p *FuncProcess) Out() *OutPort { return p.OutPort("out") }
+
+// Run runs the function of the process
+func (p *FuncProcess) Run() {
+	defer p.CloseOutPorts()
+	err := runLinked(&p.BaseProcess, p.links, func() error {
+		args := make([]reflect.Value, len(p.links))
+		for i, l := range p.links {
+			args[i] = l.ch
+		}
+		res := p.f.Call(args)[0]
+		if res.IsNil() {
+			return nil
+		}
+		return res.Interface().(error)
+	})
+	if err != nil {
+		p.Fail(err)
+	}
+}
@@ -0,0 +1,65 @@
+package flowbase
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNewFunc(t *testing.T) {
+	initTestLogs()
+	net := NewNetwork("TestNewFunc")
+
+	src := net.NewFunc("src", func(out chan<- string) error {
+		out <- "a.txt"
+		out <- "b.txt"
+		return nil
+	})
+	upper := net.NewFunc("upper", func(in <-chan string, out chan<- string) error {
+		for s := range in {
+			out <- strings.ToUpper(s)
+		}
+		return nil
+	})
+	var tags []string
+	tagger := net.NewFunc("tagger", func(in <-chan *Packet, out chan<- *Packet) error {
+		for ip := range in {
+			ip.AddTag("name", strings.TrimSuffix(ip.Data().(string), ".TXT"))
+			out <- ip
+		}
+		return nil
+	})
+	sink := net.NewFunc("sink", func(in <-chan *Packet) error {
+		for ip := range in {
+			tags = append(tags, ip.Tag("name"))
+		}
+		return nil
+	})
+	upper.In().From(src.Out())
+	tagger.In().From(upper.Out())
+	sink.In().From(tagger.Out())
+
+	net.Run()
+
+	assertEqualValues(t, []string{"A", "B"}, tags)
+}
+
+func TestNewFuncError(t *testing.T) {
+	ensureFailsProgram("TestNewFuncError", func() {
+		net := NewNetwork("TestNewFuncError")
+		src := NewFileSource(net, "src", "a.txt")
+		fail := net.NewFunc("fail", func(in <-chan string) error {
+			return errors.New("failed")
+		})
+		fail.In().From(src.Out())
+		net.Run()
+	}, t)
+}
+
+func TestNewFuncInvalid(t *testing.T) {
+	ensureFailsProgram("TestNewFuncInvalid", func() {
+		NewNetwork("TestNewFuncInvalid").NewFunc("f", func(out chan<- string, in <-chan string) error {
+			return nil
+		})
+	}, t)
+}
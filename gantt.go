@@ -0,0 +1,208 @@
+package flowbase
+
+import (
+	"fmt"
+	"hash/fnv"
+	"html"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ReadAuditInfos reads the audit infos of the tasks of a finished run from
+// the audit files found under dir, including the upstream tasks recorded in
+// them, with each task once, sorted by their start times. Tasks which were
+// never executed, such as ones whose outputs were reused, are left out.
+func ReadAuditInfos(dir string) ([]*AuditInfo, error) {
+	byID := map[string]*AuditInfo{}
+	var add func(ai *AuditInfo)
+	add = func(ai *AuditInfo) {
+		if _, ok := byID[ai.ID]; ok {
+			return
+		}
+		byID[ai.ID] = ai
+		for _, up := range ai.Upstream {
+			add(up)
+		}
+	}
+	err := filepath.WalkDir(dir, func(path string, e fs.DirEntry, err error) error {
+		if err != nil || e.IsDir() || !strings.HasSuffix(path, auditFileSuffix) {
+			return err
+		}
+		ai, err := ReadAuditFile(strings.TrimSuffix(path, auditFileSuffix))
+		if err != nil {
+			return err
+		}
+		add(ai)
+		return nil
+	})
+	if err != nil {
+		return nil, errWrapf(err, "could not read audit files in %s", dir)
+	}
+	infos := []*AuditInfo{}
+	for _, ai := range byID {
+		if !ai.StartTime.IsZero() && !ai.FinishTime.IsZero() {
+			infos = append(infos, ai)
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if !infos[i].StartTime.Equal(infos[j].StartTime) {
+			return infos[i].StartTime.Before(infos[j].StartTime)
+		}
+		return infos[i].ID < infos[j].ID
+	})
+	return infos, nil
+}
+
+// ganttRow is a row of a Gantt chart, with tasks of one process which do not
+// overlap in time
+type ganttRow struct {
+	process string
+	tasks   []*AuditInfo
+}
+
+// ganttRows lays out the tasks infos, sorted by start time, in rows, with one
+// or more rows per process, as many as the process had tasks running at the
+// same time, with the processes in the order of their first tasks
+func ganttRows(infos []*AuditInfo) []*ganttRow {
+	rows := []*ganttRow{}
+	procRows := map[string][]*ganttRow{}
+	for _, ai := range infos {
+		var row *ganttRow
+		for _, r := range procRows[ai.ProcessName] {
+			if !r.tasks[len(r.tasks)-1].FinishTime.After(ai.StartTime) {
+				row = r
+				break
+			}
+		}
+		if row == nil {
+			row = &ganttRow{process: ai.ProcessName}
+			procRows[ai.ProcessName] = append(procRows[ai.ProcessName], row)
+		}
+		row.tasks = append(row.tasks, ai)
+	}
+	seen := map[string]bool{}
+	for _, ai := range infos {
+		if !seen[ai.ProcessName] {
+			seen[ai.ProcessName] = true
+			rows = append(rows, procRows[ai.ProcessName]...)
+		}
+	}
+	return rows
+}
+
+const (
+	ganttLabelWidth = 200
+	ganttChartWidth = 1000
+	ganttRowHeight  = 20
+	ganttAxisHeight = 30
+)
+
+// ganttColors are the colors of the bars of the processes
+var ganttColors = []string{"#4e79a7", "#f28e2b", "#e15759", "#76b7b2", "#59a14f", "#edc948", "#b07aa1", "#ff9da7", "#9c755f", "#bab0ac"}
+
+// WriteGanttSVG writes a Gantt chart of the tasks infos (see
+// ReadAuditInfos) to w, as SVG, with the tasks of each process in a row of
+// its own, or several if they ran at the same time, so that it is easy to
+// see where the wall time of a run goes. Hovering a task shows its command
+// and duration.
+func WriteGanttSVG(w io.Writer, infos []*AuditInfo) error {
+	rows := ganttRows(infos)
+	var start, finish time.Time
+	for i, ai := range infos {
+		if i == 0 || ai.StartTime.Before(start) {
+			start = ai.StartTime
+		}
+		if i == 0 || ai.FinishTime.After(finish) {
+			finish = ai.FinishTime
+		}
+	}
+	total := finish.Sub(start)
+	if total <= 0 {
+		total = time.Millisecond
+	}
+	x := func(t time.Time) float64 {
+		return ganttLabelWidth + float64(t.Sub(start))/float64(total)*ganttChartWidth
+	}
+	width := ganttLabelWidth + ganttChartWidth + 20
+	height := ganttAxisHeight + len(rows)*ganttRowHeight + 10
+
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" font-family=\"sans-serif\" font-size=\"12\">\n", width, height)
+	// Time axis, with grid lines
+	step := ganttTickStep(total)
+	for d := time.Duration(0); d <= total; d += step {
+		tx := x(start.Add(d))
+		fmt.Fprintf(b, "<line x1=\"%.1f\" y1=\"%d\" x2=\"%.1f\" y2=\"%d\" stroke=\"#ddd\"/>\n", tx, ganttAxisHeight-5, tx, height-10)
+		fmt.Fprintf(b, "<text x=\"%.1f\" y=\"%d\" text-anchor=\"middle\">%s</text>\n", tx, ganttAxisHeight-10, d)
+	}
+	for i, row := range rows {
+		y := ganttAxisHeight + i*ganttRowHeight
+		if i == 0 || rows[i-1].process != row.process {
+			fmt.Fprintf(b, "<text x=\"5\" y=\"%d\">%s</text>\n", y+ganttRowHeight-6, html.EscapeString(row.process))
+		}
+		color := ganttColor(row.process)
+		for _, ai := range row.tasks {
+			x0, x1 := x(ai.StartTime), x(ai.FinishTime)
+			fmt.Fprintf(b, "<rect x=\"%.1f\" y=\"%d\" width=\"%.1f\" height=\"%d\" fill=\"%s\"><title>%s (%s)</title></rect>\n",
+				x0, y+2, x1-x0+0.5, ganttRowHeight-4, color, html.EscapeString(ai.RedactedCommand()), ai.FinishTime.Sub(ai.StartTime))
+		}
+	}
+	b.WriteString("</svg>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteGanttHTML writes an HTML page to w, with a Gantt chart of the tasks
+// infos (see WriteGanttSVG), and a table with the number of tasks and the
+// total task time of each process
+func WriteGanttHTML(w io.Writer, title string, infos []*AuditInfo) error {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n", html.EscapeString(title))
+	b.WriteString("<style>body { font-family: sans-serif; } td, th { padding: 2px 10px; text-align: left; }</style>\n</head>\n<body>\n")
+	fmt.Fprintf(b, "<h1>%s</h1>\n", html.EscapeString(title))
+	if err := WriteGanttSVG(b, infos); err != nil {
+		return err
+	}
+
+	counts := map[string]int{}
+	times := map[string]time.Duration{}
+	procs := []string{}
+	for _, ai := range infos {
+		if counts[ai.ProcessName] == 0 {
+			procs = append(procs, ai.ProcessName)
+		}
+		counts[ai.ProcessName]++
+		times[ai.ProcessName] += ai.FinishTime.Sub(ai.StartTime)
+	}
+	b.WriteString("<table>\n<tr><th>Process</th><th>Tasks</th><th>Total task time</th></tr>\n")
+	for _, proc := range procs {
+		fmt.Fprintf(b, "<tr><td>%s</td><td>%d</td><td>%s</td></tr>\n", html.EscapeString(proc), counts[proc], times[proc])
+	}
+	b.WriteString("</table>\n</body>\n</html>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ganttTickStep returns the time between the ticks of the time axis of a
+// chart spanning total, for about ten ticks at round durations
+func ganttTickStep(total time.Duration) time.Duration {
+	for _, unit := range []time.Duration{time.Millisecond, time.Second, time.Minute, time.Hour} {
+		for _, n := range []time.Duration{1, 2, 5, 10, 15, 30} {
+			if step := n * unit; total/step <= 10 {
+				return step
+			}
+		}
+	}
+	return total / 10
+}
+
+// ganttColor returns the color of the bars of the process proc
+func ganttColor(proc string) string {
+	h := fnv.New32a()
+	h.Write([]byte(proc))
+	return ganttColors[h.Sum32()%uint32(len(ganttColors))]
+}
@@ -0,0 +1,63 @@
+package flowbase
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTimedAuditInfo returns an audit info for a task of the process proc,
+// running from start to finish seconds after t0
+func newTimedAuditInfo(id string, proc string, t0 time.Time, start int, finish int) *AuditInfo {
+	ai := NewAuditInfo()
+	ai.ID = id
+	ai.ProcessName = proc
+	ai.Command = "run " + id
+	ai.StartTime = t0.Add(time.Duration(start) * time.Second)
+	ai.FinishTime = t0.Add(time.Duration(finish) * time.Second)
+	ai.ExecTimeNS = ai.FinishTime.Sub(ai.StartTime)
+	return ai
+}
+
+func TestReadAuditInfos(t *testing.T) {
+	dir := t.TempDir()
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	download := newTimedAuditInfo("download", "download", t0, 0, 2)
+	a := newTimedAuditInfo("a", "align", t0, 2, 5)
+	a.Upstream["in"] = download
+	b := newTimedAuditInfo("b", "align", t0, 3, 4)
+	b.Upstream["in"] = download
+	for name, ai := range map[string]*AuditInfo{"a.bam": a, "sub/b.bam": b} {
+		path := filepath.Join(dir, name)
+		createDirs(path)
+		if err := ai.WriteAuditFile(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	infos, err := ReadAuditInfos(dir)
+	if err != nil {
+		t.Fatalf("Could not read audit infos: %v", err)
+	}
+	ids := []string{}
+	for _, ai := range infos {
+		ids = append(ids, ai.ID)
+	}
+	assertEqualValues(t, []string{"download", "a", "b"}, ids)
+
+	// The overlapping tasks of align get a row each
+	rows := ganttRows(infos)
+	assertEqualValues(t, 3, len(rows))
+	assertEqualValues(t, "align", rows[2].process)
+
+	buf := &bytes.Buffer{}
+	if err := WriteGanttHTML(buf, "Run", infos); err != nil {
+		t.Fatalf("Could not write Gantt chart: %v", err)
+	}
+	assertEqualValues(t, 3, strings.Count(buf.String(), "<rect "))
+	if !strings.Contains(buf.String(), "<title>run a (3s)</title>") {
+		t.Errorf("Expected a tooltip with the command and duration of task a, got:\n%s", buf.String())
+	}
+}
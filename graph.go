@@ -0,0 +1,180 @@
+package flowbase
+
+import (
+	"sort"
+)
+
+// ----------------------------------------------------------------------------
+// Graph query methods
+// ----------------------------------------------------------------------------
+
+// UpstreamOf returns all processes in the network which node receives data
+// from, directly or indirectly, sorted by name
+func (net *Network) UpstreamOf(node Node) []Node {
+	return net.sortedNodes(net.reachable(node, net.predecessors))
+}
+
+// DownstreamOf returns all processes in the network which receive data from
+// node, directly or indirectly, sorted by name
+func (net *Network) DownstreamOf(node Node) []Node {
+	return net.sortedNodes(net.reachable(node, net.successors))
+}
+
+// Sources returns the processes of the network which don't receive data from
+// any other process, sorted by name
+func (net *Network) Sources() []Node {
+	nodes := []Node{}
+	for _, node := range net.ProcsSorted() {
+		if len(net.predecessors(node)) == 0 {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// Sinks returns the processes of the network which don't send data to any
+// other process in it, except for the sink of the network, sorted by name
+func (net *Network) Sinks() []Node {
+	nodes := []Node{}
+	for _, node := range net.ProcsSorted() {
+		if len(net.successors(node)) == 0 {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// TopologicalOrder returns the processes of the network ordered so that every
+// process comes after all processes it receives data from, except via
+// feedback in-ports (see InPort.SetFeedback). Among processes which could
+// come in any order, the order is by name. A *CycleError is returned if the
+// network contains a cycle.
+func (net *Network) TopologicalOrder() ([]Node, error) {
+	inDegree := map[string]int{}
+	for name := range net.procs {
+		inDegree[name] = 0
+	}
+	for _, node := range net.procs {
+		for _, e := range forwardEdges(node, net.procs) {
+			inDegree[e.to.Process().Name()]++
+		}
+	}
+	ready := []string{}
+	for name, deg := range inDegree {
+		if deg == 0 {
+			ready = append(ready, name)
+		}
+	}
+	order := []Node{}
+	for len(ready) > 0 {
+		sort.Strings(ready)
+		node := net.procs[ready[0]]
+		ready = ready[1:]
+		order = append(order, node)
+		for _, e := range forwardEdges(node, net.procs) {
+			name := e.to.Process().Name()
+			inDegree[name]--
+			if inDegree[name] == 0 {
+				ready = append(ready, name)
+			}
+		}
+	}
+	if len(order) < len(net.procs) {
+		return order, net.findCycle(net.procs)
+	}
+	return order, nil
+}
+
+// ConnectedComponents returns the groups of processes in the network which
+// are connected to each other, regardless of the direction of the
+// connections. The processes in each group are sorted by name, and the groups
+// by the name of their first process.
+func (net *Network) ConnectedComponents() [][]Node {
+	neighbours := func(node Node) map[string]Node {
+		return mergeWFMaps(net.predecessors(node), net.successors(node))
+	}
+	seen := map[string]bool{}
+	components := [][]Node{}
+	for _, node := range net.ProcsSorted() {
+		if seen[node.Name()] {
+			continue
+		}
+		component := net.reachable(node, neighbours)
+		component[node.Name()] = node
+		for name := range component {
+			seen[name] = true
+		}
+		components = append(components, net.sortedNodes(component))
+	}
+	return components
+}
+
+// ----------------------------------------------------------------------------
+// Helper methods for graph queries
+// ----------------------------------------------------------------------------
+
+// predecessors returns the processes in the network which node receives data
+// from directly
+func (net *Network) predecessors(node Node) map[string]Node {
+	nodes := map[string]Node{}
+	for _, ipt := range node.InPorts() {
+		for _, opt := range ipt.ConnectedPorts() {
+			net.addIfMember(nodes, opt.Process())
+		}
+	}
+	return nodes
+}
+
+// successors returns the processes in the network which receive data from
+// node directly
+func (net *Network) successors(node Node) map[string]Node {
+	nodes := map[string]Node{}
+	for _, opt := range node.OutPorts() {
+		for _, ipt := range opt.ConnectedPorts() {
+			net.addIfMember(nodes, ipt.Process())
+		}
+	}
+	return nodes
+}
+
+// addIfMember adds node to nodes, if it is a process of the network
+func (net *Network) addIfMember(nodes map[string]Node, node Node) {
+	if node == nil {
+		return
+	}
+	if member, ok := net.procs[node.Name()]; ok && member == node {
+		nodes[node.Name()] = node
+	}
+}
+
+// reachable returns all processes reachable from node by repeatedly following
+// next, not including node itself unless it is part of a cycle
+func (net *Network) reachable(node Node, next func(Node) map[string]Node) map[string]Node {
+	found := map[string]Node{}
+	queue := []Node{node}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for name, n := range next(current) {
+			if _, ok := found[name]; !ok {
+				found[name] = n
+				queue = append(queue, n)
+			}
+		}
+	}
+	return found
+}
+
+// sortedNodes returns the processes in nodes as a slice, sorted by name
+func (net *Network) sortedNodes(nodes map[string]Node) []Node {
+	names := []string{}
+	for name := range nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	sorted := []Node{}
+	for _, name := range names {
+		sorted = append(sorted, nodes[name])
+	}
+	return sorted
+}
@@ -0,0 +1,44 @@
+package flowbase
+
+import (
+	"testing"
+)
+
+func nodeNames(nodes []Node) []string {
+	names := []string{}
+	for _, node := range nodes {
+		names = append(names, node.Name())
+	}
+	return names
+}
+
+func TestGraphQueries(t *testing.T) {
+	initTestLogs()
+
+	net := NewNetwork("TestGraphQueries")
+	src := NewFileSource(net, "src", "a.txt")
+	tags := NewMapToTags(net, "tags", func(ip *Packet) map[string]string { return nil })
+	tags.In().From(src.Out())
+	col := NewPacketCollector(net, "col")
+	col.In().From(tags.Out())
+
+	other := NewFileSource(net, "other", "b.txt")
+	otherCol := NewPacketCollector(net, "other_col")
+	otherCol.In().From(other.Out())
+
+	assertEqualValues(t, []string{"src", "tags"}, nodeNames(net.UpstreamOf(col)))
+	assertEqualValues(t, []string{"col", "tags"}, nodeNames(net.DownstreamOf(src)))
+	assertEqualValues(t, []string{"other", "src"}, nodeNames(net.Sources()))
+	assertEqualValues(t, []string{"col", "other_col"}, nodeNames(net.Sinks()))
+
+	order, err := net.TopologicalOrder()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	assertEqualValues(t, []string{"other", "other_col", "src", "tags", "col"}, nodeNames(order))
+
+	components := net.ConnectedComponents()
+	assertEqualValues(t, 2, len(components))
+	assertEqualValues(t, []string{"col", "src", "tags"}, nodeNames(components[0]))
+	assertEqualValues(t, []string{"other", "other_col"}, nodeNames(components[1]))
+}
@@ -0,0 +1,152 @@
+package flowbase
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// IdempotencyKeyTag is the tag holding the idempotency key of a packet (see
+// Packet.IdempotencyKey)
+const IdempotencyKeyTag = "idempotency-key"
+
+// IdempotencyKey returns the idempotency key of the packet, or "" if it has
+// none. The key identifies the work the packet represents, so that it stays
+// the same when the packet is recreated, such as when a network is resumed
+// after a crash, or retried. Side-effecting sinks can use it to skip packets
+// which have already been processed (see ProcessOnce).
+func (ip *Packet) IdempotencyKey() string {
+	return ip.tags[IdempotencyKeyTag]
+}
+
+// SetIdempotencyKey sets the idempotency key of the packet to key
+func (ip *Packet) SetIdempotencyKey(key string) {
+	ip.AddTag(IdempotencyKeyTag, key)
+}
+
+// ProcessedKeys is a store of the idempotency keys of the packets which have
+// been processed. Implementations need to be safe for concurrent use.
+type ProcessedKeys interface {
+	// Processed tells whether the key has been marked as processed
+	Processed(key string) (bool, error)
+	// MarkProcessed marks the key as processed
+	MarkProcessed(key string) error
+}
+
+// ProcessOnce calls process for the packet ip, unless its idempotency key is
+// marked as processed in keys, in which case skipped is true. The key is
+// marked as processed once process has succeeded. Packets without an
+// idempotency key are always processed.
+func ProcessOnce(keys ProcessedKeys, ip *Packet, process func() error) (skipped bool, err error) {
+	key := ip.IdempotencyKey()
+	if key == "" {
+		return false, process()
+	}
+	done, err := keys.Processed(key)
+	if err != nil {
+		return false, errWrapf(err, "could not look up idempotency key %s", key)
+	}
+	if done {
+		return true, nil
+	}
+	if err := process(); err != nil {
+		return false, err
+	}
+	if err := keys.MarkProcessed(key); err != nil {
+		return false, errWrapf(err, "could not mark idempotency key %s as processed", key)
+	}
+	return false, nil
+}
+
+// MemoryKeys is a ProcessedKeys store kept in memory, for deduplicating
+// retries within a single run
+type MemoryKeys struct {
+	mx   sync.Mutex
+	keys map[string]bool
+}
+
+// NewMemoryKeys returns a new, empty, MemoryKeys store
+func NewMemoryKeys() *MemoryKeys {
+	return &MemoryKeys{keys: map[string]bool{}}
+}
+
+// Processed tells whether the key has been marked as processed
+func (s *MemoryKeys) Processed(key string) (bool, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.keys[key], nil
+}
+
+// MarkProcessed marks the key as processed
+func (s *MemoryKeys) MarkProcessed(key string) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.keys[key] = true
+	return nil
+}
+
+// FileKeys is a ProcessedKeys store kept in a file, with one key per line,
+// so that it survives crashes and restarts. Each key is synced to disk as
+// it is marked as processed.
+type FileKeys struct {
+	mx   sync.Mutex
+	file *os.File
+	keys map[string]bool
+}
+
+// OpenFileKeys opens the FileKeys store in the file at path, creating it if
+// it does not exist
+func OpenFileKeys(path string) (*FileKeys, error) {
+	createDirs(path)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, errWrapf(err, "could not open processed keys file %s", path)
+	}
+	s := &FileKeys{file: file, keys: map[string]bool{}}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if key := scanner.Text(); key != "" {
+			s.keys[key] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, errWrapf(err, "could not read processed keys file %s", path)
+	}
+	return s, nil
+}
+
+// Processed tells whether the key has been marked as processed
+func (s *FileKeys) Processed(key string) (bool, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.keys[key], nil
+}
+
+// MarkProcessed marks the key as processed, and syncs it to disk. Keys can
+// not contain newlines.
+func (s *FileKeys) MarkProcessed(key string) error {
+	if strings.ContainsAny(key, "\r\n") {
+		return fmt.Errorf("idempotency key contains a newline: %q", key)
+	}
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.keys[key] {
+		return nil
+	}
+	if _, err := s.file.WriteString(key + "\n"); err != nil {
+		return err
+	}
+	if err := s.file.Sync(); err != nil {
+		return err
+	}
+	s.keys[key] = true
+	return nil
+}
+
+// Close closes the file of the store
+func (s *FileKeys) Close() error {
+	return s.file.Close()
+}
@@ -0,0 +1,51 @@
+package flowbase
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestProcessOnceFileKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", "processed.txt")
+	keys, err := OpenFileKeys(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+	process := func() error {
+		calls++
+		return nil
+	}
+	ip := NewPacket("a")
+	ip.SetIdempotencyKey("order-1")
+	if skipped, err := ProcessOnce(keys, ip, process); skipped || err != nil {
+		t.Fatalf("First packet was not processed: %v, %v", skipped, err)
+	}
+	failed := NewPacket("b")
+	failed.SetIdempotencyKey("order-2")
+	if _, err := ProcessOnce(keys, failed, func() error { return errors.New("failed") }); err == nil {
+		t.Fatalf("Error was not returned")
+	}
+	keys.Close()
+
+	// Reopening the store, as after a crash, should remember processed keys
+	keys, err = OpenFileKeys(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer keys.Close()
+	retried := NewPacket("a")
+	retried.SetIdempotencyKey("order-1")
+	if skipped, err := ProcessOnce(keys, retried, process); !skipped || err != nil {
+		t.Errorf("Processed packet was not skipped: %v, %v", skipped, err)
+	}
+	if skipped, _ := ProcessOnce(keys, failed, process); skipped {
+		t.Errorf("Failed packet was skipped")
+	}
+	if skipped, _ := ProcessOnce(keys, NewPacket("c"), process); skipped {
+		t.Errorf("Packet without key was skipped")
+	}
+	assertEqualValues(t, 3, calls)
+}
@@ -0,0 +1,289 @@
+package flowbase
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// defaultPacketSize is the approximate size in bytes of packets whose data
+// has no known size (see Packet.Size)
+const defaultPacketSize = 64
+
+// MemSizer can be implemented by packet data types, to tell their
+// approximate size in memory, in bytes, for memory budgets (see
+// Network.SetMemoryBudget)
+type MemSizer interface {
+	MemSize() int64
+}
+
+// Size returns the approximate size of the packet in memory, in bytes, as set
+// with SetSize, or else as told by its data, if it is a MemSizer, a string,
+// a []byte or a *MemIP, or else a small default size
+func (ip *Packet) Size() int64 {
+	if ip.size > 0 {
+		return ip.size
+	}
+	switch d := ip.data.(type) {
+	case MemSizer:
+		return d.MemSize()
+	case string:
+		return int64(len(d))
+	case []byte:
+		return int64(len(d))
+	case *MemIP:
+		return d.Size()
+	}
+	return defaultPacketSize
+}
+
+// SetSize sets the approximate size of the packet in memory, in bytes, for
+// data whose size is not known (see Size)
+func (ip *Packet) SetSize(size int64) {
+	ip.size = size
+}
+
+// ------------------------------------------------------------------------
+// Memory budget
+// ------------------------------------------------------------------------
+
+// BudgetPolicy tells what happens to packets sent to in-ports, which do not
+// fit in the memory budget of the network
+type BudgetPolicy int
+
+const (
+	// BudgetBlock blocks the sender until the packet fits
+	BudgetBlock BudgetPolicy = iota
+	// BudgetSpill writes the packet to disk, until it is received
+	BudgetSpill
+)
+
+// memoryBudget keeps track of the bytes of the packets queued in the
+// in-ports of a network
+type memoryBudget struct {
+	limit    int64
+	policy   BudgetPolicy
+	mx       sync.Mutex
+	cond     *sync.Cond
+	used     int64
+	spillDir string
+	// tempDir is the spill directory created by the budget itself, which is
+	// removed after the network has run
+	tempDir string
+}
+
+// SetMemoryBudget makes the packets queued in all the in-ports of the network
+// together take at most limit bytes, by their approximate sizes (see
+// Packet.Size), so that fan-out heavy networks do not run out of memory.
+// Packets that do not fit either block their senders until enough packets
+// are received (BudgetBlock), or are written to disk until they are received
+// (BudgetSpill), in a temporary directory, or the directory set with
+// SetSpillDir. A packet bigger than the whole budget is let through when no
+// other packets are queued. With BudgetBlock, networks where processes wait
+// for packets on one in-port, while packets are queued on another, can
+// deadlock when the budget is exhausted. The budget does not apply to
+// conflating in-ports. It must be called before the network is run.
+func (net *Network) SetMemoryBudget(limit int64, policy BudgetPolicy) {
+	b := &memoryBudget{limit: limit, policy: policy}
+	if net.budget != nil {
+		b.spillDir = net.budget.spillDir
+	}
+	b.cond = sync.NewCond(&b.mx)
+	net.budget = b
+}
+
+// SetSpillDir sets the directory that packets are written to when they do
+// not fit in the memory budget, with the BudgetSpill policy
+func (net *Network) SetSpillDir(dir string) {
+	if net.budget == nil {
+		net.Fail("SetSpillDir needs a memory budget, set with SetMemoryBudget")
+	}
+	net.budget.spillDir = dir
+}
+
+// MemoryUsed returns the approximate bytes of the packets queued in the
+// in-ports of the network, if it has a memory budget
+func (net *Network) MemoryUsed() int64 {
+	if net.budget == nil {
+		return 0
+	}
+	net.budget.mx.Lock()
+	defer net.budget.mx.Unlock()
+	return net.budget.used
+}
+
+// spilledPacket is the data of packets standing in for packets written to
+// disk, in queues of in-ports
+type spilledPacket struct {
+	path string
+}
+
+// acquire accounts for the packet ip being queued, and returns it, or a
+// packet standing in for it, if it was spilled to disk
+func (b *memoryBudget) acquire(ip *Packet) *Packet {
+	size := ip.Size()
+	b.mx.Lock()
+	for b.used > 0 && b.used+size > b.limit {
+		if b.policy == BudgetSpill {
+			// The packet is written to disk without holding the lock, so that
+			// other ports are not held up by it
+			b.mx.Unlock()
+			spilled, err := b.spill(ip)
+			if err == nil {
+				return spilled
+			}
+			Warning.Printf("Could not spill packet (%s) to disk, so queueing it in memory: %v\n", ip.ID(), err)
+			b.mx.Lock()
+			break
+		}
+		b.cond.Wait()
+	}
+	b.used += size
+	ip.queued = size
+	b.mx.Unlock()
+	return ip
+}
+
+// release accounts for the packet ip being received, and returns it, after
+// reading it back from disk, if it was spilled
+func (b *memoryBudget) release(ip *Packet) (*Packet, error) {
+	if sp, ok := ip.data.(*spilledPacket); ok {
+		return b.unspill(sp)
+	}
+	queued := ip.queued
+	ip.queued = 0
+	b.releaseBytes(queued)
+	return ip, nil
+}
+
+// releaseBytes accounts for size bytes of queued packets having been
+// received
+func (b *memoryBudget) releaseBytes(size int64) {
+	if size == 0 {
+		return
+	}
+	b.mx.Lock()
+	b.used -= size
+	b.mx.Unlock()
+	b.cond.Broadcast()
+}
+
+// dir returns the spill directory, after creating a temporary one, if none
+// has been set
+func (b *memoryBudget) dir() (string, error) {
+	b.mx.Lock()
+	dir := b.spillDir
+	b.mx.Unlock()
+	if dir != "" {
+		return dir, nil
+	}
+	dir, err := os.MkdirTemp("", "flowbase-spill-")
+	if err != nil {
+		return "", err
+	}
+	b.mx.Lock()
+	if b.spillDir == "" {
+		b.spillDir, b.tempDir = dir, dir
+	}
+	spillDir := b.spillDir
+	b.mx.Unlock()
+	if spillDir != dir {
+		// Another port created a spill directory at the same time
+		os.Remove(dir)
+	}
+	return spillDir, nil
+}
+
+// spill writes the packet ip to a file in the spill directory, and returns a
+// packet standing in for it
+func (b *memoryBudget) spill(ip *Packet) (*Packet, error) {
+	dir, err := b.dir()
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, ip.ID()+".gob")
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	err = NewGobCodec().NewEncoder(f).Encode(ip)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	Debug.Printf("Spilled packet (%s) of %d bytes to %s\n", ip.ID(), ip.Size(), path)
+	return &Packet{id: ip.id, data: &spilledPacket{path: path}, tags: map[string]string{}}, nil
+}
+
+// unspill reads back the packet written to disk by spill, and removes its
+// file
+func (b *memoryBudget) unspill(sp *spilledPacket) (*Packet, error) {
+	f, err := os.Open(sp.path)
+	if err != nil {
+		return nil, err
+	}
+	ip, err := NewGobCodec().NewDecoder(f).Decode()
+	f.Close()
+	if err != nil {
+		return nil, errWrapf(err, "could not read back spilled packet from %s", sp.path)
+	}
+	return ip, os.Remove(sp.path)
+}
+
+// cleanup removes the spill directory, if the budget created it
+func (b *memoryBudget) cleanup() {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	if b.tempDir == "" {
+		return
+	}
+	if err := os.RemoveAll(b.tempDir); err != nil {
+		Warning.Printf("Could not remove spill directory %s: %v\n", b.tempDir, err)
+	}
+	b.spillDir, b.tempDir = "", ""
+}
+
+// queueBudgeted makes the packets sent to the in-port be queued in a
+// separate channel, from which they are handed over on the Chan field one at
+// a time, so that the budget b is released when packets are received, also
+// by processes reading from the Chan field directly, such as with range
+func (pt *InPort) queueBudgeted(b *memoryBudget) {
+	if pt.queue != nil || pt.rings != nil {
+		return
+	}
+	queue, out := pt.Chan, make(chan *Packet)
+	pt.queue, pt.Chan = queue, out
+	go func() {
+		for ip := range queue {
+			if sp, ok := ip.data.(*spilledPacket); ok {
+				var err error
+				if ip, err = b.unspill(sp); err != nil {
+					pt.Fail(err)
+				}
+			}
+			// The packet is handed over before its bytes are released, and
+			// not touched after, as the receiver might send it on
+			queued := ip.queued
+			ip.queued = 0
+			out <- ip
+			b.releaseBytes(queued)
+		}
+		close(out)
+	}()
+}
+
+// budget returns the memory budget of the network of the process of the
+// in-port, if any
+func (pt *InPort) budget() *memoryBudget {
+	if pt.process == nil || pt.conflate {
+		return nil
+	}
+	net := networkOf(pt.process)
+	if net == nil {
+		return nil
+	}
+	return net.budget
+}
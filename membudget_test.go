@@ -0,0 +1,240 @@
+package flowbase
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPacketSize(t *testing.T) {
+	assertEqualValues(t, int64(5), NewPacket("hello").Size())
+	assertEqualValues(t, int64(3), NewPacket([]byte("abc")).Size())
+	assertEqualValues(t, int64(defaultPacketSize), NewPacket(42).Size())
+
+	ip := NewPacket(42)
+	ip.SetSize(1000)
+	assertEqualValues(t, int64(1000), ip.Size())
+	assertEqualValues(t, int64(1000), ip.Clone().Size())
+}
+
+func TestMemoryBudgetBlock(t *testing.T) {
+	initTestLogs()
+	net := NewNetwork("TestMemoryBudgetBlock")
+	const limit = 100
+	net.SetMemoryBudget(limit, BudgetBlock)
+
+	src := net.NewFunc("src", func(out chan<- string) error {
+		for i := 0; i < 10; i++ {
+			out <- strings.Repeat(strconv.Itoa(i), 40)
+		}
+		return nil
+	})
+	var maxUsed int64
+	received := 0
+	sink := net.NewFunc("sink", func(in <-chan string) error {
+		// Without a limit, all the packets would be queued by now
+		time.Sleep(50 * time.Millisecond)
+		for range in {
+			if used := net.MemoryUsed(); used > maxUsed {
+				maxUsed = used
+			}
+			received++
+		}
+		return nil
+	})
+	sink.In().From(src.Out())
+
+	net.Run()
+
+	assertEqualValues(t, 10, received)
+	if maxUsed > limit {
+		t.Errorf("Expected at most %d bytes queued, got %d", limit, maxUsed)
+	}
+	if maxUsed == 0 {
+		t.Errorf("Expected queued packets to be counted")
+	}
+	assertEqualValues(t, int64(0), net.MemoryUsed())
+}
+
+func TestMemoryBudgetSpill(t *testing.T) {
+	initTestLogs()
+	net := NewNetwork("TestMemoryBudgetSpill")
+	net.SetMemoryBudget(100, BudgetSpill)
+	spillDir := t.TempDir()
+	net.SetSpillDir(spillDir)
+
+	sent := make(chan struct{})
+	src := net.NewFunc("src", func(out chan<- *Packet) error {
+		defer close(sent)
+		for i := 0; i < 10; i++ {
+			ip := NewPacket(strings.Repeat(strconv.Itoa(i), 40))
+			ip.AddTag("i", strconv.Itoa(i))
+			out <- ip
+		}
+		return nil
+	})
+	var spilled int
+	var data, tags []string
+	sink := net.NewFunc("sink", func(in <-chan *Packet) error {
+		// All packets are sent without waiting for the sink, as the ones not
+		// fitting in the budget are spilled
+		<-sent
+		entries, err := os.ReadDir(spillDir)
+		if err != nil {
+			return err
+		}
+		spilled = len(entries)
+		for ip := range in {
+			data = append(data, ip.Data().(string)[:1])
+			tags = append(tags, ip.Tag("i"))
+		}
+		return nil
+	})
+	sink.In().From(src.Out())
+
+	net.Run()
+
+	if spilled == 0 {
+		t.Errorf("Expected packets to be spilled to disk")
+	}
+	assertEqualValues(t, []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"}, data)
+	assertEqualValues(t, data, tags)
+	entries, _ := os.ReadDir(spillDir)
+	assertEqualValues(t, 0, len(entries))
+}
+
+type selectSink struct {
+	A    *InPort `flowbase:"in,a"`
+	B    *InPort `flowbase:"in,b"`
+	data []any
+}
+
+func (s *selectSink) Run() {
+	SelectAll(func(port *InPort, ip *Packet) {
+		s.data = append(s.data, ip.Data())
+	}, s.A, s.B)
+}
+
+func TestMemoryBudgetSelect(t *testing.T) {
+	initTestLogs()
+	net := NewNetwork("TestMemoryBudgetSelect")
+	net.SetMemoryBudget(100, BudgetSpill)
+	net.SetSpillDir(t.TempDir())
+
+	src := net.NewFunc("src", func(out chan<- string) error {
+		for i := 0; i < 10; i++ {
+			out <- strings.Repeat(strconv.Itoa(i), 40)
+		}
+		return nil
+	})
+	sink := &selectSink{}
+	proc := RegisterStruct(net, "sink", sink)
+	proc.InPort("a").From(src.Out())
+	proc.InPort("b").From(NewIIPSource(net, "iip", strings.Repeat("x", 40)).Out())
+
+	net.Run()
+
+	assertEqualValues(t, 11, len(sink.data))
+	for _, d := range sink.data {
+		if s, ok := d.(string); !ok || len(s) != 40 {
+			t.Errorf("Expected a string of 40 bytes to be selected, got %T", d)
+		}
+	}
+	assertEqualValues(t, int64(0), net.MemoryUsed())
+}
+
+func TestMemoryBudgetChan(t *testing.T) {
+	initTestLogs()
+	// With BudgetBlock, the limit fits all the packets, as the mapper would
+	// otherwise block sending to the sink, while its own in-port holds the
+	// rest of the budget
+	for _, tc := range []struct {
+		policy BudgetPolicy
+		limit  int64
+	}{
+		{BudgetBlock, 400},
+		{BudgetSpill, 100},
+	} {
+		net := NewNetwork("TestMemoryBudgetChan")
+		net.SetMemoryBudget(tc.limit, tc.policy)
+		net.SetSpillDir(t.TempDir())
+		src := net.NewFunc("src", func(out chan<- string) error {
+			for i := 0; i < 10; i++ {
+				out <- strings.Repeat(strconv.Itoa(i), 40)
+			}
+			return nil
+		})
+		// MapToTags receives from the Chan field of its in-port directly
+		mapper := NewMapToTags(net, "mapper", func(ip *Packet) map[string]string {
+			return map[string]string{"i": ip.Data().(string)[:1]}
+		})
+		mapper.In().From(src.Out())
+		sink := NewPacketCollector(net, "sink")
+		sink.In().From(mapper.Out())
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			net.Run()
+		}()
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			t.Fatalf("Network with policy %d did not finish", tc.policy)
+		}
+
+		assertEqualValues(t, 10, len(sink.Data))
+		assertEqualValues(t, int64(0), net.MemoryUsed())
+	}
+}
+
+func TestMemoryBudgetSpillSize(t *testing.T) {
+	initTestLogs()
+	net := NewNetwork("TestMemoryBudgetSpillSize")
+	net.SetMemoryBudget(100, BudgetSpill)
+	spillDir := t.TempDir()
+	net.SetSpillDir(spillDir)
+
+	sent := make(chan struct{})
+	src := net.NewFunc("src", func(out chan<- *Packet) error {
+		defer close(sent)
+		for i := 0; i < 5; i++ {
+			ip := NewPacket(i)
+			ip.SetSize(60)
+			out <- ip
+		}
+		return nil
+	})
+	var spilled int
+	relay := net.NewFunc("relay", func(in <-chan *Packet, out chan<- *Packet) error {
+		<-sent
+		entries, err := os.ReadDir(spillDir)
+		if err != nil {
+			return err
+		}
+		spilled = len(entries)
+		for ip := range in {
+			out <- ip
+		}
+		return nil
+	})
+	sizes := []int64{}
+	sink := net.NewFunc("sink", func(in <-chan *Packet) error {
+		for ip := range in {
+			sizes = append(sizes, ip.Size())
+		}
+		return nil
+	})
+	relay.In().From(src.Out())
+	sink.In().From(relay.Out())
+
+	net.Run()
+
+	if spilled == 0 {
+		t.Errorf("Expected packets to be spilled to disk")
+	}
+	assertEqualValues(t, []int64{60, 60, 60, 60, 60}, sizes)
+	assertEqualValues(t, int64(0), net.MemoryUsed())
+}
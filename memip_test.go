@@ -0,0 +1,24 @@
+package flowbase
+
+import (
+	"io"
+	"testing"
+)
+
+func TestMemIP(t *testing.T) {
+	var m ContentIP = NewMemIP("mem.json", nil)
+
+	w := m.Create()
+	_, err := io.WriteString(w, `{"a": 1}`)
+	Check(err)
+	Check(w.Close())
+
+	assertEqualValues(t, int64(8), m.Size())
+	v := map[string]int{}
+	m.UnMarshalJSON(&v)
+	assertEqualValues(t, map[string]int{"a": 1}, v)
+
+	clone := m.(*MemIP).Clone().(*MemIP)
+	clone.Write([]byte("b"))
+	assertEqualValues(t, `{"a": 1}`, string(m.Read()))
+}
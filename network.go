@@ -24,11 +24,31 @@ import (
 type Network struct {
 	name              string
 	procs             map[string]Node
+	constructed       []*procRecord
+	manualAdd         bool
+	procsMx           sync.Mutex
 	concurrentTasks   chan struct{}
 	concurrentTasksMx sync.Mutex
 	sink              *Sink
 	driver            Node
+	explicitDriver    bool
+	procDone          map[string]chan struct{}
+	procDoneMx        sync.Mutex
+	exportedInPorts   map[string]*InPort
+	exportedOutPorts  map[string]*OutPort
 	logFile           string
+	executor          Executor
+	outDir            string
+	runID             string
+	runIDUnused       bool
+	runIDMx           sync.Mutex
+	events            eventBus
+	clock             Clock
+	profiling         bool
+	disk              diskGuard
+	quotas            map[string]*Quota
+	quotasMx          sync.Mutex
+	budget            *memoryBudget
 	PlotConf          NetworkPlotConf
 }
 
@@ -131,12 +151,79 @@ func (net *Network) Procs() map[string]Node {
 	return net.procs
 }
 
-// AddProc adds a Process to the workflow, to be run when the workflow runs
+// AddProc adds a Process to the workflow, to be run when the workflow runs.
+// Adding a process which is already added, such as automatically (see
+// SetAutoAddProcs), does nothing.
 func (net *Network) AddProc(node Node) {
-	if net.procs[node.Name()] != nil {
+	net.procsMx.Lock()
+	defer net.procsMx.Unlock()
+	if existing := net.procs[node.Name()]; existing != nil {
+		if existing == node {
+			return
+		}
 		net.Failf("A process with name (%s) already exists in the workflow! Use a more unique name!", node.Name())
 	}
 	net.procs[node.Name()] = node
+	markAdded(node)
+}
+
+// SetAutoAddProcs sets whether processes embedding BaseProcess are added to
+// the workflow automatically when their first port is initialized, which is
+// the default, so that processes are not left out by forgetting to call
+// AddProc. With auto-adding turned off, processes constructed afterwards need
+// to be added with AddProc or AddProcs.
+func (net *Network) SetAutoAddProcs(autoAdd bool) {
+	net.procsMx.Lock()
+	defer net.procsMx.Unlock()
+	net.manualAdd = !autoAdd
+}
+
+// autoAddProc adds node to the workflow, unless auto-adding is turned off, or
+// a process with its name is already added
+func (net *Network) autoAddProc(node Node) {
+	net.procsMx.Lock()
+	defer net.procsMx.Unlock()
+	if net.manualAdd || net.procs[node.Name()] != nil {
+		return
+	}
+	net.procs[node.Name()] = node
+	markAdded(node)
+}
+
+// addConstructed records that the process of record was constructed with the
+// network, to report it if it is never added (see ForgottenProcs)
+func (net *Network) addConstructed(record *procRecord) {
+	net.procsMx.Lock()
+	defer net.procsMx.Unlock()
+	net.constructed = append(net.constructed, record)
+}
+
+// recorder is implemented by processes embedding BaseProcess
+type recorder interface {
+	processRecord() *procRecord
+}
+
+// markAdded records that node was added to a network
+func markAdded(node Node) {
+	if r, ok := node.(recorder); ok && r.processRecord() != nil {
+		r.processRecord().added = true
+	}
+}
+
+// ForgottenProcs returns the names of the processes constructed with the
+// network, that were never added to it, neither with AddProc, nor
+// automatically when initializing their ports. Running a network with
+// forgotten processes fails.
+func (net *Network) ForgottenProcs() []string {
+	net.procsMx.Lock()
+	defer net.procsMx.Unlock()
+	names := []string{}
+	for _, rec := range net.constructed {
+		if !rec.added {
+			names = append(names, rec.name)
+		}
+	}
+	return names
 }
 
 // AddProcs takes one or many Processes and adds them to the workflow, to be run
@@ -160,6 +247,67 @@ func (net *Network) SetSink(sink *Sink) {
 	net.sink = sink
 }
 
+// RunID returns the unique ID of the current execution of the network, such
+// as "20220101-120000-k3j5h2", which is included in audit log lines and audit
+// info, so that external systems can correlate pipeline runs. A new one is
+// generated for each run, unless set with SetRunID. After a run, it is the ID
+// of that run.
+func (net *Network) RunID() string {
+	net.runIDMx.Lock()
+	defer net.runIDMx.Unlock()
+	if net.runID == "" {
+		net.runID = newRunID()
+		net.runIDUnused = true
+	}
+	return net.runID
+}
+
+// SetRunID sets the run ID of the next run of the network, such as one
+// assigned by an external scheduler, instead of a generated one
+func (net *Network) SetRunID(runID string) {
+	net.runIDMx.Lock()
+	defer net.runIDMx.Unlock()
+	net.runID = runID
+	net.runIDUnused = true
+}
+
+// startRunID sets the run ID for a new run, which is a newly generated one,
+// unless one was set with SetRunID, or already returned by RunID, since the
+// previous run
+func (net *Network) startRunID() {
+	net.runIDMx.Lock()
+	defer net.runIDMx.Unlock()
+	if net.runID == "" || !net.runIDUnused {
+		net.runID = newRunID()
+	}
+	net.runIDUnused = false
+}
+
+// newRunID returns a new run ID, such as "20220101-120000-k3j5h2"
+func newRunID() string {
+	return time.Now().Format("20060102-150405") + "-" + randSeqLC(6)
+}
+
+// SetExecutor sets the default executor used to run the commands of processes
+// in the workflow, such as ExecProcs, which don't have one set themselves
+func (net *Network) SetExecutor(executor Executor) {
+	net.executor = executor
+}
+
+// SetOutDir makes the relative output paths of the processes of the network,
+// such as the default output paths of ExecProcs, relative to the directory
+// dir instead of the working directory, such as to keep the outputs and
+// audit files of different runs of the same network apart
+func (net *Network) SetOutDir(dir string) {
+	net.outDir = dir
+}
+
+// OutDir returns the directory relative output paths are placed in, or ""
+// for the working directory (see SetOutDir)
+func (net *Network) OutDir() string {
+	return net.outDir
+}
+
 // IncConcurrentTasks increases the conter for how many concurrent tasks are
 // currently running in the workflow
 func (net *Network) IncConcurrentTasks(slots int) {
@@ -212,13 +360,34 @@ func (net *Network) DotGraph() (dot string) {
 	dot += `  node  [fontname="Arial",fontsize=11,color="#384A52",fontcolor="#384A52",fillcolor="#EFF2F5",shape=box,style=filled];` + "\n"
 	dot += `  edge  [fontname="Arial",fontsize=9, color="#384A52",fontcolor="#384A52"];` + "\n"
 
+	// Processes with hierarchical names are grouped into clusters, per
+	// namespace
+	clusters := map[string]string{}
+	for _, p := range net.ProcsSorted() {
+		ns := ProcNamespace(p.Name())
+		if ns == "" {
+			dot += fmt.Sprintf(`  "%s" [shape=box];`+"\n", p.Name())
+			continue
+		}
+		clusters[ns] += fmt.Sprintf(`    "%s" [shape=box,label="%s"];`+"\n", p.Name(), strings.TrimPrefix(p.Name(), ns+ProcNameSeparator))
+	}
+	namespaces := []string{}
+	for ns := range clusters {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+	for _, ns := range namespaces {
+		dot += fmt.Sprintf(`  subgraph "cluster_%s" {`+"\n"+`    label="%s";`+"\n", ns, ns)
+		dot += clusters[ns]
+		dot += "  }\n"
+	}
+
 	con := ""
 	remToDotPtn := regexp.MustCompile(`^.*\.`)
 	for _, p := range net.ProcsSorted() {
-		dot += fmt.Sprintf(`  "%s" [shape=box];`+"\n", p.Name())
 		// File connections
 		for opname, op := range p.OutPorts() {
-			for rpname, rp := range op.RemotePorts {
+			for rpname, rp := range op.ConnectedPorts() {
 				if net.PlotConf.EdgeLabels {
 					con += fmt.Sprintf(`  "%s" -> "%s" [taillabel="%s", headlabel="%s"];`+"\n", op.Process().Name(), rp.Process().Name(), remToDotPtn.ReplaceAllString(opname, ""), remToDotPtn.ReplaceAllString(rpname, ""))
 				} else {
@@ -284,21 +453,104 @@ func (net *Network) RunToProcs(finalProcs ...Node) {
 
 // runProcs runs a specified set of processes only
 func (net *Network) runProcs(procs map[string]Node) {
+	net.startRunID()
+	net.resetProcDone(procs)
 	net.reconnectDeadEndConnections(procs)
 
+	if forgotten := net.ForgottenProcs(); len(forgotten) > 0 {
+		net.Failf("Processes constructed but never added to the workflow: %s. Did you forget to add them with AddProc?", strings.Join(forgotten, ", "))
+	}
 	if !net.readyToRun(procs) {
 		net.Fail("Network not ready to run, due to previously reported errors, so exiting.")
 	}
-
 	for _, node := range procs {
+		for _, ipt := range node.InPorts() {
+			if b := ipt.budget(); b != nil {
+				ipt.queueBudgeted(b)
+			}
+			if !ipt.Required() {
+				ipt.closeUnconnected()
+			}
+		}
+	}
+	if cycle := net.findCycle(procs); cycle != nil {
+		net.Fail(cycle)
+	}
+	driver := net.selectDriver(procs)
+
+	// All processes are tracked, so that the network only finishes when every
+	// process has returned from Run, not only when the driver has
+	toRun := mergeWFMaps(map[string]Node{net.sink.Name(): net.sink}, procs)
+	running := &sync.WaitGroup{}
+
+	net.publish(&Event{Type: EventNetworkStarted})
+	stopWatching, watching := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(watching)
+		net.watchHighWaterMarks(procs, stopWatching)
+	}()
+	for _, node := range toRun {
+		if node == driver {
+			continue
+		}
 		Debug.Printf(net.name+": Starting process (%s) in new go-routine", node.Name())
-		go node.Run()
+		running.Add(1)
+		go func(node Node) {
+			defer running.Done()
+			net.runProc(node)
+		}(node)
 	}
 
-	Debug.Printf("%s: Starting driver process (%s) in main go-routine", net.name, net.driver.Name())
+	Debug.Printf("%s: Starting driver process (%s) in main go-routine", net.name, driver.Name())
 	net.Auditf("Starting workflow (Writing log to %s)", net.logFile)
-	net.driver.Run()
+	net.runProc(driver)
+	running.Wait()
+	close(stopWatching)
+	<-watching
+	if net.budget != nil {
+		net.budget.cleanup()
+	}
 	net.Auditf("Finished workflow (Log written to %s)", net.logFile)
+	net.publish(&Event{Type: EventNetworkFinished})
+}
+
+// selectDriver returns the process to run in the main go-routine, among
+// procs, which is the one set with SetDriver, if any, and otherwise the
+// first process without out-ports, by name, or else the sink
+func (net *Network) selectDriver(procs map[string]Node) Node {
+	if net.explicitDriver {
+		if _, ok := procs[net.driver.Name()]; !ok && net.driver != Node(net.sink) {
+			net.Failf("Driver process (%s) is not among the processes to run", net.driver.Name())
+		}
+		return net.driver
+	}
+	names := []string{}
+	for name, node := range procs {
+		if len(node.OutPorts()) == 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return net.sink
+	}
+	sort.Strings(names)
+	return procs[names[0]]
+}
+
+// SetDriver sets the process to run in the main go-routine, overriding the
+// default choice of a process without out-ports, or the sink. The network
+// still waits for all other processes without out-ports to finish.
+func (net *Network) SetDriver(node Node) {
+	net.driver = node
+	net.explicitDriver = true
+}
+
+// Driver returns the process set with SetDriver, or nil if none is set
+func (net *Network) Driver() Node {
+	if !net.explicitDriver {
+		return nil
+	}
+	return net.driver
 }
 
 func (net *Network) readyToRun(procs map[string]Node) bool {
@@ -324,12 +576,10 @@ func (net *Network) readyToRun(procs map[string]Node) bool {
 // supposed to be run gets disconnected, its out-port(s) will be connected to
 // the sink instead, to make sure it is properly executed.
 func (net *Network) reconnectDeadEndConnections(procs map[string]Node) {
-	foundNewDriverProc := false
-
 	for _, node := range procs {
 		// OutPorts
 		for _, opt := range node.OutPorts() {
-			for iptName, ipt := range opt.RemotePorts {
+			for iptName, ipt := range opt.ConnectedPorts() {
 				// If the remotely connected process is not among the ones to run ...
 				if ipt.Process() == nil {
 					Debug.Printf("Disconnecting in-port (%s) from out-port (%s)", ipt.Name(), opt.Name())
@@ -344,21 +594,6 @@ func (net *Network) reconnectDeadEndConnections(procs map[string]Node) {
 				net.sink.From(opt)
 			}
 		}
-
-		if len(node.OutPorts()) == 0 {
-			if foundNewDriverProc {
-				net.Failf("Found more than one process without out-ports. Cannot use both as drivers (One of them being '%s'). Adapt your workflow accordingly.", node.Name())
-			}
-			foundNewDriverProc = true
-			net.driver = node
-		}
-	}
-
-	if foundNewDriverProc && len(procs) > 1 { // Allow for a workflow with a single process
-		// A process can't both be the driver and be included in the main procs
-		// map, so if we have an alerative driver, it should not be in the main
-		// procs map
-		delete(net.procs, net.driver.Name())
 	}
 }
 
@@ -366,13 +601,76 @@ func (net *Network) reconnectDeadEndConnections(procs map[string]Node) {
 // directly or indirectly, via its in-ports and param-in-ports
 func upstreamProcsForProc(node Node) map[string]Node {
 	procs := map[string]Node{}
+	addUpstreamProcs(node, procs)
+	return procs
+}
+
+// addUpstreamProcs adds the processes upstream of node to procs, skipping the
+// ones already in it, so that cycles are only followed once
+func addUpstreamProcs(node Node, procs map[string]Node) {
 	for _, inp := range node.InPorts() {
-		for _, rpt := range inp.RemotePorts {
+		for _, rpt := range inp.ConnectedPorts() {
+			if _, ok := procs[rpt.Process().Name()]; ok {
+				continue
+			}
 			procs[rpt.Process().Name()] = rpt.Process()
-			mergeWFMaps(procs, upstreamProcsForProc(rpt.Process()))
+			addUpstreamProcs(rpt.Process(), procs)
+		}
+	}
+}
+
+// runProc runs the process node, publishing events when it starts and
+// finishes, and closing its done channel (see ProcDone) when it has finished
+func (net *Network) runProc(node Node) {
+	net.publish(&Event{Type: EventProcessStarted, Process: node.Name()})
+	net.runLabeled(node)
+	close(net.procDoneChan(node.Name()))
+	net.publish(&Event{Type: EventProcessFinished, Process: node.Name()})
+}
+
+// ProcDone returns a channel which is closed when the process with name
+// procName has finished running, that is, returned from its Run method. When
+// the network is run again, a new channel is returned for the new run.
+func (net *Network) ProcDone(procName string) <-chan struct{} {
+	return net.procDoneChan(procName)
+}
+
+func (net *Network) procDoneChan(procName string) chan struct{} {
+	net.procDoneMx.Lock()
+	defer net.procDoneMx.Unlock()
+	if net.procDone == nil {
+		net.procDone = make(map[string]chan struct{})
+	}
+	done, ok := net.procDone[procName]
+	if !ok {
+		done = make(chan struct{})
+		net.procDone[procName] = done
+	}
+	return done
+}
+
+// resetProcDone replaces the done channels of the processes procs, and of
+// the sink, which were closed by a previous run, so that the network can be
+// run again. Channels not yet closed are kept, as they might already be
+// waited on.
+func (net *Network) resetProcDone(procs map[string]Node) {
+	net.procDoneMx.Lock()
+	defer net.procDoneMx.Unlock()
+	names := []string{net.sink.Name()}
+	for name := range procs {
+		names = append(names, name)
+	}
+	for _, name := range names {
+		done, ok := net.procDone[name]
+		if !ok {
+			continue
+		}
+		select {
+		case <-done:
+			net.procDone[name] = make(chan struct{})
+		default:
 		}
 	}
-	return procs
 }
 
 func mergeWFMaps(a map[string]Node, b map[string]Node) map[string]Node {
@@ -383,7 +681,7 @@ func mergeWFMaps(a map[string]Node, b map[string]Node) map[string]Node {
 }
 
 func (net *Network) Auditf(msg string, parts ...interface{}) {
-	Audit.Printf("[Network:%s] %s\n", net.Name(), fmt.Sprintf(msg, parts...))
+	Audit.Printf("[Network:%s] [Run:%s] %s\n", net.Name(), net.RunID(), fmt.Sprintf(msg, parts...))
 }
 
 func (net *Network) Failf(msg string, parts ...interface{}) {
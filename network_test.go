@@ -1,10 +1,13 @@
 package flowbase
 
 import (
+	"fmt"
 	"os"
 	"os/exec"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestSetWfName(t *testing.T) {
@@ -45,7 +48,7 @@ func (p *MapToTags) Run() {
 	for ip := range p.In().Chan {
 		newTags := p.mapFunc(ip)
 		ip.AddTags(newTags)
-		p.Out().Send(ip)
+		p.Out().SendPacket(ip)
 	}
 }
 
@@ -80,7 +83,7 @@ func (p *FileSource) Run() {
 	defer p.CloseOutPorts()
 	for _, filePath := range p.filePaths {
 		newIP := NewPacket(filePath)
-		p.Out().Send(newIP)
+		p.Out().SendPacket(newIP)
 	}
 }
 
@@ -128,3 +131,222 @@ func ensureFailsProgram(testName string, crasher func(), t *testing.T) {
 	}
 	t.Fatalf("process ran with err %v, want exit status 1", err)
 }
+
+func TestMultipleTerminalProcesses(t *testing.T) {
+	initTestLogs()
+	net := NewNetwork("TestMultipleTerminalProcesses")
+
+	src1 := NewFileSource(net, "src1", "a.txt")
+	src2 := NewFileSource(net, "src2", "b.txt", "c.txt")
+	out1 := NewPacketCollector(net, "out1")
+	out1.In().From(src1.Out())
+	out2 := NewPacketCollector(net, "out2")
+	out2.In().From(src2.Out())
+	net.SetDriver(out2)
+
+	net.Run()
+
+	assertEqualValues(t, []any{"a.txt"}, out1.Data)
+	assertEqualValues(t, []any{"b.txt", "c.txt"}, out2.Data)
+}
+
+// sideEffectProc closes its out-port right away, and then keeps working for a
+// while, only having a side effect
+type sideEffectProc struct {
+	BaseProcess
+	done bool
+}
+
+func (p *sideEffectProc) Run() {
+	p.CloseOutPorts()
+	time.Sleep(50 * time.Millisecond)
+	p.done = true
+}
+
+func TestNetworkWaitsForAllProcesses(t *testing.T) {
+	initTestLogs()
+	net := NewNetwork("TestNetworkWaitsForAllProcesses")
+
+	proc := &sideEffectProc{BaseProcess: NewBaseProcess(net, "sideeffect")}
+	proc.InitOutPort(proc, "out")
+	net.AddProc(proc)
+	src := NewFileSource(net, "src", "a.txt")
+	out := NewPacketCollector(net, "out")
+	out.In().From(src.Out())
+
+	net.Run()
+
+	if !proc.done {
+		t.Errorf("Network finished before all processes had finished")
+	}
+	select {
+	case <-net.ProcDone("sideeffect"):
+	default:
+		t.Errorf("Done channel of process was not closed")
+	}
+}
+
+func TestNetworkRunTwice(t *testing.T) {
+	initTestLogs()
+	net := NewNetwork("TestNetworkRunTwice")
+
+	proc := &sideEffectProc{BaseProcess: NewBaseProcess(net, "sideeffect")}
+	proc.InitOutPort(proc, "out")
+	net.AddProc(proc)
+
+	for i := 0; i < 2; i++ {
+		proc.done = false
+		net.Run()
+
+		if !proc.done {
+			t.Errorf("Run %d finished before the process had finished", i+1)
+		}
+		select {
+		case <-net.ProcDone("sideeffect"):
+		default:
+			t.Errorf("Done channel of process was not closed in run %d", i+1)
+		}
+	}
+}
+
+// upperProc upper-cases strings, and is not added to the network by its
+// constructor
+type upperProc struct {
+	BaseProcess
+}
+
+func newUpperProc(net *Network, name string) *upperProc {
+	p := &upperProc{BaseProcess: NewBaseProcess(net, name)}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	return p
+}
+
+func (p *upperProc) Run() {
+	defer p.CloseOutPorts()
+	for ip, ok := p.InPort("in").RecvOK(); ok; ip, ok = p.InPort("in").RecvOK() {
+		p.OutPort("out").Send(strings.ToUpper(ip.Data().(string)))
+	}
+}
+
+func TestAutoAddProcs(t *testing.T) {
+	initTestLogs()
+	net := NewNetwork("TestAutoAddProcs")
+
+	src := NewFileSource(net, "src", "a.txt")
+	upper := newUpperProc(net, "upper")
+	out := NewPacketCollector(net, "out")
+	net.Chain(src).Then(upper).To(out)
+
+	assertEqualValues(t, upper, net.Proc("upper"))
+	// Adding it explicitly as well does nothing
+	net.AddProc(upper)
+
+	net.Run()
+
+	assertEqualValues(t, []any{"A.TXT"}, out.Data)
+}
+
+func TestAddProcWithNameOfAutoAddedProc(t *testing.T) {
+	ensureFailsProgram("TestAddProcWithNameOfAutoAddedProc", func() {
+		net := NewNetwork("TestAddProcWithNameOfAutoAddedProc")
+		inner := newUpperProc(net, "upper")
+		net.AddProc(&struct{ *upperProc }{inner})
+	}, t)
+}
+
+func TestWrappingProcsAdded(t *testing.T) {
+	initTestLogs()
+	net := NewNetwork("TestWrappingProcsAdded")
+
+	rscript := NewRScriptProc(net, "rscript", "script.R {i:in}")
+	ssh := NewSSHExec(net, "ssh", "echo {i:in}", SSHConfig{Hosts: []string{"host"}})
+
+	assertEqualValues(t, Node(rscript), net.Proc("rscript"))
+	assertEqualValues(t, Node(ssh), net.Proc("ssh"))
+	assertEqualValues(t, Node(ssh), ssh.Stderr().Process())
+	assertEqualValues(t, 2, len(net.Procs()))
+}
+
+func TestConcurrentAutoAddProcs(t *testing.T) {
+	initTestLogs()
+	net := NewNetwork("TestConcurrentAutoAddProcs")
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			newUpperProc(net, fmt.Sprintf("upper%d", i))
+		}(i)
+	}
+	wg.Wait()
+
+	assertEqualValues(t, 20, len(net.Procs()))
+	assertEqualValues(t, []string{}, net.ForgottenProcs())
+}
+
+func TestSetAutoAddProcs(t *testing.T) {
+	initTestLogs()
+	net := NewNetwork("TestSetAutoAddProcs")
+	net.SetAutoAddProcs(false)
+
+	newUpperProc(net, "upper")
+
+	assertEqualValues(t, 0, len(net.Procs()))
+}
+
+func TestForgottenProcs(t *testing.T) {
+	initTestLogs()
+	net := NewNetwork("TestForgottenProcs")
+	net.SetAutoAddProcs(false)
+
+	src := NewFileSource(net, "src", "a.txt")
+	upper := newUpperProc(net, "upper")
+	out := NewPacketCollector(net, "out")
+	net.Chain(src).Then(upper).To(out)
+	idle := &sideEffectProc{BaseProcess: NewBaseProcess(net, "idle")}
+
+	assertEqualValues(t, []string{"upper", "idle"}, net.ForgottenProcs())
+
+	net.AddProcs(upper, idle)
+	assertEqualValues(t, []string{}, net.ForgottenProcs())
+}
+
+func TestRunFailsWithForgottenProcs(t *testing.T) {
+	ensureFailsProgram("TestRunFailsWithForgottenProcs", func() {
+		net := NewNetwork("TestRunFailsWithForgottenProcs")
+		src := NewFileSource(net, "src", "a.txt")
+		out := NewPacketCollector(net, "out")
+		out.In().From(src.Out())
+		NewBaseProcess(net, "forgotten")
+		net.Run()
+	}, t)
+}
+
+func TestRunIDPerRun(t *testing.T) {
+	initTestLogs()
+	net := NewNetwork("TestRunIDPerRun")
+	proc := &sideEffectProc{BaseProcess: NewBaseProcess(net, "sideeffect")}
+	proc.InitOutPort(proc, "out")
+	net.AddProc(proc)
+
+	// A run ID returned before the first run is used by that run
+	first := net.RunID()
+	net.Run()
+	assertEqualValues(t, first, net.RunID())
+
+	net.Run()
+	second := net.RunID()
+	if second == first {
+		t.Errorf("Run ID %s was reused by a second run", first)
+	}
+
+	net.SetRunID("run-3")
+	net.Run()
+	assertEqualValues(t, "run-3", net.RunID())
+	net.Run()
+	if net.RunID() == "run-3" || net.RunID() == second {
+		t.Errorf("Run ID %s was reused by a later run", net.RunID())
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !race && !flowbase_debug
+
+package flowbase
+
+// No-op versions of the ownership checks, which are only enabled in builds
+// with the race detector or the flowbase_debug build tag (see
+// ownership_debug.go)
+
+func trackOwnership(pt *OutPort, rpt *InPort, data any) {}
+
+func releaseOwnership(pt *InPort, ip *Packet) {}
+
+func checkFanOutSharing(pt *OutPort, data any) {}
@@ -0,0 +1,75 @@
+//go:build race || flowbase_debug
+
+package flowbase
+
+import (
+	"reflect"
+	"sync"
+)
+
+// Ownership tracking of data sent with OutPort.Transfer, only enabled in
+// builds with the race detector or the flowbase_debug build tag, as it
+// comes with a cost.
+
+var (
+	dataOwners   = map[uintptr]string{}
+	dataOwnersMx sync.Mutex
+)
+
+// trackOwnership records the process of the in-port rpt as the new owner of
+// data, and reports if the sending process was not the owner of it
+func trackOwnership(pt *OutPort, rpt *InPort, data any) {
+	ptr, ok := dataPointer(data)
+	if !ok {
+		return
+	}
+	sender := pt.Process().Name()
+	dataOwnersMx.Lock()
+	defer dataOwnersMx.Unlock()
+	if owner, ok := dataOwners[ptr]; ok && owner != sender {
+		Warning.Printf("[Out-Port:%s] Process (%s) transferred data (%T at %#x) which is owned by process (%s). Data must not be used after it has been transferred!\n", pt.Name(), sender, data, ptr, owner)
+	}
+	dataOwners[ptr] = rpt.Process().Name()
+}
+
+// releaseOwnership stops tracking the data of ip, once it has been received
+// by its new owner, on the in-port pt, so that data is not kept track of
+// forever
+func releaseOwnership(pt *InPort, ip *Packet) {
+	ptr, ok := dataPointer(ip.Data())
+	if !ok || pt.process == nil {
+		return
+	}
+	dataOwnersMx.Lock()
+	defer dataOwnersMx.Unlock()
+	if owner, ok := dataOwners[ptr]; ok && owner == pt.process.Name() {
+		delete(dataOwners, ptr)
+	}
+}
+
+// checkFanOutSharing reports when mutable data is sent to more than one
+// in-port without being cloned
+func checkFanOutSharing(pt *OutPort, data any) {
+	if len(pt.remotes()) < 2 {
+		return
+	}
+	if _, ok := dataPointer(data); !ok {
+		return
+	}
+	if _, isCloner := data.(Cloner); pt.cloneOnFanOut && isCloner {
+		return
+	}
+	Warning.Printf("[Out-Port:%s] Data (%T) is shared between %d in-ports. Implement Cloner and use SetCloneOnFanOut(true) to avoid concurrent mutation.\n", pt.Name(), data, len(pt.remotes()))
+}
+
+func dataPointer(data any) (uintptr, bool) {
+	v := reflect.ValueOf(data)
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			return 0, false
+		}
+		return v.Pointer(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,26 @@
+//go:build race || flowbase_debug
+
+package flowbase
+
+import "testing"
+
+func TestReleaseOwnership(t *testing.T) {
+	initTestLogs()
+	outp, inps, _ := newFanOut(1)
+	buf := []byte("data")
+	ptr, _ := dataPointer(buf)
+	outp.Transfer(buf)
+
+	dataOwnersMx.Lock()
+	owner := dataOwners[ptr]
+	dataOwnersMx.Unlock()
+	assertEqualValues(t, "dst0", owner)
+
+	inps[0].Recv()
+	dataOwnersMx.Lock()
+	_, tracked := dataOwners[ptr]
+	dataOwnersMx.Unlock()
+	if tracked {
+		t.Errorf("Data is still tracked after it was received")
+	}
+}
@@ -20,6 +20,11 @@ type Packet struct {
 	id        string
 	auditInfo *AuditInfo
 	tags      map[string]string
+	// size is the approximate size set with SetSize, if any
+	size int64
+	// queued is the size accounted for in the memory budget of the network,
+	// while the packet is queued in an in-port
+	queued int64
 }
 
 // NewPacket creates a new Packet
@@ -31,15 +36,62 @@ func NewPacket(data any) *Packet {
 	}
 }
 
+// NewDerivedPacket creates a new Packet with the data data, derived from the
+// packet ip, whose tags it gets a copy of, such as for the result of a
+// transformation of ip
+func NewDerivedPacket(ip *Packet, data any) *Packet {
+	newIP := NewPacket(data)
+	newIP.AddTags(ip.Tags())
+	return newIP
+}
+
 // ID returns a globally unique ID for the IP
 func (ip *Packet) ID() string {
 	return ip.id
 }
 
+// Data returns the data payload of the packet
 func (ip *Packet) Data() any {
 	return ip.data
 }
 
+// AuditInfo returns the audit info of the packet, or nil if none is set
+func (ip *Packet) AuditInfo() *AuditInfo {
+	return ip.auditInfo
+}
+
+// SetAuditInfo sets the audit info of the packet
+func (ip *Packet) SetAuditInfo(ai *AuditInfo) {
+	ip.auditInfo = ai
+}
+
+// Cloner can be implemented by packet data types that are able to make deep
+// copies of themselves. It is used by Packet.Clone(), and when sending data
+// on out-ports connected to more than one in-port.
+type Cloner interface {
+	Clone() any
+}
+
+// Clone returns a copy of the packet, with a new ID and a copy of the tags.
+// If the data implements Cloner, it is deep-copied, otherwise the clone will
+// share the data with the original packet.
+func (ip *Packet) Clone() *Packet {
+	newIP := NewPacket(cloneData(ip.data))
+	for k, v := range ip.tags {
+		newIP.tags[k] = v
+	}
+	newIP.auditInfo = ip.auditInfo
+	newIP.size = ip.size
+	return newIP
+}
+
+func cloneData(data any) any {
+	if c, ok := data.(Cloner); ok {
+		return c.Clone()
+	}
+	return data
+}
+
 // ------------------------------------------------------------------------
 // Tags stuff
 // ------------------------------------------------------------------------
@@ -0,0 +1,272 @@
+// Package pbwire contains minimal helpers for reading and writing the
+// protocol buffers wire format, as used by the protobuf packet codec in
+// flowbase and by code generated with `flowbase gen proto`. It only depends
+// on the standard library.
+package pbwire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Wire types, as defined by the protocol buffers encoding specification
+const (
+	WireVarint  = 0
+	WireFixed64 = 1
+	WireBytes   = 2
+	WireFixed32 = 5
+)
+
+// ------------------------------------------------------------------------
+// Buffer
+// ------------------------------------------------------------------------
+
+// Buffer accumulates an encoded protobuf message
+type Buffer struct {
+	b []byte
+}
+
+// Bytes returns the encoded message
+func (buf *Buffer) Bytes() []byte {
+	return buf.b
+}
+
+func (buf *Buffer) appendUvarint(v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	buf.b = append(buf.b, tmp[:n]...)
+}
+
+func (buf *Buffer) appendTag(field int, wireType int) {
+	buf.appendUvarint(uint64(field)<<3 | uint64(wireType))
+}
+
+// Uint64 writes an unsigned varint field
+func (buf *Buffer) Uint64(field int, v uint64) {
+	buf.appendTag(field, WireVarint)
+	buf.appendUvarint(v)
+}
+
+// Int64 writes a signed (non-zigzag, i.e. proto int64) varint field
+func (buf *Buffer) Int64(field int, v int64) {
+	buf.Uint64(field, uint64(v))
+}
+
+// Bool writes a bool field
+func (buf *Buffer) Bool(field int, v bool) {
+	if v {
+		buf.Uint64(field, 1)
+	} else {
+		buf.Uint64(field, 0)
+	}
+}
+
+// Double writes a float64 field
+func (buf *Buffer) Double(field int, v float64) {
+	buf.appendTag(field, WireFixed64)
+	var tmp [8]byte
+	binary.LittleEndian.PutUint64(tmp[:], math.Float64bits(v))
+	buf.b = append(buf.b, tmp[:]...)
+}
+
+// Float writes a float32 field
+func (buf *Buffer) Float(field int, v float32) {
+	buf.appendTag(field, WireFixed32)
+	var tmp [4]byte
+	binary.LittleEndian.PutUint32(tmp[:], math.Float32bits(v))
+	buf.b = append(buf.b, tmp[:]...)
+}
+
+// RawBytes writes a length-delimited bytes field
+func (buf *Buffer) RawBytes(field int, v []byte) {
+	buf.appendTag(field, WireBytes)
+	buf.appendUvarint(uint64(len(v)))
+	buf.b = append(buf.b, v...)
+}
+
+// String writes a string field
+func (buf *Buffer) String(field int, v string) {
+	buf.RawBytes(field, []byte(v))
+}
+
+// StringMap writes a map<string, string> field, as repeated entry messages
+func (buf *Buffer) StringMap(field int, m map[string]string) {
+	for k, v := range m {
+		entry := &Buffer{}
+		entry.String(1, k)
+		entry.String(2, v)
+		buf.RawBytes(field, entry.Bytes())
+	}
+}
+
+// AppendDelimited appends msg to b, prefixed with its length as a varint, which
+// is the conventional way to write a stream of protobuf messages
+func AppendDelimited(b []byte, msg []byte) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], uint64(len(msg)))
+	b = append(b, tmp[:n]...)
+	return append(b, msg...)
+}
+
+// ------------------------------------------------------------------------
+// Reader
+// ------------------------------------------------------------------------
+
+// ErrTruncated is returned when a message ends in the middle of a field
+var ErrTruncated = errors.New("pbwire: truncated message")
+
+// Reader reads fields from an encoded protobuf message
+type Reader struct {
+	b []byte
+}
+
+// NewReader returns a Reader for the encoded message b
+func NewReader(b []byte) *Reader {
+	return &Reader{b: b}
+}
+
+// Done tells whether all of the message has been read
+func (r *Reader) Done() bool {
+	return len(r.b) == 0
+}
+
+// Next reads the tag of the next field, returning its field number and wire
+// type
+func (r *Reader) Next() (field int, wireType int, err error) {
+	v, err := r.Uint64()
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(v >> 3), int(v & 7), nil
+}
+
+// Uint64 reads a varint value
+func (r *Reader) Uint64() (uint64, error) {
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		return 0, ErrTruncated
+	}
+	r.b = r.b[n:]
+	return v, nil
+}
+
+// Int64 reads a signed (non-zigzag) varint value
+func (r *Reader) Int64() (int64, error) {
+	v, err := r.Uint64()
+	return int64(v), err
+}
+
+// Bool reads a bool value
+func (r *Reader) Bool() (bool, error) {
+	v, err := r.Uint64()
+	return v != 0, err
+}
+
+// Double reads a float64 value
+func (r *Reader) Double() (float64, error) {
+	if len(r.b) < 8 {
+		return 0, ErrTruncated
+	}
+	v := math.Float64frombits(binary.LittleEndian.Uint64(r.b))
+	r.b = r.b[8:]
+	return v, nil
+}
+
+// Float reads a float32 value
+func (r *Reader) Float() (float32, error) {
+	if len(r.b) < 4 {
+		return 0, ErrTruncated
+	}
+	v := math.Float32frombits(binary.LittleEndian.Uint32(r.b))
+	r.b = r.b[4:]
+	return v, nil
+}
+
+// RawBytes reads a length-delimited value
+func (r *Reader) RawBytes() ([]byte, error) {
+	l, err := r.Uint64()
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(r.b)) < l {
+		return nil, ErrTruncated
+	}
+	v := r.b[:l]
+	r.b = r.b[l:]
+	return v, nil
+}
+
+// String reads a string value
+func (r *Reader) String() (string, error) {
+	v, err := r.RawBytes()
+	return string(v), err
+}
+
+// StringMapEntry reads one map<string, string> entry and stores it in m
+func (r *Reader) StringMapEntry(m map[string]string) error {
+	b, err := r.RawBytes()
+	if err != nil {
+		return err
+	}
+	er := NewReader(b)
+	k, v := "", ""
+	for !er.Done() {
+		field, wireType, err := er.Next()
+		if err != nil {
+			return err
+		}
+		switch {
+		case field == 1 && wireType == WireBytes:
+			k, err = er.String()
+		case field == 2 && wireType == WireBytes:
+			v, err = er.String()
+		default:
+			err = er.Skip(wireType)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	m[k] = v
+	return nil
+}
+
+// Skip skips over a value of the given wire type, such as for unknown fields
+func (r *Reader) Skip(wireType int) error {
+	var err error
+	switch wireType {
+	case WireVarint:
+		_, err = r.Uint64()
+	case WireFixed64:
+		_, err = r.Double()
+	case WireFixed32:
+		_, err = r.Float()
+	case WireBytes:
+		_, err = r.RawBytes()
+	default:
+		err = fmt.Errorf("pbwire: unsupported wire type %d", wireType)
+	}
+	return err
+}
+
+// Repeated reads one or more values of a repeated scalar field by calling each
+// once per value. This handles both the packed encoding (wire type bytes),
+// which is the default in proto3, and the unpacked one.
+func (r *Reader) Repeated(wireType int, each func(*Reader) error) error {
+	if wireType != WireBytes {
+		return each(r)
+	}
+	b, err := r.RawBytes()
+	if err != nil {
+		return err
+	}
+	pr := NewReader(b)
+	for !pr.Done() {
+		if err := each(pr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package pbwire
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	buf := &Buffer{}
+	buf.String(1, "abc")
+	buf.Int64(2, -42)
+	buf.Uint64(3, 1<<40)
+	buf.Bool(4, true)
+	buf.Double(5, 1.5)
+	buf.Float(6, 0.25)
+	buf.RawBytes(7, []byte{0, 1, 2})
+	buf.StringMap(8, map[string]string{"a": "1", "b": "2"})
+	// A packed repeated field, as written by proto3 encoders
+	packed := &Buffer{}
+	packed.appendUvarint(1)
+	packed.appendUvarint(300)
+	buf.RawBytes(9, packed.Bytes())
+	buf.Uint64(10, 7)
+	buf.String(99, "unknown")
+
+	got := map[int]any{}
+	m := map[string]string{}
+	repeated := []uint64{}
+	r := NewReader(buf.Bytes())
+	for !r.Done() {
+		field, wireType, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch field {
+		case 1:
+			got[field], err = r.String()
+		case 2:
+			got[field], err = r.Int64()
+		case 3:
+			got[field], err = r.Uint64()
+		case 4:
+			got[field], err = r.Bool()
+		case 5:
+			got[field], err = r.Double()
+		case 6:
+			got[field], err = r.Float()
+		case 7:
+			got[field], err = r.RawBytes()
+		case 8:
+			err = r.StringMapEntry(m)
+		case 9, 10:
+			err = r.Repeated(wireType, func(r *Reader) error {
+				v, err := r.Uint64()
+				repeated = append(repeated, v)
+				return err
+			})
+		default:
+			err = r.Skip(wireType)
+		}
+		if err != nil {
+			t.Fatalf("Could not read field %d: %v", field, err)
+		}
+	}
+
+	want := map[int]any{1: "abc", 2: int64(-42), 3: uint64(1 << 40), 4: true, 5: 1.5, 6: float32(0.25), 7: []byte{0, 1, 2}}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Got wrong fields: %v, wanted: %v", got, want)
+	}
+	if !reflect.DeepEqual(map[string]string{"a": "1", "b": "2"}, m) {
+		t.Errorf("Got wrong map: %v", m)
+	}
+	if !reflect.DeepEqual([]uint64{1, 300, 7}, repeated) {
+		t.Errorf("Got wrong repeated values: %v", repeated)
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	buf := &Buffer{}
+	buf.String(1, "abc")
+	r := NewReader(buf.Bytes()[:3])
+	if _, _, err := r.Next(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.String(); err != ErrTruncated {
+		t.Errorf("Expected ErrTruncated, got: %v", err)
+	}
+}
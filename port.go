@@ -13,20 +13,37 @@ import (
 // processes, from its own process, and with which it is communicating via
 // channels under the hood
 type InPort struct {
-	Chan        chan *Packet
-	name        string
-	process     Node
-	RemotePorts map[string]*OutPort
+	Chan    chan *Packet
+	name    string
+	process Node
+	// mx protects the connection state: remotePorts and ready
+	mx          sync.RWMutex
+	remotePorts map[string]*OutPort
 	ready       bool
-	closeLock   sync.Mutex
+	closed      bool
+	rings       *ringInput
+	validator   Validator
+	invalid     *OutPort
+	feedback    bool
+	conflate    bool
+	// conflateMx serializes senders in conflating mode
+	conflateMx sync.Mutex
+	// info is the declared type, description, multiplicity and whether
+	// the port is optional (see PortInfo)
+	info portInfo
+	// hwm is the high-water mark of the queue, if any (see SetHighWaterMark)
+	hwm *highWaterMark
+	// queue is where packets are queued, before being handed over on Chan,
+	// for in-ports under a memory budget (see queueBudgeted)
+	queue chan *Packet
 }
 
 // NewInPort returns a new InPort struct
 func NewInPort(name string) *InPort {
 	inp := &InPort{
 		name:        name,
-		RemotePorts: map[string]*OutPort{},
-		Chan:        make(chan *Packet, getBufsize()), // This one will contain merged inputs from inChans
+		remotePorts: map[string]*OutPort{},
+		Chan:        make(chan *Packet, BUFSIZE), // This one will contain merged inputs from inChans
 		ready:       false,
 	}
 	return inp
@@ -37,6 +54,16 @@ func (pt *InPort) Name() string {
 	return pt.name
 }
 
+// FullName returns the name of the InPort, prefixed by the name of its
+// process, if any, such as "procname.portname". It is used as key in the
+// remote ports maps, as port names are only unique within a process.
+func (pt *InPort) FullName() string {
+	if pt.process == nil {
+		return pt.name
+	}
+	return pt.process.Name() + "." + pt.name
+}
+
 // Process returns the process connected to the port
 func (pt *InPort) Process() Node {
 	if pt.process == nil {
@@ -50,12 +77,53 @@ func (pt *InPort) SetProcess(p Node) {
 	pt.process = p
 }
 
+// ConnectedPorts returns the out-ports connected to the InPort, keyed by
+// their full names, such as "procname.portname". The returned map is a copy,
+// which is safe to use while ports are being connected and disconnected
+// concurrently.
+func (pt *InPort) ConnectedPorts() map[string]*OutPort {
+	pt.mx.RLock()
+	defer pt.mx.RUnlock()
+	rpts := make(map[string]*OutPort, len(pt.remotePorts))
+	for name, rpt := range pt.remotePorts {
+		rpts[name] = rpt
+	}
+	return rpts
+}
+
+// RemotePorts returns the out-ports connected to the InPort, keyed by their
+// port names only, as the RemotePorts field it replaces was. Ports with the
+// same name, of different processes, hide each other.
+//
+// Deprecated: Use ConnectedPorts, which is keyed by the full names of the
+// ports (see the CHANGELOG).
+func (pt *InPort) RemotePorts() map[string]*OutPort {
+	rpts := map[string]*OutPort{}
+	for _, rpt := range pt.ConnectedPorts() {
+		rpts[rpt.Name()] = rpt
+	}
+	return rpts
+}
+
+// rekeyRemotePort changes the key of a connected out-port, after its full
+// name has changed from oldName to newName
+func (pt *InPort) rekeyRemotePort(oldName string, newName string) {
+	pt.mx.Lock()
+	defer pt.mx.Unlock()
+	if rpt, ok := pt.remotePorts[oldName]; ok {
+		delete(pt.remotePorts, oldName)
+		pt.remotePorts[newName] = rpt
+	}
+}
+
 // AddRemotePort adds a remote OutPort to the InPort
 func (pt *InPort) AddRemotePort(rpt *OutPort) {
-	if pt.RemotePorts[rpt.Name()] != nil {
-		pt.Failf("A remote port with name (%s) already exists", rpt.Name())
+	pt.mx.Lock()
+	defer pt.mx.Unlock()
+	if pt.remotePorts[rpt.FullName()] != nil {
+		pt.Failf("A remote port with name (%s) already exists", rpt.FullName())
 	}
-	pt.RemotePorts[rpt.Name()] = rpt
+	pt.remotePorts[rpt.FullName()] = rpt
 }
 
 // From connects an OutPort to the InPort
@@ -67,52 +135,217 @@ func (pt *InPort) From(rpt *OutPort) {
 	rpt.SetReady(true)
 }
 
-// Disconnect disconnects the (out-)port with name rptName, from the InPort
+// Disconnect disconnects the (out-)port with full name rptName, or with the
+// unambiguous port name rptName, from the InPort
 func (pt *InPort) Disconnect(rptName string) {
+	pt.mx.Lock()
+	defer pt.mx.Unlock()
 	pt.removeRemotePort(rptName)
-	if len(pt.RemotePorts) == 0 {
-		pt.SetReady(false)
+	if len(pt.remotePorts) == 0 {
+		pt.ready = false
 	}
 }
 
-// removeRemotePort removes the (out-)port with name rptName, from the InPort
+// removeRemotePort removes the (out-)port with name rptName, from the InPort.
+// The caller must hold the lock.
 func (pt *InPort) removeRemotePort(rptName string) {
-	if _, ok := pt.RemotePorts[rptName]; !ok {
+	rptName = remotePortKey(pt.remotePorts, rptName)
+	if _, ok := pt.remotePorts[rptName]; !ok {
 		pt.Failf("No remote port with name (%s) exists", rptName)
 	}
-	delete(pt.RemotePorts, rptName)
+	delete(pt.remotePorts, rptName)
+}
+
+// remotePortKey returns the key in remotePorts of the connected port with
+// the full name rptName, or, for callers still using port names only, of
+// the one connected port with the port name rptName, if there is one
+func remotePortKey[P interface{ Name() string }](remotePorts map[string]P, rptName string) string {
+	if _, ok := remotePorts[rptName]; ok {
+		return rptName
+	}
+	key := rptName
+	matches := 0
+	for fullName, rpt := range remotePorts {
+		if rpt.Name() == rptName {
+			key = fullName
+			matches++
+		}
+	}
+	if matches != 1 {
+		return rptName
+	}
+	return key
 }
 
 // SetReady sets the ready status of the InPort
 func (pt *InPort) SetReady(ready bool) {
+	pt.mx.Lock()
+	defer pt.mx.Unlock()
 	pt.ready = ready
 }
 
 // Ready tells whether the port is ready or not
 func (pt *InPort) Ready() bool {
+	pt.mx.RLock()
+	defer pt.mx.RUnlock()
 	return pt.ready
 }
 
+// SetRingBuffer makes the in-port use lock-free ring buffers with room for
+// size packets, instead of a Go channel, with one ring buffer per incoming
+// connection. This can reduce latency in pipelines where channel overhead
+// dominates. It must be called before the network is run, and since the Chan
+// field is not used in this mode, packets must be received with Recv, RecvOK
+// or Select.
+func (pt *InPort) SetRingBuffer(size int) {
+	pt.rings = newRingInput(size)
+}
+
+// SetConflate makes the in-port keep only the most recent packet, instead of
+// queueing them, so that when its process is slower than the processes
+// sending to it, older packets are dropped rather than blocking the senders.
+// This suits sinks which only care about the latest value, such as displays.
+// It must be called before the network is run, and overrides SetRingBuffer.
+func (pt *InPort) SetConflate(conflate bool) {
+	pt.conflate = conflate
+	if conflate {
+		pt.rings = nil
+		pt.Chan = make(chan *Packet, 1)
+	} else {
+		pt.Chan = make(chan *Packet, BUFSIZE)
+	}
+}
+
 // Send sends IPs to the in-port, and is supposed to be called from the remote
 // (out-) port, to send to this in-port
 func (pt *InPort) Send(ip *Packet) {
+	pt.send(nil, ip)
+}
+
+// send sends ip to the in-port, from the out-port from, which is nil if the
+// packet is not sent from an out-port
+func (pt *InPort) send(from *OutPort, ip *Packet) {
+	if pt.validator != nil && !pt.validate(ip) {
+		return
+	}
+	if b := pt.budget(); b != nil {
+		ip = b.acquire(ip)
+	}
+	if pt.rings != nil {
+		pt.rings.put(from, ip)
+		return
+	}
+	if pt.conflate {
+		pt.sendConflated(ip)
+		return
+	}
+	if pt.queue != nil {
+		pt.queue <- ip
+		return
+	}
 	pt.Chan <- ip
 }
 
-// Recv receives IPs from the port
+// sendConflated sends ip to the in-port, replacing the packet waiting to be
+// received, if there is one
+func (pt *InPort) sendConflated(ip *Packet) {
+	pt.conflateMx.Lock()
+	defer pt.conflateMx.Unlock()
+	for {
+		select {
+		case pt.Chan <- ip:
+			return
+		default:
+		}
+		select {
+		case old := <-pt.Chan:
+			Debug.Printf("[In-Port:%s] Dropped packet (%s), replaced by a newer one\n", pt.FullName(), old.ID())
+		default:
+		}
+	}
+}
+
+// Len returns the number of packets queued in the in-port, waiting to be
+// received
+func (pt *InPort) Len() int {
+	if pt.rings != nil {
+		return pt.rings.len()
+	}
+	if pt.queue != nil {
+		return len(pt.queue)
+	}
+	return len(pt.Chan)
+}
+
+// Recv receives IPs from the port. It returns nil when the port is closed.
 func (pt *InPort) Recv() *Packet {
-	return <-pt.Chan
+	ip, _ := pt.RecvOK()
+	return ip
+}
+
+// RecvOK receives IPs from the port, with ok being false when the port is
+// closed and there are no more IPs to receive
+func (pt *InPort) RecvOK() (ip *Packet, ok bool) {
+	if pt.rings != nil {
+		ip, ok = pt.rings.get()
+	} else {
+		ip, ok = <-pt.Chan
+	}
+	if ok {
+		ip = pt.received(ip)
+	}
+	return ip, ok
 }
 
-// CloseConnection closes the connection to the remote out-port with name
-// rptName, on the InPort
+// received does the bookkeeping for the packet ip having been received from
+// the in-port, and returns the packet to hand over to the process
+func (pt *InPort) received(ip *Packet) *Packet {
+	if b := pt.budget(); b != nil {
+		var err error
+		if ip, err = b.release(ip); err != nil {
+			pt.Fail(err)
+		}
+	}
+	releaseOwnership(pt, ip)
+	return ip
+}
+
+// CloseConnection closes the connection to the remote out-port with full
+// name rptName, or with the unambiguous port name rptName, on the InPort.
+// When the last connection is closed, the InPort is closed too. Closing a
+// connection that is not open, or closing an already closed InPort, only logs
+// a warning, so it is safe to call more than once.
 func (pt *InPort) CloseConnection(rptName string) {
-	pt.closeLock.Lock()
-	delete(pt.RemotePorts, rptName)
-	if len(pt.RemotePorts) == 0 {
+	pt.mx.Lock()
+	defer pt.mx.Unlock()
+	if pt.closed {
+		Warning.Printf("[In-Port:%s] Connection to (%s) closed on already closed port\n", pt.FullName(), rptName)
+		return
+	}
+	rptName = remotePortKey(pt.remotePorts, rptName)
+	if _, ok := pt.remotePorts[rptName]; !ok {
+		Warning.Printf("[In-Port:%s] Closing connection to (%s), which is not connected\n", pt.FullName(), rptName)
+		return
+	}
+	delete(pt.remotePorts, rptName)
+	if len(pt.remotePorts) == 0 {
+		pt.closed = true
+		pt.closeQueue()
+	}
+}
+
+// closeQueue closes the queue of the in-port, so that receivers get the
+// packets left in it, and are then told that it is closed. The caller must
+// hold the lock.
+func (pt *InPort) closeQueue() {
+	switch {
+	case pt.rings != nil:
+		pt.rings.close()
+	case pt.queue != nil:
+		close(pt.queue)
+	default:
 		close(pt.Chan)
 	}
-	pt.closeLock.Unlock()
 }
 
 // Failf fails with a message that includes the process name
@@ -133,17 +366,25 @@ func (pt *InPort) Fail(msg interface{}) {
 // processes, from its own process, and with which it is communicating via
 // channels under the hood
 type OutPort struct {
-	name        string
-	process     Node
-	RemotePorts map[string]*InPort
-	ready       bool
+	name    string
+	process Node
+	// mx protects the connection state: remotePorts, remoteList and ready
+	mx          sync.RWMutex
+	remotePorts map[string]*InPort
+	// remoteList holds the same ports as remotePorts, in the order they were
+	// connected. It is replaced rather than modified on changes, so that
+	// senders can iterate over it without holding the lock.
+	remoteList    []*InPort
+	ready         bool
+	cloneOnFanOut bool
+	info          portInfo
 }
 
 // NewOutPort returns a new OutPort struct
 func NewOutPort(name string) *OutPort {
 	outp := &OutPort{
 		name:        name,
-		RemotePorts: map[string]*InPort{},
+		remotePorts: map[string]*InPort{},
 		ready:       false,
 	}
 	return outp
@@ -154,6 +395,16 @@ func (pt *OutPort) Name() string {
 	return pt.name
 }
 
+// FullName returns the name of the OutPort, prefixed by the name of its
+// process, if any, such as "procname.portname". It is used as key in the
+// remote ports maps, as port names are only unique within a process.
+func (pt *OutPort) FullName() string {
+	if pt.process == nil {
+		return pt.name
+	}
+	return pt.process.Name() + "." + pt.name
+}
+
 // Process returns the process connected to the port
 func (pt *OutPort) Process() Node {
 	if pt.process == nil {
@@ -167,20 +418,79 @@ func (pt *OutPort) SetProcess(p Node) {
 	pt.process = p
 }
 
+// ConnectedPorts returns the in-ports connected to the OutPort, keyed by
+// their full names, such as "procname.portname". The returned map is a copy,
+// which is safe to use while ports are being connected and disconnected
+// concurrently.
+func (pt *OutPort) ConnectedPorts() map[string]*InPort {
+	pt.mx.RLock()
+	defer pt.mx.RUnlock()
+	rpts := make(map[string]*InPort, len(pt.remotePorts))
+	for name, rpt := range pt.remotePorts {
+		rpts[name] = rpt
+	}
+	return rpts
+}
+
+// RemotePorts returns the in-ports connected to the OutPort, keyed by their
+// port names only, as the RemotePorts field it replaces was. Ports with the
+// same name, of different processes, hide each other.
+//
+// Deprecated: Use ConnectedPorts, which is keyed by the full names of the
+// ports (see the CHANGELOG).
+func (pt *OutPort) RemotePorts() map[string]*InPort {
+	rpts := map[string]*InPort{}
+	for _, rpt := range pt.ConnectedPorts() {
+		rpts[rpt.Name()] = rpt
+	}
+	return rpts
+}
+
+// remotes returns the connected in-ports, in the order they were connected
+func (pt *OutPort) remotes() []*InPort {
+	pt.mx.RLock()
+	defer pt.mx.RUnlock()
+	return pt.remoteList
+}
+
+// rekeyRemotePort changes the key of a connected in-port, after its full
+// name has changed from oldName to newName
+func (pt *OutPort) rekeyRemotePort(oldName string, newName string) {
+	pt.mx.Lock()
+	defer pt.mx.Unlock()
+	if rpt, ok := pt.remotePorts[oldName]; ok {
+		delete(pt.remotePorts, oldName)
+		pt.remotePorts[newName] = rpt
+	}
+}
+
 // AddRemotePort adds a remote InPort to the OutPort
 func (pt *OutPort) AddRemotePort(rpt *InPort) {
-	if _, ok := pt.RemotePorts[rpt.Name()]; ok {
-		pt.Failf("A remote port with name (%s) already exists", rpt.Name())
+	pt.mx.Lock()
+	defer pt.mx.Unlock()
+	if _, ok := pt.remotePorts[rpt.FullName()]; ok {
+		pt.Failf("A remote port with name (%s) already exists", rpt.FullName())
 	}
-	pt.RemotePorts[rpt.Name()] = rpt
+	pt.remotePorts[rpt.FullName()] = rpt
+	pt.remoteList = append(append([]*InPort{}, pt.remoteList...), rpt)
 }
 
-// removeRemotePort removes the (in-)port with name rptName, from the OutPort
+// removeRemotePort removes the (in-)port with name rptName, from the
+// OutPort. The caller must hold the lock.
 func (pt *OutPort) removeRemotePort(rptName string) {
-	if _, ok := pt.RemotePorts[rptName]; !ok {
+	rptName = remotePortKey(pt.remotePorts, rptName)
+	rpt, ok := pt.remotePorts[rptName]
+	if !ok {
 		pt.Failf("No remote port with name (%s) exists", rptName)
 	}
-	delete(pt.RemotePorts, rptName)
+	delete(pt.remotePorts, rptName)
+	remoteList := []*InPort{}
+	for _, r := range pt.remoteList {
+		if r != rpt {
+			remoteList = append(remoteList, r)
+		}
+	}
+	pt.remoteList = remoteList
 }
 
 // To connects an InPort to the OutPort
@@ -192,41 +502,147 @@ func (pt *OutPort) To(rpt *InPort) {
 	rpt.SetReady(true)
 }
 
-// Disconnect disconnects the (in-)port with name rptName, from the OutPort
+// Disconnect disconnects the (in-)port with full name rptName, or with the
+// unambiguous port name rptName, from the OutPort
 func (pt *OutPort) Disconnect(rptName string) {
+	pt.mx.Lock()
+	defer pt.mx.Unlock()
 	pt.removeRemotePort(rptName)
-	if len(pt.RemotePorts) == 0 {
-		pt.SetReady(false)
+	if len(pt.remotePorts) == 0 {
+		pt.ready = false
 	}
 }
 
 // SetReady sets the ready status of the OutPort
 func (pt *OutPort) SetReady(ready bool) {
+	pt.mx.Lock()
+	defer pt.mx.Unlock()
 	pt.ready = ready
 }
 
 // Ready tells whether the port is ready or not
 func (pt *OutPort) Ready() bool {
+	pt.mx.RLock()
+	defer pt.mx.RUnlock()
 	return pt.ready
 }
 
-// Send sends an Packet to all the in-ports connected to the OutPort
+// QueueDepth returns the largest number of packets queued in any of the
+// in-ports connected to the OutPort, which tells how far behind the slowest
+// downstream process is
+func (pt *OutPort) QueueDepth() int {
+	depth := 0
+	for _, rpt := range pt.remotes() {
+		if n := rpt.Len(); n > depth {
+			depth = n
+		}
+	}
+	return depth
+}
+
+// SetCloneOnFanOut sets whether data sent on the OutPort should be cloned for
+// each additional in-port, when connected to more than one in-port. This
+// requires the data to implement Cloner, and prevents downstream processes
+// from sharing (and concurrently mutating) the same data.
+func (pt *OutPort) SetCloneOnFanOut(clone bool) {
+	pt.cloneOnFanOut = clone
+}
+
+// Send sends an Packet with data to all the in-ports connected to the OutPort.
 func (pt *OutPort) Send(data any) {
-	for _, rpt := range pt.RemotePorts {
+	checkFanOutSharing(pt, data)
+	remotes := pt.remotes()
+	ips := fanOutPackets(data, len(remotes), pt.cloneOnFanOut)
+	for i, rpt := range remotes {
 		Debug.Printf("Sending on out-port (%s) connected to in-port (%s)", pt.Name(), rpt.Name())
-		ip := NewPacket(data)
-		rpt.Send(ip)
+		if i == 0 {
+			publishPacketSent(pt, ips[i])
+		}
+		rpt.send(pt, ips[i])
+	}
+}
+
+// fanOutPackets returns n packets to send to n in-ports, with data for the
+// first, and clones of data for the others if clone is true. All the clones
+// are made before any packet is sent, as the receiver of the original data
+// may start modifying it as soon as it is sent.
+func fanOutPackets(data any, n int, clone bool) []*Packet {
+	ips := make([]*Packet, n)
+	for i := 1; i < n; i++ {
+		d := data
+		if clone {
+			d = cloneData(data)
+		}
+		ips[i] = NewPacket(d)
+	}
+	if n > 0 {
+		ips[0] = NewPacket(data)
+	}
+	return ips
+}
+
+// SendPacket sends the packet ip, with its tags and audit info, to all the
+// in-ports connected to the OutPort. Additional in-ports receive clones of
+// the packet, which are all made before ip is sent.
+func (pt *OutPort) SendPacket(ip *Packet) {
+	publishPacketSent(pt, ip)
+	remotes := pt.remotes()
+	ips := make([]*Packet, len(remotes))
+	for i := 1; i < len(remotes); i++ {
+		ips[i] = ip.Clone()
+	}
+	for i, rpt := range remotes {
+		Debug.Printf("Sending packet (%s) on out-port (%s) connected to in-port (%s)", ip.ID(), pt.Name(), rpt.Name())
+		if i == 0 {
+			rpt.send(pt, ip)
+			continue
+		}
+		rpt.send(pt, ips[i])
+	}
+}
+
+// Transfer sends data, typically a pointer to a large payload such as an image
+// or matrix, without copying it, handing over ownership of it to the receiving
+// process. The sending process must not read or modify the data after the
+// call. If the OutPort is connected to more than one in-port, the data must
+// implement Cloner, and each additional in-port receives its own clone.
+// In builds with the race detector or the flowbase_debug build tag enabled,
+// violations of the ownership contract, such as transferring the same data
+// again before it has been received, are reported.
+func (pt *OutPort) Transfer(data any) {
+	_, isCloner := data.(Cloner)
+	remotes := pt.remotes()
+	if len(remotes) > 1 && !isCloner {
+		pt.Failf("Can not transfer data of type %T to more than one in-port, as it does not implement Cloner", data)
+	}
+	ips := fanOutPackets(data, len(remotes), true)
+	for i, rpt := range remotes {
+		trackOwnership(pt, rpt, ips[i].Data())
+	}
+	for i, rpt := range remotes {
+		if i == 0 {
+			publishPacketSent(pt, ips[i])
+		}
+		rpt.send(pt, ips[i])
 	}
 }
 
 // Close closes the connection between this port and all the ports it is
 // connected to. If this port is the last connected port to an in-port, that
-// in-ports channel will also be closed.
+// in-ports channel will also be closed. Close is idempotent, and safe to call
+// concurrently, such as both deferred and explicitly.
 func (pt *OutPort) Close() {
-	for _, rpt := range pt.RemotePorts {
+	// The connections are removed under the lock, but closed outside of it,
+	// as closing takes the locks of the in-ports
+	pt.mx.Lock()
+	rpts := pt.remoteList
+	for _, rpt := range rpts {
+		pt.removeRemotePort(rpt.FullName())
+	}
+	pt.mx.Unlock()
+	for _, rpt := range rpts {
 		Debug.Printf("Closing out-port (%s) connected to in-port (%s)", pt.Name(), rpt.Name())
-		rpt.CloseConnection(pt.Name())
-		pt.removeRemotePort(rpt.Name())
+		rpt.CloseConnection(pt.FullName())
 	}
 }
 
@@ -0,0 +1,227 @@
+package flowbase
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentPortWiring(t *testing.T) {
+	outp := NewOutPort("out")
+	inps := []*InPort{}
+	for i := 0; i < 50; i++ {
+		inps = append(inps, NewInPort(fmt.Sprintf("in%d", i)))
+	}
+
+	wg := sync.WaitGroup{}
+	for _, inp := range inps {
+		wg.Add(1)
+		go func(inp *InPort) {
+			defer wg.Done()
+			outp.To(inp)
+			_ = outp.Ready()
+			_ = len(outp.ConnectedPorts())
+		}(inp)
+	}
+	wg.Wait()
+	assertEqualValues(t, 50, len(outp.ConnectedPorts()))
+
+	for _, inp := range inps[:25] {
+		wg.Add(1)
+		go func(inp *InPort) {
+			defer wg.Done()
+			outp.Disconnect(inp.FullName())
+			inp.Disconnect(outp.FullName())
+		}(inp)
+	}
+	wg.Wait()
+	assertEqualValues(t, 25, len(outp.ConnectedPorts()))
+	assertEqualValues(t, false, inps[0].Ready())
+	assertEqualValues(t, true, inps[25].Ready())
+}
+
+func TestRemotePortKeys(t *testing.T) {
+	initTestLogs()
+	net := NewNetwork("TestRemotePortKeys")
+	src1 := NewFileSource(net, "src1", "a.txt")
+	src2 := NewFileSource(net, "src2", "b.txt")
+	merge := newMergeProc(net, "merge")
+	merge.InPort("in").From(src1.Out())
+	merge.InPort("in").From(src2.Out())
+
+	inp := merge.InPort("in")
+	assertEqualValues(t, map[string]*OutPort{"src1.out": src1.Out(), "src2.out": src2.Out()}, inp.ConnectedPorts())
+	// The deprecated RemotePorts keeps the keys of the old field
+	assertEqualValues(t, map[string]*InPort{"in": inp}, src1.Out().RemotePorts())
+	assertEqualValues(t, 1, len(inp.RemotePorts()))
+
+	// Port names are still accepted, when they are unambiguous
+	src1.Out().Disconnect("in")
+	assertEqualValues(t, 0, len(src1.Out().ConnectedPorts()))
+	inp.Disconnect("src1.out")
+	assertEqualValues(t, map[string]*OutPort{"src2.out": src2.Out()}, inp.ConnectedPorts())
+	inp.Disconnect("out")
+	assertEqualValues(t, 0, len(inp.ConnectedPorts()))
+}
+
+func TestPortCloseIdempotent(t *testing.T) {
+	initTestLogs()
+	outp := NewOutPort("out")
+	inp := NewInPort("in")
+	outp.To(inp)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			outp.Close()
+		}()
+	}
+	wg.Wait()
+	inp.CloseConnection(outp.FullName())
+
+	if _, ok := inp.RecvOK(); ok {
+		t.Errorf("In-port was not closed")
+	}
+}
+
+func TestOutPortQueueDepth(t *testing.T) {
+	initTestLogs()
+	outp := NewOutPort("out")
+	chanIn := NewInPort("chan")
+	ringIn := NewInPort("ring")
+	ringIn.SetRingBuffer(8)
+	outp.To(chanIn)
+	outp.To(ringIn)
+
+	for i := 0; i < 3; i++ {
+		outp.Send(i)
+	}
+	chanIn.Recv()
+	assertEqualValues(t, 2, chanIn.Len())
+	assertEqualValues(t, 3, ringIn.Len())
+	assertEqualValues(t, 3, outp.QueueDepth())
+}
+
+func TestInPortConflate(t *testing.T) {
+	initTestLogs()
+	outp := NewOutPort("out")
+	inp := NewInPort("in")
+	inp.SetConflate(true)
+	outp.To(inp)
+
+	for i := 0; i < 5; i++ {
+		outp.Send(i)
+	}
+	assertEqualValues(t, 1, inp.Len())
+	assertEqualValues(t, 4, inp.Recv().Data())
+
+	outp.Send(5)
+	outp.Close()
+	assertEqualValues(t, 5, inp.Recv().Data())
+	if _, ok := inp.RecvOK(); ok {
+		t.Errorf("In-port was not closed")
+	}
+}
+
+// fanOutData is data implementing Cloner, which records how many packets
+// were already queued in the in-port first, receiving the original data,
+// when it was cloned
+type fanOutData struct {
+	first         *InPort
+	queuedAtClone []int
+	mx            *sync.Mutex
+}
+
+func (d *fanOutData) Clone() any {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	d.queuedAtClone = append(d.queuedAtClone, d.first.Len())
+	return &fanOutData{first: d.first, mx: d.mx}
+}
+
+// newFanOut returns an out-port connected to n in-ports, and data to send on
+// it
+func newFanOut(n int) (*OutPort, []*InPort, *fanOutData) {
+	net := NewNetwork("fanout")
+	outp := NewOutPort("out")
+	outp.SetProcess(NewPacketCollector(net, "src"))
+	inps := []*InPort{}
+	for i := 0; i < n; i++ {
+		inp := NewInPort(fmt.Sprintf("in%d", i))
+		inp.SetProcess(NewPacketCollector(net, fmt.Sprintf("dst%d", i)))
+		outp.To(inp)
+		inps = append(inps, inp)
+	}
+	return outp, inps, &fanOutData{first: inps[0], mx: &sync.Mutex{}}
+}
+
+// assertFanOut checks that the first in-port received data itself, the others
+// clones of it, and that all the clones were made before anything was sent
+func assertFanOut(t *testing.T, inps []*InPort, data *fanOutData) {
+	seen := map[any]bool{}
+	for i, inp := range inps {
+		d := inp.Recv().Data()
+		if (i == 0) != (d == data) {
+			t.Errorf("In-port %s got the wrong data: %p (original: %p)", inp.Name(), d, data)
+		}
+		if seen[d] {
+			t.Errorf("In-port %s got data shared with another in-port", inp.Name())
+		}
+		seen[d] = true
+	}
+	assertEqualValues(t, []int{0, 0}, data.queuedAtClone)
+}
+
+func TestOutPortSendCloneOnFanOut(t *testing.T) {
+	initTestLogs()
+	outp, inps, data := newFanOut(3)
+	outp.SetCloneOnFanOut(true)
+	outp.Send(data)
+	assertFanOut(t, inps, data)
+}
+
+func TestOutPortSendWithoutCloneOnFanOut(t *testing.T) {
+	initTestLogs()
+	outp, inps, data := newFanOut(2)
+	outp.Send(data)
+	for _, inp := range inps {
+		if d := inp.Recv().Data(); d != data {
+			t.Errorf("In-port %s did not get the original data", inp.Name())
+		}
+	}
+	assertEqualValues(t, 0, len(data.queuedAtClone))
+}
+
+func TestOutPortSendPacketFanOut(t *testing.T) {
+	initTestLogs()
+	outp, inps, data := newFanOut(3)
+	ip := NewPacket(data)
+	ip.AddTag("a", "1")
+	outp.SendPacket(ip)
+	assertEqualValues(t, "1", inps[2].Recv().Tag("a"))
+	assertEqualValues(t, ip, inps[0].Recv())
+	assertEqualValues(t, []int{0, 0}, data.queuedAtClone)
+}
+
+func TestOutPortTransfer(t *testing.T) {
+	initTestLogs()
+	outp, inps, data := newFanOut(3)
+	outp.Transfer(data)
+	assertFanOut(t, inps, data)
+
+	outp, inps, _ = newFanOut(1)
+	buf := []byte("data")
+	outp.Transfer(buf)
+	if got := inps[0].Recv().Data().([]byte); &got[0] != &buf[0] {
+		t.Errorf("Data transferred to a single in-port was copied")
+	}
+}
+
+func TestOutPortTransferWithoutCloner(t *testing.T) {
+	ensureFailsProgram("TestOutPortTransferWithoutCloner", func() {
+		outp, _, _ := newFanOut(2)
+		outp.Transfer([]byte("data"))
+	}, t)
+}
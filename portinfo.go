@@ -0,0 +1,118 @@
+package flowbase
+
+// Port directions, of PortSpec.Direction
+const (
+	PortIn  = "in"
+	PortOut = "out"
+)
+
+// Port multiplicities, of PortSpec.Multiplicity
+const (
+	// PortSingle ports connect to at most one other port
+	PortSingle = "single"
+	// PortArray ports connect to any number of other ports
+	PortArray = "array"
+)
+
+// portInfo is the declared info of a port, returned by PortInfo
+type portInfo struct {
+	dataType     string
+	description  string
+	multiplicity string
+	optional     bool
+}
+
+// ------------------------------------------------------------------------
+// InPort
+// ------------------------------------------------------------------------
+
+// SetDataType declares the kind of data received on the in-port, such as
+// "file" or "string", and describes it, for introspection, such as in
+// component docs
+func (pt *InPort) SetDataType(dataType string, description string) {
+	pt.info.dataType = dataType
+	pt.info.description = description
+}
+
+// SetMultiplicity declares whether the in-port can be connected to only one
+// out-port (PortSingle), which is checked before running the network, or to
+// any number of out-ports (PortArray)
+func (pt *InPort) SetMultiplicity(multiplicity string) {
+	checkMultiplicity(multiplicity)
+	pt.info.multiplicity = multiplicity
+}
+
+// SetRequired sets whether the in-port must be connected, which it must by
+// default. In-ports that are not required, and not connected, are closed
+// when the network runs, so that their process receives no packets on them.
+func (pt *InPort) SetRequired(required bool) {
+	pt.info.optional = !required
+}
+
+// Required tells whether the in-port must be connected
+func (pt *InPort) Required() bool {
+	return !pt.info.optional
+}
+
+// PortInfo returns the declared direction, data type, multiplicity and
+// whether the in-port is required
+func (pt *InPort) PortInfo() PortSpec {
+	return PortSpec{
+		Name:         pt.Name(),
+		Direction:    PortIn,
+		Type:         pt.info.dataType,
+		Description:  pt.info.description,
+		Multiplicity: pt.info.multiplicity,
+		Required:     pt.Required(),
+	}
+}
+
+// closeUnconnected closes the in-port, if no out-port is connected to it
+func (pt *InPort) closeUnconnected() {
+	pt.mx.Lock()
+	defer pt.mx.Unlock()
+	if pt.closed || len(pt.remotePorts) > 0 {
+		return
+	}
+	pt.closed = true
+	pt.closeQueue()
+}
+
+// ------------------------------------------------------------------------
+// OutPort
+// ------------------------------------------------------------------------
+
+// SetDataType declares the kind of data sent on the out-port, such as
+// "file" or "string", and describes it, for introspection, such as in
+// component docs
+func (pt *OutPort) SetDataType(dataType string, description string) {
+	pt.info.dataType = dataType
+	pt.info.description = description
+}
+
+// SetMultiplicity declares whether the out-port can be connected to only one
+// in-port (PortSingle), which is checked before running the network, or to
+// any number of in-ports (PortArray)
+func (pt *OutPort) SetMultiplicity(multiplicity string) {
+	checkMultiplicity(multiplicity)
+	pt.info.multiplicity = multiplicity
+}
+
+// PortInfo returns the declared direction, data type and multiplicity of the
+// out-port. Out-ports are never required, as the ones not connected are
+// connected to the sink of the network.
+func (pt *OutPort) PortInfo() PortSpec {
+	return PortSpec{
+		Name:         pt.Name(),
+		Direction:    PortOut,
+		Type:         pt.info.dataType,
+		Description:  pt.info.description,
+		Multiplicity: pt.info.multiplicity,
+	}
+}
+
+func checkMultiplicity(multiplicity string) {
+	if multiplicity != PortSingle && multiplicity != PortArray {
+		Failf("Invalid port multiplicity (%s), expected %s or %s", multiplicity, PortSingle, PortArray)
+	}
+}
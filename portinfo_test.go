@@ -0,0 +1,85 @@
+package flowbase
+
+import (
+	"sort"
+	"testing"
+)
+
+// mergeProc sends on the packets of its in-port, followed by the packets of
+// its optional extra in-port
+type mergeProc struct {
+	BaseProcess
+}
+
+func newMergeProc(net *Network, name string) *mergeProc {
+	p := &mergeProc{BaseProcess: NewBaseProcess(net, name)}
+	p.InitInPort(p, "in")
+	p.InitInPort(p, "extra")
+	p.InitOutPort(p, "out")
+	p.InPort("in").SetDataType("file", "Paths of files")
+	p.InPort("in").SetMultiplicity(PortArray)
+	p.InPort("extra").SetRequired(false)
+	p.OutPort("out").SetMultiplicity(PortSingle)
+	return p
+}
+
+func (p *mergeProc) Run() {
+	defer p.CloseOutPorts()
+	for _, name := range []string{"in", "extra"} {
+		for ip, ok := p.InPort(name).RecvOK(); ok; ip, ok = p.InPort(name).RecvOK() {
+			p.OutPort("out").SendPacket(ip)
+		}
+	}
+}
+
+func TestPortInfo(t *testing.T) {
+	initTestLogs()
+	net := NewNetwork("TestPortInfo")
+	p := newMergeProc(net, "merge")
+
+	assertEqualValues(t, PortSpec{Name: "in", Direction: PortIn, Type: "file", Description: "Paths of files", Multiplicity: PortArray, Required: true}, p.InPort("in").PortInfo())
+	assertEqualValues(t, PortSpec{Name: "extra", Direction: PortIn}, p.InPort("extra").PortInfo())
+	assertEqualValues(t, PortSpec{Name: "out", Direction: PortOut, Multiplicity: PortSingle}, p.OutPort("out").PortInfo())
+
+	info := InfoOf(p)
+	assertEqualValues(t, []PortSpec{p.InPort("extra").PortInfo(), p.InPort("in").PortInfo()}, info.InPorts)
+}
+
+func TestOptionalInPortNotConnected(t *testing.T) {
+	initTestLogs()
+	net := NewNetwork("TestOptionalInPortNotConnected")
+
+	src1 := NewFileSource(net, "src1", "a.txt")
+	src2 := NewFileSource(net, "src2", "b.txt")
+	merge := newMergeProc(net, "merge")
+	out := NewPacketCollector(net, "out")
+	merge.InPort("in").From(src1.Out())
+	merge.InPort("in").From(src2.Out())
+	out.In().From(merge.OutPort("out"))
+
+	net.Run()
+
+	// The packets of the two sources can arrive in any order
+	data := []string{}
+	for _, d := range out.Data {
+		s, ok := d.(string)
+		if !ok {
+			t.Fatalf("Expected the paths to be received as strings, got %T", d)
+		}
+		data = append(data, s)
+	}
+	sort.Strings(data)
+	assertEqualValues(t, []string{"a.txt", "b.txt"}, data)
+}
+
+func TestSingleOutPortConnectedTwice(t *testing.T) {
+	ensureFailsProgram("TestSingleOutPortConnectedTwice", func() {
+		net := NewNetwork("TestSingleOutPortConnectedTwice")
+		src := NewFileSource(net, "src", "a.txt")
+		merge := newMergeProc(net, "merge")
+		merge.InPort("in").From(src.Out())
+		NewPacketCollector(net, "out1").In().From(merge.OutPort("out"))
+		NewPacketCollector(net, "out2").In().From(merge.OutPort("out"))
+		net.Run()
+	}, t)
+}
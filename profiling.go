@@ -0,0 +1,60 @@
+package flowbase
+
+import (
+	"context"
+	"errors"
+	gonet "net"
+	"net/http"
+	"net/http/pprof"
+	runtimepprof "runtime/pprof"
+)
+
+// EnableProfiling makes the processes of the network run with the pprof
+// labels "network" and "process", set to the names of the network and the
+// process, so that hotspots in CPU profiles can be attributed to processes,
+// rather than to anonymous goroutines, such as with:
+//
+//	go tool pprof -tagfocus process=align http://localhost:6060/debug/pprof/profile
+//
+// The labels are inherited by the goroutines started by processes. Go only
+// records labels in CPU and goroutine profiles, so allocations can not be
+// attributed this way. If addr is not empty, an HTTP server serving the
+// pprof profiles at /debug/pprof/ is started on it, such as on
+// "localhost:6060", and the address it listens on is returned, which is
+// useful with port 0. It is left running until the program exits.
+func (net *Network) EnableProfiling(addr string) (string, error) {
+	net.profiling = true
+	if addr == "" {
+		return "", nil
+	}
+	listener, err := gonet.Listen("tcp", addr)
+	if err != nil {
+		return "", errWrapf(err, "could not listen for profiling on %s", addr)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	go func() {
+		if err := http.Serve(listener, mux); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			Warning.Printf("[Network:%s] Profiling server stopped: %v\n", net.name, err)
+		}
+	}()
+	Audit.Printf("[Network:%s] Serving pprof profiles at http://%s/debug/pprof/\n", net.name, listener.Addr())
+	return listener.Addr().String(), nil
+}
+
+// runLabeled runs the process node, with the pprof labels of the network and
+// process if profiling is enabled (see EnableProfiling)
+func (net *Network) runLabeled(node Node) {
+	if !net.profiling {
+		node.Run()
+		return
+	}
+	labels := runtimepprof.Labels("network", net.name, "process", node.Name())
+	runtimepprof.Do(context.Background(), labels, func(context.Context) {
+		node.Run()
+	})
+}
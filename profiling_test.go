@@ -0,0 +1,46 @@
+package flowbase
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"runtime/pprof"
+	"strings"
+	"testing"
+)
+
+func TestEnableProfiling(t *testing.T) {
+	initTestLogs()
+	net := NewNetwork("TestEnableProfiling")
+	addr, err := net.EnableProfiling("localhost:0")
+	if err != nil {
+		t.Fatalf("Could not enable profiling: %v", err)
+	}
+
+	src := NewFileSource(net, "src", "a.txt")
+	profile := &bytes.Buffer{}
+	labeler := NewMapToTags(net, "labeled", func(ip *Packet) map[string]string {
+		// Goroutine profiles with debug=1 list the labels of goroutines
+		pprof.Lookup("goroutine").WriteTo(profile, 1)
+		return map[string]string{}
+	})
+	out := NewPacketCollector(net, "out")
+	labeler.In().From(src.Out())
+	out.In().From(labeler.Out())
+	net.Run()
+
+	if !strings.Contains(profile.String(), `"process":"labeled"`) {
+		t.Errorf("Expected a goroutine labeled with the process name in:\n%s", profile.String())
+	}
+
+	resp, err := http.Get("http://" + addr + "/debug/pprof/")
+	if err != nil {
+		t.Fatalf("Could not get pprof index: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	assertEqualValues(t, http.StatusOK, resp.StatusCode)
+	if !strings.Contains(string(body), "goroutine") {
+		t.Errorf("Expected the pprof index to list profiles, got:\n%s", body)
+	}
+}
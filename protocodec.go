@@ -0,0 +1,153 @@
+package flowbase
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/flowbase/flowbase/pbwire"
+)
+
+// ProtoMessage is implemented by packet data types that can be serialized in
+// the protocol buffers wire format. Implementations are normally generated
+// with the `flowbase gen proto` command.
+type ProtoMessage interface {
+	ProtoTypeName() string
+	MarshalProto() ([]byte, error)
+	UnmarshalProto(b []byte) error
+}
+
+var errNotProtoMessage = errors.New("packet data does not implement ProtoMessage")
+
+var protoTypes = map[string]func() ProtoMessage{}
+
+// RegisterProtoType registers a factory function for the protobuf message type
+// with name typeName, so that the ProtoCodec can decode packets containing it
+func RegisterProtoType(typeName string, factory func() ProtoMessage) {
+	protoTypes[typeName] = factory
+}
+
+// ProtoCodec serializes Packets as length-delimited protobuf messages of the
+// flowbase.Packet type, as emitted by `flowbase gen proto`. Packet data needs
+// to implement ProtoMessage, and its type must be registered with
+// RegisterProtoType for decoding. Audit info is embedded as JSON.
+type ProtoCodec struct{}
+
+// NewProtoCodec returns a new ProtoCodec
+func NewProtoCodec() *ProtoCodec {
+	return &ProtoCodec{}
+}
+
+// Name returns the name of the codec
+func (c *ProtoCodec) Name() string {
+	return "protobuf"
+}
+
+// NewEncoder returns a new protobuf packet encoder writing to w
+func (c *ProtoCodec) NewEncoder(w io.Writer) PacketEncoder {
+	return &protoPacketEncoder{w: w}
+}
+
+// NewDecoder returns a new protobuf packet decoder reading from r
+func (c *ProtoCodec) NewDecoder(r io.Reader) PacketDecoder {
+	return &protoPacketDecoder{r: bufio.NewReader(r)}
+}
+
+type protoPacketEncoder struct {
+	w io.Writer
+}
+
+func (e *protoPacketEncoder) Encode(ip *Packet) error {
+	msg, ok := ip.Data().(ProtoMessage)
+	if !ok {
+		return errWrapf(errNotProtoMessage, "Could not protobuf-encode packet (%s)", ip.ID())
+	}
+	data, err := msg.MarshalProto()
+	if err != nil {
+		return errWrapf(err, "Could not protobuf-encode data of packet (%s)", ip.ID())
+	}
+
+	buf := &pbwire.Buffer{}
+	buf.String(1, ip.ID())
+	buf.StringMap(2, ip.Tags())
+	buf.String(3, msg.ProtoTypeName())
+	buf.RawBytes(4, data)
+	if ip.AuditInfo() != nil {
+		auditJSON, err := json.Marshal(ip.AuditInfo())
+		if err != nil {
+			return errWrapf(err, "Could not encode audit info of packet (%s)", ip.ID())
+		}
+		buf.RawBytes(5, auditJSON)
+	}
+
+	_, err = e.w.Write(pbwire.AppendDelimited(nil, buf.Bytes()))
+	return err
+}
+
+type protoPacketDecoder struct {
+	r *bufio.Reader
+}
+
+func (d *protoPacketDecoder) Decode() (*Packet, error) {
+	msgLen, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, errWrap(err, "Could not read length of protobuf packet")
+	}
+	msg := make([]byte, msgLen)
+	if _, err := io.ReadFull(d.r, msg); err != nil {
+		return nil, errWrap(err, "Could not read protobuf packet")
+	}
+
+	ip := &Packet{tags: make(map[string]string)}
+	var typeName string
+	var data []byte
+	r := pbwire.NewReader(msg)
+	for !r.Done() {
+		field, wireType, err := r.Next()
+		if err != nil {
+			return nil, errWrap(err, "Could not decode protobuf packet")
+		}
+		switch field {
+		case 1:
+			ip.id, err = r.String()
+		case 2:
+			err = r.StringMapEntry(ip.tags)
+		case 3:
+			typeName, err = r.String()
+		case 4:
+			data, err = r.RawBytes()
+		case 5:
+			var auditJSON []byte
+			auditJSON, err = r.RawBytes()
+			if err == nil {
+				ip.auditInfo = &AuditInfo{}
+				err = json.Unmarshal(auditJSON, ip.auditInfo)
+			}
+		default:
+			err = r.Skip(wireType)
+		}
+		if err != nil {
+			return nil, errWrap(err, "Could not decode protobuf packet")
+		}
+	}
+
+	factory, ok := protoTypes[typeName]
+	if !ok {
+		return nil, errWrapf(errNotProtoMessage, "No protobuf type registered with name (%s)", typeName)
+	}
+	dataMsg := factory()
+	if err := dataMsg.UnmarshalProto(data); err != nil {
+		return nil, errWrapf(err, "Could not decode data of packet (%s)", ip.id)
+	}
+	ip.data = dataMsg
+	return ip, nil
+}
+
+func init() {
+	RegisterCodec(NewProtoCodec())
+}
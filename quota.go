@@ -0,0 +1,105 @@
+package flowbase
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// QuotaConfig configures a Quota. Limits which are 0 are unlimited.
+type QuotaConfig struct {
+	// Rate is the number of requests per second, which are let through
+	// evenly, as by a token bucket
+	Rate float64
+	// Burst is the number of requests let through at once, after a time
+	// without requests. Defaults to 1.
+	Burst int
+	// MaxConcurrent is the number of requests which can be in flight at the
+	// same time
+	MaxConcurrent int
+}
+
+// Quota limits the requests to an external service, such as an API, made by
+// any number of processes, so that the total request rate of a network
+// respects the limits of the provider. Quotas are shared by name, within a
+// network (see Network.SetQuota).
+type Quota struct {
+	name  string
+	conf  QuotaConfig
+	net   *Network
+	slots chan struct{}
+	mx    sync.Mutex
+	// tokens are the requests that can be let through right away, which is
+	// negative when requests are waiting for their turn
+	tokens float64
+	last   time.Time
+}
+
+// SetQuota sets the quota named name, such as the name of an API provider,
+// which processes making requests to it look up with Quota, and returns it
+func (net *Network) SetQuota(name string, conf QuotaConfig) *Quota {
+	if conf.Burst < 1 {
+		conf.Burst = 1
+	}
+	q := &Quota{name: name, conf: conf, net: net, tokens: float64(conf.Burst), last: net.Clock().Now()}
+	if conf.MaxConcurrent > 0 {
+		q.slots = make(chan struct{}, conf.MaxConcurrent)
+	}
+	net.quotasMx.Lock()
+	defer net.quotasMx.Unlock()
+	if net.quotas == nil {
+		net.quotas = map[string]*Quota{}
+	}
+	net.quotas[name] = q
+	return q
+}
+
+// Quota returns the quota named name (see SetQuota), or nil if there is
+// none, in which case requests are not limited
+func (net *Network) Quota(name string) *Quota {
+	net.quotasMx.Lock()
+	defer net.quotasMx.Unlock()
+	return net.quotas[name]
+}
+
+// Name returns the name of the quota
+func (q *Quota) Name() string {
+	return q.name
+}
+
+// Acquire blocks until a request can be made within the quota, and returns a
+// function to call when the request has finished. Acquire on a nil Quota
+// returns right away.
+func (q *Quota) Acquire() (release func()) {
+	if q == nil {
+		return func() {}
+	}
+	if q.slots != nil {
+		q.slots <- struct{}{}
+	}
+	if q.conf.Rate > 0 {
+		if wait := q.reserve(); wait > 0 {
+			q.net.Clock().Sleep(wait)
+		}
+	}
+	return func() {
+		if q.slots != nil {
+			<-q.slots
+		}
+	}
+}
+
+// reserve takes a token from the bucket, and returns how long to wait until
+// it is available
+func (q *Quota) reserve() time.Duration {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	now := q.net.Clock().Now()
+	q.tokens = math.Min(float64(q.conf.Burst), q.tokens+now.Sub(q.last).Seconds()*q.conf.Rate)
+	q.last = now
+	q.tokens--
+	if q.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-q.tokens / q.conf.Rate * float64(time.Second))
+}
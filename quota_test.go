@@ -0,0 +1,56 @@
+package flowbase
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuotaRate(t *testing.T) {
+	net := NewNetwork("TestQuotaRate")
+	clock := NewVirtualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	net.SetClock(clock)
+	q := net.SetQuota("api", QuotaConfig{Rate: 10, Burst: 2})
+
+	waits := []time.Duration{}
+	for i := 0; i < 4; i++ {
+		waits = append(waits, q.reserve())
+	}
+	assertEqualValues(t, []time.Duration{0, 0, 100 * time.Millisecond, 200 * time.Millisecond}, waits)
+
+	// The tokens are refilled over time, up to the burst
+	clock.Advance(time.Second)
+	assertEqualValues(t, time.Duration(0), q.reserve())
+	assertEqualValues(t, time.Duration(0), q.reserve())
+	assertEqualValues(t, 100*time.Millisecond, q.reserve())
+}
+
+func TestQuotaConcurrency(t *testing.T) {
+	net := NewNetwork("TestQuotaConcurrency")
+	q := net.SetQuota("api", QuotaConfig{MaxConcurrent: 2})
+	if net.Quota("api") != q {
+		t.Fatal("Quota not found by name")
+	}
+
+	release1 := q.Acquire()
+	release2 := q.Acquire()
+	acquired := make(chan struct{})
+	go func() {
+		q.Acquire()()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("Acquired more than the max number of concurrent requests")
+	case <-time.After(20 * time.Millisecond):
+	}
+	release1()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("Not acquired after a request was released")
+	}
+	release2()
+
+	// Requests are not limited without a quota
+	net.Quota("other").Acquire()()
+}
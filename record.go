@@ -0,0 +1,43 @@
+package flowbase
+
+import (
+	"bufio"
+	"os"
+	"sync"
+)
+
+// Record makes all packets sent on the out-port pt be recorded to the file
+// at path, encoded with codec, while the network runs. The file is closed
+// when the network has finished. Recorded streams can be fed back into a
+// network with a replay source, such as components.ReplaySource, to
+// reproduce problems locally.
+func (net *Network) Record(pt *OutPort, path string, codec Codec) {
+	createDirs(path)
+	file, err := os.Create(path)
+	if err != nil {
+		net.Failf("Could not create recording file %s: %v", path, err)
+	}
+	bufw := bufio.NewWriter(file)
+	enc := codec.NewEncoder(bufw)
+	mx := &sync.Mutex{}
+	procName, portName := pt.Process().Name(), pt.Name()
+
+	var unsubscribe func()
+	unsubscribe = net.Subscribe(func(e *Event) bool {
+		return e.Type == EventNetworkFinished || (e.Type == EventPacketSent && e.Process == procName && e.Port == portName)
+	}, func(e *Event) {
+		mx.Lock()
+		defer mx.Unlock()
+		if e.Type == EventNetworkFinished {
+			if err := bufw.Flush(); err != nil {
+				net.Failf("Could not write recording file %s: %v", path, err)
+			}
+			file.Close()
+			unsubscribe()
+			return
+		}
+		if err := enc.Encode(e.Packet); err != nil {
+			net.Failf("Could not record packet to %s: %v", path, err)
+		}
+	})
+}
@@ -0,0 +1,95 @@
+package flowbase
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Redacted is the value that redacted parameters and tags are replaced with
+const Redacted = "[REDACTED]"
+
+var (
+	redactPatterns     []*regexp.Regexp
+	redactPatternsLock sync.RWMutex
+)
+
+// RedactAuditKeys makes the values of parameters and tags with keys matching
+// any of the regular expressions patterns, such as "(?i)password|token", be
+// redacted in audit files and audit log messages. Values are also redacted
+// where they occur in commands.
+func RedactAuditKeys(patterns ...string) {
+	redactPatternsLock.Lock()
+	defer redactPatternsLock.Unlock()
+	for _, pattern := range patterns {
+		ptn, err := regexp.Compile(pattern)
+		if err != nil {
+			Failf("Could not compile redaction pattern (%s): %v", pattern, err)
+		}
+		redactPatterns = append(redactPatterns, ptn)
+	}
+}
+
+// ClearAuditRedactions removes all redaction patterns added with
+// RedactAuditKeys
+func ClearAuditRedactions() {
+	redactPatternsLock.Lock()
+	defer redactPatternsLock.Unlock()
+	redactPatterns = nil
+}
+
+// redactKey tells whether the values of key should be redacted
+func redactKey(key string) bool {
+	redactPatternsLock.RLock()
+	defer redactPatternsLock.RUnlock()
+	for _, ptn := range redactPatterns {
+		if ptn.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redacted returns a copy of the audit info, including upstream audit infos,
+// where the values of parameters and tags matching patterns added with
+// RedactAuditKeys are replaced with Redacted, also in the command
+func (ai *AuditInfo) Redacted() *AuditInfo {
+	red := *ai
+	red.Command = ai.RedactedCommand()
+	red.Params = redactMap(ai.Params)
+	red.Tags = redactMap(ai.Tags)
+	red.Upstream = make(map[string]*AuditInfo)
+	for k, up := range ai.Upstream {
+		red.Upstream[k] = up.Redacted()
+	}
+	return &red
+}
+
+// RedactedCommand returns the command of the audit info, with the values of
+// redacted parameters and tags replaced with Redacted
+func (ai *AuditInfo) RedactedCommand() string {
+	cmd := ai.Command
+	for _, m := range []map[string]string{ai.Params, ai.Tags} {
+		for k, v := range m {
+			if v != "" && redactKey(k) {
+				cmd = strings.ReplaceAll(cmd, v, Redacted)
+			}
+		}
+	}
+	return cmd
+}
+
+// redactMap returns a copy of m with the values of redacted keys replaced
+func redactMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	red := make(map[string]string, len(m))
+	for k, v := range m {
+		if redactKey(k) {
+			v = Redacted
+		}
+		red[k] = v
+	}
+	return red
+}
@@ -0,0 +1,23 @@
+package flowbase
+
+import (
+	"testing"
+)
+
+func TestAuditInfoRedacted(t *testing.T) {
+	RedactAuditKeys("(?i)token")
+	defer ClearAuditRedactions()
+
+	ai := NewAuditInfo()
+	ai.Command = "curl -H 'Auth: s3cr3t' example.com"
+	ai.Params = map[string]string{"API_TOKEN": "s3cr3t", "url": "example.com"}
+	up := NewAuditInfo()
+	up.Tags = map[string]string{"token": "abc"}
+	ai.Upstream["in"] = up
+
+	red := ai.Redacted()
+	assertEqualValues(t, "curl -H 'Auth: [REDACTED]' example.com", red.Command)
+	assertEqualValues(t, map[string]string{"API_TOKEN": Redacted, "url": "example.com"}, red.Params)
+	assertEqualValues(t, Redacted, red.Upstream["in"].Tags["token"])
+	assertEqualValues(t, "s3cr3t", ai.Params["API_TOKEN"], "Original audit info was modified")
+}
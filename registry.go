@@ -0,0 +1,143 @@
+package flowbase
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ------------------------------------------------------------------------
+// Component registry
+// ------------------------------------------------------------------------
+
+// ComponentFactory creates a new process with name name in the network net,
+// configured by metadata, such as the command of an ExecProc. It is used to
+// instantiate processes from graph files (see NewNetworkFromGraph).
+type ComponentFactory func(net *Network, name string, metadata map[string]string) (Node, error)
+
+// ComponentNamer can be implemented by processes to set the component name
+// they are exported with to graph files, which otherwise is the name of their
+// Go type
+type ComponentNamer interface {
+	ComponentName() string
+}
+
+// ComponentMetadataer can be implemented by processes to export the metadata
+// needed to re-create them with their ComponentFactory, such as the command
+// of an ExecProc
+type ComponentMetadataer interface {
+	ComponentMetadata() map[string]string
+}
+
+var components = map[string]ComponentFactory{
+	"ExecProc":    newExecProcFromMetadata,
+	"RScriptProc": newRScriptProcFromMetadata,
+}
+
+// RegisterComponent makes factory available under the component name name,
+// for instantiating processes from graph files
+func RegisterComponent(name string, factory ComponentFactory) {
+	components[name] = factory
+}
+
+// LookupComponent returns the factory registered with the component name
+// name, and whether there is one
+func LookupComponent(name string) (ComponentFactory, bool) {
+	factory, ok := components[name]
+	return factory, ok
+}
+
+// RegisteredComponents returns the names of all registered components, sorted
+func RegisteredComponents() []string {
+	names := []string{}
+	for name := range components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// componentName returns the component name of node, as set by its
+// ComponentName method, or else the name of its Go type
+func componentName(node Node) string {
+	if namer, ok := node.(ComponentNamer); ok {
+		return namer.ComponentName()
+	}
+	typ := reflect.TypeOf(node)
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ.Name()
+}
+
+// componentMetadata returns the metadata of node, if it has any
+func componentMetadata(node Node) map[string]string {
+	if m, ok := node.(ComponentMetadataer); ok {
+		return m.ComponentMetadata()
+	}
+	return nil
+}
+
+// execProcParamPrefix prefixes the metadata fields holding the parameters of
+// ExecProcs
+const execProcParamPrefix = "param."
+
+// newExecProcFromMetadata creates an ExecProc from the "command" metadata
+// field, with its Version set from the "version" field, and parameters from
+// the "param.<name>" fields
+func newExecProcFromMetadata(net *Network, name string, metadata map[string]string) (Node, error) {
+	cmd, ok := metadata["command"]
+	if !ok {
+		return nil, fmt.Errorf("missing command metadata for ExecProc %s", name)
+	}
+	p := NewExecProc(net, name, cmd)
+	p.Version = metadata["version"]
+	for key, value := range metadata {
+		if strings.HasPrefix(key, execProcParamPrefix) {
+			p.SetParam(strings.TrimPrefix(key, execProcParamPrefix), value)
+		}
+	}
+	return p, nil
+}
+
+// ComponentMetadata returns the command pattern, version and parameters of
+// the process, as metadata for re-creating it from graph files
+func (p *ExecProc) ComponentMetadata() map[string]string {
+	metadata := map[string]string{"command": p.CommandPattern}
+	if p.Version != "" {
+		metadata["version"] = p.Version
+	}
+	for name, value := range p.params {
+		if value != "" {
+			metadata[execProcParamPrefix+name] = value
+		}
+	}
+	return metadata
+}
+
+// newRScriptProcFromMetadata creates an RScriptProc from the "script"
+// metadata field, with its Version and parameters set like for ExecProcs
+func newRScriptProcFromMetadata(net *Network, name string, metadata map[string]string) (Node, error) {
+	script, ok := metadata["script"]
+	if !ok {
+		return nil, fmt.Errorf("missing script metadata for RScriptProc %s", name)
+	}
+	p := NewRScriptProc(net, name, script)
+	p.Version = metadata["version"]
+	for key, value := range metadata {
+		if strings.HasPrefix(key, execProcParamPrefix) {
+			p.SetParam(strings.TrimPrefix(key, execProcParamPrefix), value)
+		}
+	}
+	return p, nil
+}
+
+// ComponentMetadata returns the script pattern, version and parameters of
+// the process, as metadata for re-creating it from graph files
+func (p *RScriptProc) ComponentMetadata() map[string]string {
+	metadata := p.ExecProc.ComponentMetadata()
+	delete(metadata, "command")
+	metadata["script"] = p.ScriptPattern
+	return metadata
+}
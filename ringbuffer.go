@@ -0,0 +1,241 @@
+package flowbase
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// ringBuffer is a bounded, lock-free multi-producer/multi-consumer queue of
+// packets, based on a ring of slots with per-slot sequence numbers (after
+// Dmitry Vyukov's bounded MPMC queue, similar to the LMAX Disruptor). It can
+// be used as an alternative to a Go channel for in-ports in pipelines where
+// channel overhead dominates. Producers block, rather than spin, when the
+// buffer is full, until a packet is removed.
+type ringBuffer struct {
+	// The positions are accessed atomically, and are kept first in the struct
+	// to guarantee 64-bit alignment, and padded to avoid false sharing.
+	enqueuePos uint64
+	_          [56]byte
+	dequeuePos uint64
+	_          [56]byte
+	mask       uint64
+	slots      []ringSlot
+	// notFull is signalled when a packet is removed, waking up a producer
+	// waiting for a free slot
+	notFull chan struct{}
+}
+
+type ringSlot struct {
+	seq uint64
+	ip  *Packet
+}
+
+// newRingBuffer returns a new ringBuffer with room for at least size packets
+// (rounded up to the nearest power of two)
+func newRingBuffer(size int) *ringBuffer {
+	capacity := ringCapacity(size)
+	rb := &ringBuffer{
+		mask:    uint64(capacity - 1),
+		slots:   make([]ringSlot, capacity),
+		notFull: make(chan struct{}, 1),
+	}
+	for i := range rb.slots {
+		rb.slots[i].seq = uint64(i)
+	}
+	return rb
+}
+
+// ringCapacity returns the capacity of ring buffers with room for at least
+// size packets, which is size rounded up to the nearest power of two
+func ringCapacity(size int) int {
+	capacity := 2
+	for capacity < size {
+		capacity <<= 1
+	}
+	return capacity
+}
+
+// put adds ip to the buffer, waiting for a free slot if the buffer is full
+func (rb *ringBuffer) put(ip *Packet) {
+	for !rb.tryPut(ip) {
+		<-rb.notFull
+	}
+}
+
+// tryPut adds ip to the buffer, and returns false if the buffer is full
+func (rb *ringBuffer) tryPut(ip *Packet) bool {
+	for {
+		pos := atomic.LoadUint64(&rb.enqueuePos)
+		slot := &rb.slots[pos&rb.mask]
+		seq := atomic.LoadUint64(&slot.seq)
+		switch dif := int64(seq) - int64(pos); {
+		case dif == 0:
+			if atomic.CompareAndSwapUint64(&rb.enqueuePos, pos, pos+1) {
+				slot.ip = ip
+				atomic.StoreUint64(&slot.seq, pos+1)
+				return true
+			}
+		case dif < 0:
+			return false
+		}
+	}
+}
+
+// tryGet removes and returns the oldest packet in the buffer, with ok being
+// false if the buffer is empty
+func (rb *ringBuffer) tryGet() (ip *Packet, ok bool) {
+	for {
+		pos := atomic.LoadUint64(&rb.dequeuePos)
+		slot := &rb.slots[pos&rb.mask]
+		seq := atomic.LoadUint64(&slot.seq)
+		switch dif := int64(seq) - int64(pos+1); {
+		case dif == 0:
+			if atomic.CompareAndSwapUint64(&rb.dequeuePos, pos, pos+1) {
+				ip = slot.ip
+				slot.ip = nil
+				atomic.StoreUint64(&slot.seq, pos+rb.mask+1)
+				signal(rb.notFull)
+				return ip, true
+			}
+		case dif < 0:
+			return nil, false
+		}
+	}
+}
+
+// len returns the number of packets in the buffer, which may be outdated as
+// soon as it is returned
+func (rb *ringBuffer) len() int {
+	dequeuePos := atomic.LoadUint64(&rb.dequeuePos)
+	enqueuePos := atomic.LoadUint64(&rb.enqueuePos)
+	if enqueuePos < dequeuePos {
+		return 0
+	}
+	return int(enqueuePos - dequeuePos)
+}
+
+// signal wakes up a goroutine waiting on the signal channel ch, or the next
+// one to wait on it, without blocking
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// ringInput is the queue of an in-port using ring buffers, with one ring
+// buffer per connected out-port, so that each ring has a single producer.
+// Consumers block, rather than spin, when all the rings are empty.
+type ringInput struct {
+	size int
+	// byPort holds the ring buffers by out-port. rings holds the same ring
+	// buffers, and is replaced rather than modified, under mx, so that
+	// consumers can read it without locking.
+	byPort sync.Map
+	mx     sync.Mutex
+	rings  atomic.Value
+	// next is where the next scan of the rings for a packet starts, so that
+	// all connections are served in turn
+	next uint32
+	// ready is signalled when a packet is added to any of the rings
+	ready chan struct{}
+	// done is closed when the in-port is closed
+	done chan struct{}
+}
+
+// newRingInput returns a new ringInput, with ring buffers with room for at
+// least size packets
+func newRingInput(size int) *ringInput {
+	ri := &ringInput{
+		size:  size,
+		ready: make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+	ri.rings.Store([]*ringBuffer{})
+	return ri
+}
+
+// ring returns the ring buffer of the connection to the out-port from, which
+// is nil for packets sent to the in-port directly
+func (ri *ringInput) ring(from *OutPort) *ringBuffer {
+	if rb, ok := ri.byPort.Load(from); ok {
+		return rb.(*ringBuffer)
+	}
+	ri.mx.Lock()
+	defer ri.mx.Unlock()
+	if rb, ok := ri.byPort.Load(from); ok {
+		return rb.(*ringBuffer)
+	}
+	rb := newRingBuffer(ri.size)
+	rings := ri.rings.Load().([]*ringBuffer)
+	ri.rings.Store(append(append([]*ringBuffer{}, rings...), rb))
+	ri.byPort.Store(from, rb)
+	return rb
+}
+
+// put adds ip, sent from the out-port from, waiting for a free slot if the
+// ring buffer of the connection is full
+func (ri *ringInput) put(from *OutPort, ip *Packet) {
+	ri.ring(from).put(ip)
+	signal(ri.ready)
+}
+
+// get removes and returns the next packet, waiting for one to arrive if all
+// the ring buffers are empty. When the in-port is closed and all the ring
+// buffers are empty, ok is false.
+func (ri *ringInput) get() (ip *Packet, ok bool) {
+	for {
+		ip, ok, closed := ri.tryGet()
+		if ok || closed {
+			return ip, ok
+		}
+		select {
+		case <-ri.ready:
+		case <-ri.done:
+		}
+	}
+}
+
+// tryGet removes and returns the next packet, with ok being false if all the
+// ring buffers are empty, and closed being true if the in-port is also
+// closed, so that no more packets will arrive
+func (ri *ringInput) tryGet() (ip *Packet, ok bool, closed bool) {
+	// Checked before the scan, as packets put before the in-port was closed
+	// are then guaranteed to be found by it
+	select {
+	case <-ri.done:
+		closed = true
+	default:
+	}
+	rings := ri.rings.Load().([]*ringBuffer)
+	if len(rings) == 0 {
+		return nil, false, closed
+	}
+	start := int(atomic.AddUint32(&ri.next, 1) % uint32(len(rings)))
+	for i := range rings {
+		if ip, ok = rings[(start+i)%len(rings)].tryGet(); ok {
+			// Pass on the wake-up to other consumers, if there is more to get
+			if ri.len() > 0 {
+				signal(ri.ready)
+			}
+			return ip, true, false
+		}
+	}
+	return nil, false, closed
+}
+
+// len returns the number of packets in all the ring buffers, which may be
+// outdated as soon as it is returned
+func (ri *ringInput) len() int {
+	n := 0
+	for _, rb := range ri.rings.Load().([]*ringBuffer) {
+		n += rb.len()
+	}
+	return n
+}
+
+// close marks the in-port as closed, waking up waiting consumers. Packets
+// already in the ring buffers can still be received.
+func (ri *ringInput) close() {
+	close(ri.done)
+}
@@ -0,0 +1,116 @@
+package flowbase
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRingInputSingleProducer(t *testing.T) {
+	initTestLogs()
+
+	ri := newRingInput(4)
+	n := 1000
+	go func() {
+		from := NewOutPort("out")
+		for i := 0; i < n; i++ {
+			ri.put(from, NewPacket(i))
+		}
+		ri.close()
+	}()
+
+	i := 0
+	for ip, ok := ri.get(); ok; ip, ok = ri.get() {
+		if ip.Data() != i {
+			t.Fatalf("Got packets out of order from ring buffer: %v, wanted: %d\n", ip.Data(), i)
+		}
+		i++
+	}
+	assertEqualValues(t, n, i)
+}
+
+func TestRingInputMultiProducer(t *testing.T) {
+	initTestLogs()
+
+	ri := newRingInput(4)
+	producers, perProducer := 4, 1000
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < producers; i++ {
+		wg.Add(1)
+		go func(from *OutPort) {
+			defer wg.Done()
+			for j := 0; j < perProducer; j++ {
+				ri.put(from, NewPacket(j))
+			}
+		}(NewOutPort("out"))
+	}
+	go func() {
+		wg.Wait()
+		ri.close()
+	}()
+
+	received := 0
+	for _, ok := ri.get(); ok; _, ok = ri.get() {
+		received++
+	}
+	if received != producers*perProducer {
+		t.Errorf("Got wrong number of packets from ring buffers: %d, wanted: %d\n", received, producers*perProducer)
+	}
+	assertEqualValues(t, producers, len(ri.rings.Load().([]*ringBuffer)))
+}
+
+func TestRingInputEmptyWait(t *testing.T) {
+	initTestLogs()
+
+	ri := newRingInput(4)
+	received := make(chan *Packet)
+	go func() {
+		for ip, ok := ri.get(); ok; ip, ok = ri.get() {
+			received <- ip
+		}
+		close(received)
+	}()
+
+	select {
+	case ip := <-received:
+		t.Fatalf("Got packet %v from empty ring buffers", ip.Data())
+	case <-time.After(20 * time.Millisecond):
+	}
+	ri.put(nil, NewPacket("a"))
+	assertEqualValues(t, "a", (<-received).Data())
+
+	ri.close()
+	if _, ok := <-received; ok {
+		t.Errorf("Got packet from closed, empty ring buffers")
+	}
+}
+
+func TestInPortRingBufferFanIn(t *testing.T) {
+	initTestLogs()
+
+	inp := NewInPort("in")
+	inp.SetRingBuffer(2)
+	outps := []*OutPort{NewOutPort("out1"), NewOutPort("out2")}
+	for _, outp := range outps {
+		inp.From(outp)
+	}
+
+	// Each connection has its own ring buffer, so that a full one does not
+	// block the other
+	for _, outp := range outps {
+		outp.Send(1)
+		outp.Send(2)
+	}
+	assertEqualValues(t, 4, inp.Len())
+	assertEqualValues(t, 2, inp.Capacity())
+
+	for _, outp := range outps {
+		outp.Close()
+	}
+	sum := 0
+	for ip, ok := inp.RecvOK(); ok; ip, ok = inp.RecvOK() {
+		sum += ip.Data().(int)
+	}
+	assertEqualValues(t, 6, sum)
+}
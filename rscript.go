@@ -0,0 +1,211 @@
+package flowbase
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/flowbase/flowbase/template"
+)
+
+// RScriptCommand is the command used to run the scripts of RScriptProcs
+var RScriptCommand = "Rscript"
+
+// rScriptFilesPort is the out-port of RScriptProcs on which the files the
+// scripts write to their working directory are sent
+const rScriptFilesPort = "files"
+
+// rScriptWrapper is the R code running the scripts of RScriptProcs. It reads
+// the arguments of the form type:name=value into the lists inputs, outputs,
+// params and tags, runs the script in the directory of the files out-port,
+// and writes the message and traceback of errors to stderr, in the format
+// parsed by parseRError.
+const rScriptWrapper = `a <- commandArgs(trailingOnly = TRUE)
+script <- normalizePath(a[1])
+inputs <- list(); outputs <- list(); params <- list(); tags <- list()
+for (x in a[-1]) {
+  k <- sub("=.*$", "", x); v <- sub("^[^=]*=", "", x); n <- sub("^[a-z]+:", "", k)
+  switch(sub(":.*$", "", k),
+    i = inputs[[n]] <- normalizePath(v),
+    o = outputs[[n]] <- file.path(normalizePath(dirname(v)), basename(v)),
+    p = params[[n]] <- type.convert(v, as.is = TRUE),
+    t = tags[[n]] <- v)
+}
+rm(a, x, k, v, n)
+dir.create(outputs$files, recursive = TRUE, showWarnings = FALSE)
+setwd(outputs$files)
+withCallingHandlers(source(script), error = function(e) {
+  calls <- head(sys.calls()[-1], -1)
+  calls <- Filter(function(c) !identical(c[[1]], quote(.handleSimpleError)), calls)
+  call <- conditionCall(e)
+  message(if (is.null(call)) "Error: " else paste0("Error in ", deparse(call)[1], ": "), conditionMessage(e))
+  message("Traceback:")
+  for (i in seq_along(calls)) message(i, ": ", deparse(calls[[i]])[1])
+  quit(save = "no", status = 1)
+})
+`
+
+// RScriptProc is a process running an R script for every set of packets
+// received on its in-ports, like ExecProc, but R-aware. The script and its
+// ports and parameters are given as a pattern of the script path followed by
+// placeholders, such as:
+//
+//	scripts/plot.R {i:counts} {o:report} {p:alpha}
+//
+// The values of the placeholders are available to the script in the R lists
+// inputs, outputs, params and tags, such as params$alpha, with parameters
+// converted to numbers or logicals where possible. Input and output paths are
+// absolute, as the script runs in a directory of its own, whose files, such
+// as plots written by the default graphics device, are sent as *FileIPs on
+// the Files out-port. When the script fails, the R error and traceback are
+// returned as an *RError, which fails the network, or is sent on the Errors
+// out-port if FailOnError is false.
+type RScriptProc struct {
+	*ExecProc
+	// Script is the path of the R script
+	Script string
+	// ScriptPattern is the pattern the process was created with
+	ScriptPattern string
+}
+
+// NewRScriptProc returns a new RScriptProc, running the script and with the
+// ports and parameters given by scriptPattern
+func NewRScriptProc(net *Network, name string, scriptPattern string) *RScriptProc {
+	script, cmdPattern, err := rScriptCommandPattern(scriptPattern)
+	if err != nil {
+		Failf("Process (%s) got invalid script pattern (%s): %v", name, scriptPattern, err)
+	}
+	p := &RScriptProc{
+		ExecProc:      newExecProc(net, name, cmdPattern),
+		Script:        script,
+		ScriptPattern: scriptPattern,
+	}
+	p.SetOutDirFiles(rScriptFilesPort)
+	p.wrapError = func(err *ExecError) error {
+		return parseRError(err)
+	}
+	// The ports belong to the RScriptProc, rather than to the ExecProc it
+	// wraps, which is not in the network
+	for _, pt := range p.InPorts() {
+		pt.SetProcess(p)
+	}
+	for _, pt := range p.OutPorts() {
+		pt.SetProcess(p)
+	}
+	net.AddProc(p)
+	return p
+}
+
+// Files returns the out-port on which the files the script writes to its
+// working directory are sent
+func (p *RScriptProc) Files() *OutPort { return p.OutPort(rScriptFilesPort) }
+
+// rScriptCommandPattern returns the script path of scriptPattern, and the
+// command pattern of the ExecProc running it, passing each placeholder as a
+// shell-quoted type:name=value argument to rScriptWrapper
+func rScriptCommandPattern(scriptPattern string) (string, string, error) {
+	fields := strings.Fields(scriptPattern)
+	if len(fields) == 0 {
+		return "", "", fmt.Errorf("no script")
+	}
+	script := fields[0]
+	if strings.Contains(script, "{") {
+		return "", "", fmt.Errorf("the script path can not contain placeholders")
+	}
+	args := []string{RScriptCommand, "--vanilla", "-e", template.ShellQuote(rScriptWrapper), template.ShellQuote(script)}
+	for _, field := range fields[1:] {
+		tpl, err := template.Parse(field)
+		if err != nil {
+			return "", "", err
+		}
+		phs := tpl.Placeholders()
+		if len(phs) != 1 || phs[0].Raw != field {
+			return "", "", fmt.Errorf("argument (%s) is not a single placeholder", field)
+		}
+		ph := phs[0]
+		typ := ph.Type
+		switch typ {
+		case "i", "o", "p", "t":
+		case "os":
+			typ = "o"
+		default:
+			return "", "", fmt.Errorf("unsupported placeholder %s", ph.Raw)
+		}
+		if typ == "o" && ph.Name == rScriptFilesPort {
+			return "", "", fmt.Errorf("the out-port name (%s) is reserved for the files written by the script", ph.Name)
+		}
+		quoted := strings.TrimSuffix(ph.Raw, "}") + "|quote}"
+		args = append(args, template.ShellQuote(typ+":"+ph.Name+"=")+quoted)
+	}
+	args = append(args, template.ShellQuote("o:"+rScriptFilesPort+"=")+"{o:"+rScriptFilesPort+"|quote}")
+	return script, strings.Join(args, " "), nil
+}
+
+// ------------------------------------------------------------------------
+// RError
+// ------------------------------------------------------------------------
+
+// RError is the error of a failed R script of an RScriptProc, with the R
+// error message and traceback parsed from its stderr
+type RError struct {
+	*ExecError
+	// Message is the R error message, such as
+	// "Error in log(-x): non-numeric argument to mathematical function"
+	Message string
+	// Traceback are the calls leading to the error, outermost first
+	Traceback []string
+}
+
+// Error returns the R error message and traceback, or the message of the
+// ExecError if the script did not fail with an R error
+func (e *RError) Error() string {
+	if e.Message == "" {
+		return e.ExecError.Error()
+	}
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "R script failed with exit code %d: %s", e.ExitCode, e.Message)
+	if len(e.Traceback) > 0 {
+		sb.WriteString("\nTraceback:")
+		for i, call := range e.Traceback {
+			fmt.Fprintf(sb, "\n%d: %s", i+1, call)
+		}
+	}
+	return sb.String()
+}
+
+// Unwrap returns the ExecError of the failed command
+func (e *RError) Unwrap() error {
+	return e.ExecError
+}
+
+var rTracebackLinePtn = regexp.MustCompile(`^\d+: (.*)$`)
+
+// parseRError returns an RError for err, with the last R error message and
+// traceback written to stderr by rScriptWrapper, if any
+func parseRError(err *ExecError) *RError {
+	rErr := &RError{ExecError: err}
+	lines := strings.Split(strings.TrimRight(err.Stderr, "\n"), "\n")
+	start := -1
+	for i, line := range lines {
+		if strings.HasPrefix(line, "Error") {
+			start = i
+		}
+	}
+	if start < 0 {
+		return rErr
+	}
+	msg := []string{}
+	i := start
+	for ; i < len(lines) && lines[i] != "Traceback:"; i++ {
+		msg = append(msg, lines[i])
+	}
+	rErr.Message = strings.Join(msg, "\n")
+	for i++; i < len(lines); i++ {
+		m := rTracebackLinePtn.FindStringSubmatch(lines[i])
+		if m == nil {
+			break
+		}
+		rErr.Traceback = append(rErr.Traceback, m[1])
+	}
+	return rErr
+}
@@ -0,0 +1,180 @@
+package flowbase
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// fakeRscript is a stand-in for Rscript, writing a plot and a table to the
+// directory of the files out-port, and the input path to the out-port out,
+// or failing like rScriptWrapper if the fail parameter is set
+const fakeRscript = `#!/bin/bash
+for arg in "$@"; do
+	case "$arg" in
+		i:in=*) in="${arg#i:in=}" ;;
+		o:out=*) out="${arg#o:out=}" ;;
+		o:files=*) files="${arg#o:files=}" ;;
+		p:fail=TRUE)
+			echo "Loading data" >&2
+			echo "Error in log(x): non-numeric argument to mathematical function" >&2
+			echo "Traceback:" >&2
+			echo "1: source(script)" >&2
+			echo "2: log(x)" >&2
+			exit 1 ;;
+	esac
+done
+mkdir -p "$files"
+echo plot > "$files/Rplots.pdf"
+echo table > "$files/counts.csv"
+echo "$in" > "$out"
+`
+
+func TestRScriptProc(t *testing.T) {
+	initTestLogs()
+	dir := t.TempDir()
+	rscript := filepath.Join(dir, "Rscript")
+	if err := os.WriteFile(rscript, []byte(fakeRscript), 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer func(cmd string) { RScriptCommand = cmd }(RScriptCommand)
+	RScriptCommand = rscript
+
+	net := NewNetwork("TestRScriptProc")
+	src := NewFileSource(net, "src", "in file.txt")
+	r := NewRScriptProc(net, "r", "plot.R {i:in} {o:out} {p:fail}")
+	r.SetParam("fail", "FALSE")
+	r.SetOut("out", dir+"/out.txt")
+	r.SetOutPathFunc("files", func(*ExecTask) string { return dir + "/files" })
+	r.In("in").From(src.Out())
+
+	out := NewPacketCollector(net, "out")
+	out.In().From(r.Out("out"))
+	files := NewPacketCollector(net, "files")
+	files.In().From(r.Files())
+
+	net.Run()
+
+	if len(out.Data) != 1 {
+		t.Fatalf("Expected one output, got: %v", out.Data)
+	}
+	content, err := os.ReadFile(dir + "/out.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "in file.txt\n" {
+		t.Errorf("Expected the input path to be passed unsplit, got: %q", content)
+	}
+	names := []string{}
+	for _, data := range files.Data {
+		names = append(names, filepath.Base(data.(*FileIP).Path()))
+	}
+	assertEqualValues(t, []string{"Rplots.pdf", "counts.csv"}, names)
+}
+
+func TestRScriptProcError(t *testing.T) {
+	initTestLogs()
+	dir := t.TempDir()
+	rscript := filepath.Join(dir, "Rscript")
+	if err := os.WriteFile(rscript, []byte(fakeRscript), 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer func(cmd string) { RScriptCommand = cmd }(RScriptCommand)
+	RScriptCommand = rscript
+
+	net := NewNetwork("TestRScriptProcError")
+	r := NewRScriptProc(net, "r", "plot.R {p:fail}")
+	r.SetParam("fail", "TRUE")
+	r.FailOnError = false
+	errs := NewPacketCollector(net, "errs")
+	errs.In().From(r.Errors())
+
+	net.Run()
+
+	if len(errs.Data) != 1 {
+		t.Fatalf("Expected one error, got: %v", errs.Data)
+	}
+	rErr, ok := errs.Data[0].(*RError)
+	if !ok {
+		t.Fatalf("Expected an *RError, got: %T", errs.Data[0])
+	}
+	if rErr.Message != "Error in log(x): non-numeric argument to mathematical function" {
+		t.Errorf("Unexpected R error message: %q", rErr.Message)
+	}
+	if !reflect.DeepEqual(rErr.Traceback, []string{"source(script)", "log(x)"}) {
+		t.Errorf("Unexpected R traceback: %q", rErr.Traceback)
+	}
+	var execErr *ExecError
+	if !errors.As(rErr, &execErr) || execErr.ExitCode != 1 {
+		t.Errorf("Expected the RError to wrap an ExecError with exit code 1, got: %v", execErr)
+	}
+}
+
+func TestRScriptProcPorts(t *testing.T) {
+	net := NewNetwork("TestRScriptProcPorts")
+	r := NewRScriptProc(net, "r", "scripts/de.R {i:counts} {i:samples} {o:table} {p:alpha} {t:batch}")
+
+	assertEqualValues(t, []string{"counts", "samples"}, sortedKeys(r.InPorts()))
+	assertEqualValues(t, []string{"errors", "files", "stdout", "table"}, sortedKeys(r.OutPorts()))
+	if r.Out("table").Process() != Node(r) {
+		t.Errorf("Expected the ports to belong to the RScriptProc")
+	}
+	if net.Proc("r") != Node(r) {
+		t.Errorf("Expected the RScriptProc to be added to the network")
+	}
+
+	r.SetParam("alpha", "0.05")
+	metadata := r.ComponentMetadata()
+	want := map[string]string{"script": "scripts/de.R {i:counts} {i:samples} {o:table} {p:alpha} {t:batch}", "param.alpha": "0.05"}
+	if !reflect.DeepEqual(metadata, want) {
+		t.Errorf("Expected metadata %v, got %v", want, metadata)
+	}
+}
+
+func TestRScriptProcRunsR(t *testing.T) {
+	if _, err := exec.LookPath(RScriptCommand); err != nil {
+		t.Skip("Rscript not available")
+	}
+	initTestLogs()
+	dir := t.TempDir()
+	script := filepath.Join(dir, "script.R")
+	if err := os.WriteFile(script, []byte(`
+x <- as.numeric(readLines(inputs$values))
+writeLines(format(sum(x) * params$scale), outputs$out)
+write.csv(data.frame(x = x), "x.csv", row.names = FALSE)
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	in := filepath.Join(dir, "in.txt")
+	if err := os.WriteFile(in, []byte("1\n2\n3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	net := NewNetwork("TestRScriptProcRunsR")
+	src := NewFileSource(net, "src", in)
+	r := NewRScriptProc(net, "r", script+" {i:values} {o:out} {p:scale}")
+	r.SetParam("scale", "2")
+	r.SetOut("out", dir+"/sum.txt")
+	r.SetOutPathFunc("files", func(*ExecTask) string { return dir + "/files" })
+	r.In("values").From(src.Out())
+	files := NewPacketCollector(net, "files")
+	files.In().From(r.Files())
+	out := NewPacketCollector(net, "out")
+	out.In().From(r.Out("out"))
+
+	net.Run()
+
+	content, err := os.ReadFile(dir + "/sum.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "12\n" {
+		t.Errorf("Expected 12, got: %q", content)
+	}
+	if len(files.Data) != 1 || filepath.Base(files.Data[0].(*FileIP).Path()) != "x.csv" {
+		t.Errorf("Expected x.csv to be sent on the files out-port, got: %v", files.Data)
+	}
+}
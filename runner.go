@@ -0,0 +1,215 @@
+package flowbase
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RunBuilder builds the network net for one run of a Runner, with the
+// parameters params, writing its outputs in the directory dir, which is only
+// used by this run
+type RunBuilder func(net *Network, params map[string]string, dir string)
+
+// Runner runs the same network repeatedly, once per parameter set, such as
+// for experiments and benchmarks. Each run gets a directory of its own,
+// <Dir>/<run ID>, which is also the output directory of its network (see
+// Network.SetOutDir), so that the outputs and audit files of runs at the same
+// time do not overwrite each other. Each run also gets a RunReport, and the
+// reports of all runs are written to <Dir>/report.json when all runs of a call
+// to Run have finished. Runs are numbered across calls to Run, so repeated
+// calls get new run directories too, but different Runners should not share
+// the same Dir.
+type Runner struct {
+	name      string
+	paramSets []map[string]string
+	build     RunBuilder
+	// reports are the reports of the runs of earlier calls to Run, and
+	// started the number of runs started, both protected by mx
+	reports []*RunReport
+	started int
+	mx      sync.Mutex
+	// Dir is the directory in which the run directories and report are
+	// created. Defaults to "runs/<name>".
+	Dir string
+	// Parallelism is the number of runs run at the same time. Defaults to 1,
+	// so that the timings of benchmarks are not skewed by each other.
+	Parallelism int
+	// CostRates are the rates at which costs are attributed to the
+	// processes of each run, from the usage they report (see
+	// CostAccountant), and ProcessCostRates the rates of processes with
+	// other rates than those, by process name
+	CostRates        CostRates
+	ProcessCostRates map[string]CostRates
+}
+
+// RunReport describes one run of a Runner
+type RunReport struct {
+	RunID      string
+	Params     map[string]string
+	Dir        string
+	StartTime  time.Time
+	FinishTime time.Time
+	Duration   time.Duration
+	// PacketsSent is the number of packets sent in the network
+	PacketsSent int
+	// ProcessTimes is the time from start to finish of each process
+	ProcessTimes map[string]time.Duration
+	// Costs are the usage of billable resources and the cost of each
+	// process which reported any usage, and TotalCost the cost of the run
+	// (see Runner.CostRates)
+	Costs     map[string]*ProcessCost
+	TotalCost float64
+}
+
+// NewRunner returns a new Runner, named name, running the networks built by
+// build once for each of the parameter sets paramSets (see ReadParamSets)
+func NewRunner(name string, paramSets []map[string]string, build RunBuilder) *Runner {
+	return &Runner{
+		name:        name,
+		paramSets:   paramSets,
+		build:       build,
+		Dir:         filepath.Join("runs", name),
+		Parallelism: 1,
+	}
+}
+
+// Run runs the network once per parameter set, and returns the reports of
+// the runs, in the order of the parameter sets
+func (r *Runner) Run() []*RunReport {
+	parallelism := r.Parallelism
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	r.mx.Lock()
+	first := r.started
+	r.started += len(r.paramSets)
+	r.mx.Unlock()
+
+	reports := make([]*RunReport, len(r.paramSets))
+	slots := make(chan struct{}, parallelism)
+	wg := &sync.WaitGroup{}
+	for i, params := range r.paramSets {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(i int, params map[string]string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			reports[i] = r.runOnce(first+i, params)
+		}(i, params)
+	}
+	wg.Wait()
+
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.reports = append(r.reports, reports...)
+	reportJSON, err := json.MarshalIndent(r.reports, "", "    ")
+	if err != nil {
+		Failf("[Runner:%s] Could not marshal run reports: %v", r.name, err)
+	}
+	reportPath := filepath.Join(r.Dir, "report.json")
+	createDirs(reportPath)
+	if err := os.WriteFile(reportPath, reportJSON, 0644); err != nil {
+		Failf("[Runner:%s] Could not write run report %s: %v", r.name, reportPath, err)
+	}
+	return reports
+}
+
+// runOnce builds and runs the network for the parameter set params, as the
+// run with the index i of the runner, and returns its report
+func (r *Runner) runOnce(i int, params map[string]string) *RunReport {
+	runID := fmt.Sprintf("%s-%03d", r.name, i+1)
+	report := &RunReport{
+		RunID:        runID,
+		Params:       params,
+		Dir:          filepath.Join(r.Dir, runID),
+		ProcessTimes: map[string]time.Duration{},
+	}
+	if err := os.MkdirAll(report.Dir, 0777); err != nil {
+		Failf("[Runner:%s] Could not create run directory %s: %v", r.name, report.Dir, err)
+	}
+
+	net := NewNetwork(runID)
+	net.SetRunID(runID)
+	net.SetOutDir(report.Dir)
+	r.build(net, params, report.Dir)
+
+	mx := sync.Mutex{}
+	started := map[string]time.Time{}
+	unsubscribe := net.Subscribe(EventTypes(EventPacketSent, EventProcessStarted, EventProcessFinished), func(e *Event) {
+		mx.Lock()
+		defer mx.Unlock()
+		switch e.Type {
+		case EventPacketSent:
+			report.PacketsSent++
+		case EventProcessStarted:
+			started[e.Process] = e.Time
+		case EventProcessFinished:
+			report.ProcessTimes[e.Process] = e.Time.Sub(started[e.Process])
+		}
+	})
+	defer unsubscribe()
+	costs := NewCostAccountant(net, r.CostRates, r.ProcessCostRates)
+	defer costs.Stop()
+
+	Audit.Printf("[Runner:%s] Starting run %s with parameters %v\n", r.name, runID, params)
+	report.StartTime = time.Now()
+	net.Run()
+	report.FinishTime = time.Now()
+	report.Duration = report.FinishTime.Sub(report.StartTime)
+	report.Costs = costs.Costs()
+	report.TotalCost = costs.TotalCost()
+	Audit.Printf("[Runner:%s] Finished run %s in %s\n", r.name, runID, report.Duration)
+	return report
+}
+
+// ReadParamSets reads parameter sets for a Runner from the file at path,
+// which is either a CSV file, with the parameter names in the header row and
+// one parameter set per row, or, if it has the extension .json, a JSON array
+// of objects, with one parameter set per object
+func ReadParamSets(path string) ([]map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errWrapf(err, "could not open parameter sets file %s", path)
+	}
+	defer file.Close()
+
+	paramSets := []map[string]string{}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		dec := json.NewDecoder(file)
+		dec.UseNumber()
+		objects := []map[string]any{}
+		if err := dec.Decode(&objects); err != nil {
+			return nil, errWrapf(err, "could not parse parameter sets file %s", path)
+		}
+		for _, obj := range objects {
+			params := map[string]string{}
+			for name, value := range obj {
+				params[name] = fmt.Sprint(value)
+			}
+			paramSets = append(paramSets, params)
+		}
+		return paramSets, nil
+	}
+
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, errWrapf(err, "could not parse parameter sets file %s", path)
+	}
+	if len(rows) == 0 {
+		return paramSets, nil
+	}
+	for _, row := range rows[1:] {
+		params := map[string]string{}
+		for i, name := range rows[0] {
+			params[name] = row[i]
+		}
+		paramSets = append(paramSets, params)
+	}
+	return paramSets, nil
+}
@@ -0,0 +1,103 @@
+package flowbase
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunner(t *testing.T) {
+	initTestLogs()
+	dir := t.TempDir()
+	specPath := filepath.Join(dir, "params.csv")
+	if err := os.WriteFile(specPath, []byte("size,mode\n10,fast\n20,slow\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	paramSets, err := ReadParamSets(specPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runner := NewRunner("bench", paramSets, func(net *Network, params map[string]string, dir string) {
+		src := NewIIPSource(net, "src", filepath.Join(dir, params["mode"]+".txt"))
+		collector := NewPacketCollector(net, "collector")
+		collector.In().From(src.Out())
+	})
+	runner.Dir = filepath.Join(dir, "runs")
+	runner.Parallelism = 2
+	reports := runner.Run()
+
+	assertEqualValues(t, 2, len(reports))
+	assertEqualValues(t, "bench-002", reports[1].RunID)
+	assertEqualValues(t, map[string]string{"size": "20", "mode": "slow"}, reports[1].Params)
+	assertEqualValues(t, 1, reports[0].PacketsSent)
+	if _, ok := reports[0].ProcessTimes["collector"]; !ok {
+		t.Errorf("No process time for collector: %v", reports[0].ProcessTimes)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "runs", "bench-001")); err != nil {
+		t.Errorf("Run directory not created: %v", err)
+	}
+
+	reportJSON, err := os.ReadFile(filepath.Join(dir, "runs", "report.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	written := []*RunReport{}
+	if err := json.Unmarshal(reportJSON, &written); err != nil {
+		t.Fatal(err)
+	}
+	assertEqualValues(t, "bench-001", written[0].RunID)
+}
+
+func TestRunnerRunDirs(t *testing.T) {
+	initTestLogs()
+	dir := t.TempDir()
+	paramSets := []map[string]string{{"mode": "fast"}, {"mode": "slow"}}
+	runner := NewRunner("dirs", paramSets, func(net *Network, params map[string]string, dir string) {
+		// The output gets the same default path in all runs
+		write := NewExecProc(net, "write", "echo {p:mode} > {o:out}")
+		write.SetParam("mode", params["mode"])
+		collector := NewPacketCollector(net, "collector")
+		collector.In().From(write.Out("out"))
+	})
+	runner.Dir = filepath.Join(dir, "runs")
+	runner.Parallelism = 2
+
+	// Repeated calls get new runs, instead of overwriting the earlier ones
+	reports := append(runner.Run(), runner.Run()...)
+	assertEqualValues(t, "dirs-004", reports[3].RunID)
+	for i, report := range reports {
+		outPath := filepath.Join(report.Dir, "write.out.0.out")
+		out, err := os.ReadFile(outPath)
+		if err != nil {
+			t.Fatalf("Output of run %s not in its run directory: %v", report.RunID, err)
+		}
+		assertEqualValues(t, paramSets[i%2]["mode"]+"\n", string(out))
+		if _, err := os.Stat(outPath + auditFileSuffix); err != nil {
+			t.Errorf("Audit file of run %s not in its run directory: %v", report.RunID, err)
+		}
+	}
+
+	reportJSON, err := os.ReadFile(filepath.Join(dir, "runs", "report.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	written := []*RunReport{}
+	if err := json.Unmarshal(reportJSON, &written); err != nil {
+		t.Fatal(err)
+	}
+	assertEqualValues(t, 4, len(written))
+}
+
+func TestReadParamSetsJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "params.json")
+	if err := os.WriteFile(path, []byte(`[{"size": 10, "mode": "fast"}, {"size": 2.5, "mode": "slow"}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	paramSets, err := ReadParamSets(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqualValues(t, []map[string]string{{"size": "10", "mode": "fast"}, {"size": "2.5", "mode": "slow"}}, paramSets)
+}
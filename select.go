@@ -0,0 +1,72 @@
+package flowbase
+
+import (
+	"reflect"
+)
+
+// Select waits until any of the provided in-ports has a packet available, or
+// is closed, and returns that port together with the received packet. If the
+// returned port was closed, ok is false and ip is nil, and the port should be
+// left out from subsequent calls. Packets are received like with RecvOK,
+// including being loaded back if they were spilled to disk (see
+// SetMemoryBudget).
+func Select(ports ...*InPort) (port *InPort, ip *Packet, ok bool) {
+	if len(ports) == 0 {
+		Fail("Select called without any in-ports")
+	}
+	for {
+		cases := make([]reflect.SelectCase, 0, len(ports))
+		casePorts := make([]*InPort, 0, len(ports))
+		for _, pt := range ports {
+			if pt.rings == nil {
+				cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(pt.Chan)})
+				casePorts = append(casePorts, pt)
+				continue
+			}
+			// In-ports using ring buffers are checked directly, and else
+			// waited on until a packet is added, or the port is closed
+			if ip, ok, closed := pt.rings.tryGet(); ok {
+				return pt, pt.received(ip), true
+			} else if closed {
+				return pt, nil, false
+			}
+			cases = append(cases,
+				reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(pt.rings.ready)},
+				reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(pt.rings.done)})
+			casePorts = append(casePorts, pt, pt)
+		}
+		chosen, val, ok := reflect.Select(cases)
+		pt := casePorts[chosen]
+		if pt.rings != nil {
+			continue
+		}
+		if !ok {
+			return pt, nil, false
+		}
+		return pt, pt.received(val.Interface().(*Packet)), true
+	}
+}
+
+// SelectAll receives packets from all the provided in-ports, in the order in
+// which they arrive, and calls handler with each of them together with the
+// port it arrived on, until all the ports are closed
+func SelectAll(handler func(port *InPort, ip *Packet), ports ...*InPort) {
+	open := append([]*InPort{}, ports...)
+	for len(open) > 0 {
+		port, ip, ok := Select(open...)
+		if !ok {
+			open = removePort(open, port)
+			continue
+		}
+		handler(port, ip)
+	}
+}
+
+func removePort(ports []*InPort, port *InPort) []*InPort {
+	for i, pt := range ports {
+		if pt == port {
+			return append(ports[:i], ports[i+1:]...)
+		}
+	}
+	return ports
+}
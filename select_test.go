@@ -0,0 +1,52 @@
+package flowbase
+
+import (
+	"testing"
+)
+
+func TestSelectAll(t *testing.T) {
+	initTestLogs()
+
+	in1 := NewInPort("in1")
+	in2 := NewInPort("in2")
+	in1.Send(NewPacket("a"))
+	in1.Send(NewPacket("b"))
+	in2.Send(NewPacket(1))
+	close(in1.Chan)
+	close(in2.Chan)
+
+	got := map[string][]any{}
+	SelectAll(func(port *InPort, ip *Packet) {
+		got[port.Name()] = append(got[port.Name()], ip.Data())
+	}, in1, in2)
+
+	assertEqualValues(t, []any{"a", "b"}, got["in1"])
+	assertEqualValues(t, []any{1}, got["in2"])
+}
+
+func TestSelectRingBuffer(t *testing.T) {
+	initTestLogs()
+
+	chanIn := NewInPort("chan")
+	ringIn := NewInPort("ring")
+	ringIn.SetRingBuffer(4)
+	chanOut, ringOut := NewOutPort("chan_out"), NewOutPort("ring_out")
+	chanIn.From(chanOut)
+	ringIn.From(ringOut)
+
+	go func() {
+		ringOut.Send("a")
+		chanOut.Send(1)
+		ringOut.Send("b")
+		ringOut.Close()
+		chanOut.Close()
+	}()
+
+	got := map[string][]any{}
+	SelectAll(func(port *InPort, ip *Packet) {
+		got[port.Name()] = append(got[port.Name()], ip.Data())
+	}, chanIn, ringIn)
+
+	assertEqualValues(t, []any{"a", "b"}, got["ring"])
+	assertEqualValues(t, []any{1}, got["chan"])
+}
@@ -0,0 +1,136 @@
+package flowbase
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// SimulationConfig is a hypothetical configuration of compute resources, for
+// estimating the run time of a network with Simulate. Limits which are 0
+// are unlimited.
+type SimulationConfig struct {
+	// MaxConcurrentTasks is the number of tasks which can run at the same
+	// time
+	MaxConcurrentTasks int
+	// Cores is the number of cores shared by the tasks
+	Cores int
+	// MemoryMB is the memory shared by the tasks, in MB
+	MemoryMB int
+	// Resources are the resources needed by each task of the processes, by
+	// process name. Tasks of other processes need one core.
+	Resources map[string]Resources
+}
+
+// SimulatedTask is a task scheduled by Simulate, with its start and finish
+// time, relative to the start of the simulated run
+type SimulatedTask struct {
+	Task   *AuditInfo
+	Start  time.Duration
+	Finish time.Duration
+}
+
+// SimulationResult is the outcome of Simulate
+type SimulationResult struct {
+	// Duration is the estimated run time
+	Duration time.Duration
+	// Tasks are the simulated tasks, in the order they were started
+	Tasks []*SimulatedTask
+	// MaxConcurrency is the largest number of tasks running at the same time
+	MaxConcurrency int
+}
+
+// Simulate estimates the run time of a network under the configuration
+// conf, by scheduling its tasks infos, as recorded in a previous run (see
+// ReadAuditInfos), with their recorded durations, such as for sizing a
+// cluster before paying for it. A task is started as soon as its upstream
+// tasks have finished, and the resources it needs are free, in the order
+// the tasks started in the recorded run. Time spent outside of tasks, such
+// as in sources, is not included in the estimate.
+func Simulate(infos []*AuditInfo, conf SimulationConfig) (*SimulationResult, error) {
+	byID := map[string]*AuditInfo{}
+	for _, ai := range infos {
+		byID[ai.ID] = ai
+	}
+	needs := func(ai *AuditInfo) Resources {
+		res, ok := conf.Resources[ai.ProcessName]
+		if !ok {
+			res = Resources{Cores: 1}
+		}
+		return res
+	}
+
+	// The remaining upstream tasks of each task, and the tasks downstream
+	// of each task
+	waitingFor := map[string]int{}
+	downstream := map[string][]*AuditInfo{}
+	for _, ai := range infos {
+		res := needs(ai)
+		if (conf.Cores > 0 && res.Cores > conf.Cores) || (conf.MemoryMB > 0 && res.MemoryMB > conf.MemoryMB) {
+			return nil, fmt.Errorf("tasks of process %s need more resources (%d cores, %d MB) than available (%d cores, %d MB)",
+				ai.ProcessName, res.Cores, res.MemoryMB, conf.Cores, conf.MemoryMB)
+		}
+		for _, up := range executedUpstream(ai, byID, map[string]bool{}) {
+			waitingFor[ai.ID]++
+			downstream[up.ID] = append(downstream[up.ID], ai)
+		}
+	}
+	// order is the position of each task in the recorded run, which is the
+	// order in which ready tasks are started
+	order := map[string]int{}
+	ready := []*AuditInfo{}
+	for i, ai := range infos {
+		order[ai.ID] = i
+		if waitingFor[ai.ID] == 0 {
+			ready = append(ready, ai)
+		}
+	}
+
+	result := &SimulationResult{}
+	running := []*SimulatedTask{}
+	cores, memoryMB := 0, 0
+	now := time.Duration(0)
+	for len(ready) > 0 || len(running) > 0 {
+		// Start the ready tasks which fit in the free resources
+		sort.Slice(ready, func(i, j int) bool { return order[ready[i].ID] < order[ready[j].ID] })
+		stillReady := []*AuditInfo{}
+		for _, ai := range ready {
+			res := needs(ai)
+			fits := (conf.MaxConcurrentTasks == 0 || len(running) < conf.MaxConcurrentTasks) &&
+				(conf.Cores == 0 || cores+res.Cores <= conf.Cores) &&
+				(conf.MemoryMB == 0 || memoryMB+res.MemoryMB <= conf.MemoryMB)
+			if !fits {
+				stillReady = append(stillReady, ai)
+				continue
+			}
+			task := &SimulatedTask{Task: ai, Start: now, Finish: now + ai.FinishTime.Sub(ai.StartTime)}
+			running = append(running, task)
+			result.Tasks = append(result.Tasks, task)
+			cores += res.Cores
+			memoryMB += res.MemoryMB
+		}
+		ready = stillReady
+		if len(running) > result.MaxConcurrency {
+			result.MaxConcurrency = len(running)
+		}
+
+		// Finish the task finishing first, and the ones finishing with it
+		sort.Slice(running, func(i, j int) bool { return running[i].Finish < running[j].Finish })
+		now = running[0].Finish
+		for len(running) > 0 && running[0].Finish == now {
+			done := running[0]
+			running = running[1:]
+			res := needs(done.Task)
+			cores -= res.Cores
+			memoryMB -= res.MemoryMB
+			for _, ai := range downstream[done.Task.ID] {
+				waitingFor[ai.ID]--
+				if waitingFor[ai.ID] == 0 {
+					ready = append(ready, ai)
+				}
+			}
+		}
+	}
+	result.Duration = now
+	return result, nil
+}
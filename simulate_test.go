@@ -0,0 +1,44 @@
+package flowbase
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSimulate(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	infos := []*AuditInfo{}
+	merge := newTimedAuditInfo("merge", "merge", t0, 2, 3)
+	for _, id := range []string{"a", "b", "c", "d"} {
+		ai := newTimedAuditInfo(id, "align", t0, 0, 2)
+		merge.Upstream[id] = ai
+		infos = append(infos, ai)
+	}
+	infos = append(infos, merge)
+
+	for _, tc := range []struct {
+		conf     SimulationConfig
+		duration time.Duration
+		maxConc  int
+	}{
+		{SimulationConfig{}, 3 * time.Second, 4},
+		{SimulationConfig{MaxConcurrentTasks: 2}, 5 * time.Second, 2},
+		{SimulationConfig{MaxConcurrentTasks: 1}, 9 * time.Second, 1},
+		{SimulationConfig{Cores: 4, Resources: map[string]Resources{"align": {Cores: 4}}}, 9 * time.Second, 1},
+		{SimulationConfig{MemoryMB: 8000, Resources: map[string]Resources{"align": {MemoryMB: 2500}}}, 5 * time.Second, 3},
+	} {
+		result, err := Simulate(infos, tc.conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqualValues(t, tc.duration, result.Duration)
+		assertEqualValues(t, tc.maxConc, result.MaxConcurrency)
+		assertEqualValues(t, 5, len(result.Tasks))
+		assertEqualValues(t, "merge", result.Tasks[4].Task.ID)
+	}
+
+	_, err := Simulate(infos, SimulationConfig{Cores: 2, Resources: map[string]Resources{"align": {Cores: 4}}})
+	if err == nil {
+		t.Error("expected an error for tasks needing more cores than available")
+	}
+}
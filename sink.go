@@ -9,7 +9,9 @@ type Sink struct {
 // NewSink returns a new Sink component
 func NewSink(net *Network, name string) *Sink {
 	p := &Sink{
-		BaseProcess: NewBaseProcess(net, name),
+		// The sink is run by the network itself, and not as one of its
+		// processes
+		BaseProcess: newDetachedBaseProcess(net, name),
 	}
 	p.InitInPort(p, "sink_in")
 	return p
@@ -27,7 +29,7 @@ func (p *Sink) Run() {
 	merged := make(chan int)
 	if p.in().Ready() {
 		go func() {
-			for ip := range p.in().Chan {
+			for ip, ok := p.in().RecvOK(); ok; ip, ok = p.in().RecvOK() {
 				Debug.Printf("Got file in sink: %s\n", ip.ID())
 			}
 			merged <- 1
@@ -39,3 +41,31 @@ func (p *Sink) Run() {
 	close(merged)
 	Debug.Printf("Caught up everything in sink")
 }
+
+// PacketCollector is a simple component that collects the data of all IPs it
+// receives on its In-port, such as for inspecting the results of a network
+// after it has run
+type PacketCollector struct {
+	BaseProcess
+	Data []any
+}
+
+// NewPacketCollector returns a new PacketCollector component
+func NewPacketCollector(net *Network, name string) *PacketCollector {
+	p := &PacketCollector{
+		BaseProcess: NewBaseProcess(net, name),
+	}
+	p.InitInPort(p, "in")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *PacketCollector) In() *InPort { return p.InPort("in") }
+
+// Run runs the PacketCollector process
+func (p *PacketCollector) Run() {
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		p.Data = append(p.Data, ip.Data())
+	}
+}
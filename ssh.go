@@ -0,0 +1,207 @@
+package flowbase
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/flowbase/flowbase/template"
+)
+
+// SSHConfig contains configuration for running commands on remote hosts over
+// SSH, using the ssh and scp command line tools. Authentication is done with
+// the key in IdentityFile, if set, or else with the keys of the SSH agent, or
+// the defaults of the ssh configuration. Password prompts are disabled.
+type SSHConfig struct {
+	// Hosts are the hosts to run commands on, on the form [user@]host, which
+	// are used in turn, one task at a time
+	Hosts []string
+	// User is the user to log in as, on hosts without one
+	User string
+	// Port is the SSH port. Defaults to the one of the ssh configuration.
+	Port int
+	// IdentityFile is the path to a private key
+	IdentityFile string
+	// RemoteDir is the directory commands are run in, and relative input and
+	// output paths are relative to, on the remote hosts. Defaults to the home
+	// directory.
+	RemoteDir string
+	// UploadInputs makes input files be copied to the remote host before the
+	// command is run. If false, the inputs need to be available at the same
+	// paths on the remote hosts already, such as on shared storage.
+	UploadInputs bool
+	// SSHExecutable and SCPExecutable are the executables to use. Default to
+	// "ssh" and "scp".
+	SSHExecutable string
+	SCPExecutable string
+	// ExtraArgs are added to both ssh and scp commands, such as
+	// "-o", "StrictHostKeyChecking=accept-new"
+	ExtraArgs []string
+}
+
+// SSHExecutor runs commands on remote hosts over SSH, and copies the output
+// files back to their local paths when the commands have finished
+type SSHExecutor struct {
+	conf SSHConfig
+	next uint64
+	// stderr is called for every line written to stderr, if set
+	stderr func(t *ExecTask, line string)
+}
+
+// NewSSHExecutor returns a new SSHExecutor, configured by conf
+func NewSSHExecutor(conf SSHConfig) *SSHExecutor {
+	if len(conf.Hosts) == 0 {
+		Fail("No hosts set in SSH config")
+	}
+	if conf.SSHExecutable == "" {
+		conf.SSHExecutable = "ssh"
+	}
+	if conf.SCPExecutable == "" {
+		conf.SCPExecutable = "scp"
+	}
+	return &SSHExecutor{conf: conf}
+}
+
+// SetSSH makes the ExecProc run its commands on remote hosts over SSH, as
+// configured by conf
+func (p *ExecProc) SetSSH(conf SSHConfig) {
+	p.SetExecutor(NewSSHExecutor(conf))
+}
+
+// Execute runs the command of task t on the next of the configured hosts,
+// and copies its output files back
+func (e *SSHExecutor) Execute(t *ExecTask, stdout func(line string)) error {
+	host := e.conf.Hosts[(atomic.AddUint64(&e.next, 1)-1)%uint64(len(e.conf.Hosts))]
+	if e.conf.User != "" && !strings.Contains(host, "@") {
+		host = e.conf.User + "@" + host
+	}
+
+	// Directories of inputs and outputs are created up front, since neither
+	// the command nor scp create them
+	dirs := []string{}
+	uploads := []string{}
+	if e.conf.UploadInputs {
+		// Inputs are paths, as for the {i:name} placeholders
+		for _, ip := range t.InPackets {
+			inPath := fmt.Sprint(ip.Data())
+			if _, err := os.Stat(inPath); err == nil {
+				uploads = append(uploads, inPath)
+				dirs = append(dirs, path.Dir(e.remotePath(inPath)))
+			}
+		}
+	}
+	for _, tempPath := range t.TempOutPaths {
+		dirs = append(dirs, path.Dir(e.remotePath(tempPath)))
+	}
+	if len(dirs) > 0 {
+		quoted := []string{}
+		for _, dir := range dirs {
+			quoted = append(quoted, template.ShellQuote(dir))
+		}
+		if out, err := e.sshCmd(host, "mkdir -p "+strings.Join(quoted, " ")).CombinedOutput(); err != nil {
+			return errWrapf(err, "Could not create directories on %s: %s", host, out)
+		}
+	}
+	for _, localPath := range uploads {
+		if out, err := e.scpCmd(localPath, host+":"+e.remotePath(localPath)).CombinedOutput(); err != nil {
+			return errWrapf(err, "Could not upload %s to %s: %s", localPath, host, out)
+		}
+	}
+
+	remoteCmd := "bash -c " + template.ShellQuote(t.Command)
+	if e.conf.RemoteDir != "" {
+		remoteCmd = "cd " + template.ShellQuote(e.conf.RemoteDir) + " && " + remoteCmd
+	}
+	var stderr func(line string)
+	if e.stderr != nil {
+		stderr = func(line string) { e.stderr(t, line) }
+	}
+	if err := runCmdStreamingStderr(e.sshCmd(host, remoteCmd), t.Command, stdout, stderr); err != nil {
+		return err
+	}
+
+	for outName, tempPath := range t.TempOutPaths {
+		if out, err := e.scpCmd("-r", host+":"+e.remotePath(tempPath), tempPath).CombinedOutput(); err != nil {
+			return errWrapf(err, "Could not copy output %s (%s) from %s: %s", outName, tempPath, host, out)
+		}
+	}
+	return nil
+}
+
+// remotePath returns the path on the remote hosts for the local path p
+func (e *SSHExecutor) remotePath(p string) string {
+	p = filepath.ToSlash(p)
+	if path.IsAbs(p) || e.conf.RemoteDir == "" {
+		return p
+	}
+	return path.Join(e.conf.RemoteDir, p)
+}
+
+func (e *SSHExecutor) sshCmd(host string, remoteCmd string) *exec.Cmd {
+	args := e.commonArgs()
+	if e.conf.Port != 0 {
+		args = append(args, "-p", strconv.Itoa(e.conf.Port))
+	}
+	args = append(args, host, remoteCmd)
+	return exec.Command(e.conf.SSHExecutable, args...)
+}
+
+func (e *SSHExecutor) scpCmd(args ...string) *exec.Cmd {
+	scpArgs := e.commonArgs()
+	if e.conf.Port != 0 {
+		scpArgs = append(scpArgs, "-P", strconv.Itoa(e.conf.Port))
+	}
+	return exec.Command(e.conf.SCPExecutable, append(scpArgs, args...)...)
+}
+
+// commonArgs returns the arguments common to ssh and scp commands
+func (e *SSHExecutor) commonArgs() []string {
+	args := []string{"-o", "BatchMode=yes"}
+	if e.conf.IdentityFile != "" {
+		args = append(args, "-i", e.conf.IdentityFile, "-o", "IdentitiesOnly=yes")
+	}
+	return append(args, e.conf.ExtraArgs...)
+}
+
+// ------------------------------------------------------------------------
+// SSHExec
+// ------------------------------------------------------------------------
+
+// SSHExec is an ExecProc running its commands on remote hosts over SSH (see
+// SSHConfig), which also sends the lines written to stderr, as string
+// packets, on the out-port Stderr, as they are written. Output files are
+// copied back to their local paths, and sent as *FileIPs, as for any
+// ExecProc.
+type SSHExec struct {
+	*ExecProc
+}
+
+// NewSSHExec returns a new SSHExec process, running the commands of
+// cmdPattern (see ExecProc) as configured by conf
+func NewSSHExec(net *Network, name string, cmdPattern string, conf SSHConfig) *SSHExec {
+	p := &SSHExec{newExecProc(net, name, cmdPattern)}
+	p.InitOutPort(p, "stderr")
+	executor := NewSSHExecutor(conf)
+	executor.stderr = func(t *ExecTask, line string) {
+		p.Stderr().SendPacket(p.newOutPacket(t, line))
+	}
+	p.SetExecutor(executor)
+	// The ports belong to the SSHExec, rather than to the ExecProc it wraps,
+	// which is not in the network
+	for _, pt := range p.InPorts() {
+		pt.SetProcess(p)
+	}
+	for _, pt := range p.OutPorts() {
+		pt.SetProcess(p)
+	}
+	net.AddProc(p)
+	return p
+}
+
+// Stderr returns the out-port on which the lines written to stderr are sent
+func (p *SSHExec) Stderr() *OutPort { return p.OutPort("stderr") }
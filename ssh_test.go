@@ -0,0 +1,64 @@
+package flowbase
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeSSHScripts are stand-ins for ssh and scp, which run commands, and copy
+// files, locally, ignoring options and the host part of remote paths
+var fakeSSHScripts = map[string]string{
+	"ssh": `#!/bin/bash
+exec bash -c "${@: -1}"
+`,
+	"scp": `#!/bin/bash
+src="${@: -2:1}"; dst="${@: -1}"
+cp -r "${src#*:}" "${dst#*:}"
+`,
+}
+
+func TestSSHExec(t *testing.T) {
+	initTestLogs()
+	binDir := t.TempDir()
+	for name, script := range fakeSSHScripts {
+		Check(os.WriteFile(filepath.Join(binDir, name), []byte(script), 0755))
+	}
+	remoteDir := t.TempDir()
+
+	// Relative paths, which are relative to RemoteDir on the remote host
+	inPath, outPath := "ssh_test_in.txt", "ssh_test_out/out.txt"
+	Check(os.WriteFile(inPath, []byte("hello\n"), 0644))
+	defer os.Remove(inPath)
+	defer os.RemoveAll(filepath.Dir(outPath))
+
+	net := NewNetwork("TestSSHExec")
+	src := NewFileSource(net, "src", inPath)
+	run := NewSSHExec(net, "run", "cat {i:in} > {o:out}; echo done; echo warning >&2", SSHConfig{
+		Hosts:         []string{"node1"},
+		User:          "flowbase",
+		RemoteDir:     remoteDir,
+		UploadInputs:  true,
+		SSHExecutable: filepath.Join(binDir, "ssh"),
+		SCPExecutable: filepath.Join(binDir, "scp"),
+	})
+	run.In("in").From(src.Out())
+	run.SetOutPathFunc("out", func(t *ExecTask) string { return outPath })
+	stdout := NewPacketCollector(net, "stdout")
+	stdout.In().From(run.Stdout())
+	stderr := NewPacketCollector(net, "stderr")
+	stderr.In().From(run.Stderr())
+	out := NewPacketCollector(net, "out")
+	out.In().From(run.Out("out"))
+	net.Run()
+
+	assertEqualValues(t, []any{"done"}, stdout.Data)
+	assertEqualValues(t, []any{"warning"}, stderr.Data)
+	assertEqualValues(t, []any{NewFileIP(outPath)}, out.Data)
+	if content, err := os.ReadFile(outPath); err != nil || string(content) != "hello\n" {
+		t.Errorf("Output not copied back: %q (%v)", content, err)
+	}
+	if _, err := os.Stat(filepath.Join(remoteDir, inPath)); err != nil {
+		t.Errorf("Input not uploaded: %v", err)
+	}
+}
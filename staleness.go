@@ -0,0 +1,73 @@
+package flowbase
+
+import (
+	"os"
+)
+
+// outputUpToDate tells whether the existing output file at outPath of task t
+// is up to date with respect to the input files of the task, according to
+// the staleness checks enabled on the process
+func (p *ExecProc) outputUpToDate(t *ExecTask, outPath string) bool {
+	if p.Version != "" && !p.madeByCompatibleVersion(outPath) {
+		return false
+	}
+	if p.ReuseIfNewer {
+		if p.newerThanInputs(t, outPath) {
+			return true
+		}
+		if !p.VerifyChecksums {
+			return false
+		}
+	}
+	if p.VerifyChecksums {
+		return p.checksumsMatch(t, outPath)
+	}
+	return true
+}
+
+// newerThanInputs tells whether the file at outPath was modified later than
+// all the input files of task t
+func (p *ExecProc) newerThanInputs(t *ExecTask, outPath string) bool {
+	outInfo, err := os.Stat(outPath)
+	if err != nil {
+		return false
+	}
+	for _, inPath := range t.inFilePaths() {
+		inInfo, err := os.Stat(inPath)
+		if err != nil {
+			return false
+		}
+		if inInfo.ModTime().After(outInfo.ModTime()) {
+			p.Auditf("Input %s is newer than output %s", inPath, outPath)
+			return false
+		}
+	}
+	return true
+}
+
+// madeByCompatibleVersion tells whether the output file at outPath was made
+// by a version of the process compatible with its current Version, according
+// to the version recorded in its audit file
+func (p *ExecProc) madeByCompatibleVersion(outPath string) bool {
+	ai, err := ReadAuditFile(outPath)
+	if err != nil {
+		p.Auditf("Could not read audit file of output %s, to check its version", outPath)
+		return false
+	}
+	if !sameMinorVersion(ai.ComponentVersion, p.Version) {
+		p.Auditf("Output %s was made by version %s, but the current version is %s", outPath, ai.ComponentVersion, p.Version)
+		return false
+	}
+	return true
+}
+
+// sameMinorVersion tells whether the versions a and b have the same major
+// and minor version, or, if they can't be parsed, are equal
+func sameMinorVersion(a string, b string) bool {
+	va, err1 := parseVersion(a)
+	vb, err2 := parseVersion(b)
+	if err1 != nil || err2 != nil {
+		return a == b
+	}
+	return va[0] == vb[0] && va[1] == vb[1]
+}
@@ -0,0 +1,225 @@
+package flowbase
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// StructProcess is a process wrapping a plain struct, whose ports are
+// declared with tagged fields, and created by RegisterStruct:
+//
+//	type Upper struct {
+//		In  <-chan string `flowbase:"in"`
+//		Out chan<- string `flowbase:"out"`
+//	}
+//
+//	func (u *Upper) Run() {
+//		for s := range u.In {
+//			u.Out <- strings.ToUpper(s)
+//		}
+//	}
+//
+// The tag is the direction of the port, "in" or "out", optionally followed
+// by the name of the port, such as `flowbase:"in,text"`. The name defaults
+// to the field name in lower case. The fields can be channels, which are
+// created by RegisterStruct, or *InPort and *OutPort fields, which are set
+// to the ports themselves. Channels of *Packet send and receive packets with
+// their tags, and channels of other types the data of the packets.
+//
+// The struct needs a Run() or Run() error method, which returns when it is
+// done. The channels of out-ports are closed after Run returns, and must not
+// be closed by the struct itself.
+type StructProcess struct {
+	BaseProcess
+	s     any
+	links []*structLink
+}
+
+// structLink is a channel field of the struct, and the port it is linked to
+type structLink struct {
+	port   string
+	in     bool
+	ch     reflect.Value
+	isData bool
+}
+
+// newStructLink returns a new link to the port with name port, with a new
+// channel with the element type of the channel type chanType
+func newStructLink(port string, in bool, chanType reflect.Type) *structLink {
+	return &structLink{
+		port:   port,
+		in:     in,
+		ch:     reflect.MakeChan(reflect.ChanOf(reflect.BothDir, chanType.Elem()), 0),
+		isData: chanType.Elem() != packetType,
+	}
+}
+
+// RegisterStruct adds a process with name name, wrapping the struct pointed
+// to by s, to the network net, with the ports declared by the tagged fields
+// of the struct (see StructProcess)
+func RegisterStruct(net *Network, name string, s any) *StructProcess {
+	p := &StructProcess{
+		BaseProcess: NewBaseProcess(net, name),
+		s:           s,
+	}
+	switch s.(type) {
+	case interface{ Run() }, interface{ Run() error }:
+	default:
+		p.Failf("%T has no Run() or Run() error method", s)
+	}
+	v := reflect.ValueOf(s)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		p.Failf("Can only register pointers to structs, got %T", s)
+	}
+	v = v.Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		tag, ok := field.Tag.Lookup("flowbase")
+		if !ok {
+			continue
+		}
+		if !field.IsExported() {
+			p.Failf("Field %s with a flowbase tag is not exported", field.Name)
+		}
+		dir, portName := tag, strings.ToLower(field.Name)
+		if i := strings.Index(tag, ","); i >= 0 {
+			dir, portName = tag[:i], tag[i+1:]
+		}
+		switch dir {
+		case "in":
+			p.initStructInPort(v.Field(i), field, portName)
+		case "out":
+			p.initStructOutPort(v.Field(i), field, portName)
+		default:
+			p.Failf("Invalid flowbase tag of field %s: %q, expected \"in\" or \"out\", optionally followed by a port name", field.Name, tag)
+		}
+	}
+	net.AddProc(p)
+	return p
+}
+
+var (
+	inPortType  = reflect.TypeOf((*InPort)(nil))
+	outPortType = reflect.TypeOf((*OutPort)(nil))
+	packetType  = reflect.TypeOf((*Packet)(nil))
+)
+
+func (p *StructProcess) initStructInPort(fv reflect.Value, field reflect.StructField, portName string) {
+	if field.Type != inPortType && (field.Type.Kind() != reflect.Chan || field.Type.ChanDir()&reflect.RecvDir == 0) {
+		p.Failf("In-port field %s needs to be a *InPort, or a channel to receive from, got %s", field.Name, field.Type)
+	}
+	p.InitInPort(p, portName)
+	if field.Type == inPortType {
+		fv.Set(reflect.ValueOf(p.InPort(portName)))
+		return
+	}
+	l := newStructLink(portName, true, field.Type)
+	fv.Set(l.ch)
+	p.links = append(p.links, l)
+}
+
+func (p *StructProcess) initStructOutPort(fv reflect.Value, field reflect.StructField, portName string) {
+	if field.Type != outPortType && (field.Type.Kind() != reflect.Chan || field.Type.ChanDir()&reflect.SendDir == 0) {
+		p.Failf("Out-port field %s needs to be a *OutPort, or a channel to send on, got %s", field.Name, field.Type)
+	}
+	p.InitOutPort(p, portName)
+	if field.Type == outPortType {
+		fv.Set(reflect.ValueOf(p.OutPort(portName)))
+		return
+	}
+	l := newStructLink(portName, false, field.Type)
+	fv.Set(l.ch)
+	p.links = append(p.links, l)
+}
+
+// Struct returns the struct wrapped by the process
+func (p *StructProcess) Struct() any {
+	return p.s
+}
+
+// Run runs the Run method of the struct, while forwarding packets between
+// the ports and the channel fields of the struct
+func (p *StructProcess) Run() {
+	defer p.CloseOutPorts()
+	err := runLinked(&p.BaseProcess, p.links, func() error {
+		switch s := p.s.(type) {
+		case interface{ Run() error }:
+			return s.Run()
+		case interface{ Run() }:
+			s.Run()
+		}
+		return nil
+	})
+	if err != nil {
+		p.Fail(err)
+	}
+}
+
+// runLinked runs run, while forwarding packets between the ports of p and
+// the channels of links, and returns the error of run, after the channels of
+// the out-ports are closed, and all values sent on them are forwarded
+func runLinked(p *BaseProcess, links []*structLink, run func() error) error {
+	done := make(chan struct{})
+	outs := &sync.WaitGroup{}
+	for _, l := range links {
+		if l.in {
+			go forwardIn(p.InPort(l.port), l, done)
+			continue
+		}
+		outs.Add(1)
+		go func(l *structLink) {
+			defer outs.Done()
+			forwardOut(p.OutPort(l.port), l)
+		}(l)
+	}
+	err := run()
+	close(done)
+	for _, l := range links {
+		if !l.in {
+			l.ch.Close()
+		}
+	}
+	outs.Wait()
+	return err
+}
+
+// forwardIn sends the packets received on the in-port inp on the channel of
+// l, which is closed when the in-port is, until done is closed
+func forwardIn(inp *InPort, l *structLink, done chan struct{}) {
+	for ip, ok := inp.RecvOK(); ok; ip, ok = inp.RecvOK() {
+		v := reflect.ValueOf(ip)
+		if l.isData {
+			v = reflect.ValueOf(ip.Data())
+			if !v.IsValid() {
+				v = reflect.Zero(l.ch.Type().Elem())
+			} else if !v.Type().AssignableTo(l.ch.Type().Elem()) {
+				inp.Failf("Can not receive data of type %s on in-port of type %s", v.Type(), l.ch.Type().Elem())
+			}
+		}
+		chosen, _, _ := reflect.Select([]reflect.SelectCase{
+			{Dir: reflect.SelectSend, Chan: l.ch, Send: v},
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(done)},
+		})
+		if chosen == 1 {
+			return
+		}
+	}
+	select {
+	case <-done:
+	default:
+		l.ch.Close()
+	}
+}
+
+// forwardOut sends the values sent on the channel of l on the out-port outp,
+// until the channel is closed
+func forwardOut(outp *OutPort, l *structLink) {
+	for v, ok := l.ch.Recv(); ok; v, ok = l.ch.Recv() {
+		if l.isData {
+			outp.Send(v.Interface())
+			continue
+		}
+		outp.SendPacket(v.Interface().(*Packet))
+	}
+}
@@ -0,0 +1,85 @@
+package flowbase
+
+import (
+	"strings"
+	"testing"
+)
+
+type upperStruct struct {
+	In  <-chan string `flowbase:"in"`
+	Out chan<- string `flowbase:"out"`
+}
+
+func (u *upperStruct) Run() {
+	for s := range u.In {
+		u.Out <- strings.ToUpper(s)
+	}
+}
+
+type tagStruct struct {
+	Files  chan *Packet `flowbase:"in,files"`
+	Tagged *OutPort     `flowbase:"out"`
+	count  int
+}
+
+func (s *tagStruct) Run() error {
+	for ip := range s.Files {
+		s.count++
+		s.Tagged.SendPacket(ip)
+	}
+	return nil
+}
+
+func TestRegisterStruct(t *testing.T) {
+	initTestLogs()
+	net := NewNetwork("TestRegisterStruct")
+
+	src := NewFileSource(net, "src", "a.txt", "b.txt")
+	upper := RegisterStruct(net, "upper", &upperStruct{})
+	tagger := RegisterStruct(net, "tagger", &tagStruct{})
+	out := NewPacketCollector(net, "out")
+	upper.InPort("in").From(src.Out())
+	tagger.InPort("files").From(upper.OutPort("out"))
+	out.In().From(tagger.OutPort("tagged"))
+
+	net.Run()
+
+	assertEqualValues(t, []any{"A.TXT", "B.TXT"}, out.Data)
+	assertEqualValues(t, 2, tagger.Struct().(*tagStruct).count)
+}
+
+type earlyReturnStruct struct {
+	In  chan *Packet `flowbase:"in"`
+	Out chan *Packet `flowbase:"out"`
+}
+
+func (s *earlyReturnStruct) Run() {
+	s.Out <- <-s.In
+}
+
+func TestRegisterStructEarlyReturn(t *testing.T) {
+	initTestLogs()
+	net := NewNetwork("TestRegisterStructEarlyReturn")
+
+	src := NewFileSource(net, "src", "a.txt", "b.txt", "c.txt")
+	first := RegisterStruct(net, "first", &earlyReturnStruct{})
+	out := NewPacketCollector(net, "out")
+	first.InPort("in").From(src.Out())
+	out.In().From(first.OutPort("out"))
+
+	net.Run()
+
+	assertEqualValues(t, []any{"a.txt"}, out.Data)
+}
+
+type badTagStruct struct {
+	In chan string `flowbase:"input"`
+}
+
+func (s *badTagStruct) Run() {}
+
+func TestRegisterStructBadTag(t *testing.T) {
+	ensureFailsProgram("TestRegisterStructBadTag", func() {
+		RegisterStruct(NewNetwork("net"), "bad", &badTagStruct{})
+	}, t)
+}
@@ -0,0 +1,239 @@
+// Package template implements the placeholder syntax used in command patterns
+// of FlowBase processes, such as "cat {i:infile|basename} > {o:outfile}".
+//
+// A placeholder has the form {type:name}, optionally followed by |-separated
+// modifiers, which transform the value(s) the placeholder resolves to, in
+// order. Modifiers are either given as a name, or as name:argument. The
+// built-in modifiers are:
+//
+//	basename      Remove all leading folders from paths
+//	dirname       Keep only the folder part of paths
+//	strip-ext     Remove the last file extension from paths
+//	join:SEP      Join multiple values with SEP (default: a space)
+//	%SUFFIX       Trim SUFFIX from the end of paths, e.g. %.txt
+//	s/OLD/NEW/    Replace the first occurrence of OLD with NEW
+//	quote         Quote values for the shell, as single arguments
+//
+// Custom modifiers can be added with RegisterModifier.
+package template
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	placeholderPtn = regexp.MustCompile(`{([a-z]+):([^{}|]+)((?:\|[^{}|]+)*)}`)
+	basenamePtn    = regexp.MustCompile(`.*/`)
+	dirnamePtn     = regexp.MustCompile(`/[^/]*$`)
+)
+
+// Placeholder is a parsed placeholder of a template
+type Placeholder struct {
+	// Raw is the placeholder as written in the template, including braces
+	Raw       string
+	Type      string
+	Name      string
+	Modifiers []Modifier
+}
+
+// Modifier is a parsed modifier of a placeholder
+type Modifier struct {
+	Name string
+	Arg  string
+}
+
+// Template is a parsed text with placeholders
+type Template struct {
+	src   string
+	parts []part
+}
+
+// part is either a literal text, or a placeholder
+type part struct {
+	text string
+	ph   *Placeholder
+}
+
+// Parse parses the text src into a Template
+func Parse(src string) (*Template, error) {
+	t := &Template{src: src}
+	last := 0
+	for _, loc := range placeholderPtn.FindAllStringSubmatchIndex(src, -1) {
+		if loc[0] > last {
+			t.parts = append(t.parts, part{text: src[last:loc[0]]})
+		}
+		ph := &Placeholder{
+			Raw:  src[loc[0]:loc[1]],
+			Type: src[loc[2]:loc[3]],
+			Name: strings.TrimSpace(src[loc[4]:loc[5]]),
+		}
+		if loc[7] > loc[6] {
+			for _, modStr := range strings.Split(src[loc[6]+1:loc[7]], "|") {
+				mod := parseModifier(modStr)
+				if _, ok := lookupModifier(mod.Name); !ok {
+					return nil, fmt.Errorf("unknown modifier (%s) in placeholder %s", mod.Name, ph.Raw)
+				}
+				ph.Modifiers = append(ph.Modifiers, mod)
+			}
+		}
+		t.parts = append(t.parts, part{ph: ph})
+		last = loc[1]
+	}
+	if last < len(src) {
+		t.parts = append(t.parts, part{text: src[last:]})
+	}
+	return t, nil
+}
+
+// MustParse is like Parse but panics if src can not be parsed
+func MustParse(src string) *Template {
+	t, err := Parse(src)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func parseModifier(s string) Modifier {
+	switch {
+	case strings.HasPrefix(s, "%"):
+		return Modifier{Name: "%", Arg: s[1:]}
+	case strings.HasPrefix(s, "s/"):
+		return Modifier{Name: "s", Arg: s[1:]}
+	}
+	if i := strings.Index(s, ":"); i >= 0 {
+		return Modifier{Name: s[:i], Arg: s[i+1:]}
+	}
+	return Modifier{Name: s}
+}
+
+// String returns the source text of the template
+func (t *Template) String() string {
+	return t.src
+}
+
+// Placeholders returns all placeholders of the template, in order of
+// appearance
+func (t *Template) Placeholders() []*Placeholder {
+	phs := []*Placeholder{}
+	for _, p := range t.parts {
+		if p.ph != nil {
+			phs = append(phs, p.ph)
+		}
+	}
+	return phs
+}
+
+// Execute returns the text of the template, with placeholders replaced by the
+// values returned by resolve, after applying their modifiers. If a
+// placeholder resolves to multiple values, they are joined by spaces, unless
+// a join modifier is used.
+func (t *Template) Execute(resolve func(ph *Placeholder) ([]string, error)) (string, error) {
+	sb := &strings.Builder{}
+	for _, p := range t.parts {
+		if p.ph == nil {
+			sb.WriteString(p.text)
+			continue
+		}
+		vals, err := resolve(p.ph)
+		if err != nil {
+			return "", err
+		}
+		for _, mod := range p.ph.Modifiers {
+			fn, _ := lookupModifier(mod.Name)
+			if vals, err = fn(vals, mod.Arg); err != nil {
+				return "", fmt.Errorf("modifier (%s) of placeholder %s failed: %v", mod.Name, p.ph.Raw, err)
+			}
+		}
+		sb.WriteString(strings.Join(vals, " "))
+	}
+	return sb.String(), nil
+}
+
+// ----------------------------------------------------------------------------
+// Modifiers
+// ----------------------------------------------------------------------------
+
+// ModifierFunc transforms the values of a placeholder. arg is the part after
+// the colon in modifiers of the form name:arg.
+type ModifierFunc func(vals []string, arg string) ([]string, error)
+
+var (
+	modifiers   = map[string]ModifierFunc{}
+	modifiersMx sync.RWMutex
+)
+
+// RegisterModifier registers the modifier fn with the name name, making it
+// usable in placeholders parsed after the call
+func RegisterModifier(name string, fn ModifierFunc) {
+	modifiersMx.Lock()
+	modifiers[name] = fn
+	modifiersMx.Unlock()
+}
+
+func lookupModifier(name string) (ModifierFunc, bool) {
+	modifiersMx.RLock()
+	defer modifiersMx.RUnlock()
+	fn, ok := modifiers[name]
+	return fn, ok
+}
+
+// eachValue returns a ModifierFunc applying fn to every value
+func eachValue(fn func(val string, arg string) (string, error)) ModifierFunc {
+	return func(vals []string, arg string) ([]string, error) {
+		res := make([]string, len(vals))
+		for i, v := range vals {
+			var err error
+			if res[i], err = fn(v, arg); err != nil {
+				return nil, err
+			}
+		}
+		return res, nil
+	}
+}
+
+func init() {
+	// basename and dirname only cut the path at its last slash, unlike
+	// filepath.Base and filepath.Dir, so that paths without a folder are
+	// kept as they are by dirname, rather than becoming "."
+	RegisterModifier("basename", eachValue(func(v, _ string) (string, error) {
+		return basenamePtn.ReplaceAllString(v, ""), nil
+	}))
+	RegisterModifier("dirname", eachValue(func(v, _ string) (string, error) {
+		return dirnamePtn.ReplaceAllString(v, ""), nil
+	}))
+	RegisterModifier("strip-ext", eachValue(func(v, _ string) (string, error) {
+		return strings.TrimSuffix(v, filepath.Ext(v)), nil
+	}))
+	RegisterModifier("join", func(vals []string, sep string) ([]string, error) {
+		if sep == "" {
+			sep = " "
+		}
+		return []string{strings.Join(vals, sep)}, nil
+	})
+	RegisterModifier("%", eachValue(func(v, suffix string) (string, error) {
+		if !strings.HasSuffix(v, suffix) {
+			return "", fmt.Errorf("piece (%s) not found at the end of (%s)", suffix, v)
+		}
+		return strings.TrimSuffix(v, suffix), nil
+	}))
+	RegisterModifier("s", eachValue(func(v, arg string) (string, error) {
+		bits := strings.Split(arg, "/")
+		if len(bits) != 4 || bits[1] == "" {
+			return "", fmt.Errorf("malformed search/replace pattern: s%s", arg)
+		}
+		return strings.Replace(v, bits[1], bits[2], 1), nil
+	}))
+	RegisterModifier("quote", eachValue(func(v, _ string) (string, error) {
+		return ShellQuote(v), nil
+	}))
+}
+
+// ShellQuote quotes s for safe use as a single word in bash
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,63 @@
+package template
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExecute(t *testing.T) {
+	RegisterModifier("upper", eachValue(func(v, _ string) (string, error) {
+		return strings.ToUpper(v), nil
+	}))
+
+	tpl := MustParse("cat {i:in|basename|%.txt|s/a/b/} {is:many|join:,} > {o:out|strip-ext|upper} {p:msg|quote}")
+	have, err := tpl.Execute(func(ph *Placeholder) ([]string, error) {
+		switch ph.Name {
+		case "in":
+			return []string{"/data/abc.txt"}, nil
+		case "many":
+			return []string{"x", "y"}, nil
+		case "msg":
+			return []string{"it's"}, nil
+		}
+		return []string{"res.csv"}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `cat bbc x,y > RES 'it'\''s'`
+	if have != want {
+		t.Errorf("Got wrong output from template: %s, wanted: %s\n", have, want)
+	}
+}
+
+func TestParseUnknownModifier(t *testing.T) {
+	if _, err := Parse("echo {i:in|nosuchmod}"); err == nil {
+		t.Error("Expected error for unknown modifier")
+	}
+}
+
+func TestPathModifiers(t *testing.T) {
+	for _, tc := range []struct {
+		path     string
+		basename string
+		dirname  string
+	}{
+		{"file.txt", "file.txt", "file.txt"},
+		{"data/file.txt", "file.txt", "data"},
+		{"/data/sub/file.txt", "file.txt", "/data/sub"},
+		{"/file.txt", "file.txt", ""},
+	} {
+		for _, mod := range []struct{ name, want string }{{"basename", tc.basename}, {"dirname", tc.dirname}} {
+			have, err := MustParse("{i:in|" + mod.name + "}").Execute(func(ph *Placeholder) ([]string, error) {
+				return []string{tc.path}, nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if have != mod.want {
+				t.Errorf("Got wrong %s of %s: %s, wanted: %s\n", mod.name, tc.path, have, mod.want)
+			}
+		}
+	}
+}
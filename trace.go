@@ -0,0 +1,191 @@
+package flowbase
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TraceRecorder records a timeline of the processes and tasks of a network
+// run, from its events, and exports it in the Chrome trace event format,
+// which can be viewed in chrome://tracing or https://ui.perfetto.dev, to see
+// how much processes run concurrently, how stages overlap, and where the
+// network is idle. Processes are shown as one track each, for the time from
+// their start to their finish, and tasks on one track per process and slot,
+// so that concurrent tasks of a process are shown side by side.
+type TraceRecorder struct {
+	mx          sync.Mutex
+	start       time.Time
+	events      []*traceEvent
+	procStarts  map[string]time.Time
+	taskStarts  map[string]time.Time
+	taskSlots   map[string]int
+	busySlots   map[string][]bool
+	tids        map[traceTrack]int
+	unsubscribe func()
+}
+
+// traceTrack identifies a track of the timeline, by its trace process ID
+// and name
+type traceTrack struct {
+	pid  int
+	name string
+}
+
+// traceEvent is an event in the Chrome trace event format
+type traceEvent struct {
+	Name string `json:"name"`
+	Cat  string `json:"cat,omitempty"`
+	Ph   string `json:"ph"`
+	// Ts and Dur are in microseconds
+	Ts   float64        `json:"ts"`
+	Dur  float64        `json:"dur,omitempty"`
+	Pid  int            `json:"pid"`
+	Tid  int            `json:"tid"`
+	Args map[string]any `json:"args,omitempty"`
+}
+
+const (
+	tracePidProcesses = 1
+	tracePidTasks     = 2
+)
+
+// NewTraceRecorder returns a new TraceRecorder, recording the events of net
+// until Stop is called
+func NewTraceRecorder(net *Network) *TraceRecorder {
+	r := &TraceRecorder{
+		start:      time.Now(),
+		procStarts: map[string]time.Time{},
+		taskStarts: map[string]time.Time{},
+		taskSlots:  map[string]int{},
+		busySlots:  map[string][]bool{},
+		tids:       map[traceTrack]int{},
+	}
+	r.unsubscribe = net.Subscribe(EventTypes(EventProcessStarted, EventProcessFinished, EventTaskStarted, EventTaskFinished), r.record)
+	return r
+}
+
+// Stop stops recording events
+func (r *TraceRecorder) Stop() {
+	r.unsubscribe()
+}
+
+// record adds the event e to the timeline
+func (r *TraceRecorder) record(e *Event) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	switch e.Type {
+	case EventProcessStarted:
+		r.procStarts[e.Process] = e.Time
+	case EventProcessFinished:
+		start := r.procStarts[e.Process]
+		r.addSpan(tracePidProcesses, e.Process, e.Process, "process", start, e.Time, nil)
+	case EventTaskStarted:
+		slots := r.busySlots[e.Process]
+		slot := 0
+		for slot < len(slots) && slots[slot] {
+			slot++
+		}
+		if slot == len(slots) {
+			slots = append(slots, false)
+		}
+		slots[slot] = true
+		r.busySlots[e.Process] = slots
+		r.taskSlots[e.Task.ID] = slot
+		r.taskStarts[e.Task.ID] = e.Time
+	case EventTaskFinished:
+		slot, ok := r.taskSlots[e.Task.ID]
+		if !ok {
+			// The task started before recording did
+			return
+		}
+		r.busySlots[e.Process][slot] = false
+		track := e.Process
+		if slot > 0 {
+			track = e.Process + " #" + strconv.Itoa(slot+1)
+		}
+		args := map[string]any{"command": e.Task.RedactedCommand()}
+		if e.Message != "" {
+			args["error"] = e.Message
+		}
+		r.addSpan(tracePidTasks, track, e.Process, "task", r.taskStarts[e.Task.ID], e.Time, args)
+		delete(r.taskSlots, e.Task.ID)
+		delete(r.taskStarts, e.Task.ID)
+	}
+}
+
+// addSpan adds a span named name, from start to finish, on the track named
+// trackName of the trace process pid
+func (r *TraceRecorder) addSpan(pid int, trackName string, name string, cat string, start time.Time, finish time.Time, args map[string]any) {
+	track := traceTrack{pid: pid, name: trackName}
+	tid, ok := r.tids[track]
+	if !ok {
+		tid = len(r.tids) + 1
+		r.tids[track] = tid
+	}
+	r.events = append(r.events, &traceEvent{
+		Name: name,
+		Cat:  cat,
+		Ph:   "X",
+		Ts:   float64(start.Sub(r.start).Nanoseconds()) / 1e3,
+		Dur:  float64(finish.Sub(start).Nanoseconds()) / 1e3,
+		Pid:  pid,
+		Tid:  tid,
+		Args: args,
+	})
+}
+
+// WriteChromeTrace writes the recorded timeline to w, as JSON in the Chrome
+// trace event format
+func (r *TraceRecorder) WriteChromeTrace(w io.Writer) error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	events := []*traceEvent{
+		{Name: "process_name", Ph: "M", Pid: tracePidProcesses, Args: map[string]any{"name": "Processes"}},
+		{Name: "process_name", Ph: "M", Pid: tracePidTasks, Args: map[string]any{"name": "Tasks"}},
+	}
+	tracks := make([]traceTrack, 0, len(r.tids))
+	for track := range r.tids {
+		tracks = append(tracks, track)
+	}
+	sort.Slice(tracks, func(i, j int) bool {
+		if tracks[i].pid != tracks[j].pid {
+			return tracks[i].pid < tracks[j].pid
+		}
+		return tracks[i].name < tracks[j].name
+	})
+	for i, track := range tracks {
+		tid := r.tids[track]
+		events = append(events,
+			&traceEvent{Name: "thread_name", Ph: "M", Pid: track.pid, Tid: tid, Args: map[string]any{"name": track.name}},
+			&traceEvent{Name: "thread_sort_index", Ph: "M", Pid: track.pid, Tid: tid, Args: map[string]any{"sort_index": i}},
+		)
+	}
+	events = append(events, r.events...)
+
+	enc := json.NewEncoder(w)
+	return enc.Encode(map[string]any{
+		"traceEvents":     events,
+		"displayTimeUnit": "ms",
+	})
+}
+
+// WriteChromeTraceFile writes the recorded timeline to the file at path (see
+// WriteChromeTrace)
+func (r *TraceRecorder) WriteChromeTraceFile(path string) error {
+	createDirs(path)
+	file, err := os.Create(path)
+	if err != nil {
+		return errWrapf(err, "could not create trace file %s", path)
+	}
+	if err := r.WriteChromeTrace(file); err != nil {
+		file.Close()
+		return errWrapf(err, "could not write trace file %s", path)
+	}
+	return file.Close()
+}
@@ -0,0 +1,60 @@
+package flowbase
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"testing"
+)
+
+func TestTraceRecorder(t *testing.T) {
+	initTestLogs()
+	net := NewNetwork("TestTraceRecorder")
+
+	src := NewFileSource(net, "src", "abc.txt", "cde.txt")
+	echo := NewExecProc(net, "echo", "echo {i:in}")
+	echo.In("in").From(src.Out())
+	out := NewPacketCollector(net, "out")
+	out.In().From(echo.Stdout())
+
+	rec := NewTraceRecorder(net)
+	net.Run()
+	rec.Stop()
+
+	buf := &bytes.Buffer{}
+	if err := rec.WriteChromeTrace(buf); err != nil {
+		t.Fatalf("Could not write trace: %v", err)
+	}
+	trace := struct {
+		TraceEvents []traceEvent `json:"traceEvents"`
+	}{}
+	if err := json.Unmarshal(buf.Bytes(), &trace); err != nil {
+		t.Fatalf("Could not parse trace: %v", err)
+	}
+
+	tasks := []string{}
+	procs := map[string]bool{}
+	trackNames := map[int]string{}
+	for _, e := range trace.TraceEvents {
+		switch {
+		case e.Ph == "X" && e.Cat == "task":
+			tasks = append(tasks, e.Args["command"].(string))
+			if e.Dur <= 0 {
+				t.Errorf("Expected task %v to have a duration", e.Args["command"])
+			}
+		case e.Ph == "X" && e.Cat == "process":
+			procs[e.Name] = true
+		case e.Ph == "M" && e.Name == "thread_name" && e.Pid == tracePidTasks:
+			trackNames[e.Tid] = e.Args["name"].(string)
+		}
+	}
+	// Tasks can finish in any order
+	sort.Strings(tasks)
+	assertEqualValues(t, []string{"echo 'abc.txt'", "echo 'cde.txt'"}, tasks)
+	assertEqualValues(t, true, procs["src"] && procs["echo"] && procs["out"])
+	for _, name := range trackNames {
+		if name != "echo" && name != "echo #2" {
+			t.Errorf("Unexpected task track: %s", name)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package flowbase
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TxSink is a sink whose side effects can be staged in transactions, so that
+// a group of sinks can make their side effects all take effect, or none of
+// them (see CommitAll, and components.TxGroup)
+type TxSink interface {
+	// Begin starts a new transaction, for the substream with the key key
+	Begin(key string) (Tx, error)
+}
+
+// Tx is a transaction of a TxSink, staging the side effects of the packets
+// of a substream, such as in temporary files or a database transaction
+type Tx interface {
+	// Stage stages the side effects of the packet ip
+	Stage(ip *Packet) error
+	// Prepare makes sure the staged side effects can be committed, such as
+	// by flushing them, as the first phase of a two-phase commit
+	Prepare() error
+	// Commit makes the staged side effects take effect
+	Commit() error
+	// Rollback discards the staged side effects
+	Rollback() error
+}
+
+// CommitAll commits the transactions txs with a two-phase commit: first all
+// of them are prepared, and only if all succeed, all are committed, and
+// otherwise all are rolled back. Errors from committing are returned, but
+// can not be undone, so Prepare should leave as little as possible to fail
+// in Commit.
+func CommitAll(txs ...Tx) error {
+	for i, tx := range txs {
+		if err := tx.Prepare(); err != nil {
+			return rollbackAll(txs, errWrapf(err, "could not prepare transaction %d", i))
+		}
+	}
+	errs := []string{}
+	for i, tx := range txs {
+		if err := tx.Commit(); err != nil {
+			errs = append(errs, fmt.Sprintf("could not commit transaction %d: %v", i, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// RollbackAll rolls back all the transactions txs, returning the errors from
+// doing so, if any
+func RollbackAll(txs ...Tx) error {
+	return rollbackAll(txs, nil)
+}
+
+// rollbackAll rolls back the transactions txs, because of the error cause,
+// and returns cause together with any errors from rolling back
+func rollbackAll(txs []Tx, cause error) error {
+	errs := []string{}
+	if cause != nil {
+		errs = append(errs, cause.Error())
+	}
+	for i, tx := range txs {
+		if err := tx.Rollback(); err != nil {
+			errs = append(errs, fmt.Sprintf("could not roll back transaction %d: %v", i, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "\n"))
+	}
+	return nil
+}
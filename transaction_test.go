@@ -0,0 +1,45 @@
+package flowbase
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCommitAll(t *testing.T) {
+	log := []string{}
+	ok := &logTx{name: "ok", log: &log}
+	if err := CommitAll(ok, &logTx{name: "ok2", log: &log}); err != nil {
+		t.Fatal(err)
+	}
+	assertEqualValues(t, []string{"prepare ok", "prepare ok2", "commit ok", "commit ok2"}, log)
+
+	log = []string{}
+	failing := &logTx{name: "failing", log: &log, prepareErr: errors.New("disk full")}
+	if err := CommitAll(ok, failing); err == nil {
+		t.Fatalf("No error returned when prepare failed")
+	}
+	assertEqualValues(t, []string{"prepare ok", "prepare failing", "rollback ok", "rollback failing"}, log)
+}
+
+type logTx struct {
+	name       string
+	log        *[]string
+	prepareErr error
+}
+
+func (tx *logTx) Stage(ip *Packet) error { return nil }
+
+func (tx *logTx) Prepare() error {
+	*tx.log = append(*tx.log, "prepare "+tx.name)
+	return tx.prepareErr
+}
+
+func (tx *logTx) Commit() error {
+	*tx.log = append(*tx.log, "commit "+tx.name)
+	return nil
+}
+
+func (tx *logTx) Rollback() error {
+	*tx.log = append(*tx.log, "rollback "+tx.name)
+	return nil
+}
@@ -0,0 +1,209 @@
+package flowbase
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// TransferMethod is the tool used to transfer files to and from remote hosts.
+// Files in buckets, with locations on the form s3://bucket/key and
+// gs://bucket/key, are always transferred with the aws and gsutil command
+// line tools.
+type TransferMethod string
+
+// The methods for transferring files to and from remote hosts, with
+// locations on the form [user@]host:path
+const (
+	TransferRsync TransferMethod = "rsync"
+	TransferSCP   TransferMethod = "scp"
+)
+
+// transferCommand returns the command copying the file src to dst, where
+// one of them is a remote location
+func transferCommand(method TransferMethod, extraArgs []string, src string, dst string) *exec.Cmd {
+	var args []string
+	switch {
+	case isBucketURI(src, "s3://") || isBucketURI(dst, "s3://"):
+		args = []string{"aws", "s3", "cp"}
+	case isBucketURI(src, "gs://") || isBucketURI(dst, "gs://"):
+		args = []string{"gsutil", "cp"}
+	case method == TransferSCP:
+		args = []string{"scp", "-o", "BatchMode=yes"}
+	default:
+		args = []string{"rsync", "-a"}
+	}
+	args = append(append(args, extraArgs...), src, dst)
+	return exec.Command(args[0], args[1:]...)
+}
+
+func isBucketURI(location string, scheme string) bool {
+	return strings.HasPrefix(location, scheme)
+}
+
+// transfer copies the file src to dst, and returns the audit info of the
+// transfer, with the checksum of the local file localPath, by process
+func transfer(process string, method TransferMethod, extraArgs []string, src string, dst string, localPath string) (*AuditInfo, error) {
+	cmd := transferCommand(method, extraArgs, src, dst)
+	ai := NewAuditInfo()
+	ai.ProcessName = process
+	ai.Command = strings.Join(cmd.Args, " ")
+	ai.StartTime = time.Now()
+	out, err := cmd.CombinedOutput()
+	ai.FinishTime = time.Now()
+	ai.ExecTimeNS = ai.FinishTime.Sub(ai.StartTime)
+	if err != nil {
+		return nil, errWrapf(err, "Could not transfer %s to %s: %s", src, dst, out)
+	}
+	sum, err := fileChecksum(localPath)
+	if err != nil {
+		return nil, err
+	}
+	ai.Checksums = map[string]string{localPath: sum}
+	ai.OutFiles["out"] = dst
+	return ai, nil
+}
+
+// ------------------------------------------------------------------------
+// StageIn
+// ------------------------------------------------------------------------
+
+// StageIn downloads the remote files whose locations it receives, as
+// strings, to a local directory, before compute steps, and sends them on as
+// *FileIPs. Locations are on the form [user@]host:path, s3://bucket/key or
+// gs://bucket/key. The audit info of each download, including its duration
+// and the checksum of the downloaded file, is written to the audit file of
+// the file, and set on the sent packet.
+type StageIn struct {
+	BaseProcess
+	dir string
+	// Method is the tool used for remote hosts. Defaults to rsync.
+	Method TransferMethod
+	// ExtraArgs are added to the transfer commands
+	ExtraArgs []string
+}
+
+// NewStageIn returns a new StageIn process, downloading files to dir
+func NewStageIn(net *Network, name string, dir string) *StageIn {
+	p := &StageIn{
+		BaseProcess: NewBaseProcess(net, name),
+		dir:         dir,
+		Method:      TransferRsync,
+	}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port, on which remote locations are received
+func (p *StageIn) In() *InPort { return p.InPort("in") }
+
+// Out returns the out-port, on which the downloaded files are sent
+func (p *StageIn) Out() *OutPort { return p.OutPort("out") }
+
+// Run runs the StageIn process
+func (p *StageIn) Run() {
+	defer p.CloseOutPorts()
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		remote := fmt.Sprint(ip.Data())
+		// The file name is what follows the last slash, or the host
+		name := remote[strings.LastIndexAny(remote, "/:")+1:]
+		if name == "" {
+			ip.Failf("Remote location has no file name: %s", remote)
+		}
+		localPath := filepath.Join(p.dir, name)
+		if err := os.MkdirAll(p.dir, 0777); err != nil {
+			p.Failf("Could not create directory %s: %v", p.dir, err)
+		}
+		ai, err := transfer(p.Name(), p.Method, p.ExtraArgs, remote, localPath, localPath)
+		if err != nil {
+			ip.Fail(err)
+		}
+		ai.Tags = ip.Tags()
+		if upstream := ip.AuditInfo(); upstream != nil {
+			ai.Upstream["in"] = upstream
+		}
+		if err := ai.WriteAuditFile(localPath); err != nil {
+			Warning.Printf("[Process:%s] %v\n", p.Name(), err)
+		}
+		if fi, err := os.Stat(localPath); err == nil {
+			p.ReportUsage(Usage{BytesTransferred: fi.Size()})
+		}
+		p.Auditf("Downloaded %s to %s in %s", remote, localPath, ai.ExecTimeNS)
+		out := NewPacket(NewFileIP(localPath))
+		out.AddTags(ip.Tags())
+		out.SetAuditInfo(ai)
+		p.Out().SendPacket(out)
+	}
+}
+
+// ------------------------------------------------------------------------
+// StageOut
+// ------------------------------------------------------------------------
+
+// StageOut uploads the files it receives, as *FileIPs or paths, to a remote
+// location, after compute steps, and sends on their remote locations as
+// strings. The remote location is a directory, on the form
+// [user@]host:dir, or a bucket prefix, on the form s3://bucket/prefix or
+// gs://bucket/prefix. The audit info of each upload, including its duration
+// and the checksum of the uploaded file, is set on the sent packet.
+type StageOut struct {
+	BaseProcess
+	remote string
+	// Method is the tool used for remote hosts. Defaults to rsync.
+	Method TransferMethod
+	// ExtraArgs are added to the transfer commands
+	ExtraArgs []string
+}
+
+// NewStageOut returns a new StageOut process, uploading files to remote
+func NewStageOut(net *Network, name string, remote string) *StageOut {
+	p := &StageOut{
+		BaseProcess: NewBaseProcess(net, name),
+		remote:      strings.TrimSuffix(remote, "/"),
+		Method:      TransferRsync,
+	}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "out")
+	net.AddProc(p)
+	return p
+}
+
+// In returns the in-port, on which the files to upload are received
+func (p *StageOut) In() *InPort { return p.InPort("in") }
+
+// Out returns the out-port, on which the remote locations of the uploaded
+// files are sent
+func (p *StageOut) Out() *OutPort { return p.OutPort("out") }
+
+// Run runs the StageOut process
+func (p *StageOut) Run() {
+	defer p.CloseOutPorts()
+	for ip, ok := p.In().RecvOK(); ok; ip, ok = p.In().RecvOK() {
+		localPath := fmt.Sprint(ip.Data())
+		if f, ok := ip.Data().(*FileIP); ok {
+			localPath = f.Path()
+		}
+		remote := p.remote + "/" + filepath.Base(localPath)
+		ai, err := transfer(p.Name(), p.Method, p.ExtraArgs, localPath, remote, localPath)
+		if err != nil {
+			ip.Fail(err)
+		}
+		ai.Tags = ip.Tags()
+		if upstream := ip.AuditInfo(); upstream != nil {
+			ai.Upstream["in"] = upstream
+		}
+		if fi, err := os.Stat(localPath); err == nil {
+			p.ReportUsage(Usage{BytesTransferred: fi.Size()})
+		}
+		p.Auditf("Uploaded %s to %s in %s", localPath, remote, ai.ExecTimeNS)
+		out := NewPacket(remote)
+		out.AddTags(ip.Tags())
+		out.SetAuditInfo(ai)
+		p.Out().SendPacket(out)
+	}
+}
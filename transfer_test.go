@@ -0,0 +1,49 @@
+package flowbase
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStageInStageOut(t *testing.T) {
+	initTestLogs()
+	// A stand-in for rsync, which copies files locally, ignoring options and
+	// the host part of remote locations
+	binDir := t.TempDir()
+	Check(os.WriteFile(filepath.Join(binDir, "rsync"), []byte(`#!/bin/bash
+src="${@: -2:1}"; dst="${@: -1}"
+cp "${src#*:}" "${dst#*:}"
+`), 0755))
+	t.Setenv("PATH", binDir+":"+os.Getenv("PATH"))
+
+	remoteDir, localDir, outDir := t.TempDir(), t.TempDir(), t.TempDir()
+	Check(os.WriteFile(filepath.Join(remoteDir, "in.txt"), []byte("data\n"), 0644))
+
+	net := NewNetwork("TestStageInStageOut")
+	src := NewFileSource(net, "src", "node1:"+remoteDir+"/in.txt")
+	stageIn := NewStageIn(net, "stage_in", localDir)
+	stageIn.In().From(src.Out())
+	stageOut := NewStageOut(net, "stage_out", "node2:"+outDir+"/")
+	stageOut.In().From(stageIn.Out())
+	out := NewPacketCollector(net, "out")
+	out.In().From(stageOut.Out())
+	net.Run()
+
+	assertEqualValues(t, []any{"node2:" + outDir + "/in.txt"}, out.Data)
+	if content, err := os.ReadFile(filepath.Join(outDir, "in.txt")); err != nil || string(content) != "data\n" {
+		t.Errorf("File not uploaded: %q (%v)", content, err)
+	}
+	localPath := filepath.Join(localDir, "in.txt")
+	ai, err := ReadAuditFile(localPath)
+	if err != nil {
+		t.Fatalf("Could not read audit file of downloaded file: %v", err)
+	}
+	// The SHA-256 of "data\n"
+	if sum := ai.Checksums[localPath]; sum != "6667b2d1aab6a00caa5aee5af8ad9f1465e567abf1c209d15727d57b3e8f6e5f" {
+		t.Errorf("Wrong checksum in audit info: %s", sum)
+	}
+	if ai.ExecTimeNS <= 0 || ai.ProcessName != "stage_in" {
+		t.Errorf("Transfer not audited: %+v", ai)
+	}
+}
@@ -0,0 +1,35 @@
+package flowbase
+
+import (
+	"os/exec"
+	"time"
+)
+
+// ResourceUsage is the use of resources by the command of a task, as
+// measured by the operating system, for right-sizing the resources
+// requested for tasks (see ExecProc.Resources)
+type ResourceUsage struct {
+	// MaxRSSKB is the peak resident memory, in kB
+	MaxRSSKB   int64
+	UserTime   time.Duration
+	SystemTime time.Duration
+	// ReadBytes and WrittenBytes are the bytes read from and written to
+	// block devices, which leaves out reads served by the page cache
+	ReadBytes    int64
+	WrittenBytes int64
+}
+
+// CPUTime returns the total CPU time, in user and system mode
+func (u *ResourceUsage) CPUTime() time.Duration {
+	return u.UserTime + u.SystemTime
+}
+
+// recordUsage sets the resource usage of the finished command cmd, and the
+// processes it waited for, on the audit info of the task t, if the
+// operating system reports it
+func recordUsage(t *ExecTask, cmd *exec.Cmd) {
+	if t.AuditInfo == nil || cmd.ProcessState == nil {
+		return
+	}
+	t.AuditInfo.ResourceUsage = processUsage(cmd.ProcessState)
+}
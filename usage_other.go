@@ -0,0 +1,11 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+
+package flowbase
+
+import "os"
+
+// processUsage returns nil, as resource usage is not supported on this
+// operating system
+func processUsage(state *os.ProcessState) *ResourceUsage {
+	return nil
+}
@@ -0,0 +1,35 @@
+package flowbase
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestLocalExecutorRecordsUsage(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("resource usage is not recorded on windows")
+	}
+	initTestLogs()
+	net := NewNetwork("TestLocalExecutorRecordsUsage")
+	var usage *ResourceUsage
+	net.Subscribe(EventTypes(EventTaskFinished), func(e *Event) {
+		usage = e.Task.ResourceUsage
+	})
+
+	src := NewFileSource(net, "src", "a.txt")
+	count := NewExecProc(net, "count", "seq 100000 | wc -l # {i:in}")
+	count.In("in").From(src.Out())
+	out := NewPacketCollector(net, "out")
+	out.In().From(count.Stdout())
+	net.Run()
+
+	if usage == nil {
+		t.Fatal("No resource usage recorded")
+	}
+	if usage.MaxRSSKB <= 0 {
+		t.Errorf("Expected a max RSS, got %d kB", usage.MaxRSSKB)
+	}
+	if usage.CPUTime() < 0 {
+		t.Errorf("Expected a non-negative CPU time, got %s", usage.CPUTime())
+	}
+}
@@ -0,0 +1,34 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package flowbase
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// rusageBlockSize is the size of the blocks counted in the block I/O fields
+// of rusage
+const rusageBlockSize = 512
+
+// processUsage returns the resource usage of the exited process state
+func processUsage(state *os.ProcessState) *ResourceUsage {
+	rusage, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok || rusage == nil {
+		return nil
+	}
+	maxRSSKB := int64(rusage.Maxrss)
+	if runtime.GOOS == "darwin" {
+		// Reported in bytes, rather than kB
+		maxRSSKB /= 1024
+	}
+	return &ResourceUsage{
+		MaxRSSKB:     maxRSSKB,
+		UserTime:     time.Duration(rusage.Utime.Nano()),
+		SystemTime:   time.Duration(rusage.Stime.Nano()),
+		ReadBytes:    int64(rusage.Inblock) * rusageBlockSize,
+		WrittenBytes: int64(rusage.Oublock) * rusageBlockSize,
+	}
+}
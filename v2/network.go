@@ -0,0 +1,228 @@
+//go:build flowbase_v2
+
+// Package flowbase is the experimental v2 API of FlowBase, with ports typed
+// by the data they carry, processes returning errors instead of failing the
+// program, and cancellation with contexts:
+//
+//	type Doubler struct {
+//		fb.BaseProcess
+//		In  *fb.InPort[int]
+//		Out *fb.OutPort[int]
+//	}
+//
+//	func (p *Doubler) Run(ctx context.Context) error {
+//		for {
+//			n, err := p.In.Recv(ctx)
+//			if err == io.EOF {
+//				return nil
+//			} else if err != nil {
+//				return err
+//			}
+//			if err := p.Out.Send(ctx, 2*n); err != nil {
+//				return err
+//			}
+//		}
+//	}
+//
+// Connecting ports of different types does not compile, and the first error
+// returned by a process cancels the others, and is returned by Network.Run.
+//
+// While the API is experimental, it is only built with the flowbase_v2 build
+// tag, and the v1 API in the parent package is unchanged. Code using the v1
+// API is migrated with the flowbase migrate command, which rewrites what can
+// be rewritten automatically, and writes a guide to the remaining changes.
+package flowbase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Process is a process of a network, run with Network.Run
+type Process interface {
+	// Name returns the name of the process, which is unique in its network
+	Name() string
+	// Run runs the process until it is done, or ctx is canceled, and returns
+	// why it stopped early, if it did. The out-ports of the process are
+	// closed when Run returns.
+	Run(ctx context.Context) error
+}
+
+// Network is a network of processes connected by their ports
+type Network struct {
+	name  string
+	procs map[string]Process
+	// errs are the errors of setting up the network, returned by Run
+	errs []error
+}
+
+// NewNetwork returns a new, empty network with name name
+func NewNetwork(name string) *Network {
+	return &Network{name: name, procs: map[string]Process{}}
+}
+
+// Name returns the name of the network
+func (net *Network) Name() string {
+	return net.name
+}
+
+// AddProc adds the process p to the network. Adding a process with the name
+// of another process makes Run return an error.
+func (net *Network) AddProc(p Process) {
+	if _, ok := net.procs[p.Name()]; ok {
+		net.errs = append(net.errs, fmt.Errorf("a process with name (%s) already exists in the network", p.Name()))
+		return
+	}
+	net.procs[p.Name()] = p
+}
+
+// AddProcs adds the processes procs to the network, like AddProc
+func (net *Network) AddProcs(procs ...Process) {
+	for _, p := range procs {
+		net.AddProc(p)
+	}
+}
+
+// Proc returns the process with name name, or nil if there is none
+func (net *Network) Proc(name string) Process {
+	return net.procs[name]
+}
+
+// Run runs all processes of the network concurrently, until they are done,
+// and returns the first error returned by a process, wrapped with the name
+// of the process, after which the context passed to the other processes is
+// canceled. Networks with unconnected ports are not run.
+func (net *Network) Run(ctx context.Context) error {
+	if err := net.validate(); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var firstErr error
+	var errMx sync.Mutex
+	wg := &sync.WaitGroup{}
+	for _, p := range net.procs {
+		wg.Add(1)
+		go func(p Process) {
+			defer wg.Done()
+			err := p.Run(ctx)
+			if b, ok := p.(baser); ok {
+				b.base().CloseOutPorts()
+			}
+			if err == nil {
+				return
+			}
+			errMx.Lock()
+			if firstErr == nil {
+				firstErr = fmt.Errorf("process %s: %w", p.Name(), err)
+				cancel()
+			}
+			errMx.Unlock()
+		}(p)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// validate returns the errors of setting up the network, and of the ports of
+// its processes that are not connected
+func (net *Network) validate() error {
+	errs := append([]error{}, net.errs...)
+	names := make([]string, 0, len(net.procs))
+	for name := range net.procs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b, ok := net.procs[name].(baser)
+		if !ok {
+			continue
+		}
+		p := b.base()
+		errs = append(errs, p.errs...)
+		for _, pt := range p.ports {
+			if !pt.connected() {
+				errs = append(errs, fmt.Errorf("port %s.%s is not connected", name, pt.Name()))
+			}
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	msgs := []string{}
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	return errors.New(strings.Join(msgs, "\n"))
+}
+
+// ------------------------------------------------------------------------
+// BaseProcess
+// ------------------------------------------------------------------------
+
+// BaseProcess is embedded in processes, and keeps track of their name and
+// ports, which are created with NewInPort and NewOutPort
+type BaseProcess struct {
+	net   *Network
+	name  string
+	ports []port
+	errs  []error
+}
+
+// port is an in-port or out-port of any type
+type port interface {
+	Name() string
+	connected() bool
+	// close closes out-ports, and does nothing for in-ports
+	close()
+}
+
+// baser is implemented by processes embedding BaseProcess
+type baser interface {
+	base() *BaseProcess
+}
+
+// NewBaseProcess returns a new BaseProcess, for a process with name name in
+// the network net
+func NewBaseProcess(net *Network, name string) BaseProcess {
+	return BaseProcess{net: net, name: name}
+}
+
+// Name returns the name of the process
+func (p *BaseProcess) Name() string {
+	return p.name
+}
+
+// Network returns the network of the process
+func (p *BaseProcess) Network() *Network {
+	return p.net
+}
+
+// CloseOutPorts closes all out-ports of the process, which is also done by
+// Network.Run when the process returns
+func (p *BaseProcess) CloseOutPorts() {
+	for _, pt := range p.ports {
+		pt.close()
+	}
+}
+
+func (p *BaseProcess) base() *BaseProcess {
+	return p
+}
+
+// addPort adds the port pt, unless the process has a port with the same
+// name, which makes Network.Run return an error
+func (p *BaseProcess) addPort(pt port) {
+	for _, other := range p.ports {
+		if other.Name() == pt.Name() {
+			p.errs = append(p.errs, fmt.Errorf("process %s has several ports with name (%s)", p.name, pt.Name()))
+			return
+		}
+	}
+	p.ports = append(p.ports, pt)
+}
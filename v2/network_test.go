@@ -0,0 +1,156 @@
+//go:build flowbase_v2
+
+package flowbase
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type source struct {
+	BaseProcess
+	Out    *OutPort[int]
+	values []int
+}
+
+func newSource(net *Network, name string, values ...int) *source {
+	p := &source{BaseProcess: NewBaseProcess(net, name), values: values}
+	p.Out = NewOutPort[int](&p.BaseProcess, "out")
+	net.AddProc(p)
+	return p
+}
+
+func (p *source) Run(ctx context.Context) error {
+	for _, v := range p.values {
+		if err := p.Out.Send(ctx, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// doubler doubles the values it receives, and fails on negative values
+type doubler struct {
+	BaseProcess
+	In  *InPort[int]
+	Out *OutPort[int]
+}
+
+func newDoubler(net *Network, name string) *doubler {
+	p := &doubler{BaseProcess: NewBaseProcess(net, name)}
+	p.In = NewInPort[int](&p.BaseProcess, "in")
+	p.Out = NewOutPort[int](&p.BaseProcess, "out")
+	net.AddProc(p)
+	return p
+}
+
+func (p *doubler) Run(ctx context.Context) error {
+	for {
+		n, err := p.In.Recv(ctx)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if n < 0 {
+			return errors.New("negative value")
+		}
+		if err := p.Out.Send(ctx, 2*n); err != nil {
+			return err
+		}
+	}
+}
+
+type collector struct {
+	BaseProcess
+	In     *InPort[int]
+	values []int
+}
+
+func newCollector(net *Network, name string) *collector {
+	p := &collector{BaseProcess: NewBaseProcess(net, name)}
+	p.In = NewInPort[int](&p.BaseProcess, "in")
+	net.AddProc(p)
+	return p
+}
+
+func (p *collector) Run(ctx context.Context) error {
+	for {
+		n, err := p.In.Recv(ctx)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		p.values = append(p.values, n)
+	}
+}
+
+func TestNetworkRun(t *testing.T) {
+	net := NewNetwork("TestNetworkRun")
+	src := newSource(net, "src", 1, 2, 3)
+	dbl := newDoubler(net, "dbl")
+	out := newCollector(net, "out")
+	dbl.In.From(src.Out)
+	out.In.From(dbl.Out)
+
+	if err := net.Run(context.Background()); err != nil {
+		t.Fatalf("Network failed: %v", err)
+	}
+	if !reflect.DeepEqual(out.values, []int{2, 4, 6}) {
+		t.Errorf("Expected [2 4 6], got %v", out.values)
+	}
+}
+
+func TestNetworkRunReturnsFirstError(t *testing.T) {
+	net := NewNetwork("TestNetworkRunReturnsFirstError")
+	// More values than fit in the buffers, so that the source blocks until
+	// it is canceled
+	values := make([]int, 10*BufSize)
+	values[0] = -1
+	src := newSource(net, "src", values...)
+	dbl := newDoubler(net, "dbl")
+	out := newCollector(net, "out")
+	dbl.In.From(src.Out)
+	out.In.From(dbl.Out)
+
+	err := net.Run(context.Background())
+	if err == nil || err.Error() != "process dbl: negative value" {
+		t.Errorf("Expected the error of dbl, got: %v", err)
+	}
+}
+
+func TestNetworkRunCanceled(t *testing.T) {
+	net := NewNetwork("TestNetworkRunCanceled")
+	src := newSource(net, "src", make([]int, 10*BufSize)...)
+	out := newCollector(net, "out")
+	out.In.From(src.Out)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := net.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got: %v", err)
+	}
+}
+
+func TestNetworkValidation(t *testing.T) {
+	net := NewNetwork("TestNetworkValidation")
+	src := newSource(net, "src")
+	newSource(net, "src")
+	dbl := newDoubler(net, "dbl")
+	dbl.In.From(src.Out)
+
+	err := net.Run(context.Background())
+	if err == nil {
+		t.Fatal("Expected an error for the invalid network")
+	}
+	for _, msg := range []string{"a process with name (src) already exists", "port dbl.out is not connected"} {
+		if !strings.Contains(err.Error(), msg) {
+			t.Errorf("Expected error %q to contain %q", err, msg)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+//go:build flowbase_v2
+
+package flowbase
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+)
+
+// BufSize is the buffer size of the channels of in-ports
+var BufSize = 128
+
+// ErrNotConnected is returned when sending on an out-port which is not
+// connected to any in-port
+var ErrNotConnected = errors.New("port is not connected")
+
+// ------------------------------------------------------------------------
+// InPort
+// ------------------------------------------------------------------------
+
+// InPort is an in-port receiving values of type T, from one or more
+// out-ports
+type InPort[T any] struct {
+	name    string
+	ch      chan T
+	mx      sync.Mutex
+	remotes int
+	closed  int
+}
+
+// NewInPort returns a new in-port of the process p, with name name
+func NewInPort[T any](p *BaseProcess, name string) *InPort[T] {
+	pt := &InPort[T]{name: name, ch: make(chan T, BufSize)}
+	p.addPort(pt)
+	return pt
+}
+
+// Name returns the name of the port
+func (pt *InPort[T]) Name() string {
+	return pt.name
+}
+
+// From connects the out-port out to the in-port
+func (pt *InPort[T]) From(out *OutPort[T]) {
+	out.To(pt)
+}
+
+// Recv returns the next value received, or io.EOF when all the connected
+// out-ports are closed, and there are no more values, or the error of ctx if
+// it is canceled first
+func (pt *InPort[T]) Recv(ctx context.Context) (T, error) {
+	select {
+	case v, ok := <-pt.ch:
+		if !ok {
+			return v, io.EOF
+		}
+		return v, nil
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+func (pt *InPort[T]) connected() bool {
+	pt.mx.Lock()
+	defer pt.mx.Unlock()
+	return pt.remotes > 0
+}
+
+func (pt *InPort[T]) close() {}
+
+// remoteClosed closes the channel of the in-port when the last of the
+// connected out-ports is closed
+func (pt *InPort[T]) remoteClosed() {
+	pt.mx.Lock()
+	defer pt.mx.Unlock()
+	pt.closed++
+	if pt.closed == pt.remotes {
+		close(pt.ch)
+	}
+}
+
+// ------------------------------------------------------------------------
+// OutPort
+// ------------------------------------------------------------------------
+
+// OutPort is an out-port sending values of type T, to one or more in-ports
+type OutPort[T any] struct {
+	name      string
+	remotes   []*InPort[T]
+	closeOnce sync.Once
+}
+
+// NewOutPort returns a new out-port of the process p, with name name
+func NewOutPort[T any](p *BaseProcess, name string) *OutPort[T] {
+	pt := &OutPort[T]{name: name}
+	p.addPort(pt)
+	return pt
+}
+
+// Name returns the name of the port
+func (pt *OutPort[T]) Name() string {
+	return pt.name
+}
+
+// To connects the out-port to the in-port in
+func (pt *OutPort[T]) To(in *InPort[T]) {
+	pt.remotes = append(pt.remotes, in)
+	in.mx.Lock()
+	in.remotes++
+	in.mx.Unlock()
+}
+
+// Send sends v to all connected in-ports, or returns the error of ctx if it
+// is canceled before all of them have room for it
+func (pt *OutPort[T]) Send(ctx context.Context, v T) error {
+	if len(pt.remotes) == 0 {
+		return ErrNotConnected
+	}
+	for _, in := range pt.remotes {
+		select {
+		case in.ch <- v:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Close closes the out-port, after which the connected in-ports return
+// io.EOF once they have received all values sent from all their out-ports.
+// Closing an out-port more than once does nothing.
+func (pt *OutPort[T]) Close() {
+	pt.closeOnce.Do(func() {
+		for _, in := range pt.remotes {
+			in.remoteClosed()
+		}
+	})
+}
+
+func (pt *OutPort[T]) connected() bool {
+	return len(pt.remotes) > 0
+}
+
+func (pt *OutPort[T]) close() {
+	pt.Close()
+}
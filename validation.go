@@ -0,0 +1,221 @@
+package flowbase
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Validator validates packets arriving on an in-port, returning an error
+// describing what is wrong with invalid packets
+type Validator func(ip *Packet) error
+
+// ValidationError is sent on the invalid-packets out-port of an in-port
+// with a validator (see InPort.SetValidator), for each packet failing
+// validation
+type ValidationError struct {
+	Port   string
+	Packet *Packet
+	Err    error
+}
+
+// Error returns the validation report
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("Invalid packet (%s) on in-port (%s): %v", e.Packet.ID(), e.Port, e.Err)
+}
+
+// Unwrap returns the error returned by the validator
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// SetValidator makes the in-port validate all incoming packets with
+// validator. Invalid packets are not delivered to the process, but sent as
+// *ValidationErrors on the out-port invalid, which is typically a
+// dead-letter out-port of the same process. If invalid is nil, the network
+// fails on invalid packets instead.
+func (pt *InPort) SetValidator(validator Validator, invalid *OutPort) {
+	pt.validator = validator
+	pt.invalid = invalid
+}
+
+// validate validates ip, and returns whether it is valid. Invalid packets are
+// sent on the invalid-packets out-port.
+func (pt *InPort) validate(ip *Packet) bool {
+	err := pt.validator(ip)
+	if err == nil {
+		return true
+	}
+	verr := &ValidationError{Port: pt.FullName(), Packet: ip, Err: err}
+	if pt.invalid == nil {
+		pt.Fail(verr)
+	}
+	pt.invalid.Send(verr)
+	return false
+}
+
+// ------------------------------------------------------------------------
+// JSON schema validation
+// ------------------------------------------------------------------------
+
+// JSONSchemaValidator returns a Validator checking that the data of packets,
+// as encoded to JSON, conforms to the JSON schema schema. The supported
+// subset of JSON schema is the keywords type, enum, const, properties,
+// required, additionalProperties (as boolean), items, minItems, maxItems,
+// minimum, maximum, minLength, maxLength and pattern.
+func JSONSchemaValidator(schema string) Validator {
+	sch := map[string]any{}
+	if err := json.Unmarshal([]byte(schema), &sch); err != nil {
+		Failf("Could not parse JSON schema: %v", err)
+	}
+	return func(ip *Packet) error {
+		data, err := json.Marshal(ip.Data())
+		if err != nil {
+			return errWrap(err, "Could not encode packet data as JSON")
+		}
+		var v any
+		if err := json.Unmarshal(data, &v); err != nil {
+			return errWrap(err, "Could not decode packet data as JSON")
+		}
+		errs := validateJSONSchema(sch, v, "")
+		if len(errs) > 0 {
+			return fmt.Errorf("%s", strings.Join(errs, "; "))
+		}
+		return nil
+	}
+}
+
+// validateJSONSchema validates the JSON value v against the schema sch, and
+// returns a message for every violation, prefixed by the JSON pointer path
+// of the offending value
+func validateJSONSchema(sch map[string]any, v any, path string) []string {
+	errs := []string{}
+	fail := func(msg string, parts ...any) {
+		p := path
+		if p == "" {
+			p = "/"
+		}
+		errs = append(errs, p+": "+fmt.Sprintf(msg, parts...))
+	}
+
+	if typ, ok := sch["type"]; ok {
+		types := []string{}
+		switch t := typ.(type) {
+		case string:
+			types = append(types, t)
+		case []any:
+			for _, ti := range t {
+				types = append(types, fmt.Sprint(ti))
+			}
+		}
+		if !strInSlice(jsonType(v), types) && !(jsonType(v) == "integer" && strInSlice("number", types)) {
+			fail("expected type %s, got %s", strings.Join(types, " or "), jsonType(v))
+			return errs
+		}
+	}
+	if enum, ok := sch["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			if jsonEqual(e, v) {
+				found = true
+			}
+		}
+		if !found {
+			fail("value %v is not one of %v", v, enum)
+		}
+	}
+	if c, ok := sch["const"]; ok && !jsonEqual(c, v) {
+		fail("value %v is not %v", v, c)
+	}
+
+	switch val := v.(type) {
+	case map[string]any:
+		if required, ok := sch["required"].([]any); ok {
+			for _, r := range required {
+				if _, ok := val[fmt.Sprint(r)]; !ok {
+					fail("missing required property %q", r)
+				}
+			}
+		}
+		props, _ := sch["properties"].(map[string]any)
+		keys := []string{}
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if propSch, ok := props[k].(map[string]any); ok {
+				errs = append(errs, validateJSONSchema(propSch, val[k], path+"/"+k)...)
+			} else if additional, ok := sch["additionalProperties"].(bool); ok && !additional {
+				fail("unexpected property %q", k)
+			}
+		}
+	case []any:
+		if n, ok := sch["minItems"].(float64); ok && float64(len(val)) < n {
+			fail("expected at least %v items, got %d", n, len(val))
+		}
+		if n, ok := sch["maxItems"].(float64); ok && float64(len(val)) > n {
+			fail("expected at most %v items, got %d", n, len(val))
+		}
+		if itemSch, ok := sch["items"].(map[string]any); ok {
+			for i, item := range val {
+				errs = append(errs, validateJSONSchema(itemSch, item, fmt.Sprintf("%s/%d", path, i))...)
+			}
+		}
+	case float64:
+		if n, ok := sch["minimum"].(float64); ok && val < n {
+			fail("value %v is less than minimum %v", val, n)
+		}
+		if n, ok := sch["maximum"].(float64); ok && val > n {
+			fail("value %v is greater than maximum %v", val, n)
+		}
+	case string:
+		if n, ok := sch["minLength"].(float64); ok && float64(len([]rune(val))) < n {
+			fail("expected at least %v characters, got %d", n, len([]rune(val)))
+		}
+		if n, ok := sch["maxLength"].(float64); ok && float64(len([]rune(val))) > n {
+			fail("expected at most %v characters, got %d", n, len([]rune(val)))
+		}
+		if ptn, ok := sch["pattern"].(string); ok {
+			re, err := regexp.Compile(ptn)
+			if err != nil {
+				fail("invalid pattern %q in schema: %v", ptn, err)
+			} else if !re.MatchString(val) {
+				fail("value %q does not match pattern %q", val, ptn)
+			}
+		}
+	}
+	return errs
+}
+
+// jsonType returns the JSON schema type name of the decoded JSON value v
+func jsonType(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if val == math.Trunc(val) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// jsonEqual tells whether the decoded JSON values a and b are equal
+func jsonEqual(a any, b any) bool {
+	aj, _ := json.Marshal(a)
+	bj, _ := json.Marshal(b)
+	return string(aj) == string(bj)
+}
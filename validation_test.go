@@ -0,0 +1,58 @@
+package flowbase
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestJSONSchemaValidator(t *testing.T) {
+	validate := JSONSchemaValidator(`{
+		"type": "object",
+		"required": ["name", "age"],
+		"properties": {
+			"name": {"type": "string", "minLength": 1},
+			"age": {"type": "integer", "minimum": 0},
+			"tags": {"type": "array", "items": {"type": "string"}}
+		}
+	}`)
+
+	valid := map[string]any{"name": "anna", "age": 3, "tags": []string{"a"}}
+	if err := validate(NewPacket(valid)); err != nil {
+		t.Errorf("Valid data failed validation: %v", err)
+	}
+
+	invalid := map[string]any{"name": "", "age": 1.5, "tags": []any{"a", 2}}
+	err := validate(NewPacket(invalid))
+	if err == nil {
+		t.Fatalf("Invalid data passed validation")
+	}
+	for _, expected := range []string{"/name: expected at least 1", "/age: expected type integer", "/tags/1: expected type string"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Validation report (%v) does not contain (%s)", err, expected)
+		}
+	}
+}
+
+func TestInPortValidatorRoutesInvalid(t *testing.T) {
+	inp := NewInPort("in")
+	invalid := NewOutPort("invalid")
+	deadLetters := NewInPort("deadletters")
+	invalid.To(deadLetters)
+	inp.SetValidator(func(ip *Packet) error {
+		if ip.Data() == "bad" {
+			return errors.New("bad data")
+		}
+		return nil
+	}, invalid)
+
+	inp.Send(NewPacket("good"))
+	inp.Send(NewPacket("bad"))
+
+	assertEqualValues(t, "good", (<-inp.Chan).Data())
+	verr := (<-deadLetters.Chan).Data().(*ValidationError)
+	assertEqualValues(t, "bad", verr.Packet.Data())
+	if len(inp.Chan) != 0 {
+		t.Errorf("Invalid packet was delivered")
+	}
+}
@@ -0,0 +1,118 @@
+package flowbase
+
+import (
+	"fmt"
+	"time"
+)
+
+// minWatermarkInterval is the shortest interval at which the queues of
+// in-ports with high-water marks are checked
+const minWatermarkInterval = 10 * time.Millisecond
+
+// highWaterMark is the high-water mark of an in-port, and the state of the
+// check of its queue
+type highWaterMark struct {
+	mark  int
+	grace time.Duration
+	// since is when the queue last went above the mark, or zero if it is not
+	// above it
+	since   time.Time
+	alarmed bool
+}
+
+// SetCapacity sets the number of packets that can be queued in the in-port,
+// waiting to be received, after which senders block. It defaults to BUFSIZE.
+// It must be called before the network is run, and has no effect on
+// conflating in-ports. For in-ports using ring buffers, it is the capacity of
+// each incoming connection.
+func (pt *InPort) SetCapacity(capacity int) {
+	if pt.conflate {
+		return
+	}
+	if pt.rings != nil {
+		pt.rings = newRingInput(capacity)
+		return
+	}
+	pt.Chan = make(chan *Packet, capacity)
+}
+
+// Capacity returns the number of packets that can be queued in the in-port
+func (pt *InPort) Capacity() int {
+	if pt.rings != nil {
+		return ringCapacity(pt.rings.size)
+	}
+	if pt.queue != nil {
+		return cap(pt.queue)
+	}
+	return cap(pt.Chan)
+}
+
+// SetHighWaterMark makes the network warn about the process of the in-port
+// being a slow consumer, when more than mark packets have been queued in the
+// in-port for longer than grace, which is an early warning before senders
+// are blocked, or memory runs out. The warning is logged, and published as an
+// EventHighWaterMark event, once until the queue is back at or below the
+// mark. It must be called before the network is run.
+func (pt *InPort) SetHighWaterMark(mark int, grace time.Duration) {
+	pt.hwm = &highWaterMark{mark: mark, grace: grace}
+}
+
+// watchHighWaterMarks checks the queues of the in-ports of procs that have
+// high-water marks, until stop is closed
+func (net *Network) watchHighWaterMarks(procs map[string]Node, stop chan struct{}) {
+	ports := []*InPort{}
+	interval := time.Duration(0)
+	for _, name := range sortedKeys(procs) {
+		for _, ptName := range sortedKeys(procs[name].InPorts()) {
+			pt := procs[name].InPorts()[ptName]
+			if pt.hwm == nil {
+				continue
+			}
+			ports = append(ports, pt)
+			if interval == 0 || pt.hwm.grace/2 < interval {
+				interval = pt.hwm.grace / 2
+			}
+		}
+	}
+	if len(ports) == 0 {
+		return
+	}
+	if interval < minWatermarkInterval {
+		interval = minWatermarkInterval
+	}
+	ticker := net.Clock().NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+			now := net.Clock().Now()
+			for _, pt := range ports {
+				net.checkHighWaterMark(pt, now)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// checkHighWaterMark warns about the process of the in-port pt if its queue
+// has been above its high-water mark for longer than the grace period, at
+// the time now
+func (net *Network) checkHighWaterMark(pt *InPort, now time.Time) {
+	hwm := pt.hwm
+	depth := pt.Len()
+	if depth <= hwm.mark {
+		hwm.since, hwm.alarmed = time.Time{}, false
+		return
+	}
+	if hwm.since.IsZero() {
+		hwm.since = now
+	}
+	if hwm.alarmed || now.Sub(hwm.since) < hwm.grace {
+		return
+	}
+	hwm.alarmed = true
+	msg := fmt.Sprintf("%d packets queued in in-port (%s), above its high-water mark of %d, for %s: process (%s) is a slow consumer", depth, pt.FullName(), hwm.mark, now.Sub(hwm.since), pt.process.Name())
+	Warning.Printf("[Network:%s] %s\n", net.name, msg)
+	net.publish(&Event{Type: EventHighWaterMark, Process: pt.process.Name(), Port: pt.Name(), QueueDepth: depth, Message: msg})
+}
@@ -0,0 +1,57 @@
+package flowbase
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSetCapacity(t *testing.T) {
+	initTestLogs()
+	pt := NewInPort("in")
+	pt.SetCapacity(3)
+	assertEqualValues(t, 3, pt.Capacity())
+
+	pt.SetRingBuffer(4)
+	pt.SetCapacity(16)
+	assertEqualValues(t, 16, pt.Capacity())
+}
+
+func TestHighWaterMark(t *testing.T) {
+	initTestLogs()
+	net := NewNetwork("TestHighWaterMark")
+
+	src := net.NewFunc("src", func(out chan<- int) error {
+		for i := 0; i < 20; i++ {
+			out <- i
+		}
+		return nil
+	})
+	slow := net.NewFunc("slow", func(in <-chan int) error {
+		for range in {
+			time.Sleep(10 * time.Millisecond)
+		}
+		return nil
+	})
+	slow.In().From(src.Out())
+	slow.In().SetHighWaterMark(5, 30*time.Millisecond)
+
+	var events []*Event
+	var mx sync.Mutex
+	net.Subscribe(EventTypes(EventHighWaterMark), func(e *Event) {
+		mx.Lock()
+		defer mx.Unlock()
+		events = append(events, e)
+	})
+
+	net.Run()
+
+	if len(events) != 1 {
+		t.Fatalf("Expected one high-water-mark event, got %d", len(events))
+	}
+	assertEqualValues(t, "slow", events[0].Process)
+	assertEqualValues(t, "in", events[0].Port)
+	if events[0].QueueDepth <= 5 {
+		t.Errorf("Expected a queue depth above 5, got %d", events[0].QueueDepth)
+	}
+}
@@ -0,0 +1,41 @@
+package flowbase
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// setWorkspaceOutPath places the temporary path of the output outName of
+// task t inside the task's workspace. Streaming outputs are not moved, as
+// they are never finalized, but get absolute paths, so that they can be
+// found from within the workspace.
+func (t *ExecTask) setWorkspaceOutPath(outName string, streaming bool) {
+	if streaming {
+		t.OutPaths[outName] = absPath(t.OutPaths[outName])
+		t.TempOutPaths[outName] = t.OutPaths[outName]
+		return
+	}
+	t.TempOutPaths[outName] = filepath.Join(t.WorkDir, outName, filepath.Base(t.OutPaths[outName]))
+}
+
+// workspaceInPath returns the absolute path of the input value val, if it is
+// a relative path to an existing file, so that it can be found from within
+// the workspace of task t. Other values are returned unchanged.
+func (t *ExecTask) workspaceInPath(val string) string {
+	if filepath.IsAbs(val) {
+		return val
+	}
+	if _, err := os.Stat(val); err != nil {
+		return val
+	}
+	return absPath(val)
+}
+
+// absPath returns the absolute version of path
+func absPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		Failf("Could not get absolute path of %s: %v", path, err)
+	}
+	return abs
+}